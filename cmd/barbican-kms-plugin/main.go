@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"net/http"
 	"os"
 	"os/signal"
 
@@ -25,12 +26,14 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/kms/server"
 	"k8s.io/cloud-provider-openstack/pkg/version"
 	"k8s.io/component-base/cli"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 )
 
 var (
-	socketPath  string
-	cloudConfig string
+	socketPath   string
+	httpEndpoint string
+	opts         server.Options
 )
 
 func main() {
@@ -40,7 +43,20 @@ func main() {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			sigChan := make(chan os.Signal, 1)
 			signal.Notify(sigChan, unix.SIGTERM, unix.SIGINT)
-			err := server.Run(cloudConfig, socketPath, sigChan)
+
+			if httpEndpoint != "" {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", legacyregistry.HandlerWithReset())
+				go func() {
+					// The plugin keeps serving the apiserver without its metrics
+					err := http.ListenAndServe(httpEndpoint, mux)
+					if err != nil {
+						klog.Errorf("failed to listen & serve metrics from %q: %v", httpEndpoint, err)
+					}
+				}()
+			}
+
+			err := server.Run(opts, socketPath, sigChan)
 			return err
 		},
 		Version: version.Version,
@@ -51,10 +67,13 @@ func main() {
 		klog.Fatalf("Unable to mark flag socketpath as required: %v", err)
 	}
 
-	cmd.PersistentFlags().StringVar(&cloudConfig, "cloud-config", "", "Barbican KMS Plugin cloud config")
-	if err := cmd.MarkPersistentFlagRequired("cloud-config"); err != nil {
-		klog.Fatalf("Unable to mark flag cloud-config as required: %v", err)
-	}
+	cmd.PersistentFlags().StringVar(&opts.CloudConfig, "cloud-config", "", "Barbican KMS Plugin cloud config, when empty the credentials are read from the clouds.yaml")
+	cmd.PersistentFlags().StringVar(&opts.CloudsFile, "clouds-file", "", "Path to the clouds.yaml file, defaults to the OS_CLIENT_CONFIG_FILE environment variable and the standard locations")
+	cmd.PersistentFlags().StringVar(&opts.Cloud, "cloud", "", "Name of the cloud in the clouds.yaml file, defaults to the OS_CLOUD environment variable")
+	cmd.PersistentFlags().StringVar(&opts.KeyID, "key-id", "", "ID of the Barbican key, overrides the key-id of the cloud config")
+	cmd.PersistentFlags().StringVar(&opts.KeyAlias, "key-alias", "", "Name of the rotated Barbican keys, overrides the key-alias of the cloud config")
+
+	cmd.PersistentFlags().StringVar(&httpEndpoint, "http-endpoint", "", "The TCP network address where the HTTP server for providing metrics for diagnostics, will listen (example: `:8080`). The default is empty string, which means the server is disabled.")
 
 	code := cli.Run(cmd)
 	os.Exit(code)
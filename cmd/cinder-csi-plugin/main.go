@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -43,7 +44,21 @@ var (
 	provideControllerService bool
 	provideNodeService       bool
 	noClient                 bool
+	nodeEphemeralVolumes     bool
+	ephemeralMaxCapacity     string
+	ephemeralTypes           []string
+	ephemeralGCInterval      time.Duration
 	withTopology             bool
+	shutdownTimeout          time.Duration
+	shutdownJournal          string
+	volumeHealthInterval     time.Duration
+	pvLabelSyncInterval      time.Duration
+	flattenInterval          time.Duration
+	flattenChainDepth        int
+	flattenWindow            string
+	flattenLeaseNamespace    string
+	zoneBalanceInterval      time.Duration
+	namespaceCapacityLimits  bool
 )
 
 func main() {
@@ -99,8 +114,27 @@ func main() {
 	cmd.PersistentFlags().BoolVar(&provideControllerService, "provide-controller-service", true, "If set to true then the CSI driver does provide the controller service (default: true)")
 	cmd.PersistentFlags().BoolVar(&provideNodeService, "provide-node-service", true, "If set to true then the CSI driver does provide the node service (default: true)")
 	cmd.PersistentFlags().BoolVar(&noClient, "node-service-no-os-client", false, "If set to true then the CSI driver node service will not use the OpenStack client (default: false)")
+	cmd.PersistentFlags().BoolVar(&nodeEphemeralVolumes, "node-ephemeral-volumes", false, "If set to true then the node service creates and deletes the Cinder volumes of the CSI inline ephemeral volumes of the pods, which requires the OpenStack credentials in the cloud config of the node service. The default is false, which means the inline ephemeral volumes are refused.")
+	cmd.PersistentFlags().StringVar(&ephemeralMaxCapacity, "node-ephemeral-volume-max-capacity", "", "Maximum capacity of the CSI inline ephemeral volumes, e.g. 10Gi, the larger ones are refused. The default is empty string, which means the capacity is not limited.")
+	cmd.PersistentFlags().StringSliceVar(&ephemeralTypes, "node-ephemeral-volume-types", nil, "Comma-separated list of the volume types allowed for the CSI inline ephemeral volumes, the first one is used when the volume sets no type. The default is empty, which means all the volume types are allowed.")
+	cmd.PersistentFlags().DurationVar(&ephemeralGCInterval, "ephemeral-volume-gc-interval", 0, "Interval of the collection by the controller service of the Cinder volumes of the CSI inline ephemeral volumes left detached by the nodes, e.g. deleted before unpublishing them. The default is 0, which means the volumes are not collected.")
 	cmd.PersistentFlags().MarkDeprecated("node-service-no-os-client", "This flag is deprecated and will be removed in the future. Node service do not use OpenStack credentials anymore.") //nolint:errcheck
 
+	cmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 25*time.Second, "Maximum time to wait for the in-flight CSI operations on SIGTERM, new operations are rejected meanwhile. Keep it below the terminationGracePeriodSeconds of the pod.")
+	cmd.PersistentFlags().StringVar(&shutdownJournal, "shutdown-journal", "", "File the CSI operations still in flight after --shutdown-timeout are persisted to, they are reported on the next start. The default is empty string, which means no journal is written.")
+	cmd.PersistentFlags().DurationVar(&volumeHealthInterval, "volume-health-check-interval", 0, "Interval of the checks cordoning the PersistentVolumes of the attached Cinder volumes in error state with the "+cinder.VolumeUnhealthyAnnotation+" annotation, their attachment to new nodes is refused. The default is 0, which means the volumes are not checked.")
+
+	cmd.PersistentFlags().DurationVar(&pvLabelSyncInterval, "pv-label-sync-interval", 0, "Interval of the synchronization of the volume type, availability zone, encrypted and bootable attributes of the Cinder volumes to labels of their PersistentVolumes. The default is 0, which means the PersistentVolumes are not labeled.")
+
+	cmd.PersistentFlags().IntVar(&flattenChainDepth, "flatten-chain-depth", 0, "Depth of the chains of snapshots and clones from which the Cinder volumes of the PersistentVolumes are flattened by migrating them, which requires the admin role to migrate the volumes and follow their migration. The default is 0, which means the volumes are not flattened.")
+	cmd.PersistentFlags().DurationVar(&flattenInterval, "flatten-interval", time.Hour, "Interval of the checks of the chains of snapshots and clones of the volumes when --flatten-chain-depth is set.")
+	cmd.PersistentFlags().StringVar(&flattenWindow, "flatten-window", "", "Daily maintenance window in UTC the volumes are flattened in, for example `22:00-04:00`. The default is empty string, which means the volumes are flattened at any time.")
+	cmd.PersistentFlags().StringVar(&flattenLeaseNamespace, "flatten-lease-namespace", "kube-system", "Namespace of the Lease electing the controller plugin replica flattening the volumes when --flatten-chain-depth is set.")
+
+	cmd.PersistentFlags().DurationVar(&zoneBalanceInterval, "zone-balance-report-interval", 0, "Interval of the report comparing the availability zones of the PersistentVolumes with the attach capacity of the schedulable nodes of the zones, the zones whose stateful pods are likely to be unschedulable are logged and flagged in the metrics. The default is 0, which means the zones are not reported.")
+
+	cmd.PersistentFlags().BoolVar(&namespaceCapacityLimits, "namespace-capacity-limits", false, "If set to true then the total size of the volumes of the namespaces is limited by their "+cinder.NamespaceCapacityLimitAnnotation+" annotation in GiB, when the volumes are created and expanded. The namespaceCapacityLimit StorageClass parameter is enforced regardless. The default is false, which means the annotation is ignored.")
+
 	openstack.AddExtraFlags(pflag.CommandLine)
 
 	code := cli.Run(cmd)
@@ -109,12 +143,44 @@ func main() {
 
 func handle() {
 	// Initialize cloud
-	d := cinder.NewDriver(&cinder.DriverOpts{
-		Endpoint:     endpoint,
-		ClusterID:    cluster,
-		PVCLister:    csi.GetPVCLister(),
-		WithTopology: withTopology,
-	})
+	opts := &cinder.DriverOpts{
+		Endpoint:        endpoint,
+		ClusterID:       cluster,
+		PVCLister:       csi.GetPVCLister(),
+		PVLister:        csi.GetPVLister(),
+		PodClient:       csi.GetPodClient(),
+		WithTopology:    withTopology,
+		ShutdownTimeout: shutdownTimeout,
+		ShutdownJournal: shutdownJournal,
+
+		EphemeralVolumeGCInterval: ephemeralGCInterval,
+	}
+	if provideControllerService && (volumeHealthInterval > 0 || pvLabelSyncInterval > 0 || flattenChainDepth > 0 || zoneBalanceInterval > 0) {
+		window, err := cinder.ParseMaintenanceWindow(flattenWindow)
+		if err != nil {
+			klog.Fatalf("Invalid --flatten-window: %v", err)
+		}
+
+		opts.KubeClient = csi.GetKubeClient()
+		opts.VolumeHealthCheckInterval = volumeHealthInterval
+		opts.PVLabelSyncInterval = pvLabelSyncInterval
+		opts.FlattenInterval = flattenInterval
+		opts.FlattenChainDepth = flattenChainDepth
+		opts.FlattenWindow = window
+		opts.FlattenLeaseNamespace = flattenLeaseNamespace
+		opts.ZoneBalanceReportInterval = zoneBalanceInterval
+	}
+	if provideControllerService && namespaceCapacityLimits {
+		opts.NamespaceCapacityLimits = true
+		if opts.KubeClient == nil {
+			opts.KubeClient = csi.GetKubeClient()
+		}
+	}
+	if provideControllerService && opts.PVCLister != nil && opts.KubeClient == nil {
+		// The volumes of the PVCs of a spread group are recorded on the PVCs
+		opts.KubeClient = csi.GetKubeClient()
+	}
+	d := cinder.NewDriver(opts)
 
 	openstack.InitOpenStackProvider(cloudConfig, httpEndpoint)
 
@@ -146,6 +212,17 @@ func handle() {
 		metadata := metadata.GetMetadataProvider(cfg.Metadata.SearchOrder)
 
 		d.SetupNodeService(mount, metadata, cfg.BlockStorage, additionalTopologies)
+
+		if nodeEphemeralVolumes {
+			cloud, err := openstack.GetOpenStackProvider(cloudNames[0])
+			if err != nil {
+				klog.Warningf("Failed to GetOpenStackProvider %s: %v", cloudNames[0], err)
+				return
+			}
+			if err := d.SetupNodeEphemeralVolumes(cloud, cinder.EphemeralVolumeOpts{MaxCapacity: ephemeralMaxCapacity, Types: ephemeralTypes}); err != nil {
+				klog.Fatalf("Invalid ephemeral volume options: %v", err)
+			}
+		}
 	}
 
 	d.Run()
@@ -21,11 +21,12 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
+	tokens3 "github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
 	"github.com/gophercloud/utils/v2/openstack/clientconfig"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"k8s.io/component-base/cli"
 
 	"golang.org/x/term"
@@ -34,21 +35,6 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/version"
 )
 
-const errRespTemplate string = `{
-	"apiVersion": "client.authentication.k8s.io/v1beta1",
-	"kind": "ExecCredential",
-	"status": {}
-}`
-
-const respTemplate string = `{
-	"apiVersion": "client.authentication.k8s.io/v1beta1",
-	"kind": "ExecCredential",
-	"status": {
-		"token": "%v",
-		"expirationTimestamp": "%v"
-	}
-}`
-
 func promptForString(field string, r io.Reader, show bool) (result string, err error) {
 	// We have to print output to Stderr, because Stdout is redirected and not shown to the user.
 	fmt.Fprintf(os.Stderr, "Please enter %s: ", field)
@@ -64,8 +50,8 @@ func promptForString(field string, r io.Reader, show bool) (result string, err e
 	return result, err
 }
 
-// prompt pulls keystone auth url, domain, project, username and password from stdin,
-// if they are not specified initially (i.e. equal "").
+// prompt pulls keystone auth url, domain, project and username from stdin,
+// if they are not specified initially (i.e. equal ""). The missing password is prompted for afterwards.
 func prompt(url string, domain string, user string, project string, password string, applicationCredentialID string, applicationCredentialName string, applicationCredentialSecret string) (gophercloud.AuthOptions, error) {
 	var err error
 	var options gophercloud.AuthOptions
@@ -98,13 +84,6 @@ func prompt(url string, domain string, user string, project string, password str
 		}
 	}
 
-	if password == "" && applicationCredentialID == "" && applicationCredentialName == "" {
-		password, err = promptForString("password", nil, false)
-		if err != nil {
-			return options, err
-		}
-	}
-
 	options = gophercloud.AuthOptions{
 		IdentityEndpoint:            url,
 		Username:                    user,
@@ -135,6 +114,35 @@ func argumentsAreSet(url, user, project, password, domain, applicationCredential
 	return false
 }
 
+// overrideCloudAuthOptions replaces the settings of the cloud with the flags set on the command line, the flags
+// defaulting to the OS_* environment variables don't override them.
+func overrideCloudAuthOptions(fs *pflag.FlagSet, opts *gophercloud.AuthOptions) {
+	if fs.Changed("keystone-url") {
+		opts.IdentityEndpoint = url
+	}
+	if fs.Changed("domain-name") {
+		opts.DomainName, opts.DomainID = domain, ""
+	}
+	if fs.Changed("user-name") {
+		opts.Username, opts.UserID = user, ""
+	}
+	if fs.Changed("project-name") {
+		opts.TenantName, opts.TenantID = project, ""
+	}
+	if fs.Changed("password") {
+		opts.Password = password
+	}
+	if fs.Changed("application-credential-id") {
+		opts.ApplicationCredentialID = applicationCredentialID
+	}
+	if fs.Changed("application-credential-name") {
+		opts.ApplicationCredentialName = applicationCredentialName
+	}
+	if fs.Changed("application-credential-secret") {
+		opts.ApplicationCredentialSecret = applicationCredentialSecret
+	}
+}
+
 var (
 	url                         string
 	domain                      string
@@ -149,6 +157,9 @@ var (
 	applicationCredentialID     string
 	applicationCredentialName   string
 	applicationCredentialSecret string
+	tokenCacheDir               string
+	cloud                       string
+	useKeyring                  bool
 )
 
 func main() {
@@ -156,7 +167,7 @@ func main() {
 		Use:   "client-keystone-auth",
 		Short: "Keystone client credential plugin for Kubernetes",
 		Run: func(cmd *cobra.Command, args []string) {
-			handle()
+			handle(cmd.Flags())
 		},
 		Version: version.Version,
 	}
@@ -172,15 +183,38 @@ func main() {
 	cmd.PersistentFlags().StringVar(&applicationCredentialID, "application-credential-id", os.Getenv("OS_APPLICATION_CREDENTIAL_ID"), "Application Credential ID")
 	cmd.PersistentFlags().StringVar(&applicationCredentialName, "application-credential-name", os.Getenv("OS_APPLICATION_CREDENTIAL_NAME"), "Application Credential Name")
 	cmd.PersistentFlags().StringVar(&applicationCredentialSecret, "application-credential-secret", os.Getenv("OS_APPLICATION_CREDENTIAL_SECRET"), "Application Credential Secret")
+	cmd.PersistentFlags().StringVar(&tokenCacheDir, "token-cache-dir", os.Getenv("OS_TOKEN_CACHE_DIR"), "Directory of the encrypted token cache, the tokens are not cached if empty")
+	cmd.PersistentFlags().StringVar(&cloud, "os-cloud", os.Getenv("OS_CLOUD"), "Name of the cloud of clouds.yaml, merged with secure.yaml, to read the credentials from. The credential flags set on the command line override its settings")
+	cmd.PersistentFlags().BoolVar(&useKeyring, "keyring", false, "Read the missing password from the OS keyring, the password entered on the console is stored in it")
 
 	code := cli.Run(cmd)
 	os.Exit(code)
 }
 
-func handle() {
+func handle(fs *pflag.FlagSet) {
+	execInfo, err := keystone.ParseExecInfo(os.Getenv(keystone.ExecInfoEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occurred: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Generate Gophercloud Auth Options based on input data from stdin
 	// if IsTerminal returns "true", or from env variables otherwise.
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
+	// client-go tells whether stdin has been passed to the plugin.
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	if execInfo.Interactive != nil && !*execInfo.Interactive {
+		interactive = false
+	}
+	// OS_CLOUD alone only selects clouds.yaml when the file exists, as it's also exported for the other OpenStack
+	// clients.
+	if cloud != "" && (fs.Changed("os-cloud") || keystone.CloudsYAMLExists()) {
+		options, err = keystone.CloudOptions(cloud)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read clouds.yaml: %s\n", err)
+			os.Exit(1)
+		}
+		overrideCloudAuthOptions(fs, &options.AuthOptions)
+	} else if !interactive {
 		// If all required arguments are set use them
 		if argumentsAreSet(url, user, project, password, domain, applicationCredentialID, applicationCredentialName, applicationCredentialSecret) {
 			options.AuthOptions = gophercloud.AuthOptions{
@@ -210,14 +244,53 @@ func handle() {
 		}
 	}
 
-	options.ClientCertPath = clientCertPath
-	options.ClientKeyPath = clientKeyPath
-	options.ClientCAPath = clientCAPath
+	if clientCertPath != "" {
+		options.ClientCertPath = clientCertPath
+	}
+	if clientKeyPath != "" {
+		options.ClientKeyPath = clientKeyPath
+	}
+	if clientCAPath != "" {
+		options.ClientCAPath = clientCAPath
+	}
 
-	token, err := keystone.GetToken(options)
+	var keyring *keystone.Keyring
+	if useKeyring {
+		keyring = keystone.NewKeyring()
+	}
+	passwordFromKeyring := false
+	passwordPrompted := false
+	if keyring != nil && keystone.NeedsPassword(options) {
+		options.AuthOptions.Password, err = keyring.GetPassword(options.AuthOptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the password from the keyring: %v\n", err)
+		}
+		passwordFromKeyring = options.AuthOptions.Password != ""
+	}
+	if interactive && keystone.NeedsPassword(options) {
+		options.AuthOptions.Password, err = promptForString("password", nil, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read data from console: %s\n", err)
+			os.Exit(1)
+		}
+		passwordPrompted = true
+	}
+
+	var cache *keystone.TokenFileCache
+	if tokenCacheDir != "" {
+		cache = keystone.NewTokenFileCache(tokenCacheDir)
+	}
+
+	token, err := getToken(options, cache, execInfo.Unauthorized)
 	if err != nil {
 		if gophercloud.ResponseCodeIs(err, http.StatusUnauthorized) {
-			fmt.Println(errRespTemplate)
+			if passwordFromKeyring {
+				// The stored password is prompted for again next time.
+				if err := keyring.DeletePassword(options.AuthOptions); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to delete the password from the keyring: %v\n", err)
+				}
+			}
+			printExecCredential(execInfo.APIVersion, nil)
 			os.Stderr.WriteString("Invalid user credentials were provided\n")
 			os.Exit(0)
 		}
@@ -225,6 +298,48 @@ func handle() {
 		os.Exit(1)
 	}
 
-	out := fmt.Sprintf(respTemplate, token.ID, token.ExpiresAt.Format(time.RFC3339Nano))
-	fmt.Println(out)
+	if keyring != nil && passwordPrompted {
+		if err := keyring.SetPassword(options.AuthOptions, options.AuthOptions.Password); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to store the password in the keyring: %v\n", err)
+		}
+	}
+
+	printExecCredential(execInfo.APIVersion, token)
+}
+
+// getToken returns the cached token if it's still valid, or issues a new one. The cached token is dropped when it was
+// rejected by the Kubernetes API.
+func getToken(options keystone.Options, cache *keystone.TokenFileCache, unauthorized bool) (*tokens3.Token, error) {
+	if cache != nil {
+		if unauthorized {
+			if err := cache.Delete(options); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to delete the cached token: %v\n", err)
+			}
+		} else if token, err := cache.Get(options); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the cached token: %v\n", err)
+		} else if token != nil {
+			return token, nil
+		}
+	}
+
+	token, err := keystone.GetToken(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Set(options, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to cache the token: %v\n", err)
+		}
+	}
+	return token, nil
+}
+
+func printExecCredential(apiVersion string, token *tokens3.Token) {
+	out, err := keystone.NewExecCredential(apiVersion, token)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occurred: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
 }
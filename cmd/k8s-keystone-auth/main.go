@@ -48,6 +48,8 @@ func main() {
 		},
 		Version: version.Version,
 	}
+	cmd.AddCommand(newPolicyCommand())
+	cmd.AddCommand(newTrustCommand())
 
 	keystone.AddExtraFlags(pflag.CommandLine)
 
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/cloud-provider-openstack/pkg/identity/keystone"
+)
+
+func newPolicyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Authorization policy tools",
+	}
+	cmd.AddCommand(newPolicyTestCommand())
+	return cmd
+}
+
+func newPolicyTestCommand() *cobra.Command {
+	var policyFile string
+	var req keystone.PolicyRequest
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Evaluate a policy file against a request",
+		Long: "Evaluate a policy file against a request and print the decision and the rule allowing the request. " +
+			"The command exits with 0 when the request is allowed and with 1 otherwise.",
+		Example: "  k8s-keystone-auth policy test --policy-file policy.json --project demo --roles member --verb get --namespace default --resource pods",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			decision, err := keystone.EvaluatePolicyFile(policyFile, req)
+			if err != nil {
+				return err
+			}
+
+			if !decision.Allowed {
+				fmt.Fprintf(cmd.OutOrStdout(), "Decision: deny\nReason: %s\n", decision.Reason)
+				// The usage is not printed with a denied request, cli.Run exits with 1
+				cmd.SilenceUsage = true
+				return fmt.Errorf("request denied by policy file %s", policyFile)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Decision: allow\nMatched rule #%d:\n%s\n", decision.Rule, decision.Policy)
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&policyFile, "policy-file", "", "File containing the policy to evaluate.")
+	fs.StringVar(&req.User, "user", "", "Name of the user making the request.")
+	fs.StringSliceVar(&req.Groups, "groups", nil, "Groups of the user making the request.")
+	fs.StringVar(&req.ProjectID, "project-id", "", "ID of the Keystone project of the user.")
+	fs.StringVar(&req.ProjectName, "project", "", "Name of the Keystone project of the user.")
+	fs.StringSliceVar(&req.Roles, "roles", nil, "Keystone roles of the user in the project.")
+	fs.StringVar(&req.Verb, "verb", "", "Verb of the request, e.g. get, list, create.")
+	fs.StringVar(&req.APIGroup, "api-group", "", "API group of the requested resource.")
+	fs.StringVar(&req.Namespace, "namespace", "", "Namespace of the requested resource, empty for cluster scoped resources.")
+	fs.StringVar(&req.Resource, "resource", "", "Requested resource, e.g. pods.")
+	fs.StringVar(&req.Subresource, "subresource", "", "Requested subresource, e.g. log.")
+	fs.StringVar(&req.Path, "path", "", "Requested non-resource path, e.g. /healthz. Mutually exclusive with --resource.")
+	_ = cmd.MarkFlagRequired("policy-file")
+	_ = cmd.MarkFlagRequired("verb")
+
+	return cmd
+}
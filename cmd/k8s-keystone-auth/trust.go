@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/cloud-provider-openstack/pkg/identity/keystone"
+)
+
+func newTrustCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Keystone trust tools",
+	}
+	cmd.AddCommand(newTrustCreateCommand())
+	return cmd
+}
+
+func newTrustCreateCommand() *cobra.Command {
+	var cloud, region, expiresAt, trusteePrefix string
+	var components, roles []string
+	var trusteeIDs map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create the Keystone trusts of the components",
+		Long: "Create a Keystone trust delegating the minimal roles needed by each component in the project of the " +
+			"administrator credentials, and print the cloud config of the components authenticating with the trusts. " +
+			"A trustee user is created for the components without --trustee-user-id. Default roles: " + defaultTrustRoles() + ".",
+		Example: "  k8s-keystone-auth trust create --os-cloud admin --component openstack-cloud-controller-manager --component cinder-csi-plugin",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			requests, err := parseTrustRequests(components, roles, trusteeIDs, expiresAt)
+			if err != nil {
+				return err
+			}
+
+			options, err := keystone.CloudOptions(cloud)
+			if err != nil {
+				return err
+			}
+			creator, err := keystone.NewTrustCreator(cmd.Context(), options)
+			if err != nil {
+				return err
+			}
+			creator.TrusteePrefix = trusteePrefix
+
+			for _, req := range requests {
+				trust, err := creator.Create(cmd.Context(), req)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "# %s, trust %s\n%s\n", trust.Component, trust.TrustID,
+					trust.CloudConfig(options.AuthOptions.IdentityEndpoint, region))
+			}
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&cloud, "os-cloud", os.Getenv("OS_CLOUD"), "Cloud of clouds.yaml with the administrator credentials, the trustor. Defaults to the OS_CLOUD environment variable.")
+	fs.StringSliceVar(&components, "component", nil, "Components to create a trust for, among "+strings.Join(keystone.TrustComponents(), ", ")+".")
+	fs.StringToStringVar(&trusteeIDs, "trustee-user-id", nil, "Existing trustee user ID of a component, e.g. cinder-csi-plugin=<user ID>.")
+	fs.StringArrayVar(&roles, "roles", nil, "Roles delegated to a component instead of the default ones, e.g. cinder-csi-plugin=member,reader.")
+	fs.StringVar(&expiresAt, "expires-at", "", "Expiration time of the trusts, in RFC 3339 format. The trusts don't expire by default.")
+	fs.StringVar(&region, "region", os.Getenv("OS_REGION_NAME"), "Region set in the cloud configs. Defaults to the OS_REGION_NAME environment variable.")
+	fs.StringVar(&trusteePrefix, "trustee-prefix", "kubernetes-", "Prefix of the name of the trustee users created for the components.")
+	_ = cmd.MarkFlagRequired("component")
+
+	return cmd
+}
+
+// defaultTrustRoles describes the default roles of the components.
+func defaultTrustRoles() string {
+	var s []string
+	for _, component := range keystone.TrustComponents() {
+		roles, _ := keystone.ComponentTrustRoles(component)
+		s = append(s, fmt.Sprintf("%s=%s", component, strings.Join(roles, ",")))
+	}
+	return strings.Join(s, "; ")
+}
+
+// parseTrustRequests returns the trust requests of the components.
+func parseTrustRequests(components, roles []string, trusteeIDs map[string]string, expiresAt string) ([]keystone.TrustRequest, error) {
+	var expiration *time.Time
+	if expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --expires-at: %v", err)
+		}
+		expiration = &t
+	}
+
+	componentRoles := map[string][]string{}
+	for _, r := range roles {
+		component, list, ok := strings.Cut(r, "=")
+		if !ok || list == "" {
+			return nil, fmt.Errorf("invalid --roles %q, must be <component>=<role>[,<role>]", r)
+		}
+		componentRoles[component] = strings.Split(list, ",")
+	}
+
+	requests := make([]keystone.TrustRequest, 0, len(components))
+	for _, component := range components {
+		if _, err := keystone.ComponentTrustRoles(component); err != nil {
+			return nil, err
+		}
+		requests = append(requests, keystone.TrustRequest{
+			Component:     component,
+			TrusteeUserID: trusteeIDs[component],
+			Roles:         componentRoles[component],
+			ExpiresAt:     expiration,
+		})
+	}
+	for component := range trusteeIDs {
+		if !containsComponent(requests, component) {
+			return nil, fmt.Errorf("--trustee-user-id set for %s which is not in --component", component)
+		}
+	}
+	for component := range componentRoles {
+		if !containsComponent(requests, component) {
+			return nil, fmt.Errorf("--roles set for %s which is not in --component", component)
+		}
+	}
+	return requests, nil
+}
+
+func containsComponent(requests []keystone.TrustRequest, component string) bool {
+	for _, req := range requests {
+		if req.Component == component {
+			return true
+		}
+	}
+	return false
+}
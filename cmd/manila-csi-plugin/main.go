@@ -18,10 +18,12 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cloud-provider-openstack/pkg/csi"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/csiclient"
@@ -30,6 +32,7 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/cloud-provider-openstack/pkg/version"
 	"k8s.io/component-base/cli"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 )
 
@@ -39,6 +42,7 @@ var (
 	withTopology          bool
 	protoSelector         string
 	fwdEndpoint           string
+	fwdEndpoints          map[string]string
 	compatibilitySettings string
 
 	// Node information
@@ -49,6 +53,7 @@ var (
 	// Runtime options
 	endpoint                 string
 	runtimeConfigFile        string
+	httpEndpoint             string
 	userAgentData            []string
 	provideControllerService bool
 	provideNodeService       bool
@@ -72,8 +77,15 @@ func main() {
 		Use:   os.Args[0],
 		Short: "CSI Manila driver",
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := validateShareProtocolSelector(protoSelector); err != nil {
-				klog.Fatal(err.Error())
+			if protoSelector != "" || len(fwdEndpoints) == 0 {
+				if err := validateShareProtocolSelector(protoSelector); err != nil {
+					klog.Fatal(err.Error())
+				}
+			}
+			for proto := range fwdEndpoints {
+				if err := validateShareProtocolSelector(proto); err != nil {
+					klog.Fatal(err.Error())
+				}
 			}
 
 			manilaClientBuilder := &manilaclient.ClientBuilder{UserAgent: "manila-csi-plugin", ExtraUserAgentData: userAgentData}
@@ -85,10 +97,12 @@ func main() {
 				ShareProto:          protoSelector,
 				ServerCSIEndpoint:   endpoint,
 				FwdCSIEndpoint:      fwdEndpoint,
+				FwdCSIEndpoints:     fwdEndpoints,
 				ManilaClientBuilder: manilaClientBuilder,
 				CSIClientBuilder:    csiClientBuilder,
 				ClusterID:           clusterID,
 				PVCLister:           csi.GetPVCLister(),
+				KubeClient:          csi.GetSecretClient(),
 			}
 
 			d, err := manila.NewDriver(opts)
@@ -114,6 +128,20 @@ func main() {
 			}
 
 			runtimeconfig.RuntimeConfigFilename = runtimeConfigFile
+			if err := runtimeconfig.Watch(wait.NeverStop); err != nil {
+				klog.Fatalf("Runtime config initialization failed: %v", err)
+			}
+
+			if httpEndpoint != "" {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", legacyregistry.HandlerWithReset())
+				go func() {
+					err := http.ListenAndServe(httpEndpoint, mux)
+					if err != nil {
+						klog.Fatalf("failed to listen & serve metrics from %q: %v", httpEndpoint, err)
+					}
+				}()
+			}
 
 			d.Run()
 		},
@@ -138,17 +166,15 @@ func main() {
 
 	cmd.PersistentFlags().StringVar(&runtimeConfigFile, "runtime-config-file", "", "path to the runtime configuration file")
 
+	cmd.PersistentFlags().StringVar(&httpEndpoint, "http-endpoint", "", "The TCP network address where the HTTP server for providing metrics for diagnostics, will listen (example: `:8080`). The default is empty string, which means the server is disabled.")
+
 	cmd.PersistentFlags().BoolVar(&withTopology, "with-topology", false, "cluster is topology-aware")
 
-	cmd.PersistentFlags().StringVar(&protoSelector, "share-protocol-selector", "", "specifies which Manila share protocol to use. Valid values are NFS and CEPHFS")
-	if err := cmd.MarkPersistentFlagRequired("share-protocol-selector"); err != nil {
-		klog.Fatalf("Unable to mark flag share-protocol-selector to be required: %v", err)
-	}
+	cmd.PersistentFlags().StringVar(&protoSelector, "share-protocol-selector", "", "specifies which Manila share protocol to use. Valid values are NFS and CEPHFS. Required unless --share-protocol-fwdendpoints is set, in which case it is the protocol of the volumes whose StorageClass doesn't set the protocol parameter")
 
-	cmd.PersistentFlags().StringVar(&fwdEndpoint, "fwdendpoint", "", "CSI Node Plugin endpoint to which all Node Service RPCs are forwarded. Must be able to handle the file-system specified in share-protocol-selector")
-	if err := cmd.MarkPersistentFlagRequired("fwdendpoint"); err != nil {
-		klog.Fatalf("Unable to mark flag fwdendpoint to be required: %v", err)
-	}
+	cmd.PersistentFlags().StringVar(&fwdEndpoint, "fwdendpoint", "", "CSI Node Plugin endpoint to which all Node Service RPCs are forwarded. Must be able to handle the file-system specified in share-protocol-selector. Required unless --share-protocol-fwdendpoints is set")
+
+	cmd.PersistentFlags().StringToStringVar(&fwdEndpoints, "share-protocol-fwdendpoints", nil, "CSI Node Plugin endpoints the Node Service RPCs are forwarded to by share protocol, for example NFS=unix:///csi/nfs.sock,CEPHFS=unix:///csi/cephfs.sock. The driver operates on the shares of all these protocols, the StorageClasses select the protocol with the protocol parameter")
 
 	cmd.PersistentFlags().StringVar(&compatibilitySettings, "compatibility-settings", "", "settings for the compatibility layer")
 
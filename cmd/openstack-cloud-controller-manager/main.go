@@ -74,6 +74,11 @@ func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
 		klog.Fatalf("Cloud provider is nil")
 	}
 
+	if osCloud, ok := cloud.(*openstack.OpenStack); ok {
+		osCloud.SetClusterName(config.ComponentConfig.KubeCloudShared.ClusterName)
+		osCloud.SetCloudConfigFile(cloudConfig.CloudConfigFile)
+	}
+
 	if !cloud.HasClusterID() {
 		if config.ComponentConfig.KubeCloudShared.AllowUntaggedCloud {
 			klog.Warning("detected a cluster without a ClusterID.  A ClusterID will be required in the future.  Please tag your cluster to avoid any future issues")
@@ -72,6 +72,12 @@ type Check struct {
 
 	// (Required) Customized health check parameters defined by individual health check plugin.
 	Params map[string]interface{} `mapstructure:"params"`
+
+	// (Optional) How long to wait before checking again a healthy node. Default: 0, the node is checked on every monitoring loop
+	Interval time.Duration `mapstructure:"interval"`
+
+	// (Optional) Severity of the health check, "critical" checks trigger the node repair, "warning" checks only record an event. Default: critical
+	Severity string `mapstructure:"severity"`
 }
 
 // Configuration for connecting to Kubernetes API server, either api_host or kubeconfig should be configured.
@@ -120,6 +120,8 @@ func NewController(conf config.Config) *Controller {
 	// register healthchecks
 	healthcheck.RegisterHealthCheck(healthcheck.EndpointType, healthcheck.NewEndpointCheck)
 	healthcheck.RegisterHealthCheck(healthcheck.NodeConditionType, healthcheck.NewNodeConditionCheck)
+	healthcheck.RegisterHealthCheck(healthcheck.KubeletType, healthcheck.NewKubeletCheck)
+	healthcheck.RegisterHealthCheck(healthcheck.HTTPType, healthcheck.NewHTTPCheck)
 
 	// register clouds
 	cloudprovider.RegisterCloudProvider(openstack.ProviderName, openstack.NewOpenStackCloudProvider)
@@ -154,10 +156,17 @@ func NewController(conf config.Config) *Controller {
 		if err != nil {
 			log.Fatalf("failed to get %s type health check for worker node, error: %v", item.Type, err)
 		}
+		if checker == nil {
+			continue
+		}
 		if !checker.IsWorkerSupported() {
 			log.Warningf("Plugin type %s does not support worker node health check, will skip", item.Type)
 			continue
 		}
+		checker, err = healthcheck.NewScheduledHealthCheck(checker, item.Interval, item.Severity)
+		if err != nil {
+			log.Fatalf("failed to get %s type health check for worker node, error: %v", item.Type, err)
+		}
 		workerCheckers = append(workerCheckers, checker)
 	}
 	for _, item := range conf.HealthCheck.Master {
@@ -165,10 +174,17 @@ func NewController(conf config.Config) *Controller {
 		if err != nil {
 			log.Fatalf("failed to get %s type health check for master node, error: %v", item.Type, err)
 		}
+		if checker == nil {
+			continue
+		}
 		if !checker.IsMasterSupported() {
 			log.Warningf("Plugin type %s does not support master node health check, will skip", item.Type)
 			continue
 		}
+		checker, err = healthcheck.NewScheduledHealthCheck(checker, item.Interval, item.Severity)
+		if err != nil {
+			log.Fatalf("failed to get %s type health check for master node, error: %v", item.Type, err)
+		}
 		masterCheckers = append(masterCheckers, checker)
 	}
 
@@ -217,6 +233,11 @@ func (c *Controller) UpdateNodeAnnotation(node healthcheck.NodeInfo, annotation
 	return nil
 }
 
+// RecordNodeEvent records an event for the node. This implements the interface healthcheck.NodeController
+func (c *Controller) RecordNodeEvent(node healthcheck.NodeInfo, eventType, reason, message string) {
+	c.recorder.Event(&node.KubeNode, eventType, reason, message)
+}
+
 func (c *Controller) GetLeaderElectionLock() (resourcelock.Interface, error) {
 	// Identity used to distinguish between multiple cloud controller manager instances
 	id, err := os.Hostname()
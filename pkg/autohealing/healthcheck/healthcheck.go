@@ -17,12 +17,23 @@ limitations under the License.
 package healthcheck
 
 import (
+	"fmt"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
 	log "k8s.io/klog/v2"
 )
 
+const (
+	// SeverityCritical is the severity of the health checks whose failure triggers the node repair.
+	SeverityCritical = "critical"
+	// SeverityWarning is the severity of the health checks whose failure is only reported.
+	SeverityWarning = "warning"
+
+	// EventReasonHealthCheckWarning is the reason of the event recorded when a warning health check fails.
+	EventReasonHealthCheckWarning = "HealthCheckWarning"
+)
+
 var (
 	checkPlugins = make(map[string]registerPlugin)
 )
@@ -57,6 +68,9 @@ type NodeController interface {
 	// UpdateNodeAnnotation updates the specified node annotation, if value equals empty string, the annotation will be
 	// removed.
 	UpdateNodeAnnotation(node NodeInfo, annotation string, value string) error
+
+	// RecordNodeEvent records an event for the node.
+	RecordNodeEvent(node NodeInfo, eventType, reason, message string)
 }
 
 func RegisterHealthCheck(name string, register registerPlugin) {
@@ -68,14 +82,125 @@ func RegisterHealthCheck(name string, register registerPlugin) {
 	checkPlugins[name] = register
 }
 
+// GetHealthChecker returns the health check plugin of the given type, or nil if the plugin is not registered.
 func GetHealthChecker(name string, config interface{}) (HealthCheck, error) {
 	c, found := checkPlugins[name]
 	if !found {
+		log.Warningf("Health check plugin %s is not registered, will skip", name)
 		return nil, nil
 	}
 	return c(config)
 }
 
+// scheduledCheck runs a health check plugin at its own interval and with its own severity.
+type scheduledCheck struct {
+	HealthCheck
+
+	interval time.Duration
+	severity string
+	// lastHealthy records when each node was last found healthy, by node UID.
+	lastHealthy map[string]time.Time
+}
+
+// NewScheduledHealthCheck wraps the health check plugin so that a healthy node is checked again only after the given
+// interval, and so that the failures of a warning check are reported without repairing the node. A node found unhealthy
+// is checked again on every monitoring loop.
+func NewScheduledHealthCheck(check HealthCheck, interval time.Duration, severity string) (HealthCheck, error) {
+	if severity == "" {
+		severity = SeverityCritical
+	}
+	if severity != SeverityCritical && severity != SeverityWarning {
+		return nil, fmt.Errorf("invalid severity %q of health check plugin %s, must be %s or %s", severity, check.GetName(), SeverityCritical, SeverityWarning)
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("invalid interval %s of health check plugin %s", interval, check.GetName())
+	}
+	if interval == 0 && severity == SeverityCritical {
+		return check, nil
+	}
+
+	return &scheduledCheck{
+		HealthCheck: check,
+		interval:    interval,
+		severity:    severity,
+		lastHealthy: make(map[string]time.Time),
+	}, nil
+}
+
+// Check checks the node health with the wrapped plugin when the interval has elapsed.
+func (check *scheduledCheck) Check(node NodeInfo, controller NodeController) bool {
+	now := time.Now()
+	uid := string(node.KubeNode.UID)
+	if last, found := check.lastHealthy[uid]; found && now.Sub(last) < check.interval {
+		return true
+	}
+
+	if check.HealthCheck.Check(node, controller) {
+		for id, last := range check.lastHealthy {
+			if now.Sub(last) >= check.interval {
+				delete(check.lastHealthy, id)
+			}
+		}
+		if check.interval > 0 {
+			check.lastHealthy[uid] = now
+		}
+		return true
+	}
+	delete(check.lastHealthy, uid)
+
+	if check.severity == SeverityWarning {
+		log.Warningf("Node %s failed the health check %s, skip the repair as the check severity is %s", node.KubeNode.Name, check.GetName(), check.severity)
+		controller.RecordNodeEvent(node, apiv1.EventTypeWarning, EventReasonHealthCheckWarning, fmt.Sprintf("Node failed the health check %s", check.GetName()))
+		return true
+	}
+	return false
+}
+
+// checkUnhealthyDuration records in the node annotation when the node was first found unhealthy, and returns false once
+// the node has been unhealthy for the given duration.
+func checkUnhealthyDuration(node NodeInfo, controller NodeController, annotation string, duration time.Duration, healthy bool) bool {
+	name := node.KubeNode.Name
+
+	if healthy {
+		if _, isPresent := node.KubeNode.Annotations[annotation]; !isPresent {
+			return true
+		}
+		// Remove the annotation
+		if err := controller.UpdateNodeAnnotation(node, annotation, ""); err != nil {
+			log.Errorf("Failed to remove the node annotation(will skip the check) for %s, error: %v", name, err)
+		}
+		return true
+	}
+
+	now := time.Now()
+	var unhealthyStartTime *time.Time
+
+	// Get the current annotation value
+	if timeStr, isPresent := node.KubeNode.Annotations[annotation]; isPresent {
+		if timeStr != "" {
+			startTime, err := time.Parse(TimeLayout, timeStr)
+			if err == nil {
+				unhealthyStartTime = &startTime
+			}
+		}
+	}
+
+	if unhealthyStartTime == nil {
+		// Set the annotation value
+		if err := controller.UpdateNodeAnnotation(node, annotation, now.Format(TimeLayout)); err != nil {
+			log.Errorf("Failed to set the node annotation(will skip the check) for %s, error: %v", name, err)
+		}
+		return true
+	}
+
+	if now.Sub(*unhealthyStartTime) >= duration {
+		// Need repair
+		return false
+	}
+	// Keep the annotation value
+	return true
+}
+
 // CheckNodes goes through the health checkers, returns the unhealthy nodes.
 func CheckNodes(checkers []HealthCheck, nodes []NodeInfo, controller NodeController) []NodeInfo {
 	var unhealthyNodes []NodeInfo
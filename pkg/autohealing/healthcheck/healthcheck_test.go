@@ -0,0 +1,212 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeNodeController struct {
+	annotations map[string]string
+	events      []string
+}
+
+func (c *fakeNodeController) UpdateNodeAnnotation(node NodeInfo, annotation string, value string) error {
+	if c.annotations == nil {
+		c.annotations = make(map[string]string)
+	}
+	c.annotations[annotation] = value
+	return nil
+}
+
+func (c *fakeNodeController) RecordNodeEvent(node NodeInfo, eventType, reason, message string) {
+	c.events = append(c.events, reason)
+}
+
+type fakeCheck struct {
+	healthy bool
+	calls   int
+}
+
+func (check *fakeCheck) Check(node NodeInfo, controller NodeController) bool {
+	check.calls++
+	return check.healthy
+}
+
+func (check *fakeCheck) IsMasterSupported() bool { return true }
+
+func (check *fakeCheck) IsWorkerSupported() bool { return true }
+
+func (check *fakeCheck) GetName() string { return "FakeCheck" }
+
+func newTestNode(name string) NodeInfo {
+	return NodeInfo{
+		KubeNode: apiv1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID("uid-" + name)},
+			Status: apiv1.NodeStatus{
+				Addresses: []apiv1.NodeAddress{{Type: apiv1.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		},
+		IsWorker: true,
+	}
+}
+
+func TestGetHealthChecker(t *testing.T) {
+	RegisterHealthCheck("TestGetHealthChecker", func(config interface{}) (HealthCheck, error) {
+		return &fakeCheck{healthy: true}, nil
+	})
+
+	checker, err := GetHealthChecker("TestGetHealthChecker", nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, checker)
+
+	// An unknown plugin is skipped rather than failing the configuration.
+	checker, err = GetHealthChecker("Unknown", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, checker)
+}
+
+func TestNewScheduledHealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		severity      string
+		expectWrapped bool
+		expectErr     bool
+	}{
+		{name: "default", expectWrapped: false},
+		{name: "critical without interval", severity: SeverityCritical, expectWrapped: false},
+		{name: "critical with interval", interval: time.Minute, severity: SeverityCritical, expectWrapped: true},
+		{name: "warning", severity: SeverityWarning, expectWrapped: true},
+		{name: "invalid severity", severity: "fatal", expectErr: true},
+		{name: "negative interval", interval: -time.Second, expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := &fakeCheck{}
+			checker, err := NewScheduledHealthCheck(check, test.interval, test.severity)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			_, wrapped := checker.(*scheduledCheck)
+			assert.Equal(t, test.expectWrapped, wrapped)
+		})
+	}
+}
+
+func TestScheduledCheckInterval(t *testing.T) {
+	check := &fakeCheck{healthy: true}
+	checker, err := NewScheduledHealthCheck(check, time.Hour, SeverityCritical)
+	assert.NoError(t, err)
+	controller := &fakeNodeController{}
+	node := newTestNode("node-1")
+
+	// A healthy node is not checked again before the interval has elapsed.
+	assert.True(t, checker.Check(node, controller))
+	assert.True(t, checker.Check(node, controller))
+	assert.Equal(t, 1, check.calls)
+
+	// A node found unhealthy is checked on every loop.
+	checker.(*scheduledCheck).lastHealthy = make(map[string]time.Time)
+	check.healthy = false
+	assert.False(t, checker.Check(node, controller))
+	assert.False(t, checker.Check(node, controller))
+	assert.Equal(t, 3, check.calls)
+}
+
+func TestScheduledCheckWarning(t *testing.T) {
+	check := &fakeCheck{healthy: false}
+	checker, err := NewScheduledHealthCheck(check, 0, SeverityWarning)
+	assert.NoError(t, err)
+	controller := &fakeNodeController{}
+
+	// The failure of a warning check is reported without repairing the node.
+	assert.True(t, checker.Check(newTestNode("node-1"), controller))
+	assert.Equal(t, []string{EventReasonHealthCheckWarning}, controller.events)
+}
+
+func TestNewHTTPCheck(t *testing.T) {
+	_, err := NewHTTPCheck(map[string]interface{}{})
+	assert.Error(t, err)
+
+	_, err = NewHTTPCheck(map[string]interface{}{"url": "http://{node_ip}/healthz", "body-regexp": "("})
+	assert.Error(t, err)
+
+	checker, err := NewHTTPCheck(map[string]interface{}{"url": "http://{node_ip}/healthz", "timeout": "10s"})
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, checker.(*HTTPCheck).Timeout)
+	assert.Equal(t, []int{200}, checker.(*HTTPCheck).OKCodes)
+}
+
+func TestHTTPCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node") != "node-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("status: ok"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		config        map[string]interface{}
+		expectHealthy bool
+	}{
+		{
+			name:          "healthy",
+			config:        map[string]interface{}{"url": server.URL, "headers": map[string]string{"X-Node": "{node_name}"}, "body-regexp": "ok$"},
+			expectHealthy: true,
+		},
+		{
+			name:          "unexpected code",
+			config:        map[string]interface{}{"url": server.URL},
+			expectHealthy: false,
+		},
+		{
+			name:          "body mismatch",
+			config:        map[string]interface{}{"url": server.URL, "headers": map[string]string{"X-Node": "{node_name}"}, "body-regexp": "failed"},
+			expectHealthy: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config["unhealthy-duration"] = "0s"
+			checker, err := NewHTTPCheck(test.config)
+			assert.NoError(t, err)
+			node := newTestNode("node-1")
+			controller := &fakeNodeController{}
+
+			// The first failure only records the unhealthy time in the node annotation.
+			assert.True(t, checker.Check(node, controller))
+			node.KubeNode.Annotations = controller.annotations
+			assert.Equal(t, test.expectHealthy, checker.Check(node, controller))
+		})
+	}
+}
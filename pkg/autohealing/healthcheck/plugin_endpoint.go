@@ -79,45 +79,7 @@ func (check *EndpointCheck) IsWorkerSupported() bool {
 
 // checkDuration checks if the node should be marked as healthy or not.
 func (check *EndpointCheck) checkDuration(node NodeInfo, controller NodeController, checkRet bool) bool {
-	name := node.KubeNode.Name
-
-	if checkRet {
-		// Remove the annotation
-		if err := controller.UpdateNodeAnnotation(node, check.UnhealthyAnnotation, ""); err != nil {
-			log.Errorf("Failed to remove the node annotation(will skip the check) for %s, error: %v", name, err)
-		}
-		return true
-	}
-
-	now := time.Now()
-	var unhealthyStartTime *time.Time
-
-	// Get the current annotation value
-	if timeStr, isPresent := node.KubeNode.Annotations[check.UnhealthyAnnotation]; isPresent {
-		if timeStr != "" {
-			startTime, err := time.Parse(TimeLayout, timeStr)
-			if err != nil {
-				unhealthyStartTime = nil
-			} else {
-				unhealthyStartTime = &startTime
-			}
-		}
-	}
-
-	if unhealthyStartTime == nil {
-		// Set the annotation value
-		if err := controller.UpdateNodeAnnotation(node, check.UnhealthyAnnotation, now.Format(TimeLayout)); err != nil {
-			log.Errorf("Failed to set the node annotation(will skip the check) for %s, error: %v", name, err)
-		}
-		return true
-	}
-
-	if now.Sub(*unhealthyStartTime) >= check.UnhealthyDuration {
-		// Need repair
-		return false
-	}
-	// Keep the annotation value
-	return true
+	return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, checkRet)
 }
 
 // Check checks the node health, returns false if the node is unhealthy. Update the node cache accordingly.
@@ -205,7 +167,7 @@ func NewEndpointCheck(config interface{}) (HealthCheck, error) {
 	}
 	err = decoder.Decode(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get configuration for health check plugin %s, error: %v", NodeConditionType, err)
+		return nil, fmt.Errorf("failed to get configuration for health check plugin %s, error: %v", EndpointType, err)
 	}
 
 	return &check, nil
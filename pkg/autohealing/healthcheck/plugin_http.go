@@ -0,0 +1,184 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	log "k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/autohealing/utils"
+)
+
+const (
+	HTTPType = "HTTP"
+
+	// httpCheckMaxBodySize is the maximum size of the response body matched against BodyRegexp.
+	httpCheckMaxBodySize = 1 << 20
+)
+
+// HTTPCheck sends a custom HTTP request for each node. The "{node_name}" and "{node_ip}" placeholders in the URL and
+// the header values are replaced with the name and the internal IP of the node.
+type HTTPCheck struct {
+	// (Optional) Name of the check, used in the logs and events. Default: HTTPCheck
+	Name string `mapstructure:"name"`
+
+	// (Required) URL of the request, e.g. http://{node_ip}:9100/healthz.
+	URL string `mapstructure:"url"`
+
+	// (Optional) HTTP method of the request. Default: GET
+	Method string `mapstructure:"method"`
+
+	// (Optional) Headers of the request. Default: {}
+	Headers map[string]string `mapstructure:"headers"`
+
+	// (Optional) Timeout of the request. Default: 5s
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// (Optional) Skip the verification of the server certificate. Default: false
+	InsecureSkipVerify bool `mapstructure:"insecure-skip-verify"`
+
+	// (Optional) The accepted HTTP response codes. Default: [200].
+	OKCodes []int `mapstructure:"ok-codes"`
+
+	// (Optional) Regular expression the response body must match. Default: ""
+	BodyRegexp string `mapstructure:"body-regexp"`
+
+	// (Optional) How long to wait before a unhealthy node should be repaired. Default: 300s
+	UnhealthyDuration time.Duration `mapstructure:"unhealthy-duration"`
+
+	// (Optional) The node annotation which records the node unhealthy time, it must be unique per HTTP check. Default: autohealing.openstack.org/http-unhealthy-timestamp
+	UnhealthyAnnotation string `mapstructure:"unhealthy-annotation"`
+
+	client     *http.Client
+	bodyRegexp *regexp.Regexp
+}
+
+// GetName returns name of the health check
+func (check *HTTPCheck) GetName() string {
+	return check.Name
+}
+
+// IsMasterSupported checks if the health check plugin supports master node.
+func (check *HTTPCheck) IsMasterSupported() bool {
+	return true
+}
+
+// IsWorkerSupported checks if the health check plugin supports worker node.
+func (check *HTTPCheck) IsWorkerSupported() bool {
+	return true
+}
+
+// Check checks the node health, returns false if the node is unhealthy.
+func (check *HTTPCheck) Check(node NodeInfo, controller NodeController) bool {
+	nodeName := node.KubeNode.Name
+	ip := ""
+	for _, addr := range node.KubeNode.Status.Addresses {
+		if addr.Type == "InternalIP" {
+			ip = addr.Address
+			break
+		}
+	}
+	if ip == "" && strings.Contains(check.URL, "{node_ip}") {
+		log.Warningf("Cannot find IP address for node %s, skip the check %s", nodeName, check.Name)
+		return true
+	}
+
+	replacer := strings.NewReplacer("{node_name}", nodeName, "{node_ip}", ip)
+	url := replacer.Replace(check.URL)
+	req, err := http.NewRequest(check.Method, url, nil)
+	if err != nil {
+		log.Errorf("Node %s, failed to get request %s, error: %v", nodeName, url, err)
+		return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, false)
+	}
+	for name, value := range check.Headers {
+		req.Header.Set(name, replacer.Replace(value))
+	}
+
+	resp, err := check.client.Do(req)
+	if err != nil {
+		log.Errorf("Node %s, failed to read response for url %s, error: %v", nodeName, url, err)
+		return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, false)
+	}
+	defer resp.Body.Close()
+
+	if !utils.ContainsInt(check.OKCodes, resp.StatusCode) {
+		log.V(4).Infof("Node %s, return code for url %s is %d, expected: %d", nodeName, url, resp.StatusCode, check.OKCodes)
+		return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, false)
+	}
+
+	if check.bodyRegexp != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, httpCheckMaxBodySize))
+		if err != nil {
+			log.Errorf("Node %s, failed to read response body for url %s, error: %v", nodeName, url, err)
+			return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, false)
+		}
+		if !check.bodyRegexp.Match(body) {
+			log.V(4).Infof("Node %s, response body for url %s doesn't match %q", nodeName, url, check.BodyRegexp)
+			return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, false)
+		}
+	}
+
+	return checkUnhealthyDuration(node, controller, check.UnhealthyAnnotation, check.UnhealthyDuration, true)
+}
+
+func NewHTTPCheck(config interface{}) (HealthCheck, error) {
+	check := HTTPCheck{
+		Name:                "HTTPCheck",
+		Method:              http.MethodGet,
+		Timeout:             5 * time.Second,
+		OKCodes:             []int{200},
+		UnhealthyDuration:   300 * time.Second,
+		UnhealthyAnnotation: "autohealing.openstack.org/http-unhealthy-timestamp",
+	}
+
+	decConfig := mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &check,
+	}
+	decoder, err := mapstructure.NewDecoder(&decConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration for health check plugin %s, error: %v", HTTPType, err)
+	}
+
+	if check.URL == "" {
+		return nil, fmt.Errorf("url is required for health check plugin %s", HTTPType)
+	}
+	if check.BodyRegexp != "" {
+		check.bodyRegexp, err = regexp.Compile(check.BodyRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body-regexp for health check plugin %s, error: %v", HTTPType, err)
+		}
+	}
+	check.client = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: check.InsecureSkipVerify}},
+		Timeout:   check.Timeout,
+	}
+
+	return &check, nil
+}
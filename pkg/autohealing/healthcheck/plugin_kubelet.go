@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	KubeletType = "Kubelet"
+)
+
+// KubeletCheck probes the kubelet endpoints of the node. It accepts the same parameters as EndpointCheck, with defaults
+// targeting the kubelet server.
+type KubeletCheck struct {
+	EndpointCheck `mapstructure:",squash"`
+}
+
+// GetName returns name of the health check
+func (check *KubeletCheck) GetName() string {
+	return "KubeletCheck"
+}
+
+// IsMasterSupported checks if the health check plugin supports master node.
+func (check *KubeletCheck) IsMasterSupported() bool {
+	return true
+}
+
+// IsWorkerSupported checks if the health check plugin supports worker node.
+func (check *KubeletCheck) IsWorkerSupported() bool {
+	return true
+}
+
+func NewKubeletCheck(config interface{}) (HealthCheck, error) {
+	check := KubeletCheck{
+		EndpointCheck: EndpointCheck{
+			Protocol:            "https",
+			Port:                10250,
+			UnhealthyDuration:   300 * time.Second,
+			Endpoints:           []string{"/healthz"},
+			OKCodes:             []int{200},
+			RequireToken:        true,
+			UnhealthyAnnotation: "autohealing.openstack.org/kubelet-unhealthy-timestamp",
+		},
+	}
+
+	decConfig := mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &check,
+	}
+	decoder, err := mapstructure.NewDecoder(&decConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration for health check plugin %s, error: %v", KubeletType, err)
+	}
+
+	return &check, nil
+}
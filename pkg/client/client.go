@@ -22,6 +22,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net/http"
+	"os"
 	"runtime"
 	"strings"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/gophercloud/utils/v2/client"
 	"github.com/gophercloud/utils/v2/openstack/clientconfig"
+	"gopkg.in/yaml.v2"
 
 	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/client-go/util/cert"
@@ -199,13 +201,38 @@ func replaceEmpty(a string, b string) string {
 	return a
 }
 
+// cloudsFileYAMLOpts reads the clouds.yaml from a file, the secure.yaml and clouds-public.yaml are looked up in the
+// standard locations.
+type cloudsFileYAMLOpts struct {
+	clientconfig.YAMLOpts
+
+	path string
+}
+
+func (opts cloudsFileYAMLOpts) LoadCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	content, err := os.ReadFile(opts.path)
+	if err != nil {
+		return nil, err
+	}
+	var clouds clientconfig.Clouds
+	if err := yaml.Unmarshal(content, &clouds); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", opts.path, err)
+	}
+	return clouds.Clouds, nil
+}
+
 // ReadClouds reads Reads clouds.yaml to generate a Config
 // Allows the cloud-config to have priority
+// The clouds.yaml is read from the CloudsFile when set, otherwise from the OS_CLIENT_CONFIG_FILE environment variable
+// and the standard locations.
 func ReadClouds(authOpts *AuthOpts) error {
 	co := new(clientconfig.ClientOpts)
 	if authOpts.Cloud != "" {
 		co.Cloud = authOpts.Cloud
 	}
+	if authOpts.CloudsFile != "" {
+		co.YAMLOpts = cloudsFileYAMLOpts{path: authOpts.CloudsFile}
+	}
 	cloud, err := clientconfig.GetCloudFromYAML(co)
 	if err != nil {
 		return err
@@ -69,3 +69,12 @@ func NewKeyManagerV1(provider *gophercloud.ProviderClient, eo *gophercloud.Endpo
 	}
 	return secret, nil
 }
+
+// NewIdentityV3 creates a ServiceClient that may be used with the Keystone v3 API
+func NewIdentityV3(provider *gophercloud.ProviderClient, eo *gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error) {
+	identity, err := openstack.NewIdentityV3(provider, *eo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find identity v3 %s endpoint for region %s: %v", eo.Availability, eo.Region, err)
+	}
+	return identity, nil
+}
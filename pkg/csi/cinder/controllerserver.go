@@ -19,11 +19,16 @@ package cinder
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/backups"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
@@ -43,12 +48,35 @@ import (
 type controllerServer struct {
 	Driver *Driver
 	Clouds map[string]openstack.IOpenStack
+
+	// volumeHealth is only set when the volume health remediation is enabled
+	volumeHealth *volumeHealthMonitor
+	// volumeLabels is only set when the PV label synchronization is enabled
+	volumeLabels *volumeLabelSyncer
+	// volumeFlattener is only set when the volume flattening is enabled
+	volumeFlattener *volumeFlattener
+	// volumeZoneBalance is only set when the zone balance report is enabled
+	volumeZoneBalance *volumeZoneBalanceReporter
+	// ephemeralGC is only set when the collection of the orphaned ephemeral volumes is enabled
+	ephemeralGC *ephemeralVolumeCollector
 }
 
 const (
 	cinderCSIClusterIDKey = "cinder.csi.openstack.org/cluster"
-	affinityKey           = "cinder.csi.openstack.org/affinity"
-	antiAffinityKey       = "cinder.csi.openstack.org/anti-affinity"
+	// snapshotAvailabilityZoneKey is the snapshot metadata recording the AZ of the source volume, the volumes restored
+	// from the snapshot are created in it.
+	snapshotAvailabilityZoneKey = "cinder.csi.openstack.org/availability-zone"
+	affinityKey                 = "cinder.csi.openstack.org/affinity"
+	antiAffinityKey             = "cinder.csi.openstack.org/anti-affinity"
+
+	// availabilityZonesKey is the StorageClass parameter listing the AZs to create the volumes in, in order of
+	// preference, among the ones allowed by the topology requirement.
+	availabilityZonesKey = "availabilityZones"
+
+	// volumeTypesKey is the StorageClass parameter listing the tiered volume types the PVCs choose from with the
+	// volumeTypeKey annotation, the first one by default.
+	volumeTypesKey = "types"
+	volumeTypeKey  = "cinder.csi.openstack.org/volume-type"
 )
 
 func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
@@ -60,6 +88,7 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[CreateVolume] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	// Volume Name
 	volName := req.GetName()
@@ -81,18 +110,84 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	}
 	volSizeGB := int(util.RoundUpSize(volSizeBytes, 1024*1024*1024))
 
+	// get the PVC annotation
+	pvcAnnotations := sharedcsi.GetPVCAnnotations(cs.Driver.pvcLister, volParams)
+	for k, v := range pvcAnnotations {
+		klog.V(4).Infof("CreateVolume: retrieved %q pvc annotation: %s: %s", k, v, volName)
+	}
+
 	// Volume Type
-	volType := volParams["type"]
+	volType, err := getVolumeType(volParams, pvcAnnotations)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+	}
+
+	encryption, err := getVolumeEncryption(volParams)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+	}
+
+	volQoS, err := getVolumeQoS(volParams, volSizeGB)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+	}
+
+	// The volume context is passed to the node plugin
+	var volCtx map[string]string
+	projectQuotas, err := getProjectQuotasParameter(volParams)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+	}
+	if projectQuotas {
+		for _, volCap := range req.GetVolumeCapabilities() {
+			if fsType := volCap.GetMount().GetFsType(); fsType != "" && fsType != "ext4" && fsType != "xfs" {
+				return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] project quotas are only supported on xfs and ext4 filesystems, not %s", fsType)
+			}
+		}
+		volCtx = map[string]string{projectQuotasKey: "true"}
+	}
+	if encryption != nil {
+		volType, err = getEncryptedVolumeType(cloud, volType, encryption)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The encrypted volume type, when selected, must also provide the requested performance
+	if volQoS != nil {
+		volType, err = getQoSVolumeType(cloud, volType, volQoS, volSizeGB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	multiNode, err := validateMultiNodeCapabilities(volCapabilities)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+	}
+	if multiNode {
+		if err := checkMultiattachVolumeType(cloud, volType); err != nil {
+			return nil, err
+		}
+	}
 
 	var volAvailability string
+	// AZs to fall back to, in order, when the volume can't be created in volAvailability
+	var fallbackAvailabilities []string
 	if cs.Driver.withTopology {
 		// First check if volAvailability is already specified, if not get preferred from Topology
 		// Required, incase vol AZ is different from node AZ
 		volAvailability = volParams["availability"]
 		if volAvailability == "" {
 			accessibleTopologyReq := req.GetAccessibilityRequirements()
-			// Check from Topology
-			if accessibleTopologyReq != nil {
+			if zones := volParams[availabilityZonesKey]; zones != "" {
+				// The order of the StorageClass prevails over the order of the topology requirement
+				availabilities, err := getOrderedAvailabilityZones(zones, accessibleTopologyReq)
+				if err != nil {
+					return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %v", err)
+				}
+				volAvailability, fallbackAvailabilities = availabilities[0], availabilities[1:]
+			} else if accessibleTopologyReq != nil {
+				// Check from Topology
 				volAvailability = sharedcsi.GetAZFromTopology(topologyKey, accessibleTopologyReq)
 			}
 		}
@@ -100,12 +195,6 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	ignoreVolumeAZ := cloud.GetBlockStorageOpts().IgnoreVolumeAZ
 
-	// get the PVC annotation
-	pvcAnnotations := sharedcsi.GetPVCAnnotations(cs.Driver.pvcLister, volParams)
-	for k, v := range pvcAnnotations {
-		klog.V(4).Infof("CreateVolume: retrieved %q pvc annotation: %s: %s", k, v, volName)
-	}
-
 	// Verify a volume with the provided name doesn't already exist for this tenant
 	vols, err := cloud.GetVolumesByName(volName)
 	if err != nil {
@@ -117,15 +206,34 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		if volSizeGB != vols[0].Size {
 			return nil, status.Error(codes.AlreadyExists, "Volume Already exists with same name and different capacity")
 		}
+		if encryption != nil && !vols[0].Encrypted {
+			return nil, status.Error(codes.AlreadyExists, "Volume Already exists with same name and is not encrypted")
+		}
+		if multiNode && !vols[0].Multiattach {
+			return nil, status.Error(codes.AlreadyExists, "Volume Already exists with same name and is not multiattach")
+		}
 		klog.V(4).Infof("Volume %s already exists in Availability Zone: %s of size %d GiB", vols[0].ID, vols[0].AvailabilityZone, vols[0].Size)
-		return getCreateVolumeResponse(&vols[0], nil, ignoreVolumeAZ, req.GetAccessibilityRequirements()), nil
+		cs.recordSpreadGroupVolume(ctx, pvcAnnotations[spreadGroupKey], volParams, vols[0].ID)
+		return getCreateVolumeResponse(&vols[0], volCtx, ignoreVolumeAZ, req.GetAccessibilityRequirements()), nil
 	} else if len(vols) > 1 {
 		klog.V(3).Infof("found multiple existing volumes with selected name (%s) during create", volName)
 		return nil, status.Error(codes.Internal, "Multiple volumes reported by Cinder with same name")
 	}
 
+	capacityLimit, unlockCapacity, err := cs.checkCreateVolumeCapacityLimits(ctx, cloud, volParams, volType, volSizeGB)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCapacity()
+
 	// Volume Create
 	properties := map[string]string{cinderCSIClusterIDKey: cs.Driver.clusterID}
+	if capacityLimit != nil {
+		properties[volumeCapacityLimitKey] = capacityLimit.String()
+	}
+	if volQoS != nil {
+		properties[volumeQoSKey] = formatVolumeQoSParams(volParams)
+	}
 	//Tag volume with metadata if present: https://github.com/kubernetes-csi/external-provisioner/pull/399
 	for _, mKey := range sharedcsi.RecognizedCSIProvisionerParams {
 		if v, ok := req.Parameters[mKey]; ok {
@@ -155,6 +263,21 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 			return nil, status.Errorf(codes.Unavailable, "VolumeContentSource Snapshot %s is not yet available. status: %s", snapshotID, snap.Status)
 		}
 
+		// Cinder restores the snapshots in their AZ, check it against the topology before creating the volume.
+		if err == nil && cs.Driver.withTopology {
+			requirement := req.GetAccessibilityRequirements()
+			if ignoreVolumeAZ {
+				// The volume AZ doesn't constrain the nodes
+				requirement = nil
+			}
+			snapAvailability := getSnapshotAvailabilityZone(cloud, snap)
+			volAvailability, err = getRestoreAvailabilityZone(snapAvailability, volAvailability, volParams["availability"] != "", requirement)
+			if err != nil {
+				return nil, err
+			}
+			fallbackAvailabilities = nil
+		}
+
 		// In case a snapshot is not found
 		// check if a Backup with the same ID exists
 		if backupsAreEnabled && cpoerrors.IsNotFound(err) {
@@ -203,9 +326,19 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	// Set scheduler hints if affinity or anti-affinity is set in PVC annotations
 	var schedulerHints volumes.SchedulerHintOptsBuilder
-	var volCtx map[string]string
 	affinity := pvcAnnotations[affinityKey]
 	antiAffinity := pvcAnnotations[antiAffinityKey]
+	// The volumes of the other PVCs of the spread group are kept on different backends. They are created one after the
+	// other, so that each one sees the volumes created before.
+	spreadGroup := pvcAnnotations[spreadGroupKey]
+	if spreadGroup != "" {
+		defer lockSpreadGroup(volParams, spreadGroup)()
+	}
+	spreadVolumes := cs.getSpreadGroupVolumes(ctx, volParams, spreadGroup)
+	if len(spreadVolumes) > 0 {
+		klog.V(4).Infof("CreateVolume: Spreading volume %s away from the volumes of spread group %s: %s", volName, spreadGroup, strings.Join(spreadVolumes, ","))
+		antiAffinity = strings.Join(append(util.SplitTrim(antiAffinity, ','), spreadVolumes...), ",")
+	}
 	if affinity != "" || antiAffinity != "" {
 		klog.V(4).Infof("CreateVolume: Getting scheduler hints: affinity=%s, anti-affinity=%s", affinity, antiAffinity)
 
@@ -229,12 +362,29 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		klog.V(4).Infof("CreateVolume: Resolved scheduler hints: affinity=%s, anti-affinity=%s", affinity, antiAffinity)
 	}
 
-	vol, err := cloud.CreateVolume(opts, schedulerHints)
+	vol, err := createVolumeWithFallback(cloud, opts, schedulerHints, fallbackAvailabilities)
 	if err != nil {
 		klog.Errorf("Failed to CreateVolume: %v", err)
 		return nil, status.Errorf(codes.Internal, "CreateVolume failed with error %v", err)
 	}
 
+	// The volume type encryption can't always be checked beforehand
+	if encryption != nil && !vol.Encrypted {
+		klog.Errorf("Volume %s of type %s is not encrypted, deleting it", vol.ID, vol.VolumeType)
+		if err := deleteRejectedVolume(cloud, vol.ID); err != nil {
+			return nil, status.Errorf(codes.Internal, "volume type %s is not encrypted, failed to delete volume %s: %v", vol.VolumeType, vol.ID, err)
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "volume type %s is not encrypted", vol.VolumeType)
+	}
+
+	if multiNode && !vol.Multiattach {
+		klog.Errorf("Volume %s of type %s is not multiattach, deleting it", vol.ID, vol.VolumeType)
+		if err := deleteRejectedVolume(cloud, vol.ID); err != nil {
+			return nil, status.Errorf(codes.Internal, "volume type %s doesn't have multiattach enabled, failed to delete volume %s: %v", vol.VolumeType, vol.ID, err)
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "volume type %s doesn't have multiattach enabled", vol.VolumeType)
+	}
+
 	// When creating a volume from a backup, the response does not include the backupID.
 	if sourceBackupID != "" {
 		vol.BackupID = &sourceBackupID
@@ -242,9 +392,51 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	klog.V(4).Infof("CreateVolume: Successfully created volume %s in Availability Zone: %s of size %d GiB", vol.ID, vol.AvailabilityZone, vol.Size)
 
+	cs.recordSpreadGroupVolume(ctx, spreadGroup, volParams, vol.ID)
+
 	return getCreateVolumeResponse(vol, volCtx, ignoreVolumeAZ, req.GetAccessibilityRequirements()), nil
 }
 
+// createVolumeWithFallback creates the volume in the availability zone of opts, or in the first of the fallback
+// availability zones it can be created in. Cinder refuses an unknown availability zone right away, but a volume without
+// a valid backend in the availability zone only goes to error asynchronously. The volume is then waited for as long as
+// there are availability zones to fall back to, and deleted when it goes to error.
+func createVolumeWithFallback(cloud openstack.IOpenStack, opts *volumes.CreateOpts, schedulerHints volumes.SchedulerHintOptsBuilder, fallbackAvailabilities []string) (*volumes.Volume, error) {
+	for {
+		vol, err := cloud.CreateVolume(opts, schedulerHints)
+		if err == nil && len(fallbackAvailabilities) > 0 {
+			err = cloud.WaitVolumeTargetStatus(vol.ID, []string{openstack.VolumeAvailableStatus})
+			if errors.Is(err, openstack.ErrVolumeErrorState) {
+				if derr := cloud.DeleteVolume(vol.ID); derr != nil {
+					return nil, fmt.Errorf("volume %s went to error in Availability Zone %s, failed to delete it: %v", vol.ID, opts.AvailabilityZone, derr)
+				}
+			} else {
+				// The volume is kept when it's still being created after the timeout
+				err = nil
+			}
+		}
+		if err == nil {
+			return vol, nil
+		}
+		if (!cpoerrors.IsInvalidError(err) && !errors.Is(err, openstack.ErrVolumeErrorState)) || len(fallbackAvailabilities) == 0 {
+			return nil, err
+		}
+
+		klog.Warningf("Failed to create volume %s in Availability Zone %s, falling back to %s: %v", opts.Name, opts.AvailabilityZone, fallbackAvailabilities[0], err)
+		opts.AvailabilityZone, fallbackAvailabilities = fallbackAvailabilities[0], fallbackAvailabilities[1:]
+	}
+}
+
+// deleteRejectedVolume deletes a volume created with a volume type that doesn't
+// match the request. The volume is usually still being created, so it waits
+// for the volume to settle before deleting it.
+func deleteRejectedVolume(cloud openstack.IOpenStack, volumeID string) error {
+	if err := cloud.WaitVolumeTargetStatus(volumeID, []string{openstack.VolumeAvailableStatus, "error"}); err != nil {
+		return fmt.Errorf("volume didn't become available: %v", err)
+	}
+	return cloud.DeleteVolume(volumeID)
+}
+
 func (d *controllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
 	klog.V(4).InfoS("ControllerModifyVolume: called", "args", *req)
 	return nil, status.Error(codes.Unimplemented, "")
@@ -259,6 +451,7 @@ func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 	if !cloudExist {
 		return nil, status.Errorf(codes.InvalidArgument, "[DeleteVolume] specified cloud \"%s\" undefined", volCloud)
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	// Volume Delete
 	volID := req.GetVolumeId()
@@ -289,6 +482,7 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[ControllerPublishVolume] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	// Volume Attach
 	instanceID := req.GetNodeId()
@@ -304,22 +498,40 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	if volumeCapability == nil {
 		return nil, status.Error(codes.InvalidArgument, "[ControllerPublishVolume] Volume capability must be provided")
 	}
+	if cs.volumeHealth != nil && cs.volumeHealth.isCordoned(volumeID) {
+		return nil, status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] Volume %s is cordoned by the %s annotation of its PersistentVolume", volumeID, VolumeUnhealthyAnnotation)
+	}
 
-	_, err := cloud.GetVolume(volumeID)
+	vol, err := cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
 			return nil, status.Errorf(codes.NotFound, "[ControllerPublishVolume] Volume %s not found", volumeID)
 		}
 		return nil, status.Errorf(codes.Internal, "[ControllerPublishVolume] get volume failed with error %v", err)
 	}
+	if isMultiNodeWriter(volumeCapability) && !vol.Multiattach {
+		return nil, status.Errorf(codes.InvalidArgument, "[ControllerPublishVolume] Volume %s is not multiattach, access mode %s is not supported", volumeID, volumeCapability.GetAccessMode().GetMode())
+	}
+	if !vol.Multiattach {
+		for _, att := range vol.Attachments {
+			if att.ServerID != instanceID {
+				return nil, status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] Volume %s is already attached to instance %s and is not multiattach", volumeID, att.ServerID)
+			}
+		}
+	}
 
-	_, err = cloud.GetInstanceByID(instanceID)
+	instance, err := cloud.GetInstanceByID(instanceID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
 			return nil, status.Errorf(codes.NotFound, "[ControllerPublishVolume] Instance %s not found", instanceID)
 		}
 		return nil, status.Errorf(codes.Internal, "[ControllerPublishVolume] GetInstanceByID failed with error %v", err)
 	}
+	if cloud.GetBlockStorageOpts().AdoptVolumes {
+		if err := adoptVolume(cloud, cs.Driver.clusterID, vol, instance); err != nil {
+			return nil, err
+		}
+	}
 
 	_, err = cloud.AttachVolume(instanceID, volumeID)
 	if err != nil {
@@ -360,6 +572,7 @@ func (cs *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[ControllerUnpublishVolume] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	// Volume Detach
 	instanceID := req.GetNodeId()
@@ -416,7 +629,7 @@ func (cs *controllerServer) extractNodeIDs(attachments []volumes.Attachment) []s
 	return nodeIDs
 }
 
-func (cs *controllerServer) createVolumeEntries(vlist []volumes.Volume) []*csi.ListVolumesResponse_Entry {
+func (cs *controllerServer) createVolumeEntries(vlist []volumes.Volume, adoptVolumes bool) []*csi.ListVolumesResponse_Entry {
 	entries := make([]*csi.ListVolumesResponse_Entry, len(vlist))
 	for i, v := range vlist {
 		entries[i] = &csi.ListVolumesResponse_Entry{
@@ -426,6 +639,7 @@ func (cs *controllerServer) createVolumeEntries(vlist []volumes.Volume) []*csi.L
 			},
 			Status: &csi.ListVolumesResponse_VolumeStatus{
 				PublishedNodeIds: cs.extractNodeIDs(v.Attachments),
+				VolumeCondition:  getVolumeCondition(&v, cs.Driver.clusterID, adoptVolumes),
 			},
 		}
 	}
@@ -483,9 +697,9 @@ func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 	}
 	for idx := startIdx; idx < len(cloudsNames); idx++ {
 		if maxEntries > 0 {
-			vlist, nextPageToken, err = cs.Clouds[cloudsNames[idx]].ListVolumes(maxEntries-len(cloudsVentries), startingToken)
+			vlist, nextPageToken, err = requestCloud(ctx, cs.Clouds[cloudsNames[idx]]).ListVolumes(maxEntries-len(cloudsVentries), startingToken)
 		} else {
-			vlist, nextPageToken, err = cs.Clouds[cloudsNames[idx]].ListVolumes(maxEntries, startingToken)
+			vlist, nextPageToken, err = requestCloud(ctx, cs.Clouds[cloudsNames[idx]]).ListVolumes(maxEntries, startingToken)
 		}
 		startingToken = nextPageToken
 		if err != nil {
@@ -496,7 +710,7 @@ func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 			return nil, status.Errorf(codes.Internal, "ListVolumes failed with error %v", err)
 		}
 
-		ventries := cs.createVolumeEntries(vlist)
+		ventries := cs.createVolumeEntries(vlist, cs.Clouds[cloudsNames[idx]].GetBlockStorageOpts().AdoptVolumes)
 		klog.V(4).Infof("ListVolumes: retrieved %d entries and %q next token from cloud %q", len(ventries), nextPageToken, cloudsNames[idx])
 
 		cloudsVentries = append(cloudsVentries, ventries...)
@@ -518,7 +732,7 @@ func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 					// set token to next non empty cloud
 					i := 0
 					for i = idx + 1; i < len(cloudsNames); i++ {
-						vlistTmp, _, err := cs.Clouds[cloudsNames[i]].ListVolumes(1, "")
+						vlistTmp, _, err := requestCloud(ctx, cs.Clouds[cloudsNames[i]]).ListVolumes(1, "")
 						if err != nil {
 							klog.Errorf("Failed to ListVolumes: %v", err)
 							if cpoerrors.IsInvalidError(err) {
@@ -568,10 +782,11 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[CreateSnapshot] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	name := req.Name
 	volumeID := req.GetSourceVolumeId()
-	snapshotType := req.Parameters[openstack.SnapshotType]
+	snapshotType := getSnapshotType(req.Parameters, cloud.GetBlockStorageOpts())
 	filters := map[string]string{"Name": name}
 	backupMaxDurationSecondsPerGB := openstack.BackupMaxDurationSecondsPerGBDefault
 
@@ -586,11 +801,6 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 	var backups []backups.Backup
 	var err error
 
-	// Set snapshot type to 'snapshot' by default
-	if snapshotType == "" {
-		snapshotType = "snapshot"
-	}
-
 	if name == "" {
 		return nil, status.Error(codes.InvalidArgument, "Snapshot name must be provided in CreateSnapshot request")
 	}
@@ -780,6 +990,14 @@ func (cs *controllerServer) createSnapshot(cloud openstack.IOpenStack, name stri
 		}
 	}
 
+	// Record the AZ of the source volume, Cinder restores the snapshot in it
+	vol, err := cloud.GetVolume(volumeID)
+	if err != nil {
+		klog.Warningf("Failed to get the availability zone of volume %s for snapshot %s: %v", volumeID, name, err)
+	} else if vol.AvailabilityZone != "" {
+		properties[snapshotAvailabilityZoneKey] = vol.AvailabilityZone
+	}
+
 	// TODO: Delegate the check to openstack itself and ignore the conflict
 	snap, err = cloud.CreateSnapshot(name, volumeID, properties)
 	if err != nil {
@@ -825,6 +1043,7 @@ func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[DeleteSnapshot] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	id := req.GetSnapshotId()
 
@@ -862,6 +1081,7 @@ func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnap
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[DeleteSnapshot] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	snapshotID := req.GetSnapshotId()
 	if len(snapshotID) != 0 {
@@ -956,6 +1176,7 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[ValidateVolumeCapabilities] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	reqVolCap := req.GetVolumeCapabilities()
 
@@ -968,7 +1189,7 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 		return nil, status.Error(codes.InvalidArgument, "ValidateVolumeCapabilities Volume ID must be provided")
 	}
 
-	_, err := cloud.GetVolume(volumeID)
+	vol, err := cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
 			return nil, status.Errorf(codes.NotFound, "ValidateVolumeCapabilities Volume %s not found", volumeID)
@@ -977,19 +1198,14 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 	}
 
 	for _, cap := range reqVolCap {
-		if cap.GetAccessMode().GetMode() != cs.Driver.vcap[0].Mode {
+		if !isVolumeCapabilitySupported(cs.Driver.vcap, vol, cap) {
 			return &csi.ValidateVolumeCapabilitiesResponse{Message: "Requested Volume Capability not supported"}, nil
 		}
 	}
 
-	// Cinder CSI driver currently supports one mode only
 	resp := &csi.ValidateVolumeCapabilitiesResponse{
 		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
-			VolumeCapabilities: []*csi.VolumeCapability{
-				{
-					AccessMode: cs.Driver.vcap[0],
-				},
-			},
+			VolumeCapabilities: reqVolCap,
 		},
 	}
 
@@ -1011,6 +1227,7 @@ func (cs *controllerServer) ControllerGetVolume(ctx context.Context, req *csi.Co
 
 	var volume *volumes.Volume
 	var err error
+	var adoptVolumes bool
 	for _, cloud := range cs.Clouds {
 		volume, err = cloud.GetVolume(volumeID)
 		if err != nil {
@@ -1019,6 +1236,7 @@ func (cs *controllerServer) ControllerGetVolume(ctx context.Context, req *csi.Co
 			}
 			return nil, status.Errorf(codes.Internal, "ControllerGetVolume failed with error %v", err)
 		}
+		adoptVolumes = cloud.GetBlockStorageOpts().AdoptVolumes
 	}
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "Volume %s not found", volumeID)
@@ -1036,11 +1254,24 @@ func (cs *controllerServer) ControllerGetVolume(ctx context.Context, req *csi.Co
 	for _, attachment := range volume.Attachments {
 		status.PublishedNodeIds = append(status.PublishedNodeIds, attachment.ServerID)
 	}
+	status.VolumeCondition = getVolumeCondition(volume, cs.Driver.clusterID, adoptVolumes)
 	ventry.Status = status
 
 	return &ventry, nil
 }
 
+// getVolumeCondition reports the volume as abnormal when it's in error state, or when it belongs to another cluster
+// and the volumes are adopted.
+func getVolumeCondition(volume *volumes.Volume, clusterID string, adoptVolumes bool) *csi.VolumeCondition {
+	if err := checkVolumeOwnership(volume, clusterID); err != nil && adoptVolumes {
+		return &csi.VolumeCondition{Abnormal: true, Message: err.Error()}
+	}
+	if volume.Status == volumeStatusError {
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Volume %s is in %s state", volume.ID, volume.Status)}
+	}
+	return &csi.VolumeCondition{Message: "Volume is healthy"}
+}
+
 func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
 	klog.V(4).Infof("ControllerExpandVolume: called with args %+v", protosanitizer.StripSecrets(*req))
 
@@ -1050,6 +1281,7 @@ func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi
 	if !cloudExist {
 		return nil, status.Error(codes.InvalidArgument, "[ControllerExpandVolume] specified cloud undefined")
 	}
+	cloud = requestCloud(ctx, cloud)
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -1085,9 +1317,28 @@ func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi
 		}, nil
 	}
 
+	if volume.Status != openstack.VolumeAvailableStatus && volume.Status != openstack.VolumeInUseStatus {
+		return nil, status.Errorf(codes.FailedPrecondition, "[ControllerExpandVolume] volume %s is %s, it can only be expanded when %s or %s", volumeID, volume.Status, openstack.VolumeAvailableStatus, openstack.VolumeInUseStatus)
+	}
+
+	if err := checkExpandVolumeQuota(cloud, volume, volSizeGB); err != nil {
+		return nil, err
+	}
+
+	if err := checkExpandVolumeQoS(cloud, volume, volSizeGB); err != nil {
+		return nil, err
+	}
+
+	unlockCapacity, err := cs.checkExpandVolumeCapacityLimits(ctx, cloud, volume, volSizeGB)
+	if err != nil {
+		return nil, err
+	}
+	// The size of the volume is updated once it's expanded.
+	defer unlockCapacity()
+
 	err = cloud.ExpandVolume(volumeID, volume.Status, volSizeGB)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not resize volume %q to size %v: %v", volumeID, volSizeGB, err)
+		return nil, status.Errorf(expandVolumeErrorCode(err), "Could not resize volume %q to size %v: %s", volumeID, volSizeGB, openstack.FaultMessage(err))
 	}
 
 	// we need wait for the volume to be available or InUse, it might be error_extending in some scenario
@@ -1106,6 +1357,151 @@ func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi
 	}, nil
 }
 
+// getOrderedAvailabilityZones returns the AZs of the comma-separated list allowed by the topology requirement, in the
+// order of the list.
+func getOrderedAvailabilityZones(zones string, requirement *csi.TopologyRequirement) ([]string, error) {
+	allowed := sharedcsi.GetAZsFromTopology(topologyKey, requirement)
+
+	var availabilities []string
+	for _, zone := range util.SplitTrim(zones, ',') {
+		if zone == "" || slices.Contains(availabilities, zone) {
+			continue
+		}
+		if len(allowed) == 0 || slices.Contains(allowed, zone) {
+			availabilities = append(availabilities, zone)
+		}
+	}
+
+	if len(availabilities) == 0 {
+		return nil, fmt.Errorf("none of the availability zones %q satisfies the topology requirement %v", zones, allowed)
+	}
+
+	return availabilities, nil
+}
+
+// getVolumeType returns the volume type of the StorageClass. When the StorageClass lists tiered volume types instead,
+// the PVC chooses one of them with its volume type annotation, the first one is used by default.
+func getVolumeType(volParams map[string]string, pvcAnnotations map[string]string) (string, error) {
+	types, ok := volParams[volumeTypesKey]
+	if !ok {
+		return volParams["type"], nil
+	}
+	if volParams["type"] != "" {
+		return "", fmt.Errorf("%s and type parameters are mutually exclusive", volumeTypesKey)
+	}
+
+	var allowed []string
+	for _, volType := range util.SplitTrim(types, ',') {
+		if volType != "" && !slices.Contains(allowed, volType) {
+			allowed = append(allowed, volType)
+		}
+	}
+	if len(allowed) == 0 {
+		return "", fmt.Errorf("%s parameter lists no volume type", volumeTypesKey)
+	}
+
+	requested := pvcAnnotations[volumeTypeKey]
+	if requested == "" {
+		return allowed[0], nil
+	}
+	if !slices.Contains(allowed, requested) {
+		return "", fmt.Errorf("volume type %q of the PVC annotation %s is not one of %q", requested, volumeTypeKey, types)
+	}
+
+	return requested, nil
+}
+
+// getSnapshotAvailabilityZone returns the AZ of the snapshot, recorded in its metadata or the one of its source volume
+// for the snapshots created before. It returns an empty string when the AZ is unknown.
+func getSnapshotAvailabilityZone(cloud openstack.IOpenStack, snap *snapshots.Snapshot) string {
+	if zone := snap.Metadata[snapshotAvailabilityZoneKey]; zone != "" {
+		return zone
+	}
+
+	vol, err := cloud.GetVolume(snap.VolumeID)
+	if err != nil {
+		klog.Warningf("Failed to get the availability zone of the source volume %s of snapshot %s: %v", snap.VolumeID, snap.ID, err)
+		return ""
+	}
+	return vol.AvailabilityZone
+}
+
+// getSnapshotType returns the type of the VolumeSnapshotClass, the default-snapshot-type of the cloud when not set,
+// "snapshot" by default.
+func getSnapshotType(params map[string]string, opts openstack.BlockStorageOpts) string {
+	if snapshotType := params[openstack.SnapshotType]; snapshotType != "" {
+		return snapshotType
+	}
+	if opts.DefaultSnapshotType != "" {
+		return opts.DefaultSnapshotType
+	}
+
+	return "snapshot"
+}
+
+// getRestoreAvailabilityZone returns the AZ to restore a snapshot of the given AZ in. The AZ computed from the topology
+// requirement is replaced with the AZ of the snapshot if the requirement allows it, an explicit AZ must match.
+func getRestoreAvailabilityZone(snapAvailability, volAvailability string, explicit bool, requirement *csi.TopologyRequirement) (string, error) {
+	if snapAvailability == "" || volAvailability == "" || volAvailability == snapAvailability {
+		return volAvailability, nil
+	}
+
+	if explicit {
+		return "", status.Errorf(codes.InvalidArgument, "[CreateVolume] availability zone %s doesn't match the availability zone %s of the snapshot", volAvailability, snapAvailability)
+	}
+
+	if allowed := sharedcsi.GetAZsFromTopology(topologyKey, requirement); len(allowed) > 0 && !slices.Contains(allowed, snapAvailability) {
+		return "", status.Errorf(codes.ResourceExhausted, "[CreateVolume] the snapshot in availability zone %s can't be restored in the requested topology %v", snapAvailability, allowed)
+	}
+
+	klog.V(4).Infof("Restoring the snapshot in its availability zone %s instead of %s", snapAvailability, volAvailability)
+	return snapAvailability, nil
+}
+
+// checkExpandVolumeQuota checks that expanding the volume to the given size doesn't exceed the gigabytes quotas of
+// its project, so that the resizer gets a ResourceExhausted error instead of the failure of the resize. The check is
+// skipped when the quotas can't be read, Cinder still enforces them.
+func checkExpandVolumeQuota(cloud openstack.IOpenStack, volume *volumes.Volume, volSizeGB int) error {
+	if volume.TenantID == "" {
+		return nil
+	}
+
+	quota, err := cloud.GetVolumeQuotaUsage(volume.TenantID)
+	if err != nil {
+		klog.Warningf("Failed to get the volume quotas of project %s, skipping the quota check: %v", volume.TenantID, err)
+		return nil
+	}
+
+	if limit := quota.PerVolumeGigabytes.Limit; limit >= 0 && volSizeGB > limit {
+		return status.Errorf(codes.OutOfRange, "[ControllerExpandVolume] requested size %d GiB exceeds the per volume quota of %d GiB", volSizeGB, limit)
+	}
+
+	gigabytes := quota.Gigabytes
+	increase := volSizeGB - volume.Size
+	if gigabytes.Limit >= 0 && gigabytes.InUse+gigabytes.Reserved+increase > gigabytes.Limit {
+		return status.Errorf(codes.ResourceExhausted, "[ControllerExpandVolume] expanding volume %s by %d GiB exceeds the gigabytes quota of project %s: %d GiB of %d GiB used, %d GiB reserved",
+			volume.ID, increase, volume.TenantID, gigabytes.InUse, gigabytes.Limit, gigabytes.Reserved)
+	}
+
+	return nil
+}
+
+// expandVolumeErrorCode returns the gRPC code of the error of the Cinder volume extend request, so that the resizer
+// backs off on quota errors and the volume state errors are reported as such.
+func expandVolumeErrorCode(err error) codes.Code {
+	switch {
+	case gophercloud.ResponseCodeIs(err, http.StatusRequestEntityTooLarge):
+		// Cinder reports the exceeded quotas as overLimit
+		return codes.ResourceExhausted
+	case gophercloud.ResponseCodeIs(err, http.StatusBadRequest), gophercloud.ResponseCodeIs(err, http.StatusConflict):
+		return codes.FailedPrecondition
+	case cpoerrors.IsNotFound(err):
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
+
 func getCreateVolumeResponse(vol *volumes.Volume, volCtx map[string]string, ignoreVolumeAZ bool, accessibleTopologyReq *csi.TopologyRequirement) *csi.CreateVolumeResponse {
 	var volsrc *csi.VolumeContentSource
 	volCnx := map[string]string{}
@@ -1146,6 +1542,14 @@ func getCreateVolumeResponse(vol *volumes.Volume, volCtx map[string]string, igno
 		}
 	}
 
+	if vol.Encrypted {
+		volCnx[encryptedKey] = "true"
+	}
+
+	if volCtx[projectQuotasKey] == "true" {
+		volCnx[projectQuotasKey] = "true"
+	}
+
 	var accessibleTopology []*csi.Topology
 	// If ignore-volume-az is true , dont set the accessible topology to volume az,
 	// use from preferred topologies instead.
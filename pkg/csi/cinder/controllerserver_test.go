@@ -18,12 +18,18 @@ package cinder
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
 	openstack "k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 )
@@ -149,6 +155,143 @@ func TestCreateVolumeWithParam(t *testing.T) {
 
 }
 
+// Test CreateVolume with an ordered list of AZs
+func TestCreateVolumeWithAvailabilityZones(t *testing.T) {
+	volName := "fake-volume-az-fallback"
+	properties := map[string]string{cinderCSIClusterIDKey: FakeCluster}
+	vol := &volumes.Volume{ID: "fake-volume-az-fallback-id", Name: volName, Size: 1, AvailabilityZone: "az1"}
+
+	// az3 isn't allowed by the topology, az2 fails and az1 is the fallback
+	osmock.On("CreateVolume", volName, mock.AnythingOfType("int"), "", "az2", "", "", "", properties).Return((*volumes.Volume)(nil), gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusBadRequest})
+	osmock.On("CreateVolume", volName, mock.AnythingOfType("int"), "", "az1", "", "", "", properties).Return(vol, nil)
+	osmock.On("GetVolumesByName", volName).Return(FakeVolListEmpty, nil)
+
+	fakeReq := &csi.CreateVolumeRequest{
+		Name: volName,
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		},
+		Parameters: map[string]string{
+			availabilityZonesKey: "az3, az2, az1",
+		},
+		AccessibilityRequirements: &csi.TopologyRequirement{
+			Preferred: []*csi.Topology{
+				{Segments: map[string]string{topologyKey: "az1"}},
+			},
+			Requisite: []*csi.Topology{
+				{Segments: map[string]string{topologyKey: "az1"}},
+				{Segments: map[string]string{topologyKey: "az2"}},
+			},
+		},
+	}
+
+	actualRes, err := fakeCs.CreateVolume(FakeCtx, fakeReq)
+	assert.NoError(t, err)
+	assert.Equal(t, "az1", actualRes.Volume.AccessibleTopology[0].GetSegments()[topologyKey])
+	osmock.AssertCalled(t, "CreateVolume", volName, mock.AnythingOfType("int"), "", "az2", "", "", "", properties)
+
+	// None of the AZs is allowed
+	fakeReq.Parameters[availabilityZonesKey] = "az3"
+	_, err = fakeCs.CreateVolume(FakeCtx, fakeReq)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// Test CreateVolume falling back to the next AZ when the volume goes to error asynchronously
+func TestCreateVolumeWithAvailabilityZonesAsyncError(t *testing.T) {
+	volName := "fake-volume-az-async-fallback"
+	properties := map[string]string{cinderCSIClusterIDKey: FakeCluster}
+	failed := &volumes.Volume{ID: "fake-volume-az-async-failed-id", Name: volName, Size: 1, AvailabilityZone: "az4", Status: "creating"}
+	vol := &volumes.Volume{ID: "fake-volume-az-async-fallback-id", Name: volName, Size: 1, AvailabilityZone: "az5", Status: "creating"}
+
+	// The volume of az4 goes to error as no backend is available, az5 is the fallback
+	osmock.On("CreateVolume", volName, mock.AnythingOfType("int"), "", "az4", "", "", "", properties).Return(failed, nil)
+	osmock.On("WaitVolumeTargetStatus", failed.ID, []string{openstack.VolumeAvailableStatus}).Return(fmt.Errorf("%w : error", openstack.ErrVolumeErrorState))
+	osmock.On("DeleteVolume", failed.ID).Return(nil)
+	osmock.On("CreateVolume", volName, mock.AnythingOfType("int"), "", "az5", "", "", "", properties).Return(vol, nil)
+	osmock.On("GetVolumesByName", volName).Return(FakeVolListEmpty, nil)
+
+	fakeReq := &csi.CreateVolumeRequest{
+		Name: volName,
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		},
+		Parameters: map[string]string{
+			availabilityZonesKey: "az4,az5",
+		},
+	}
+
+	actualRes, err := fakeCs.CreateVolume(FakeCtx, fakeReq)
+	assert.NoError(t, err)
+	assert.Equal(t, vol.ID, actualRes.Volume.VolumeId)
+	osmock.AssertCalled(t, "DeleteVolume", failed.ID)
+	// The volume isn't waited for without AZ to fall back to
+	osmock.AssertNotCalled(t, "WaitVolumeTargetStatus", vol.ID, mock.Anything)
+}
+
+func TestGetOrderedAvailabilityZones(t *testing.T) {
+	requirement := &csi.TopologyRequirement{
+		Preferred: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "az1"}},
+		},
+		Requisite: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "az1"}},
+			{Segments: map[string]string{topologyKey: "az2"}},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		zones       string
+		requirement *csi.TopologyRequirement
+		expected    []string
+		wantErr     bool
+	}{
+		{
+			name:        "order of the list",
+			zones:       "az2,az1",
+			requirement: requirement,
+			expected:    []string{"az2", "az1"},
+		},
+		{
+			name:        "zones not allowed by the topology",
+			zones:       "az3, az1, az1",
+			requirement: requirement,
+			expected:    []string{"az1"},
+		},
+		{
+			name:     "no topology requirement",
+			zones:    "az3,az1",
+			expected: []string{"az3", "az1"},
+		},
+		{
+			name:        "no zone allowed",
+			zones:       "az3",
+			requirement: requirement,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zones, err := getOrderedAvailabilityZones(tc.zones, tc.requirement)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, zones)
+		})
+	}
+}
+
 func TestCreateVolumeWithExtraMetadata(t *testing.T) {
 	// mock OpenStack
 	properties := map[string]string{
@@ -242,6 +385,180 @@ func TestCreateVolumeFromSnapshot(t *testing.T) {
 
 }
 
+// Test CreateVolume from a snapshot in an AZ the topology doesn't allow
+func TestCreateVolumeFromSnapshotIncompatibleTopology(t *testing.T) {
+	fakeReq := &csi.CreateVolumeRequest{
+		Name: "fake-volume-from-snapshot-other-az",
+		VolumeCapabilities: []*csi.VolumeCapability{
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		},
+		VolumeContentSource: &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{
+					SnapshotId: FakeSnapshotID,
+				},
+			},
+		},
+		AccessibilityRequirements: &csi.TopologyRequirement{
+			Requisite: []*csi.Topology{
+				{Segments: map[string]string{topologyKey: "az2"}},
+			},
+		},
+	}
+	osmock.On("GetVolumesByName", fakeReq.Name).Return(FakeVolListEmpty, nil)
+
+	// The snapshot of the mock is in AZ nova
+	_, err := fakeCs.CreateVolume(FakeCtx, fakeReq)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestGetVolumeType(t *testing.T) {
+	tiered := map[string]string{volumeTypesKey: "premium, standard"}
+
+	testCases := []struct {
+		name           string
+		volParams      map[string]string
+		pvcAnnotations map[string]string
+		expected       string
+		expectedErr    bool
+	}{
+		{
+			name:      "single type",
+			volParams: map[string]string{"type": "standard"},
+			expected:  "standard",
+		},
+		{
+			name:      "first tier by default",
+			volParams: tiered,
+			expected:  "premium",
+		},
+		{
+			name:           "tier chosen by the PVC",
+			volParams:      tiered,
+			pvcAnnotations: map[string]string{volumeTypeKey: "standard"},
+			expected:       "standard",
+		},
+		{
+			name:           "tier not allowed",
+			volParams:      tiered,
+			pvcAnnotations: map[string]string{volumeTypeKey: "gold"},
+			expectedErr:    true,
+		},
+		{
+			name:        "both parameters",
+			volParams:   map[string]string{"type": "standard", volumeTypesKey: "premium"},
+			expectedErr: true,
+		},
+		{
+			name:        "empty list",
+			volParams:   map[string]string{volumeTypesKey: ","},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volType, err := getVolumeType(tc.volParams, tc.pvcAnnotations)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, volType)
+		})
+	}
+}
+
+func TestGetSnapshotType(t *testing.T) {
+	backupDefault := openstack.BlockStorageOpts{DefaultSnapshotType: "backup"}
+
+	assert.Equal(t, "snapshot", getSnapshotType(nil, openstack.BlockStorageOpts{}))
+	assert.Equal(t, "backup", getSnapshotType(nil, backupDefault))
+	assert.Equal(t, "snapshot", getSnapshotType(map[string]string{openstack.SnapshotType: "snapshot"}, backupDefault))
+}
+
+func TestGetRestoreAvailabilityZone(t *testing.T) {
+	requirement := &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "az1"}},
+			{Segments: map[string]string{topologyKey: "az2"}},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		snapAvailability string
+		volAvailability  string
+		explicit         bool
+		requirement      *csi.TopologyRequirement
+		expected         string
+		expectedCode     codes.Code
+	}{
+		{
+			name:             "same zone",
+			snapAvailability: "az1",
+			volAvailability:  "az1",
+			requirement:      requirement,
+			expected:         "az1",
+		},
+		{
+			name:            "unknown snapshot zone",
+			volAvailability: "az1",
+			requirement:     requirement,
+			expected:        "az1",
+		},
+		{
+			name:             "no volume zone",
+			snapAvailability: "az1",
+			expected:         "",
+		},
+		{
+			name:             "zone allowed by the topology",
+			snapAvailability: "az2",
+			volAvailability:  "az1",
+			requirement:      requirement,
+			expected:         "az2",
+		},
+		{
+			name:             "no topology requirement",
+			snapAvailability: "az3",
+			volAvailability:  "az1",
+			expected:         "az3",
+		},
+		{
+			name:             "zone not allowed by the topology",
+			snapAvailability: "az3",
+			volAvailability:  "az1",
+			requirement:      requirement,
+			expectedCode:     codes.ResourceExhausted,
+		},
+		{
+			name:             "explicit zone",
+			snapAvailability: "az2",
+			volAvailability:  "az1",
+			explicit:         true,
+			requirement:      requirement,
+			expectedCode:     codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone, err := getRestoreAvailabilityZone(tc.snapAvailability, tc.volAvailability, tc.explicit, tc.requirement)
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, zone)
+		})
+	}
+}
+
 func TestCreateVolumeFromSourceVolume(t *testing.T) {
 	properties := map[string]string{cinderCSIClusterIDKey: FakeCluster}
 	// CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourceVolID string, sourceBackupID string, tags map[string]string) (string, string, int, error)
@@ -355,6 +672,8 @@ func TestControllerPublishVolume(t *testing.T) {
 	osmock.On("WaitDiskAttached", FakeNodeID, FakeVolID).Return(nil)
 	// GetAttachmentDiskPath(instanceID, volumeID string) (string, error)
 	osmock.On("GetAttachmentDiskPath", FakeNodeID, FakeVolID).Return(FakeDevicePath, nil)
+	// UpdateVolumeMetadata(volumeID string, metadata map[string]string) error
+	osmock.On("UpdateVolumeMetadata", "261a8b81-3660-43e5-bab8-6470b65ee4e9", map[string]string{cinderCSIClusterIDKey: FakeCluster}).Return(nil)
 
 	// Init assert
 	assert := assert.New(t)
@@ -433,6 +752,7 @@ func genFakeVolumeEntry(fakeVol volumes.Volume) *csi.ListVolumesResponse_Entry {
 		},
 		Status: &csi.ListVolumesResponse_VolumeStatus{
 			PublishedNodeIds: extractFakeNodeIDs(fakeVol.Attachments),
+			VolumeCondition:  &csi.VolumeCondition{Message: "Volume is healthy"},
 		},
 	}
 }
@@ -1318,7 +1638,7 @@ func TestGlobalListVolumesMultipleClouds(t *testing.T) {
 // Test CreateSnapshot
 func TestCreateSnapshot(t *testing.T) {
 
-	osmock.On("CreateSnapshot", FakeSnapshotName, FakeVolID, map[string]string{cinderCSIClusterIDKey: "cluster"}).Return(&FakeSnapshotRes, nil)
+	osmock.On("CreateSnapshot", FakeSnapshotName, FakeVolID, map[string]string{cinderCSIClusterIDKey: "cluster", snapshotAvailabilityZoneKey: "nova"}).Return(&FakeSnapshotRes, nil)
 	osmock.On("ListSnapshots", map[string]string{"Name": FakeSnapshotName}).Return(FakeSnapshotListEmpty, "", nil)
 	osmock.On("WaitSnapshotReady", FakeSnapshotID).Return(FakeSnapshotRes.Status, nil)
 	osmock.On("ListBackups", map[string]string{"Name": FakeSnapshotName}).Return(FakeBackupListEmpty, nil)
@@ -1353,6 +1673,7 @@ func TestCreateSnapshotWithExtraMetadata(t *testing.T) {
 		sharedcsi.VolSnapshotContentNameKey: FakeSnapshotContentName,
 		sharedcsi.VolSnapshotNamespaceKey:   FakeSnapshotNamespace,
 		openstack.SnapshotForceCreate:       "true",
+		snapshotAvailabilityZoneKey:         "nova",
 	}
 
 	osmock.On("CreateSnapshot", FakeSnapshotName, FakeVolID, properties).Return(&FakeSnapshotRes, nil)
@@ -1464,6 +1785,66 @@ func TestControllerExpandVolume(t *testing.T) {
 
 }
 
+func TestCheckExpandVolumeQuota(t *testing.T) {
+	vol := &volumes.Volume{ID: FakeVolID, TenantID: "project", Size: 10}
+
+	tests := []struct {
+		name     string
+		quota    *quotasets.QuotaUsageSet
+		quotaErr error
+		size     int
+		code     codes.Code
+	}{
+		{
+			name:  "unlimited",
+			quota: &quotasets.QuotaUsageSet{Gigabytes: quotasets.QuotaUsage{Limit: -1}, PerVolumeGigabytes: quotasets.QuotaUsage{Limit: -1}},
+			size:  1000,
+			code:  codes.OK,
+		},
+		{
+			name:  "within quota",
+			quota: &quotasets.QuotaUsageSet{Gigabytes: quotasets.QuotaUsage{Limit: 100, InUse: 80, Reserved: 5}, PerVolumeGigabytes: quotasets.QuotaUsage{Limit: -1}},
+			size:  25,
+			code:  codes.OK,
+		},
+		{
+			name:  "gigabytes quota exceeded",
+			quota: &quotasets.QuotaUsageSet{Gigabytes: quotasets.QuotaUsage{Limit: 100, InUse: 80, Reserved: 5}, PerVolumeGigabytes: quotasets.QuotaUsage{Limit: -1}},
+			size:  26,
+			code:  codes.ResourceExhausted,
+		},
+		{
+			name:  "per volume quota exceeded",
+			quota: &quotasets.QuotaUsageSet{Gigabytes: quotasets.QuotaUsage{Limit: -1}, PerVolumeGigabytes: quotasets.QuotaUsage{Limit: 50}},
+			size:  60,
+			code:  codes.OutOfRange,
+		},
+		{
+			name:     "quotas not readable",
+			quotaErr: gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusForbidden},
+			size:     1000,
+			code:     codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := new(openstack.OpenStackMock)
+			cloud.On("GetVolumeQuotaUsage", "project").Return(tt.quota, tt.quotaErr)
+
+			err := checkExpandVolumeQuota(cloud, vol, tt.size)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestExpandVolumeErrorCode(t *testing.T) {
+	assert.Equal(t, codes.ResourceExhausted, expandVolumeErrorCode(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusRequestEntityTooLarge}))
+	assert.Equal(t, codes.FailedPrecondition, expandVolumeErrorCode(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusBadRequest}))
+	assert.Equal(t, codes.NotFound, expandVolumeErrorCode(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusNotFound}))
+	assert.Equal(t, codes.Internal, expandVolumeErrorCode(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusInternalServerError}))
+}
+
 func TestValidateVolumeCapabilities(t *testing.T) {
 	// GetVolume(volumeID string)
 	osmock.On("GetVolume", FakeVolID).Return(FakeVol1)
@@ -1527,3 +1908,12 @@ func TestValidateVolumeCapabilities(t *testing.T) {
 	assert.Equal(expectedRes2, actualRes2)
 
 }
+
+func TestGetVolumeCondition(t *testing.T) {
+	other := &volumes.Volume{ID: FakeVolID, Status: "available", Metadata: map[string]string{cinderCSIClusterIDKey: "other"}}
+
+	assert.False(t, getVolumeCondition(&FakeVol1, FakeCluster, true).Abnormal)
+	assert.True(t, getVolumeCondition(&volumes.Volume{ID: FakeVolID, Status: volumeStatusError}, FakeCluster, false).Abnormal)
+	assert.True(t, getVolumeCondition(other, FakeCluster, true).Abnormal)
+	assert.False(t, getVolumeCondition(other, FakeCluster, false).Abnormal)
+}
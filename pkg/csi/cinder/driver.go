@@ -18,11 +18,18 @@ package cinder
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/listers/core/v1"
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/cloud-provider-openstack/pkg/util/mount"
@@ -39,6 +46,11 @@ const (
 
 	// ResizeRequired parameter, if set to true, will trigger a resize on mount operation
 	ResizeRequired = driverName + "/resizeRequired"
+
+	// MountOptionsAnnotation is the PV annotation holding a comma-separated list of
+	// additional mount options. The options are applied on the next NodeStageVolume,
+	// remounting the staged volume if it's already mounted.
+	MountOptionsAnnotation = driverName + "/mount-options"
 )
 
 var (
@@ -69,6 +81,21 @@ type Driver struct {
 	clusterID    string
 	withTopology bool
 
+	shutdownTimeout time.Duration
+	shutdownJournal string
+
+	kclient              kubernetes.Interface
+	volumeHealthInterval time.Duration
+	pvLabelSyncInterval  time.Duration
+	flattenInterval      time.Duration
+	flattenChainDepth    int
+	flattenWindow        *MaintenanceWindow
+	flattenLeaseNS       string
+	zoneBalanceInterval  time.Duration
+
+	namespaceCapacityLimits bool
+	ephemeralGCInterval     time.Duration
+
 	ids *identityServer
 	cs  *controllerServer
 	ns  *nodeServer
@@ -78,6 +105,8 @@ type Driver struct {
 	nscap []*csi.NodeServiceCapability
 
 	pvcLister v1.PersistentVolumeClaimLister
+	pvLister  sharedcsi.PVLister
+	podClient kubernetes.Interface
 }
 
 type DriverOpts struct {
@@ -85,17 +114,66 @@ type DriverOpts struct {
 	Endpoint     string
 	WithTopology bool
 
+	// ShutdownTimeout bounds the wait for the in-flight operations on SIGTERM.
+	ShutdownTimeout time.Duration
+	// ShutdownJournal is the file the operations interrupted by the shutdown are persisted to, optional.
+	ShutdownJournal string
+
+	// KubeClient is used by the volume health remediation, the PV label synchronization, the volume flattening, the
+	// zone balance report, the spread groups and the namespace capacity limits, optional.
+	KubeClient kubernetes.Interface
+	// VolumeHealthCheckInterval is the interval of the volume health checks, 0 disables the remediation.
+	VolumeHealthCheckInterval time.Duration
+	// PVLabelSyncInterval is the interval of the PV label synchronization, 0 disables it.
+	PVLabelSyncInterval time.Duration
+	// FlattenInterval is the interval of the checks of the snapshot and clone chains of the volumes.
+	FlattenInterval time.Duration
+	// FlattenChainDepth is the chain depth from which the volumes are flattened, 0 disables the flattening.
+	FlattenChainDepth int
+	// FlattenWindow restricts the flattening to a daily maintenance window, optional.
+	FlattenWindow *MaintenanceWindow
+	// FlattenLeaseNamespace is the namespace of the Lease electing the controller plugin replica flattening the
+	// volumes.
+	FlattenLeaseNamespace string
+	// ZoneBalanceReportInterval is the interval of the report of the balance of the PVs across the availability
+	// zones, 0 disables it.
+	ZoneBalanceReportInterval time.Duration
+	// NamespaceCapacityLimits enables the NamespaceCapacityLimitAnnotation of the namespaces, read with KubeClient.
+	NamespaceCapacityLimits bool
+	// EphemeralVolumeGCInterval is the interval of the collection of the orphaned inline ephemeral volumes, 0
+	// disables it.
+	EphemeralVolumeGCInterval time.Duration
+
 	PVCLister v1.PersistentVolumeClaimLister
+	PVLister  sharedcsi.PVLister
+	// PodClient reads the annotations of the pods the volumes are published for, optional.
+	PodClient kubernetes.Interface
 }
 
 func NewDriver(o *DriverOpts) *Driver {
 	d := &Driver{
-		name:         driverName,
-		fqVersion:    fmt.Sprintf("%s@%s", Version, version.Version),
-		endpoint:     o.Endpoint,
-		clusterID:    o.ClusterID,
-		withTopology: o.WithTopology,
-		pvcLister:    o.PVCLister,
+		name:            driverName,
+		fqVersion:       fmt.Sprintf("%s@%s", Version, version.Version),
+		endpoint:        o.Endpoint,
+		clusterID:       o.ClusterID,
+		withTopology:    o.WithTopology,
+		shutdownTimeout: o.ShutdownTimeout,
+		shutdownJournal: o.ShutdownJournal,
+		pvcLister:       o.PVCLister,
+		pvLister:        o.PVLister,
+		podClient:       o.PodClient,
+
+		kclient:              o.KubeClient,
+		volumeHealthInterval: o.VolumeHealthCheckInterval,
+		pvLabelSyncInterval:  o.PVLabelSyncInterval,
+		flattenInterval:      o.FlattenInterval,
+		flattenChainDepth:    o.FlattenChainDepth,
+		flattenWindow:        o.FlattenWindow,
+		flattenLeaseNS:       o.FlattenLeaseNamespace,
+		zoneBalanceInterval:  o.ZoneBalanceReportInterval,
+
+		namespaceCapacityLimits: o.NamespaceCapacityLimits,
+		ephemeralGCInterval:     o.EphemeralVolumeGCInterval,
 	}
 
 	klog.Info("Driver: ", d.name)
@@ -114,10 +192,13 @@ func NewDriver(o *DriverOpts) *Driver {
 			csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
 			csi.ControllerServiceCapability_RPC_GET_VOLUME,
+			csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
 		})
 	d.AddVolumeCapabilityAccessModes(
 		[]csi.VolumeCapability_AccessMode_Mode{
 			csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			// Only confirmed for the block volumes of a volume type with multiattach enabled
+			csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 		})
 
 	// ignoring error, because AddNodeServiceCapabilities is public
@@ -127,6 +208,7 @@ func NewDriver(o *DriverOpts) *Driver {
 			csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
 			csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
 			csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
 		})
 
 	d.ids = NewIdentityServer(d)
@@ -192,6 +274,22 @@ func (d *Driver) GetVolumeCapabilityAccessModes() []*csi.VolumeCapability_Access
 func (d *Driver) SetupControllerService(clouds map[string]openstack.IOpenStack) {
 	klog.Info("Providing controller service")
 	d.cs = NewControllerServer(d, clouds)
+
+	if d.kclient != nil && d.volumeHealthInterval > 0 {
+		d.cs.volumeHealth = newVolumeHealthMonitor(clouds, d.kclient, d.volumeHealthInterval)
+	}
+	if d.kclient != nil && d.pvLabelSyncInterval > 0 {
+		d.cs.volumeLabels = newVolumeLabelSyncer(clouds, d.kclient, d.pvLabelSyncInterval)
+	}
+	if d.kclient != nil && d.flattenChainDepth > 0 && d.flattenInterval > 0 {
+		d.cs.volumeFlattener = newVolumeFlattener(clouds, d.kclient, d.flattenInterval, d.flattenChainDepth, d.flattenWindow, d.flattenLeaseNS)
+	}
+	if d.kclient != nil && d.zoneBalanceInterval > 0 {
+		d.cs.volumeZoneBalance = newVolumeZoneBalanceReporter(d.kclient, d.pvLister, d.zoneBalanceInterval)
+	}
+	if d.ephemeralGCInterval > 0 {
+		d.cs.ephemeralGC = newEphemeralVolumeCollector(clouds, d.clusterID, d.ephemeralGCInterval)
+	}
 }
 
 func (d *Driver) SetupNodeService(mount mount.IMount, metadata metadata.IMetadata, opts openstack.BlockStorageOpts, topologies map[string]string) {
@@ -199,10 +297,63 @@ func (d *Driver) SetupNodeService(mount mount.IMount, metadata metadata.IMetadat
 	d.ns = NewNodeServer(d, mount, metadata, opts, topologies)
 }
 
+// SetupNodeEphemeralVolumes enables the CSI inline ephemeral volumes on the node service, their Cinder volumes are
+// created and deleted by the node with the given OpenStack client, within the limits of the options.
+func (d *Driver) SetupNodeEphemeralVolumes(cloud openstack.IOpenStack, opts EphemeralVolumeOpts) error {
+	klog.Info("Providing CSI inline ephemeral volumes")
+	ephemeral, err := newEphemeralVolumes(cloud, d.clusterID, opts)
+	if err != nil {
+		return err
+	}
+	d.ns.ephemeral = ephemeral
+	return nil
+}
+
 func (d *Driver) Run() {
 	if nil == d.cs && nil == d.ns {
 		klog.Fatal("No CSI services initialized")
 	}
 
-	RunServicesInitialized(d.endpoint, d.ids, d.cs, d.ns)
+	shutdown := newShutdownManager(d.shutdownJournal)
+	interrupted, err := shutdown.recoverJournal()
+	if err != nil {
+		klog.Warningf("Failed to recover the operations interrupted by the previous shutdown: %v", err)
+	}
+	if d.cs != nil && len(interrupted) > 0 {
+		d.cs.cleanupInterruptedOperations(interrupted)
+	}
+
+	s := &nonBlockingGRPCServer{shutdown: shutdown}
+	s.Start(d.endpoint, d.ids, d.cs, d.ns)
+
+	if d.cs != nil && d.cs.volumeHealth != nil {
+		go d.cs.volumeHealth.run(wait.NeverStop)
+	}
+	if d.cs != nil && d.cs.volumeLabels != nil {
+		go d.cs.volumeLabels.run(wait.NeverStop)
+	}
+	if d.cs != nil && d.cs.volumeFlattener != nil {
+		go d.cs.volumeFlattener.run(wait.NeverStop)
+	}
+	if d.cs != nil && d.cs.volumeZoneBalance != nil {
+		go d.cs.volumeZoneBalance.run(wait.NeverStop)
+	}
+	if d.cs != nil && d.cs.ephemeralGC != nil {
+		go d.cs.ephemeralGC.run(wait.NeverStop)
+	}
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		sig := <-sigCh
+
+		klog.Infof("Received %v, stopping the CSI server", sig)
+		if interrupted := shutdown.drain(d.shutdownTimeout); len(interrupted) > 0 {
+			s.ForceStop()
+			return
+		}
+		s.Stop()
+	}()
+
+	s.Wait()
 }
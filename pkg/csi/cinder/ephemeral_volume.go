@@ -0,0 +1,415 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/uuid"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	"k8s.io/cloud-provider-openstack/pkg/util"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+const (
+	// ephemeralVolumeKey is the metadata of the Cinder volumes created for the CSI inline ephemeral volumes, only the
+	// volumes with it are deleted when their pod is gone.
+	ephemeralVolumeKey = "cinder.csi.openstack.org/ephemeral"
+	// ephemeralCapacityKey and ephemeralTypeKey are the volumeAttributes of the inline ephemeral volumes
+	ephemeralCapacityKey = "capacity"
+	ephemeralTypeKey     = "type"
+
+	defaultEphemeralCapacity = "1Gi"
+
+	// ephemeralVolumeGCGracePeriod is the age from which the detached ephemeral volumes are collected, the volumes
+	// are detached between their creation and their attachment by the node.
+	ephemeralVolumeGCGracePeriod = 10 * time.Minute
+)
+
+// EphemeralVolumeOpts are the limits set by the operator on the CSI inline ephemeral volumes.
+type EphemeralVolumeOpts struct {
+	// MaxCapacity is the maximum capacity of a volume, e.g. "10Gi", unlimited when empty.
+	MaxCapacity string
+	// Types are the volume types allowed, the first one by default. All the volume types are allowed when empty.
+	Types []string
+}
+
+// ephemeralVolumes creates the Cinder volumes of the CSI inline ephemeral volumes of the pods on the node, the volumes
+// follow the lifecycle of their pod: they are created and attached when published, and deleted when unpublished.
+type ephemeralVolumes struct {
+	cloud     openstack.IOpenStack
+	clusterID string
+	// maxSizeGB is the maximum size of the volumes, 0 when unlimited
+	maxSizeGB int
+	// types are the volume types allowed, all of them when empty
+	types []string
+}
+
+func newEphemeralVolumes(cloud openstack.IOpenStack, clusterID string, opts EphemeralVolumeOpts) (*ephemeralVolumes, error) {
+	e := &ephemeralVolumes{cloud: cloud, clusterID: clusterID}
+	if opts.MaxCapacity != "" {
+		maxSizeGB, err := ephemeralVolumeSize(opts.MaxCapacity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum capacity of the ephemeral volumes: %v", err)
+		}
+		e.maxSizeGB = maxSizeGB
+	}
+	for _, t := range opts.Types {
+		if t = strings.TrimSpace(t); t != "" {
+			e.types = append(e.types, t)
+		}
+	}
+	return e, nil
+}
+
+// volumeType checks the size and the type of the inline ephemeral volume against the limits of the operator. It
+// returns the volume type to create the volume with, the first allowed type when none is requested.
+func (e *ephemeralVolumes) volumeType(sizeGB int, volType string) (string, error) {
+	if e.maxSizeGB > 0 && sizeGB > e.maxSizeGB {
+		return "", fmt.Errorf("%s of %d GiB exceeds the maximum of %d GiB", ephemeralCapacityKey, sizeGB, e.maxSizeGB)
+	}
+	if len(e.types) == 0 {
+		return volType, nil
+	}
+	if volType == "" {
+		return e.types[0], nil
+	}
+	if !slices.Contains(e.types, volType) {
+		return "", fmt.Errorf("%s %q is not allowed, the allowed volume types are %s", ephemeralTypeKey, volType, strings.Join(e.types, ", "))
+	}
+	return volType, nil
+}
+
+// ephemeralVolumeName returns the name of the Cinder volume of the inline ephemeral volume, the volume handle given by
+// the kubelet is unique to the pod and the volume.
+func ephemeralVolumeName(volumeID string) string {
+	return fmt.Sprintf("ephemeral-%s", volumeID)
+}
+
+// ephemeralVolumeSize returns the size in GiB of the inline ephemeral volume of the given capacity.
+func ephemeralVolumeSize(capacity string) (int, error) {
+	if capacity == "" {
+		capacity = defaultEphemeralCapacity
+	}
+	quantity, err := resource.ParseQuantity(capacity)
+	if err != nil || quantity.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive quantity", ephemeralCapacityKey, capacity)
+	}
+	return int(util.RoundUpSize(quantity.Value(), 1024*1024*1024)), nil
+}
+
+// owns checks whether the volume was created for an inline ephemeral volume of the cluster.
+func (e *ephemeralVolumes) owns(vol *volumes.Volume) bool {
+	if vol.Metadata[ephemeralVolumeKey] != "true" {
+		return false
+	}
+	return e.clusterID == "" || vol.Metadata[cinderCSIClusterIDKey] == e.clusterID
+}
+
+// checkEphemeralVolumeQuota checks that creating a volume of the given size doesn't exceed the volumes and gigabytes
+// quotas of the project, so that the pod gets a ResourceExhausted error instead of a volume in error.
+func checkEphemeralVolumeQuota(quota *quotasets.QuotaUsageSet, sizeGB int) error {
+	if limit := quota.PerVolumeGigabytes.Limit; limit >= 0 && sizeGB > limit {
+		return status.Errorf(codes.ResourceExhausted, "[NodePublishVolume] ephemeral volume of %d GiB exceeds the per volume quota of %d GiB", sizeGB, limit)
+	}
+	if v := quota.Volumes; v.Limit >= 0 && v.InUse+v.Reserved+1 > v.Limit {
+		return status.Errorf(codes.ResourceExhausted, "[NodePublishVolume] ephemeral volume exceeds the volumes quota: %d of %d used, %d reserved", v.InUse, v.Limit, v.Reserved)
+	}
+	if g := quota.Gigabytes; g.Limit >= 0 && g.InUse+g.Reserved+sizeGB > g.Limit {
+		return status.Errorf(codes.ResourceExhausted, "[NodePublishVolume] ephemeral volume of %d GiB exceeds the gigabytes quota: %d GiB of %d GiB used, %d GiB reserved", sizeGB, g.InUse, g.Limit, g.Reserved)
+	}
+	return nil
+}
+
+// ensureVolume returns the volume of the inline ephemeral volume, created if missing. The volumes are accounted to
+// the namespace of the pod, as the volumes of its PersistentVolumeClaims are.
+func (e *ephemeralVolumes) ensureVolume(volumeID, instanceID, availability string, sizeGB int, volType string, volCtx map[string]string) (*volumes.Volume, error) {
+	name := ephemeralVolumeName(volumeID)
+	vols, err := e.cloud.GetVolumesByName(name)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "[NodePublishVolume] failed to get volume %s: %v", name, err)
+	}
+	if len(vols) > 1 {
+		return nil, status.Errorf(codes.Internal, "[NodePublishVolume] multiple volumes named %s", name)
+	}
+	if len(vols) == 1 {
+		if !e.owns(&vols[0]) {
+			return nil, status.Errorf(codes.AlreadyExists, "[NodePublishVolume] volume %s already exists and is not an ephemeral volume of the cluster", name)
+		}
+		return &vols[0], nil
+	}
+
+	server, err := e.cloud.GetInstanceByID(instanceID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "[NodePublishVolume] failed to get instance %s: %v", instanceID, err)
+	}
+	if server.TenantID != "" {
+		quota, err := e.cloud.GetVolumeQuotaUsage(server.TenantID)
+		if err != nil {
+			// Cinder still enforces the quotas
+			klog.Warningf("Failed to get the volume quotas of project %s, skipping the quota check: %v", server.TenantID, err)
+		} else if err := checkEphemeralVolumeQuota(quota, sizeGB); err != nil {
+			return nil, err
+		}
+	}
+
+	properties := map[string]string{ephemeralVolumeKey: "true"}
+	if e.clusterID != "" {
+		properties[cinderCSIClusterIDKey] = e.clusterID
+	}
+	if namespace := volCtx[sharedcsi.PodNamespaceKey]; namespace != "" {
+		// Counted in the namespaceCapacityLimit of the StorageClasses
+		properties[sharedcsi.PvcNamespaceKey] = namespace
+		properties[sharedcsi.PodNameKey] = volCtx[sharedcsi.PodNameKey]
+	}
+
+	opts := &volumes.CreateOpts{
+		Name:             name,
+		Size:             sizeGB,
+		VolumeType:       volType,
+		AvailabilityZone: availability,
+		Metadata:         properties,
+	}
+	vol, err := e.cloud.CreateVolume(opts, nil)
+	if err != nil {
+		return nil, status.Errorf(expandVolumeErrorCode(err), "[NodePublishVolume] failed to create ephemeral volume %s: %v", name, err)
+	}
+	klog.V(4).Infof("Created ephemeral volume %s (%s) of %d GiB", name, vol.ID, sizeGB)
+
+	if err := e.cloud.WaitVolumeTargetStatus(vol.ID, []string{openstack.VolumeAvailableStatus}); err != nil {
+		if delErr := e.deleteVolume(instanceID, vol); delErr != nil {
+			klog.Warningf("Failed to clean up ephemeral volume %s: %v", vol.ID, delErr)
+		}
+		return nil, status.Errorf(codes.Internal, "[NodePublishVolume] ephemeral volume %s is not available: %v", name, err)
+	}
+
+	return vol, nil
+}
+
+// deleteVolume detaches the volume from the instance and deletes it, the volume already deleted is skipped.
+func (e *ephemeralVolumes) deleteVolume(instanceID string, vol *volumes.Volume) error {
+	for _, att := range vol.Attachments {
+		if att.ServerID != instanceID {
+			continue
+		}
+		if err := e.cloud.DetachVolume(instanceID, vol.ID); err != nil && !cpoerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to detach ephemeral volume %s: %v", vol.ID, err)
+		}
+		if err := e.cloud.WaitDiskDetached(instanceID, vol.ID); err != nil {
+			return fmt.Errorf("failed to wait for ephemeral volume %s to be detached: %v", vol.ID, err)
+		}
+	}
+
+	if err := e.cloud.DeleteVolume(vol.ID); err != nil && !cpoerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ephemeral volume %s: %v", vol.ID, err)
+	}
+	klog.V(4).Infof("Deleted ephemeral volume %s (%s)", vol.Name, vol.ID)
+	return nil
+}
+
+// nodePublishEphemeral creates the volume of the inline ephemeral volume, attaches it to the node, formats it and
+// mounts it at the target path. The volume is deleted when it can't be published.
+func (ns *nodeServer) nodePublishEphemeral(req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	volumeID := req.GetVolumeId()
+	targetPath := req.GetTargetPath()
+	volCtx := req.GetVolumeContext()
+	volumeCapability := req.GetVolumeCapability()
+	m := ns.Mount
+
+	if volumeCapability.GetBlock() != nil {
+		return nil, status.Error(codes.InvalidArgument, "[NodePublishVolume] inline ephemeral volumes must be filesystem volumes")
+	}
+
+	notMnt, err := m.IsLikelyNotMountPointAttach(targetPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !notMnt {
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
+	sizeGB, err := ephemeralVolumeSize(volCtx[ephemeralCapacityKey])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[NodePublishVolume] %v", err)
+	}
+	volType, err := ns.ephemeral.volumeType(sizeGB, volCtx[ephemeralTypeKey])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "[NodePublishVolume] %v", err)
+	}
+
+	instanceID, err := ns.Metadata.GetInstanceID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "[NodePublishVolume] failed to get the instance ID of the node: %v", err)
+	}
+	var availability string
+	if !ns.Opts.IgnoreVolumeAZ {
+		availability, err = ns.Metadata.GetAvailabilityZone()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "[NodePublishVolume] failed to get the availability zone of the node: %v", err)
+		}
+	}
+
+	vol, err := ns.ephemeral.ensureVolume(volumeID, instanceID, availability, sizeGB, volType, volCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ns.publishEphemeralVolume(req, instanceID, vol.ID); err != nil {
+		if vol, getErr := ns.ephemeral.cloud.GetVolume(vol.ID); getErr == nil {
+			if delErr := ns.ephemeral.deleteVolume(instanceID, vol); delErr != nil {
+				klog.Warningf("Failed to clean up ephemeral volume %s: %v", vol.ID, delErr)
+			}
+		}
+		return nil, err
+	}
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+func (ns *nodeServer) publishEphemeralVolume(req *csi.NodePublishVolumeRequest, instanceID, volID string) error {
+	cloud := ns.ephemeral.cloud
+	m := ns.Mount
+
+	if _, err := cloud.AttachVolume(instanceID, volID); err != nil {
+		return status.Errorf(codes.Internal, "[NodePublishVolume] failed to attach ephemeral volume %s: %v", volID, err)
+	}
+	if err := cloud.WaitDiskAttached(instanceID, volID); err != nil {
+		return status.Errorf(codes.Internal, "[NodePublishVolume] failed to wait for ephemeral volume %s to be attached: %v", volID, err)
+	}
+
+	devicePath, err := getDevicePath(volID, m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to find Device path for volume: %v", err)
+	}
+
+	if err := m.MakeDir(req.GetTargetPath()); err != nil {
+		return status.Errorf(codes.Internal, "Could not create dir %q: %v", req.GetTargetPath(), err)
+	}
+
+	fsType := "ext4"
+	var options []string
+	if mnt := req.GetVolumeCapability().GetMount(); mnt != nil {
+		if mnt.FsType != "" {
+			fsType = mnt.FsType
+		}
+		options = collectMountOptions(fsType, mnt.GetMountFlags())
+	}
+	if req.GetReadonly() {
+		options = append(options, "ro")
+	}
+
+	if err := ns.formatAndMountRetry(devicePath, req.GetTargetPath(), fsType, options, nil); err != nil {
+		return status.Errorf(codes.Internal, "[NodePublishVolume] failed to mount ephemeral volume %s: %v", volID, err)
+	}
+
+	return nil
+}
+
+// nodeUnpublishEphemeral deletes the volume of the inline ephemeral volume once unmounted. The persistent volumes,
+// whose handle is the UUID of their Cinder volume, are skipped without calling Cinder.
+func (ns *nodeServer) nodeUnpublishEphemeral(volumeID string) error {
+	if _, err := uuid.Parse(volumeID); err == nil {
+		return nil
+	}
+
+	vols, err := ns.ephemeral.cloud.GetVolumesByName(ephemeralVolumeName(volumeID))
+	if err != nil {
+		return status.Errorf(codes.Internal, "[NodeUnpublishVolume] failed to get ephemeral volume of %s: %v", volumeID, err)
+	}
+
+	var instanceID string
+	for i := range vols {
+		vol := &vols[i]
+		if !ns.ephemeral.owns(vol) {
+			continue
+		}
+		if instanceID == "" {
+			instanceID, err = ns.Metadata.GetInstanceID()
+			if err != nil {
+				return status.Errorf(codes.Internal, "[NodeUnpublishVolume] failed to get the instance ID of the node: %v", err)
+			}
+		}
+		if err := ns.ephemeral.deleteVolume(instanceID, vol); err != nil {
+			return status.Errorf(codes.Internal, "[NodeUnpublishVolume] %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ephemeralVolumeCollector periodically deletes the Cinder volumes of the inline ephemeral volumes left behind by the
+// nodes, e.g. when the node was deleted before unpublishing them: Nova detaches the volumes of the deleted servers.
+// The volumes detached for longer than the grace period are deleted, the attached ones are left to their node.
+type ephemeralVolumeCollector struct {
+	clouds    map[string]openstack.IOpenStack
+	clusterID string
+	interval  time.Duration
+}
+
+func newEphemeralVolumeCollector(clouds map[string]openstack.IOpenStack, clusterID string, interval time.Duration) *ephemeralVolumeCollector {
+	return &ephemeralVolumeCollector{
+		clouds:    clouds,
+		clusterID: clusterID,
+		interval:  interval,
+	}
+}
+
+func (c *ephemeralVolumeCollector) run(stopCh <-chan struct{}) {
+	klog.Infof("Collecting the orphaned ephemeral volumes every %v", c.interval)
+	wait.Until(c.collect, c.interval, stopCh)
+}
+
+// collect deletes the detached ephemeral volumes of the cluster older than the grace period.
+func (c *ephemeralVolumeCollector) collect() {
+	metadata := map[string]string{ephemeralVolumeKey: "true"}
+	if c.clusterID != "" {
+		metadata[cinderCSIClusterIDKey] = c.clusterID
+	}
+
+	for name, cloud := range c.clouds {
+		vols, err := cloud.GetVolumesByMetadata(metadata)
+		if err != nil {
+			klog.Warningf("Failed to list the ephemeral volumes of cloud %q: %v", name, err)
+			continue
+		}
+		for i := range vols {
+			vol := &vols[i]
+			if vol.Status != openstack.VolumeAvailableStatus || len(vol.Attachments) > 0 ||
+				time.Since(vol.CreatedAt) < ephemeralVolumeGCGracePeriod {
+				continue
+			}
+			if err := cloud.DeleteVolume(vol.ID); err != nil && !cpoerrors.IsNotFound(err) {
+				klog.Warningf("Failed to delete orphaned ephemeral volume %s (%s): %v", vol.Name, vol.ID, err)
+				continue
+			}
+			klog.Infof("Deleted orphaned ephemeral volume %s (%s) of pod %s/%s", vol.Name, vol.ID, vol.Metadata[sharedcsi.PvcNamespaceKey], vol.Metadata[sharedcsi.PodNameKey])
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
+	"k8s.io/cloud-provider-openstack/pkg/util/mount"
+)
+
+func TestEphemeralVolumeSize(t *testing.T) {
+	testCases := []struct {
+		capacity    string
+		expected    int
+		expectedErr bool
+	}{
+		{capacity: "", expected: 1},
+		{capacity: "2Gi", expected: 2},
+		{capacity: "1500Mi", expected: 2},
+		{capacity: "0", expectedErr: true},
+		{capacity: "big", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		size, err := ephemeralVolumeSize(tc.capacity)
+		if tc.expectedErr {
+			assert.Error(t, err, tc.capacity)
+			continue
+		}
+		assert.NoError(t, err, tc.capacity)
+		assert.Equal(t, tc.expected, size, tc.capacity)
+	}
+}
+
+func TestCheckEphemeralVolumeQuota(t *testing.T) {
+	unlimited := quotasets.QuotaUsage{Limit: -1}
+
+	testCases := []struct {
+		name        string
+		quota       quotasets.QuotaUsageSet
+		expectedErr bool
+	}{
+		{
+			name:  "unlimited",
+			quota: quotasets.QuotaUsageSet{Volumes: unlimited, Gigabytes: unlimited, PerVolumeGigabytes: unlimited},
+		},
+		{
+			name:        "per volume quota exceeded",
+			quota:       quotasets.QuotaUsageSet{Volumes: unlimited, Gigabytes: unlimited, PerVolumeGigabytes: quotasets.QuotaUsage{Limit: 1}},
+			expectedErr: true,
+		},
+		{
+			name:        "volumes quota exceeded",
+			quota:       quotasets.QuotaUsageSet{Volumes: quotasets.QuotaUsage{Limit: 10, InUse: 9, Reserved: 1}, Gigabytes: unlimited, PerVolumeGigabytes: unlimited},
+			expectedErr: true,
+		},
+		{
+			name:        "gigabytes quota exceeded",
+			quota:       quotasets.QuotaUsageSet{Volumes: unlimited, Gigabytes: quotasets.QuotaUsage{Limit: 100, InUse: 99}, PerVolumeGigabytes: unlimited},
+			expectedErr: true,
+		},
+		{
+			name:  "within the quotas",
+			quota: quotasets.QuotaUsageSet{Volumes: quotasets.QuotaUsage{Limit: 10, InUse: 8}, Gigabytes: quotasets.QuotaUsage{Limit: 100, InUse: 98}, PerVolumeGigabytes: unlimited},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkEphemeralVolumeQuota(&tc.quota, 2)
+			if tc.expectedErr {
+				assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEphemeralVolumeType(t *testing.T) {
+	_, err := newEphemeralVolumes(nil, FakeCluster, EphemeralVolumeOpts{MaxCapacity: "big"})
+	assert.Error(t, err)
+
+	limited, err := newEphemeralVolumes(nil, FakeCluster, EphemeralVolumeOpts{MaxCapacity: "10Gi", Types: []string{"standard", " fast"}})
+	assert.NoError(t, err)
+	unlimited, err := newEphemeralVolumes(nil, FakeCluster, EphemeralVolumeOpts{})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		ephemeral   *ephemeralVolumes
+		sizeGB      int
+		volType     string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "unlimited", ephemeral: unlimited, sizeGB: 100, volType: "any", expected: "any"},
+		{name: "default type", ephemeral: limited, sizeGB: 10, expected: "standard"},
+		{name: "allowed type", ephemeral: limited, sizeGB: 1, volType: "fast", expected: "fast"},
+		{name: "type not allowed", ephemeral: limited, sizeGB: 1, volType: "premium", expectedErr: true},
+		{name: "capacity exceeded", ephemeral: limited, sizeGB: 11, volType: "fast", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volType, err := tc.ephemeral.volumeType(tc.sizeGB, tc.volType)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, volType)
+		})
+	}
+}
+
+func TestEphemeralVolumeCollector(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("GetVolumesByMetadata", map[string]string{ephemeralVolumeKey: "true", cinderCSIClusterIDKey: FakeCluster}).Return([]volumes.Volume{
+		// Left behind by a deleted node
+		{ID: "orphaned", Status: openstack.VolumeAvailableStatus, CreatedAt: old},
+		// Being attached by its node
+		{ID: "new", Status: openstack.VolumeAvailableStatus, CreatedAt: time.Now()},
+		{ID: "attached", Status: openstack.VolumeInUseStatus, CreatedAt: old, Attachments: []volumes.Attachment{{ServerID: FakeNodeID}}},
+		{ID: "deleted", Status: openstack.VolumeAvailableStatus, CreatedAt: old},
+	}, nil)
+	cloud.On("DeleteVolume", "orphaned").Return(nil)
+	cloud.On("DeleteVolume", "deleted").Return(gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusNotFound})
+
+	newEphemeralVolumeCollector(map[string]openstack.IOpenStack{"": cloud}, FakeCluster, time.Minute).collect()
+	cloud.AssertCalled(t, "DeleteVolume", "orphaned")
+	cloud.AssertNotCalled(t, "DeleteVolume", "new")
+	cloud.AssertNotCalled(t, "DeleteVolume", "attached")
+	cloud.AssertNumberOfCalls(t, "DeleteVolume", 2)
+}
+
+func TestNodePublishUnpublishEphemeral(t *testing.T) {
+	const (
+		volumeID = "csi-5a2b1c"
+		volName  = "ephemeral-csi-5a2b1c"
+		volID    = "ephemeral-vol-id"
+	)
+
+	mmock := new(mount.MountMock)
+	metamock := new(metadata.MetadataMock)
+	cloud := new(openstack.OpenStackMock)
+
+	d := NewDriver(&DriverOpts{Endpoint: FakeEndpoint, ClusterID: FakeCluster})
+	ns := NewNodeServer(d, mmock, metamock, openstack.BlockStorageOpts{}, map[string]string{})
+	ns.ephemeral = &ephemeralVolumes{cloud: cloud, clusterID: FakeCluster}
+
+	properties := map[string]string{
+		ephemeralVolumeKey:        "true",
+		cinderCSIClusterIDKey:     FakeCluster,
+		sharedcsi.PvcNamespaceKey: "default",
+		sharedcsi.PodNameKey:      "app",
+	}
+
+	metamock.On("GetInstanceID").Return(FakeNodeID, nil)
+	metamock.On("GetAvailabilityZone").Return(FakeAvailability, nil)
+	mmock.On("IsLikelyNotMountPointAttach", FakeTargetPath).Return(true, nil)
+	mmock.On("GetDevicePath", volID).Return(FakeDevicePath, nil)
+	mmock.On("UnmountPath", FakeTargetPath).Return(nil)
+	cloud.On("GetVolumesByName", volName).Return([]volumes.Volume{}, nil).Once()
+	cloud.On("CreateVolume", volName, 2, "fast", FakeAvailability, "", "", "", properties).Return(&volumes.Volume{ID: volID, Name: volName}, nil)
+	cloud.On("WaitVolumeTargetStatus", volID, []string{openstack.VolumeAvailableStatus}).Return(nil)
+	cloud.On("AttachVolume", FakeNodeID, volID).Return(volID, nil)
+	cloud.On("WaitDiskAttached", FakeNodeID, volID).Return(nil)
+
+	_, err := ns.NodePublishVolume(FakeCtx, &csi.NodePublishVolumeRequest{
+		VolumeId:   volumeID,
+		TargetPath: FakeTargetPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+		VolumeContext: map[string]string{
+			sharedcsi.VolEphemeralKey: "true",
+			sharedcsi.PodNamespaceKey: "default",
+			sharedcsi.PodNameKey:      "app",
+			ephemeralCapacityKey:      "2Gi",
+			ephemeralTypeKey:          "fast",
+		},
+	})
+	assert.NoError(t, err)
+
+	// The volume is deleted once unmounted
+	cloud.On("GetVolumesByName", volName).Return([]volumes.Volume{{
+		ID:          volID,
+		Name:        volName,
+		Metadata:    properties,
+		Attachments: []volumes.Attachment{{ServerID: FakeNodeID}},
+	}}, nil).Once()
+	cloud.On("DetachVolume", FakeNodeID, volID).Return(nil)
+	cloud.On("WaitDiskDetached", FakeNodeID, volID).Return(nil)
+	cloud.On("DeleteVolume", volID).Return(nil)
+
+	_, err = ns.NodeUnpublishVolume(FakeCtx, &csi.NodeUnpublishVolumeRequest{VolumeId: volumeID, TargetPath: FakeTargetPath})
+	assert.NoError(t, err)
+	cloud.AssertCalled(t, "DeleteVolume", volID)
+
+	// The volumes not created for an ephemeral volume are never deleted
+	cloud.On("GetVolumesByName", volName).Return([]volumes.Volume{{ID: "foreign-vol-id", Name: volName}}, nil).Once()
+	_, err = ns.NodeUnpublishVolume(FakeCtx, &csi.NodeUnpublishVolumeRequest{VolumeId: volumeID, TargetPath: FakeTargetPath})
+	assert.NoError(t, err)
+	cloud.AssertNotCalled(t, "DeleteVolume", "foreign-vol-id")
+
+	// The persistent volumes are not looked up
+	_, err = ns.NodeUnpublishVolume(FakeCtx, &csi.NodeUnpublishVolumeRequest{VolumeId: "261a8b81-3660-43e5-bab8-6470b65ee4e8", TargetPath: FakeTargetPath})
+	assert.NoError(t, err)
+	cloud.AssertNumberOfCalls(t, "GetVolumesByName", 3)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"k8s.io/cloud-provider-openstack/pkg/util/blockdevice"
+	"k8s.io/klog/v2"
+)
+
+// virtioBlkMaxVolumes is the default attach limit of the nodes whose disks are attached with virtio-blk, when
+// node-volume-attach-limit-virtio-blk is set. libvirt plugs each of them in a slot of the 32 of the PCI bus of the
+// instance, some of which are used by the other devices.
+const virtioBlkMaxVolumes = 24
+
+// usesVirtioBlk checks whether the disks of the node are attached with virtio-blk, overridden by the tests.
+var usesVirtioBlk = blockdevice.UsesVirtioBlk
+
+// flavorAttachLimit is the attach limit of the nodes whose flavor name matches a pattern.
+type flavorAttachLimit struct {
+	pattern string
+	limit   int64
+}
+
+// parseFlavorAttachLimits parses the attach limits by flavor formatted as <flavor>=<limit>, where the flavor is a
+// shell pattern, e.g. gpu.*. The invalid ones are ignored.
+func parseFlavorAttachLimits(values []string) []flavorAttachLimit {
+	var limits []flavorAttachLimit
+	for _, value := range values {
+		pattern, limitValue, found := cutLast(value, "=")
+		pattern = strings.TrimSpace(pattern)
+		limit, err := strconv.ParseInt(strings.TrimSpace(limitValue), 10, 64)
+		if _, patternErr := path.Match(pattern, ""); !found || pattern == "" || err != nil || patternErr != nil || limit <= 0 || limit > maxVolumesPerNode {
+			klog.Warningf("Ignoring the invalid node-volume-attach-limit-flavor %q, expected <flavor>=<limit> with a limit between 1 and %d", value, maxVolumesPerNode)
+			continue
+		}
+		limits = append(limits, flavorAttachLimit{pattern: pattern, limit: limit})
+	}
+	return limits
+}
+
+// cutLast slices s around the last instance of sep, as the flavor names may contain it.
+func cutLast(s, sep string) (string, string, bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// nodeAttachLimit returns the maximum number of volumes that can be attached to the node: the limit of the first
+// pattern matching its flavor, else node-volume-attach-limit, else the limit of the bus its disks are attached with
+// when node-volume-attach-limit-virtio-blk is set.
+func (ns *nodeServer) nodeAttachLimit() int64 {
+	if len(ns.flavorAttachLimits) > 0 {
+		flavor, err := ns.Metadata.GetInstanceType()
+		if err != nil {
+			klog.Warningf("Unable to retrieve the flavor of the node, the attach limits by flavor are ignored: %v", err)
+		}
+		for _, l := range ns.flavorAttachLimits {
+			if ok, _ := path.Match(l.pattern, flavor); ok && flavor != "" {
+				klog.V(4).Infof("Using the attach limit %d of flavor %s", l.limit, flavor)
+				return l.limit
+			}
+		}
+	}
+
+	if ns.Opts.NodeVolumeAttachLimit > 0 {
+		return ns.Opts.NodeVolumeAttachLimit
+	}
+	if ns.Opts.NodeVolumeAttachLimitVirtioBlk && usesVirtioBlk() {
+		klog.V(2).Infof("The disks of the node are attached with virtio-blk, using the attach limit %d", virtioBlkMaxVolumes)
+		return virtioBlkMaxVolumes
+	}
+	return maxVolumesPerNode
+}
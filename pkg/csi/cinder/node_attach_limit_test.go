@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
+)
+
+func TestParseFlavorAttachLimits(t *testing.T) {
+	limits := parseFlavorAttachLimits([]string{
+		"m1.large=24",
+		" gpu.* = 8 ",
+		"flavor=with=equal=16",
+		"no-limit",
+		"=10",
+		"m1.small=0",
+		"m1.xlarge=300",
+		"m1.tiny=abc",
+		"[invalid=10",
+	})
+	assert.Equal(t, []flavorAttachLimit{
+		{pattern: "m1.large", limit: 24},
+		{pattern: "gpu.*", limit: 8},
+		{pattern: "flavor=with=equal", limit: 16},
+	}, limits)
+}
+
+func TestNodeAttachLimit(t *testing.T) {
+	virtioBlk := false
+	oldUsesVirtioBlk := usesVirtioBlk
+	usesVirtioBlk = func() bool { return virtioBlk }
+	defer func() { usesVirtioBlk = oldUsesVirtioBlk }()
+
+	limits := parseFlavorAttachLimits([]string{"m1.large=24", "gpu.*=8", "*=32"})
+
+	tests := []struct {
+		name      string
+		flavor    string
+		flavorErr error
+		limits    []flavorAttachLimit
+		opts      openstack.BlockStorageOpts
+		virtioBlk bool
+		expected  int64
+	}{
+		{name: "default", expected: maxVolumesPerNode},
+		{name: "virtio-blk", opts: openstack.BlockStorageOpts{NodeVolumeAttachLimitVirtioBlk: true}, virtioBlk: true, expected: virtioBlkMaxVolumes},
+		{name: "virtio-blk not enabled", virtioBlk: true, expected: maxVolumesPerNode},
+		{name: "configured", opts: openstack.BlockStorageOpts{NodeVolumeAttachLimit: 64, NodeVolumeAttachLimitVirtioBlk: true}, virtioBlk: true, expected: 64},
+		{name: "flavor", flavor: "m1.large", limits: limits, opts: openstack.BlockStorageOpts{NodeVolumeAttachLimit: 64}, expected: 24},
+		{name: "flavor pattern", flavor: "gpu.xlarge", limits: limits, expected: 8},
+		{name: "first match", flavor: "m1.small", limits: limits, expected: 32},
+		{name: "no match", flavor: "m1.small", limits: limits[:2], opts: openstack.BlockStorageOpts{NodeVolumeAttachLimitVirtioBlk: true}, virtioBlk: true, expected: virtioBlkMaxVolumes},
+		{name: "unknown flavor", flavorErr: errors.New("metadata unavailable"), limits: limits, opts: openstack.BlockStorageOpts{NodeVolumeAttachLimit: 64}, expected: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := new(metadata.MetadataMock)
+			md.On("GetInstanceType").Return(tt.flavor, tt.flavorErr)
+			virtioBlk = tt.virtioBlk
+
+			ns := &nodeServer{Metadata: md, Opts: tt.opts, flavorAttachLimits: tt.limits}
+			assert.Equal(t, tt.expected, ns.nodeAttachLimit())
+			if len(tt.limits) == 0 {
+				md.AssertNotCalled(t, "GetInstanceType")
+			}
+		})
+	}
+}
@@ -18,9 +18,11 @@ package cinder
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -44,6 +46,13 @@ type nodeServer struct {
 	Metadata   metadata.IMetadata
 	Opts       openstack.BlockStorageOpts
 	Topologies map[string]string
+
+	// flavorAttachLimits are the attach limits by flavor of node-volume-attach-limit-flavor
+	flavorAttachLimits []flavorAttachLimit
+	// statsCache is nil when the volume stats are not cached
+	statsCache *volumeStatsCache
+	// ephemeral is nil when the CSI inline ephemeral volumes are not enabled on the node
+	ephemeral *ephemeralVolumes
 }
 
 func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
@@ -63,11 +72,16 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	if volumeCapability == nil {
 		return nil, status.Error(codes.InvalidArgument, "NodePublishVolume Volume Capability must be provided")
 	}
+	if isMultiNodeWriter(volumeCapability) && volumeCapability.GetBlock() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodePublishVolume access mode %s is only supported for block volumes", volumeCapability.GetAccessMode().GetMode())
+	}
 
 	ephemeralVolume := req.GetVolumeContext()[sharedcsi.VolEphemeralKey] == "true"
 	if ephemeralVolume {
-		// See https://github.com/kubernetes/cloud-provider-openstack/issues/2599
-		return nil, status.Error(codes.Unimplemented, "CSI inline ephemeral volumes support is removed in 1.31 release.")
+		if ns.ephemeral == nil {
+			return nil, status.Error(codes.Unimplemented, "CSI inline ephemeral volumes are not enabled on the node, see the --node-ephemeral-volumes flag")
+		}
+		return ns.nodePublishEphemeral(req)
 	}
 
 	// In case of ephemeral volume staging path not provided
@@ -108,9 +122,41 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		}
 	}
 
+	if req.GetVolumeContext()[projectQuotasKey] == "true" {
+		if err := ns.publishProjectQuotas(ctx, req, source); err != nil {
+			return nil, err
+		}
+	}
+
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// publishProjectQuotas applies the project quotas requested by the pod the volume is published for to the subdirectories
+// of the staged filesystem.
+func (ns *nodeServer) publishProjectQuotas(ctx context.Context, req *csi.NodePublishVolumeRequest, stagingTarget string) error {
+	annotations, err := sharedcsi.GetPodAnnotations(ctx, ns.Driver.podClient, req.GetVolumeContext())
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the project quotas of volume %q: %v", req.GetVolumeId(), err)
+	}
+	value, ok := annotations[ProjectQuotasAnnotation]
+	if !ok {
+		return nil
+	}
+
+	quotas, err := parseProjectQuotas(value)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid %s pod annotation: %v", ProjectQuotasAnnotation, err)
+	}
+
+	if err := applyProjectQuotas(ns.Mount.Mounter().Exec, stagingTarget, quotas); err != nil {
+		if errors.Is(err, errProjectQuotaSet) {
+			return status.Errorf(codes.FailedPrecondition, "Failed to apply the project quotas of volume %q: %v", req.GetVolumeId(), err)
+		}
+		return status.Errorf(codes.Internal, "Failed to apply the project quotas of volume %q: %v", req.GetVolumeId(), err)
+	}
+	return nil
+}
+
 func nodePublishVolumeForBlock(req *csi.NodePublishVolumeRequest, ns *nodeServer, mountOptions []string) (*csi.NodePublishVolumeResponse, error) {
 	klog.V(4).Infof("NodePublishVolumeBlock: called with args %+v", protosanitizer.StripSecrets(*req))
 
@@ -166,6 +212,16 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Errorf(codes.Internal, "Unmount of targetpath %s failed with error %v", targetPath, err)
 	}
 
+	if ns.ephemeral != nil {
+		if err := ns.nodeUnpublishEphemeral(volumeID); err != nil {
+			return nil, err
+		}
+	}
+
+	if ns.statsCache != nil {
+		ns.statsCache.remove(targetPath)
+	}
+
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
@@ -187,6 +243,9 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	if volumeCapability == nil {
 		return nil, status.Error(codes.InvalidArgument, "NodeStageVolume Volume Capability must be provided")
 	}
+	if isMultiNodeWriter(volumeCapability) && volumeCapability.GetBlock() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "NodeStageVolume access mode %s is only supported for block volumes", volumeCapability.GetAccessMode().GetMode())
+	}
 
 	m := ns.Mount
 	// Do not trust the path provided by cinder, get the real path on node
@@ -206,23 +265,47 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	// Additional mount options requested via the PV annotation
+	extraOptions := getMountOptionsFromAnnotations(sharedcsi.GetPVAnnotations(ns.Driver.pvLister, driverName, volumeID))
+
+	// set default fstype is ext4
+	fsType := "ext4"
+	if mnt := volumeCapability.GetMount(); mnt != nil && mnt.FsType != "" {
+		fsType = mnt.FsType
+	}
+
+	var options []string
+	if mnt := volumeCapability.GetMount(); mnt != nil {
+		mountFlags := mnt.GetMountFlags()
+		options = append(options, collectMountOptions(fsType, mountFlags)...)
+	}
+	options = append(options, extraOptions...)
+	if volumeContext[projectQuotasKey] == "true" {
+		options = append(options, projectQuotaMountOption)
+	}
+
 	// Volume Mount
 	if notMnt {
-		// set default fstype is ext4
-		fsType := "ext4"
-		var options []string
-		if mnt := volumeCapability.GetMount(); mnt != nil {
-			if mnt.FsType != "" {
-				fsType = mnt.FsType
+		var formatOptions []string
+		if volumeContext[projectQuotasKey] == "true" {
+			if fsType == "ext4" {
+				formatOptions, err = ns.prepareExt4ProjectQuotas(devicePath)
+				if err != nil {
+					return nil, status.Error(codes.Internal, err.Error())
+				}
 			}
-			mountFlags := mnt.GetMountFlags()
-			options = append(options, collectMountOptions(fsType, mountFlags)...)
 		}
+
 		// Mount
-		err = ns.formatAndMountRetry(devicePath, stagingTarget, fsType, options)
+		err = ns.formatAndMountRetry(devicePath, stagingTarget, fsType, options, formatOptions)
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
+	} else if ns.Driver.pvLister != nil {
+		// Already staged, apply the mount options requested via the PV annotation with a remount
+		if err := remountIfNeeded(ns.Mount.Mounter(), devicePath, stagingTarget, fsType, options, extraOptions); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to remount volume %q with options %v: %v", volumeID, extraOptions, err)
+		}
 	}
 
 	if required, ok := volumeContext[ResizeRequired]; ok && strings.EqualFold(required, "true") {
@@ -245,11 +328,27 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	return &csi.NodeStageVolumeResponse{}, nil
 }
 
+// prepareExt4ProjectQuotas returns the format options of a new ext4 filesystem with the project quotas, and enables
+// them on an existing one.
+func (ns *nodeServer) prepareExt4ProjectQuotas(devicePath string) ([]string, error) {
+	format, err := ns.Mount.Mounter().GetDiskFormat(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		return []string{"-O", "quota,project"}, nil
+	}
+	if format == "ext4" {
+		return nil, enableExt4ProjectQuotas(ns.Mount.Mounter().Exec, devicePath)
+	}
+	return nil, nil
+}
+
 // formatAndMountRetry attempts to format and mount a device at the given path.
 // If the initial mount fails, it rescans the device and retries the mount operation.
-func (ns *nodeServer) formatAndMountRetry(devicePath, stagingTarget, fsType string, options []string) error {
+func (ns *nodeServer) formatAndMountRetry(devicePath, stagingTarget, fsType string, options []string, formatOptions []string) error {
 	m := ns.Mount
-	err := m.Mounter().FormatAndMount(devicePath, stagingTarget, fsType, options)
+	err := m.Mounter().FormatAndMountSensitiveWithFormatOptions(devicePath, stagingTarget, fsType, options, nil, formatOptions)
 	if err != nil {
 		klog.Infof("Initial format and mount failed: %v. Attempting rescan.", err)
 		// Attempting rescan if the initial mount fails
@@ -259,11 +358,53 @@ func (ns *nodeServer) formatAndMountRetry(devicePath, stagingTarget, fsType stri
 			return err
 		}
 		klog.Infof("Rescan succeeded, retrying format and mount")
-		err = m.Mounter().FormatAndMount(devicePath, stagingTarget, fsType, options)
+		err = m.Mounter().FormatAndMountSensitiveWithFormatOptions(devicePath, stagingTarget, fsType, options, nil, formatOptions)
 	}
 	return err
 }
 
+// remountIfNeeded remounts the staging target and the publish targets bind
+// mounted from it with the given options, unless the extra options are
+// already applied and no atime option has to be reverted. A remount keeps the
+// atime options which are not given, so the ones no longer requested are
+// reverted to the default.
+func remountIfNeeded(mounter mountutil.Interface, devicePath, stagingTarget, fsType string, options []string, extraOptions []string) error {
+	mountPoints, err := mounter.List()
+	if err != nil {
+		return err
+	}
+	currentOptions := make(map[string][]string, len(mountPoints))
+	for _, mp := range mountPoints {
+		currentOptions[mp.Path] = mp.Opts
+	}
+
+	publishTargets, err := mounter.GetMountRefs(stagingTarget)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range append([]string{stagingTarget}, publishTargets...) {
+		current := currentOptions[target]
+		reverted := revertedMountOptions(current, options)
+		if hasMountOptions(current, extraOptions) && len(reverted) == 0 {
+			klog.V(4).Infof("Mount options %v are already applied to %s", extraOptions, target)
+			continue
+		}
+
+		remountOptions := append(append([]string{"remount"}, options...), reverted...)
+		// A remount without ro makes a read-only publish target writable.
+		if slices.Contains(current, "ro") && !slices.Contains(remountOptions, "ro") {
+			remountOptions = append(remountOptions, "ro")
+		}
+		klog.Infof("Remounting %s with options %v", target, remountOptions)
+		if err := mounter.Mount(devicePath, target, fsType, remountOptions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
 	klog.V(4).Infof("NodeUnstageVolume: called with args %+v", protosanitizer.StripSecrets(*req))
 
@@ -293,7 +434,7 @@ func (ns *nodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 
 	nodeInfo := &csi.NodeGetInfoResponse{
 		NodeId:            nodeID,
-		MaxVolumesPerNode: ns.Opts.NodeVolumeAttachLimit,
+		MaxVolumesPerNode: ns.nodeAttachLimit(),
 	}
 
 	if !ns.Driver.withTopology {
@@ -322,7 +463,7 @@ func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 	}, nil
 }
 
-func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
 	klog.V(4).Infof("NodeGetVolumeStats: called with args %+v", protosanitizer.StripSecrets(*req))
 
 	volumeID := req.GetVolumeId()
@@ -342,9 +483,26 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 	if !exists {
 		return nil, status.Errorf(codes.NotFound, "target: %s not found", volumePath)
 	}
-	stats, err := ns.Mount.GetDeviceStats(volumePath)
+	stats, err := ns.getDeviceStats(ctx, volumePath)
+	if err != nil && ctx.Err() != nil {
+		// The node is too slow to answer in time, which doesn't tell anything about the volume
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get stats by path: %v", err)
+		// The volume is reported abnormal rather than failing the call, so that the volume health monitoring can
+		// detect the devices which can't be read anymore.
+		klog.Errorf("Failed to get stats of volume %s at %s: %v", volumeID, volumePath, err)
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  fmt.Sprintf("failed to get stats by path: %v", err),
+			},
+		}, nil
+	}
+
+	condition := &csi.VolumeCondition{
+		Abnormal: false,
+		Message:  "volume is healthy",
 	}
 
 	if stats.Block {
@@ -355,6 +513,7 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 					Unit:  csi.VolumeUsage_BYTES,
 				},
 			},
+			VolumeCondition: condition,
 		}, nil
 	}
 
@@ -363,9 +522,18 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 			{Total: stats.TotalBytes, Available: stats.AvailableBytes, Used: stats.UsedBytes, Unit: csi.VolumeUsage_BYTES},
 			{Total: stats.TotalInodes, Available: stats.AvailableInodes, Used: stats.UsedInodes, Unit: csi.VolumeUsage_INODES},
 		},
+		VolumeCondition: condition,
 	}, nil
 }
 
+// getDeviceStats returns the stats of the volume path, from the cache when it's enabled.
+func (ns *nodeServer) getDeviceStats(ctx context.Context, volumePath string) (*mount.DeviceStats, error) {
+	if ns.statsCache == nil {
+		return ns.Mount.GetDeviceStats(volumePath)
+	}
+	return ns.statsCache.get(ctx, volumePath)
+}
+
 func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
 	klog.V(4).Infof("NodeExpandVolume: called with args %+v", protosanitizer.StripSecrets(*req))
 
@@ -393,7 +561,13 @@ func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 		return nil, status.Error(codes.Internal, "Unable to find Device path for volume")
 	}
 
-	if ns.Opts.RescanOnResize {
+	// the multipath devices keep their size until their paths are rescanned and the map is resized, they are
+	// rescanned unless rescan-on-resize is explicitly disabled
+	rescan := ns.Opts.RescanOnResize != nil && *ns.Opts.RescanOnResize
+	if ns.Opts.RescanOnResize == nil {
+		rescan = blockdevice.IsMultipathDevice(devicePath)
+	}
+	if rescan {
 		// comparing current volume size with the expected one
 		newSize := req.GetCapacityRange().GetRequiredBytes()
 		if err := blockdevice.RescanBlockDeviceGeometry(devicePath, volumePath, newSize); err != nil {
@@ -428,6 +602,54 @@ func getDevicePath(volumeID string, m mount.IMount) (string, error) {
 	return devicePath, nil
 }
 
+// getMountOptionsFromAnnotations parses the comma-separated mount options from
+// the PV annotations.
+func getMountOptionsFromAnnotations(annotations map[string]string) []string {
+	var options []string
+	for _, opt := range strings.Split(annotations[MountOptionsAnnotation], ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// revertibleMountOptions are the atime options a remount keeps when no atime
+// option is given, with the default option reverting them.
+var revertibleMountOptions = map[string]string{
+	"noatime":     "relatime",
+	"strictatime": "relatime",
+	"nodiratime":  "diratime",
+}
+
+// revertedMountOptions returns the options reverting the atime options of
+// current which are not in wanted.
+func revertedMountOptions(current []string, wanted []string) []string {
+	var reverted []string
+	for _, opt := range current {
+		revert, ok := revertibleMountOptions[opt]
+		if !ok || slices.Contains(reverted, revert) {
+			continue
+		}
+		// Another option of the same kind is wanted, e.g. strictatime instead of noatime.
+		if slices.ContainsFunc(wanted, func(w string) bool { return w == revert || revertibleMountOptions[w] == revert }) {
+			continue
+		}
+		reverted = append(reverted, revert)
+	}
+	return reverted
+}
+
+// hasMountOptions returns true if all wanted options are present in current.
+func hasMountOptions(current []string, wanted []string) bool {
+	for _, w := range wanted {
+		if !slices.Contains(current, w) {
+			return false
+		}
+	}
+	return true
+}
+
 func collectMountOptions(fsType string, mntFlags []string) []string {
 	var options []string
 	options = append(options, mntFlags...)
@@ -17,10 +17,13 @@ limitations under the License.
 package cinder
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
@@ -30,6 +33,8 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/cloud-provider-openstack/pkg/util/mount"
+	mountutil "k8s.io/mount-utils"
+	"k8s.io/utils/ptr"
 )
 
 var fakeNs *nodeServer
@@ -56,7 +61,7 @@ func init() {
 		}
 
 		opts := openstack.BlockStorageOpts{
-			RescanOnResize:        false,
+			RescanOnResize:        ptr.To(false),
 			NodeVolumeAttachLimit: maxVolumesPerNode,
 		}
 
@@ -165,7 +170,7 @@ func TestNodePublishVolumeEphemeral(t *testing.T) {
 
 	// Invoke NodePublishVolume
 	_, err := fakeNs.NodePublishVolume(FakeCtx, fakeReq)
-	assert.Equal(status.Error(codes.Unimplemented, "CSI inline ephemeral volumes support is removed in 1.31 release."), err)
+	assert.Equal(status.Error(codes.Unimplemented, "CSI inline ephemeral volumes are not enabled on the node, see the --node-ephemeral-volumes flag"), err)
 }
 
 // Test NodeStageVolume
@@ -357,6 +362,7 @@ func TestNodeGetVolumeStatsBlock(t *testing.T) {
 		Usage: []*csi.VolumeUsage{
 			{Total: FakeBlockDeviceStats.TotalBytes, Unit: csi.VolumeUsage_BYTES},
 		},
+		VolumeCondition: &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"},
 	}
 
 	blockRes, err := fakeNs.NodeGetVolumeStats(FakeCtx, fakeReq)
@@ -393,6 +399,7 @@ func TestNodeGetVolumeStatsFs(t *testing.T) {
 			{Total: FakeFsStats.TotalBytes, Available: FakeFsStats.AvailableBytes, Used: FakeFsStats.UsedBytes, Unit: csi.VolumeUsage_BYTES},
 			{Total: FakeFsStats.TotalInodes, Available: FakeFsStats.AvailableInodes, Used: FakeFsStats.UsedInodes, Unit: csi.VolumeUsage_INODES},
 		},
+		VolumeCondition: &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"},
 	}
 
 	fsRes, err := fakeNs.NodeGetVolumeStats(FakeCtx, fakeReq)
@@ -401,3 +408,115 @@ func TestNodeGetVolumeStatsFs(t *testing.T) {
 	assert.Equal(expectedFsRes, fsRes)
 
 }
+
+func TestNodeGetVolumeStatsAbnormal(t *testing.T) {
+	assert := assert.New(t)
+	mmock.ExpectedCalls = nil
+
+	// setup for test
+	tempDir := os.TempDir()
+	volumePath := filepath.Join(tempDir, FakeTargetPath)
+	err := os.MkdirAll(volumePath, 0750)
+	if err != nil {
+		t.Fatalf("Failed to set up volumepath: %v", err)
+	}
+	defer os.RemoveAll(volumePath)
+
+	fakeReq := &csi.NodeGetVolumeStatsRequest{
+		VolumeId:   FakeVolName,
+		VolumePath: volumePath,
+	}
+
+	mmock.On("GetDeviceStats", volumePath).Return((*mount.DeviceStats)(nil), errors.New("input/output error"))
+
+	res, err := fakeNs.NodeGetVolumeStats(FakeCtx, fakeReq)
+
+	assert.NoError(err)
+	assert.Empty(res.Usage)
+	assert.True(res.VolumeCondition.Abnormal)
+	assert.Contains(res.VolumeCondition.Message, "input/output error")
+}
+
+func TestNodeGetVolumeStatsTimeout(t *testing.T) {
+	volumePath := t.TempDir()
+	block := make(chan struct{})
+	defer close(block)
+	ns := &nodeServer{statsCache: newVolumeStatsCache(time.Minute, 0, 1, func(string) (*mount.DeviceStats, error) {
+		<-block
+		return &mount.DeviceStats{}, nil
+	})}
+	req := &csi.NodeGetVolumeStatsRequest{VolumeId: FakeVolName, VolumePath: volumePath}
+
+	// The volume isn't reported abnormal when the stats can't be collected in time
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := ns.NodeGetVolumeStats(ctx, req)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = ns.NodeGetVolumeStats(ctx, req)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestGetMountOptionsFromAnnotations(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(getMountOptionsFromAnnotations(nil))
+	assert.Nil(getMountOptionsFromAnnotations(map[string]string{"foo": "bar"}))
+	assert.Equal([]string{"noatime", "nodiratime"}, getMountOptionsFromAnnotations(map[string]string{
+		MountOptionsAnnotation: " noatime, ,nodiratime",
+	}))
+}
+
+func TestHasMountOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	current := []string{"rw", "relatime", "noatime"}
+	assert.True(hasMountOptions(current, []string{"noatime"}))
+	assert.True(hasMountOptions(current, nil))
+	assert.False(hasMountOptions(current, []string{"noatime", "nodiratime"}))
+}
+
+func TestRevertedMountOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	current := []string{"rw", "noatime", "nodiratime"}
+	assert.Equal([]string{"relatime", "diratime"}, revertedMountOptions(current, nil))
+	assert.Nil(revertedMountOptions(current, []string{"noatime", "nodiratime"}))
+	assert.Equal([]string{"diratime"}, revertedMountOptions(current, []string{"strictatime"}))
+	assert.Nil(revertedMountOptions([]string{"rw", "relatime"}, nil))
+}
+
+func TestRemountIfNeeded(t *testing.T) {
+	assert := assert.New(t)
+
+	mounter := mountutil.NewFakeMounter([]mountutil.MountPoint{
+		{Device: "/dev/vdb", Path: "/staging", Type: "ext4", Opts: []string{"rw", "noatime"}},
+		{Device: "/dev/vdb", Path: "/publish-rw", Type: "ext4", Opts: []string{"rw", "noatime"}},
+		{Device: "/dev/vdb", Path: "/publish-ro", Type: "ext4", Opts: []string{"ro", "noatime"}},
+		{Device: "/dev/vdc", Path: "/other", Type: "ext4", Opts: []string{"rw", "noatime"}},
+	})
+
+	// The noatime option is no longer requested, it's reverted on the staging and the publish targets.
+	assert.NoError(remountIfNeeded(mounter, "/dev/vdb", "/staging", "ext4", []string{"nodev"}, nil))
+	mountPoints, err := mounter.List()
+	assert.NoError(err)
+	remounts := map[string][]string{}
+	for _, mp := range mountPoints[4:] {
+		remounts[mp.Path] = mp.Opts
+	}
+	assert.Equal(map[string][]string{
+		"/staging":    {"remount", "nodev", "relatime"},
+		"/publish-rw": {"remount", "nodev", "relatime"},
+		"/publish-ro": {"remount", "nodev", "relatime", "ro"},
+	}, remounts)
+
+	// The options are already applied.
+	mounter = mountutil.NewFakeMounter([]mountutil.MountPoint{
+		{Device: "/dev/vdb", Path: "/staging", Type: "ext4", Opts: []string{"rw", "noatime"}},
+		{Device: "/dev/vdb", Path: "/publish", Type: "ext4", Opts: []string{"rw", "noatime"}},
+	})
+	assert.NoError(remountIfNeeded(mounter, "/dev/vdb", "/staging", "ext4", []string{"noatime"}, []string{"noatime"}))
+	assert.Empty(mounter.GetLog())
+}
@@ -24,13 +24,17 @@ import (
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/backups"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
 	"github.com/spf13/pflag"
 	gcfg "gopkg.in/gcfg.v1"
 	"k8s.io/cloud-provider-openstack/pkg/client"
 	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	"k8s.io/cloud-provider-openstack/pkg/util"
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
@@ -56,6 +60,7 @@ type IOpenStack interface {
 	GetAttachmentDiskPath(instanceID, volumeID string) (string, error)
 	GetVolume(volumeID string) (*volumes.Volume, error)
 	GetVolumesByName(name string) ([]volumes.Volume, error)
+	GetVolumesByMetadata(metadata map[string]string) ([]volumes.Volume, error)
 	GetVolumeByName(name string) (*volumes.Volume, error)
 	CreateSnapshot(name, volID string, tags map[string]string) (*snapshots.Snapshot, error)
 	ListSnapshots(filters map[string]string) ([]snapshots.Snapshot, string, error)
@@ -70,10 +75,17 @@ type IOpenStack interface {
 	WaitBackupReady(backupID string, snapshotSize int, backupMaxDurationSecondsPerGB int) (string, error)
 	GetInstanceByID(instanceID string) (*servers.Server, error)
 	ExpandVolume(volumeID string, status string, size int) error
+	UpdateVolumeMetadata(volumeID string, metadata map[string]string) error
+	MigrateVolume(volumeID string) error
+	GetVolumeMigrationStatus(volumeID string) (string, error)
+	GetVolumeQuotaUsage(projectID string) (*quotasets.QuotaUsageSet, error)
 	GetMaxVolLimit() int64
 	GetMetadataOpts() metadata.Opts
 	GetBlockStorageOpts() BlockStorageOpts
 	ResolveVolumeListToUUIDs(volumes string) (string, error)
+	ListVolumeTypes() ([]volumetypes.VolumeType, error)
+	GetVolumeTypeEncryption(volumeTypeID string) (*volumetypes.GetEncryptionType, error)
+	GetQoSSpecs(qosSpecsID string) (*qos.QoS, error)
 }
 
 type OpenStack struct {
@@ -85,10 +97,22 @@ type OpenStack struct {
 }
 
 type BlockStorageOpts struct {
-	NodeVolumeAttachLimit    int64 `gcfg:"node-volume-attach-limit"`
-	RescanOnResize           bool  `gcfg:"rescan-on-resize"`
-	IgnoreVolumeAZ           bool  `gcfg:"ignore-volume-az"`
-	IgnoreVolumeMicroversion bool  `gcfg:"ignore-volume-microversion"`
+	NodeVolumeAttachLimit      int64           `gcfg:"node-volume-attach-limit"`
+	RescanOnResize             *bool           `gcfg:"rescan-on-resize"`
+	IgnoreVolumeAZ             bool            `gcfg:"ignore-volume-az"`
+	IgnoreVolumeMicroversion   bool            `gcfg:"ignore-volume-microversion"`
+	NodeVolumeStatsCacheTTL    util.MyDuration `gcfg:"node-volume-stats-cache-ttl"`
+	NodeVolumeStatsBudget      util.MyDuration `gcfg:"node-volume-stats-budget"`
+	NodeVolumeStatsConcurrency int             `gcfg:"node-volume-stats-concurrency"`
+	DefaultSnapshotType        string          `gcfg:"default-snapshot-type"`
+	// FlavorAttachLimits are the attach limits of the nodes by flavor, formatted as <flavor>=<limit>
+	FlavorAttachLimits []string `gcfg:"node-volume-attach-limit-flavor"`
+	// NodeVolumeAttachLimitVirtioBlk limits the nodes whose disks are attached with virtio-blk to 24 volumes by
+	// default
+	NodeVolumeAttachLimitVirtioBlk bool `gcfg:"node-volume-attach-limit-virtio-blk"`
+	// AdoptVolumes validates the volumes without the cluster metadata before their first attachment and tags them,
+	// and refuses to attach the volumes of the other clusters
+	AdoptVolumes bool `gcfg:"adopt-volumes"`
 }
 
 type Config struct {
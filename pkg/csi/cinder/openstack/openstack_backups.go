@@ -49,6 +49,7 @@ func (os *OpenStack) CreateBackup(name, volID, snapshotID, availabilityZone stri
 	if err != nil {
 		return &backups.Backup{}, err
 	}
+	blockstorageServiceClient.MoreHeaders = os.blockstorage.MoreHeaders
 
 	force := false
 	// if no flag given, then force will be false by default
@@ -20,8 +20,11 @@ import (
 	"fmt"
 
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/backups"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
 	"github.com/stretchr/testify/mock"
 	"k8s.io/cloud-provider-openstack/pkg/util/errors"
@@ -237,6 +240,29 @@ func (_m *OpenStackMock) GetVolumesByName(name string) ([]volumes.Volume, error)
 	return r0, r1
 }
 
+// GetVolumesByMetadata provides a mock function with given fields: metadata
+func (_m *OpenStackMock) GetVolumesByMetadata(metadata map[string]string) ([]volumes.Volume, error) {
+	ret := _m.Called(metadata)
+
+	var r0 []volumes.Volume
+	if rf, ok := ret.Get(0).(func(map[string]string) []volumes.Volume); ok {
+		r0 = rf(metadata)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volumes.Volume)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(map[string]string) error); ok {
+		r1 = rf(metadata)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetVolumeByName provides a mock function with given fields: name
 func (_m *OpenStackMock) GetVolumeByName(name string) (*volumes.Volume, error) {
 	vols, err := _m.GetVolumesByName(name)
@@ -495,7 +521,7 @@ func (_m *OpenStackMock) BackupsAreEnabled() (bool, error) {
 }
 
 func (_m *OpenStackMock) GetInstanceByID(instanceID string) (*servers.Server, error) {
-	return nil, nil
+	return &servers.Server{ID: instanceID}, nil
 }
 
 // ExpandVolume provides a mock function with given fields: instanceID, volumeID
@@ -512,6 +538,78 @@ func (_m *OpenStackMock) ExpandVolume(volumeID string, status string, size int)
 	return r0
 }
 
+// UpdateVolumeMetadata provides a mock function with given fields: volumeID, metadata
+func (_m *OpenStackMock) UpdateVolumeMetadata(volumeID string, metadata map[string]string) error {
+	ret := _m.Called(volumeID, metadata)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, map[string]string) error); ok {
+		r0 = rf(volumeID, metadata)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MigrateVolume provides a mock function with given fields: volumeID
+func (_m *OpenStackMock) MigrateVolume(volumeID string) error {
+	ret := _m.Called(volumeID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(volumeID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetVolumeMigrationStatus provides a mock function with given fields: volumeID
+func (_m *OpenStackMock) GetVolumeMigrationStatus(volumeID string) (string, error) {
+	ret := _m.Called(volumeID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string) string); ok {
+		r0 = rf(volumeID)
+	} else {
+		r0 = ret.String(0)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(volumeID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetVolumeQuotaUsage provides a mock function with given fields: projectID
+func (_m *OpenStackMock) GetVolumeQuotaUsage(projectID string) (*quotasets.QuotaUsageSet, error) {
+	ret := _m.Called(projectID)
+
+	var r0 *quotasets.QuotaUsageSet
+	if rf, ok := ret.Get(0).(func(string) *quotasets.QuotaUsageSet); ok {
+		r0 = rf(projectID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*quotasets.QuotaUsageSet)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(projectID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 func (_m *OpenStackMock) GetMetadataOpts() metadata.Opts {
 	var m metadata.Opts
 	m.SearchOrder = "configDrive"
@@ -527,3 +625,72 @@ func (_m *OpenStackMock) GetBlockStorageOpts() BlockStorageOpts {
 func (_m *OpenStackMock) ResolveVolumeListToUUIDs(v string) (string, error) {
 	return v, nil
 }
+
+// ListVolumeTypes provides a mock function with given fields:
+func (_m *OpenStackMock) ListVolumeTypes() ([]volumetypes.VolumeType, error) {
+	ret := _m.Called()
+
+	var r0 []volumetypes.VolumeType
+	if rf, ok := ret.Get(0).(func() []volumetypes.VolumeType); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volumetypes.VolumeType)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetVolumeTypeEncryption provides a mock function with given fields: volumeTypeID
+func (_m *OpenStackMock) GetVolumeTypeEncryption(volumeTypeID string) (*volumetypes.GetEncryptionType, error) {
+	ret := _m.Called(volumeTypeID)
+
+	var r0 *volumetypes.GetEncryptionType
+	if rf, ok := ret.Get(0).(func(string) *volumetypes.GetEncryptionType); ok {
+		r0 = rf(volumeTypeID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*volumetypes.GetEncryptionType)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(volumeTypeID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetQoSSpecs provides a mock function with given fields: qosSpecsID
+func (_m *OpenStackMock) GetQoSSpecs(qosSpecsID string) (*qos.QoS, error) {
+	ret := _m.Called(qosSpecsID)
+
+	var r0 *qos.QoS
+	if rf, ok := ret.Get(0).(func(string) *qos.QoS); ok {
+		r0 = rf(qosSpecsID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*qos.QoS)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(qosSpecsID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"maps"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+// RequestIDHeader is the header of the global request ID, the OpenStack services log it along with their own request
+// ID. Only the IDs formatted as req-<UUID> are accepted.
+const RequestIDHeader = "X-OpenStack-Request-ID"
+
+// WithRequestID returns a copy of the OpenStack client sending the request ID with all its requests, so that the
+// requests of a CSI call can be found in the logs of Nova and Cinder.
+func (os *OpenStack) WithRequestID(requestID string) IOpenStack {
+	c := *os
+	c.compute = withRequestID(os.compute, requestID)
+	c.blockstorage = withRequestID(os.blockstorage, requestID)
+	return &c
+}
+
+// withRequestID returns a copy of the service client with the request ID header.
+func withRequestID(client *gophercloud.ServiceClient, requestID string) *gophercloud.ServiceClient {
+	c := *client
+	c.MoreHeaders = maps.Clone(client.MoreHeaders)
+	if c.MoreHeaders == nil {
+		c.MoreHeaders = map[string]string{}
+	}
+	c.MoreHeaders[RequestIDHeader] = requestID
+	return &c
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package openstack
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"reflect"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/v2"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/cloud-provider-openstack/pkg/client"
+	"k8s.io/utils/ptr"
 )
 
 var fakeFileName = "cloud.conf"
@@ -85,7 +90,9 @@ domain-id=` + fakeDomainID_cloud3 + `
 ca-file=` + fakeCAfile_cloud3 + `
 region=` + fakeRegion_cloud3 + `
 [BlockStorage]
-rescan-on-resize=true`
+rescan-on-resize=true
+node-volume-attach-limit-flavor=m1.large=24
+node-volume-attach-limit-flavor=gpu.*=8`
 
 	f, err := os.Create(fakeFileName)
 	if err != nil {
@@ -132,7 +139,8 @@ rescan-on-resize=true`
 		Region:   fakeRegion_cloud3,
 	}
 
-	expectedOpts.BlockStorage.RescanOnResize = true
+	expectedOpts.BlockStorage.RescanOnResize = ptr.To(true)
+	expectedOpts.BlockStorage.FlavorAttachLimits = []string{"m1.large=24", "gpu.*=8"}
 
 	// Invoke GetConfigFromFiles
 	actualAuthOpts, err := GetConfigFromFiles([]string{fakeFileName})
@@ -163,7 +171,7 @@ rescan-on-resize=false`
 	// expectedOpts should reflect the overridden value of rescan-on-resize. All
 	// other values should be the same as before because they come from the
 	// 'base' configuration
-	expectedOpts.BlockStorage.RescanOnResize = false
+	expectedOpts.BlockStorage.RescanOnResize = ptr.To(false)
 
 	// Invoke GetConfigFromFiles with both the base and override config files
 	actualAuthOpts, err = GetConfigFromFiles([]string{fakeFileName, fakeOverrideFileName})
@@ -256,7 +264,7 @@ rescan-on-resize=true`
 		Cloud:        fakeCloudName_cloud3,
 	}
 
-	expectedOpts.BlockStorage.RescanOnResize = true
+	expectedOpts.BlockStorage.RescanOnResize = ptr.To(true)
 
 	// Invoke GetConfigFromFiles
 	actualAuthOpts, err := GetConfigFromFiles([]string{fakeFileName})
@@ -304,3 +312,34 @@ func TestUserAgentFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestFaultMessage(t *testing.T) {
+	err := gophercloud.ErrUnexpectedResponseCode{
+		Actual: 413,
+		Body:   []byte(`{"overLimit": {"code": 413, "message": "VolumeSizeExceedsAvailableQuota: Requested volume or snapshot exceeds allowed gigabytes quota.", "retryAfter": "0"}}`),
+	}
+	assert.Equal(t, "VolumeSizeExceedsAvailableQuota: Requested volume or snapshot exceeds allowed gigabytes quota.", FaultMessage(err))
+
+	err = gophercloud.ErrUnexpectedResponseCode{Actual: 500, Body: []byte("Internal Server Error")}
+	assert.Equal(t, err.Error(), FaultMessage(err))
+}
+
+func TestWithRequestID(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/volumes/vol-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestHeader(t, r, RequestIDHeader, "req-id")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"volume": {"id": "vol-id"}}`)
+	})
+
+	cloud := &OpenStack{compute: fakeclient.ServiceClient(), blockstorage: fakeclient.ServiceClient()}
+	vol, err := cloud.WithRequestID("req-id").GetVolume("vol-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "vol-id", vol.ID)
+
+	// The client of the plugin is left unchanged
+	assert.Empty(t, cloud.blockstorage.MoreHeaders)
+	assert.Empty(t, cloud.compute.MoreHeaders)
+}
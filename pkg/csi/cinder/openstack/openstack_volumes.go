@@ -18,13 +18,19 @@ package openstack
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/volumeattach"
 	"github.com/gophercloud/gophercloud/v2/pagination"
 	"google.golang.org/grpc/codes"
@@ -54,12 +60,16 @@ const (
 
 var volumeErrorStates = [...]string{"error", "error_extending", "error_deleting"}
 
+// ErrVolumeErrorState is returned by WaitVolumeTargetStatus when the volume is in an error state.
+var ErrVolumeErrorState = errors.New("Volume is in Error State")
+
 // CreateVolume creates a volume of given size
 func (os *OpenStack) CreateVolume(opts *volumes.CreateOpts, schedulerHints volumes.SchedulerHintOptsBuilder) (*volumes.Volume, error) {
 	blockstorageClient, err := openstack.NewBlockStorageV3(os.blockstorage.ProviderClient, os.epOpts)
 	if err != nil {
 		return nil, err
 	}
+	blockstorageClient.MoreHeaders = os.blockstorage.MoreHeaders
 
 	// creating volumes from backups and backups cross-az is available since 3.51 microversion
 	// https://docs.openstack.org/cinder/latest/contributor/api_microversion_history.html#id47
@@ -122,6 +132,7 @@ func (os *OpenStack) GetVolumesByName(n string) ([]volumes.Volume, error) {
 	if err != nil {
 		return nil, err
 	}
+	blockstorageClient.MoreHeaders = os.blockstorage.MoreHeaders
 
 	// cinder filtering in volumes list is available since 3.34 microversion
 	// https://docs.openstack.org/cinder/latest/contributor/api_microversion_history.html#id32
@@ -144,6 +155,18 @@ func (os *OpenStack) GetVolumesByName(n string) ([]volumes.Volume, error) {
 	return vols, nil
 }
 
+// GetVolumesByMetadata returns the volumes having all the given metadata
+func (os *OpenStack) GetVolumesByMetadata(metadata map[string]string) ([]volumes.Volume, error) {
+	opts := volumes.ListOpts{Metadata: metadata}
+	mc := metrics.NewMetricContext("volume", "list")
+	pages, err := volumes.List(os.blockstorage, opts).AllPages(context.TODO())
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return volumes.ExtractVolumes(pages)
+}
+
 // GetVolumeByName is a wrapper around GetVolumesByName that returns a single Volume reference
 // with the specified name
 func (os *OpenStack) GetVolumeByName(n string) (*volumes.Volume, error) {
@@ -212,6 +235,7 @@ func (os *OpenStack) AttachVolume(instanceID, volumeID string) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		computeServiceClient.MoreHeaders = os.compute.MoreHeaders
 		computeServiceClient.Microversion = "2.60"
 	}
 
@@ -272,7 +296,7 @@ func (os *OpenStack) WaitVolumeTargetStatus(volumeID string, tStatus []string) e
 		}
 		for _, eState := range volumeErrorStates {
 			if vol.Status == eState {
-				return false, fmt.Errorf("Volume is in Error State : %s", vol.Status)
+				return false, fmt.Errorf("%w : %s", ErrVolumeErrorState, vol.Status)
 			}
 		}
 		return false, nil
@@ -380,6 +404,7 @@ func (os *OpenStack) ExpandVolume(volumeID string, status string, newSize int) e
 		if err != nil {
 			return err
 		}
+		blockstorageClient.MoreHeaders = os.blockstorage.MoreHeaders
 
 		// cinder online resize is available since 3.42 microversion
 		// https://docs.openstack.org/cinder/latest/contributor/api_microversion_history.html#id40
@@ -396,6 +421,106 @@ func (os *OpenStack) ExpandVolume(volumeID string, status string, newSize int) e
 	return fmt.Errorf("volume cannot be resized, when status is %s", status)
 }
 
+// UpdateVolumeMetadata sets the metadata keys of the volume, its other keys are kept
+func (os *OpenStack) UpdateVolumeMetadata(volumeID string, metadata map[string]string) error {
+	vol, err := os.GetVolume(volumeID)
+	if err != nil {
+		return err
+	}
+
+	md := make(map[string]string, len(vol.Metadata)+len(metadata))
+	for k, v := range vol.Metadata {
+		md[k] = v
+	}
+	for k, v := range metadata {
+		md[k] = v
+	}
+
+	mc := metrics.NewMetricContext("volume", "update")
+	_, err = volumes.Update(context.TODO(), os.blockstorage, volumeID, volumes.UpdateOpts{Metadata: md}).Extract()
+	return mc.ObserveRequest(err)
+}
+
+// MigrateVolume migrates the volume with a full copy of its data, letting the scheduler pick the destination host.
+// The copy detaches the volume from its source volume or snapshot on the backends cloning them lazily.
+func (os *OpenStack) MigrateVolume(volumeID string) error {
+	if os.bsOpts.IgnoreVolumeMicroversion {
+		return fmt.Errorf("volume migration without destination host is not available with ignore-volume-microversion, requires microversion 3.16 or newer")
+	}
+
+	// Init a local thread safe copy of the Cinder ServiceClient
+	blockstorageClient, err := openstack.NewBlockStorageV3(os.blockstorage.ProviderClient, os.epOpts)
+	if err != nil {
+		return err
+	}
+	blockstorageClient.MoreHeaders = os.blockstorage.MoreHeaders
+
+	// the destination host is optional since 3.16 microversion
+	// https://docs.openstack.org/cinder/latest/contributor/api_microversion_history.html
+	blockstorageClient.Microversion = "3.16"
+
+	body := map[string]any{
+		"os-migrate_volume": map[string]any{
+			"force_host_copy": true,
+		},
+	}
+
+	mc := metrics.NewMetricContext("volume", "migrate")
+	_, err = blockstorageClient.Post(context.TODO(), blockstorageClient.ServiceURL("volumes", volumeID, "action"), body, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	return mc.ObserveRequest(err)
+}
+
+// GetVolumeMigrationStatus returns the status of the last migration of the volume, e.g. migrating, success or error.
+// Cinder only returns it to the administrators, it is empty for the other users.
+func (os *OpenStack) GetVolumeMigrationStatus(volumeID string) (string, error) {
+	var vol struct {
+		MigrationStatus string `json:"os-vol-mig-status-attr:migstat"`
+	}
+
+	mc := metrics.NewMetricContext("volume", "get")
+	err := volumes.Get(context.TODO(), os.blockstorage, volumeID).ExtractIntoStructPtr(&vol, "volume")
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+
+	return vol.MigrationStatus, nil
+}
+
+// GetVolumeQuotaUsage returns the volume quotas of the project and their usage
+func (os *OpenStack) GetVolumeQuotaUsage(projectID string) (*quotasets.QuotaUsageSet, error) {
+	mc := metrics.NewMetricContext("quota", "get_usage")
+	usage, err := quotasets.GetUsage(context.TODO(), os.blockstorage, projectID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// FaultMessage returns the message of the Cinder fault in the error response, or the error itself if there is none
+func FaultMessage(err error) string {
+	var respErr gophercloud.ErrUnexpectedResponseCode
+	if !errors.As(err, &respErr) {
+		return err.Error()
+	}
+
+	// Cinder faults are like {"overLimit": {"code": 413, "message": "..."}}
+	var fault map[string]struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(respErr.Body, &fault) == nil {
+		for _, f := range fault {
+			if f.Message != "" {
+				return f.Message
+			}
+		}
+	}
+
+	return err.Error()
+}
+
 // GetMaxVolLimit returns max vol limit
 func (os *OpenStack) GetMaxVolLimit() int64 {
 	if os.bsOpts.NodeVolumeAttachLimit > 0 && os.bsOpts.NodeVolumeAttachLimit <= 256 {
@@ -433,6 +558,44 @@ func (os *OpenStack) diskIsUsed(volumeID string) (bool, error) {
 	return false, nil
 }
 
+// ListVolumeTypes lists the volume types available to the project
+func (os *OpenStack) ListVolumeTypes() ([]volumetypes.VolumeType, error) {
+	mc := metrics.NewMetricContext("volume_type", "list")
+	allPages, err := volumetypes.List(os.blockstorage, volumetypes.ListOpts{}).AllPages(context.TODO())
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return volumetypes.ExtractVolumeTypes(allPages)
+}
+
+// GetVolumeTypeEncryption returns the encryption of the volume type, or nil if the volume type is not encrypted
+func (os *OpenStack) GetVolumeTypeEncryption(volumeTypeID string) (*volumetypes.GetEncryptionType, error) {
+	mc := metrics.NewMetricContext("volume_type", "get_encryption")
+	encryption, err := volumetypes.GetEncryption(context.TODO(), os.blockstorage, volumeTypeID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	// Cinder returns an empty object for the volume types without encryption
+	if encryption.EncryptionID == "" {
+		return nil, nil
+	}
+
+	return encryption, nil
+}
+
+// GetQoSSpecs returns the QoS specs associated with a volume type
+func (os *OpenStack) GetQoSSpecs(qosSpecsID string) (*qos.QoS, error) {
+	mc := metrics.NewMetricContext("qos_specs", "get")
+	specs, err := qos.Get(context.TODO(), os.blockstorage, qosSpecsID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return specs, nil
+}
+
 // GetBlockStorageOpts returns OpenStack block storage options
 func (os *OpenStack) GetBlockStorageOpts() BlockStorageOpts {
 	return os.bsOpts
@@ -0,0 +1,216 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	// projectQuotasKey is the StorageClass parameter, and the volume context key, enabling the project quotas on the
+	// filesystem of the volumes.
+	projectQuotasKey = "projectQuotas"
+
+	// ProjectQuotasAnnotation is the pod annotation holding the comma-separated list of the subdirectories of its
+	// volumes with project quotas enabled, with their capacity limit, e.g. "app1=10Gi,logs=1Gi". The subdirectories
+	// are created at NodePublishVolume, meant to be mounted by the containers with subPath.
+	ProjectQuotasAnnotation = driverName + "/project-quotas"
+
+	// projectQuotaMountOption enables the project quotas of the xfs and ext4 filesystems.
+	projectQuotaMountOption = "prjquota"
+
+	// projectQuotaXattr is the extended attribute recording the project quota limit set on a subdirectory.
+	projectQuotaXattr = "trusted." + driverName + ".project-quota"
+)
+
+// projectQuota is the capacity limit of a subdirectory of a volume.
+type projectQuota struct {
+	subPath string
+	bytes   int64
+}
+
+// getProjectQuotasParameter checks the projectQuotas parameter of the StorageClass.
+func getProjectQuotasParameter(params map[string]string) (bool, error) {
+	v, ok := params[projectQuotasKey]
+	if !ok {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter %q", projectQuotasKey, v)
+	}
+	return enabled, nil
+}
+
+// parseProjectQuotas parses the project quotas annotation of a pod, sorted by subdirectory.
+func parseProjectQuotas(value string) ([]projectQuota, error) {
+	var quotas []projectQuota
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subPath, size, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid project quota %q, expected <subdirectory>=<size>", entry)
+		}
+		subPath = filepath.Clean(strings.TrimSpace(subPath))
+		if filepath.IsAbs(subPath) || subPath == "." || subPath == ".." || strings.HasPrefix(subPath, "../") {
+			return nil, fmt.Errorf("invalid project quota subdirectory %q, it must be relative to the volume", subPath)
+		}
+		if seen[subPath] {
+			return nil, fmt.Errorf("duplicate project quota subdirectory %q", subPath)
+		}
+		seen[subPath] = true
+
+		quantity, err := resource.ParseQuantity(strings.TrimSpace(size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid project quota size %q of subdirectory %q: %v", size, subPath, err)
+		}
+		if quantity.Value() <= 0 {
+			return nil, fmt.Errorf("invalid project quota size %q of subdirectory %q, it must be positive", size, subPath)
+		}
+		quotas = append(quotas, projectQuota{subPath: subPath, bytes: quantity.Value()})
+	}
+
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].subPath < quotas[j].subPath })
+	return quotas, nil
+}
+
+// projectID returns the project ID of the subdirectory, derived from its path so that it's stable across the nodes
+// and the pods sharing the volume.
+func projectID(subPath string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(subPath))
+	// The project 0 is the default project of the files without quota
+	if id := h.Sum32() & 0x7fffffff; id != 0 {
+		return id
+	}
+	return 1
+}
+
+// errProjectQuotaSet is returned when a pod requests another limit for a subdirectory whose project quota is set.
+var errProjectQuotaSet = errors.New("the project quota is already set")
+
+// getFilesystemType returns the type of the filesystem of the file, it's replaced by the tests.
+var getFilesystemType = filesystemType
+
+// applyProjectQuotas creates the subdirectories of the quotas in the filesystem mounted at mountPoint, assigns each of
+// them to its project and sets the block hard limit of the project. The xfs filesystems must be mounted with
+// prjquota, the ext4 ones must also have the quota and project features. The limit of a subdirectory is set once and
+// recorded on it, the pods sharing the volume can't change it.
+func applyProjectQuotas(exec utilexec.Interface, mountPoint string, quotas []projectQuota) error {
+	root, err := os.Open(mountPoint)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	// The filesystem may not be the one requested, e.g. for a volume formatted beforehand
+	fsType, err := getFilesystemType(root)
+	if err != nil {
+		return fmt.Errorf("failed to get the filesystem type of %s: %v", mountPoint, err)
+	}
+	if fsType != "xfs" && fsType != "ext4" {
+		return fmt.Errorf("project quotas are only supported on xfs and ext4 filesystems")
+	}
+
+	info, err := root.Stat()
+	if err != nil {
+		return err
+	}
+
+	for _, q := range quotas {
+		if err := applyProjectQuota(exec, root, fsType, q, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyProjectQuota creates the subdirectory of the quota, and sets its project quota unless it's set already.
+func applyProjectQuota(exec utilexec.Interface, root *os.File, fsType string, q projectQuota, perm os.FileMode) error {
+	dir, err := openDirNoSymlinks(root, q.subPath, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create the subdirectory %s: %v", q.subPath, err)
+	}
+	defer dir.Close()
+
+	limit := strconv.FormatInt(q.bytes, 10)
+	recorded, ok, err := getProjectQuotaLimit(dir)
+	if err != nil {
+		return fmt.Errorf("failed to get the project quota of the subdirectory %s: %v", q.subPath, err)
+	}
+	if ok {
+		if recorded != limit {
+			return fmt.Errorf("%w: the limit of the subdirectory %s is %s bytes, not %s", errProjectQuotaSet, q.subPath, recorded, limit)
+		}
+		klog.V(4).Infof("The project quota of %s is set already", dir.Name())
+		return nil
+	}
+
+	// The commands run as root get the opened directory, not a path the pods may have replaced with a symbolic link
+	path := openedPath(dir)
+	id := strconv.FormatUint(uint64(projectID(q.subPath)), 10)
+	var cmds [][]string
+	switch fsType {
+	case "xfs":
+		cmds = [][]string{
+			{"xfs_quota", "-x", "-c", fmt.Sprintf("project -s -p %s %s", path, id), root.Name()},
+			{"xfs_quota", "-x", "-c", fmt.Sprintf("limit -p bhard=%d %s", q.bytes, id), root.Name()},
+		}
+	case "ext4":
+		// setquota counts the block limits in KiB
+		cmds = [][]string{
+			{"chattr", "-p", id, "+P", path},
+			{"setquota", "-P", id, "0", strconv.FormatInt((q.bytes+1023)/1024, 10), "0", "0", root.Name()},
+		}
+	}
+	for _, cmd := range cmds {
+		if out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to set the project quota of the subdirectory %s: %s: %v: %s", q.subPath, cmd[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	if err := setProjectQuotaLimit(dir, limit); err != nil {
+		return fmt.Errorf("failed to record the project quota of the subdirectory %s: %v", q.subPath, err)
+	}
+	klog.V(4).Infof("Set the project quota %s of %s to %d bytes", id, dir.Name(), q.bytes)
+
+	return nil
+}
+
+// enableExt4ProjectQuotas enables the quota and project features of an existing ext4 filesystem, which must not be
+// mounted.
+func enableExt4ProjectQuotas(exec utilexec.Interface, devicePath string) error {
+	if out, err := exec.Command("tune2fs", "-O", "quota,project", devicePath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to enable the project quotas of %s: %v: %s", devicePath, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// filesystemType returns the type of the filesystem of the file, empty for the filesystems other than xfs and ext4.
+func filesystemType(f *os.File) (string, error) {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return "", err
+	}
+	switch st.Type {
+	case unix.XFS_SUPER_MAGIC:
+		return "xfs", nil
+	case unix.EXT4_SUPER_MAGIC:
+		return "ext4", nil
+	}
+	return "", nil
+}
+
+// openDirNoSymlinks creates the subdirectory of the root directory and its parents, and opens it. The path is walked
+// one directory at a time without following the symbolic links, so that the pods writing to the volume can't
+// redirect it outside of the volume meanwhile.
+func openDirNoSymlinks(root *os.File, subPath string, perm os.FileMode) (*os.File, error) {
+	fd, err := unix.Openat(int(root.Fd()), ".", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := root.Name()
+	for _, name := range strings.Split(subPath, string(filepath.Separator)) {
+		dir = filepath.Join(dir, name)
+		if err := unix.Mkdirat(fd, name, uint32(perm)); err != nil && !errors.Is(err, unix.EEXIST) {
+			unix.Close(fd)
+			return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+		next, err := unix.Openat(fd, name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		if err != nil {
+			// A symbolic link fails with ELOOP or ENOTDIR, depending on the kernel
+			var st unix.Stat_t
+			isLink := unix.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW) == nil && st.Mode&unix.S_IFMT == unix.S_IFLNK
+			unix.Close(fd)
+			switch {
+			case isLink || errors.Is(err, unix.ELOOP):
+				return nil, fmt.Errorf("%s is a symbolic link", dir)
+			case errors.Is(err, unix.ENOTDIR):
+				return nil, fmt.Errorf("%s is not a directory", dir)
+			}
+			return nil, fmt.Errorf("failed to open %s: %v", dir, err)
+		}
+		unix.Close(fd)
+		fd = next
+	}
+
+	return os.NewFile(uintptr(fd), dir), nil
+}
+
+// openedPath returns a path to the opened file that the commands run by the driver resolve to the file itself, even
+// if it's renamed or replaced meanwhile.
+func openedPath(f *os.File) string {
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd())
+}
+
+// getProjectQuotaLimit returns the project quota limit recorded on the directory, if any.
+func getProjectQuotaLimit(dir *os.File) (string, bool, error) {
+	buf := make([]byte, 32)
+	n, err := unix.Fgetxattr(int(dir.Fd()), projectQuotaXattr, buf)
+	if errors.Is(err, unix.ENODATA) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(buf[:n]), true, nil
+}
+
+// setProjectQuotaLimit records the project quota limit on the directory. The trusted extended attributes are only
+// writable with the CAP_SYS_ADMIN capability, the pods can't change them.
+func setProjectQuotaLimit(dir *os.File, limit string) error {
+	return unix.Fsetxattr(int(dir.Fd()), projectQuotaXattr, []byte(limit), 0)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	utilexec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+func TestParseProjectQuotas(t *testing.T) {
+	quotas, err := parseProjectQuotas(" logs=1Gi, app1/data=10Gi,,")
+	assert.NoError(t, err)
+	assert.Equal(t, []projectQuota{
+		{subPath: "app1/data", bytes: 10 * 1024 * 1024 * 1024},
+		{subPath: "logs", bytes: 1024 * 1024 * 1024},
+	}, quotas)
+
+	for value, expectedErr := range map[string]string{
+		"app1":               "expected <subdirectory>=<size>",
+		"/app1=1Gi":          "it must be relative to the volume",
+		"../app1=1Gi":        "it must be relative to the volume",
+		".=1Gi":              "it must be relative to the volume",
+		"app1=1Gi,app1/=2Gi": "duplicate project quota subdirectory",
+		"app1=big":           "invalid project quota size",
+		"app1=0":             "it must be positive",
+	} {
+		_, err := parseProjectQuotas(value)
+		assert.ErrorContains(t, err, expectedErr, value)
+	}
+}
+
+func TestProjectID(t *testing.T) {
+	assert.Equal(t, projectID("app1"), projectID("app1"))
+	assert.NotEqual(t, projectID("app1"), projectID("app2"))
+	assert.NotZero(t, projectID("app1"))
+}
+
+func fakeQuotaExec(cmds *[][]string, count int) *testingexec.FakeExec {
+	fake := &testingexec.FakeExec{}
+	for i := 0; i < count; i++ {
+		fake.CommandScript = append(fake.CommandScript, func(cmd string, args ...string) utilexec.Cmd {
+			*cmds = append(*cmds, append([]string{cmd}, args...))
+			return &testingexec.FakeCmd{CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) { return nil, nil, nil },
+			}}
+		})
+	}
+	return fake
+}
+
+// fakeFilesystemType makes the filesystems of the tests look like fsType.
+func fakeFilesystemType(t *testing.T, fsType string) {
+	getFilesystemType = func(*os.File) (string, error) { return fsType, nil }
+	t.Cleanup(func() { getFilesystemType = filesystemType })
+}
+
+// requireProjectQuotaXattr skips the test when the trusted extended attributes can't be set in the temporary directories.
+func requireProjectQuotaXattr(t *testing.T) {
+	dir, err := os.Open(t.TempDir())
+	assert.NoError(t, err)
+	defer dir.Close()
+	if err := setProjectQuotaLimit(dir, "0"); err != nil {
+		t.Skipf("The trusted extended attributes aren't supported: %v", err)
+	}
+}
+
+func TestApplyProjectQuotas(t *testing.T) {
+	requireProjectQuotaXattr(t)
+	quotas := []projectQuota{{subPath: "app1", bytes: 10 * 1024 * 1024}}
+	id := strconv.FormatUint(uint64(projectID("app1")), 10)
+	fdPrefix := fmt.Sprintf("/proc/%d/fd/", os.Getpid())
+
+	fakeFilesystemType(t, "xfs")
+	mountPoint := t.TempDir()
+	var cmds [][]string
+	assert.NoError(t, applyProjectQuotas(fakeQuotaExec(&cmds, 2), mountPoint, quotas))
+	assert.DirExists(t, filepath.Join(mountPoint, "app1"))
+	assert.Len(t, cmds, 2)
+	assert.Regexp(t, "^project -s -p "+fdPrefix+"[0-9]+ "+id+"$", cmds[0][3])
+	assert.Equal(t, []string{"xfs_quota", "-x", "-c", "limit -p bhard=10485760 " + id, mountPoint}, cmds[1])
+
+	// The quota set already isn't set again, nor changed by another pod
+	assert.NoError(t, applyProjectQuotas(&testingexec.FakeExec{}, mountPoint, quotas))
+	err := applyProjectQuotas(&testingexec.FakeExec{}, mountPoint, []projectQuota{{subPath: "app1", bytes: 20 * 1024 * 1024}})
+	assert.ErrorIs(t, err, errProjectQuotaSet)
+
+	fakeFilesystemType(t, "ext4")
+	mountPoint = t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(mountPoint, "app1"), 0755))
+	cmds = nil
+	assert.NoError(t, applyProjectQuotas(fakeQuotaExec(&cmds, 2), mountPoint, quotas))
+	assert.Len(t, cmds, 2)
+	assert.Equal(t, []string{"chattr", "-p", id, "+P"}, cmds[0][:4])
+	assert.Regexp(t, "^"+fdPrefix+"[0-9]+$", cmds[0][4])
+	assert.Equal(t, []string{"setquota", "-P", id, "0", "10240", "0", "0", mountPoint}, cmds[1])
+
+	fakeFilesystemType(t, "")
+	err = applyProjectQuotas(&testingexec.FakeExec{}, t.TempDir(), quotas)
+	assert.ErrorContains(t, err, "only supported on xfs and ext4")
+}
+
+func TestApplyProjectQuotasSymlink(t *testing.T) {
+	fakeFilesystemType(t, "xfs")
+	mountPoint := t.TempDir()
+	outside := t.TempDir()
+	assert.NoError(t, os.Symlink(outside, filepath.Join(mountPoint, "app1")))
+
+	// The symbolic links created by the pods aren't followed
+	for _, subPath := range []string{"app1", "app1/logs"} {
+		quotas := []projectQuota{{subPath: subPath, bytes: 1024}}
+		err := applyProjectQuotas(&testingexec.FakeExec{}, mountPoint, quotas)
+		assert.ErrorContains(t, err, "is a symbolic link")
+	}
+	assert.NoDirExists(t, filepath.Join(outside, "logs"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(mountPoint, "file"), nil, 0644))
+	err := applyProjectQuotas(&testingexec.FakeExec{}, mountPoint, []projectQuota{{subPath: "file", bytes: 1024}})
+	assert.ErrorContains(t, err, "is not a directory")
+}
+
+func TestGetProjectQuotasParameter(t *testing.T) {
+	enabled, err := getProjectQuotasParameter(map[string]string{projectQuotasKey: "true"})
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	enabled, err = getProjectQuotasParameter(nil)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = getProjectQuotasParameter(map[string]string{projectQuotasKey: "yes please"})
+	assert.ErrorContains(t, err, "invalid projectQuotas parameter")
+}
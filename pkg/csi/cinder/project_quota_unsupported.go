@@ -0,0 +1,44 @@
+//go:build !linux
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"os"
+)
+
+func filesystemType(f *os.File) (string, error) {
+	return "", errors.New("project quotas are not implemented for this OS")
+}
+
+func openDirNoSymlinks(root *os.File, subPath string, perm os.FileMode) (*os.File, error) {
+	return nil, errors.New("project quotas are not implemented for this OS")
+}
+
+func openedPath(f *os.File) string {
+	return f.Name()
+}
+
+func getProjectQuotaLimit(dir *os.File) (string, bool, error) {
+	return "", false, errors.New("project quotas are not implemented for this OS")
+}
+
+func setProjectQuotaLimit(dir *os.File, limit string) error {
+	return errors.New("project quotas are not implemented for this OS")
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+var (
+	grpcRequestDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:    "cinder_csi_grpc_request_duration_seconds",
+			Help:    "Duration of the CSI calls by method and gRPC status code",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300},
+		}, []string{"method", "code"})
+
+	registerGRPCMetrics sync.Once
+)
+
+// RegisterGRPCMetrics registers the metrics of the CSI calls.
+func RegisterGRPCMetrics() {
+	registerGRPCMetrics.Do(func() {
+		legacyregistry.MustRegister(grpcRequestDuration)
+	})
+}
+
+type requestIDKey struct{}
+
+// newRequestID returns a request ID in the req-<UUID> format of the OpenStack global request IDs.
+func newRequestID() string {
+	return "req-" + uuid.NewString()
+}
+
+// withRequestID returns a copy of the context carrying the request ID of the CSI call.
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// requestIDFromContext returns the request ID of the CSI call, empty if none.
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// requestCloud returns the OpenStack client sending the request ID of the CSI call with its requests, or the client
+// itself when it doesn't support request IDs.
+func requestCloud(ctx context.Context, cloud openstack.IOpenStack) openstack.IOpenStack {
+	requestID := requestIDFromContext(ctx)
+	c, ok := cloud.(interface {
+		WithRequestID(requestID string) openstack.IOpenStack
+	})
+	if requestID == "" || !ok {
+		return cloud
+	}
+	return c.WithRequestID(requestID)
+}
@@ -47,6 +47,8 @@ func NewNonBlockingGRPCServer() NonBlockingGRPCServer {
 type nonBlockingGRPCServer struct {
 	wg     sync.WaitGroup
 	server *grpc.Server
+	// shutdown tracks the in-flight operations to drain them on shutdown, optional.
+	shutdown *shutdownManager
 }
 
 func (s *nonBlockingGRPCServer) Start(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
@@ -88,8 +90,13 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 		klog.Fatalf("Failed to listen: %v", err)
 	}
 
+	RegisterGRPCMetrics()
+	interceptors := []grpc.UnaryServerInterceptor{logGRPC}
+	if s.shutdown != nil {
+		interceptors = append(interceptors, s.shutdown.intercept)
+	}
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(logGRPC),
+		grpc.ChainUnaryInterceptor(interceptors...),
 	}
 	server := grpc.NewServer(opts...)
 	s.server = server
@@ -0,0 +1,232 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// createVolumeMethod is the gRPC method of the CreateVolume calls.
+const createVolumeMethod = "/csi.v1.Controller/CreateVolume"
+
+// interruptedOperation is a CSI operation still in flight when the shutdown drain timed out.
+type interruptedOperation struct {
+	Method    string    `json:"method"`
+	VolumeID  string    `json:"volumeID,omitempty"`
+	NodeID    string    `json:"nodeID,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// shutdownManager tracks the in-flight CSI operations, so that they can be drained on shutdown.
+type shutdownManager struct {
+	// journalPath is the file the interrupted operations are persisted to, no journal is written when empty.
+	journalPath string
+
+	mu       sync.Mutex
+	draining bool
+	nextID   uint64
+	inFlight map[uint64]interruptedOperation
+	idle     *sync.Cond
+}
+
+func newShutdownManager(journalPath string) *shutdownManager {
+	m := &shutdownManager{
+		journalPath: journalPath,
+		inFlight:    make(map[uint64]interruptedOperation),
+	}
+	m.idle = sync.NewCond(&m.mu)
+	return m
+}
+
+// intercept is a gRPC unary interceptor rejecting the new operations once the drain started, and tracking
+// the accepted ones.
+func (m *shutdownManager) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id, ok := m.begin(info.FullMethod, req)
+	if !ok {
+		return nil, status.Errorf(codes.Unavailable, "the driver is shutting down, %s rejected", info.FullMethod)
+	}
+	defer m.end(id)
+
+	return handler(ctx, req)
+}
+
+func (m *shutdownManager) begin(method string, req interface{}) (uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draining {
+		return 0, false
+	}
+
+	op := interruptedOperation{Method: method, StartedAt: time.Now()}
+	if r, ok := req.(interface{ GetVolumeId() string }); ok {
+		op.VolumeID = r.GetVolumeId()
+	}
+	if r, ok := req.(interface{ GetNodeId() string }); ok {
+		op.NodeID = r.GetNodeId()
+	}
+	if r, ok := req.(interface{ GetName() string }); ok {
+		op.Name = r.GetName()
+	}
+
+	m.nextID++
+	m.inFlight[m.nextID] = op
+	return m.nextID, true
+}
+
+func (m *shutdownManager) end(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inFlight, id)
+	if len(m.inFlight) == 0 {
+		m.idle.Broadcast()
+	}
+}
+
+// drain stops accepting new operations and waits up to timeout for the in-flight ones. The operations still in
+// flight after the timeout are persisted to the journal and returned.
+func (m *shutdownManager) drain(timeout time.Duration) []interruptedOperation {
+	m.mu.Lock()
+	m.draining = true
+	klog.Infof("Draining %d in-flight CSI operations", len(m.inFlight))
+
+	timedOut := false
+	timer := time.AfterFunc(timeout, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		timedOut = true
+		m.idle.Broadcast()
+	})
+	defer timer.Stop()
+
+	for len(m.inFlight) > 0 && !timedOut {
+		m.idle.Wait()
+	}
+	if len(m.inFlight) == 0 {
+		m.mu.Unlock()
+		klog.Info("All in-flight CSI operations completed")
+		return nil
+	}
+
+	ops := make([]interruptedOperation, 0, len(m.inFlight))
+	for _, op := range m.inFlight {
+		ops = append(ops, op)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].StartedAt.Before(ops[j].StartedAt) })
+	klog.Warningf("Timed out after %v waiting for %d in-flight CSI operations", timeout, len(ops))
+	if err := m.writeJournal(ops); err != nil {
+		klog.Errorf("Failed to persist the interrupted CSI operations: %v", err)
+	}
+
+	return ops
+}
+
+func (m *shutdownManager) writeJournal(ops []interruptedOperation) error {
+	if m.journalPath == "" || len(ops) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that an interrupted write never leaves a corrupted journal.
+	tmp := filepath.Join(filepath.Dir(m.journalPath), "."+filepath.Base(m.journalPath)+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.journalPath)
+}
+
+// recoverJournal reports the operations interrupted by the previous shutdown and removes the journal. The
+// container orchestrator retries these idempotent operations, the controller cleans up the volumes the retries
+// can't recover from, see cleanupInterruptedOperations.
+func (m *shutdownManager) recoverJournal() ([]interruptedOperation, error) {
+	if m.journalPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(m.journalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ops []interruptedOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("failed to parse the journal %s: %v", m.journalPath, err)
+	}
+
+	for _, op := range ops {
+		klog.Warningf("CSI operation %s interrupted by the previous shutdown: volume %q, node %q, name %q, started at %s",
+			op.Method, op.VolumeID, op.NodeID, op.Name, op.StartedAt.Format(time.RFC3339))
+	}
+
+	if err := os.Remove(m.journalPath); err != nil {
+		return ops, err
+	}
+
+	return ops, nil
+}
+
+// cleanupInterruptedOperations deletes the volumes left in error by the CreateVolume calls interrupted by the previous
+// shutdown, as the retried calls would find them by name and return them. The volumes still being created are kept,
+// the retried calls return them once they are available. The other operations are resumed by the retries of the
+// container orchestrator.
+func (cs *controllerServer) cleanupInterruptedOperations(ops []interruptedOperation) {
+	for _, op := range ops {
+		if op.Method != createVolumeMethod || op.Name == "" {
+			continue
+		}
+		for cloudName, cloud := range cs.Clouds {
+			vols, err := cloud.GetVolumesByName(op.Name)
+			if err != nil {
+				klog.Errorf("Failed to get the volumes %s of the interrupted CreateVolume from cloud %q: %v", op.Name, cloudName, err)
+				continue
+			}
+			for _, vol := range vols {
+				if vol.Status != volumeStatusError {
+					continue
+				}
+				if err := cloud.DeleteVolume(vol.ID); err != nil {
+					klog.Errorf("Failed to delete volume %s left in error by the interrupted CreateVolume of %s: %v", vol.ID, op.Name, err)
+					continue
+				}
+				klog.Infof("Deleted volume %s left in error by the interrupted CreateVolume of %s", vol.ID, op.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var publishInfo = &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/ControllerPublishVolume"}
+
+func TestShutdownManagerDrain(t *testing.T) {
+	m := newShutdownManager("")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	result := make(chan error)
+	go func() {
+		_, err := m.intercept(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol"}, publishInfo, func(context.Context, interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		result <- err
+	}()
+	<-started
+
+	drained := make(chan []interruptedOperation)
+	go func() {
+		drained <- m.drain(5 * time.Second)
+	}()
+
+	// Wait for the drain to start, the new operations are rejected.
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.draining
+	}, time.Second, 10*time.Millisecond)
+	_, err := m.intercept(context.Background(), &csi.ControllerPublishVolumeRequest{}, publishInfo, func(context.Context, interface{}) (interface{}, error) {
+		t.Fatal("operation accepted while draining")
+		return nil, nil
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// The in-flight operation completes, the drain does not time out.
+	close(release)
+	assert.NoError(t, <-result)
+	assert.Empty(t, <-drained)
+}
+
+func TestShutdownManagerJournal(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "journal.json")
+	m := newShutdownManager(journal)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go func() {
+		_, _ = m.intercept(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol", NodeId: "node"}, publishInfo, func(context.Context, interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+	}()
+	<-started
+
+	interrupted := m.drain(10 * time.Millisecond)
+	require.Len(t, interrupted, 1)
+	assert.Equal(t, publishInfo.FullMethod, interrupted[0].Method)
+	assert.Equal(t, "vol", interrupted[0].VolumeID)
+	assert.Equal(t, "node", interrupted[0].NodeID)
+
+	// The next start reports the interrupted operation and removes the journal.
+	recovered, err := newShutdownManager(journal).recoverJournal()
+	require.NoError(t, err)
+	require.Len(t, recovered, 1)
+	assert.Equal(t, interrupted[0].Method, recovered[0].Method)
+	assert.Equal(t, interrupted[0].VolumeID, recovered[0].VolumeID)
+	_, err = os.Stat(journal)
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing to recover without a journal.
+	recovered, err = newShutdownManager(journal).recoverJournal()
+	assert.NoError(t, err)
+	assert.Empty(t, recovered)
+}
+
+func TestCleanupInterruptedOperations(t *testing.T) {
+	const name = "pvc-interrupted"
+	osmock.On("GetVolumesByName", name).Return([]volumes.Volume{
+		{ID: "interrupted-error", Name: name, Status: "error"},
+		{ID: "interrupted-creating", Name: name, Status: "creating"},
+	}, nil).Once()
+	osmock.On("DeleteVolume", "interrupted-error").Return(nil).Once()
+
+	fakeCs.cleanupInterruptedOperations([]interruptedOperation{
+		{Method: createVolumeMethod, Name: name},
+		// The other operations are resumed by the retries
+		{Method: publishInfo.FullMethod, VolumeID: "interrupted-error", NodeID: "node"},
+	})
+
+	osmock.AssertCalled(t, "DeleteVolume", "interrupted-error")
+	osmock.AssertNotCalled(t, "DeleteVolume", "interrupted-creating")
+}
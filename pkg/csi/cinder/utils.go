@@ -4,21 +4,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/cloud-provider-openstack/pkg/util/mount"
 	"k8s.io/klog/v2"
 )
 
-var (
-	serverGRPCEndpointCallCounter uint64
-)
-
 func NewControllerServiceCapability(cap csi.ControllerServiceCapability_RPC_Type) *csi.ControllerServiceCapability {
 	return &csi.ControllerServiceCapability{
 		Type: &csi.ControllerServiceCapability_Rpc{
@@ -62,23 +59,25 @@ func NewNodeServer(d *Driver, mount mount.IMount, metadata metadata.IMetadata, o
 		opts.NodeVolumeAttachLimit = maxVolumesPerNode
 	}
 
-	return &nodeServer{
+	ns := &nodeServer{
 		Driver:     d,
 		Mount:      mount,
 		Metadata:   metadata,
 		Topologies: topologies,
 		Opts:       opts,
+
+		flavorAttachLimits: parseFlavorAttachLimits(opts.FlavorAttachLimits),
 	}
-}
 
-//revive:enable:unexported-return
+	if opts.NodeVolumeStatsCacheTTL.Duration > 0 {
+		ns.statsCache = newVolumeStatsCache(opts.NodeVolumeStatsCacheTTL.Duration, opts.NodeVolumeStatsBudget.Duration, opts.NodeVolumeStatsConcurrency, mount.GetDeviceStats)
+	}
 
-func RunServicesInitialized(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
-	s := NewNonBlockingGRPCServer()
-	s.Start(endpoint, ids, cs, ns)
-	s.Wait()
+	return ns
 }
 
+//revive:enable:unexported-return
+
 func ParseEndpoint(ep string) (string, string, error) {
 	if strings.HasPrefix(strings.ToLower(ep), "unix://") || strings.HasPrefix(strings.ToLower(ep), "tcp://") {
 		s := strings.SplitN(ep, "://", 2)
@@ -89,17 +88,21 @@ func ParseEndpoint(ep string) (string, string, error) {
 	return "", "", fmt.Errorf("Invalid endpoint: %v", ep)
 }
 
+// logGRPC logs the CSI calls with their secrets stripped and observes their duration. Each call gets a request ID,
+// which is sent with the OpenStack requests of the call.
 func logGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	callID := atomic.AddUint64(&serverGRPCEndpointCallCounter, 1)
+	requestID := newRequestID()
+	start := time.Now()
 
-	klog.V(3).Infof("[ID:%d] GRPC call: %s", callID, info.FullMethod)
-	klog.V(5).Infof("[ID:%d] GRPC request: %s", callID, protosanitizer.StripSecrets(req))
-	resp, err := handler(ctx, req)
+	klog.V(3).Infof("[ID:%s] GRPC call: %s", requestID, info.FullMethod)
+	klog.V(5).Infof("[ID:%s] GRPC request: %s", requestID, protosanitizer.StripSecrets(req))
+	resp, err := handler(withRequestID(ctx, requestID), req)
 	if err != nil {
-		klog.Errorf("[ID:%d] GRPC error: %v", callID, err)
+		klog.Errorf("[ID:%s] GRPC error: %v", requestID, err)
 	} else {
-		klog.V(5).Infof("[ID:%d] GRPC response: %s", callID, protosanitizer.StripSecrets(resp))
+		klog.V(5).Infof("[ID:%s] GRPC response: %s", requestID, protosanitizer.StripSecrets(resp))
 	}
+	grpcRequestDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
 
 	return resp, err
 }
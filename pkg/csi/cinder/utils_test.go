@@ -99,7 +99,11 @@ func TestLogGRPC(t *testing.T) {
 	buf := new(bytes.Buffer)
 	klog.SetOutput(buf)
 
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	var requestID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		requestID = requestIDFromContext(ctx)
+		return nil, nil
+	}
 	info := grpc.UnaryServerInfo{
 		FullMethod: "fake",
 	}
@@ -137,7 +141,8 @@ func TestLogGRPC(t *testing.T) {
 			klog.Flush()
 
 			// ASSERT
-			assert.Contains(t, buf.String(), "GRPC call: fake")
+			assert.Regexp(t, "^req-[0-9a-f-]{36}$", requestID)
+			assert.Contains(t, buf.String(), "[ID:"+requestID+"] GRPC call: fake")
 			assert.Contains(t, buf.String(), test.expStr)
 			assert.Contains(t, buf.String(), "GRPC response: null")
 
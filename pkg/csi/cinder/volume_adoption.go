@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+// isVolumeAdopted checks whether the volume carries the cluster metadata, as the volumes provisioned by the driver
+// and the adopted ones.
+func isVolumeAdopted(vol *volumes.Volume) bool {
+	_, ok := vol.Metadata[cinderCSIClusterIDKey]
+	return ok
+}
+
+// checkVolumeOwnership checks that the volume isn't tagged with the cluster metadata of another cluster.
+func checkVolumeOwnership(vol *volumes.Volume, clusterID string) error {
+	if owner, ok := vol.Metadata[cinderCSIClusterIDKey]; ok && owner != clusterID {
+		return fmt.Errorf("volume %s belongs to cluster %q", vol.ID, owner)
+	}
+	return nil
+}
+
+// adoptVolume validates a volume without the cluster metadata, e.g. the volume of a statically provisioned
+// PersistentVolume, before its first attachment, then tags it with the cluster metadata so that it's handled as the
+// provisioned volumes. It only runs with adopt-volumes, as the volumes provisioned by the previous releases or with
+// another --cluster would otherwise be refused. The volume can't be attached to another instance, which could be
+// outside of the cluster, and must be in the availability zone of the instance unless ignore-volume-az is set.
+func adoptVolume(cloud openstack.IOpenStack, clusterID string, vol *volumes.Volume, instance *servers.Server) error {
+	if isVolumeAdopted(vol) {
+		if err := checkVolumeOwnership(vol, clusterID); err != nil {
+			return status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] %v", err)
+		}
+		return nil
+	}
+
+	for _, att := range vol.Attachments {
+		if att.ServerID != instance.ID {
+			return status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] Volume %s can't be adopted, it is attached to instance %s", vol.ID, att.ServerID)
+		}
+	}
+	if !cloud.GetBlockStorageOpts().IgnoreVolumeAZ && instance.AvailabilityZone != "" && vol.AvailabilityZone != instance.AvailabilityZone {
+		return status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] Volume %s can't be adopted, its availability zone %s differs from the one of instance %s: %s", vol.ID, vol.AvailabilityZone, instance.ID, instance.AvailabilityZone)
+	}
+
+	klog.V(2).Infof("Adopting volume %s in cluster %q", vol.ID, clusterID)
+	if err := cloud.UpdateVolumeMetadata(vol.ID, map[string]string{cinderCSIClusterIDKey: clusterID}); err != nil {
+		return status.Errorf(codes.Internal, "[ControllerPublishVolume] failed to set the cluster metadata of volume %s: %v", vol.ID, err)
+	}
+	return nil
+}
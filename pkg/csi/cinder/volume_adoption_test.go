@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func TestAdoptVolume(t *testing.T) {
+	instance := &servers.Server{ID: "instance", AvailabilityZone: "nova"}
+
+	testCases := []struct {
+		name    string
+		vol     *volumes.Volume
+		code    codes.Code
+		adopted bool
+	}{
+		{
+			name: "volume of the cluster",
+			vol:  &volumes.Volume{ID: "vol", AvailabilityZone: "nova", Metadata: map[string]string{cinderCSIClusterIDKey: FakeCluster}},
+			code: codes.OK,
+		},
+		{
+			name: "volume of another cluster",
+			vol:  &volumes.Volume{ID: "vol", AvailabilityZone: "nova", Metadata: map[string]string{cinderCSIClusterIDKey: "other"}},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name:    "pre-provisioned volume",
+			vol:     &volumes.Volume{ID: "vol", AvailabilityZone: "nova"},
+			code:    codes.OK,
+			adopted: true,
+		},
+		{
+			name:    "pre-provisioned volume attached to the instance",
+			vol:     &volumes.Volume{ID: "vol", AvailabilityZone: "nova", Attachments: []volumes.Attachment{{ServerID: "instance"}}},
+			code:    codes.OK,
+			adopted: true,
+		},
+		{
+			name: "pre-provisioned volume attached elsewhere",
+			vol:  &volumes.Volume{ID: "vol", AvailabilityZone: "nova", Multiattach: true, Attachments: []volumes.Attachment{{ServerID: "other"}}},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "pre-provisioned volume in another availability zone",
+			vol:  &volumes.Volume{ID: "vol", AvailabilityZone: "az2"},
+			code: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cloud := new(openstack.OpenStackMock)
+			if tc.adopted {
+				cloud.On("UpdateVolumeMetadata", "vol", map[string]string{cinderCSIClusterIDKey: FakeCluster}).Return(nil)
+			}
+
+			err := adoptVolume(cloud, FakeCluster, tc.vol, instance)
+			assert.Equal(t, tc.code, status.Code(err))
+			cloud.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCheckVolumeOwnership(t *testing.T) {
+	assert.NoError(t, checkVolumeOwnership(&volumes.Volume{}, FakeCluster))
+	assert.NoError(t, checkVolumeOwnership(&volumes.Volume{Metadata: map[string]string{cinderCSIClusterIDKey: FakeCluster}}, FakeCluster))
+	assert.Error(t, checkVolumeOwnership(&volumes.Volume{Metadata: map[string]string{cinderCSIClusterIDKey: "other"}}, FakeCluster))
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/keymutex"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+// The capacity limits of the namespaces. Cinder quotas can't enforce them as they apply to the whole project.
+const (
+	// namespaceCapacityLimitKey is the StorageClass parameter limiting the total size in GiB of the volumes of the
+	// cluster in a namespace, among the volumes of the same volume type.
+	namespaceCapacityLimitKey = "namespaceCapacityLimit"
+
+	// NamespaceCapacityLimitAnnotation is the annotation of a Namespace limiting the total size in GiB of the volumes
+	// of the cluster in the namespace, of any volume type.
+	NamespaceCapacityLimitAnnotation = "cinder.csi.openstack.org/capacity-limit"
+
+	// volumeCapacityLimitKey is the volume property recording the namespaceCapacityLimit the volume was created with,
+	// checked again when the volume is expanded.
+	volumeCapacityLimitKey = "cinder.csi.openstack.org/namespace-capacity-limit"
+)
+
+// namespaceCapacityLocks serializes the creation and the expansion of the volumes of each namespace with capacity
+// limits, so that each check sees the sizes of the volumes created or expanded before.
+var namespaceCapacityLocks = keymutex.NewHashed(0)
+
+// lockNamespaceCapacity locks the capacity of the namespace if any of the limits applies, it returns the unlock
+// function.
+func lockNamespaceCapacity(namespace string, limits ...*capacityLimit) func() {
+	if !slices.ContainsFunc(limits, func(l *capacityLimit) bool { return l != nil }) {
+		return func() {}
+	}
+	namespaceCapacityLocks.LockKey(namespace)
+	return func() {
+		_ = namespaceCapacityLocks.UnlockKey(namespace)
+	}
+}
+
+// capacityLimit is the maximum total size of the volumes of a namespace.
+type capacityLimit struct {
+	sizeGB int
+	// volTypeID restricts the limit to the volumes of a volume type, all the volumes are counted when empty.
+	volTypeID string
+}
+
+// String formats the limit as recorded in the volumeCapacityLimitKey property, the size in GiB followed by the ID of
+// the volume type if any, e.g. "100:9f2b...".
+func (l *capacityLimit) String() string {
+	if l.volTypeID == "" {
+		return strconv.Itoa(l.sizeGB)
+	}
+	return fmt.Sprintf("%d:%s", l.sizeGB, l.volTypeID)
+}
+
+// parseCapacityLimitSize parses a size in GiB of a capacity limit.
+func parseCapacityLimitSize(s string) (int, error) {
+	size, err := strconv.Atoi(s)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("%q must be a positive number of GiB", s)
+	}
+	return size, nil
+}
+
+// parseCapacityLimit parses a limit formatted by capacityLimit.String.
+func parseCapacityLimit(s string) (*capacityLimit, error) {
+	sizeStr, volTypeID, _ := strings.Cut(s, ":")
+	size, err := parseCapacityLimitSize(sizeStr)
+	if err != nil {
+		return nil, err
+	}
+	return &capacityLimit{sizeGB: size, volTypeID: volTypeID}, nil
+}
+
+// getVolumeTypeIDs maps the names and the IDs of the volume types to their IDs. The volumes report the name of their
+// volume type while the StorageClasses may set either.
+func getVolumeTypeIDs(cloud openstack.IOpenStack) (map[string]string, error) {
+	volTypes, err := cloud.ListVolumeTypes()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list volume types: %v", err)
+	}
+	ids := make(map[string]string, 2*len(volTypes))
+	for _, t := range volTypes {
+		ids[t.Name] = t.ID
+		ids[t.ID] = t.ID
+	}
+	return ids, nil
+}
+
+// getNamespaceCapacityLimit returns the limit set by the NamespaceCapacityLimitAnnotation of the namespace, nil when
+// the annotations of the namespaces aren't enabled or the annotation isn't set.
+func (cs *controllerServer) getNamespaceCapacityLimit(ctx context.Context, namespace string) (*capacityLimit, error) {
+	if !cs.Driver.namespaceCapacityLimits || cs.Driver.kclient == nil || namespace == "" {
+		return nil, nil
+	}
+	ns, err := cs.Driver.kclient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get namespace %s: %v", namespace, err)
+	}
+	value, ok := ns.Annotations[NamespaceCapacityLimitAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	size, err := parseCapacityLimitSize(value)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s annotation of namespace %s: %v", NamespaceCapacityLimitAnnotation, namespace, err)
+	}
+	return &capacityLimit{sizeGB: size}, nil
+}
+
+// getSnapshotSizes returns the total size in GiB of the snapshots of each volume. The snapshots use the gigabytes of
+// the volume type of their volume, the backups don't.
+func getSnapshotSizes(cloud openstack.IOpenStack) (map[string]int, error) {
+	sizes := map[string]int{}
+	filters := map[string]string{}
+	for {
+		snaps, nextPageToken, err := cloud.ListSnapshots(filters)
+		if err != nil {
+			return nil, err
+		}
+		for _, snap := range snaps {
+			sizes[snap.VolumeID] += snap.Size
+		}
+		if nextPageToken == "" {
+			return sizes, nil
+		}
+		filters = map[string]string{"Marker": nextPageToken}
+	}
+}
+
+// checkCapacityLimits checks that the volumes of the cluster in the namespace and their snapshots don't exceed the
+// limits once a volume has the given size. The volume is excluded from the volumes counted, it's empty for a new
+// volume. The clones are counted as the other volumes of the namespace.
+func checkCapacityLimits(cloud openstack.IOpenStack, clusterID, namespace, volumeID string, volSizeGB int, limits ...*capacityLimit) error {
+	var volTypeIDs map[string]string
+	for _, limit := range limits {
+		if limit != nil && limit.volTypeID != "" && volTypeIDs == nil {
+			var err error
+			if volTypeIDs, err = getVolumeTypeIDs(cloud); err != nil {
+				return err
+			}
+		}
+	}
+
+	var vols []volumes.Volume
+	var snapshotSizes map[string]int
+	for _, limit := range limits {
+		if limit == nil {
+			continue
+		}
+		if vols == nil {
+			metadata := map[string]string{sharedcsi.PvcNamespaceKey: namespace}
+			if clusterID != "" {
+				metadata[cinderCSIClusterIDKey] = clusterID
+			}
+			var err error
+			if vols, err = cloud.GetVolumesByMetadata(metadata); err != nil {
+				klog.Errorf("Failed to list the volumes of namespace %s: %v", namespace, err)
+				return status.Errorf(codes.Internal, "Failed to get volumes: %v", err)
+			}
+			if snapshotSizes, err = getSnapshotSizes(cloud); err != nil {
+				klog.Errorf("Failed to list the snapshots of namespace %s: %v", namespace, err)
+				return status.Errorf(codes.Internal, "Failed to get snapshots: %v", err)
+			}
+		}
+
+		used := 0
+		for _, vol := range vols {
+			if limit.volTypeID != "" && volTypeIDs[vol.VolumeType] != limit.volTypeID {
+				continue
+			}
+			used += snapshotSizes[vol.ID]
+			if vol.ID != volumeID || volumeID == "" {
+				used += vol.Size
+			}
+		}
+		if used+volSizeGB > limit.sizeGB {
+			scope := "of any volume type"
+			if limit.volTypeID != "" {
+				scope = "of volume type " + limit.volTypeID
+			}
+			return status.Errorf(codes.ResourceExhausted, "a volume of %d GiB exceeds the capacity limit of namespace %s: %d GiB of %d GiB used by the volumes %s", volSizeGB, namespace, used, limit.sizeGB, scope)
+		}
+	}
+	return nil
+}
+
+// checkCreateVolumeCapacityLimits checks that creating a volume of the given size doesn't exceed the namespaceCapacityLimit
+// of the StorageClass, counted among the volumes with the same volume type or all of them when the StorageClass sets
+// no type, nor the NamespaceCapacityLimitAnnotation of the namespace of the PVC. It returns the limit of the
+// StorageClass to record in the volume properties, and the function unlocking the capacity of the namespace once the
+// volume is created.
+func (cs *controllerServer) checkCreateVolumeCapacityLimits(ctx context.Context, cloud openstack.IOpenStack, volParams map[string]string, volType string, volSizeGB int) (*capacityLimit, func(), error) {
+	namespace := volParams[sharedcsi.PvcNamespaceKey]
+
+	var limit *capacityLimit
+	if limitParam, ok := volParams[namespaceCapacityLimitKey]; ok {
+		size, err := parseCapacityLimitSize(limitParam)
+		if err != nil {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] invalid %s: %v", namespaceCapacityLimitKey, err)
+		}
+		if namespace == "" {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] %s requires the --extra-create-metadata flag in csi-provisioner", namespaceCapacityLimitKey)
+		}
+		limit = &capacityLimit{sizeGB: size}
+		if volType != "" {
+			volTypeIDs, err := getVolumeTypeIDs(cloud)
+			if err != nil {
+				return nil, nil, err
+			}
+			if limit.volTypeID = volTypeIDs[volType]; limit.volTypeID == "" {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] volume type %s not found", volType)
+			}
+		}
+	}
+
+	nsLimit, err := cs.getNamespaceCapacityLimit(ctx, namespace)
+	if err != nil {
+		return nil, nil, status.Errorf(status.Code(err), "[CreateVolume] %v", status.Convert(err).Message())
+	}
+
+	unlock := lockNamespaceCapacity(namespace, limit, nsLimit)
+	if err := checkCapacityLimits(cloud, cs.Driver.clusterID, namespace, "", volSizeGB, limit, nsLimit); err != nil {
+		unlock()
+		return nil, nil, status.Errorf(status.Code(err), "[CreateVolume] %v", status.Convert(err).Message())
+	}
+	return limit, unlock, nil
+}
+
+// checkExpandVolumeCapacityLimits checks that expanding the volume to the given size doesn't exceed the
+// namespaceCapacityLimit it was created with nor the NamespaceCapacityLimitAnnotation of its namespace. It returns the
+// function unlocking the capacity of the namespace once the volume is expanded.
+func (cs *controllerServer) checkExpandVolumeCapacityLimits(ctx context.Context, cloud openstack.IOpenStack, volume *volumes.Volume, volSizeGB int) (func(), error) {
+	namespace := volume.Metadata[sharedcsi.PvcNamespaceKey]
+	if namespace == "" {
+		return func() {}, nil
+	}
+
+	var limit *capacityLimit
+	if value, ok := volume.Metadata[volumeCapacityLimitKey]; ok {
+		var err error
+		if limit, err = parseCapacityLimit(value); err != nil {
+			return nil, status.Errorf(codes.Internal, "[ControllerExpandVolume] invalid %s property of volume %s: %v", volumeCapacityLimitKey, volume.ID, err)
+		}
+	}
+
+	nsLimit, err := cs.getNamespaceCapacityLimit(ctx, namespace)
+	if err != nil {
+		return nil, status.Errorf(status.Code(err), "[ControllerExpandVolume] %v", status.Convert(err).Message())
+	}
+
+	unlock := lockNamespaceCapacity(namespace, limit, nsLimit)
+	if err := checkCapacityLimits(cloud, cs.Driver.clusterID, namespace, volume.ID, volSizeGB, limit, nsLimit); err != nil {
+		unlock()
+		return nil, status.Errorf(status.Code(err), "[ControllerExpandVolume] volume %s can't be expanded to %d GiB: %v", volume.ID, volSizeGB, status.Convert(err).Message())
+	}
+	return unlock, nil
+}
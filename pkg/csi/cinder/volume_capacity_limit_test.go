@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func newCapacityLimitCloud() *openstack.OpenStackMock {
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumeTypes").Return([]volumetypes.VolumeType{
+		{ID: "ssd-id", Name: "ssd"},
+		{ID: "hdd-id", Name: "hdd"},
+	}, nil)
+	// The volumes report the name of their volume type
+	cloud.On("GetVolumesByMetadata", map[string]string{cinderCSIClusterIDKey: FakeCluster, sharedcsi.PvcNamespaceKey: "ns"}).Return([]volumes.Volume{
+		{ID: "vol-ssd", Size: 4, VolumeType: "ssd"},
+		{ID: "vol-hdd", Size: 3, VolumeType: "hdd"},
+	}, nil)
+	cloud.On("ListSnapshots", map[string]string{}).Return([]snapshots.Snapshot{
+		{ID: "snap-hdd", VolumeID: "vol-hdd", Size: 1},
+	}, "next", nil)
+	cloud.On("ListSnapshots", map[string]string{"Marker": "next"}).Return([]snapshots.Snapshot{
+		{ID: "snap-hdd-2", VolumeID: "vol-hdd", Size: 1},
+		{ID: "snap-other", VolumeID: "vol-other", Size: 50},
+	}, "", nil)
+	return cloud
+}
+
+func newCapacityLimitControllerServer(annotations map[string]string) *controllerServer {
+	kclient := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ns", Annotations: annotations},
+	})
+	d := NewDriver(&DriverOpts{Endpoint: FakeEndpoint, ClusterID: FakeCluster, KubeClient: kclient, NamespaceCapacityLimits: annotations != nil})
+	return &controllerServer{Driver: d}
+}
+
+func TestCheckCreateVolumeCapacityLimits(t *testing.T) {
+	cloud := newCapacityLimitCloud()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		params      map[string]string
+		volType     string
+		size        int
+		code        codes.Code
+		expected    string
+	}{
+		{name: "no limit", params: map[string]string{}, size: 100, code: codes.OK},
+		{name: "within the limit of the type", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, volType: "ssd", size: 6, code: codes.OK, expected: "10:ssd-id"},
+		{name: "within the limit of the type ID", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, volType: "ssd-id", size: 6, code: codes.OK, expected: "10:ssd-id"},
+		{name: "exceeds the limit of the type", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, volType: "ssd-id", size: 7, code: codes.ResourceExhausted},
+		{name: "within the limit of all types", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, size: 1, code: codes.OK, expected: "10"},
+		// The snapshots of the volumes are counted
+		{name: "exceeds the limit of all types", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, size: 2, code: codes.ResourceExhausted},
+		{name: "exceeds the limit of the type with the snapshots", params: map[string]string{namespaceCapacityLimitKey: "5", sharedcsi.PvcNamespaceKey: "ns"}, volType: "hdd", size: 1, code: codes.ResourceExhausted},
+		{name: "unknown type", params: map[string]string{namespaceCapacityLimitKey: "10", sharedcsi.PvcNamespaceKey: "ns"}, volType: "nvme", size: 1, code: codes.InvalidArgument},
+		{name: "invalid limit", params: map[string]string{namespaceCapacityLimitKey: "10Gi", sharedcsi.PvcNamespaceKey: "ns"}, size: 1, code: codes.InvalidArgument},
+		{name: "missing namespace", params: map[string]string{namespaceCapacityLimitKey: "10"}, size: 1, code: codes.InvalidArgument},
+		{name: "within the limit of the namespace", annotations: map[string]string{NamespaceCapacityLimitAnnotation: "20"}, params: map[string]string{sharedcsi.PvcNamespaceKey: "ns"}, size: 11, code: codes.OK},
+		{name: "exceeds the limit of the namespace", annotations: map[string]string{NamespaceCapacityLimitAnnotation: "20"}, params: map[string]string{namespaceCapacityLimitKey: "100", sharedcsi.PvcNamespaceKey: "ns"}, volType: "ssd", size: 14, code: codes.ResourceExhausted},
+		{name: "invalid limit of the namespace", annotations: map[string]string{NamespaceCapacityLimitAnnotation: "-1"}, params: map[string]string{sharedcsi.PvcNamespaceKey: "ns"}, size: 1, code: codes.InvalidArgument},
+		{name: "namespace without limit", annotations: map[string]string{}, params: map[string]string{sharedcsi.PvcNamespaceKey: "ns"}, size: 100, code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newCapacityLimitControllerServer(tt.annotations)
+			limit, unlock, err := cs.checkCreateVolumeCapacityLimits(context.TODO(), cloud, tt.params, tt.volType, tt.size)
+			assert.Equal(t, tt.code, status.Code(err), "%v", err)
+			if err == nil {
+				unlock()
+			}
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, limit.String())
+			}
+		})
+	}
+}
+
+func TestCheckExpandVolumeCapacityLimits(t *testing.T) {
+	cloud := newCapacityLimitCloud()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		metadata    map[string]string
+		size        int
+		code        codes.Code
+	}{
+		{name: "no namespace", metadata: map[string]string{volumeCapacityLimitKey: "1"}, size: 100, code: codes.OK},
+		{name: "no limit", metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns"}, size: 100, code: codes.OK},
+		// The current size of the volume isn't counted
+		{name: "within the limit of the type", metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns", volumeCapacityLimitKey: "10:ssd-id"}, size: 10, code: codes.OK},
+		{name: "exceeds the limit of the type", metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns", volumeCapacityLimitKey: "10:ssd-id"}, size: 11, code: codes.ResourceExhausted},
+		{name: "exceeds the limit of all types", metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns", volumeCapacityLimitKey: "10"}, size: 8, code: codes.ResourceExhausted},
+		{name: "exceeds the limit of the namespace", annotations: map[string]string{NamespaceCapacityLimitAnnotation: "12"}, metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns"}, size: 10, code: codes.ResourceExhausted},
+		{name: "invalid property", metadata: map[string]string{sharedcsi.PvcNamespaceKey: "ns", volumeCapacityLimitKey: "ten"}, size: 10, code: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newCapacityLimitControllerServer(tt.annotations)
+			vol := &volumes.Volume{ID: "vol-ssd", Size: 4, VolumeType: "ssd", Metadata: tt.metadata}
+			unlock, err := cs.checkExpandVolumeCapacityLimits(context.TODO(), cloud, vol, tt.size)
+			assert.Equal(t, tt.code, status.Code(err), "%v", err)
+			if err == nil {
+				unlock()
+			}
+		})
+	}
+}
+
+func TestCheckCapacityLimitsSerialized(t *testing.T) {
+	cloud := newCapacityLimitCloud()
+	cs := newCapacityLimitControllerServer(map[string]string{NamespaceCapacityLimitAnnotation: "100"})
+	params := map[string]string{sharedcsi.PvcNamespaceKey: "ns"}
+
+	_, unlock, err := cs.checkCreateVolumeCapacityLimits(context.TODO(), cloud, params, "", 1)
+	assert.NoError(t, err)
+
+	// The expansion waits for the volume being created.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vol := &volumes.Volume{ID: "vol-ssd", Size: 4, VolumeType: "ssd", Metadata: params}
+		unlockExpand, err := cs.checkExpandVolumeCapacityLimits(context.TODO(), cloud, vol, 5)
+		if assert.NoError(t, err) {
+			unlockExpand()
+		}
+	}()
+	select {
+	case <-done:
+		t.Fatal("the capacity of the namespace isn't locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	<-done
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+// StorageClass parameters requesting an encrypted volume. The encrypted parameter is also set in the volume context
+// of the encrypted volumes.
+const (
+	encryptedKey                 = "encrypted"
+	encryptionProviderKey        = "encryption-provider"
+	encryptionCipherKey          = "encryption-cipher"
+	encryptionKeySizeKey         = "encryption-key-size"
+	encryptionControlLocationKey = "encryption-control-location"
+)
+
+// volumeEncryption is the encryption requested for a volume, the empty fields match any volume type encryption.
+type volumeEncryption struct {
+	provider        string
+	cipher          string
+	keySize         int
+	controlLocation string
+}
+
+// getVolumeEncryption parses the encryption parameters, it returns nil if the volume doesn't need to be encrypted.
+func getVolumeEncryption(params map[string]string) (*volumeEncryption, error) {
+	encryption := &volumeEncryption{
+		provider:        params[encryptionProviderKey],
+		cipher:          params[encryptionCipherKey],
+		controlLocation: params[encryptionControlLocationKey],
+	}
+	if v, ok := params[encryptionKeySizeKey]; ok {
+		keySize, err := strconv.Atoi(v)
+		if err != nil || keySize <= 0 {
+			return nil, fmt.Errorf("invalid %s parameter %q", encryptionKeySizeKey, v)
+		}
+		encryption.keySize = keySize
+	}
+
+	encrypted := false
+	if v, ok := params[encryptedKey]; ok {
+		var err error
+		if encrypted, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %q", encryptedKey, v)
+		}
+	}
+
+	if !encrypted {
+		if *encryption != (volumeEncryption{}) {
+			return nil, fmt.Errorf("encryption parameters require the %s parameter to be true", encryptedKey)
+		}
+		return nil, nil
+	}
+
+	return encryption, nil
+}
+
+// matches checks whether the volume type encryption satisfies the requested encryption.
+func (e *volumeEncryption) matches(encryption *volumetypes.GetEncryptionType) bool {
+	if encryption == nil {
+		return false
+	}
+	if e.provider != "" && e.provider != encryption.Provider {
+		return false
+	}
+	if e.cipher != "" && e.cipher != encryption.Cipher {
+		return false
+	}
+	if e.keySize != 0 && e.keySize != encryption.KeySize {
+		return false
+	}
+	if e.controlLocation != "" && e.controlLocation != encryption.ControlLocation {
+		return false
+	}
+	return true
+}
+
+// getEncryptedVolumeType returns the volume type to create the encrypted volume with. When a volume type is set, it's
+// validated against the requested encryption, otherwise the first matching encrypted volume type is selected.
+func getEncryptedVolumeType(cloud openstack.IOpenStack, volType string, encryption *volumeEncryption) (string, error) {
+	volTypes, err := cloud.ListVolumeTypes()
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to list volume types: %v", err)
+	}
+
+	for _, t := range volTypes {
+		if volType != "" && volType != t.ID && volType != t.Name {
+			continue
+		}
+
+		typeEncryption, err := cloud.GetVolumeTypeEncryption(t.ID)
+		if err != nil {
+			// Reading the encryption of the volume types is usually restricted to the administrators, in which case
+			// the volume is checked once created.
+			if volType != "" && cpoerrors.IsForbiddenError(err) {
+				klog.V(4).Infof("Not allowed to get the encryption of volume type %s, the volume encryption will be checked once created", volType)
+				return volType, nil
+			}
+			return "", status.Errorf(codes.Internal, "failed to get the encryption of volume type %s: %v", t.ID, err)
+		}
+
+		if encryption.matches(typeEncryption) {
+			if volType != "" {
+				return volType, nil
+			}
+			klog.V(4).Infof("Selected encrypted volume type %s (%s)", t.Name, t.ID)
+			return t.ID, nil
+		}
+
+		if volType != "" {
+			return "", status.Errorf(codes.InvalidArgument, "volume type %s doesn't provide the requested encryption", volType)
+		}
+	}
+
+	if volType != "" {
+		return "", status.Errorf(codes.InvalidArgument, "volume type %s not found", volType)
+	}
+	return "", status.Error(codes.InvalidArgument, "no volume type provides the requested encryption")
+}
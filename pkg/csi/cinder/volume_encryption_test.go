@@ -0,0 +1,208 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func TestGetVolumeEncryption(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   map[string]string
+		expected *volumeEncryption
+		wantErr  bool
+	}{
+		{
+			name:   "not encrypted",
+			params: map[string]string{"type": "ssd"},
+		},
+		{
+			name:   "explicitly not encrypted",
+			params: map[string]string{encryptedKey: "false"},
+		},
+		{
+			name:     "encrypted",
+			params:   map[string]string{encryptedKey: "true"},
+			expected: &volumeEncryption{},
+		},
+		{
+			name: "encrypted with options",
+			params: map[string]string{
+				encryptedKey:                 "true",
+				encryptionProviderKey:        "luks",
+				encryptionCipherKey:          "aes-xts-plain64",
+				encryptionKeySizeKey:         "256",
+				encryptionControlLocationKey: "front-end",
+			},
+			expected: &volumeEncryption{provider: "luks", cipher: "aes-xts-plain64", keySize: 256, controlLocation: "front-end"},
+		},
+		{
+			name:    "invalid encrypted",
+			params:  map[string]string{encryptedKey: "yes please"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key size",
+			params:  map[string]string{encryptedKey: "true", encryptionKeySizeKey: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "options without encrypted",
+			params:  map[string]string{encryptionProviderKey: "luks"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encryption, err := getVolumeEncryption(tc.params)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, encryption)
+		})
+	}
+}
+
+func TestGetEncryptedVolumeType(t *testing.T) {
+	luks := &volumetypes.GetEncryptionType{EncryptionID: "enc-id", Provider: "luks", Cipher: "aes-xts-plain64", KeySize: 256, ControlLocation: "front-end"}
+	volTypes := []volumetypes.VolumeType{
+		{ID: "plain-id", Name: "plain"},
+		{ID: "luks-id", Name: "luks"},
+	}
+
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumeTypes").Return(volTypes, nil)
+	cloud.On("GetVolumeTypeEncryption", "plain-id").Return(nil, nil)
+	cloud.On("GetVolumeTypeEncryption", "luks-id").Return(luks, nil)
+
+	forbidden := new(openstack.OpenStackMock)
+	forbidden.On("ListVolumeTypes").Return(volTypes, nil)
+	forbidden.On("GetVolumeTypeEncryption", "luks-id").Return(nil, gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusForbidden})
+
+	testCases := []struct {
+		name       string
+		cloud      openstack.IOpenStack
+		volType    string
+		encryption *volumeEncryption
+		expected   string
+		code       codes.Code
+	}{
+		{
+			name:       "select the encrypted volume type",
+			cloud:      cloud,
+			encryption: &volumeEncryption{},
+			expected:   "luks-id",
+		},
+		{
+			name:       "validate the volume type by name",
+			cloud:      cloud,
+			volType:    "luks",
+			encryption: &volumeEncryption{provider: "luks", keySize: 256},
+			expected:   "luks",
+		},
+		{
+			name:       "volume type not encrypted",
+			cloud:      cloud,
+			volType:    "plain",
+			encryption: &volumeEncryption{},
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "volume type not matching the options",
+			cloud:      cloud,
+			volType:    "luks-id",
+			encryption: &volumeEncryption{keySize: 512},
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "no volume type matching the options",
+			cloud:      cloud,
+			encryption: &volumeEncryption{cipher: "aes-cbc-essiv"},
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "volume type not found",
+			cloud:      cloud,
+			volType:    "missing",
+			encryption: &volumeEncryption{},
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "encryption not readable",
+			cloud:      forbidden,
+			volType:    "luks",
+			encryption: &volumeEncryption{},
+			expected:   "luks",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volType, err := getEncryptedVolumeType(tc.cloud, tc.volType, tc.encryption)
+			if tc.code != codes.OK {
+				assert.Equal(t, tc.code, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, volType)
+		})
+	}
+}
+
+func TestGetCreateVolumeResponseEncrypted(t *testing.T) {
+	resp := getCreateVolumeResponse(&volumes.Volume{ID: FakeVolID, Encrypted: true}, nil, true, nil)
+	assert.Equal(t, "true", resp.Volume.VolumeContext[encryptedKey])
+
+	resp = getCreateVolumeResponse(&volumes.Volume{ID: FakeVolID}, nil, true, nil)
+	assert.NotContains(t, resp.Volume.VolumeContext, encryptedKey)
+}
+
+func TestDeleteRejectedVolume(t *testing.T) {
+	settled := []string{openstack.VolumeAvailableStatus, "error"}
+
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("WaitVolumeTargetStatus", "vol", settled).Return(nil)
+	cloud.On("DeleteVolume", "vol").Return(nil)
+	assert.NoError(t, deleteRejectedVolume(cloud, "vol"))
+	cloud.AssertCalled(t, "DeleteVolume", "vol")
+
+	// The volume is still being created, it can't be deleted yet
+	creating := new(openstack.OpenStackMock)
+	creating.On("WaitVolumeTargetStatus", "vol", settled).Return(errors.New("timed out"))
+	assert.Error(t, deleteRejectedVolume(creating, "vol"))
+	creating.AssertNotCalled(t, "DeleteVolume", "vol")
+
+	failed := new(openstack.OpenStackMock)
+	failed.On("WaitVolumeTargetStatus", "vol", settled).Return(nil)
+	failed.On("DeleteVolume", "vol").Return(errors.New("delete failed"))
+	assert.Error(t, deleteRejectedVolume(failed, "vol"))
+}
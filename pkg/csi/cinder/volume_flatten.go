@@ -0,0 +1,473 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+// VolumeFlattenedKey is the metadata key set on the volumes whose flattening completed, with the time it did.
+// The chains of snapshots and clones are not followed beyond these volumes, removing the key makes the volume
+// eligible again.
+const VolumeFlattenedKey = driverName + "/flattened"
+
+const (
+	// maxFlattensPerRun bounds the migrations running at once, each of them copies the full volume.
+	maxFlattensPerRun = 3
+
+	// The flattening of a volume that failed is retried after a delay doubling with each failure.
+	flattenRetryInitialDelay = time.Hour
+	flattenRetryMaxDelay     = 24 * time.Hour
+
+	// volumeFlattenLeaseName is the Lease held by the controller plugin replica flattening the volumes.
+	volumeFlattenLeaseName = "cinder-csi-volume-flattener"
+
+	eventVolumeFlattening   = "VolumeFlattening"
+	eventVolumeFlattened    = "VolumeFlattened"
+	eventVolumeFlattenError = "VolumeFlattenFailed"
+)
+
+// The migration statuses of the volumes being migrated, the migrations end in success or error.
+var migrationInProgress = sets.New[string]("starting", "migrating", "completing")
+
+var (
+	flattenCandidates = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "cinder_csi_volume_flatten_candidates",
+			Help: "Number of volumes whose snapshot and clone chain reached the flattening depth at the last check",
+		})
+	flattenTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "cinder_csi_volume_flatten_total",
+			Help: "Total number of completed volume flattenings, by result",
+		}, []string{"result"})
+
+	registerFlattenMetrics sync.Once
+)
+
+// MaintenanceWindow is a daily time range in UTC, it wraps around midnight when it ends before it starts.
+type MaintenanceWindow struct {
+	start, end time.Duration
+}
+
+// ParseMaintenanceWindow parses a window like "22:00-04:00", the empty string means no window.
+func ParseMaintenanceWindow(s string) (*MaintenanceWindow, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid maintenance window %q, expected HH:MM-HH:MM", s)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return nil, fmt.Errorf("invalid start of maintenance window %q: %v", s, err)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return nil, fmt.Errorf("invalid end of maintenance window %q: %v", s, err)
+	}
+	if start.Equal(end) {
+		return nil, fmt.Errorf("invalid maintenance window %q, it is empty", s)
+	}
+
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &MaintenanceWindow{start: start.Sub(midnight), end: end.Sub(midnight)}, nil
+}
+
+// contains checks whether t is in the window, a nil window contains any time.
+func (w *MaintenanceWindow) contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	t = t.UTC()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return now >= w.start && now < w.end
+	}
+	return now >= w.start || now < w.end
+}
+
+// volumeFlattener periodically looks for the volumes of the PVs at the end of deep chains of snapshots and clones,
+// which slow down the I/O on the backends cloning lazily, and flattens them with a migration copying their data.
+// The migrations are only triggered in the maintenance window and followed until they end, Events are emitted for
+// the PVs and their PVCs. Migrating the volumes and reading their migration status requires the admin role.
+type volumeFlattener struct {
+	clouds         map[string]openstack.IOpenStack
+	kclient        kubernetes.Interface
+	recorder       record.EventRecorder
+	interval       time.Duration
+	depth          int
+	window         *MaintenanceWindow
+	leaseNamespace string
+
+	// migrating holds the volumes being migrated by ID, only the run loop accesses it.
+	migrating map[string]*flattenCandidate
+	// retries holds the volumes whose flattening failed by ID, they are left to the other candidates until their
+	// retry time.
+	retries map[string]*flattenRetry
+}
+
+// flattenRetry is the backoff of a volume whose flattening failed.
+type flattenRetry struct {
+	failures  int
+	notBefore time.Time
+}
+
+func newVolumeFlattener(clouds map[string]openstack.IOpenStack, kclient kubernetes.Interface, interval time.Duration, depth int, window *MaintenanceWindow, leaseNamespace string) *volumeFlattener {
+	registerFlattenMetrics.Do(func() {
+		legacyregistry.MustRegister(flattenCandidates, flattenTotal)
+	})
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(klog.Infof)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kclient.CoreV1().Events("")})
+
+	return &volumeFlattener{
+		clouds:   clouds,
+		kclient:  kclient,
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: driverName}),
+		interval: interval,
+		depth:    depth,
+		window:   window,
+
+		leaseNamespace: leaseNamespace,
+		migrating:      make(map[string]*flattenCandidate),
+		retries:        make(map[string]*flattenRetry),
+	}
+}
+
+// run flattens the volumes until the channel is closed. Only the controller plugin replica holding the Lease
+// flattens the volumes, the others wait to take it over.
+func (f *volumeFlattener) run(stopCh <-chan struct{}) {
+	ctx := wait.ContextForChannel(stopCh)
+
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Errorf("Failed to start the volume flattening: %v", err)
+		return
+	}
+	// add a uniquifier so that two processes on the same host don't accidentally both become active
+	id = id + "_" + string(uuid.NewUUID())
+
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, f.leaseNamespace, volumeFlattenLeaseName,
+		f.kclient.CoreV1(), f.kclient.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		klog.Errorf("Failed to start the volume flattening: %v", err)
+		return
+	}
+
+	// The Lease is campaigned for again when it is lost
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   60 * time.Second,
+			RenewDeadline:   30 * time.Second,
+			RetryPeriod:     10 * time.Second,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: f.runLeading,
+				OnStoppedLeading: func() {
+					klog.Infof("Stopped leading the volume flattening")
+				},
+			},
+			Name: volumeFlattenLeaseName,
+		})
+	}, time.Second)
+}
+
+func (f *volumeFlattener) runLeading(ctx context.Context) {
+	klog.Infof("Flattening the volumes with chains of %d snapshots and clones every %v", f.depth, f.interval)
+	// The migrations triggered by the previous leader are found again by their migration status.
+	f.migrating = make(map[string]*flattenCandidate)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		// The running migrations are followed outside of the maintenance window too.
+		f.checkMigrations()
+		if !f.window.contains(time.Now()) {
+			klog.V(4).Info("Outside of the maintenance window, not flattening the volumes")
+			return
+		}
+		if err := f.flatten(ctx); err != nil {
+			klog.Warningf("Failed to flatten the volumes: %v", err)
+		}
+	}, f.interval)
+}
+
+// flattenCandidate is a volume of a PV whose chain reached the flattening depth.
+type flattenCandidate struct {
+	cloud   openstack.IOpenStack
+	vols    map[string]*volumes.Volume
+	parents map[string]string
+	vol     *volumes.Volume
+	pv      *corev1.PersistentVolume
+	depth   int
+}
+
+// flatten triggers the flattening of the volumes of the PVs with the shallowest chains reaching the depth first, as
+// flattening them shortens the chains of their descendants.
+func (f *volumeFlattener) flatten(ctx context.Context) error {
+	pvs, err := f.kclient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the PersistentVolumes: %v", err)
+	}
+
+	managed := make(map[string]*corev1.PersistentVolume)
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == driverName {
+			managed[pv.Spec.CSI.VolumeHandle] = pv
+		}
+	}
+
+	var candidates []*flattenCandidate
+	for name, cloud := range f.clouds {
+		vols := make(map[string]*volumes.Volume)
+		if err := listCloudVolumes(cloud, vols); err != nil {
+			return fmt.Errorf("failed to list the volumes of cloud %q: %v", name, err)
+		}
+
+		// The volumes being migrated are the roots of their chains already.
+		parents := make(map[string]string)
+		for id, c := range f.migrating {
+			if c.cloud == cloud {
+				parents[id] = ""
+			}
+		}
+		for id, pv := range managed {
+			vol, found := vols[id]
+			if !found {
+				continue
+			}
+			if depth := chainDepth(cloud, vols, parents, vol); depth >= f.depth {
+				candidates = append(candidates, &flattenCandidate{cloud: cloud, vols: vols, parents: parents, vol: vol, pv: pv, depth: depth})
+			}
+		}
+	}
+	flattenCandidates.Set(float64(len(candidates)))
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].depth != candidates[j].depth {
+			return candidates[i].depth < candidates[j].depth
+		}
+		return candidates[i].vol.ID < candidates[j].vol.ID
+	})
+
+	triggered := 0
+	now := time.Now()
+	for _, c := range candidates {
+		if triggered == maxFlattensPerRun || len(f.migrating) >= maxFlattensPerRun {
+			klog.V(4).Infof("Migrating %d volumes, the others are left to the next checks", len(f.migrating))
+			break
+		}
+		if _, ok := f.migrating[c.vol.ID]; ok {
+			continue
+		}
+		if retry, ok := f.retries[c.vol.ID]; ok && now.Before(retry.notBefore) {
+			klog.V(4).Infof("Not flattening volume %s before %v, its flattening failed %d times", c.vol.ID, retry.notBefore, retry.failures)
+			continue
+		}
+		if c.vol.Status != openstack.VolumeAvailableStatus && c.vol.Status != openstack.VolumeInUseStatus {
+			klog.V(4).Infof("Not flattening volume %s in %s state", c.vol.ID, c.vol.Status)
+			continue
+		}
+		// The volumes flattened meanwhile shortened the chain.
+		if depth := chainDepth(c.cloud, c.vols, c.parents, c.vol); depth < f.depth {
+			continue
+		}
+
+		// The volume may be migrated already, e.g. by the previous leader.
+		status, err := c.cloud.GetVolumeMigrationStatus(c.vol.ID)
+		if err == nil && migrationInProgress.Has(status) {
+			klog.V(4).Infof("Volume %s is being migrated already, following its migration", c.vol.ID)
+		} else if hasSnapshots, err := volumeHasSnapshots(c.cloud, c.vol.ID); err != nil || hasSnapshots {
+			// Cinder doesn't migrate the volumes with snapshots, they are flattened once the snapshots are deleted.
+			if err != nil {
+				klog.Warningf("Failed to list the snapshots of volume %s: %v", c.vol.ID, err)
+			} else {
+				klog.V(4).Infof("Not flattening volume %s, it has snapshots", c.vol.ID)
+			}
+			continue
+		} else if err := c.cloud.MigrateVolume(c.vol.ID); err != nil {
+			f.flattenFailed(c, openstack.FaultMessage(err))
+			continue
+		} else {
+			msg := fmt.Sprintf("Flattening Cinder volume %s with a chain of %d snapshots and clones by migrating it", c.vol.ID, c.depth)
+			klog.Info(msg)
+			f.recordEvent(c.pv, corev1.EventTypeNormal, eventVolumeFlattening, msg)
+		}
+
+		triggered++
+		f.migrating[c.vol.ID] = c
+		c.parents[c.vol.ID] = ""
+	}
+
+	return nil
+}
+
+// volumeHasSnapshots checks whether the volume has snapshots.
+func volumeHasSnapshots(cloud openstack.IOpenStack, volumeID string) (bool, error) {
+	snaps, _, err := cloud.ListSnapshots(map[string]string{"VolumeID": volumeID, "Limit": "1"})
+	if err != nil {
+		return false, err
+	}
+	return len(snaps) > 0, nil
+}
+
+// checkMigrations marks the volumes whose migration succeeded with VolumeFlattenedKey, and stops following the ones
+// whose migration failed. The migrations still running are checked again by the next checks.
+func (f *volumeFlattener) checkMigrations() {
+	for id, c := range f.migrating {
+		status, err := c.cloud.GetVolumeMigrationStatus(id)
+		if err != nil {
+			if cpoerrors.IsNotFound(err) {
+				klog.V(4).Infof("Volume %s was deleted while being migrated", id)
+				delete(f.migrating, id)
+				continue
+			}
+			klog.Warningf("Failed to get the migration status of volume %s: %v", id, err)
+			continue
+		}
+
+		switch {
+		case migrationInProgress.Has(status):
+			klog.V(4).Infof("Volume %s is still being migrated: %s", id, status)
+		case status == "success":
+			flattened := map[string]string{VolumeFlattenedKey: time.Now().UTC().Format(time.RFC3339)}
+			if err := c.cloud.UpdateVolumeMetadata(id, flattened); err != nil {
+				// The metadata is set again by the next check.
+				klog.Warningf("Failed to set the %s metadata of volume %s: %v", VolumeFlattenedKey, id, err)
+				continue
+			}
+			delete(f.migrating, id)
+			delete(f.retries, id)
+			flattenTotal.WithLabelValues("success").Inc()
+			msg := fmt.Sprintf("Flattened Cinder volume %s with a chain of %d snapshots and clones", id, c.depth)
+			klog.Info(msg)
+			f.recordEvent(c.pv, corev1.EventTypeNormal, eventVolumeFlattened, msg)
+		case status == "":
+			delete(f.migrating, id)
+			f.flattenFailed(c, "the migration status of the volume is not visible to the cloud user, which requires the admin role")
+		default:
+			delete(f.migrating, id)
+			f.flattenFailed(c, fmt.Sprintf("the migration ended in %s status", status))
+		}
+	}
+}
+
+// flattenFailed reports the failure of the flattening of the volume, which is retried after a delay.
+func (f *volumeFlattener) flattenFailed(c *flattenCandidate, reason string) {
+	if f.retries == nil {
+		f.retries = make(map[string]*flattenRetry)
+	}
+	retry, ok := f.retries[c.vol.ID]
+	if !ok {
+		retry = &flattenRetry{}
+		f.retries[c.vol.ID] = retry
+	}
+	delay := flattenRetryInitialDelay << min(retry.failures, 5)
+	retry.failures++
+	retry.notBefore = time.Now().Add(min(delay, flattenRetryMaxDelay))
+
+	flattenTotal.WithLabelValues("error").Inc()
+	msg := fmt.Sprintf("Failed to flatten Cinder volume %s with a chain of %d snapshots and clones: %s", c.vol.ID, c.depth, reason)
+	klog.Warning(msg)
+	f.recordEvent(c.pv, corev1.EventTypeWarning, eventVolumeFlattenError, msg)
+}
+
+// chainDepth returns the number of clones, directly or through a snapshot, between the volume and the root of its
+// chain, which has no source or is marked with VolumeFlattenedKey. The parent volume IDs are cached in parents.
+func chainDepth(cloud openstack.IOpenStack, vols map[string]*volumes.Volume, parents map[string]string, vol *volumes.Volume) int {
+	depth := 0
+	seen := make(map[string]bool)
+	for vol != nil && !seen[vol.ID] {
+		seen[vol.ID] = true
+		if _, ok := vol.Metadata[VolumeFlattenedKey]; ok {
+			break
+		}
+
+		parentID, ok := parents[vol.ID]
+		if !ok {
+			parentID = parentVolumeID(cloud, vol)
+			parents[vol.ID] = parentID
+		}
+		if parentID == "" {
+			break
+		}
+
+		// The parent may be deleted, it is still part of the chain on the backend.
+		depth++
+		vol = vols[parentID]
+	}
+
+	return depth
+}
+
+// parentVolumeID returns the ID of the volume the volume was cloned from, directly or through a snapshot.
+func parentVolumeID(cloud openstack.IOpenStack, vol *volumes.Volume) string {
+	if vol.SourceVolID != "" {
+		return vol.SourceVolID
+	}
+	if vol.SnapshotID == "" {
+		return ""
+	}
+
+	snap, err := cloud.GetSnapshotByID(vol.SnapshotID)
+	if err != nil {
+		if !cpoerrors.IsNotFound(err) {
+			klog.Warningf("Failed to get snapshot %s of volume %s: %v", vol.SnapshotID, vol.ID, err)
+		}
+		// The snapshot is still a link of the chain.
+		return vol.SnapshotID
+	}
+
+	return snap.VolumeID
+}
+
+// recordEvent emits the event for the PV and its PVC.
+func (f *volumeFlattener) recordEvent(pv *corev1.PersistentVolume, eventType, reason, msg string) {
+	f.recorder.Event(pv, eventType, reason, msg)
+	if pv.Spec.ClaimRef != nil {
+		f.recorder.Event(pv.Spec.ClaimRef, eventType, reason, msg)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	w, err := ParseMaintenanceWindow("")
+	assert.NoError(t, err)
+	assert.True(t, w.contains(time.Now()))
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	w, err = ParseMaintenanceWindow("01:30-04:00")
+	assert.NoError(t, err)
+	assert.True(t, w.contains(at(1, 30)))
+	assert.True(t, w.contains(at(3, 59)))
+	assert.False(t, w.contains(at(4, 0)))
+	assert.False(t, w.contains(at(23, 0)))
+
+	w, err = ParseMaintenanceWindow("22:00 - 04:00")
+	assert.NoError(t, err)
+	assert.True(t, w.contains(at(23, 0)))
+	assert.True(t, w.contains(at(0, 0)))
+	assert.False(t, w.contains(at(12, 0)))
+	// The times are compared in UTC
+	assert.True(t, w.contains(at(23, 0).In(time.FixedZone("UTC+2", 2*60*60))))
+
+	for _, invalid := range []string{"22:00", "22:00-25:00", "4-5", "04:00-04:00"} {
+		_, err = ParseMaintenanceWindow(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestVolumeFlattenerFlatten(t *testing.T) {
+	// The mock resolves all the snapshots to CSIVolumeID.
+	vols := []volumes.Volume{
+		{ID: "CSIVolumeID", Status: "in-use"},
+		{ID: "clone1", Status: "in-use", SnapshotID: "snapshot"},
+		{ID: "clone2", Status: "available", SourceVolID: "clone1"},
+		{ID: "clone3", Status: "in-use", SourceVolID: "clone2"},
+		{ID: "migrating", Status: "maintenance", SourceVolID: "clone1"},
+		{ID: "flattened", Status: "in-use", SourceVolID: "clone1", Metadata: map[string]string{VolumeFlattenedKey: "2024-01-01T00:00:00Z"}},
+		{ID: "from-flattened", Status: "in-use", SourceVolID: "flattened"},
+	}
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumes", 0, "").Return(vols, "", nil)
+	cloud.On("GetVolumeMigrationStatus", "clone2").Return("", nil).Once()
+	cloud.On("ListSnapshots", map[string]string{"VolumeID": "clone2", "Limit": "1"}).Return([]snapshots.Snapshot{}, "", nil).Once()
+	cloud.On("MigrateVolume", "clone2").Return(nil).Once()
+
+	kclient := fake.NewSimpleClientset(
+		newCinderPV("pv-root", "CSIVolumeID", nil),
+		newCinderPV("pv-clone1", "clone1", nil),
+		newCinderPV("pv-clone2", "clone2", nil),
+		newCinderPV("pv-clone3", "clone3", nil),
+		newCinderPV("pv-migrating", "migrating", nil),
+		newCinderPV("pv-flattened", "flattened", nil),
+		newCinderPV("pv-from-flattened", "from-flattened", nil),
+	)
+	recorder := record.NewFakeRecorder(10)
+
+	f := &volumeFlattener{
+		clouds:    map[string]openstack.IOpenStack{"": cloud},
+		kclient:   kclient,
+		recorder:  recorder,
+		depth:     2,
+		migrating: make(map[string]*flattenCandidate),
+	}
+	assert.NoError(t, f.flatten(context.TODO()))
+
+	// clone3 is no longer in a deep chain once clone2 is flattened, migrating is not in a state allowing it.
+	cloud.AssertExpectations(t)
+	cloud.AssertNumberOfCalls(t, "MigrateVolume", 1)
+	assert.Contains(t, f.migrating, "clone2")
+	// An event for the PV and one for the PVC
+	assert.Len(t, recorder.Events, 2)
+	assert.Contains(t, <-recorder.Events, eventVolumeFlattening)
+	<-recorder.Events
+
+	// clone2 is not marked while it is being migrated, nor is clone3 flattened meanwhile.
+	cloud.On("GetVolumeMigrationStatus", "clone2").Return("migrating", nil).Once()
+	f.checkMigrations()
+	assert.NoError(t, f.flatten(context.TODO()))
+	cloud.AssertNumberOfCalls(t, "MigrateVolume", 1)
+	cloud.AssertNotCalled(t, "UpdateVolumeMetadata", "clone2", mock.Anything)
+	assert.Empty(t, recorder.Events)
+
+	cloud.On("GetVolumeMigrationStatus", "clone2").Return("success", nil).Once()
+	cloud.On("UpdateVolumeMetadata", "clone2", mock.Anything).Return(nil).Once()
+	f.checkMigrations()
+	cloud.AssertExpectations(t)
+	assert.Empty(t, f.migrating)
+	assert.Contains(t, <-recorder.Events, eventVolumeFlattened)
+}
+
+func TestVolumeFlattenerFlattenSkips(t *testing.T) {
+	// All the clones have a chain of 2
+	vols := []volumes.Volume{
+		{ID: "root", Status: "available"},
+		{ID: "mid", Status: "available", SourceVolID: "root"},
+		{ID: "a-snapshots", Status: "in-use", SourceVolID: "mid"},
+		{ID: "b-failing", Status: "in-use", SourceVolID: "mid"},
+		{ID: "c", Status: "in-use", SourceVolID: "mid"},
+		{ID: "d", Status: "in-use", SourceVolID: "mid"},
+		{ID: "e", Status: "in-use", SourceVolID: "mid"},
+	}
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumes", 0, "").Return(vols, "", nil)
+	cloud.On("GetVolumeMigrationStatus", mock.Anything).Return("", nil)
+	cloud.On("ListSnapshots", map[string]string{"VolumeID": "a-snapshots", "Limit": "1"}).Return([]snapshots.Snapshot{{ID: "snap"}}, "", nil)
+	cloud.On("ListSnapshots", mock.Anything).Return([]snapshots.Snapshot{}, "", nil)
+	cloud.On("MigrateVolume", "b-failing").Return(errors.New("no valid host"))
+	cloud.On("MigrateVolume", mock.Anything).Return(nil)
+
+	var pvs []runtime.Object
+	for _, vol := range vols {
+		pvs = append(pvs, newCinderPV("pv-"+vol.ID, vol.ID, nil))
+	}
+	f := &volumeFlattener{
+		clouds:    map[string]openstack.IOpenStack{"": cloud},
+		kclient:   fake.NewSimpleClientset(pvs...),
+		recorder:  record.NewFakeRecorder(100),
+		depth:     2,
+		migrating: make(map[string]*flattenCandidate),
+	}
+	assert.NoError(t, f.flatten(context.TODO()))
+
+	// The volume with snapshots and the failed migration don't use up the migrations of the run
+	cloud.AssertNotCalled(t, "MigrateVolume", "a-snapshots")
+	assert.Len(t, f.migrating, 3)
+	for _, id := range []string{"c", "d", "e"} {
+		assert.Contains(t, f.migrating, id)
+	}
+	if assert.Contains(t, f.retries, "b-failing") {
+		assert.Equal(t, 1, f.retries["b-failing"].failures)
+		assert.True(t, f.retries["b-failing"].notBefore.After(time.Now()))
+	}
+
+	// The failed volume is not retried before its retry time, once the others are flattened
+	for i := 4; i < len(vols); i++ {
+		vols[i].Metadata = map[string]string{VolumeFlattenedKey: "2024-01-01T00:00:00Z"}
+	}
+	f.migrating = make(map[string]*flattenCandidate)
+	assert.NoError(t, f.flatten(context.TODO()))
+	cloud.AssertNumberOfCalls(t, "MigrateVolume", 4)
+
+	f.retries["b-failing"].notBefore = time.Now()
+	assert.NoError(t, f.flatten(context.TODO()))
+	cloud.AssertNumberOfCalls(t, "MigrateVolume", 5)
+	assert.Equal(t, 2, f.retries["b-failing"].failures)
+}
+
+func TestVolumeFlattenerCheckMigrations(t *testing.T) {
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("GetVolumeMigrationStatus", "failed").Return("error", nil).Once()
+	cloud.On("GetVolumeMigrationStatus", "hidden").Return("", nil).Once()
+	cloud.On("GetVolumeMigrationStatus", "running").Return("completing", nil).Once()
+
+	recorder := record.NewFakeRecorder(10)
+	f := &volumeFlattener{recorder: recorder, migrating: make(map[string]*flattenCandidate)}
+	for _, id := range []string{"failed", "hidden", "running"} {
+		f.migrating[id] = &flattenCandidate{cloud: cloud, vol: &volumes.Volume{ID: id}, pv: newCinderPV("pv-"+id, id, nil), depth: 2}
+	}
+	f.checkMigrations()
+
+	cloud.AssertExpectations(t)
+	cloud.AssertNotCalled(t, "UpdateVolumeMetadata", mock.Anything, mock.Anything)
+	assert.Len(t, f.migrating, 1)
+	assert.Contains(t, f.migrating, "running")
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	// An event for the PV and one for the PVC of each failure
+	assert.Len(t, events, 4)
+	for _, e := range events {
+		assert.Contains(t, e, eventVolumeFlattenError)
+	}
+	assert.Contains(t, strings.Join(events, "\n"), "requires the admin role")
+}
+
+func TestChainDepth(t *testing.T) {
+	cloud := new(openstack.OpenStackMock)
+	vols := map[string]*volumes.Volume{
+		"root":   {ID: "root"},
+		"clone":  {ID: "clone", SourceVolID: "root"},
+		"orphan": {ID: "orphan", SourceVolID: "deleted"},
+		"loop":   {ID: "loop", SourceVolID: "loop"},
+	}
+	parents := make(map[string]string)
+
+	assert.Equal(t, 0, chainDepth(cloud, vols, parents, vols["root"]))
+	assert.Equal(t, 1, chainDepth(cloud, vols, parents, vols["clone"]))
+	assert.Equal(t, 1, chainDepth(cloud, vols, parents, vols["orphan"]))
+	assert.Equal(t, 1, chainDepth(cloud, vols, parents, vols["loop"]))
+	assert.Equal(t, "root", parents["clone"])
+}
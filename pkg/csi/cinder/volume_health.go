@@ -0,0 +1,204 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+// VolumeUnhealthyAnnotation is the PV annotation cordoning a volume Cinder reports in error state while attached.
+// ControllerPublishVolume refuses to attach the cordoned volumes, the annotation is removed once Cinder reports the
+// volume out of the error state.
+const VolumeUnhealthyAnnotation = driverName + "/unhealthy"
+
+const (
+	volumeStatusError = "error"
+
+	eventVolumeUnhealthy = "VolumeUnhealthy"
+	eventVolumeRecovered = "VolumeRecovered"
+)
+
+// volumeHealthMonitor periodically compares the status of the Cinder volumes with their PVs. The PVs of the attached
+// volumes in error state are cordoned with VolumeUnhealthyAnnotation and Warning events are emitted for them and their
+// PVCs, so that the stateful workloads can fail over before the filesystem gets corrupted.
+type volumeHealthMonitor struct {
+	clouds   map[string]openstack.IOpenStack
+	kclient  kubernetes.Interface
+	recorder record.EventRecorder
+	interval time.Duration
+
+	mu       sync.RWMutex
+	cordoned map[string]bool
+}
+
+func newVolumeHealthMonitor(clouds map[string]openstack.IOpenStack, kclient kubernetes.Interface, interval time.Duration) *volumeHealthMonitor {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(klog.Infof)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kclient.CoreV1().Events("")})
+
+	return &volumeHealthMonitor{
+		clouds:   clouds,
+		kclient:  kclient,
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: driverName}),
+		interval: interval,
+		cordoned: make(map[string]bool),
+	}
+}
+
+func (m *volumeHealthMonitor) run(stopCh <-chan struct{}) {
+	klog.Infof("Checking the health of the volumes every %v", m.interval)
+	wait.Until(func() {
+		if err := m.check(context.TODO()); err != nil {
+			klog.Warningf("Failed to check the health of the volumes: %v", err)
+		}
+	}, m.interval, stopCh)
+}
+
+// isCordoned checks whether the PV of the volume carries VolumeUnhealthyAnnotation.
+func (m *volumeHealthMonitor) isCordoned(volumeID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cordoned[volumeID]
+}
+
+// listVolumes returns the volumes of all the clouds by ID.
+func listVolumes(clouds map[string]openstack.IOpenStack) (map[string]*volumes.Volume, error) {
+	vols := make(map[string]*volumes.Volume)
+	for name, cloud := range clouds {
+		if err := listCloudVolumes(cloud, vols); err != nil {
+			return nil, fmt.Errorf("failed to list the volumes of cloud %q: %v", name, err)
+		}
+	}
+
+	return vols, nil
+}
+
+// listCloudVolumes adds the volumes of the cloud to vols by ID.
+func listCloudVolumes(cloud openstack.IOpenStack, vols map[string]*volumes.Volume) error {
+	token := ""
+	for {
+		page, next, err := cloud.ListVolumes(0, token)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			vols[page[i].ID] = &page[i]
+		}
+		if next == "" || next == token {
+			return nil
+		}
+		token = next
+	}
+}
+
+// check cordons the PVs of the attached volumes in error state and uncordons the recovered ones.
+func (m *volumeHealthMonitor) check(ctx context.Context) error {
+	vols, err := listVolumes(m.clouds)
+	if err != nil {
+		return err
+	}
+
+	pvs, err := m.kclient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the PersistentVolumes: %v", err)
+	}
+
+	cordoned := make(map[string]bool)
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName {
+			continue
+		}
+
+		volumeID := pv.Spec.CSI.VolumeHandle
+		_, annotated := pv.Annotations[VolumeUnhealthyAnnotation]
+		vol, found := vols[volumeID]
+		switch {
+		case !found:
+			// Unknown to the clouds, e.g. deleted meanwhile, keep the PV as it is.
+		case vol.Status == volumeStatusError && len(vol.Attachments) > 0 && !annotated:
+			msg := fmt.Sprintf("Cinder volume %s is in %s state while attached, its attachment to new nodes is refused", volumeID, vol.Status)
+			if err := m.patchAnnotation(ctx, pv.Name, &msg); err != nil {
+				klog.Warningf("Failed to cordon PersistentVolume %s: %v", pv.Name, err)
+				break
+			}
+			klog.Warningf("Cordoned PersistentVolume %s: %s", pv.Name, msg)
+			m.recordEvent(pv, corev1.EventTypeWarning, eventVolumeUnhealthy, msg)
+			annotated = true
+		case vol.Status != volumeStatusError && annotated:
+			if err := m.patchAnnotation(ctx, pv.Name, nil); err != nil {
+				klog.Warningf("Failed to uncordon PersistentVolume %s: %v", pv.Name, err)
+				break
+			}
+			msg := fmt.Sprintf("Cinder volume %s recovered in %s state, its attachment is allowed again", volumeID, vol.Status)
+			klog.Infof("Uncordoned PersistentVolume %s: %s", pv.Name, msg)
+			m.recordEvent(pv, corev1.EventTypeNormal, eventVolumeRecovered, msg)
+			annotated = false
+		}
+
+		if annotated {
+			cordoned[volumeID] = true
+		}
+	}
+
+	m.mu.Lock()
+	m.cordoned = cordoned
+	m.mu.Unlock()
+
+	return nil
+}
+
+// patchAnnotation sets VolumeUnhealthyAnnotation on the PV, or removes it when value is nil.
+func (m *volumeHealthMonitor) patchAnnotation(ctx context.Context, pvName string, value *string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]*string{VolumeUnhealthyAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = m.kclient.CoreV1().PersistentVolumes().Patch(ctx, pvName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// recordEvent emits the event for the PV and its PVC, the stateful workloads watch the latter.
+func (m *volumeHealthMonitor) recordEvent(pv *corev1.PersistentVolume, eventType, reason, msg string) {
+	m.recorder.Event(pv, eventType, reason, msg)
+	if pv.Spec.ClaimRef != nil {
+		m.recorder.Event(pv.Spec.ClaimRef, eventType, reason, msg)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func newCinderPV(name, volumeID string, annotations map[string]string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driverName, VolumeHandle: volumeID},
+			},
+			ClaimRef: &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "default", Name: name},
+		},
+	}
+}
+
+func TestVolumeHealthMonitorCheck(t *testing.T) {
+	attached := []volumes.Attachment{{ServerID: "server"}}
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumes", 0, "").Return([]volumes.Volume{
+		{ID: "failing", Status: "error", Attachments: attached},
+		{ID: "detached", Status: "error"},
+		{ID: "recovered", Status: "in-use", Attachments: attached},
+		{ID: "cordoned", Status: "error", Attachments: attached},
+		{ID: "healthy", Status: "in-use", Attachments: attached},
+	}, "", nil)
+
+	cordon := map[string]string{VolumeUnhealthyAnnotation: "error"}
+	kclient := fake.NewSimpleClientset(
+		newCinderPV("pv-failing", "failing", nil),
+		newCinderPV("pv-detached", "detached", nil),
+		newCinderPV("pv-recovered", "recovered", cordon),
+		newCinderPV("pv-cordoned", "cordoned", cordon),
+		newCinderPV("pv-healthy", "healthy", nil),
+	)
+	recorder := record.NewFakeRecorder(10)
+
+	m := &volumeHealthMonitor{
+		clouds:   map[string]openstack.IOpenStack{"": cloud},
+		kclient:  kclient,
+		recorder: recorder,
+		cordoned: make(map[string]bool),
+	}
+	assert.NoError(t, m.check(context.TODO()))
+
+	expected := map[string]bool{
+		"pv-failing":   true,
+		"pv-detached":  false,
+		"pv-recovered": false,
+		"pv-cordoned":  true,
+		"pv-healthy":   false,
+	}
+	for name, annotated := range expected {
+		pv, err := kclient.CoreV1().PersistentVolumes().Get(context.TODO(), name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		_, ok := pv.Annotations[VolumeUnhealthyAnnotation]
+		assert.Equal(t, annotated, ok, name)
+	}
+
+	assert.True(t, m.isCordoned("failing"))
+	assert.True(t, m.isCordoned("cordoned"))
+	assert.False(t, m.isCordoned("recovered"))
+	assert.False(t, m.isCordoned("healthy"))
+
+	// An event for the PV and one for the PVC of each change
+	assert.Len(t, recorder.Events, 4)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+// The PV labels set from the attributes of the Cinder volumes.
+const (
+	VolumeTypeLabel       = driverName + "/volume-type"
+	AvailabilityZoneLabel = driverName + "/availability-zone"
+	EncryptedLabel        = driverName + "/encrypted"
+	BootableLabel         = driverName + "/bootable"
+)
+
+// volumeLabelSyncer periodically sets the attributes of the Cinder volumes as labels of their PVs, so that the PVs can
+// be selected and the policies enforced on the actual volumes rather than on their StorageClass.
+type volumeLabelSyncer struct {
+	clouds   map[string]openstack.IOpenStack
+	kclient  kubernetes.Interface
+	interval time.Duration
+}
+
+func newVolumeLabelSyncer(clouds map[string]openstack.IOpenStack, kclient kubernetes.Interface, interval time.Duration) *volumeLabelSyncer {
+	return &volumeLabelSyncer{
+		clouds:   clouds,
+		kclient:  kclient,
+		interval: interval,
+	}
+}
+
+func (s *volumeLabelSyncer) run(stopCh <-chan struct{}) {
+	klog.Infof("Synchronizing the labels of the PersistentVolumes every %v", s.interval)
+	wait.Until(func() {
+		if err := s.sync(context.TODO()); err != nil {
+			klog.Warningf("Failed to synchronize the labels of the PersistentVolumes: %v", err)
+		}
+	}, s.interval, stopCh)
+}
+
+// volumeLabels returns the PV labels of the volume, the attributes which aren't valid label values are removed.
+func volumeLabels(vol *volumes.Volume) map[string]*string {
+	return map[string]*string{
+		VolumeTypeLabel:       labelValue(vol.VolumeType),
+		AvailabilityZoneLabel: labelValue(vol.AvailabilityZone),
+		EncryptedLabel:        labelValue(strconv.FormatBool(vol.Encrypted)),
+		BootableLabel:         labelValue(strings.ToLower(vol.Bootable)),
+	}
+}
+
+func labelValue(value string) *string {
+	value = cpoutil.SanitizeLabel(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// sync updates the labels of the PVs whose volume attributes changed.
+func (s *volumeLabelSyncer) sync(ctx context.Context) error {
+	vols, err := listVolumes(s.clouds)
+	if err != nil {
+		return err
+	}
+
+	pvs, err := s.kclient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the PersistentVolumes: %v", err)
+	}
+
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName {
+			continue
+		}
+		vol, found := vols[pv.Spec.CSI.VolumeHandle]
+		if !found {
+			// Unknown to the clouds, e.g. deleted meanwhile, keep the PV as it is.
+			continue
+		}
+
+		labels := volumeLabels(vol)
+		changed := false
+		for k, v := range labels {
+			cur, ok := pv.Labels[k]
+			if (v == nil && ok) || (v != nil && (!ok || cur != *v)) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if err := s.patchLabels(ctx, pv.Name, labels); err != nil {
+			klog.Warningf("Failed to update the labels of PersistentVolume %s: %v", pv.Name, err)
+			continue
+		}
+		klog.V(4).Infof("Updated the labels of PersistentVolume %s from volume %s", pv.Name, vol.ID)
+	}
+
+	return nil
+}
+
+// patchLabels sets the labels of the PV, the nil ones are removed.
+func (s *volumeLabelSyncer) patchLabels(ctx context.Context, pvName string, labels map[string]*string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.kclient.CoreV1().PersistentVolumes().Patch(ctx, pvName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func TestVolumeLabelSyncerSync(t *testing.T) {
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumes", 0, "").Return([]volumes.Volume{
+		{ID: "new", VolumeType: "fast ssd", AvailabilityZone: "nova", Encrypted: true, Bootable: "false"},
+		{ID: "retyped", VolumeType: "slow", AvailabilityZone: "nova", Bootable: "true"},
+		{ID: "untyped", AvailabilityZone: "nova", Bootable: "false"},
+	}, "", nil)
+
+	retyped := newCinderPV("pv-retyped", "retyped", nil)
+	retyped.Labels = map[string]string{VolumeTypeLabel: "fast", AvailabilityZoneLabel: "nova", EncryptedLabel: "false", BootableLabel: "true", "app": "db"}
+	untyped := newCinderPV("pv-untyped", "untyped", nil)
+	untyped.Labels = map[string]string{VolumeTypeLabel: "fast"}
+	kclient := fake.NewSimpleClientset(
+		newCinderPV("pv-new", "new", nil),
+		retyped,
+		untyped,
+		newCinderPV("pv-missing", "missing", nil),
+	)
+
+	s := &volumeLabelSyncer{
+		clouds:  map[string]openstack.IOpenStack{"": cloud},
+		kclient: kclient,
+	}
+	assert.NoError(t, s.sync(context.TODO()))
+
+	expected := map[string]map[string]string{
+		"pv-new": {
+			VolumeTypeLabel:       "fast-ssd",
+			AvailabilityZoneLabel: "nova",
+			EncryptedLabel:        "true",
+			BootableLabel:         "false",
+		},
+		"pv-retyped": {
+			VolumeTypeLabel:       "slow",
+			AvailabilityZoneLabel: "nova",
+			EncryptedLabel:        "false",
+			BootableLabel:         "true",
+			"app":                 "db",
+		},
+		"pv-untyped": {
+			AvailabilityZoneLabel: "nova",
+			EncryptedLabel:        "false",
+			BootableLabel:         "false",
+		},
+		"pv-missing": nil,
+	}
+	for name, labels := range expected {
+		pv, err := kclient.CoreV1().PersistentVolumes().Get(context.TODO(), name, metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, labels, pv.Labels, name)
+	}
+}
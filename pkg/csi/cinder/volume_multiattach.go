@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+// multiattachExtraSpec is the volume type extra spec allowing the volumes to be attached to several instances.
+const multiattachExtraSpec = "multiattach"
+
+// isMultiNodeWriter checks whether the volume capability requests the volume to be written from several nodes.
+func isMultiNodeWriter(volCap *csi.VolumeCapability) bool {
+	return volCap.GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER
+}
+
+// validateMultiNodeCapabilities returns whether the volume capabilities request the volume to be written from several
+// nodes. This is only allowed for raw block volumes, as the filesystems don't support being mounted on several nodes.
+func validateMultiNodeCapabilities(volCaps []*csi.VolumeCapability) (bool, error) {
+	multiNode := false
+	for _, volCap := range volCaps {
+		if !isMultiNodeWriter(volCap) {
+			continue
+		}
+		if volCap.GetBlock() == nil {
+			return false, fmt.Errorf("access mode %s is only supported for block volumes", volCap.GetAccessMode().GetMode())
+		}
+		multiNode = true
+	}
+	return multiNode, nil
+}
+
+// isVolumeCapabilitySupported checks whether the volume supports the volume capability. The volumes can only be
+// written from several nodes when they are multiattach block volumes.
+func isVolumeCapabilitySupported(vcap []*csi.VolumeCapability_AccessMode, vol *volumes.Volume, volCap *csi.VolumeCapability) bool {
+	if isMultiNodeWriter(volCap) && (!vol.Multiattach || volCap.GetBlock() == nil) {
+		return false
+	}
+	for _, c := range vcap {
+		if c.GetMode() == volCap.GetAccessMode().GetMode() {
+			return true
+		}
+	}
+	return false
+}
+
+// isMultiattachExtraSpec parses the multiattach extra spec of a volume type, e.g. "<is> True".
+func isMultiattachExtraSpec(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "<is>")), "true")
+}
+
+// checkMultiattachVolumeType checks that the volume type, given by name or ID, allows the volumes to be attached to
+// several instances.
+func checkMultiattachVolumeType(cloud openstack.IOpenStack, volType string) error {
+	if volType == "" {
+		return status.Errorf(codes.InvalidArgument, "a volume type with multiattach enabled must be set in the type parameter for the %s access mode", csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER)
+	}
+
+	volTypes, err := cloud.ListVolumeTypes()
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to list volume types: %v", err)
+	}
+
+	var found *volumetypes.VolumeType
+	for i, t := range volTypes {
+		if volType == t.ID || volType == t.Name {
+			found = &volTypes[i]
+			break
+		}
+	}
+	if found == nil {
+		return status.Errorf(codes.InvalidArgument, "volume type %s not found", volType)
+	}
+	if !isMultiattachExtraSpec(found.ExtraSpecs[multiattachExtraSpec]) {
+		return status.Errorf(codes.InvalidArgument, "volume type %s doesn't have multiattach enabled, required by the %s access mode", volType, csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func newVolumeCapability(mode csi.VolumeCapability_AccessMode_Mode, block bool) *csi.VolumeCapability {
+	volCap := &csi.VolumeCapability{
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode},
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+	}
+	if block {
+		volCap.AccessType = &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}
+	}
+	return volCap
+}
+
+func TestValidateMultiNodeCapabilities(t *testing.T) {
+	multiNode, err := validateMultiNodeCapabilities([]*csi.VolumeCapability{
+		newVolumeCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER, false),
+	})
+	assert.NoError(t, err)
+	assert.False(t, multiNode)
+
+	multiNode, err = validateMultiNodeCapabilities([]*csi.VolumeCapability{
+		newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, true),
+	})
+	assert.NoError(t, err)
+	assert.True(t, multiNode)
+
+	_, err = validateMultiNodeCapabilities([]*csi.VolumeCapability{
+		newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, false),
+	})
+	assert.Error(t, err)
+}
+
+func TestIsVolumeCapabilitySupported(t *testing.T) {
+	vcap := fakeCs.Driver.vcap
+	multiattach := &volumes.Volume{Multiattach: true}
+	single := &volumes.Volume{}
+
+	assert.True(t, isVolumeCapabilitySupported(vcap, single, newVolumeCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER, false)))
+	assert.True(t, isVolumeCapabilitySupported(vcap, multiattach, newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, true)))
+	assert.False(t, isVolumeCapabilitySupported(vcap, multiattach, newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, false)))
+	assert.False(t, isVolumeCapabilitySupported(vcap, single, newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, true)))
+	assert.False(t, isVolumeCapabilitySupported(vcap, multiattach, newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY, true)))
+}
+
+func TestCheckMultiattachVolumeType(t *testing.T) {
+	volTypes := []volumetypes.VolumeType{
+		{ID: "type-multiattach", Name: "multiattach", ExtraSpecs: map[string]string{multiattachExtraSpec: "<is> True"}},
+		{ID: "type-ssd", Name: "ssd", ExtraSpecs: map[string]string{multiattachExtraSpec: "<is> False"}},
+		{ID: "type-default", Name: "default"},
+	}
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumeTypes").Return(volTypes, nil)
+
+	testCases := []struct {
+		name     string
+		volType  string
+		expected codes.Code
+	}{
+		{name: "multiattach by name", volType: "multiattach", expected: codes.OK},
+		{name: "multiattach by ID", volType: "type-multiattach", expected: codes.OK},
+		{name: "multiattach disabled", volType: "ssd", expected: codes.InvalidArgument},
+		{name: "multiattach not set", volType: "default", expected: codes.InvalidArgument},
+		{name: "type not found", volType: "unknown", expected: codes.InvalidArgument},
+		{name: "type not set", expected: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkMultiattachVolumeType(cloud, tc.volType)
+			assert.Equal(t, tc.expected, status.Code(err))
+		})
+	}
+}
+
+func TestCreateVolumeNotMultiattach(t *testing.T) {
+	volTypes := []volumetypes.VolumeType{
+		{ID: "type-multiattach", Name: "multiattach", ExtraSpecs: map[string]string{multiattachExtraSpec: "<is> True"}},
+	}
+	// The extra spec of the volume type changed after it was checked
+	vol := &volumes.Volume{ID: "vol", Name: "pvc-multiattach", Size: 1, VolumeType: "multiattach", Status: "creating"}
+	settled := []string{openstack.VolumeAvailableStatus, "error"}
+
+	req := &csi.CreateVolumeRequest{
+		Name:               "pvc-multiattach",
+		VolumeCapabilities: []*csi.VolumeCapability{newVolumeCapability(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, true)},
+		Parameters:         map[string]string{"type": "multiattach"},
+	}
+
+	testCases := []struct {
+		name      string
+		deleteErr error
+		expected  codes.Code
+	}{
+		{name: "volume deleted", expected: codes.InvalidArgument},
+		{name: "volume not deleted", deleteErr: errors.New("delete failed"), expected: codes.Internal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cloud := new(openstack.OpenStackMock)
+			cloud.On("GetVolumesByName", "pvc-multiattach").Return([]volumes.Volume{}, nil)
+			cloud.On("ListVolumeTypes").Return(volTypes, nil)
+			cloud.On("CreateVolume", "pvc-multiattach", 1, "multiattach", "", "", "", "", mock.Anything).Return(vol, nil)
+			cloud.On("WaitVolumeTargetStatus", "vol", settled).Return(nil)
+			cloud.On("DeleteVolume", "vol").Return(tc.deleteErr)
+
+			d := NewDriver(&DriverOpts{Endpoint: FakeEndpoint, ClusterID: FakeCluster})
+			cs := NewControllerServer(d, map[string]openstack.IOpenStack{"": cloud})
+
+			_, err := cs.CreateVolume(FakeCtx, req)
+			assert.Equal(t, tc.expected, status.Code(err))
+			// The volume is only deleted once it's no longer being created
+			cloud.AssertCalled(t, "WaitVolumeTargetStatus", "vol", settled)
+			cloud.AssertCalled(t, "DeleteVolume", "vol")
+		})
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+// StorageClass parameters requesting the performance of a volume. The volume is created with a volume type whose QoS
+// specs provide it.
+const (
+	iopsPerGBKey  = "iopsPerGB"
+	maxIOPSKey    = "maxIOPS"
+	throughputKey = "throughput" // MiB/s
+
+	// volumeQoSKey is the volume property recording the QoS parameters the volume was created with, checked again
+	// when the volume is expanded.
+	volumeQoSKey = "cinder.csi.openstack.org/qos"
+)
+
+// The QoS specs keys limiting the IOPS and the bytes per second of a volume, the front-end keys of Cinder and the
+// back-end keys of the most common drivers. The per GB limits are multiplied by the size of the volume.
+var (
+	qosIOPSKeys      = []string{"total_iops_sec", "read_iops_sec", "write_iops_sec", "maxIOPS"}
+	qosIOPSPerGBKeys = []string{"total_iops_sec_per_gb", "read_iops_sec_per_gb", "write_iops_sec_per_gb", "maxIOPSperGiB"}
+	qosBPSKeys       = []string{"total_bytes_sec", "read_bytes_sec", "write_bytes_sec", "maxBPS"}
+	qosBPSPerGBKeys  = []string{"total_bytes_sec_per_gb", "read_bytes_sec_per_gb", "write_bytes_sec_per_gb", "maxBPSperGiB"}
+)
+
+// volumeQoS is the performance requested for a volume, zero when not requested.
+type volumeQoS struct {
+	iops        int64
+	bytesPerSec int64
+}
+
+// getVolumeQoS parses the QoS parameters for a volume of the given size, it returns nil if no performance is requested.
+// The IOPS requested per GB are capped by maxIOPS when both are set.
+func getVolumeQoS(params map[string]string, sizeGB int) (*volumeQoS, error) {
+	parse := func(key string) (int64, error) {
+		v, ok := params[key]
+		if !ok {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid %s parameter %q, must be a positive integer", key, v)
+		}
+		return n, nil
+	}
+
+	iopsPerGB, err := parse(iopsPerGBKey)
+	if err != nil {
+		return nil, err
+	}
+	maxIOPS, err := parse(maxIOPSKey)
+	if err != nil {
+		return nil, err
+	}
+	throughput, err := parse(throughputKey)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &volumeQoS{iops: maxIOPS, bytesPerSec: throughput * 1024 * 1024}
+	if iopsPerGB > 0 {
+		q.iops = iopsPerGB * int64(sizeGB)
+		if maxIOPS > 0 {
+			q.iops = min(q.iops, maxIOPS)
+		}
+	}
+	if *q == (volumeQoS{}) {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// formatVolumeQoSParams returns the QoS parameters of the StorageClass recorded in the volume properties, e.g.
+// "iopsPerGB=10,maxIOPS=1000".
+func formatVolumeQoSParams(params map[string]string) string {
+	var parts []string
+	for _, key := range []string{iopsPerGBKey, maxIOPSKey, throughputKey} {
+		if v, ok := params[key]; ok {
+			parts = append(parts, key+"="+v)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// getVolumeQoSFromProperties returns the performance requested for a volume of the given size by the QoS parameters
+// recorded in its properties, nil when none are recorded.
+func getVolumeQoSFromProperties(properties map[string]string, sizeGB int) (*volumeQoS, error) {
+	value := properties[volumeQoSKey]
+	if value == "" {
+		return nil, nil
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		key, v, _ := strings.Cut(part, "=")
+		params[key] = v
+	}
+	return getVolumeQoS(params, sizeGB)
+}
+
+// qosLimit returns the lowest limit of the QoS specs for a volume of the given size, 0 when not limited.
+func qosLimit(specs map[string]string, keys, perGBKeys []string, sizeGB int) (int64, error) {
+	var limit int64
+	lower := func(key string, factor int64) error {
+		v, ok := specs[key]
+		if !ok {
+			return nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid QoS spec %s=%q", key, v)
+		}
+		if n*factor > 0 && (limit == 0 || n*factor < limit) {
+			limit = n * factor
+		}
+		return nil
+	}
+	for _, key := range keys {
+		if err := lower(key, 1); err != nil {
+			return 0, err
+		}
+	}
+	for _, key := range perGBKeys {
+		if err := lower(key, int64(sizeGB)); err != nil {
+			return 0, err
+		}
+	}
+	return limit, nil
+}
+
+// qosLimits returns the IOPS and the bytes per second limits of the QoS specs for a volume of the given size, the
+// limits which aren't set are math.MaxInt64.
+func qosLimits(specs *qos.QoS, sizeGB int) (volumeQoS, error) {
+	iops, err := qosLimit(specs.Specs, qosIOPSKeys, qosIOPSPerGBKeys, sizeGB)
+	if err != nil {
+		return volumeQoS{}, err
+	}
+	bps, err := qosLimit(specs.Specs, qosBPSKeys, qosBPSPerGBKeys, sizeGB)
+	if err != nil {
+		return volumeQoS{}, err
+	}
+	if iops == 0 {
+		iops = math.MaxInt64
+	}
+	if bps == 0 {
+		bps = math.MaxInt64
+	}
+	return volumeQoS{iops: iops, bytesPerSec: bps}, nil
+}
+
+// honoredBy checks whether the limits of QoS specs provide the requested performance.
+func (q *volumeQoS) honoredBy(limits volumeQoS) bool {
+	return limits.iops >= q.iops && limits.bytesPerSec >= q.bytesPerSec
+}
+
+// getQoSVolumeType returns the volume type to create the volume with the requested performance. When a volume type
+// is set, it's validated against its QoS specs, otherwise the volume type with the lowest QoS specs providing the
+// performance is selected, the IOPS first. The volume types without QoS specs don't guarantee any performance and are
+// never selected. Reading the QoS specs is restricted to the administrators by the default Cinder policy, the
+// credentials of the plugin need the admin role or a policy allowing volume_extension:qos_specs_manage:get.
+func getQoSVolumeType(cloud openstack.IOpenStack, volType string, q *volumeQoS, sizeGB int) (string, error) {
+	volTypes, err := cloud.ListVolumeTypes()
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to list volume types: %v", err)
+	}
+
+	found := false
+	var selected string
+	var selectedLimits volumeQoS
+	for _, t := range volTypes {
+		if volType != "" && volType != t.ID && volType != t.Name {
+			continue
+		}
+		found = true
+
+		if t.QosSpecID == "" {
+			if volType != "" {
+				return "", status.Errorf(codes.InvalidArgument, "volume type %s has no QoS specs, or reading them requires the admin role", volType)
+			}
+			continue
+		}
+		specs, err := cloud.GetQoSSpecs(t.QosSpecID)
+		if err != nil {
+			if cpoerrors.IsForbiddenError(err) {
+				return "", status.Errorf(codes.FailedPrecondition, "not allowed to read the QoS specs of volume type %s: %v", t.Name, err)
+			}
+			return "", status.Errorf(codes.Internal, "failed to get the QoS specs of volume type %s: %v", t.Name, err)
+		}
+
+		limits, err := qosLimits(specs, sizeGB)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "QoS specs %s of volume type %s: %v", specs.Name, t.Name, err)
+		}
+		if volType != "" {
+			if !q.honoredBy(limits) {
+				return "", status.Errorf(codes.InvalidArgument, "QoS specs %s of volume type %s don't provide %s", specs.Name, volType, q)
+			}
+			return volType, nil
+		}
+
+		if q.honoredBy(limits) && (selected == "" || limits.iops < selectedLimits.iops ||
+			limits.iops == selectedLimits.iops && limits.bytesPerSec < selectedLimits.bytesPerSec) {
+			klog.V(5).Infof("Volume type %s (%s) with QoS specs %s provides %s", t.Name, t.ID, specs.Name, q)
+			selected, selectedLimits = t.ID, limits
+		}
+	}
+
+	if volType != "" && !found {
+		return "", status.Errorf(codes.InvalidArgument, "volume type %s not found", volType)
+	}
+	if selected == "" {
+		return "", status.Errorf(codes.InvalidArgument, "no volume type has QoS specs providing %s", q)
+	}
+	klog.V(4).Infof("Selected volume type %s providing %s", selected, q)
+	return selected, nil
+}
+
+// checkExpandVolumeQoS checks that the volume type of a volume created with QoS parameters still provides the
+// requested performance at the new size, e.g. the IOPS per GB exceeding the fixed limits of the QoS specs.
+func checkExpandVolumeQoS(cloud openstack.IOpenStack, volume *volumes.Volume, volSizeGB int) error {
+	q, err := getVolumeQoSFromProperties(volume.Metadata, volSizeGB)
+	if err != nil {
+		return status.Errorf(codes.Internal, "[ControllerExpandVolume] invalid %s property of volume %s: %v", volumeQoSKey, volume.ID, err)
+	}
+	if q == nil {
+		return nil
+	}
+	if _, err := getQoSVolumeType(cloud, volume.VolumeType, q, volSizeGB); err != nil {
+		return status.Errorf(status.Code(err), "[ControllerExpandVolume] volume %s can't be expanded to %d GiB: %v", volume.ID, volSizeGB, status.Convert(err).Message())
+	}
+	return nil
+}
+
+func (q *volumeQoS) String() string {
+	switch {
+	case q.iops > 0 && q.bytesPerSec > 0:
+		return fmt.Sprintf("%d IOPS and %d MiB/s", q.iops, q.bytesPerSec/1024/1024)
+	case q.iops > 0:
+		return fmt.Sprintf("%d IOPS", q.iops)
+	default:
+		return fmt.Sprintf("%d MiB/s", q.bytesPerSec/1024/1024)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+)
+
+func TestGetVolumeQoS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   map[string]string
+		expected *volumeQoS
+		wantErr  bool
+	}{
+		{
+			name:   "no QoS",
+			params: map[string]string{"type": "ssd"},
+		},
+		{
+			name:     "IOPS per GB",
+			params:   map[string]string{iopsPerGBKey: "50"},
+			expected: &volumeQoS{iops: 500},
+		},
+		{
+			name:     "IOPS per GB capped",
+			params:   map[string]string{iopsPerGBKey: "50", maxIOPSKey: "300"},
+			expected: &volumeQoS{iops: 300},
+		},
+		{
+			name:     "max IOPS and throughput",
+			params:   map[string]string{maxIOPSKey: "1000", throughputKey: "100"},
+			expected: &volumeQoS{iops: 1000, bytesPerSec: 100 * 1024 * 1024},
+		},
+		{
+			name:    "invalid IOPS per GB",
+			params:  map[string]string{iopsPerGBKey: "fast"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid throughput",
+			params:  map[string]string{throughputKey: "0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := getVolumeQoS(tc.params, 10)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, q)
+		})
+	}
+}
+
+func TestGetQoSVolumeType(t *testing.T) {
+	volTypes := []volumetypes.VolumeType{
+		{ID: "plain-id", Name: "plain"},
+		{ID: "fast-id", Name: "fast", QosSpecID: "fast-qos"},
+		{ID: "standard-id", Name: "standard", QosSpecID: "standard-qos"},
+	}
+
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumeTypes").Return(volTypes, nil)
+	cloud.On("GetQoSSpecs", "standard-qos").Return(&qos.QoS{Name: "standard", Specs: map[string]string{"total_iops_sec": "500", "total_bytes_sec": "104857600"}}, nil)
+	cloud.On("GetQoSSpecs", "fast-qos").Return(&qos.QoS{Name: "fast", Specs: map[string]string{"total_iops_sec_per_gb": "100", "maxIOPS": "5000"}}, nil)
+
+	forbidden := new(openstack.OpenStackMock)
+	forbidden.On("ListVolumeTypes").Return(volTypes, nil)
+	forbidden.On("GetQoSSpecs", "standard-qos").Return(nil, gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusForbidden})
+
+	testCases := []struct {
+		name     string
+		cloud    openstack.IOpenStack
+		volType  string
+		qos      *volumeQoS
+		expected string
+		code     codes.Code
+	}{
+		{
+			name:     "select the smallest volume type providing the IOPS",
+			cloud:    cloud,
+			qos:      &volumeQoS{iops: 500},
+			expected: "standard-id",
+		},
+		{
+			name:     "select the volume type with the IOPS per GB",
+			cloud:    cloud,
+			qos:      &volumeQoS{iops: 800},
+			expected: "fast-id",
+		},
+		{
+			name:     "validate the volume type by name",
+			cloud:    cloud,
+			volType:  "standard",
+			qos:      &volumeQoS{bytesPerSec: 100 * 1024 * 1024},
+			expected: "standard",
+		},
+		{
+			name:    "volume type without QoS specs",
+			cloud:   cloud,
+			volType: "plain",
+			qos:     &volumeQoS{iops: 100},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "volume type not providing the throughput",
+			cloud:   cloud,
+			volType: "standard-id",
+			qos:     &volumeQoS{bytesPerSec: 200 * 1024 * 1024},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:  "no volume type providing the IOPS",
+			cloud: cloud,
+			qos:   &volumeQoS{iops: 2000},
+			code:  codes.InvalidArgument,
+		},
+		{
+			name:    "volume type not found",
+			cloud:   cloud,
+			volType: "missing",
+			qos:     &volumeQoS{iops: 100},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "QoS specs not readable",
+			cloud:   forbidden,
+			volType: "standard",
+			qos:     &volumeQoS{iops: 100},
+			code:    codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volType, err := getQoSVolumeType(tc.cloud, tc.volType, tc.qos, 10)
+			if tc.code != codes.OK {
+				assert.Equal(t, tc.code, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, volType)
+		})
+	}
+}
+
+func TestCheckExpandVolumeQoS(t *testing.T) {
+	volTypes := []volumetypes.VolumeType{{ID: "standard-id", Name: "standard", QosSpecID: "standard-qos"}}
+	cloud := new(openstack.OpenStackMock)
+	cloud.On("ListVolumeTypes").Return(volTypes, nil)
+	cloud.On("GetQoSSpecs", "standard-qos").Return(&qos.QoS{Name: "standard", Specs: map[string]string{"total_iops_sec": "500"}}, nil)
+
+	params := map[string]string{iopsPerGBKey: "50", "type": "standard"}
+	volume := &volumes.Volume{ID: "vol", VolumeType: "standard", Metadata: map[string]string{volumeQoSKey: formatVolumeQoSParams(params)}}
+	assert.Equal(t, "iopsPerGB=50", volume.Metadata[volumeQoSKey])
+
+	// 50 IOPS per GB fit in the 500 IOPS of the volume type up to 10 GiB
+	assert.NoError(t, checkExpandVolumeQoS(cloud, volume, 10))
+	err := checkExpandVolumeQoS(cloud, volume, 11)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.ErrorContains(t, err, "550 IOPS")
+
+	// The volumes created without QoS parameters aren't checked
+	assert.NoError(t, checkExpandVolumeQoS(new(openstack.OpenStackMock), &volumes.Volume{ID: "vol"}, 100))
+}
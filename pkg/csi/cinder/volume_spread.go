@@ -0,0 +1,221 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/keymutex"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+)
+
+const (
+	// spreadGroupKey is the PVC annotation grouping the PVCs of a namespace whose volumes are created on different
+	// Cinder backends, typically the PVCs of a pod.
+	spreadGroupKey = "cinder.csi.openstack.org/spread-group"
+	// spreadVolumeIDKey is the PVC annotation the controller sets to the ID of the Cinder volume of a PVC of a spread
+	// group, the volumes of the other PVCs of the group are kept away from it.
+	spreadVolumeIDKey = "cinder.csi.openstack.org/spread-volume-id"
+
+	// recordedSpreadVolumeTTL is how long the volumes recorded on the PVCs are remembered, until the PVC lister sees
+	// the annotations.
+	recordedSpreadVolumeTTL = 10 * time.Minute
+)
+
+var (
+	// statefulSetOrdinal matches the ordinal of the pod at the end of the name of a PVC of a StatefulSet,
+	// <claim template>-<StatefulSet>-<ordinal>.
+	statefulSetOrdinal = regexp.MustCompile(`-([0-9]+)$`)
+
+	// spreadGroupLocks serializes the creation of the volumes of each spread group, so that each one sees the volumes
+	// created before. The volumes of different groups are created in parallel.
+	spreadGroupLocks = keymutex.NewHashed(0)
+	// recordedSpreadVolumes are the volumes recently recorded on the PVCs of the spread groups.
+	recordedSpreadVolumes = &spreadVolumeRecords{}
+)
+
+// spreadVolumeRecords remembers the volumes recorded on the PVCs of the spread groups, keyed by <namespace>/<name>,
+// the PVC lister may not see the annotations yet when the next volume of the group is created.
+type spreadVolumeRecords struct {
+	mu      sync.Mutex
+	records map[string]spreadVolumeRecord
+}
+
+type spreadVolumeRecord struct {
+	volumeID   string
+	recordedAt time.Time
+}
+
+func (r *spreadVolumeRecords) add(key, volumeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.records == nil {
+		r.records = make(map[string]spreadVolumeRecord)
+	}
+	now := time.Now()
+	for k, record := range r.records {
+		if now.Sub(record.recordedAt) > recordedSpreadVolumeTTL {
+			delete(r.records, k)
+		}
+	}
+	r.records[key] = spreadVolumeRecord{volumeID: volumeID, recordedAt: now}
+}
+
+func (r *spreadVolumeRecords) get(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.records[key]; ok && time.Since(record.recordedAt) <= recordedSpreadVolumeTTL {
+		return record.volumeID
+	}
+	return ""
+}
+
+// spreadGroupScope returns the spread group of a PVC scoped to the pod ordinal its name ends with, if any. Each
+// replica of a StatefulSet gets its own group of PVCs from the claim templates, rather than all the replicas sharing
+// one group that outgrows the number of backends.
+func spreadGroupScope(group, pvcName string) string {
+	if m := statefulSetOrdinal.FindStringSubmatch(pvcName); m != nil {
+		return group + "/" + m[1]
+	}
+	return group
+}
+
+// lockSpreadGroup serializes the creation of the volumes of the spread group of a PVC, it returns the unlock function.
+func lockSpreadGroup(params map[string]string, group string) func() {
+	key := params[sharedcsi.PvcNamespaceKey] + "/" + spreadGroupScope(group, params[sharedcsi.PvcNameKey])
+	spreadGroupLocks.LockKey(key)
+	return func() {
+		_ = spreadGroupLocks.UnlockKey(key)
+	}
+}
+
+// getSpreadGroupVolumes returns the IDs of the volumes already created for the other PVCs of the spread group of the
+// PVC, among the PVCs of the same StorageClass and pod ordinal. The PVCs are read from the PVC lister, completed with the volumes
+// recorded recently it may not see yet.
+func (cs *controllerServer) getSpreadGroupVolumes(ctx context.Context, params map[string]string, group string) []string {
+	if group == "" {
+		return nil
+	}
+
+	namespace := params[sharedcsi.PvcNamespaceKey]
+	pvcName := params[sharedcsi.PvcNameKey]
+	pvcs, err := cs.listPVCs(ctx, namespace)
+	if err != nil {
+		klog.Errorf("Failed to list the PVCs of namespace %s: %v", namespace, err)
+		return nil
+	}
+
+	var pvc *corev1.PersistentVolumeClaim
+	for _, p := range pvcs {
+		if p.Name == pvcName {
+			pvc = p
+		}
+	}
+	if pvc == nil {
+		klog.Errorf("Failed to find PVC %s/%s of spread group %s", namespace, pvcName, group)
+		return nil
+	}
+
+	scope := spreadGroupScope(group, pvcName)
+	var ids []string
+	for _, p := range pvcs {
+		if p.Name == pvcName || spreadGroupScope(p.Annotations[spreadGroupKey], p.Name) != scope || !sameStorageClass(p, pvc) {
+			continue
+		}
+		id := p.Annotations[spreadVolumeIDKey]
+		if id == "" {
+			id = recordedSpreadVolumes.get(namespace + "/" + p.Name)
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// listPVCs lists the PVCs of the namespace from the PVC lister, or from the API when there is none.
+func (cs *controllerServer) listPVCs(ctx context.Context, namespace string) ([]*corev1.PersistentVolumeClaim, error) {
+	if cs.Driver.pvcLister != nil {
+		return cs.Driver.pvcLister.PersistentVolumeClaims(namespace).List(labels.Everything())
+	}
+	if cs.Driver.kclient == nil {
+		return nil, nil
+	}
+
+	list, err := cs.Driver.kclient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pvcs := make([]*corev1.PersistentVolumeClaim, 0, len(list.Items))
+	for i := range list.Items {
+		pvcs = append(pvcs, &list.Items[i])
+	}
+	return pvcs, nil
+}
+
+func sameStorageClass(a, b *corev1.PersistentVolumeClaim) bool {
+	return a.Spec.StorageClassName != nil && b.Spec.StorageClassName != nil && *a.Spec.StorageClassName == *b.Spec.StorageClassName
+}
+
+// recordSpreadGroupVolume records the volume of a PVC of a spread group on the PVC. The volume is created anyway when
+// the PVC can't be annotated, the following volumes of the group may land on its backend.
+func (cs *controllerServer) recordSpreadGroupVolume(ctx context.Context, group string, params map[string]string, volumeID string) {
+	if group == "" {
+		return
+	}
+	if cs.Driver.kclient == nil {
+		klog.Warningf("No Kubernetes client to record volume %s of spread group %s on its PVC", volumeID, group)
+		return
+	}
+	if err := annotateSpreadGroupVolume(ctx, cs.Driver.kclient, params, volumeID); err != nil {
+		klog.Errorf("Failed to record volume %s of spread group %s on PVC %s/%s: %v", volumeID, group, params[sharedcsi.PvcNamespaceKey], params[sharedcsi.PvcNameKey], err)
+		return
+	}
+	recordedSpreadVolumes.add(params[sharedcsi.PvcNamespaceKey]+"/"+params[sharedcsi.PvcNameKey], volumeID)
+}
+
+// annotateSpreadGroupVolume annotates the PVC with the ID of its volume, so that the volumes of the other PVCs of its
+// spread group are created on other backends.
+func annotateSpreadGroupVolume(ctx context.Context, kclient kubernetes.Interface, params map[string]string, volumeID string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{spreadVolumeIDKey: volumeID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = kclient.CoreV1().PersistentVolumeClaims(params[sharedcsi.PvcNamespaceKey]).Patch(ctx, params[sharedcsi.PvcNameKey], types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
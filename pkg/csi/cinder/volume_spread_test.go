@@ -0,0 +1,143 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	sharedcsi "k8s.io/cloud-provider-openstack/pkg/csi"
+)
+
+func newSpreadPVC(name, class string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &class},
+	}
+}
+
+func TestGetSpreadGroupVolumes(t *testing.T) {
+	pvcs := []*corev1.PersistentVolumeClaim{
+		newSpreadPVC("disk-a", "fast", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-0"}),
+		newSpreadPVC("disk-b", "fast", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-1"}),
+		newSpreadPVC("disk-c", "fast", map[string]string{spreadGroupKey: "raid"}),
+		newSpreadPVC("disk-d", "fast", map[string]string{spreadGroupKey: "raid"}),
+		newSpreadPVC("other-group", "fast", map[string]string{spreadGroupKey: "logs", spreadVolumeIDKey: "vol-logs"}),
+		newSpreadPVC("other-class", "slow", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-slow"}),
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	kclient := fake.NewSimpleClientset()
+	for _, pvc := range pvcs {
+		assert.NoError(t, indexer.Add(pvc))
+		_, err := kclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(context.TODO(), pvc, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	for name, driver := range map[string]*Driver{
+		"api":    {kclient: kclient},
+		"lister": {pvcLister: v1.NewPersistentVolumeClaimLister(indexer)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cs := &controllerServer{Driver: driver}
+			params := map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk-c"}
+
+			assert.Equal(t, []string{"vol-0", "vol-1"}, cs.getSpreadGroupVolumes(context.TODO(), params, "raid"))
+			// The volume of the PVC itself is ignored when it is created again
+			params[sharedcsi.PvcNameKey] = "disk-a"
+			assert.Equal(t, []string{"vol-1"}, cs.getSpreadGroupVolumes(context.TODO(), params, "raid"))
+			assert.Empty(t, cs.getSpreadGroupVolumes(context.TODO(), params, ""))
+		})
+	}
+
+	cs := &controllerServer{Driver: &Driver{}}
+	assert.Empty(t, cs.getSpreadGroupVolumes(context.TODO(), map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk-c"}, "raid"))
+}
+
+func TestGetSpreadGroupVolumesStatefulSet(t *testing.T) {
+	// The PVCs of each replica of the StatefulSet are spread apart, not the PVCs of all the replicas
+	kclient := fake.NewSimpleClientset(
+		newSpreadPVC("disk0-db-0", "fast", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-0-0"}),
+		newSpreadPVC("disk1-db-0", "fast", map[string]string{spreadGroupKey: "raid"}),
+		newSpreadPVC("disk0-db-1", "fast", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-1-0"}),
+		newSpreadPVC("disk1-db-1", "fast", map[string]string{spreadGroupKey: "raid"}),
+		newSpreadPVC("disk0-db-10", "fast", map[string]string{spreadGroupKey: "raid", spreadVolumeIDKey: "vol-10-0"}),
+	)
+	cs := &controllerServer{Driver: &Driver{kclient: kclient}}
+
+	params := map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk1-db-0"}
+	assert.Equal(t, []string{"vol-0-0"}, cs.getSpreadGroupVolumes(context.TODO(), params, "raid"))
+	params[sharedcsi.PvcNameKey] = "disk1-db-1"
+	assert.Equal(t, []string{"vol-1-0"}, cs.getSpreadGroupVolumes(context.TODO(), params, "raid"))
+
+	assert.Equal(t, "raid/0", spreadGroupScope("raid", "disk1-db-0"))
+	assert.Equal(t, "raid", spreadGroupScope("raid", "disk-a"))
+}
+
+func TestGetSpreadGroupVolumesRecorded(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	// The lister doesn't see the annotation of the volume just recorded on disk-a yet
+	assert.NoError(t, indexer.Add(newSpreadPVC("disk-a", "fast", map[string]string{spreadGroupKey: "recorded"})))
+	assert.NoError(t, indexer.Add(newSpreadPVC("disk-b", "fast", map[string]string{spreadGroupKey: "recorded"})))
+	kclient := fake.NewSimpleClientset(newSpreadPVC("disk-a", "fast", map[string]string{spreadGroupKey: "recorded"}))
+
+	cs := &controllerServer{Driver: &Driver{kclient: kclient, pvcLister: v1.NewPersistentVolumeClaimLister(indexer)}}
+	params := map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk-b"}
+	assert.Empty(t, cs.getSpreadGroupVolumes(context.TODO(), params, "recorded"))
+
+	cs.recordSpreadGroupVolume(context.TODO(), "recorded", map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk-a"}, "vol-0")
+	assert.Equal(t, []string{"vol-0"}, cs.getSpreadGroupVolumes(context.TODO(), params, "recorded"))
+}
+
+func TestLockSpreadGroup(t *testing.T) {
+	params := map[string]string{sharedcsi.PvcNamespaceKey: "default"}
+	unlock := lockSpreadGroup(params, "raid")
+
+	locked := make(chan struct{})
+	go func() {
+		defer lockSpreadGroup(params, "raid")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the spread group was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+}
+
+func TestRecordSpreadGroupVolume(t *testing.T) {
+	kclient := fake.NewSimpleClientset(newSpreadPVC("disk-a", "fast", map[string]string{spreadGroupKey: "raid"}))
+	cs := &controllerServer{Driver: &Driver{kclient: kclient}}
+	params := map[string]string{sharedcsi.PvcNamespaceKey: "default", sharedcsi.PvcNameKey: "disk-a"}
+
+	cs.recordSpreadGroupVolume(context.TODO(), "raid", params, "vol-0")
+
+	pvc, err := kclient.CoreV1().PersistentVolumeClaims("default").Get(context.TODO(), "disk-a", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "vol-0", pvc.Annotations[spreadVolumeIDKey])
+	assert.Equal(t, "raid", pvc.Annotations[spreadGroupKey])
+}
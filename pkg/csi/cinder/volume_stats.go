@@ -0,0 +1,182 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/cloud-provider-openstack/pkg/util/mount"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+const defaultVolumeStatsConcurrency = 10
+
+var (
+	volumeStatsCacheHits = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "cinder_csi_volume_stats_cache_hits_total",
+			Help: "Total number of volume stats served from the cache",
+		})
+	volumeStatsCacheMisses = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "cinder_csi_volume_stats_cache_misses_total",
+			Help: "Total number of volume stats collected because the cache had no fresh entry",
+		})
+	volumeStatsStale = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "cinder_csi_volume_stats_stale_total",
+			Help: "Total number of expired volume stats served because the collection exceeded its budget",
+		})
+	volumeStatsAge = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Name:    "cinder_csi_volume_stats_age_seconds",
+			Help:    "Age of the volume stats served from the cache",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+		})
+
+	registerVolumeStatsMetrics sync.Once
+)
+
+// RegisterVolumeStatsMetrics registers the volume stats cache metrics.
+func RegisterVolumeStatsMetrics() {
+	registerVolumeStatsMetrics.Do(func() {
+		legacyregistry.MustRegister(volumeStatsCacheHits, volumeStatsCacheMisses, volumeStatsStale, volumeStatsAge)
+	})
+}
+
+type volumeStatsEntry struct {
+	stats     *mount.DeviceStats
+	updatedAt time.Time
+}
+
+// volumeStatsRefresh is a collection of the stats of a volume path in progress, shared by all the callers asking for
+// the same path meanwhile.
+type volumeStatsRefresh struct {
+	done  chan struct{}
+	stats *mount.DeviceStats
+	err   error
+}
+
+// volumeStatsCache caches the stats of the volume paths for a short time, so that the nodes with many volumes don't
+// resolve the device and call statfs for every volume each time kubelet collects the stats. At most concurrency
+// collections run at the same time. When a collection takes longer than the budget, the expired stats are served
+// instead and the cache is updated in the background once the collection completes.
+type volumeStatsCache struct {
+	mu       sync.Mutex
+	entries  map[string]*volumeStatsEntry
+	inflight map[string]*volumeStatsRefresh
+
+	ttl    time.Duration
+	budget time.Duration
+	sem    chan struct{}
+	fetch  func(path string) (*mount.DeviceStats, error)
+	now    func() time.Time
+}
+
+func newVolumeStatsCache(ttl, budget time.Duration, concurrency int, fetch func(path string) (*mount.DeviceStats, error)) *volumeStatsCache {
+	if concurrency <= 0 {
+		concurrency = defaultVolumeStatsConcurrency
+	}
+
+	RegisterVolumeStatsMetrics()
+
+	return &volumeStatsCache{
+		entries:  make(map[string]*volumeStatsEntry),
+		inflight: make(map[string]*volumeStatsRefresh),
+		ttl:      ttl,
+		budget:   budget,
+		sem:      make(chan struct{}, concurrency),
+		fetch:    fetch,
+		now:      time.Now,
+	}
+}
+
+// get returns the stats of the volume path, from the cache when they are fresh enough.
+func (c *volumeStatsCache) get(ctx context.Context, path string) (*mount.DeviceStats, error) {
+	c.mu.Lock()
+	entry := c.entries[path]
+	if entry != nil && c.now().Sub(entry.updatedAt) < c.ttl {
+		c.mu.Unlock()
+		volumeStatsCacheHits.Inc()
+		volumeStatsAge.Observe(c.now().Sub(entry.updatedAt).Seconds())
+		return entry.stats, nil
+	}
+
+	r, ok := c.inflight[path]
+	if !ok {
+		r = &volumeStatsRefresh{done: make(chan struct{})}
+		c.inflight[path] = r
+		go c.refresh(path, r)
+	}
+	c.mu.Unlock()
+	volumeStatsCacheMisses.Inc()
+
+	// The budget only applies when there is something to fall back to.
+	var budget <-chan time.Time
+	if entry != nil && c.budget > 0 {
+		timer := time.NewTimer(c.budget)
+		defer timer.Stop()
+		budget = timer.C
+	}
+
+	select {
+	case <-r.done:
+		return r.stats, r.err
+	case <-budget:
+		age := c.now().Sub(entry.updatedAt)
+		klog.V(4).Infof("Collecting stats of %s exceeded the budget of %v, serving stats from %v ago", path, c.budget, age)
+		volumeStatsStale.Inc()
+		volumeStatsAge.Observe(age.Seconds())
+		return entry.stats, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *volumeStatsCache) refresh(path string, r *volumeStatsRefresh) {
+	c.sem <- struct{}{}
+	stats, err := c.fetch(path)
+	<-c.sem
+
+	c.mu.Lock()
+	// The path may have been removed meanwhile, in which case the result must not be cached.
+	if c.inflight[path] == r {
+		delete(c.inflight, path)
+		if err != nil {
+			delete(c.entries, path)
+		} else {
+			c.entries[path] = &volumeStatsEntry{stats: stats, updatedAt: c.now()}
+		}
+	}
+	c.mu.Unlock()
+
+	r.stats, r.err = stats, err
+	close(r.done)
+}
+
+// remove drops the cached stats of the volume path, e.g. when the volume is unpublished.
+func (c *volumeStatsCache) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, path)
+	delete(c.inflight, path)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/cloud-provider-openstack/pkg/util/mount"
+)
+
+func TestVolumeStatsCache(t *testing.T) {
+	var calls atomic.Int32
+	fetch := func(path string) (*mount.DeviceStats, error) {
+		n := calls.Add(1)
+		return &mount.DeviceStats{TotalBytes: int64(n)}, nil
+	}
+
+	now := time.Now()
+	c := newVolumeStatsCache(time.Minute, 0, 0, fetch)
+	c.now = func() time.Time { return now }
+
+	stats, err := c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalBytes)
+
+	// Served from the cache until the TTL elapses
+	stats, err = c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalBytes)
+
+	now = now.Add(time.Minute)
+	stats, err = c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalBytes)
+
+	c.remove("/path")
+	stats, err = c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalBytes)
+}
+
+func TestVolumeStatsCacheBudget(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fetch := func(path string) (*mount.DeviceStats, error) {
+		if calls.Add(1) > 1 {
+			<-release
+		}
+		return &mount.DeviceStats{TotalBytes: int64(calls.Load())}, nil
+	}
+
+	now := time.Now()
+	c := newVolumeStatsCache(time.Minute, 10*time.Millisecond, 0, fetch)
+	c.now = func() time.Time { return now }
+
+	_, err := c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+
+	// The expired stats are served when the collection exceeds the budget
+	now = now.Add(time.Minute)
+	stats, err := c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalBytes)
+
+	// and updated in the background once it completes
+	close(release)
+	assert.Eventually(t, func() bool {
+		stats, err := c.get(context.TODO(), "/path")
+		return err == nil && stats.TotalBytes == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestVolumeStatsCacheError(t *testing.T) {
+	fetchErr := errors.New("no such file or directory")
+	fail := false
+	fetch := func(path string) (*mount.DeviceStats, error) {
+		if fail {
+			return nil, fetchErr
+		}
+		return &mount.DeviceStats{}, nil
+	}
+
+	now := time.Now()
+	c := newVolumeStatsCache(time.Minute, time.Second, 0, fetch)
+	c.now = func() time.Time { return now }
+
+	_, err := c.get(context.TODO(), "/path")
+	assert.NoError(t, err)
+
+	fail = true
+	now = now.Add(time.Minute)
+	_, err = c.get(context.TODO(), "/path")
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Empty(t, c.entries)
+}
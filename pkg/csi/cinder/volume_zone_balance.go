@@ -0,0 +1,293 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+// zoneBalanceMaxUtilization is the share of the attach capacity of the schedulable nodes of a zone above which the
+// zone is flagged: its stateful pods are likely to be unschedulable once some of its nodes are drained.
+const zoneBalanceMaxUtilization = 0.8
+
+// unschedulableTaints are the taints keeping the stateful pods off a node: the node is not ready, unreachable,
+// cordoned, out of service, being removed, or a control plane node. The other taints, e.g. of the node pools dedicated
+// to the stateful workloads, are assumed to be tolerated by their pods.
+var unschedulableTaints = sets.New(
+	corev1.TaintNodeNotReady,
+	corev1.TaintNodeUnreachable,
+	corev1.TaintNodeUnschedulable,
+	corev1.TaintNodeOutOfService,
+	corev1.TaintNodeNetworkUnavailable,
+	"node.cloudprovider.kubernetes.io/uninitialized",
+	"node.cloudprovider.kubernetes.io/shutdown",
+	"node-role.kubernetes.io/control-plane",
+	"ToBeDeletedByClusterAutoscaler",
+)
+
+var (
+	zoneVolumes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cinder_csi_zone_volumes",
+			Help: "Number of bound PersistentVolumes pinned to the availability zone at the last report",
+		}, []string{"zone"})
+	zoneSchedulableNodes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cinder_csi_zone_schedulable_nodes",
+			Help: "Number of schedulable nodes running the node plugin in the availability zone at the last report",
+		}, []string{"zone"})
+	zoneAttachCapacity = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cinder_csi_zone_attach_capacity",
+			Help: "Number of volumes that can be attached to the schedulable nodes of the availability zone at the last report",
+		}, []string{"zone"})
+	zoneImbalanced = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cinder_csi_zone_imbalanced",
+			Help: "Whether the stateful pods of the availability zone are likely to be unschedulable at the last report",
+		}, []string{"zone"})
+
+	registerZoneBalanceMetrics sync.Once
+)
+
+// zoneBalance is the number of PVs pinned to an availability zone and the capacity of its nodes to attach them.
+type zoneBalance struct {
+	volumes        int
+	nodes          int
+	attachCapacity int64
+}
+
+// imbalance returns why the stateful pods of the zone are likely to be unschedulable, or an empty string.
+func (b *zoneBalance) imbalance() string {
+	switch {
+	case b.volumes == 0:
+		return ""
+	case b.nodes == 0:
+		return fmt.Sprintf("%d volumes and no schedulable node", b.volumes)
+	case float64(b.volumes) > zoneBalanceMaxUtilization*float64(b.attachCapacity):
+		return fmt.Sprintf("%d volumes for an attach capacity of %d on %d schedulable nodes", b.volumes, b.attachCapacity, b.nodes)
+	}
+	return ""
+}
+
+// volumeZoneBalanceReporter periodically compares the availability zones of the PVs with the attach capacity of the
+// schedulable nodes of the zones, and flags the zones whose stateful pods are likely to be unschedulable, before they
+// are. The report is logged and served as metrics. The PVs, nodes and CSINodes are read from the caches of informers
+// rather than listed from the API server at every report.
+type volumeZoneBalanceReporter struct {
+	factory       informers.SharedInformerFactory
+	pvLister      corelisters.PersistentVolumeLister
+	nodeLister    corelisters.NodeLister
+	csiNodeLister storagelisters.CSINodeLister
+	interval      time.Duration
+
+	// reportedZones are the zones of the metrics set by the last report
+	reportedZones sets.Set[string]
+}
+
+// newVolumeZoneBalanceReporter returns a reporter watching the objects with the client, the PVs are read from pvLister
+// when set, e.g. by --pv-annotations.
+func newVolumeZoneBalanceReporter(kclient kubernetes.Interface, pvLister corelisters.PersistentVolumeLister, interval time.Duration) *volumeZoneBalanceReporter {
+	registerZoneBalanceMetrics.Do(func() {
+		legacyregistry.MustRegister(zoneVolumes, zoneSchedulableNodes, zoneAttachCapacity, zoneImbalanced)
+	})
+
+	factory := informers.NewSharedInformerFactory(kclient, 0)
+	if pvLister == nil {
+		pvLister = factory.Core().V1().PersistentVolumes().Lister()
+	}
+	return &volumeZoneBalanceReporter{
+		factory:       factory,
+		pvLister:      pvLister,
+		nodeLister:    factory.Core().V1().Nodes().Lister(),
+		csiNodeLister: factory.Storage().V1().CSINodes().Lister(),
+		interval:      interval,
+		reportedZones: sets.New[string](),
+	}
+}
+
+// start starts the informers and waits for their caches to sync.
+func (r *volumeZoneBalanceReporter) start(stopCh <-chan struct{}) error {
+	r.factory.Start(stopCh)
+	for informer, synced := range r.factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync the cache of %v", informer)
+		}
+	}
+	return nil
+}
+
+func (r *volumeZoneBalanceReporter) run(stopCh <-chan struct{}) {
+	if err := r.start(stopCh); err != nil {
+		klog.Errorf("Failed to report the balance of the PersistentVolumes across the availability zones: %v", err)
+		return
+	}
+
+	klog.Infof("Reporting the balance of the PersistentVolumes across the availability zones every %v", r.interval)
+	wait.Until(func() {
+		if err := r.report(); err != nil {
+			klog.Warningf("Failed to report the balance of the PersistentVolumes across the availability zones: %v", err)
+		}
+	}, r.interval, stopCh)
+}
+
+// report updates the metrics of the zones and logs the imbalanced ones.
+func (r *volumeZoneBalanceReporter) report() error {
+	zones, err := r.balance()
+	if err != nil {
+		return err
+	}
+
+	// The metrics are updated in place rather than reset first, a scrape never sees them empty
+	for zone, b := range zones {
+		zoneVolumes.WithLabelValues(zone).Set(float64(b.volumes))
+		zoneSchedulableNodes.WithLabelValues(zone).Set(float64(b.nodes))
+		zoneAttachCapacity.WithLabelValues(zone).Set(float64(b.attachCapacity))
+
+		imbalanced := 0.0
+		if reason := b.imbalance(); reason != "" {
+			klog.Warningf("The stateful pods of availability zone %s are likely to be unschedulable: %s", zone, reason)
+			imbalanced = 1
+		}
+		zoneImbalanced.WithLabelValues(zone).Set(imbalanced)
+	}
+
+	// The zones without PVs nor nodes anymore are no longer reported
+	for zone := range r.reportedZones {
+		if _, ok := zones[zone]; ok {
+			continue
+		}
+		zoneLabels := map[string]string{"zone": zone}
+		zoneVolumes.Delete(zoneLabels)
+		zoneSchedulableNodes.Delete(zoneLabels)
+		zoneAttachCapacity.Delete(zoneLabels)
+		zoneImbalanced.Delete(zoneLabels)
+	}
+	r.reportedZones = sets.KeySet(zones)
+
+	return nil
+}
+
+// balance returns the balance of the zones of the bound PVs and of the schedulable nodes running the node plugin.
+func (r *volumeZoneBalanceReporter) balance() (map[string]*zoneBalance, error) {
+	zones := make(map[string]*zoneBalance)
+	zoneBalanceOf := func(zone string) *zoneBalance {
+		b, ok := zones[zone]
+		if !ok {
+			b = &zoneBalance{}
+			zones[zone] = b
+		}
+		return b
+	}
+
+	pvs, err := r.pvLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the PersistentVolumes: %v", err)
+	}
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName || pv.Status.Phase != corev1.VolumeBound {
+			continue
+		}
+		// The PVs not pinned to a zone, e.g. with ignore-volume-az, can be attached anywhere
+		if zone := pvZone(pv); zone != "" {
+			zoneBalanceOf(zone).volumes++
+		}
+	}
+
+	// The attach limits reported by the node plugins
+	csiNodes, err := r.csiNodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the CSINodes: %v", err)
+	}
+	attachLimits := make(map[string]int64)
+	for _, csiNode := range csiNodes {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Name != driverName {
+				continue
+			}
+			attachLimits[csiNode.Name] = maxVolumesPerNode
+			if driver.Allocatable != nil && driver.Allocatable.Count != nil {
+				attachLimits[csiNode.Name] = int64(*driver.Allocatable.Count)
+			}
+		}
+	}
+
+	nodes, err := r.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the nodes: %v", err)
+	}
+	for _, node := range nodes {
+		attachLimit, ok := attachLimits[node.Name]
+		zone := node.Labels[topologyKey]
+		if !ok || zone == "" || !nodeSchedulable(node) {
+			continue
+		}
+		b := zoneBalanceOf(zone)
+		b.nodes++
+		b.attachCapacity += attachLimit
+	}
+
+	return zones, nil
+}
+
+// pvZone returns the availability zone the PV is pinned to by its node affinity, or an empty string.
+func pvZone(pv *corev1.PersistentVolume) string {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return ""
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == topologyKey && expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) == 1 {
+				return expr.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// nodeSchedulable checks whether new stateful pods can be scheduled on the node: it is ready, not cordoned and has none
+// of the unschedulableTaints.
+func nodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect != corev1.TaintEffectPreferNoSchedule && unschedulableTaints.Has(taint.Key) {
+			return false
+		}
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cinder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/utils/ptr"
+)
+
+func newZonePV(name, zone string, phase corev1.PersistentVolumePhase) *corev1.PersistentVolume {
+	pv := newCinderPV(name, name, nil)
+	pv.Status.Phase = phase
+	if zone != "" {
+		pv.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{
+			Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: topologyKey, Operator: corev1.NodeSelectorOpIn, Values: []string{zone}},
+				},
+			}}},
+		}
+	}
+	return pv
+}
+
+func newZoneNode(name, zone string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{topologyKey: zone}},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+	}
+}
+
+func newZoneCSINode(name string, limit *int32) *storagev1.CSINode {
+	driver := storagev1.CSINodeDriver{Name: driverName, NodeID: name}
+	if limit != nil {
+		driver.Allocatable = &storagev1.VolumeNodeResources{Count: limit}
+	}
+	return &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{driver}},
+	}
+}
+
+func TestVolumeZoneBalanceReport(t *testing.T) {
+	cordoned := newZoneNode("az1-cordoned", "az1", true)
+	cordoned.Spec.Unschedulable = true
+	tainted := newZoneNode("az1-tainted", "az1", true)
+	tainted.Spec.Taints = []corev1.Taint{{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}}
+	unreachable := newZoneNode("az1-unreachable", "az1", true)
+	unreachable.Spec.Taints = []corev1.Taint{{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute}}
+	// The stateful pods tolerate the taints of their dedicated nodes
+	dedicated := newZoneNode("az2-dedicated", "az2", true)
+	dedicated.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "database", Effect: corev1.TaintEffectNoSchedule}}
+	foreign := newZonePV("az1-foreign", "az1", corev1.VolumeBound)
+	foreign.Spec.CSI.Driver = "other.csi.k8s.io"
+
+	kclient := fake.NewSimpleClientset(
+		newZonePV("az1-a", "az1", corev1.VolumeBound),
+		newZonePV("az1-b", "az1", corev1.VolumeBound),
+		newZonePV("az1-released", "az1", corev1.VolumeReleased),
+		foreign,
+		newZonePV("az2-a", "az2", corev1.VolumeBound),
+		newZonePV("az3-a", "az3", corev1.VolumeBound),
+		newZonePV("anywhere", "", corev1.VolumeBound),
+
+		// az1 can only attach 2 volumes on a schedulable node
+		newZoneNode("az1-node", "az1", true), newZoneCSINode("az1-node", ptr.To[int32](2)),
+		cordoned, newZoneCSINode("az1-cordoned", ptr.To[int32](24)),
+		tainted, newZoneCSINode("az1-tainted", ptr.To[int32](24)),
+		unreachable, newZoneCSINode("az1-unreachable", ptr.To[int32](24)),
+		newZoneNode("az1-no-plugin", "az1", true),
+		// az2 has no attach limit
+		newZoneNode("az2-node", "az2", true), newZoneCSINode("az2-node", nil),
+		dedicated, newZoneCSINode("az2-dedicated", ptr.To[int32](10)),
+		// az3 has no ready node
+		newZoneNode("az3-node", "az3", false), newZoneCSINode("az3-node", ptr.To[int32](24)),
+	)
+
+	r := newVolumeZoneBalanceReporter(kclient, nil, 0)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.NoError(t, r.start(stopCh))
+
+	zones, err := r.balance()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*zoneBalance{
+		"az1": {volumes: 2, nodes: 1, attachCapacity: 2},
+		"az2": {volumes: 1, nodes: 2, attachCapacity: maxVolumesPerNode + 10},
+		"az3": {volumes: 1},
+	}, zones)
+
+	assert.NotEmpty(t, zones["az1"].imbalance())
+	assert.Empty(t, zones["az2"].imbalance())
+	assert.NotEmpty(t, zones["az3"].imbalance())
+	assert.Empty(t, (&zoneBalance{nodes: 1, attachCapacity: 0}).imbalance())
+
+	assert.NoError(t, r.report())
+	for zone, expected := range map[string]float64{"az1": 1, "az2": 0, "az3": 1} {
+		value, err := testutil.GetGaugeMetricValue(zoneImbalanced.WithLabelValues(zone))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value, zone)
+	}
+	value, err := testutil.GetGaugeMetricValue(zoneAttachCapacity.WithLabelValues("az2"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(maxVolumesPerNode+10), value)
+
+	// The zone no longer having PVs nor nodes is removed
+	zoneVolumes.WithLabelValues("az4").Set(1)
+	r.reportedZones.Insert("az4")
+	assert.NoError(t, r.report())
+	assert.False(t, zoneVolumes.Delete(map[string]string{"zone": "az4"}))
+	assert.Equal(t, sets.New("az1", "az2", "az3"), r.reportedZones)
+}
@@ -18,12 +18,16 @@ package csi
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -44,6 +48,9 @@ const (
 	PvNameKey       = "csi.storage.k8s.io/pv/name"
 	// https://github.com/kubernetes/kubernetes/pull/79983
 	VolEphemeralKey = "csi.storage.k8s.io/ephemeral"
+	// Passed to NodePublishVolume when the CSIDriver has podInfoOnMount set
+	PodNameKey      = "csi.storage.k8s.io/pod.name"
+	PodNamespaceKey = "csi.storage.k8s.io/pod.namespace"
 )
 
 var (
@@ -68,6 +75,9 @@ var (
 var (
 	// CSI controller options
 	pvcAnnotations bool
+	// CSI node options
+	pvAnnotations  bool
+	podAnnotations bool
 	// k8s client options
 	master          string
 	kubeconfig      string
@@ -84,6 +94,8 @@ func AddPVCFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().DurationVar(&minResyncPeriod, "min-resync-period", 12*time.Hour, "The resync period in reflectors will be random between MinResyncPeriod and 2*MinResyncPeriod.")
 
 	cmd.PersistentFlags().BoolVar(&pvcAnnotations, "pvc-annotations", false, "Enable support for PVC annotations in the controller's CreateVolume CSI method (enabling this flag requires enabling the --extra-create-metadata flag in csi-provisioner)")
+	cmd.PersistentFlags().BoolVar(&pvAnnotations, "pv-annotations", false, "Enable support for PV annotations in the node's NodeStageVolume CSI method (enabling this flag requires granting the node plugin read access to PersistentVolumes)")
+	cmd.PersistentFlags().BoolVar(&podAnnotations, "pod-annotations", false, "Enable support for pod annotations in the node's NodePublishVolume CSI method (enabling this flag requires granting the node plugin read access to Pods)")
 }
 
 func GetAZFromTopology(topologyKey string, requirement *csi.TopologyRequirement) string {
@@ -111,11 +123,121 @@ func GetAZFromTopology(topologyKey string, requirement *csi.TopologyRequirement)
 	return zone
 }
 
+// GetAZsFromTopology returns all the AZs allowed by the topology requirement, the preferred ones first.
+func GetAZsFromTopology(topologyKey string, requirement *csi.TopologyRequirement) []string {
+	var zones []string
+	seen := make(map[string]bool)
+	for _, topology := range slices.Concat(requirement.GetPreferred(), requirement.GetRequisite()) {
+		if zone, exists := topology.GetSegments()[topologyKey]; exists && !seen[zone] {
+			seen[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+
+	return zones
+}
+
 func GetPVCLister() v1.PersistentVolumeClaimLister {
 	if !pvcAnnotations {
 		return nil
 	}
 
+	factory := informers.NewSharedInformerFactory(GetKubeClient(), resyncPeriod(minResyncPeriod))
+	ctx := context.TODO()
+	pvcInformer := factory.Core().V1().PersistentVolumeClaims().Informer()
+	go pvcInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), pvcInformer.HasSynced) {
+		klog.Fatal("Error syncing PVC informer cache")
+	}
+
+	klog.Info("Successully created PVC Annotations Lister")
+
+	return factory.Core().V1().PersistentVolumeClaims().Lister()
+}
+
+func GetPVLister() PVLister {
+	if !pvAnnotations {
+		return nil
+	}
+
+	factory := informers.NewSharedInformerFactory(GetKubeClient(), resyncPeriod(minResyncPeriod))
+	ctx := context.TODO()
+	pvInformer := factory.Core().V1().PersistentVolumes().Informer()
+	lister, err := NewPVLister(pvInformer.GetIndexer())
+	if err != nil {
+		klog.Fatalf("Failed to index the PVs by volume handle: %v", err)
+	}
+	go pvInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), pvInformer.HasSynced) {
+		klog.Fatal("Error syncing PV informer cache")
+	}
+
+	klog.Info("Successully created PV Annotations Lister")
+
+	return lister
+}
+
+// PVLister is a PersistentVolumeLister also getting the PVs by their CSI volume handle.
+type PVLister interface {
+	v1.PersistentVolumeLister
+	// GetByVolumeHandle returns the PV of the volume provisioned by the CSI driver, nil if there's none.
+	GetByVolumeHandle(driverName string, volumeID string) (*corev1.PersistentVolume, error)
+}
+
+// pvVolumeHandleIndex is the index of the PVs by CSI driver and volume handle.
+const pvVolumeHandleIndex = "volumeHandle"
+
+func pvVolumeHandleIndexFunc(obj interface{}) ([]string, error) {
+	pv, ok := obj.(*corev1.PersistentVolume)
+	if !ok || pv.Spec.CSI == nil {
+		return nil, nil
+	}
+	return []string{pv.Spec.CSI.Driver + "/" + pv.Spec.CSI.VolumeHandle}, nil
+}
+
+type pvLister struct {
+	v1.PersistentVolumeLister
+	indexer cache.Indexer
+}
+
+// NewPVLister returns a PVLister reading the PVs from the indexer, it adds the volume handle index to the indexer.
+func NewPVLister(indexer cache.Indexer) (PVLister, error) {
+	if err := indexer.AddIndexers(cache.Indexers{pvVolumeHandleIndex: pvVolumeHandleIndexFunc}); err != nil {
+		return nil, err
+	}
+	return &pvLister{PersistentVolumeLister: v1.NewPersistentVolumeLister(indexer), indexer: indexer}, nil
+}
+
+func (l *pvLister) GetByVolumeHandle(driverName string, volumeID string) (*corev1.PersistentVolume, error) {
+	objs, err := l.indexer.ByIndex(pvVolumeHandleIndex, driverName+"/"+volumeID)
+	if err != nil || len(objs) == 0 {
+		return nil, err
+	}
+	return objs[0].(*corev1.PersistentVolume), nil
+}
+
+// GetPodClient returns a client of the Kubernetes API to read the annotations of the pods, nil unless the
+// --pod-annotations flag is set. The pods are read on demand rather than cached, as a node only needs its own pods.
+func GetPodClient() kubernetes.Interface {
+	if !podAnnotations {
+		return nil
+	}
+
+	return GetKubeClient()
+}
+
+// GetSecretClient returns a client of the Kubernetes API to read the Secrets named by the PVC annotations, nil unless
+// the --pvc-annotations flag is set.
+func GetSecretClient() kubernetes.Interface {
+	if !pvcAnnotations {
+		return nil
+	}
+
+	return GetKubeClient()
+}
+
+// GetKubeClient returns a client of the Kubernetes API, configured by the flags added by AddPVCFlags.
+func GetKubeClient() kubernetes.Interface {
 	// get the KUBECONFIG from env if specified (useful for local/debug cluster)
 	kubeconfigEnv := os.Getenv("KUBECONFIG")
 
@@ -138,17 +260,7 @@ func GetPVCLister() v1.PersistentVolumeClaimLister {
 		klog.Fatalf("Failed to create client: %v", err)
 	}
 
-	factory := informers.NewSharedInformerFactory(clientset, resyncPeriod(minResyncPeriod))
-	ctx := context.TODO()
-	pvcInformer := factory.Core().V1().PersistentVolumeClaims().Informer()
-	go pvcInformer.Run(ctx.Done())
-	if !cache.WaitForCacheSync(ctx.Done(), pvcInformer.HasSynced) {
-		klog.Fatal("Error syncing PVC informer cache")
-	}
-
-	klog.Info("Successully created PVC Annotations Lister")
-
-	return factory.Core().V1().PersistentVolumeClaims().Lister()
+	return clientset
 }
 
 // GetPVCAnnotations returns PVC annotations for the given PVC name and
@@ -174,6 +286,68 @@ func GetPVCAnnotations(pvcLister v1.PersistentVolumeClaimLister, params map[stri
 	return pvc.Annotations
 }
 
+// GetPVAnnotations returns annotations of the PV provisioned by the given
+// CSI driver for the given volume handle.
+func GetPVAnnotations(pvLister PVLister, driverName string, volumeID string) map[string]string {
+	if pvLister == nil {
+		return nil
+	}
+
+	pv, err := pvLister.GetByVolumeHandle(driverName, volumeID)
+	if err != nil {
+		klog.Errorf("Failed to get the PV of volume %s: %v", volumeID, err)
+		return nil
+	}
+	if pv == nil {
+		klog.Errorf("Failed to find PV for volume %s", volumeID)
+		return nil
+	}
+
+	return pv.Annotations
+}
+
+// GetPodAnnotations returns annotations of the pod the volume is published
+// for, as passed in the volume context of NodePublishVolume. It fails when
+// the pod can't be retrieved, so that the annotations aren't ignored.
+func GetPodAnnotations(ctx context.Context, client kubernetes.Interface, volumeContext map[string]string) (map[string]string, error) {
+	if client == nil {
+		return nil, fmt.Errorf("reading the pod annotations requires the --pod-annotations flag")
+	}
+
+	namespace := volumeContext[PodNamespaceKey]
+	podName := volumeContext[PodNameKey]
+	if namespace == "" || podName == "" {
+		return nil, fmt.Errorf("invalid namespace or pod name (%s/%s), check whether podInfoOnMount is set in the CSIDriver", namespace, podName)
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
+	}
+
+	return pod.Annotations, nil
+}
+
+// GetPVCNamespaceSecret returns the data of the Secret of the namespace of the PVC stored in the params map. Only the
+// Secrets of the namespace of the PVC can be read, so that a PVC cannot reveal the Secrets of other namespaces.
+func GetPVCNamespaceSecret(ctx context.Context, client kubernetes.Interface, params map[string]string, name string) (map[string][]byte, error) {
+	if client == nil {
+		return nil, fmt.Errorf("reading Secrets requires the --pvc-annotations flag")
+	}
+
+	namespace := params[PvcNamespaceKey]
+	if namespace == "" {
+		return nil, fmt.Errorf("invalid PVC namespace, check whether the --extra-create-metadata flag is set in csi-provisioner")
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s: %v", namespace, name, err)
+	}
+
+	return secret.Data, nil
+}
+
 // resyncPeriod generates a random duration so that multiple controllers don't
 // get into lock-step and all hammer the apiserver with list requests
 // simultaneously. Copied from the
@@ -21,6 +21,7 @@ import (
 
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/shareadapters"
 	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
 )
 
 func getShareAdapter(proto string) shareadapters.ShareAdapter {
@@ -35,3 +36,41 @@ func getShareAdapter(proto string) shareadapters.ShareAdapter {
 
 	return nil
 }
+
+// isShareProtocolSupported checks whether the share protocol has a share adapter.
+func isShareProtocolSupported(proto string) bool {
+	switch strings.ToUpper(proto) {
+	case "CEPHFS", "NFS":
+		return true
+	default:
+		return false
+	}
+}
+
+// getMountShareProtocol returns the share protocol of the filesystem mounted at path, empty if there is none.
+func getMountShareProtocol(path string) (string, error) {
+	mountPoints, err := mount.New("").List()
+	if err != nil {
+		return "", err
+	}
+
+	for _, mp := range mountPoints {
+		if mp.Path == path {
+			return filesystemShareProtocol(mp.Type), nil
+		}
+	}
+
+	return "", nil
+}
+
+// filesystemShareProtocol returns the share protocol of the filesystem type, empty if it is not a share.
+func filesystemShareProtocol(fsType string) string {
+	switch {
+	case strings.HasPrefix(fsType, "nfs"):
+		return "NFS"
+	case fsType == "ceph", fsType == "fuse.ceph-fuse":
+		return "CEPHFS"
+	default:
+		return ""
+	}
+}
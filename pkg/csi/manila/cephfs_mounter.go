@@ -0,0 +1,131 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+)
+
+const (
+	cephfsMounterAuto   = "auto"
+	cephfsMounterKernel = "kernel"
+	cephfsMounterFuse   = "fuse"
+)
+
+var (
+	// cephfsKernelMinVersion is the first kernel release enforcing the CephFS quotas, which the size of the shares
+	// relies on.
+	cephfsKernelMinVersion = version.MustParseGeneric("4.17")
+
+	// cephfsKernelOptionVersions are the kernel releases supporting the mount options unknown to older kernel clients.
+	// These options are only known to the kernel client.
+	cephfsKernelOptionVersions = map[string]*version.Version{
+		"recover_session": version.MustParseGeneric("5.4"),
+		"ms_mode":         version.MustParseGeneric("5.11"),
+	}
+)
+
+// getKernelRelease returns the release of the running kernel.
+func getKernelRelease() (string, error) {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(release)), nil
+}
+
+// splitMountOptions splits comma separated mount options, dropping the empty ones.
+func splitMountOptions(opts string) []string {
+	var split []string
+	for _, opt := range strings.Split(opts, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			split = append(split, opt)
+		}
+	}
+
+	return split
+}
+
+// cephfsKernelRequiredVersion returns the kernel release required to mount a share with the kernel client and the
+// mount options.
+func cephfsKernelRequiredVersion(mountOptions []string) *version.Version {
+	required := cephfsKernelMinVersion
+	for _, opt := range mountOptions {
+		name, _, _ := strings.Cut(opt, "=")
+		if v, ok := cephfsKernelOptionVersions[name]; ok && v.GreaterThan(required) {
+			required = v
+		}
+	}
+
+	return required
+}
+
+// selectCephfsMounter returns the CephFS mounter to use for the volume. The kernel client is preferred when the
+// mounter is set to auto, unless the running kernel is too old for the quotas or the requested mount options.
+func selectCephfsMounter(shareOpts *options.NodeVolumeContext, kernelRelease func() (string, error)) (string, error) {
+	if shareOpts.CephfsMounter != cephfsMounterAuto {
+		return shareOpts.CephfsMounter, nil
+	}
+
+	release, err := kernelRelease()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the kernel release: %v", err)
+	}
+
+	kernelVersion, err := version.ParseGeneric(release)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kernel release %s: %v", release, err)
+	}
+
+	mountOptions := append(splitMountOptions(shareOpts.CephfsMountOptions), splitMountOptions(shareOpts.CephfsKernelMountOptions)...)
+	required := cephfsKernelRequiredVersion(mountOptions)
+
+	if kernelVersion.LessThan(required) {
+		klog.V(4).Infof("kernel %s is older than %s, using the CephFS FUSE client", release, required)
+		return cephfsMounterFuse, nil
+	}
+
+	return cephfsMounterKernel, nil
+}
+
+// filterCephfsMountOptions drops the options only known to the kernel client from the common mount options when the
+// share is mounted with ceph-fuse, which would fail on them.
+func filterCephfsMountOptions(mountOptions, mounter string) string {
+	if mounter != cephfsMounterFuse {
+		return mountOptions
+	}
+
+	var kept []string
+	for _, opt := range splitMountOptions(mountOptions) {
+		name, _, _ := strings.Cut(opt, "=")
+		if _, kernelOnly := cephfsKernelOptionVersions[name]; kernelOnly {
+			klog.V(4).Infof("dropping mount option %s of the CephFS kernel client, the share is mounted with the FUSE client", opt)
+			continue
+		}
+		kept = append(kept, opt)
+	}
+
+	return strings.Join(kept, ",")
+}
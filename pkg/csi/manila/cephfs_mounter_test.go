@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+)
+
+func TestSelectCephfsMounter(t *testing.T) {
+	ts := []struct {
+		opts     options.NodeVolumeContext
+		release  string
+		expected string
+	}{
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "fuse"},
+			release:  "6.1.0",
+			expected: "fuse",
+		},
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "kernel"},
+			release:  "3.10.0-1160.el7.x86_64",
+			expected: "kernel",
+		},
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "auto"},
+			release:  "5.14.0-362.8.1.el9_3.x86_64",
+			expected: "kernel",
+		},
+		{
+			// No quotas
+			opts:     options.NodeVolumeContext{CephfsMounter: "auto"},
+			release:  "4.15.0-213-generic",
+			expected: "fuse",
+		},
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "auto", CephfsMountOptions: "ro,recover_session=clean"},
+			release:  "5.4.0-150-generic",
+			expected: "kernel",
+		},
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "auto", CephfsMountOptions: "recover_session=clean"},
+			release:  "4.18.0-553.el8_10.x86_64",
+			expected: "fuse",
+		},
+		{
+			opts:     options.NodeVolumeContext{CephfsMounter: "auto", CephfsKernelMountOptions: "ms_mode=secure"},
+			release:  "5.10.0-28-amd64",
+			expected: "fuse",
+		},
+	}
+
+	for i := range ts {
+		tc := &ts[i]
+		mounter, err := selectCephfsMounter(&tc.opts, func() (string, error) { return tc.release, nil })
+		if err != nil {
+			t.Errorf("test case %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		if mounter != tc.expected {
+			t.Errorf("test case %d: expected %s, got %s", i, tc.expected, mounter)
+		}
+	}
+}
+
+func TestSelectCephfsMounterKernelReleaseError(t *testing.T) {
+	opts := options.NodeVolumeContext{CephfsMounter: "auto"}
+	if _, err := selectCephfsMounter(&opts, func() (string, error) { return "", errors.New("not found") }); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := selectCephfsMounter(&opts, func() (string, error) { return "unknown", nil }); err == nil {
+		t.Error("expected an error for an invalid kernel release")
+	}
+}
+
+func TestFilterCephfsMountOptions(t *testing.T) {
+	ts := []struct {
+		mountOptions string
+		mounter      string
+		expected     string
+	}{
+		{
+			mountOptions: "ro,recover_session=clean",
+			mounter:      "kernel",
+			expected:     "ro,recover_session=clean",
+		},
+		{
+			mountOptions: "ro, recover_session=clean,ms_mode=secure",
+			mounter:      "fuse",
+			expected:     "ro",
+		},
+		{
+			mountOptions: "",
+			mounter:      "fuse",
+			expected:     "",
+		},
+	}
+
+	for i := range ts {
+		tc := &ts[i]
+		if opts := filterCephfsMountOptions(tc.mountOptions, tc.mounter); opts != tc.expected {
+			t.Errorf("test case %d: expected %q, got %q", i, tc.expected, opts)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package manila
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -40,6 +41,13 @@ const (
 	affinityKey        = "manila.csi.openstack.org/affinity"
 	antiAffinityKey    = "manila.csi.openstack.org/anti-affinity"
 	groupIDKey         = "manila.csi.openstack.org/group-id"
+	transferShareIDKey = "manila.csi.openstack.org/transfer-share-id"
+	transferIDKey      = "manila.csi.openstack.org/transfer-id"
+	// transferAuthKeyKey used to hold the auth key in plain text, it is rejected in favor of transferAuthKeySecretKey.
+	transferAuthKeyKey       = "manila.csi.openstack.org/transfer-auth-key"
+	transferAuthKeySecretKey = "manila.csi.openstack.org/transfer-auth-key-secret"
+	// transferAuthKeySecretField is the field of the Secret holding the auth key of the transfer.
+	transferAuthKeySecretField = "authKey"
 )
 
 type controllerServer struct {
@@ -51,7 +59,35 @@ var (
 	pendingSnapshots = sync.Map{}
 )
 
-func getVolumeCreator(source *csi.VolumeContentSource) (volumeCreator, error) {
+// getVolumeCreator returns the creator of the volume. The auth key of a share transfer is read with readSecret from
+// the Secret of the namespace of the PVC named by its annotation.
+func getVolumeCreator(source *csi.VolumeContentSource, pvcAnnotations map[string]string, readSecret func(name string) (map[string][]byte, error)) (volumeCreator, error) {
+	if shareID, ok := pvcAnnotations[transferShareIDKey]; ok {
+		if _, ok := pvcAnnotations[transferAuthKeyKey]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "the %s annotation is not supported, store the auth key in a Secret named by the %s annotation", transferAuthKeyKey, transferAuthKeySecretKey)
+		}
+		secretName := pvcAnnotations[transferAuthKeySecretKey]
+		transfer := &volumeFromTransfer{
+			shareID:    shareID,
+			transferID: pvcAnnotations[transferIDKey],
+		}
+		if transfer.shareID == "" || transfer.transferID == "" || secretName == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "adopting a share requires the %s, %s and %s annotations", transferShareIDKey, transferIDKey, transferAuthKeySecretKey)
+		}
+		if source != nil {
+			return nil, status.Error(codes.InvalidArgument, "volume content source cannot be set when adopting a share")
+		}
+
+		secret, err := readSecret(secretName)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to read the auth key of transfer %s from Secret %s: %v", transfer.transferID, secretName, err)
+		}
+		if transfer.authKey = string(secret[transferAuthKeySecretField]); transfer.authKey == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "Secret %s has no %s field", secretName, transferAuthKeySecretField)
+		}
+		return transfer, nil
+	}
+
 	if source == nil {
 		return &blankVolume{}, nil
 	}
@@ -91,7 +127,11 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		params = make(map[string]string)
 	}
 
-	params["protocol"] = cs.d.shareProto
+	shareProto, err := cs.d.selectShareProtocol(params["protocol"])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	params["protocol"] = shareProto
 
 	shareOpts, err := options.NewControllerVolumeContext(params)
 	if err != nil {
@@ -141,6 +181,18 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 				Segments: map[string]string{topologyKey: shareOpts.AvailabilityZone},
 			}}
 		}
+
+		// When the nodes publish their share networks, the share is only accessible from the nodes reaching its share network.
+		if hasShareNetworkSegments(accessibleTopologyReq) {
+			if shareOpts.ShareNetworkID == "" && strings.EqualFold(shareOpts.AutoTopology, "true") {
+				shareOpts.ShareNetworkID = getShareNetworkFromTopology(accessibleTopologyReq)
+			}
+
+			if shareOpts.ShareNetworkID != "" && !isShareNetworkReachable(accessibleTopologyReq, shareOpts.ShareNetworkID) {
+				return nil, status.Errorf(codes.ResourceExhausted, "share network %s is not reachable from the requested topology", shareOpts.ShareNetworkID)
+			}
+			accessibleTopology = withShareNetworkTopology(accessibleTopology, shareOpts.ShareNetworkID)
+		}
 	}
 
 	// get the PVC annotation
@@ -174,21 +226,44 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	// Retrieve an existing share or create a new one
 
-	volCreator, err := getVolumeCreator(req.GetVolumeContentSource())
+	volCreator, err := getVolumeCreator(req.GetVolumeContentSource(), pvcAnnotations, func(name string) (map[string][]byte, error) {
+		return sharedcsi.GetPVCNamespaceSecret(ctx, cs.d.kclient, params, name)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if _, adopted := volCreator.(*volumeFromTransfer); adopted && isEncryptionRequested(shareOpts) {
+		return nil, status.Error(codes.InvalidArgument, "encryption cannot be requested when adopting a share")
+	}
+
+	if err := prepareShareEncryption(manilaClient, shareName, shareOpts, shareMetadata); err != nil {
+		return nil, err
+	}
+
 	share, err := volCreator.create(manilaClient, shareName, sizeInGiB, shareOpts, shareMetadata)
 	if err != nil {
+		tryDeleteEncryptionKey(manilaClient, shareName, shareMetadata)
 		return nil, err
 	}
 
-	err = verifyVolumeCompatibility(sizeInGiB, req, share, shareOpts)
+	if _, adopted := volCreator.(*volumeFromTransfer); adopted {
+		// The adopted share keeps its size, which can only be larger than the requested one
+		err = verifyAdoptedVolumeCompatibility(sizeInGiB, share, shareOpts)
+		sizeInGiB = share.Size
+	} else {
+		err = verifyVolumeCompatibility(sizeInGiB, req, share, shareOpts)
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.AlreadyExists, "volume %s already exists, but is incompatible with the request: %v", req.GetName(), err)
 	}
 
+	if cs.d.withTopology {
+		// The share may be created in another zone than the requested ones, e.g. when the availability parameter is set
+		// or when it's restored from a snapshot, the pods must be scheduled in its zone.
+		accessibleTopology = withShareZoneTopology(accessibleTopology, share.AvailabilityZone)
+	}
+
 	// Grant access to the share
 
 	ad := getShareAdapter(shareOpts.Protocol)
@@ -208,6 +283,7 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	volCtx = util.SetMapIfNotEmpty(volCtx, "groupID", share.ShareGroupID)
 	volCtx = util.SetMapIfNotEmpty(volCtx, "affinity", shareOpts.Affinity)
 	volCtx = util.SetMapIfNotEmpty(volCtx, "antiAffinity", shareOpts.AntiAffinity)
+	volCtx = setEncryptionVolumeContext(volCtx, share)
 
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
@@ -240,7 +316,19 @@ func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 		return nil, status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
 	}
 
-	if err := deleteShare(manilaClient, req.GetVolumeId()); err != nil {
+	share, err := manilaClient.GetShareByID(req.GetVolumeId())
+	if err != nil {
+		if clouderrors.IsNotFound(err) {
+			klog.V(4).Infof("volume with share ID %s not found, assuming it to be already deleted", req.GetVolumeId())
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+		return nil, status.Errorf(codes.Internal, "failed to retrieve volume %s: %v", req.GetVolumeId(), err)
+	}
+
+	if err := deleteShareWithEncryptionKey(manilaClient, share); err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
 		return nil, status.Errorf(codes.Internal, "failed to delete volume %s: %v", req.GetVolumeId(), err)
 	}
 
@@ -281,9 +369,9 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 		return nil, status.Errorf(codes.Internal, "failed to retrieve source volume %s when creating snapshot %s: %v", req.GetSourceVolumeId(), req.GetName(), err)
 	}
 
-	if strings.ToUpper(sourceShare.ShareProto) != cs.d.shareProto {
-		return nil, status.Errorf(codes.InvalidArgument, "share protocol mismatch: requested snapshot of %s volume %s, but share protocol selector is set to %s",
-			sourceShare.ShareProto, req.GetSourceVolumeId(), cs.d.shareProto)
+	if !cs.d.servesShareProtocol(sourceShare.ShareProto) {
+		return nil, status.Errorf(codes.InvalidArgument, "share protocol mismatch: requested snapshot of %s volume %s, but the driver operates on %v shares",
+			sourceShare.ShareProto, req.GetSourceVolumeId(), cs.d.shareProtocols())
 	}
 
 	// In order to satisfy CSI spec requirements around CREATE_DELETE_SNAPSHOT
@@ -431,8 +519,8 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 		return nil, status.Errorf(codes.FailedPrecondition, "volume %s is in an unexpected state: wanted %s, got %s", req.GetVolumeId(), shareAvailable, share.Status)
 	}
 
-	if !compareProtocol(share.ShareProto, cs.d.shareProto) {
-		return nil, status.Errorf(codes.InvalidArgument, "share protocol mismatch: wanted %s, got %s", cs.d.shareProto, share.ShareProto)
+	if !cs.d.servesShareProtocol(share.ShareProto) {
+		return nil, status.Errorf(codes.InvalidArgument, "share protocol mismatch: wanted one of %v, got %s", cs.d.shareProtocols(), share.ShareProto)
 	}
 
 	return &csi.ValidateVolumeCapabilitiesResponse{
@@ -460,8 +548,52 @@ func (cs *controllerServer) GetCapacity(context.Context, *csi.GetCapacityRequest
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
-func (cs *controllerServer) ListSnapshots(context.Context, *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "max_entries must not be negative, got %d", req.GetMaxEntries())
+	}
+
+	start := 0
+	if token := req.GetStartingToken(); token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil || start < 0 {
+			return nil, status.Errorf(codes.Aborted, "invalid starting token %q", token)
+		}
+	}
+
+	osOpts, err := options.NewOpenstackOptions(req.GetSecrets())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid OpenStack secrets: %v", err)
+	}
+
+	manilaClient, err := cs.d.manilaClientBuilder.New(osOpts)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
+	}
+
+	snaps, err := listSnapshots(manilaClient, req.GetSnapshotId(), req.GetSourceVolumeId(), cs.d.shareProtocols())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list snapshots: %v", err)
+	}
+
+	if start > len(snaps) {
+		return nil, status.Errorf(codes.Aborted, "invalid starting token %q: only %d snapshots found", req.GetStartingToken(), len(snaps))
+	}
+
+	end := len(snaps)
+	if maxEntries := int(req.GetMaxEntries()); maxEntries > 0 && start+maxEntries < end {
+		end = start + maxEntries
+	}
+
+	res := &csi.ListSnapshotsResponse{}
+	for i := range snaps[start:end] {
+		res.Entries = append(res.Entries, &csi.ListSnapshotsResponse_Entry{Snapshot: csiSnapshot(&snaps[start+i])})
+	}
+	if end < len(snaps) {
+		res.NextToken = strconv.Itoa(end)
+	}
+
+	return res, nil
 }
 
 func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
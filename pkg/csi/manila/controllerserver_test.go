@@ -17,6 +17,8 @@ import (
 	"fmt"
 	"testing"
 
+	csispec "github.com/container-storage-interface/spec/lib/go/csi"
+
 	"k8s.io/cloud-provider-openstack/pkg/csi"
 )
 
@@ -123,3 +125,51 @@ func TestPrepareShareMetadata(t *testing.T) {
 		}
 	}
 }
+
+func TestGetVolumeCreatorTransfer(t *testing.T) {
+	annotations := map[string]string{
+		transferShareIDKey:       "share-id",
+		transferIDKey:            "transfer-id",
+		transferAuthKeySecretKey: "transfer-secret",
+	}
+	readSecret := func(name string) (map[string][]byte, error) {
+		if name != "transfer-secret" {
+			return nil, fmt.Errorf("secret %s not found", name)
+		}
+		return map[string][]byte{transferAuthKeySecretField: []byte("auth-key")}, nil
+	}
+
+	creator, err := getVolumeCreator(nil, annotations, readSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &volumeFromTransfer{shareID: "share-id", transferID: "transfer-id", authKey: "auth-key"}
+	if transfer, ok := creator.(*volumeFromTransfer); !ok || *transfer != *expected {
+		t.Errorf("returned an incorrect volume creator: got %#v, expected %#v", creator, expected)
+	}
+
+	if _, err := getVolumeCreator(nil, map[string]string{transferShareIDKey: "share-id"}, readSecret); err == nil {
+		t.Error("expected an error when the transfer annotations are missing")
+	}
+
+	plainText := map[string]string{transferShareIDKey: "share-id", transferIDKey: "transfer-id", transferAuthKeyKey: "auth-key"}
+	if _, err := getVolumeCreator(nil, plainText, readSecret); err == nil {
+		t.Error("expected an error when the auth key is set in the annotations")
+	}
+
+	missing := map[string]string{transferShareIDKey: "share-id", transferIDKey: "transfer-id", transferAuthKeySecretKey: "missing"}
+	if _, err := getVolumeCreator(nil, missing, readSecret); err == nil {
+		t.Error("expected an error when the Secret is missing")
+	}
+
+	source := &csispec.VolumeContentSource{Type: &csispec.VolumeContentSource_Snapshot{Snapshot: &csispec.VolumeContentSource_SnapshotSource{SnapshotId: "snapshot-id"}}}
+	if _, err := getVolumeCreator(source, annotations, readSecret); err == nil {
+		t.Error("expected an error when both a volume content source and a transfer are set")
+	}
+
+	if creator, err := getVolumeCreator(nil, nil, readSecret); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := creator.(*blankVolume); !ok {
+		t.Errorf("returned an incorrect volume creator: got %#v, expected a blank volume", creator)
+	}
+}
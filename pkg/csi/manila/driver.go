@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -29,6 +30,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	"google.golang.org/grpc"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/listers/core/v1"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/csiclient"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
@@ -38,15 +40,17 @@ import (
 )
 
 type Driver struct {
-	name       string
-	fqVersion  string // Fully qualified version in format {driverVersion}@{CPO version}
+	name      string
+	fqVersion string // Fully qualified version in format {driverVersion}@{CPO version}
+	// shareProto is the share protocol of the volumes whose StorageClass doesn't set one, empty if it must be set
 	shareProto string
 	clusterID  string
 
 	withTopology bool
 
 	serverEndpoint string
-	fwdEndpoint    string
+	// fwdEndpoints are the endpoints of the proxied CSI node plugins by share protocol
+	fwdEndpoints map[string]string
 
 	ids *identityServer
 	cs  *controllerServer
@@ -60,6 +64,7 @@ type Driver struct {
 	csiClientBuilder    csiclient.Builder
 
 	pvcLister v1.PersistentVolumeClaimLister
+	kclient   kubernetes.Interface
 }
 
 type DriverOpts struct {
@@ -71,11 +76,17 @@ type DriverOpts struct {
 
 	ServerCSIEndpoint string
 	FwdCSIEndpoint    string
+	// FwdCSIEndpoints are the endpoints of the proxied CSI node plugins by share protocol, for the drivers operating on
+	// several share protocols. ShareProto and FwdCSIEndpoint are then optional, ShareProto is the share protocol of the
+	// volumes whose StorageClass doesn't set one.
+	FwdCSIEndpoints map[string]string
 
 	ManilaClientBuilder manilaclient.Builder
 	CSIClientBuilder    csiclient.Builder
 
 	PVCLister v1.PersistentVolumeClaimLister
+	// KubeClient reads the Secrets named by the PVC annotations, e.g. the auth keys of the share transfers, optional.
+	KubeClient kubernetes.Interface
 }
 
 type nonBlockingGRPCServer struct {
@@ -103,10 +114,12 @@ func argNotEmpty(val, name string) error {
 
 func NewDriver(o *DriverOpts) (*Driver, error) {
 	m := map[string]string{
-		"driver name":             o.DriverName,
-		"driver endpoint":         o.ServerCSIEndpoint,
-		"FWD endpoint":            o.FwdCSIEndpoint,
-		"share protocol selector": o.ShareProto,
+		"driver name":     o.DriverName,
+		"driver endpoint": o.ServerCSIEndpoint,
+	}
+	if len(o.FwdCSIEndpoints) == 0 {
+		m["FWD endpoint"] = o.FwdCSIEndpoint
+		m["share protocol selector"] = o.ShareProto
 	}
 	for k, v := range m {
 		if err := argNotEmpty(v, k); err != nil {
@@ -119,12 +132,13 @@ func NewDriver(o *DriverOpts) (*Driver, error) {
 		withTopology:        o.WithTopology,
 		name:                o.DriverName,
 		serverEndpoint:      o.ServerCSIEndpoint,
-		fwdEndpoint:         o.FwdCSIEndpoint,
+		fwdEndpoints:        make(map[string]string),
 		shareProto:          strings.ToUpper(o.ShareProto),
 		manilaClientBuilder: o.ManilaClientBuilder,
 		csiClientBuilder:    o.CSIClientBuilder,
 		clusterID:           o.ClusterID,
 		pvcLister:           o.PVCLister,
+		kclient:             o.KubeClient,
 	}
 
 	klog.Info("Driver: ", d.name)
@@ -132,21 +146,46 @@ func NewDriver(o *DriverOpts) (*Driver, error) {
 	klog.Info("CSI spec version: ", specVersion)
 	klog.Infof("Topology awareness: %T", d.withTopology)
 
-	getShareAdapter(d.shareProto) // The program will terminate with a non-zero exit code if the share protocol selector is wrong
-	klog.Infof("Operating on %s shares", d.shareProto)
+	fwdEndpoints := make(map[string]string, len(o.FwdCSIEndpoints)+1)
+	for shareProto, endpoint := range o.FwdCSIEndpoints {
+		fwdEndpoints[strings.ToUpper(shareProto)] = endpoint
+	}
+	if o.FwdCSIEndpoint != "" {
+		if d.shareProto == "" {
+			return nil, fmt.Errorf("share protocol selector is missing for FWD endpoint %s", o.FwdCSIEndpoint)
+		}
+		if endpoint, ok := fwdEndpoints[d.shareProto]; ok && endpoint != o.FwdCSIEndpoint {
+			return nil, fmt.Errorf("conflicting FWD endpoints %s and %s for %s shares", endpoint, o.FwdCSIEndpoint, d.shareProto)
+		}
+		fwdEndpoints[d.shareProto] = o.FwdCSIEndpoint
+	}
+	if _, ok := fwdEndpoints[d.shareProto]; d.shareProto != "" && !ok {
+		return nil, fmt.Errorf("FWD endpoint is missing for %s shares", d.shareProto)
+	}
 
-	serverProto, serverAddr, err := parseGRPCEndpoint(o.ServerCSIEndpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse server endpoint address %s: %v", o.ServerCSIEndpoint, err)
+	for shareProto, endpoint := range fwdEndpoints {
+		if !isShareProtocolSupported(shareProto) {
+			return nil, fmt.Errorf("share protocol %q not supported", shareProto)
+		}
+
+		fwdProto, fwdAddr, err := parseGRPCEndpoint(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy client address %s: %v", endpoint, err)
+		}
+		d.fwdEndpoints[shareProto] = endpointAddress(fwdProto, fwdAddr)
+	}
+
+	klog.Infof("Operating on %v shares", d.shareProtocols())
+	if d.shareProto != "" {
+		klog.Infof("Defaulting to %s shares", d.shareProto)
 	}
 
-	fwdProto, fwdAddr, err := parseGRPCEndpoint(o.FwdCSIEndpoint)
+	serverProto, serverAddr, err := parseGRPCEndpoint(o.ServerCSIEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse proxy client address %s: %v", o.FwdCSIEndpoint, err)
+		return nil, fmt.Errorf("failed to parse server endpoint address %s: %v", o.ServerCSIEndpoint, err)
 	}
 
 	d.serverEndpoint = endpointAddress(serverProto, serverAddr)
-	d.fwdEndpoint = endpointAddress(fwdProto, fwdAddr)
 
 	d.ids = &identityServer{d: d}
 
@@ -159,6 +198,7 @@ func (d *Driver) SetupControllerService() error {
 	d.addControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
 		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 	})
 
@@ -177,32 +217,85 @@ func (d *Driver) SetupControllerService() error {
 func (d *Driver) SetupNodeService(metadata metadata.IMetadata) error {
 	klog.Info("Providing node service")
 
-	var supportsNodeStage bool
-
-	nodeCapsMap, err := d.initProxiedDriver()
-	if err != nil {
-		return fmt.Errorf("failed to initialize proxied CSI driver: %v", err)
+	// The capabilities of all the proxied drivers are advertised, the RPCs of the capabilities a proxied driver
+	// doesn't have are handled by the node service for its share protocol. The volume stats are collected by the node
+	// service when the pods directory of kubelet is mounted in its container.
+	supportsNodeStage := make(map[string]bool, len(d.fwdEndpoints))
+	supportsVolumeStats := make(map[string]bool, len(d.fwdEndpoints))
+	nodeCapsMap := make(csiNodeCapabilitySet)
+	for _, shareProto := range d.shareProtocols() {
+		caps, err := d.initProxiedDriver(d.fwdEndpoints[shareProto])
+		if err != nil {
+			return fmt.Errorf("failed to initialize proxied CSI driver for %s shares: %v", shareProto, err)
+		}
+		for c := range caps {
+			nodeCapsMap[c] = true
+		}
+		supportsNodeStage[shareProto] = caps[csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME]
+		supportsVolumeStats[shareProto] = caps[csi.NodeServiceCapability_RPC_GET_VOLUME_STATS]
+	}
+	if kubeletPodsDirMounted() {
+		nodeCapsMap[csi.NodeServiceCapability_RPC_GET_VOLUME_STATS] = true
+	} else {
+		klog.Warningf("%s is not mounted, the volume stats are only reported by the proxied drivers which support them", kubeletPodsDir)
 	}
+
 	nscaps := make([]csi.NodeServiceCapability_RPC_Type, 0, len(nodeCapsMap))
 	for c := range nodeCapsMap {
 		nscaps = append(nscaps, c)
-
-		if c == csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME {
-			supportsNodeStage = true
-		}
 	}
 
 	d.addNodeServiceCapabilities(nscaps)
 
 	d.ns = &nodeServer{
-		d:                 d,
-		metadata:          metadata,
-		supportsNodeStage: supportsNodeStage,
-		nodeStageCache:    make(map[volumeID]stageCacheEntry),
+		d:                   d,
+		metadata:            metadata,
+		supportsNodeStage:   supportsNodeStage,
+		supportsVolumeStats: supportsVolumeStats,
+		nodeStageCache:      make(map[volumeID]stageCacheEntry),
+		volumeProtocols:     make(map[volumeID]string),
+		mountProtocol:       getMountShareProtocol,
+		kernelRelease:       getKernelRelease,
+		shareStats:          getShareStats,
+		shareStatsCalls:     make(map[string]*shareStatsCall),
 	}
 	return nil
 }
 
+// shareProtocols returns the share protocols the driver operates on, sorted.
+func (d *Driver) shareProtocols() []string {
+	protos := make([]string, 0, len(d.fwdEndpoints))
+	for shareProto := range d.fwdEndpoints {
+		protos = append(protos, shareProto)
+	}
+	sort.Strings(protos)
+
+	return protos
+}
+
+// servesShareProtocol checks whether the driver operates on the shares of the protocol.
+func (d *Driver) servesShareProtocol(shareProto string) bool {
+	_, ok := d.fwdEndpoints[strings.ToUpper(shareProto)]
+	return ok
+}
+
+// selectShareProtocol returns the share protocol of a volume whose StorageClass requests the share protocol, which
+// may be empty to use the default share protocol of the driver.
+func (d *Driver) selectShareProtocol(requested string) (string, error) {
+	if requested == "" {
+		if d.shareProto == "" {
+			return "", fmt.Errorf("the protocol parameter is required, the driver operates on %v shares", d.shareProtocols())
+		}
+		return d.shareProto, nil
+	}
+
+	if !d.servesShareProtocol(requested) {
+		return "", fmt.Errorf("share protocol %s is not served by the driver, it operates on %v shares", requested, d.shareProtocols())
+	}
+
+	return strings.ToUpper(requested), nil
+}
+
 func (d *Driver) Run() {
 	if nil == d.cs && nil == d.ns {
 		klog.Fatal("No CSI services initialized")
@@ -262,10 +355,10 @@ func (d *Driver) addNodeServiceCapabilities(ns []csi.NodeServiceCapability_RPC_T
 	d.nscaps = caps
 }
 
-func (d *Driver) initProxiedDriver() (csiNodeCapabilitySet, error) {
-	conn, err := d.csiClientBuilder.NewConnection(d.fwdEndpoint)
+func (d *Driver) initProxiedDriver(fwdEndpoint string) (csiNodeCapabilitySet, error) {
+	conn, err := d.csiClientBuilder.NewConnection(fwdEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to %s endpoint failed: %v", d.fwdEndpoint, err)
+		return nil, fmt.Errorf("connecting to %s endpoint failed: %v", fwdEndpoint, err)
 	}
 	defer conn.Close()
 
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDriverShareProtocols(t *testing.T) {
+	ts := []struct {
+		name            string
+		opts            DriverOpts
+		expectedProtos  []string
+		expectedDefault string
+		expectedErr     string
+	}{
+		{
+			name:            "single share protocol",
+			opts:            DriverOpts{ShareProto: "nfs", FwdCSIEndpoint: "unix:///csi/nfs.sock"},
+			expectedProtos:  []string{"NFS"},
+			expectedDefault: "NFS",
+		},
+		{
+			name: "several share protocols",
+			opts: DriverOpts{FwdCSIEndpoints: map[string]string{
+				"NFS":    "unix:///csi/nfs.sock",
+				"cephfs": "unix:///csi/cephfs.sock",
+			}},
+			expectedProtos: []string{"CEPHFS", "NFS"},
+		},
+		{
+			name: "several share protocols with a default one",
+			opts: DriverOpts{
+				ShareProto:      "CEPHFS",
+				FwdCSIEndpoints: map[string]string{"NFS": "unix:///csi/nfs.sock", "CEPHFS": "unix:///csi/cephfs.sock"},
+			},
+			expectedProtos:  []string{"CEPHFS", "NFS"},
+			expectedDefault: "CEPHFS",
+		},
+		{
+			name: "conflicting endpoints",
+			opts: DriverOpts{
+				ShareProto:      "NFS",
+				FwdCSIEndpoint:  "unix:///csi/other.sock",
+				FwdCSIEndpoints: map[string]string{"NFS": "unix:///csi/nfs.sock"},
+			},
+			expectedErr: "conflicting FWD endpoints",
+		},
+		{
+			name: "default share protocol without endpoint",
+			opts: DriverOpts{
+				ShareProto:      "CEPHFS",
+				FwdCSIEndpoints: map[string]string{"NFS": "unix:///csi/nfs.sock"},
+			},
+			expectedErr: "FWD endpoint is missing for CEPHFS shares",
+		},
+		{
+			name:        "unsupported share protocol",
+			opts:        DriverOpts{FwdCSIEndpoints: map[string]string{"CIFS": "unix:///csi/cifs.sock"}},
+			expectedErr: `share protocol "CIFS" not supported`,
+		},
+		{
+			name:        "missing endpoint",
+			opts:        DriverOpts{ShareProto: "NFS"},
+			expectedErr: "FWD endpoint is missing",
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.DriverName = "manila.csi.openstack.org"
+			tt.opts.ServerCSIEndpoint = "unix:///csi/csi.sock"
+
+			d, err := NewDriver(&tt.opts)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedProtos, d.shareProtocols())
+			assert.Equal(t, tt.expectedDefault, d.shareProto)
+		})
+	}
+}
+
+func TestSelectShareProtocol(t *testing.T) {
+	d := &Driver{fwdEndpoints: map[string]string{"NFS": "/csi/nfs.sock", "CEPHFS": "/csi/cephfs.sock"}}
+
+	_, err := d.selectShareProtocol("")
+	assert.ErrorContains(t, err, "the protocol parameter is required")
+
+	shareProto, err := d.selectShareProtocol("cephfs")
+	assert.NoError(t, err)
+	assert.Equal(t, "CEPHFS", shareProto)
+
+	_, err = d.selectShareProtocol("CIFS")
+	assert.ErrorContains(t, err, "not served by the driver")
+
+	d.shareProto = "NFS"
+	shareProto, err = d.selectShareProtocol("")
+	assert.NoError(t, err)
+	assert.Equal(t, "NFS", shareProto)
+}
+
+func TestFilesystemShareProtocol(t *testing.T) {
+	ts := map[string]string{
+		"nfs":            "NFS",
+		"nfs4":           "NFS",
+		"ceph":           "CEPHFS",
+		"fuse.ceph-fuse": "CEPHFS",
+		"ext4":           "",
+	}
+
+	for fsType, expected := range ts {
+		assert.Equal(t, expected, filesystemShareProtocol(fsType), fsType)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	"k8s.io/cloud-provider-openstack/pkg/util"
+	clouderrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Share encryption is available since Manila microversion 2.90
+	shareEncryptionMicroversion = "2.90"
+
+	encryptionKeyRefKey     = "manila.csi.openstack.org/encryption-key-ref"
+	managedEncryptionKeyKey = "manila.csi.openstack.org/managed-encryption-key"
+
+	// The encryption keys created by the driver are named after the share
+	encryptionKeyNamePrefix = "manila-csi-"
+)
+
+// encryptedShareCreateOpts adds the encryption key of the share to the create request, gophercloud doesn't support it.
+type encryptedShareCreateOpts struct {
+	*shares.CreateOpts
+	EncryptionKeyRef string
+}
+
+func (opts encryptedShareCreateOpts) ToShareCreateMap() (map[string]any, error) {
+	b, err := opts.CreateOpts.ToShareCreateMap()
+	if err != nil {
+		return nil, err
+	}
+
+	b["share"].(map[string]any)["encryption_key_ref"] = opts.EncryptionKeyRef
+
+	return b, nil
+}
+
+func isEncryptionRequested(shareOpts *options.ControllerVolumeContext) bool {
+	return shareOpts.EncryptionKeyRef != "" || strings.EqualFold(shareOpts.Encrypted, "true")
+}
+
+// prepareShareEncryption sets the encryption key of the share to be created. Unless set in the volume parameters, the
+// key is created in Barbican and recorded in the share metadata so that it's deleted along with the share.
+func prepareShareEncryption(manilaClient manilaclient.Interface, shareName string, shareOpts *options.ControllerVolumeContext, shareMetadata map[string]string) error {
+	if !isEncryptionRequested(shareOpts) {
+		return nil
+	}
+
+	if shareOpts.EncryptionKeyRef == "" {
+		keyID, err := manilaClient.GetOrCreateEncryptionKey(encryptionKeyNamePrefix + shareName)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to create the encryption key of volume %s: %v", shareName, err)
+		}
+		shareOpts.EncryptionKeyRef = keyID
+		shareMetadata[managedEncryptionKeyKey] = "true"
+	}
+	shareMetadata[encryptionKeyRefKey] = shareOpts.EncryptionKeyRef
+
+	return nil
+}
+
+// setEncryptionVolumeContext exposes the encryption status of the share in the volume context, i.e. the PV attributes.
+func setEncryptionVolumeContext(volCtx map[string]string, share *shares.Share) map[string]string {
+	keyRef := share.Metadata[encryptionKeyRefKey]
+	if keyRef == "" {
+		return volCtx
+	}
+
+	volCtx = util.SetMapIfNotEmpty(volCtx, "encrypted", "true")
+	return util.SetMapIfNotEmpty(volCtx, "encryptionKeyRef", keyRef)
+}
+
+// deleteShareWithEncryptionKey deletes the share, and then the encryption key created for it by the driver. The key
+// is deleted once the share is gone as Manila needs it to delete the share.
+func deleteShareWithEncryptionKey(manilaClient manilaclient.Interface, share *shares.Share) error {
+	keyID := share.Metadata[encryptionKeyRefKey]
+	if share.Metadata[managedEncryptionKeyKey] != "true" || keyID == "" {
+		return deleteShare(manilaClient, share.ID)
+	}
+
+	if share.Status != shareDeleting {
+		if err := deleteShare(manilaClient, share.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, _, err := waitForShareStatus(manilaClient, share.ID, []string{shareDeleting}, "", true); err != nil {
+		if wait.Interrupted(err) {
+			return status.Errorf(codes.DeadlineExceeded, "deadline exceeded while waiting for volume %s to be deleted", share.ID)
+		}
+		return err
+	}
+
+	if err := manilaClient.DeleteEncryptionKey(keyID); err != nil {
+		return status.Errorf(codes.Internal, "failed to delete the encryption key %s of volume %s: %v", keyID, share.ID, err)
+	}
+	klog.V(4).Infof("deleted the encryption key %s of volume %s", keyID, share.ID)
+
+	return nil
+}
+
+// tryDeleteEncryptionKey deletes the encryption key created by the driver for a share whose creation failed. The key
+// is kept as long as the share exists, the retried CreateVolume call will reuse it.
+func tryDeleteEncryptionKey(manilaClient manilaclient.Interface, shareName string, shareMetadata map[string]string) {
+	keyID := shareMetadata[encryptionKeyRefKey]
+	if shareMetadata[managedEncryptionKeyKey] != "true" || keyID == "" {
+		return
+	}
+
+	if _, err := manilaClient.GetShareByName(shareName); !clouderrors.IsNotFound(err) {
+		if err != nil {
+			klog.Errorf("couldn't retrieve volume %s in a roll-back procedure, keeping its encryption key %s: %v", shareName, keyID, err)
+		}
+		return
+	}
+
+	if err := manilaClient.DeleteEncryptionKey(keyID); err != nil {
+		klog.Errorf("couldn't delete the encryption key %s of volume %s in a roll-back procedure: %v", keyID, shareName, err)
+		return
+	}
+	klog.V(4).Infof("deleted the encryption key %s of volume %s", keyID, shareName)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+)
+
+// keyManilaClient creates and deletes the encryption keys of the shares.
+type keyManilaClient struct {
+	manilaclient.Interface
+
+	created []string
+	deleted []string
+	share   *shares.Share
+}
+
+func (c *keyManilaClient) GetOrCreateEncryptionKey(name string) (string, error) {
+	c.created = append(c.created, name)
+	return "key-" + name, nil
+}
+
+func (c *keyManilaClient) DeleteEncryptionKey(keyID string) error {
+	c.deleted = append(c.deleted, keyID)
+	return nil
+}
+
+func (c *keyManilaClient) GetShareByName(shareName string) (*shares.Share, error) {
+	if c.share == nil || c.share.Name != shareName {
+		return nil, gophercloud.ErrResourceNotFound{}
+	}
+	return c.share, nil
+}
+
+func TestEncryptedShareCreateOpts(t *testing.T) {
+	opts := encryptedShareCreateOpts{
+		CreateOpts:       &shares.CreateOpts{ShareProto: "NFS", Size: 1},
+		EncryptionKeyRef: "key",
+	}
+
+	b, err := opts.ToShareCreateMap()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ref := b["share"].(map[string]any)["encryption_key_ref"]; ref != "key" {
+		t.Errorf("expected encryption key ref key, got %v", ref)
+	}
+}
+
+func TestPrepareShareEncryption(t *testing.T) {
+	tests := []struct {
+		name             string
+		shareOpts        options.ControllerVolumeContext
+		expectedMetadata map[string]string
+		expectedCreated  []string
+	}{
+		{
+			name:             "not encrypted",
+			shareOpts:        options.ControllerVolumeContext{Encrypted: "false"},
+			expectedMetadata: map[string]string{},
+		},
+		{
+			name:      "managed key",
+			shareOpts: options.ControllerVolumeContext{Encrypted: "True"},
+			expectedMetadata: map[string]string{
+				encryptionKeyRefKey:     "key-manila-csi-pvc",
+				managedEncryptionKeyKey: "true",
+			},
+			expectedCreated: []string{"manila-csi-pvc"},
+		},
+		{
+			name:             "user key",
+			shareOpts:        options.ControllerVolumeContext{Encrypted: "false", EncryptionKeyRef: "user-key"},
+			expectedMetadata: map[string]string{encryptionKeyRefKey: "user-key"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &keyManilaClient{}
+			metadata := map[string]string{}
+
+			if err := prepareShareEncryption(client, "pvc", &test.shareOpts, metadata); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(metadata, test.expectedMetadata) {
+				t.Errorf("expected metadata %v, got %v", test.expectedMetadata, metadata)
+			}
+			if !reflect.DeepEqual(client.created, test.expectedCreated) {
+				t.Errorf("expected created keys %v, got %v", test.expectedCreated, client.created)
+			}
+			if ref := test.expectedMetadata[encryptionKeyRefKey]; test.shareOpts.EncryptionKeyRef != ref {
+				t.Errorf("expected encryption key ref %q, got %q", ref, test.shareOpts.EncryptionKeyRef)
+			}
+		})
+	}
+}
+
+func TestSetEncryptionVolumeContext(t *testing.T) {
+	volCtx := setEncryptionVolumeContext(map[string]string{}, &shares.Share{})
+	if len(volCtx) != 0 {
+		t.Errorf("expected no encryption attributes, got %v", volCtx)
+	}
+
+	volCtx = setEncryptionVolumeContext(map[string]string{}, &shares.Share{Metadata: map[string]string{encryptionKeyRefKey: "key"}})
+	expected := map[string]string{"encrypted": "true", "encryptionKeyRef": "key"}
+	if !reflect.DeepEqual(volCtx, expected) {
+		t.Errorf("expected volume context %v, got %v", expected, volCtx)
+	}
+}
+
+func TestTryDeleteEncryptionKey(t *testing.T) {
+	managedKey := map[string]string{encryptionKeyRefKey: "key-manila-csi-pvc", managedEncryptionKeyKey: "true"}
+
+	tests := []struct {
+		name            string
+		share           *shares.Share
+		metadata        map[string]string
+		expectedDeleted []string
+	}{
+		{
+			name:            "share not created",
+			metadata:        managedKey,
+			expectedDeleted: []string{"key-manila-csi-pvc"},
+		},
+		{
+			name:     "share still exists",
+			share:    &shares.Share{ID: "share-id", Name: "pvc"},
+			metadata: managedKey,
+		},
+		{
+			name:     "user key",
+			metadata: map[string]string{encryptionKeyRefKey: "user-key"},
+		},
+		{
+			name:     "not encrypted",
+			metadata: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &keyManilaClient{share: test.share}
+
+			tryDeleteEncryptionKey(client, "pvc", test.metadata)
+
+			if !reflect.DeepEqual(client.deleted, test.expectedDeleted) {
+				t.Errorf("expected deleted keys %v, got %v", test.expectedDeleted, client.deleted)
+			}
+		})
+	}
+}
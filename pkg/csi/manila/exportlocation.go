@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/runtimeconfig"
+	manilautil "k8s.io/cloud-provider-openstack/pkg/csi/manila/util"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
+)
+
+// The share replicas API is available since this microversion.
+const shareReplicasMicroversion = "2.56"
+
+// getExportLocationConfig returns the export location selection settings of the volume,
+// the StorageClass parameters override the runtime configuration of the node.
+func getExportLocationConfig(shareOpts *options.NodeVolumeContext) (*runtimeconfig.ExportLocationConfig, error) {
+	conf, err := runtimeconfig.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime config file %s: %v", runtimeconfig.RuntimeConfigFilename, err)
+	}
+
+	elConf := &runtimeconfig.ExportLocationConfig{}
+	if conf != nil && conf.ExportLocation != nil {
+		*elConf = *conf.ExportLocation
+	}
+
+	if shareOpts.ExportLocationPathPattern != "" {
+		elConf.PathPattern = shareOpts.ExportLocationPathPattern
+	}
+	if shareOpts.ExportLocationZoneAffinity != "" {
+		elConf.ZoneAffinity = strings.EqualFold(shareOpts.ExportLocationZoneAffinity, "true")
+	}
+	if shareOpts.ExportLocationAllowAdminOnly != "" {
+		elConf.AllowAdminOnly = strings.EqualFold(shareOpts.ExportLocationAllowAdminOnly, "true")
+	}
+
+	return elConf, nil
+}
+
+// buildExportLocationPolicy returns the policy ranking the export locations of the share.
+// With zone affinity, the export locations of the share instances in the availability zone of the node are preferred,
+// the share instances of a replicated share being looked up among its replicas.
+func buildExportLocationPolicy(elConf *runtimeconfig.ExportLocationConfig, share *shares.Share, locs []shares.ExportLocation,
+	manilaClient manilaclient.Interface, md metadata.IMetadata) (*manilautil.ExportLocationPolicy, error) {
+	policy := &manilautil.ExportLocationPolicy{
+		AllowAdminOnly: elConf.AllowAdminOnly,
+	}
+
+	if elConf.PathPattern != "" {
+		rx, err := regexp.Compile(elConf.PathPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid export location path pattern %q: %v", elConf.PathPattern, err)
+		}
+		policy.PathPattern = rx
+	}
+
+	if !elConf.ZoneAffinity {
+		return policy, nil
+	}
+
+	zone, err := md.GetAvailabilityZone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the availability zone of the node: %v", err)
+	}
+	policy.Zone = zone
+
+	policy.LocationZones = make(map[string]string, len(locs))
+	for _, loc := range locs {
+		policy.LocationZones[loc.ShareInstanceID] = share.AvailabilityZone
+	}
+
+	if share.ReplicationType != "" {
+		mv := manilaClient.GetMicroversion()
+		manilaClient.SetMicroversion(shareReplicasMicroversion)
+		defer manilaClient.SetMicroversion(mv)
+
+		replicas, err := manilaClient.GetShareReplicas(share.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the replicas of share %s: %v", share.ID, err)
+		}
+		for _, replica := range replicas {
+			policy.LocationZones[replica.ID] = replica.AvailabilityZone
+		}
+	}
+
+	return policy, nil
+}
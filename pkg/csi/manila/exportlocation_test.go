@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/replicas"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/runtimeconfig"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
+)
+
+// replicaManilaClient serves the replicas of a share.
+type replicaManilaClient struct {
+	manilaclient.Interface
+
+	microversion string
+	replicas     []replicas.Replica
+}
+
+func (c *replicaManilaClient) GetMicroversion() string { return c.microversion }
+
+func (c *replicaManilaClient) SetMicroversion(version string) { c.microversion = version }
+
+func (c *replicaManilaClient) GetShareReplicas(shareID string) ([]replicas.Replica, error) {
+	if c.microversion != shareReplicasMicroversion {
+		return nil, os.ErrInvalid
+	}
+	return c.replicas, nil
+}
+
+func TestGetExportLocationConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtimeconfig.json")
+	if err := os.WriteFile(path, []byte(`{"exportLocation": {"pathPattern": "^10\\.0\\.", "zoneAffinity": true}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(filename string) { runtimeconfig.RuntimeConfigFilename = filename }(runtimeconfig.RuntimeConfigFilename)
+	runtimeconfig.RuntimeConfigFilename = path
+
+	elConf, err := getExportLocationConfig(&options.NodeVolumeContext{
+		ExportLocationZoneAffinity:   "false",
+		ExportLocationAllowAdminOnly: "True",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &runtimeconfig.ExportLocationConfig{PathPattern: `^10\.0\.`, ZoneAffinity: false, AllowAdminOnly: true}
+	if !reflect.DeepEqual(elConf, expected) {
+		t.Errorf("expected export location config %+v, got %+v", expected, elConf)
+	}
+}
+
+func TestBuildExportLocationPolicy(t *testing.T) {
+	share := &shares.Share{ID: "share", AvailabilityZone: "zone-a", ReplicationType: "dr"}
+	locs := []shares.ExportLocation{
+		{Path: "10.0.0.1:/share", ShareInstanceID: "instance-a"},
+		{Path: "10.0.1.1:/share", ShareInstanceID: "instance-b"},
+	}
+	manilaClient := &replicaManilaClient{
+		microversion: "2.37",
+		replicas:     []replicas.Replica{{ID: "instance-a", AvailabilityZone: "zone-a"}, {ID: "instance-b", AvailabilityZone: "zone-b"}},
+	}
+	md := new(metadata.MetadataMock)
+	md.On("GetAvailabilityZone").Return("zone-b", nil)
+
+	policy, err := buildExportLocationPolicy(&runtimeconfig.ExportLocationConfig{ZoneAffinity: true}, share, locs, manilaClient, md)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if policy.Zone != "zone-b" {
+		t.Errorf("expected zone zone-b, got %s", policy.Zone)
+	}
+	expected := map[string]string{"instance-a": "zone-a", "instance-b": "zone-b"}
+	if !reflect.DeepEqual(policy.LocationZones, expected) {
+		t.Errorf("expected location zones %v, got %v", expected, policy.LocationZones)
+	}
+	if manilaClient.microversion != "2.37" {
+		t.Errorf("expected the microversion to be restored, got %s", manilaClient.microversion)
+	}
+
+	if _, err := buildExportLocationPolicy(&runtimeconfig.ExportLocationConfig{PathPattern: "("}, share, locs, manilaClient, md); err == nil {
+		t.Error("expected an error for an invalid path pattern")
+	}
+}
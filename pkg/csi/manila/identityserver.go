@@ -40,9 +40,25 @@ func (ids *identityServer) GetPluginInfo(ctx context.Context, req *csi.GetPlugin
 }
 
 func (ids *identityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	csiConn, err := ids.d.csiClientBuilder.NewConnectionWithContext(ctx, ids.d.fwdEndpoint)
+	// The driver is ready once all the proxied drivers are
+	var rsp *csi.ProbeResponse
+	for _, shareProto := range ids.d.shareProtocols() {
+		var err error
+		if rsp, err = ids.probeProxiedDriver(ctx, ids.d.fwdEndpoints[shareProto], req); err != nil {
+			return nil, err
+		}
+		if ready := rsp.GetReady(); ready != nil && !ready.GetValue() {
+			return rsp, nil
+		}
+	}
+
+	return rsp, nil
+}
+
+func (ids *identityServer) probeProxiedDriver(ctx context.Context, fwdEndpoint string, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	csiConn, err := ids.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.FailedPrecondition, fmtGrpcConnError(ids.d.fwdEndpoint, err))
+		return nil, status.Error(codes.FailedPrecondition, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
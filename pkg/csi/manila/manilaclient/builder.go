@@ -68,7 +68,16 @@ func New(o *client.AuthOpts, userAgent string, extraUserAgentData []string) (*Cl
 		return nil, fmt.Errorf("Manila v2 client validation failed: %v", err)
 	}
 
-	return &Client{c: client}, nil
+	// The key manager stores the encryption keys of the shares, it's optional
+	km, err := openstack.NewKeyManagerV1(provider, gophercloud.EndpointOpts{
+		Region:       o.Region,
+		Availability: o.EndpointType,
+	})
+	if err != nil {
+		km = nil
+	}
+
+	return &Client{c: client, km: km}, nil
 }
 
 func splitManilaMicroversion(microversion string) (major, minor int) {
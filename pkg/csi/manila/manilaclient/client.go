@@ -18,19 +18,30 @@ package manilaclient
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 
 	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/replicas"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetransfers"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
 	shares_utils "github.com/gophercloud/utils/v2/openstack/sharedfilesystems/v2/shares"
 	sharetypes_utils "github.com/gophercloud/utils/v2/openstack/sharedfilesystems/v2/sharetypes"
 	snapshots_utils "github.com/gophercloud/utils/v2/openstack/sharedfilesystems/v2/snapshots"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
 )
 
+var errNoKeyManager = errors.New("key manager service not found in the service catalog")
+
 type Client struct {
-	c *gophercloud.ServiceClient
+	c  *gophercloud.ServiceClient
+	km *gophercloud.ServiceClient
 }
 
 func (c Client) GetMicroversion() string {
@@ -66,10 +77,31 @@ func (c Client) ExtendShare(shareID string, opts shares.ExtendOptsBuilder) error
 	return shares.Extend(context.TODO(), c.c, shareID, opts).ExtractErr()
 }
 
+func (c Client) UpdateShare(shareID string, opts shares.UpdateOptsBuilder) (*shares.Share, error) {
+	return shares.Update(context.TODO(), c.c, shareID, opts).Extract()
+}
+
+func (c Client) GetShareTransfer(transferID string) (*sharetransfers.Transfer, error) {
+	return sharetransfers.Get(context.TODO(), c.c, transferID).Extract()
+}
+
+func (c Client) AcceptShareTransfer(transferID string, opts sharetransfers.AcceptOpts) error {
+	return sharetransfers.Accept(context.TODO(), c.c, transferID, opts).ExtractErr()
+}
+
 func (c Client) GetExportLocations(shareID string) ([]shares.ExportLocation, error) {
 	return shares.ListExportLocations(context.TODO(), c.c, shareID).Extract()
 }
 
+func (c Client) GetShareReplicas(shareID string) ([]replicas.Replica, error) {
+	allPages, err := replicas.ListDetail(c.c, replicas.ListOpts{ShareID: shareID}).AllPages(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	return replicas.ExtractReplicas(allPages)
+}
+
 func (c Client) SetShareMetadata(shareID string, opts shares.SetMetadataOptsBuilder) (map[string]string, error) {
 	return shares.SetMetadata(context.TODO(), c.c, shareID, opts).Extract()
 }
@@ -95,6 +127,15 @@ func (c Client) GetSnapshotByName(snapName string) (*snapshots.Snapshot, error)
 	return snapshots.Get(context.TODO(), c.c, snapID).Extract()
 }
 
+func (c Client) ListSnapshots(opts snapshots.ListOptsBuilder) ([]snapshots.Snapshot, error) {
+	allPages, err := snapshots.ListDetail(c.c, opts).AllPages(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots.ExtractSnapshots(allPages)
+}
+
 func (c Client) CreateSnapshot(opts snapshots.CreateOptsBuilder) (*snapshots.Snapshot, error) {
 	return snapshots.Create(context.TODO(), c.c, opts).Extract()
 }
@@ -128,3 +169,36 @@ func (c Client) GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Messa
 
 	return messages.ExtractMessages(allPages)
 }
+
+// GetOrCreateEncryptionKey returns the ID of the Barbican secret with the name, the secret is created with a random
+// 256-bit key if it doesn't exist.
+func (c Client) GetOrCreateEncryptionKey(name string) (string, error) {
+	if c.km == nil {
+		return "", errNoKeyManager
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	secretRef, err := openstackutil.EnsureSecret(c.km, name, "application/octet-stream", base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return "", err
+	}
+
+	return openstackutil.ParseSecretID(secretRef)
+}
+
+// DeleteEncryptionKey deletes the Barbican secret by its ID, a secret already deleted is ignored.
+func (c Client) DeleteEncryptionKey(keyID string) error {
+	if c.km == nil {
+		return errNoKeyManager
+	}
+
+	if err := secrets.Delete(context.TODO(), c.km, keyID).ExtractErr(); err != nil && !cpoerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
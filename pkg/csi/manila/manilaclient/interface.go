@@ -18,7 +18,9 @@ package manilaclient
 
 import (
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/replicas"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetransfers"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
 	"k8s.io/cloud-provider-openstack/pkg/client"
@@ -33,8 +35,13 @@ type Interface interface {
 	CreateShare(opts shares.CreateOptsBuilder) (*shares.Share, error)
 	DeleteShare(shareID string) error
 	ExtendShare(shareID string, opts shares.ExtendOptsBuilder) error
+	UpdateShare(shareID string, opts shares.UpdateOptsBuilder) (*shares.Share, error)
+
+	GetShareTransfer(transferID string) (*sharetransfers.Transfer, error)
+	AcceptShareTransfer(transferID string, opts sharetransfers.AcceptOpts) error
 
 	GetExportLocations(shareID string) ([]shares.ExportLocation, error)
+	GetShareReplicas(shareID string) ([]replicas.Replica, error)
 
 	SetShareMetadata(shareID string, opts shares.SetMetadataOptsBuilder) (map[string]string, error)
 
@@ -43,6 +50,7 @@ type Interface interface {
 
 	GetSnapshotByID(snapID string) (*snapshots.Snapshot, error)
 	GetSnapshotByName(snapName string) (*snapshots.Snapshot, error)
+	ListSnapshots(opts snapshots.ListOptsBuilder) ([]snapshots.Snapshot, error)
 	CreateSnapshot(opts snapshots.CreateOptsBuilder) (*snapshots.Snapshot, error)
 	DeleteSnapshot(snapID string) error
 
@@ -51,6 +59,9 @@ type Interface interface {
 	GetShareTypeIDFromName(shareTypeName string) (string, error)
 
 	GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Message, error)
+
+	GetOrCreateEncryptionKey(name string) (string, error)
+	DeleteEncryptionKey(keyID string) error
 }
 
 type Builder interface {
@@ -18,6 +18,8 @@ package manila
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"strings"
 	"sync"
 
@@ -36,25 +38,43 @@ import (
 type nodeServer struct {
 	d *Driver
 
-	metadata          metadata.IMetadata
-	supportsNodeStage bool
+	metadata metadata.IMetadata
+	// supportsNodeStage tells by share protocol whether the proxied driver has the STAGE_UNSTAGE_VOLUME capability
+	supportsNodeStage map[string]bool
+	// supportsVolumeStats tells by share protocol whether the proxied driver has the GET_VOLUME_STATS capability
+	supportsVolumeStats map[string]bool
 	// The result of NodeStageVolume is stashed away for NodePublishVolume(s) that will follow
 	nodeStageCache    map[volumeID]stageCacheEntry
 	nodeStageCacheMtx sync.RWMutex
+
+	// volumeProtocols are the share protocols of the staged and published volumes, for the RPCs without volume context
+	volumeProtocols    map[volumeID]string
+	volumeProtocolsMtx sync.RWMutex
+	// mountProtocol returns the share protocol of the filesystem mounted at the path, empty if there is none
+	mountProtocol func(path string) (string, error)
+	// kernelRelease returns the release of the running kernel, for the selection of the CephFS mounter
+	kernelRelease func() (string, error)
+	// shareStats returns the stats of the share mounted at the path
+	shareStats func(shareProto, path string) (*shareStats, error)
+
+	// shareStatsCalls are the stats collections in progress by path
+	shareStatsCalls    map[string]*shareStatsCall
+	shareStatsCallsMtx sync.Mutex
 }
 
 type stageCacheEntry struct {
+	shareProto    string
 	volumeContext map[string]string
 	stageSecret   map[string]string
 	publishSecret map[string]string
 }
 
 func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.NodeVolumeContext, osOpts *client.AuthOpts) (
-	volumeContext map[string]string, accessRight *shares.AccessRight, err error,
+	volumeContext map[string]string, accessRight *shares.AccessRight, shareProto string, err error,
 ) {
 	manilaClient, err := ns.d.manilaClientBuilder.New(osOpts)
 	if err != nil {
-		return nil, nil, status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
+		return nil, nil, "", status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
 	}
 
 	// Retrieve the share by its ID or name
@@ -69,7 +89,7 @@ func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.Node
 				errCode = codes.NotFound
 			}
 
-			return nil, nil, status.Errorf(errCode, "failed to retrieve volume with share ID %s: %v", shareOpts.ShareID, err)
+			return nil, nil, "", status.Errorf(errCode, "failed to retrieve volume with share ID %s: %v", shareOpts.ShareID, err)
 		}
 	} else {
 		share, err = manilaClient.GetShareByName(shareOpts.ShareName)
@@ -79,24 +99,31 @@ func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.Node
 				errCode = codes.NotFound
 			}
 
-			return nil, nil, status.Errorf(errCode, "failed to retrieve volume with share name %s: %v", shareOpts.ShareName, err)
+			return nil, nil, "", status.Errorf(errCode, "failed to retrieve volume with share name %s: %v", shareOpts.ShareName, err)
 		}
 	}
 
 	// Verify the plugin supports this share
 
-	if !strings.EqualFold(share.ShareProto, ns.d.shareProto) {
-		return nil, nil, status.Errorf(codes.InvalidArgument,
-			"wrong share protocol %s for volume %s, the plugin is set to operate in %s",
-			share.ShareProto, volID, ns.d.shareProto)
+	if shareOpts.Protocol != "" && !strings.EqualFold(share.ShareProto, shareOpts.Protocol) {
+		return nil, nil, "", status.Errorf(codes.InvalidArgument,
+			"wrong share protocol %s for volume %s, the volume context requests %s",
+			share.ShareProto, volID, shareOpts.Protocol)
+	}
+
+	if !ns.d.servesShareProtocol(share.ShareProto) {
+		return nil, nil, "", status.Errorf(codes.InvalidArgument,
+			"wrong share protocol %s for volume %s, the plugin is set to operate on %v",
+			share.ShareProto, volID, ns.d.shareProtocols())
 	}
+	shareProto = strings.ToUpper(share.ShareProto)
 
 	if share.Status != shareAvailable {
 		if share.Status == shareCreating {
-			return nil, nil, status.Errorf(codes.Unavailable, "volume %s is in transient creating state", volID)
+			return nil, nil, "", status.Errorf(codes.Unavailable, "volume %s is in transient creating state", volID)
 		}
 
-		return nil, nil, status.Errorf(codes.FailedPrecondition, "invalid share status for volume %s: expected 'available', got '%s'",
+		return nil, nil, "", status.Errorf(codes.FailedPrecondition, "invalid share status for volume %s: expected 'available', got '%s'",
 			volID, share.Status)
 	}
 
@@ -104,7 +131,7 @@ func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.Node
 
 	accessRights, err := manilaClient.GetAccessRights(share.ID)
 	if err != nil {
-		return nil, nil, status.Errorf(codes.Internal, "failed to list access rights for volume %s: %v", volID, err)
+		return nil, nil, "", status.Errorf(codes.Internal, "failed to list access rights for volume %s: %v", volID, err)
 	}
 
 	for i := range accessRights {
@@ -115,7 +142,7 @@ func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.Node
 	}
 
 	if accessRight == nil {
-		return nil, nil, status.Errorf(codes.InvalidArgument, "cannot find access right %s for volume %s",
+		return nil, nil, "", status.Errorf(codes.InvalidArgument, "cannot find access right %s for volume %s",
 			shareOpts.ShareAccessID, volID)
 	}
 
@@ -124,19 +151,40 @@ func (ns *nodeServer) buildVolumeContext(volID volumeID, shareOpts *options.Node
 
 	availableExportLocations, err := manilaClient.GetExportLocations(share.ID)
 	if err != nil {
-		return nil, nil, status.Errorf(codes.Internal, "failed to list export locations for volume %s: %v", volID, err)
+		return nil, nil, "", status.Errorf(codes.Internal, "failed to list export locations for volume %s: %v", volID, err)
+	}
+
+	elConf, err := getExportLocationConfig(shareOpts)
+	if err != nil {
+		return nil, nil, "", status.Errorf(codes.Internal, "failed to get export location policy for volume %s: %v", volID, err)
+	}
+
+	policy, err := buildExportLocationPolicy(elConf, share, availableExportLocations, manilaClient, ns.metadata)
+	if err != nil {
+		return nil, nil, "", status.Errorf(codes.InvalidArgument, "failed to build export location policy for volume %s: %v", volID, err)
 	}
 
 	// Build volume context for fwd plugin
 
-	sa := getShareAdapter(ns.d.shareProto)
+	if shareProto == "CEPHFS" {
+		mounter, err := selectCephfsMounter(shareOpts, ns.kernelRelease)
+		if err != nil {
+			return nil, nil, "", status.Errorf(codes.Internal, "failed to select CephFS mounter for volume %s: %v", volID, err)
+		}
+
+		shareOpts.CephfsMounter = mounter
+		shareOpts.CephfsMountOptions = filterCephfsMountOptions(shareOpts.CephfsMountOptions, mounter)
+	}
+
+	sa := getShareAdapter(shareProto)
 	opts := &shareadapters.VolumeContextArgs{
 		Locations: availableExportLocations,
+		Policy:    policy,
 		Options:   shareOpts,
 	}
 	volumeContext, err = sa.BuildVolumeContext(opts)
 	if err != nil {
-		return nil, nil, status.Errorf(codes.InvalidArgument, "failed to build volume context for volume %s: %v", volID, err)
+		return nil, nil, "", status.Errorf(codes.InvalidArgument, "failed to build volume context for volume %s: %v", volID, err)
 	}
 
 	return
@@ -187,45 +235,48 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 
 	var (
 		accessRight       *shares.AccessRight
+		shareProto        string
 		volumeCtx, secret map[string]string
 	)
 
-	if ns.supportsNodeStage {
-		// STAGE_UNSTAGE_VOLUME capability is enabled, NodeStageVolume should've already built the staging data
+	// When STAGE_UNSTAGE_VOLUME capability is enabled, NodeStageVolume should've already built the staging data
 
-		ns.nodeStageCacheMtx.RLock()
-		cacheEntry, ok := ns.nodeStageCache[volID]
-		ns.nodeStageCacheMtx.RUnlock()
+	ns.nodeStageCacheMtx.RLock()
+	cacheEntry, ok := ns.nodeStageCache[volID]
+	ns.nodeStageCacheMtx.RUnlock()
 
-		if ok {
-			volumeCtx, secret = cacheEntry.volumeContext, cacheEntry.publishSecret
-		} else {
-			klog.Warningf("STAGE_UNSTAGE_VOLUME capability is enabled, but node stage cache doesn't contain an entry for %s - this is most likely a bug! Rebuilding staging data anyway...", volID)
-			volumeCtx, accessRight, err = ns.buildVolumeContext(volID, shareOpts, osOpts)
-			if err == nil {
-				secret, err = buildNodePublishSecret(accessRight, getShareAdapter(ns.d.shareProto), volID)
-			}
-		}
+	if ok {
+		shareProto, volumeCtx, secret = cacheEntry.shareProto, cacheEntry.volumeContext, cacheEntry.publishSecret
 	} else {
-		volumeCtx, accessRight, err = ns.buildVolumeContext(volID, shareOpts, osOpts)
+		volumeCtx, accessRight, shareProto, err = ns.buildVolumeContext(volID, shareOpts, osOpts)
 		if err == nil {
-			secret, err = buildNodePublishSecret(accessRight, getShareAdapter(ns.d.shareProto), volID)
+			if ns.supportsNodeStage[shareProto] {
+				klog.Warningf("STAGE_UNSTAGE_VOLUME capability is enabled, but node stage cache doesn't contain an entry for %s - this is most likely a bug! Rebuilding staging data anyway...", volID)
+			}
+			secret, err = buildNodePublishSecret(accessRight, getShareAdapter(shareProto), volID)
 		}
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	ns.setVolumeProtocol(volID, shareProto)
+
 	// Forward the RPC
 
-	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, ns.d.fwdEndpoint)
+	fwdEndpoint := ns.d.fwdEndpoints[shareProto]
+	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(ns.d.fwdEndpoint, err))
+		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
 	req.Secrets = secret
 	req.VolumeContext = volumeCtx
+	if !ns.supportsNodeStage[shareProto] {
+		// The volume was not staged by the proxied driver
+		req.StagingTargetPath = ""
+	}
 
 	return ns.d.csiClientBuilder.NewNodeServiceClient(csiConn).PublishVolume(ctx, req)
 }
@@ -235,9 +286,10 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, ns.d.fwdEndpoint)
+	fwdEndpoint := ns.d.fwdEndpoints[ns.volumeProtocol(volumeID(req.GetVolumeId()), req.GetTargetPath())]
+	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(ns.d.fwdEndpoint, err))
+		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
@@ -253,6 +305,7 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 
 	var (
 		accessRight                *shares.AccessRight
+		shareProto                 string
 		volumeCtx                  map[string]string
 		stageSecret, publishSecret map[string]string
 		err                        error
@@ -272,20 +325,20 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 
 	ns.nodeStageCacheMtx.Lock()
 	if cacheEntry, ok := ns.nodeStageCache[volID]; ok {
-		volumeCtx, stageSecret = cacheEntry.volumeContext, cacheEntry.stageSecret
+		shareProto, volumeCtx, stageSecret = cacheEntry.shareProto, cacheEntry.volumeContext, cacheEntry.stageSecret
 	} else {
-		volumeCtx, accessRight, err = ns.buildVolumeContext(volID, shareOpts, osOpts)
+		volumeCtx, accessRight, shareProto, err = ns.buildVolumeContext(volID, shareOpts, osOpts)
 
 		if err == nil {
-			stageSecret, err = buildNodeStageSecret(accessRight, getShareAdapter(ns.d.shareProto), volID)
+			stageSecret, err = buildNodeStageSecret(accessRight, getShareAdapter(shareProto), volID)
 		}
 
 		if err == nil {
-			publishSecret, err = buildNodePublishSecret(accessRight, getShareAdapter(ns.d.shareProto), volID)
+			publishSecret, err = buildNodePublishSecret(accessRight, getShareAdapter(shareProto), volID)
 		}
 
 		if err == nil {
-			ns.nodeStageCache[volID] = stageCacheEntry{volumeContext: volumeCtx, stageSecret: stageSecret, publishSecret: publishSecret}
+			ns.nodeStageCache[volID] = stageCacheEntry{shareProto: shareProto, volumeContext: volumeCtx, stageSecret: stageSecret, publishSecret: publishSecret}
 		}
 	}
 	ns.nodeStageCacheMtx.Unlock()
@@ -293,11 +346,19 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, err
 	}
 
+	ns.setVolumeProtocol(volID, shareProto)
+
+	if !ns.supportsNodeStage[shareProto] {
+		// Another proxied driver has the STAGE_UNSTAGE_VOLUME capability, the volume is only published
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
 	// Forward the RPC
 
-	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, ns.d.fwdEndpoint)
+	fwdEndpoint := ns.d.fwdEndpoints[shareProto]
+	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(ns.d.fwdEndpoint, err))
+		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
@@ -312,13 +373,25 @@ func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	volID := volumeID(req.GetVolumeId())
+	shareProto := ns.volumeProtocol(volID, req.GetStagingTargetPath())
+
 	ns.nodeStageCacheMtx.Lock()
-	delete(ns.nodeStageCache, volumeID(req.VolumeId))
+	delete(ns.nodeStageCache, volID)
 	ns.nodeStageCacheMtx.Unlock()
 
-	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, ns.d.fwdEndpoint)
+	ns.volumeProtocolsMtx.Lock()
+	delete(ns.volumeProtocols, volID)
+	ns.volumeProtocolsMtx.Unlock()
+
+	if !ns.supportsNodeStage[shareProto] {
+		return &csi.NodeUnstageVolumeResponse{}, nil
+	}
+
+	fwdEndpoint := ns.d.fwdEndpoints[shareProto]
+	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(ns.d.fwdEndpoint, err))
+		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
@@ -344,8 +417,14 @@ func (ns *nodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 		return nil, status.Errorf(codes.Internal, "[NodeGetInfo] Unable to retrieve availability zone of node %v", err)
 	}
 
+	segments, err := getShareNetworkSegments()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "[NodeGetInfo] Unable to retrieve share networks of node %v", err)
+	}
+	segments[topologyKey] = zone
+
 	nodeInfo.AccessibleTopology = &csi.Topology{
-		Segments: map[string]string{topologyKey: zone},
+		Segments: segments,
 	}
 
 	return nodeInfo, nil
@@ -358,9 +437,41 @@ func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 }
 
 func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, ns.d.fwdEndpoint)
+	if err := validateNodeGetVolumeStatsRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	volID := volumeID(req.GetVolumeId())
+	volumePath := req.GetVolumePath()
+	shareProto := ns.volumeProtocol(volID, volumePath)
+
+	// The stats are collected by the plugin rather than the proxied drivers, so that they're reported for both share
+	// protocols, with the quotas of the CephFS subvolumes.
+	stats, err := ns.collectShareStats(ctx, shareProto, volumePath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// The pods directory of kubelet isn't mounted in the container, e.g. with the manifests of the previous
+		// releases, or the volume is gone
+		if ns.supportsVolumeStats[shareProto] {
+			return ns.forwardNodeGetVolumeStats(ctx, shareProto, req)
+		}
+		return nil, status.Errorf(codes.NotFound, "volume path %s not found", volumePath)
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Errorf(codes.DeadlineExceeded, "timed out getting stats of volume %s at %s", volID, volumePath)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to get stats of volume %s at %s: %v", volID, volumePath, err)
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: stats.toVolumeUsage(),
+	}, nil
+}
+
+func (ns *nodeServer) forwardNodeGetVolumeStats(ctx context.Context, shareProto string, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	fwdEndpoint := ns.d.fwdEndpoints[shareProto]
+	csiConn, err := ns.d.csiClientBuilder.NewConnectionWithContext(ctx, fwdEndpoint)
 	if err != nil {
-		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(ns.d.fwdEndpoint, err))
+		return nil, status.Error(codes.Unavailable, fmtGrpcConnError(fwdEndpoint, err))
 	}
 	defer csiConn.Close()
 
@@ -370,3 +481,40 @@ func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVo
 func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "")
 }
+
+func (ns *nodeServer) setVolumeProtocol(volID volumeID, shareProto string) {
+	ns.volumeProtocolsMtx.Lock()
+	defer ns.volumeProtocolsMtx.Unlock()
+
+	ns.volumeProtocols[volID] = shareProto
+}
+
+// volumeProtocol returns the share protocol of the volume staged or published at path, for the RPCs forwarded to the
+// proxied driver of the protocol without the volume context. The protocol is known from the RPCs the volume was staged
+// or published with, or else from the filesystem mounted at path, e.g. after a restart of the plugin.
+func (ns *nodeServer) volumeProtocol(volID volumeID, path string) string {
+	protos := ns.d.shareProtocols()
+	if len(protos) == 1 {
+		return protos[0]
+	}
+
+	ns.volumeProtocolsMtx.RLock()
+	shareProto, ok := ns.volumeProtocols[volID]
+	ns.volumeProtocolsMtx.RUnlock()
+	if ok {
+		return shareProto
+	}
+
+	shareProto, err := ns.mountProtocol(path)
+	if err != nil {
+		klog.Warningf("Failed to get the share protocol of volume %s from the filesystem mounted at %s: %v", volID, path, err)
+	} else if shareProto != "" && ns.d.servesShareProtocol(shareProto) {
+		return shareProto
+	}
+
+	// Nothing mounted, the proxied driver only has to clean the path up
+	if ns.d.shareProto != "" {
+		return ns.d.shareProto
+	}
+	return protos[0]
+}
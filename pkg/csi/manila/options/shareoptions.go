@@ -21,7 +21,7 @@ import (
 )
 
 type ControllerVolumeContext struct {
-	Protocol            string `name:"protocol" matches:"^(?i)CEPHFS|NFS$"`
+	Protocol            string `name:"protocol" matches:"^(?i)(CEPHFS|NFS)$"`
 	Type                string `name:"type" value:"default:default"`
 	ShareNetworkID      string `name:"shareNetworkID" value:"optional"`
 	AutoTopology        string `name:"autoTopology" value:"default:false" matches:"(?i)^true|false$"`
@@ -30,26 +30,45 @@ type ControllerVolumeContext struct {
 	Affinity            string `name:"affinity" value:"optional"`
 	AntiAffinity        string `name:"antiAffinity" value:"optional"`
 	GroupID             string `name:"groupID" value:"optional"`
+	Encrypted           string `name:"encrypted" value:"default:false" matches:"(?i)^(true|false)$"`
+	EncryptionKeyRef    string `name:"encryptionKeyRef" value:"optional"`
 
 	// Adapter options
 
-	CephfsMounter            string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
+	CephfsMounter            string `name:"cephfs-mounter" value:"default:fuse" matches:"^(kernel|fuse|auto)$"`
 	CephfsClientID           string `name:"cephfs-clientID" value:"optional"`
 	CephfsKernelMountOptions string `name:"cephfs-kernelMountOptions" value:"optional"`
 	CephfsFuseMountOptions   string `name:"cephfs-fuseMountOptions" value:"optional"`
+	CephfsMountOptions       string `name:"cephfs-mountOptions" value:"optional"`
 	NFSShareClient           string `name:"nfs-shareClient" value:"default:0.0.0.0/0"`
+
+	// Export location selection policy, used by the node plugin
+
+	ExportLocationPathPattern    string `name:"exportLocationPathPattern" value:"optional"`
+	ExportLocationZoneAffinity   string `name:"exportLocationZoneAffinity" value:"optional" matches:"(?i)^(true|false)$"`
+	ExportLocationAllowAdminOnly string `name:"exportLocationAllowAdminOnly" value:"optional" matches:"(?i)^(true|false)$"`
 }
 
 type NodeVolumeContext struct {
 	ShareID       string `name:"shareID" value:"optionalIf:shareName=." precludes:"shareName"`
 	ShareName     string `name:"shareName" value:"optionalIf:shareID=." precludes:"shareID"`
 	ShareAccessID string `name:"shareAccessID"`
+	// Protocol is the share protocol selected by the controller, the node plugins operating on several share protocols
+	// forward the RPCs to the proxied driver of the protocol.
+	Protocol string `name:"protocol" value:"optional" matches:"^(?i)(CEPHFS|NFS)$"`
 
 	// Adapter options
 
-	CephfsMounter            string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
+	CephfsMounter            string `name:"cephfs-mounter" value:"default:fuse" matches:"^(kernel|fuse|auto)$"`
 	CephfsKernelMountOptions string `name:"cephfs-kernelMountOptions" value:"optional"`
 	CephfsFuseMountOptions   string `name:"cephfs-fuseMountOptions" value:"optional"`
+	CephfsMountOptions       string `name:"cephfs-mountOptions" value:"optional"`
+
+	// Export location selection policy, overrides the runtime configuration of the node
+
+	ExportLocationPathPattern    string `name:"exportLocationPathPattern" value:"optional"`
+	ExportLocationZoneAffinity   string `name:"exportLocationZoneAffinity" value:"optional" matches:"(?i)^(true|false)$"`
+	ExportLocationAllowAdminOnly string `name:"exportLocationAllowAdminOnly" value:"optional" matches:"(?i)^(true|false)$"`
 }
 
 var (
@@ -0,0 +1,27 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtimeconfig
+
+type ExportLocationConfig struct {
+	// When a share has multiple suitable export locations, prefer the ones with a path
+	// matching this regular expression.
+	PathPattern string `json:"pathPattern,omitempty"`
+	// Prefer the export locations of the share instances in the availability zone of the node.
+	ZoneAffinity bool `json:"zoneAffinity,omitempty"`
+	// Also consider the admin-only export locations. They're excluded by default.
+	AllowAdminOnly bool `json:"allowAdminOnly,omitempty"`
+}
@@ -27,13 +27,27 @@ var (
 )
 
 type RuntimeConfig struct {
-	Nfs *NfsConfig `json:"nfs,omitempty"`
+	Nfs      *NfsConfig      `json:"nfs,omitempty"`
+	Topology *TopologyConfig `json:"topology,omitempty"`
+
+	ExportLocation *ExportLocationConfig `json:"exportLocation,omitempty"`
 }
 
+// Get returns the runtime configuration. When the file is watched, the last successfully
+// loaded configuration is returned, otherwise the file is read on each call.
 func Get() (*RuntimeConfig, error) {
-	// File contents are deliberately not cached
-	// as they may change over time.
-	data, err := os.ReadFile(RuntimeConfigFilename)
+	mu.RLock()
+	if watcher != nil {
+		defer mu.RUnlock()
+		return current, nil
+	}
+	mu.RUnlock()
+
+	return read(RuntimeConfigFilename)
+}
+
+func read(path string) (*RuntimeConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
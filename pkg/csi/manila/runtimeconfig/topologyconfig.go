@@ -0,0 +1,24 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtimeconfig
+
+type TopologyConfig struct {
+	// IDs of the Manila share networks reachable from the nodes using this runtime configuration.
+	// With topology awareness enabled, the nodes are labeled with a topology key per share network,
+	// so that the workloads consuming a share land on nodes with access to its share network.
+	ShareNetworks []string `json:"shareNetworks,omitempty"`
+}
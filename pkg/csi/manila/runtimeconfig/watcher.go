@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtimeconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+var (
+	lastReloadTimestamp = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "manila_csi_runtime_config_last_reload_timestamp_seconds",
+			Help: "Timestamp of the last successful reload of the runtime config file",
+		})
+	reloadErrors = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "manila_csi_runtime_config_reload_errors_total",
+			Help: "Total number of failed reloads of the runtime config file",
+		})
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the runtime config metrics.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(lastReloadTimestamp, reloadErrors)
+	})
+}
+
+var (
+	mu sync.RWMutex
+	// watcher is set while the runtime config file is watched, in which case current holds its last valid content.
+	watcher *fsnotify.Watcher
+	current *RuntimeConfig
+)
+
+// Watch loads the runtime config file and reloads it whenever it changes, until stopCh is closed.
+// The file must be valid initially. When a reload fails, the previous configuration is kept.
+func Watch(stopCh <-chan struct{}) error {
+	path := RuntimeConfigFilename
+	if path == "" {
+		return nil
+	}
+
+	RegisterMetrics()
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create runtime config file watcher: %v", err)
+	}
+
+	// The directory is watched rather than the file, as ConfigMap volumes update the files by
+	// swapping a symlink, which doesn't generate any event for the file itself.
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to watch runtime config file %s: %v", path, err)
+	}
+
+	cfg, err := read(path)
+	if err != nil {
+		w.Close()
+		return fmt.Errorf("failed to read runtime config file %s: %v", path, err)
+	}
+
+	mu.Lock()
+	current = cfg
+	watcher = w
+	mu.Unlock()
+	lastReloadTimestamp.SetToCurrentTime()
+
+	go func() {
+		defer func() {
+			w.Close()
+
+			mu.Lock()
+			if watcher == w {
+				watcher = nil
+			}
+			mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+					continue
+				}
+				reload(path)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("Error watching runtime config file %s: %v", path, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func reload(path string) {
+	cfg, err := read(path)
+	if err != nil {
+		reloadErrors.Inc()
+		klog.Errorf("Failed to reload runtime config file %s, keeping the previous configuration: %v", path, err)
+		return
+	}
+
+	mu.Lock()
+	changed := !reflect.DeepEqual(current, cfg)
+	current = cfg
+	mu.Unlock()
+	lastReloadTimestamp.SetToCurrentTime()
+
+	if changed {
+		klog.Infof("Reloaded runtime config file %s", path)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtimeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+	// Replace the file atomically, the same way ConfigMap volumes are updated.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getMatchAddress() string {
+	cfg, err := Get()
+	if err != nil || cfg == nil || cfg.Nfs == nil {
+		return ""
+	}
+	return cfg.Nfs.MatchExportLocationAddress
+}
+
+func TestWatch(t *testing.T) {
+	RuntimeConfigFilename = filepath.Join(t.TempDir(), "runtimeconfig.json")
+	defer func() { RuntimeConfigFilename = "" }()
+
+	writeConfig(t, RuntimeConfigFilename, `{"nfs":{"matchExportLocationAddress":"10.0.0.0/24"}}`)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.NoError(t, Watch(stopCh))
+	assert.Equal(t, "10.0.0.0/24", getMatchAddress())
+
+	writeConfig(t, RuntimeConfigFilename, `{"nfs":{"matchExportLocationAddress":"10.0.1.0/24"}}`)
+	assert.Eventually(t, func() bool {
+		return getMatchAddress() == "10.0.1.0/24"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// An invalid file keeps the previous configuration
+	writeConfig(t, RuntimeConfigFilename, `{"nfs":`)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "10.0.1.0/24", getMatchAddress())
+
+	// A removed file drops the configuration
+	assert.NoError(t, os.Remove(RuntimeConfigFilename))
+	assert.Eventually(t, func() bool {
+		cfg, err := Get()
+		return err == nil && cfg == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatchInvalidFile(t *testing.T) {
+	RuntimeConfigFilename = filepath.Join(t.TempDir(), "runtimeconfig.json")
+	defer func() { RuntimeConfigFilename = "" }()
+
+	writeConfig(t, RuntimeConfigFilename, `{"nfs":`)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.Error(t, Watch(stopCh))
+}
@@ -43,6 +43,7 @@ const (
 	shareErrorDeleting        = "error_deleting"
 	shareErrorExtending       = "extending_error"
 	shareAvailable            = "available"
+	shareAwaitingTransfer     = "awaiting_transfer"
 
 	shareDescription = "provisioned-by=manila.csi.openstack.org"
 )
@@ -62,7 +63,7 @@ func isShareInErrorState(s string) bool {
 
 // getOrCreateShare first retrieves an existing share with name=shareName, or creates a new one if it doesn't exist yet.
 // Once the share is created, an exponential back-off is used to wait till the status of the share is "available".
-func getOrCreateShare(manilaClient manilaclient.Interface, shareName string, createOpts *shares.CreateOpts) (*shares.Share, manilaError, error) {
+func getOrCreateShare(manilaClient manilaclient.Interface, shareName string, createOpts shares.CreateOptsBuilder) (*shares.Share, manilaError, error) {
 	var (
 		share *shares.Share
 		err   error
@@ -76,7 +77,7 @@ func getOrCreateShare(manilaClient manilaclient.Interface, shareName string, cre
 
 			var createErr error
 			if share, createErr = manilaClient.CreateShare(createOpts); createErr != nil {
-				return nil, 0, createErr
+				return nil, classifyCreateShareError(createErr), createErr
 			}
 		} else {
 			// Something else is wrong
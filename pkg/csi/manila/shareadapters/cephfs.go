@@ -18,6 +18,7 @@ package shareadapters
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
@@ -110,7 +111,7 @@ func (Cephfs) GetOrGrantAccess(args *GrantAccessArgs) (accessRight *shares.Acces
 }
 
 func (Cephfs) BuildVolumeContext(args *VolumeContextArgs) (volumeContext map[string]string, err error) {
-	chosenExportLocationIdx, err := manilautil.FindExportLocation(args.Locations, manilautil.AnyExportLocation)
+	chosenExportLocationIdx, err := manilautil.FindExportLocationWithPolicy(args.Locations, manilautil.AnyExportLocation, args.Policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to choose an export location: %v", err)
 	}
@@ -124,12 +125,13 @@ func (Cephfs) BuildVolumeContext(args *VolumeContextArgs) (volumeContext map[str
 		"provisionVolume": "false",
 	}
 
-	if args.Options.CephfsKernelMountOptions != "" {
-		volCtx["kernelMountOptions"] = args.Options.CephfsKernelMountOptions
+	// The common mount options are passed to whichever client mounts the share
+	if opts := joinMountOptions(args.Options.CephfsMountOptions, args.Options.CephfsKernelMountOptions); opts != "" {
+		volCtx["kernelMountOptions"] = opts
 	}
 
-	if args.Options.CephfsFuseMountOptions != "" {
-		volCtx["fuseMountOptions"] = args.Options.CephfsFuseMountOptions
+	if opts := joinMountOptions(args.Options.CephfsMountOptions, args.Options.CephfsFuseMountOptions); opts != "" {
+		volCtx["fuseMountOptions"] = opts
 	}
 
 	return volCtx, err
@@ -145,3 +147,15 @@ func (Cephfs) BuildNodeStageSecret(args *SecretArgs) (secret map[string]string,
 func (Cephfs) BuildNodePublishSecret(args *SecretArgs) (secret map[string]string, err error) {
 	return nil, nil
 }
+
+// joinMountOptions joins the non-empty comma separated mount options.
+func joinMountOptions(opts ...string) string {
+	var nonEmpty []string
+	for _, o := range opts {
+		if o != "" {
+			nonEmpty = append(nonEmpty, o)
+		}
+	}
+
+	return strings.Join(nonEmpty, ",")
+}
@@ -61,7 +61,7 @@ func (NFS) GetOrGrantAccess(args *GrantAccessArgs) (*shares.AccessRight, error)
 }
 
 func (NFS) BuildVolumeContext(args *VolumeContextArgs) (volumeContext map[string]string, err error) {
-	chosenExportLocationIdx, err := nfsChooseExportLocation(args.Locations)
+	chosenExportLocationIdx, err := nfsChooseExportLocation(args.Locations, args.Policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to choose an export location: %v", err)
 	}
@@ -86,8 +86,8 @@ func (NFS) BuildNodePublishSecret(args *SecretArgs) (secret map[string]string, e
 // Returns index into `locs`.
 // Runtime config for NFS is probed first to see if it contains any export location filters.
 // Those are then used for selecting the location. If none are defined, the function
-// falls back to using manilautil.AnyExportLocation filter.
-func nfsChooseExportLocation(locs []shares.ExportLocation) (chosenExportLocationIdx int, err error) {
+// falls back to using manilautil.AnyExportLocation filter. The matching locations are ranked by the policy.
+func nfsChooseExportLocation(locs []shares.ExportLocation, policy *manilautil.ExportLocationPolicy) (chosenExportLocationIdx int, err error) {
 	var conf *runtimeconfig.RuntimeConfig
 
 	if conf, err = runtimeconfig.Get(); err != nil {
@@ -95,7 +95,7 @@ func nfsChooseExportLocation(locs []shares.ExportLocation) (chosenExportLocation
 	}
 
 	if conf != nil {
-		if chosenExportLocationIdx, err = nfsMatchExportLocationFromConfig(locs, conf); err != nil {
+		if chosenExportLocationIdx, err = nfsMatchExportLocationFromConfig(locs, conf, policy); err != nil {
 			return -1, err
 		}
 
@@ -108,13 +108,13 @@ func nfsChooseExportLocation(locs []shares.ExportLocation) (chosenExportLocation
 		// Fall through and choose any suitable location.
 	}
 
-	return manilautil.FindExportLocation(locs, manilautil.AnyExportLocation)
+	return manilautil.FindExportLocationWithPolicy(locs, manilautil.AnyExportLocation, policy)
 }
 
-func nfsMatchExportLocationFromConfig(locs []shares.ExportLocation, conf *runtimeconfig.RuntimeConfig) (idx int, err error) {
+func nfsMatchExportLocationFromConfig(locs []shares.ExportLocation, conf *runtimeconfig.RuntimeConfig, policy *manilautil.ExportLocationPolicy) (idx int, err error) {
 	if conf.Nfs != nil {
 		if conf.Nfs.MatchExportLocationAddress != "" {
-			return nfsMatchExportLocationAddress(locs, conf.Nfs.MatchExportLocationAddress)
+			return nfsMatchExportLocationAddress(locs, conf.Nfs.MatchExportLocationAddress, policy)
 		}
 	}
 
@@ -123,7 +123,7 @@ func nfsMatchExportLocationFromConfig(locs []shares.ExportLocation, conf *runtim
 }
 
 // Selects an export location with a matching address
-func nfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress string) (idx int, err error) {
+func nfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress string, policy *manilautil.ExportLocationPolicy) (idx int, err error) {
 	if ip := net.ParseIP(matchAddress); ip != nil {
 		// `matchAddress` is a valid IP, but does not have a prefix.
 		// This means we're looking for an exact match in export location addresses.
@@ -143,7 +143,7 @@ func nfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress st
 		return -1, fmt.Errorf("matchExportLocationAddress filter '%s' is not a CIDR-formatted IP address", matchAddress)
 	}
 
-	idx, err = manilautil.FindExportLocation(locs, func(i int) (bool, error) {
+	idx, err = manilautil.FindExportLocationWithPolicy(locs, func(i int) (bool, error) {
 		addr, _, err := splitExportLocationPath(locs[i].Path)
 		if err != nil {
 			return false, err
@@ -155,7 +155,7 @@ func nfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress st
 		}
 
 		return netIP.Contains(hostIP), nil
-	})
+	}, policy)
 
 	if err != nil {
 		return -1, fmt.Errorf("matchExportLocationAddress filter '%s': %v", matchAddress, err)
@@ -20,6 +20,7 @@ import (
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	manilautil "k8s.io/cloud-provider-openstack/pkg/csi/manila/util"
 )
 
 type GrantAccessArgs struct {
@@ -32,6 +33,8 @@ type VolumeContextArgs struct {
 	// Share adapters are responsible for choosing
 	// an export location when building a volume context.
 	Locations []shares.ExportLocation
+	// Policy ranks the suitable export locations.
+	Policy *manilautil.ExportLocationPolicy
 
 	Options *options.NodeVolumeContext
 }
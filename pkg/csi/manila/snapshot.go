@@ -18,9 +18,14 @@ package manila
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
 	clouderrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
@@ -72,6 +77,62 @@ func getOrCreateSnapshot(manilaClient manilaclient.Interface, snapName, sourceSh
 	return snapshot, nil
 }
 
+// listSnapshots lists the snapshots of the shares of the shareProtos protocols taken by the driver, sorted by ID. A snapshot requested
+// by its ID is listed even if it was not taken by the driver.
+func listSnapshots(manilaClient manilaclient.Interface, snapID, sourceShareID string, shareProtos []string) ([]snapshots.Snapshot, error) {
+	var snaps []snapshots.Snapshot
+
+	if snapID != "" {
+		snapshot, err := manilaClient.GetSnapshotByID(snapID)
+		if err != nil {
+			if clouderrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if sourceShareID != "" && snapshot.ShareID != sourceShareID {
+			return nil, nil
+		}
+		snaps = []snapshots.Snapshot{*snapshot}
+	} else {
+		var err error
+		snaps, err = manilaClient.ListSnapshots(snapshots.ListOpts{ShareID: sourceShareID, Description: snapshotDescription})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := snaps[:0]
+	for _, snapshot := range snaps {
+		if snapshot.ShareProto == "" || slices.ContainsFunc(shareProtos, func(shareProto string) bool {
+			return strings.EqualFold(snapshot.ShareProto, shareProto)
+		}) {
+			filtered = append(filtered, snapshot)
+		}
+	}
+
+	// Sort the snapshots so that the pages of consecutive calls are consistent
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].ID < filtered[j].ID })
+
+	return filtered, nil
+}
+
+// csiSnapshot converts a Manila snapshot to a CSI snapshot.
+func csiSnapshot(snapshot *snapshots.Snapshot) *csi.Snapshot {
+	ctime := timestamppb.New(snapshot.CreatedAt)
+	if err := ctime.CheckValid(); err != nil {
+		klog.Warningf("couldn't parse timestamp %v from snapshot %s: %v", snapshot.CreatedAt, snapshot.ID, err)
+	}
+
+	return &csi.Snapshot{
+		SnapshotId:     snapshot.ID,
+		SourceVolumeId: snapshot.ShareID,
+		SizeBytes:      int64(max(snapshot.Size, snapshot.ShareSize)) * bytesInGiB,
+		CreationTime:   ctime,
+		ReadyToUse:     snapshot.Status == snapshotAvailable,
+	}
+}
+
 func deleteSnapshot(manilaClient manilaclient.Interface, snapID string) error {
 	if err := manilaClient.DeleteSnapshot(snapID); err != nil {
 		if clouderrors.IsNotFound(err) {
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+)
+
+// snapshotsManilaClient serves a fixed set of snapshots.
+type snapshotsManilaClient struct {
+	manilaclient.Interface
+
+	snapshots []snapshots.Snapshot
+}
+
+func (c *snapshotsManilaClient) GetSnapshotByID(snapID string) (*snapshots.Snapshot, error) {
+	for i := range c.snapshots {
+		if c.snapshots[i].ID == snapID {
+			return &c.snapshots[i], nil
+		}
+	}
+	return nil, gophercloud.ErrResourceNotFound{}
+}
+
+func (c *snapshotsManilaClient) ListSnapshots(opts snapshots.ListOptsBuilder) ([]snapshots.Snapshot, error) {
+	listOpts := opts.(snapshots.ListOpts)
+
+	var snaps []snapshots.Snapshot
+	for _, snapshot := range c.snapshots {
+		if (listOpts.ShareID == "" || snapshot.ShareID == listOpts.ShareID) && snapshot.Description == listOpts.Description {
+			snaps = append(snaps, snapshot)
+		}
+	}
+	return snaps, nil
+}
+
+func TestListSnapshots(t *testing.T) {
+	client := &snapshotsManilaClient{
+		snapshots: []snapshots.Snapshot{
+			{ID: "snap-3", ShareID: "share-a", ShareProto: "NFS", Description: snapshotDescription},
+			{ID: "snap-1", ShareID: "share-a", ShareProto: "NFS", Description: snapshotDescription},
+			{ID: "snap-2", ShareID: "share-b", ShareProto: "NFS", Description: snapshotDescription},
+			{ID: "snap-4", ShareID: "share-c", ShareProto: "CEPHFS", Description: snapshotDescription},
+			{ID: "snap-5", ShareID: "share-a", ShareProto: "NFS", Description: "manual"},
+		},
+	}
+
+	ts := []struct {
+		name          string
+		snapID        string
+		sourceShareID string
+		expectedIDs   []string
+	}{
+		{name: "all", expectedIDs: []string{"snap-1", "snap-2", "snap-3"}},
+		{name: "by source share", sourceShareID: "share-a", expectedIDs: []string{"snap-1", "snap-3"}},
+		{name: "by ID", snapID: "snap-2", expectedIDs: []string{"snap-2"}},
+		{name: "by ID not taken by the driver", snapID: "snap-5", expectedIDs: []string{"snap-5"}},
+		{name: "by ID and other source share", snapID: "snap-2", sourceShareID: "share-a"},
+		{name: "by ID of another protocol", snapID: "snap-4"},
+		{name: "by unknown ID", snapID: "snap-6"},
+	}
+
+	for _, tt := range ts {
+		snaps, err := listSnapshots(client, tt.snapID, tt.sourceShareID, []string{"NFS"})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		var ids []string
+		for _, snapshot := range snaps {
+			ids = append(ids, snapshot.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.expectedIDs) {
+			t.Errorf("%s: returned incorrect snapshots: got %v, expected %v", tt.name, ids, tt.expectedIDs)
+		}
+	}
+}
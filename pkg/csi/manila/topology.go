@@ -0,0 +1,160 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/runtimeconfig"
+)
+
+// shareNetworkTopologyKeyPrefix prefixes the topology keys of the share networks reachable from a node,
+// the share network ID follows.
+const shareNetworkTopologyKeyPrefix = "topology.manila.csi.openstack.org/share-network-"
+
+func shareNetworkTopologyKey(shareNetworkID string) string {
+	return shareNetworkTopologyKeyPrefix + shareNetworkID
+}
+
+// getShareNetworkSegments returns the topology segments of the share networks listed in the runtime configuration.
+func getShareNetworkSegments() (map[string]string, error) {
+	conf, err := runtimeconfig.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime config file %s: %v", runtimeconfig.RuntimeConfigFilename, err)
+	}
+
+	segments := make(map[string]string)
+	if conf == nil || conf.Topology == nil {
+		return segments, nil
+	}
+
+	for _, id := range conf.Topology.ShareNetworks {
+		if id = strings.TrimSpace(id); id != "" {
+			segments[shareNetworkTopologyKey(id)] = "true"
+		}
+	}
+
+	return segments, nil
+}
+
+// hasShareNetworkSegments checks whether the nodes of the topology requirement publish their share networks.
+func hasShareNetworkSegments(req *csi.TopologyRequirement) bool {
+	for _, topology := range slices.Concat(req.GetRequisite(), req.GetPreferred()) {
+		for key := range topology.GetSegments() {
+			if strings.HasPrefix(key, shareNetworkTopologyKeyPrefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getShareNetworkFromTopology returns the first share network reachable from the topology requirement,
+// the preferred topologies first.
+func getShareNetworkFromTopology(req *csi.TopologyRequirement) string {
+	for _, topology := range slices.Concat(req.GetPreferred(), req.GetRequisite()) {
+		var ids []string
+		for key, value := range topology.GetSegments() {
+			if value == "true" && strings.HasPrefix(key, shareNetworkTopologyKeyPrefix) {
+				ids = append(ids, strings.TrimPrefix(key, shareNetworkTopologyKeyPrefix))
+			}
+		}
+		if len(ids) > 0 {
+			// Segments are a map, pick the same share network on each call
+			return slices.Min(ids)
+		}
+	}
+
+	return ""
+}
+
+// isShareNetworkReachable checks whether the share network is reachable from at least one of the topologies
+// of the requirement.
+func isShareNetworkReachable(req *csi.TopologyRequirement, shareNetworkID string) bool {
+	key := shareNetworkTopologyKey(shareNetworkID)
+	for _, topology := range slices.Concat(req.GetRequisite(), req.GetPreferred()) {
+		if topology.GetSegments()[key] == "true" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withShareNetworkTopology restricts the topologies to the nodes reaching the share network, if any. The segments
+// of the other share networks are dropped, the nodes don't need to reach them.
+func withShareNetworkTopology(topologies []*csi.Topology, shareNetworkID string) []*csi.Topology {
+	if len(topologies) == 0 {
+		if shareNetworkID == "" {
+			return topologies
+		}
+		return []*csi.Topology{{
+			Segments: map[string]string{shareNetworkTopologyKey(shareNetworkID): "true"},
+		}}
+	}
+
+	var res []*csi.Topology
+	for _, topology := range topologies {
+		segments := make(map[string]string)
+		if shareNetworkID != "" {
+			segments[shareNetworkTopologyKey(shareNetworkID)] = "true"
+		}
+		for key, value := range topology.GetSegments() {
+			if !strings.HasPrefix(key, shareNetworkTopologyKeyPrefix) {
+				segments[key] = value
+			}
+		}
+
+		duplicate := false
+		for _, t := range res {
+			if reflect.DeepEqual(t.Segments, segments) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			res = append(res, &csi.Topology{Segments: segments})
+		}
+	}
+
+	return res
+}
+
+// withShareZoneTopology restricts the topologies to the availability zone of the share. They're left as they are when
+// none of them is in the zone, e.g. when the availability zones of Manila and Nova are named differently.
+func withShareZoneTopology(topologies []*csi.Topology, zone string) []*csi.Topology {
+	if zone == "" {
+		return topologies
+	}
+
+	var res []*csi.Topology
+	for _, topology := range topologies {
+		if topology.GetSegments()[topologyKey] == zone {
+			res = append(res, topology)
+		}
+	}
+	if len(res) == 0 {
+		return topologies
+	}
+
+	return res
+}
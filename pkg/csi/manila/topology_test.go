@@ -0,0 +1,160 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/runtimeconfig"
+)
+
+func TestGetShareNetworkSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtimeconfig.json")
+	if err := os.WriteFile(path, []byte(`{"topology": {"shareNetworks": ["sn-1", " sn-2 ", ""]}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(filename string) { runtimeconfig.RuntimeConfigFilename = filename }(runtimeconfig.RuntimeConfigFilename)
+	runtimeconfig.RuntimeConfigFilename = path
+
+	segments, err := getShareNetworkSegments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"topology.manila.csi.openstack.org/share-network-sn-1": "true",
+		"topology.manila.csi.openstack.org/share-network-sn-2": "true",
+	}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("expected segments %v, got %v", expected, segments)
+	}
+}
+
+func TestGetShareNetworkFromTopology(t *testing.T) {
+	req := &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "zone-a", shareNetworkTopologyKey("sn-3"): "true"}},
+		},
+		Preferred: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "zone-b"}},
+			{Segments: map[string]string{topologyKey: "zone-b", shareNetworkTopologyKey("sn-2"): "true", shareNetworkTopologyKey("sn-1"): "true"}},
+		},
+	}
+
+	if !hasShareNetworkSegments(req) {
+		t.Errorf("expected the share networks to be published")
+	}
+	if id := getShareNetworkFromTopology(req); id != "sn-1" {
+		t.Errorf("expected share network sn-1, got %q", id)
+	}
+	if !isShareNetworkReachable(req, "sn-3") {
+		t.Errorf("expected share network sn-3 to be reachable")
+	}
+	if isShareNetworkReachable(req, "sn-4") {
+		t.Errorf("expected share network sn-4 not to be reachable")
+	}
+
+	zoneOnly := &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{topologyKey: "zone-a"}}}}
+	if hasShareNetworkSegments(zoneOnly) {
+		t.Errorf("expected no share network to be published")
+	}
+}
+
+func TestWithShareNetworkTopology(t *testing.T) {
+	topologies := []*csi.Topology{
+		{Segments: map[string]string{topologyKey: "zone-a", shareNetworkTopologyKey("sn-1"): "true", shareNetworkTopologyKey("sn-2"): "true"}},
+		{Segments: map[string]string{topologyKey: "zone-a", shareNetworkTopologyKey("sn-1"): "true"}},
+	}
+
+	tcs := []struct {
+		name           string
+		topologies     []*csi.Topology
+		shareNetworkID string
+		expected       []*csi.Topology
+	}{
+		{
+			name:           "restricted to the share network",
+			topologies:     topologies,
+			shareNetworkID: "sn-1",
+			expected:       []*csi.Topology{{Segments: map[string]string{topologyKey: "zone-a", shareNetworkTopologyKey("sn-1"): "true"}}},
+		},
+		{
+			name:       "share networks dropped",
+			topologies: topologies,
+			expected:   []*csi.Topology{{Segments: map[string]string{topologyKey: "zone-a"}}},
+		},
+		{
+			name:           "no topology",
+			shareNetworkID: "sn-1",
+			expected:       []*csi.Topology{{Segments: map[string]string{shareNetworkTopologyKey("sn-1"): "true"}}},
+		},
+		{
+			name:     "no topology nor share network",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			res := withShareNetworkTopology(tc.topologies, tc.shareNetworkID)
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Errorf("expected topologies %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestWithShareZoneTopology(t *testing.T) {
+	zoneA := &csi.Topology{Segments: map[string]string{topologyKey: "zone-a"}}
+	zoneB := &csi.Topology{Segments: map[string]string{topologyKey: "zone-b", shareNetworkTopologyKey("sn-1"): "true"}}
+	topologies := []*csi.Topology{zoneA, zoneB}
+
+	tcs := []struct {
+		name     string
+		zone     string
+		expected []*csi.Topology
+	}{
+		{
+			name:     "restricted to the zone of the share",
+			zone:     "zone-b",
+			expected: []*csi.Topology{zoneB},
+		},
+		{
+			name:     "zone of the share not requested",
+			zone:     "nova",
+			expected: topologies,
+		},
+		{
+			name:     "no zone",
+			expected: topologies,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			res := withShareZoneTopology(topologies, tc.zone)
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Errorf("expected topologies %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
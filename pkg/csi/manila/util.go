@@ -28,6 +28,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	clouderrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 	"k8s.io/klog/v2"
 )
 
@@ -48,11 +49,14 @@ func (c manilaError) toRPCErrorCode() codes.Code {
 	case manilaErrUnexpectedNetwork:
 		return codes.InvalidArgument
 	case manilaErrAvailability:
-		return codes.ResourceExhausted
+		// The requested availability zone doesn't exist or has no backend able to host the share.
+		return codes.InvalidArgument
 	case manilaErrCapabilities:
 		return codes.InvalidArgument
 	case manilaErrCapacity:
 		return codes.OutOfRange
+	case manilaErrInvalidRequest:
+		return codes.InvalidArgument
 	default:
 		return codes.Internal
 	}
@@ -64,6 +68,10 @@ const (
 	manilaErrAvailability
 	manilaErrCapabilities
 	manilaErrCapacity
+
+	// manilaErrInvalidRequest is not a Manila user message code. It's used for requests rejected
+	// right away by the Manila API, e.g. because of an unknown share type or share protocol.
+	manilaErrInvalidRequest
 )
 
 var (
@@ -82,6 +90,18 @@ type manilaErrorMessage struct {
 	message string
 }
 
+// classifyCreateShareError returns manilaErrInvalidRequest if Manila refused to create the share
+// because of the request itself, so that retrying the same request is pointless.
+func classifyCreateShareError(err error) manilaError {
+	// Manila responds with 404 Not Found to references to non-existent resources,
+	// e.g. share types, availability zones or share networks.
+	if clouderrors.IsInvalidError(err) || clouderrors.IsNotFound(err) {
+		return manilaErrInvalidRequest
+	}
+
+	return 0
+}
+
 func parseGRPCEndpoint(endpoint string) (proto, addr string, err error) {
 	const (
 		unixScheme = "unix://"
@@ -149,10 +169,6 @@ func lastResourceError(manilaClient manilaclient.Interface, resourceID string) (
 	return manilaErrorMessage{message: "unknown error"}, nil
 }
 
-func compareProtocol(protoA, protoB string) bool {
-	return strings.EqualFold(protoA, protoB)
-}
-
 //
 // Controller service request validation
 //
@@ -261,6 +277,27 @@ func verifyVolumeCompatibility(sizeInGiB int, req *csi.CreateVolumeRequest, shar
 		return fmt.Errorf("source snapshot ID mismatch: wanted %s, got %s", coalesceValue(share.SnapshotID), coalesceValue(reqSrcSnapID))
 	}
 
+	if keyRef := share.Metadata[encryptionKeyRefKey]; keyRef != shareOpts.EncryptionKeyRef {
+		return fmt.Errorf("encryption key mismatch: wanted %s, got %s", coalesceValue(shareOpts.EncryptionKeyRef), coalesceValue(keyRef))
+	}
+
+	return nil
+}
+
+// verifyAdoptedVolumeCompatibility checks whether a share adopted from another project can be used for the volume.
+func verifyAdoptedVolumeCompatibility(sizeInGiB int, share *shares.Share, shareOpts *options.ControllerVolumeContext) error {
+	if share.Size < sizeInGiB {
+		return fmt.Errorf("share is too small: wanted at least %d, got %d", sizeInGiB, share.Size)
+	}
+
+	if share.ShareProto != shareOpts.Protocol {
+		return fmt.Errorf("share protocol mismatch: wanted %s, got %s", coalesceValue(shareOpts.Protocol), coalesceValue(share.ShareProto))
+	}
+
+	if share.ShareNetworkID != shareOpts.ShareNetworkID {
+		return fmt.Errorf("share network ID mismatch: wanted %s, got %s", coalesceValue(shareOpts.ShareNetworkID), coalesceValue(share.ShareNetworkID))
+	}
+
 	return nil
 }
 
@@ -371,3 +408,15 @@ func validateNodeUnpublishVolumeRequest(req *csi.NodeUnpublishVolumeRequest) err
 
 	return nil
 }
+
+func validateNodeGetVolumeStatsRequest(req *csi.NodeGetVolumeStatsRequest) error {
+	if req.GetVolumeId() == "" {
+		return errors.New("volume ID missing in request")
+	}
+
+	if req.GetVolumePath() == "" {
+		return errors.New("volume path missing in request")
+	}
+
+	return nil
+}
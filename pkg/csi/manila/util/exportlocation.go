@@ -15,6 +15,7 @@ package util
 
 import (
 	"errors"
+	"regexp"
 	"strings"
 
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
@@ -29,6 +30,36 @@ type ExportLocationPredicate func(index int) (match bool, err error)
 // Predicate matching any export location
 func AnyExportLocation(int) (bool, error) { return true, nil }
 
+// ExportLocationPolicy ranks the suitable export locations of a share when choosing the one to mount.
+// The zero value prefers the export locations marked as preferred by Manila.
+type ExportLocationPolicy struct {
+	// PathPattern prefers the export locations with a matching path.
+	PathPattern *regexp.Regexp
+	// Zone prefers the export locations of the share instances in this availability zone,
+	// looked up in LocationZones by share instance ID.
+	Zone          string
+	LocationZones map[string]string
+	// AllowAdminOnly also considers the admin-only export locations.
+	AllowAdminOnly bool
+}
+
+// rank returns the preference of an export location, the higher the better.
+// A matching path prevails over the availability zone, which prevails over Manila's preferred flag.
+func (p *ExportLocationPolicy) rank(loc *shares.ExportLocation) int {
+	rank := 0
+	if p.PathPattern != nil && p.PathPattern.MatchString(loc.Path) {
+		rank += 4
+	}
+	if p.Zone != "" && p.LocationZones[loc.ShareInstanceID] == p.Zone {
+		rank += 2
+	}
+	if loc.Preferred {
+		rank++
+	}
+
+	return rank
+}
+
 // Searches for an export location.
 // Returns index of an export location from the `locs` slice that satisfies following rules:
 // 1. Location is not admin-only and is not empty
@@ -37,32 +68,36 @@ func AnyExportLocation(int) (bool, error) { return true, nil }
 // 1. Location.Preferred == true is preferred over Location.Preferred == false
 // 2. Locations with lower index are preferred over those with higher index
 func FindExportLocation(locs []shares.ExportLocation, pred ExportLocationPredicate) (index int, err error) {
+	return FindExportLocationWithPolicy(locs, pred, &ExportLocationPolicy{})
+}
+
+// FindExportLocationWithPolicy searches for an export location like FindExportLocation,
+// the matching locations being ranked by the policy. Locations with lower index are preferred
+// among the ones with the same rank. A nil policy is the zero policy.
+func FindExportLocationWithPolicy(locs []shares.ExportLocation, pred ExportLocationPredicate, policy *ExportLocationPolicy) (index int, err error) {
 	const invalidIdx = -1
-	firstMatchNotPreferred := invalidIdx
+	if policy == nil {
+		policy = &ExportLocationPolicy{}
+	}
+	bestIdx, bestRank := invalidIdx, -1
 
 	for i := range locs {
-		if locs[i].IsAdminOnly || strings.TrimSpace(locs[i].Path) == "" {
+		if (locs[i].IsAdminOnly && !policy.AllowAdminOnly) || strings.TrimSpace(locs[i].Path) == "" {
 			continue
 		}
 
 		if hasMatch, err := pred(i); err != nil {
 			return i, err
 		} else if hasMatch {
-			if locs[i].Preferred {
-				// This export location is a match and it is Preferred.
-				// Can't get better than that!
-				return i, nil
-			}
-
-			if firstMatchNotPreferred == invalidIdx {
-				firstMatchNotPreferred = i
+			if rank := policy.rank(&locs[i]); rank > bestRank {
+				bestIdx, bestRank = i, rank
 			}
 		}
 	}
 
-	if firstMatchNotPreferred == invalidIdx {
+	if bestIdx == invalidIdx {
 		err = errors.New("no match, or no suitable non-admin export locations available")
 	}
 
-	return firstMatchNotPreferred, err
+	return bestIdx, err
 }
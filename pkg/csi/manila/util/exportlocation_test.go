@@ -14,6 +14,7 @@ limitations under the License.
 package util
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
@@ -125,3 +126,68 @@ func TestFindExportLocationAny(t *testing.T) {
 		}
 	}
 }
+
+// Tests FindExportLocationWithPolicy with AnyExportLocation predicate
+func TestFindExportLocationWithPolicy(t *testing.T) {
+	locs := []shares.ExportLocation{
+		{
+			Path:            "10.0.0.1:/admin",
+			IsAdminOnly:     true,
+			ShareInstanceID: "instance-a",
+		},
+		{
+			Path:            "10.0.0.2:/share",
+			Preferred:       true,
+			ShareInstanceID: "instance-a",
+		},
+		{
+			Path:            "10.0.1.2:/share",
+			ShareInstanceID: "instance-b",
+		},
+		{
+			Path:            "10.0.2.2:/share",
+			ShareInstanceID: "instance-b",
+		},
+	}
+	zones := map[string]string{"instance-a": "zone-a", "instance-b": "zone-b"}
+
+	ts := []struct {
+		policy           *ExportLocationPolicy
+		expectedMatchIdx int
+	}{
+		{
+			// Manila's preferred location by default
+			policy:           nil,
+			expectedMatchIdx: 1,
+		},
+		{
+			policy:           &ExportLocationPolicy{Zone: "zone-b", LocationZones: zones},
+			expectedMatchIdx: 2,
+		},
+		{
+			// The path prevails over the availability zone
+			policy:           &ExportLocationPolicy{PathPattern: regexp.MustCompile(`^10\.0\.2\.`), Zone: "zone-a", LocationZones: zones},
+			expectedMatchIdx: 3,
+		},
+		{
+			// The admin-only locations are excluded unless allowed
+			policy:           &ExportLocationPolicy{PathPattern: regexp.MustCompile("admin")},
+			expectedMatchIdx: 1,
+		},
+		{
+			policy:           &ExportLocationPolicy{PathPattern: regexp.MustCompile("admin"), AllowAdminOnly: true},
+			expectedMatchIdx: 0,
+		},
+	}
+
+	for i := range ts {
+		result, err := FindExportLocationWithPolicy(locs, AnyExportLocation, ts[i].policy)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+
+		if result != ts[i].expectedMatchIdx {
+			t.Errorf("test %d: returned an incorrect index: got %d, expected %d", i, result, ts[i].expectedMatchIdx)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClassifyCreateShareError(t *testing.T) {
+	ts := []struct {
+		err          error
+		expectedCode codes.Code
+	}{
+		{
+			// Unknown share protocol
+			err:          gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusBadRequest},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			// Non-existent share type
+			err:          fmt.Errorf("wrapped: %w", gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusNotFound}),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			err:          gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusServiceUnavailable},
+			expectedCode: codes.Internal,
+		},
+		{
+			err:          errors.New("connection refused"),
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for i, tc := range ts {
+		code := classifyCreateShareError(tc.err).toRPCErrorCode()
+		if code != tc.expectedCode {
+			t.Errorf("test case %d: expected %s, got %s", i, tc.expectedCode, code)
+		}
+	}
+}
+
+func TestManilaErrorToRPCErrorCode(t *testing.T) {
+	ts := []struct {
+		detailID     string
+		expectedCode codes.Code
+	}{
+		{detailID: "002", expectedCode: codes.OutOfRange},
+		{detailID: "003", expectedCode: codes.InvalidArgument},
+		{detailID: "007", expectedCode: codes.InvalidArgument},
+		{detailID: "008", expectedCode: codes.InvalidArgument},
+		{detailID: "009", expectedCode: codes.OutOfRange},
+		{detailID: "001", expectedCode: codes.Internal},
+	}
+
+	for _, tc := range ts {
+		code := manilaErrorCodesMap[tc.detailID].toRPCErrorCode()
+		if code != tc.expectedCode {
+			t.Errorf("detail ID %s: expected %s, got %s", tc.detailID, tc.expectedCode, code)
+		}
+	}
+}
@@ -17,13 +17,18 @@ limitations under the License.
 package manila
 
 import (
+	"strings"
+
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetransfers"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
 	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
 	clouderrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	"k8s.io/klog/v2"
 )
 
 type volumeCreator interface {
@@ -56,7 +61,15 @@ func create(manilaClient manilaclient.Interface, shareName string, sizeInGiB int
 		}
 	}
 
-	share, manilaErrCode, err := getOrCreateShare(manilaClient, shareName, createOpts)
+	var createOptsBuilder shares.CreateOptsBuilder = createOpts
+	if shareOpts.EncryptionKeyRef != "" {
+		v := manilaClient.GetMicroversion()
+		manilaClient.SetMicroversion(shareEncryptionMicroversion)
+		defer manilaClient.SetMicroversion(v)
+		createOptsBuilder = encryptedShareCreateOpts{CreateOpts: createOpts, EncryptionKeyRef: shareOpts.EncryptionKeyRef}
+	}
+
+	share, manilaErrCode, err := getOrCreateShare(manilaClient, shareName, createOptsBuilder)
 	if err != nil {
 		if wait.Interrupted(err) {
 			return nil, status.Errorf(codes.DeadlineExceeded, "deadline exceeded while waiting for volume %s to become available", shareName)
@@ -108,5 +121,117 @@ func (v volumeFromSnapshot) create(manilaClient manilaclient.Interface, shareNam
 		return nil, status.Errorf(codes.FailedPrecondition, "snapshot %s is in invalid state: expected 'available', got '%s'", snapshot.ID, snapshot.Status)
 	}
 
+	if err := verifySnapshotSource(snapshot, sizeInGiB, shareOpts); err != nil {
+		return nil, err
+	}
+
 	return create(manilaClient, shareName, sizeInGiB, shareOpts, shareMetadata, snapshot.ID)
 }
+
+// verifySnapshotSource checks that a share of the requested size and protocol can be created from the snapshot.
+func verifySnapshotSource(snapshot *snapshots.Snapshot, sizeInGiB int, shareOpts *options.ControllerVolumeContext) error {
+	if snapshot.ShareProto != "" && !strings.EqualFold(snapshot.ShareProto, shareOpts.Protocol) {
+		return status.Errorf(codes.InvalidArgument, "snapshot %s is a snapshot of a %s share, cannot restore it into a %s volume", snapshot.ID, snapshot.ShareProto, shareOpts.Protocol)
+	}
+
+	// Manila cannot create a share smaller than the snapshot it is created from
+	if snapshotSize := max(snapshot.Size, snapshot.ShareSize); sizeInGiB < snapshotSize {
+		return status.Errorf(codes.OutOfRange, "requested size %dGiB is smaller than the size %dGiB of snapshot %s", sizeInGiB, snapshotSize, snapshot.ID)
+	}
+
+	return nil
+}
+
+// volumeFromTransfer adopts a share transferred from another project. The share is renamed after the volume so that
+// the retries of CreateVolume find it once the transfer is accepted.
+type volumeFromTransfer struct {
+	shareID    string
+	transferID string
+	authKey    string
+}
+
+func (v volumeFromTransfer) create(manilaClient manilaclient.Interface, shareName string, sizeInGiB int, shareOpts *options.ControllerVolumeContext, shareMetadata map[string]string) (*shares.Share, error) {
+	if share, err := manilaClient.GetShareByName(shareName); err == nil {
+		klog.V(4).Infof("volume %s already adopted from share %s", shareName, share.ID)
+		return share, nil
+	} else if !clouderrors.IsNotFound(err) {
+		return nil, status.Errorf(codes.Internal, "failed to retrieve volume %s: %v", shareName, err)
+	}
+
+	if err := v.accept(manilaClient, shareName); err != nil {
+		return nil, err
+	}
+
+	share, manilaErrCode, err := waitForShareStatus(manilaClient, v.shareID, []string{shareAwaitingTransfer}, shareAvailable, false)
+	if err != nil {
+		if wait.Interrupted(err) {
+			return nil, status.Errorf(codes.DeadlineExceeded, "deadline exceeded while waiting for share %s to become available", v.shareID)
+		}
+		return nil, status.Errorf(manilaErrCode.toRPCErrorCode(), "failed to adopt share %s: %v", v.shareID, err)
+	}
+
+	description := shareDescription
+	share, err = manilaClient.UpdateShare(share.ID, shares.UpdateOpts{DisplayName: &shareName, DisplayDescription: &description})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to rename share %s to %s: %v", v.shareID, shareName, err)
+	}
+
+	if len(shareMetadata) > 0 {
+		if _, err := manilaClient.SetShareMetadata(share.ID, shares.SetMetadataOpts{Metadata: shareMetadata}); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to set the metadata of share %s: %v", share.ID, err)
+		}
+	}
+
+	return share, nil
+}
+
+// accept accepts the transfer of the share, unless a previous attempt did. The shares of the project are only adopted
+// when they are known to come from the transfer, either read from the transfer before accepting it or marked with the
+// transfer ID by the attempt which accepted it, so that a volume cannot take over any share of the project.
+func (v volumeFromTransfer) accept(manilaClient manilaclient.Interface, shareName string) error {
+	// Share transfers are available since Manila microversion 2.77
+	mv := manilaClient.GetMicroversion()
+	manilaClient.SetMicroversion("2.77")
+	defer manilaClient.SetMicroversion(mv)
+
+	share, err := manilaClient.GetShareByID(v.shareID)
+	switch {
+	case err == nil && share.Metadata[transferIDKey] == v.transferID:
+		klog.V(4).Infof("transfer %s of share %s already accepted for volume %s", v.transferID, v.shareID, shareName)
+		return nil
+	case err == nil:
+		// The share is visible before the transfer is accepted, e.g. with admin credentials or when it already belongs
+		// to the project: the transfer must be readable and transfer this share.
+		transfer, err := manilaClient.GetShareTransfer(v.transferID)
+		if err != nil {
+			return status.Errorf(codes.FailedPrecondition, "share %s is not known to be transferred by transfer %s: %v", v.shareID, v.transferID, err)
+		}
+		if transfer.ResourceID != v.shareID {
+			return status.Errorf(codes.InvalidArgument, "transfer %s transfers share %s, not share %s", v.transferID, transfer.ResourceID, v.shareID)
+		}
+	case !clouderrors.IsNotFound(err):
+		return status.Errorf(codes.Internal, "failed to retrieve share %s: %v", v.shareID, err)
+	}
+
+	// The access rules granted in the source project are cleared, the driver grants its own below.
+	if err := manilaClient.AcceptShareTransfer(v.transferID, sharetransfers.AcceptOpts{AuthKey: v.authKey, ClearAccessRules: true}); err != nil {
+		if clouderrors.IsNotFound(err) {
+			return status.Errorf(codes.NotFound, "transfer %s of share %s not found", v.transferID, v.shareID)
+		}
+		return status.Errorf(codes.Internal, "failed to accept transfer %s of share %s: %v", v.transferID, v.shareID, err)
+	}
+	klog.V(4).Infof("accepted transfer %s of share %s for volume %s", v.transferID, v.shareID, shareName)
+
+	// The share must be in the project now, an invisible share was not moved by the transfer
+	if _, err := manilaClient.GetShareByID(v.shareID); err != nil {
+		return status.Errorf(codes.FailedPrecondition, "share %s not found after accepting transfer %s, which transfers another share: %v", v.shareID, v.transferID, err)
+	}
+
+	// The retries of CreateVolume recognize the share by the transfer ID until it is renamed
+	marker := shares.SetMetadataOpts{Metadata: map[string]string{transferIDKey: v.transferID}}
+	if _, err := manilaClient.SetShareMetadata(v.shareID, marker); err != nil {
+		return status.Errorf(codes.Internal, "failed to mark share %s as accepted from transfer %s: %v", v.shareID, v.transferID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetransfers"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+)
+
+// transferManilaClient serves a single share transferred from another project.
+type transferManilaClient struct {
+	manilaclient.Interface
+
+	share   *shares.Share
+	authKey string
+	// transferredShareID is the share the transfer transfers, the share by default.
+	transferredShareID string
+	// visible shares are in the project, or readable with admin credentials, before the transfer is accepted.
+	visible  bool
+	accepted bool
+	metadata map[string]string
+}
+
+func (c *transferManilaClient) GetMicroversion() string { return "2.37" }
+
+func (c *transferManilaClient) SetMicroversion(string) {}
+
+func (c *transferManilaClient) transferred() string {
+	if c.transferredShareID != "" {
+		return c.transferredShareID
+	}
+	return c.share.ID
+}
+
+func (c *transferManilaClient) GetShareByID(shareID string) (*shares.Share, error) {
+	inProject := c.visible || (c.accepted && c.transferred() == c.share.ID)
+	if !inProject || shareID != c.share.ID {
+		return nil, gophercloud.ErrResourceNotFound{}
+	}
+	return c.share, nil
+}
+
+func (c *transferManilaClient) GetShareByName(shareName string) (*shares.Share, error) {
+	if !c.accepted || shareName != c.share.Name {
+		return nil, gophercloud.ErrResourceNotFound{}
+	}
+	return c.share, nil
+}
+
+func (c *transferManilaClient) GetShareTransfer(transferID string) (*sharetransfers.Transfer, error) {
+	if c.accepted || transferID != "transfer-id" {
+		return nil, gophercloud.ErrResourceNotFound{}
+	}
+	return &sharetransfers.Transfer{ID: transferID, ResourceID: c.transferred()}, nil
+}
+
+func (c *transferManilaClient) AcceptShareTransfer(transferID string, opts sharetransfers.AcceptOpts) error {
+	if c.accepted || transferID != "transfer-id" {
+		return gophercloud.ErrResourceNotFound{}
+	}
+	if opts.AuthKey != c.authKey {
+		return gophercloud.ErrUnexpectedResponseCode{Actual: 400}
+	}
+	c.accepted = true
+	return nil
+}
+
+func (c *transferManilaClient) UpdateShare(shareID string, opts shares.UpdateOptsBuilder) (*shares.Share, error) {
+	updateOpts := opts.(shares.UpdateOpts)
+	c.share.Name = *updateOpts.DisplayName
+	c.share.Description = *updateOpts.DisplayDescription
+	return c.share, nil
+}
+
+func (c *transferManilaClient) SetShareMetadata(shareID string, opts shares.SetMetadataOptsBuilder) (map[string]string, error) {
+	if c.share.Metadata == nil {
+		c.share.Metadata = make(map[string]string)
+	}
+	for k, v := range opts.(shares.SetMetadataOpts).Metadata {
+		c.share.Metadata[k] = v
+	}
+	c.metadata = c.share.Metadata
+	return c.metadata, nil
+}
+
+func TestVolumeFromTransfer(t *testing.T) {
+	client := &transferManilaClient{
+		share:   &shares.Share{ID: "share-id", Name: "old-name", Status: shareAvailable},
+		authKey: "auth-key",
+	}
+	metadata := map[string]string{clusterMetadataKey: "cluster"}
+
+	if _, err := (volumeFromTransfer{shareID: "share-id", transferID: "transfer-id", authKey: "wrong"}).create(client, "pvc-name", 1, &options.ControllerVolumeContext{}, metadata); err == nil {
+		t.Fatal("expected an error with an invalid auth key")
+	}
+
+	v := volumeFromTransfer{shareID: "share-id", transferID: "transfer-id", authKey: "auth-key"}
+	share, err := v.create(client, "pvc-name", 1, &options.ControllerVolumeContext{}, metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if share.ID != "share-id" || share.Name != "pvc-name" || share.Description != shareDescription {
+		t.Errorf("share not adopted: %#v", share)
+	}
+	if client.metadata[clusterMetadataKey] != "cluster" || client.metadata[transferIDKey] != "transfer-id" {
+		t.Errorf("share metadata not set: %v", client.metadata)
+	}
+
+	// The retries find the adopted share, the transfer is gone
+	share, err = v.create(client, "pvc-name", 1, &options.ControllerVolumeContext{}, metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if share.ID != "share-id" {
+		t.Errorf("returned an incorrect share: %#v", share)
+	}
+}
+
+func TestVolumeFromTransferAcceptedBeforeRename(t *testing.T) {
+	// A previous attempt accepted the transfer and marked the share, but did not rename it
+	client := &transferManilaClient{
+		share:    &shares.Share{ID: "share-id", Name: "old-name", Status: shareAvailable, Metadata: map[string]string{transferIDKey: "transfer-id"}},
+		accepted: true,
+	}
+
+	v := volumeFromTransfer{shareID: "share-id", transferID: "transfer-id", authKey: "auth-key"}
+	share, err := v.create(client, "pvc-name", 1, &options.ControllerVolumeContext{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if share.Name != "pvc-name" {
+		t.Errorf("share not adopted: %#v", share)
+	}
+}
+
+func TestVolumeFromTransferTakeover(t *testing.T) {
+	ts := []struct {
+		name   string
+		client *transferManilaClient
+	}{
+		{
+			// The share is in the project, the transfer is gone
+			name: "share of the project",
+			client: &transferManilaClient{
+				share:    &shares.Share{ID: "share-id", Name: "other-volume", Status: shareAvailable},
+				visible:  true,
+				accepted: true,
+			},
+		},
+		{
+			// The share is in the project, the transfer is a transfer of another share of the author of the PVC
+			name: "transfer of another share",
+			client: &transferManilaClient{
+				share:              &shares.Share{ID: "share-id", Name: "other-volume", Status: shareAvailable},
+				authKey:            "auth-key",
+				transferredShareID: "own-share-id",
+				visible:            true,
+			},
+		},
+		{
+			// The share is in another project, the transfer is a transfer of another share
+			name: "invisible share",
+			client: &transferManilaClient{
+				share:              &shares.Share{ID: "share-id", Name: "other-volume", Status: shareAvailable},
+				authKey:            "auth-key",
+				transferredShareID: "own-share-id",
+			},
+		},
+	}
+
+	for _, tt := range ts {
+		v := volumeFromTransfer{shareID: "share-id", transferID: "transfer-id", authKey: "auth-key"}
+		if _, err := v.create(tt.client, "pvc-name", 1, &options.ControllerVolumeContext{}, nil); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if tt.client.share.Name != "other-volume" {
+			t.Errorf("%s: share taken over: %#v", tt.name, tt.client.share)
+		}
+	}
+}
+
+func TestVerifySnapshotSource(t *testing.T) {
+	snapshot := &snapshots.Snapshot{ID: "snapshot-id", ShareProto: "NFS", Size: 2, ShareSize: 2}
+
+	ts := []struct {
+		name         string
+		sizeInGiB    int
+		protocol     string
+		expectedCode codes.Code
+	}{
+		{name: "same size", sizeInGiB: 2, protocol: "NFS", expectedCode: codes.OK},
+		{name: "larger size", sizeInGiB: 5, protocol: "nfs", expectedCode: codes.OK},
+		{name: "smaller size", sizeInGiB: 1, protocol: "NFS", expectedCode: codes.OutOfRange},
+		{name: "protocol mismatch", sizeInGiB: 2, protocol: "CEPHFS", expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range ts {
+		err := verifySnapshotSource(snapshot, tt.sizeInGiB, &options.ControllerVolumeContext{Protocol: tt.protocol})
+		if code := status.Code(err); code != tt.expectedCode {
+			t.Errorf("%s: returned an incorrect code: got %v, expected %v (%v)", tt.name, code, tt.expectedCode, err)
+		}
+	}
+}
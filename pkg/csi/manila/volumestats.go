@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+	utilpath "k8s.io/utils/path"
+)
+
+const (
+	// kubeletPodsDir is the directory of kubelet the volumes are published in, the plugin container needs it mounted
+	// with the HostToContainer propagation to collect their stats.
+	kubeletPodsDir = "/var/lib/kubelet/pods"
+	// volumeStatsTimeout bounds the stats collection, statfs blocks as long as the NFS server of a share is unreachable.
+	volumeStatsTimeout = 30 * time.Second
+)
+
+// kubeletPodsDirMounted checks whether the pods directory of kubelet is mounted in the plugin container.
+func kubeletPodsDirMounted() bool {
+	exists, _ := utilpath.Exists(utilpath.CheckFollowSymlink, kubeletPodsDir)
+	return exists
+}
+
+// CephFS virtual extended attributes of the directories, see https://docs.ceph.com/en/latest/cephfs/quota/
+const (
+	cephfsQuotaMaxBytes = "ceph.quota.max_bytes"
+	cephfsQuotaMaxFiles = "ceph.quota.max_files"
+	cephfsDirRbytes     = "ceph.dir.rbytes"
+	cephfsDirRentries   = "ceph.dir.rentries"
+)
+
+// shareStats are the capacity and the inode usage of a mounted share.
+type shareStats struct {
+	totalBytes      int64
+	availableBytes  int64
+	usedBytes       int64
+	totalInodes     int64
+	availableInodes int64
+	usedInodes      int64
+}
+
+func (s *shareStats) toVolumeUsage() []*csi.VolumeUsage {
+	return []*csi.VolumeUsage{
+		{Total: s.totalBytes, Available: s.availableBytes, Used: s.usedBytes, Unit: csi.VolumeUsage_BYTES},
+		{Total: s.totalInodes, Available: s.availableInodes, Used: s.usedInodes, Unit: csi.VolumeUsage_INODES},
+	}
+}
+
+// shareStatsCall is a stats collection in progress, its result is set once done is closed.
+type shareStatsCall struct {
+	done  chan struct{}
+	stats *shareStats
+	err   error
+}
+
+// collectShareStats returns the stats of the share mounted at path, or an error wrapping context.DeadlineExceeded if
+// they aren't collected within volumeStatsTimeout. The call blocked in the kernel is left behind, it returns once the
+// share is reachable again. Only one call runs per path, the requests received in the meantime wait for its result
+// rather than blocking another thread in statfs.
+func (ns *nodeServer) collectShareStats(ctx context.Context, shareProto, path string) (*shareStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, volumeStatsTimeout)
+	defer cancel()
+
+	ns.shareStatsCallsMtx.Lock()
+	call, found := ns.shareStatsCalls[path]
+	if !found {
+		call = &shareStatsCall{done: make(chan struct{})}
+		ns.shareStatsCalls[path] = call
+		go func() {
+			call.stats, call.err = ns.shareStats(shareProto, path)
+			ns.shareStatsCallsMtx.Lock()
+			delete(ns.shareStatsCalls, path)
+			ns.shareStatsCallsMtx.Unlock()
+			close(call.done)
+		}()
+	}
+	ns.shareStatsCallsMtx.Unlock()
+
+	select {
+	case <-call.done:
+		return call.stats, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// getShareStats returns the stats of the share mounted at path. The CephFS shares are sized with the quotas of their
+// subvolume, whose usage is read from the CephFS extended attributes.
+func getShareStats(shareProto, path string) (*shareStats, error) {
+	stats, err := getStatfsStats(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if shareProto == "CEPHFS" {
+		return applyCephfsQuota(stats, func(name string) (int64, bool, error) { return getCephfsXattr(path, name) })
+	}
+
+	return stats, nil
+}
+
+// getStatfsStats returns the stats of the filesystem mounted at path, e.g. the export of an NFS share.
+func getStatfsStats(path string) (*shareStats, error) {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(path, &statfs); err != nil {
+		return nil, err
+	}
+
+	return &shareStats{
+		totalBytes:      int64(statfs.Blocks) * int64(statfs.Bsize),
+		availableBytes:  int64(statfs.Bavail) * int64(statfs.Bsize),
+		usedBytes:       (int64(statfs.Blocks) - int64(statfs.Bfree)) * int64(statfs.Bsize),
+		totalInodes:     int64(statfs.Files),
+		availableInodes: int64(statfs.Ffree),
+		usedInodes:      int64(statfs.Files) - int64(statfs.Ffree),
+	}, nil
+}
+
+// applyCephfsQuota overrides the filesystem stats with the quotas of the CephFS directory and its recursive usage. The
+// stats of the filesystem are kept for the limits without quota, the whole filesystem is then available.
+func applyCephfsQuota(stats *shareStats, xattr func(name string) (int64, bool, error)) (*shareStats, error) {
+	maxBytes, _, err := xattr(cephfsQuotaMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 {
+		usedBytes, _, err := xattr(cephfsDirRbytes)
+		if err != nil {
+			return nil, err
+		}
+		stats.totalBytes = maxBytes
+		stats.usedBytes = usedBytes
+		stats.availableBytes = max(maxBytes-usedBytes, 0)
+	}
+
+	usedInodes, ok, err := xattr(cephfsDirRentries)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return stats, nil
+	}
+	stats.usedInodes = usedInodes
+
+	maxFiles, _, err := xattr(cephfsQuotaMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	if maxFiles > 0 {
+		stats.totalInodes = maxFiles
+		stats.availableInodes = max(maxFiles-usedInodes, 0)
+	}
+
+	return stats, nil
+}
+
+// getCephfsXattr returns the integer value of a CephFS extended attribute of path, false if it isn't set.
+func getCephfsXattr(path, name string) (int64, bool, error) {
+	buf := make([]byte, 32)
+	n, err := unix.Getxattr(path, name, buf)
+	if errors.Is(err, unix.ENODATA) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get extended attribute %s of %s: %v", name, path, err)
+	}
+
+	value, err := strconv.ParseInt(strings.TrimSpace(string(buf[:n])), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse extended attribute %s of %s: %v", name, path, err)
+	}
+
+	return value, true, nil
+}
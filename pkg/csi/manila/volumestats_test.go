@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestApplyCephfsQuota(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+
+	fsStats := shareStats{
+		totalBytes:      100 * gib,
+		availableBytes:  60 * gib,
+		usedBytes:       40 * gib,
+		totalInodes:     1000,
+		availableInodes: 0,
+		usedInodes:      1000,
+	}
+
+	ts := []struct {
+		xattrs   map[string]int64
+		expected shareStats
+	}{
+		{
+			// No quota, no recursive stats
+			xattrs:   map[string]int64{},
+			expected: fsStats,
+		},
+		{
+			xattrs: map[string]int64{
+				cephfsQuotaMaxBytes: 10 * gib,
+				cephfsDirRbytes:     4 * gib,
+				cephfsDirRentries:   12,
+			},
+			expected: shareStats{
+				totalBytes:      10 * gib,
+				availableBytes:  6 * gib,
+				usedBytes:       4 * gib,
+				totalInodes:     1000,
+				availableInodes: 0,
+				usedInodes:      12,
+			},
+		},
+		{
+			// Over quota
+			xattrs: map[string]int64{
+				cephfsQuotaMaxBytes: 10 * gib,
+				cephfsDirRbytes:     11 * gib,
+				cephfsQuotaMaxFiles: 100,
+				cephfsDirRentries:   12,
+			},
+			expected: shareStats{
+				totalBytes:      10 * gib,
+				availableBytes:  0,
+				usedBytes:       11 * gib,
+				totalInodes:     100,
+				availableInodes: 88,
+				usedInodes:      12,
+			},
+		},
+	}
+
+	for i, tc := range ts {
+		stats := fsStats
+		res, err := applyCephfsQuota(&stats, func(name string) (int64, bool, error) {
+			v, ok := tc.xattrs[name]
+			return v, ok, nil
+		})
+		if err != nil {
+			t.Errorf("test case %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		if *res != tc.expected {
+			t.Errorf("test case %d: expected %+v, got %+v", i, tc.expected, *res)
+		}
+	}
+
+	stats := fsStats
+	if _, err := applyCephfsQuota(&stats, func(string) (int64, bool, error) { return 0, false, errors.New("transport endpoint is not connected") }); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGetShareStats(t *testing.T) {
+	stats, err := getShareStats("NFS", t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats.totalBytes <= 0 || stats.availableBytes > stats.totalBytes || stats.usedBytes > stats.totalBytes {
+		t.Errorf("unexpected stats %+v", *stats)
+	}
+
+	if _, err := getShareStats("NFS", "/nonexistent"); err == nil {
+		t.Error("expected an error for a nonexistent path")
+	}
+}
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	stats := &shareStats{totalBytes: 100, availableBytes: 60, usedBytes: 40, totalInodes: 10, availableInodes: 8, usedInodes: 2}
+	release := make(chan struct{})
+	var hungCalls atomic.Int32
+
+	ns := &nodeServer{
+		d: &Driver{fwdEndpoints: map[string]string{"NFS": "unix:///nfs.sock"}},
+		shareStats: func(shareProto, path string) (*shareStats, error) {
+			switch path {
+			case "/hung":
+				hungCalls.Add(1)
+				<-release
+				return nil, errors.New("released")
+			case "/gone":
+				return getStatfsStats("/nonexistent")
+			}
+			return stats, nil
+		},
+		shareStatsCalls: make(map[string]*shareStatsCall),
+	}
+
+	res, err := ns.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/mounted"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Usage) != 2 || res.Usage[0].Total != 100 || res.Usage[1].Used != 2 {
+		t.Errorf("unexpected usage %v", res.Usage)
+	}
+
+	// The proxied driver doesn't report the stats
+	_, err = ns.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/gone"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	// The requests received while the stats of a path are collected wait for the call in progress
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		_, err = ns.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/hung"})
+		cancel()
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	}
+	if n := hungCalls.Load(); n != 1 {
+		t.Errorf("expected 1 stats call, got %d", n)
+	}
+
+	close(release)
+	_, err = ns.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/hung"})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected Internal, got %v", err)
+	}
+
+	_, err = ns.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
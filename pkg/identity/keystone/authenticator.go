@@ -19,12 +19,17 @@ package keystone
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/groups"
 	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
 	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/users"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
 )
 
 type tokenInfo struct {
@@ -35,11 +40,30 @@ type tokenInfo struct {
 	projectID   string
 	domainName  string
 	domainID    string
+	expiresAt   time.Time
+	// identityProvider, protocol and groupIDs are only set for the users federated through an identity provider.
+	identityProvider string
+	protocol         string
+	groupIDs         []string
+}
+
+// federationInfo is the OS-FEDERATION attribute of the user of a federated token.
+type federationInfo struct {
+	IdentityProvider struct {
+		ID string `json:"id"`
+	} `json:"identity_provider"`
+	Protocol struct {
+		ID string `json:"id"`
+	} `json:"protocol"`
+	Groups []struct {
+		ID string `json:"id"`
+	} `json:"groups"`
 }
 
 type IKeystone interface {
-	GetTokenInfo(string) (*tokenInfo, error)
-	GetGroups(string, string) ([]string, error)
+	GetTokenInfo(context.Context, string) (*tokenInfo, error)
+	GetGroups(context.Context, string, string) ([]string, error)
+	GetGroupNames(context.Context, string, []string) ([]string, error)
 }
 
 type Keystoner struct {
@@ -53,13 +77,17 @@ func NewKeystoner(client *gophercloud.ServiceClient) *Keystoner {
 }
 
 // revive:disable:unexported-return
-func (k *Keystoner) GetTokenInfo(token string) (*tokenInfo, error) {
+func (k *Keystoner) GetTokenInfo(ctx context.Context, token string) (*tokenInfo, error) {
+	ctx, span := startSpan(ctx, "Keystone.GetTokenInfo")
+	defer span.End()
+
 	k.client.ProviderClient.SetToken(token)
-	ret := tokens.Get(context.TODO(), k.client, token)
+	ret := tokens.Get(ctx, k.client, token)
 
 	tokenUser, err := ret.ExtractUser()
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract user information from Keystone response: %v", err)
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("failed to extract user information from Keystone response: %w", err)
 	}
 
 	project, err := ret.ExtractProject()
@@ -72,12 +100,26 @@ func (k *Keystoner) GetTokenInfo(token string) (*tokenInfo, error) {
 		return nil, fmt.Errorf("failed to extract roles information from Keystone response: %v", err)
 	}
 
+	t, err := ret.ExtractToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract token information from Keystone response: %v", err)
+	}
+
+	var federated struct {
+		User struct {
+			Federation *federationInfo `json:"OS-FEDERATION"`
+		} `json:"user"`
+	}
+	if err := ret.ExtractInto(&federated); err != nil {
+		return nil, fmt.Errorf("failed to extract federation information from Keystone response: %v", err)
+	}
+
 	userRoles := make([]string, 0, len(roles))
 	for _, role := range roles {
 		userRoles = append(userRoles, role.Name)
 	}
 
-	return &tokenInfo{
+	info := &tokenInfo{
 		userName:    tokenUser.Name,
 		userID:      tokenUser.ID,
 		projectName: project.Name,
@@ -85,16 +127,30 @@ func (k *Keystoner) GetTokenInfo(token string) (*tokenInfo, error) {
 		roles:       userRoles,
 		domainID:    tokenUser.Domain.ID,
 		domainName:  tokenUser.Domain.Name,
-	}, nil
+		expiresAt:   t.ExpiresAt,
+	}
+	if f := federated.User.Federation; f != nil {
+		info.identityProvider = f.IdentityProvider.ID
+		info.protocol = f.Protocol.ID
+		for _, g := range f.Groups {
+			info.groupIDs = append(info.groupIDs, g.ID)
+		}
+	}
+
+	return info, nil
 }
 
 // revive:enable:unexported-return
 
-func (k *Keystoner) GetGroups(token string, userID string) ([]string, error) {
+func (k *Keystoner) GetGroups(ctx context.Context, token string, userID string) ([]string, error) {
+	ctx, span := startSpan(ctx, "Keystone.GetGroups")
+	defer span.End()
+
 	k.client.ProviderClient.SetToken(token)
-	allGroupPages, err := users.ListGroups(k.client, userID).AllPages(context.TODO())
+	allGroupPages, err := users.ListGroups(k.client, userID).AllPages(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user groups from Keystone: %v", err)
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("failed to get user groups from Keystone: %w", err)
 	}
 
 	allGroups, err := groups.ExtractGroups(allGroupPages)
@@ -110,19 +166,99 @@ func (k *Keystoner) GetGroups(token string, userID string) ([]string, error) {
 	return userGroups, nil
 }
 
+// GetGroupNames resolves the names of the groups a federated token is mapped to, the token only contains their IDs.
+// The ID is used when the group is not visible to the user.
+func (k *Keystoner) GetGroupNames(ctx context.Context, token string, groupIDs []string) ([]string, error) {
+	ctx, span := startSpan(ctx, "Keystone.GetGroupNames")
+	defer span.End()
+
+	k.client.ProviderClient.SetToken(token)
+	names := make([]string, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		g, err := groups.Get(ctx, k.client, id).Extract()
+		if err != nil {
+			if gophercloud.ResponseCodeIs(err, http.StatusForbidden) || gophercloud.ResponseCodeIs(err, http.StatusNotFound) {
+				names = append(names, id)
+				continue
+			}
+			recordSpanError(span, err)
+			return nil, fmt.Errorf("failed to get group %s from Keystone: %w", id, err)
+		}
+		names = append(names, g.Name)
+	}
+
+	return names, nil
+}
+
 // Authenticator contacts openstack keystone to validate user's token passed in the request.
 type Authenticator struct {
 	keystoner IKeystone
+	// cache is nil when token caching is disabled.
+	cache *tokenCache
+	// federationGroupPrefixes maps the identity providers to the prefix of the Kubernetes groups of their federated
+	// users, "<identity provider>:" is used for the identity providers not in the map.
+	federationGroupPrefixes map[string]string
+}
+
+// federatedGroups returns the Kubernetes groups of a federated user.
+func (a *Authenticator) federatedGroups(ctx context.Context, token string, info *tokenInfo) ([]string, error) {
+	if len(info.groupIDs) == 0 {
+		return nil, nil
+	}
+
+	names, err := a.keystoner.GetGroupNames(ctx, token, info.groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ok := a.federationGroupPrefixes[info.identityProvider]
+	if !ok {
+		prefix = info.identityProvider + ":"
+	}
+	groups := make([]string, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, prefix+name)
+	}
+	return groups, nil
+}
+
+// revalidateToken validates a cached token with Keystone again, the token is removed from the cache when Keystone
+// rejects it.
+func (a *Authenticator) revalidateToken(ctx context.Context, token string) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRevalidateTimeout)
+	defer cancel()
+
+	_, err := a.keystoner.GetTokenInfo(ctx, token)
+	if err != nil {
+		klog.V(4).Infof("Failed to validate a cached token again: %v", err)
+	}
+	a.cache.finishRevalidation(token, err)
 }
 
 // AuthenticateToken checks the token via Keystone call
-func (a *Authenticator) AuthenticateToken(token string) (user.Info, bool, error) {
-	tokenInfo, err := a.keystoner.GetTokenInfo(token)
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (user.Info, bool, error) {
+	span := trace.SpanFromContext(ctx)
+	if a.cache != nil {
+		if cachedUser, ok := a.cache.get(token); ok {
+			span.SetAttributes(attribute.Bool(attrTokenCacheHit, true))
+			if a.cache.startRevalidation(token) {
+				go a.revalidateToken(context.WithoutCancel(ctx), token)
+			}
+			return cachedUser, true, nil
+		}
+	}
+
+	start := time.Now()
+	defer func() {
+		span.SetAttributes(attribute.Int64(attrKeystoneLatency, time.Since(start).Milliseconds()))
+	}()
+
+	tokenInfo, err := a.keystoner.GetTokenInfo(ctx, token)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to authenticate: %v", err)
 	}
 
-	userGroups, err := a.keystoner.GetGroups(token, tokenInfo.userID)
+	userGroups, err := a.keystoner.GetGroups(ctx, token, tokenInfo.userID)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to authenticate: %v", err)
 	}
@@ -135,6 +271,16 @@ func (a *Authenticator) AuthenticateToken(token string) (user.Info, bool, error)
 		DomainName:  {tokenInfo.domainName},
 	}
 
+	if tokenInfo.identityProvider != "" {
+		federatedGroups, err := a.federatedGroups(ctx, token, tokenInfo)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to authenticate: %v", err)
+		}
+		userGroups = append(userGroups, federatedGroups...)
+		extra[IdentityProvider] = []string{tokenInfo.identityProvider}
+		extra[Protocol] = []string{tokenInfo.protocol}
+	}
+
 	userGroups = append(userGroups, tokenInfo.projectID)
 	authenticatedUser := &user.DefaultInfo{
 		Name:   tokenInfo.userName,
@@ -143,5 +289,9 @@ func (a *Authenticator) AuthenticateToken(token string) (user.Info, bool, error)
 		Extra:  extra,
 	}
 
+	if a.cache != nil {
+		a.cache.add(token, authenticatedUser, tokenInfo.expiresAt)
+	}
+
 	return authenticatedUser, true, nil
 }
@@ -14,16 +14,27 @@ limitations under the License.
 package keystone
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gophercloud/gophercloud/v2"
 	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/mock"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
 func TestAuthenticateToken(t *testing.T) {
 	keystone := &MockIKeystone{}
 	keystone.
-		On("GetTokenInfo", "token").
+		On("GetTokenInfo", mock.Anything, "token").
 		Return(&tokenInfo{
 			userName:    "user-name",
 			userID:      "user-id",
@@ -35,14 +46,14 @@ func TestAuthenticateToken(t *testing.T) {
 		}, nil).
 		Once()
 	keystone.
-		On("GetGroups", "token", "user-id").
+		On("GetGroups", mock.Anything, "token", "user-id").
 		Return([]string{"group1", "group2"}, nil).
 		Once()
 
 	a := &Authenticator{
 		keystoner: keystone,
 	}
-	userInfo, allowed, err := a.AuthenticateToken("token")
+	userInfo, allowed, err := a.AuthenticateToken(context.TODO(), "token")
 
 	th.AssertNoErr(t, err)
 	th.AssertEquals(t, true, allowed)
@@ -63,3 +74,306 @@ func TestAuthenticateToken(t *testing.T) {
 
 	keystone.AssertExpectations(t)
 }
+
+func TestAuthenticateFederatedToken(t *testing.T) {
+	keystone := &MockIKeystone{}
+	keystone.
+		On("GetTokenInfo", mock.Anything, "token").
+		Return(&tokenInfo{
+			userName:         "user-name",
+			userID:           "user-id",
+			projectID:        "project-id",
+			projectName:      "project-name",
+			domainName:       "Federated",
+			domainID:         "federated-domain-id",
+			identityProvider: "sso",
+			protocol:         "openid",
+			roles:            []string{"member"},
+			groupIDs:         []string{"group-id1", "group-id2"},
+		}, nil).
+		Twice()
+	keystone.
+		On("GetGroups", mock.Anything, "token", "user-id").
+		Return([]string{}, nil).
+		Twice()
+	keystone.
+		On("GetGroupNames", mock.Anything, "token", []string{"group-id1", "group-id2"}).
+		Return([]string{"admins", "group-id2"}, nil).
+		Twice()
+
+	a := &Authenticator{keystoner: keystone}
+	userInfo, allowed, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, allowed)
+
+	expectedUserInfo := &user.DefaultInfo{
+		Name:   "user-name",
+		UID:    "user-id",
+		Groups: []string{"sso:admins", "sso:group-id2", "project-id"},
+		Extra: map[string][]string{
+			Roles:            {"member"},
+			ProjectID:        {"project-id"},
+			ProjectName:      {"project-name"},
+			DomainID:         {"federated-domain-id"},
+			DomainName:       {"Federated"},
+			IdentityProvider: {"sso"},
+			Protocol:         {"openid"},
+		},
+	}
+	th.AssertDeepEquals(t, expectedUserInfo, userInfo)
+
+	// The identity provider has a configured prefix.
+	a.federationGroupPrefixes = map[string]string{"sso": "oidc-"}
+	userInfo, _, err = a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{"oidc-admins", "oidc-group-id2", "project-id"}, userInfo.GetGroups())
+
+	keystone.AssertExpectations(t)
+}
+
+func TestKeystonerFederatedToken(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestHeader(t, r, "X-Subject-Token", "token")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"token": {
+			"expires_at": "2015-11-09T01:42:57.527363Z",
+			"user": {
+				"domain": {"id": "federated-domain-id", "name": "Federated"},
+				"id": "user-id",
+				"name": "user-name",
+				"OS-FEDERATION": {
+					"identity_provider": {"id": "sso"},
+					"protocol": {"id": "openid"},
+					"groups": [{"id": "group-id1"}, {"id": "group-id2"}]
+				}
+			},
+			"project": {"id": "project-id", "name": "project-name"},
+			"roles": [{"id": "role-id", "name": "member"}]
+		}}`)
+	})
+	th.Mux.HandleFunc("/groups/group-id1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"group": {"id": "group-id1", "name": "admins"}}`)
+	})
+	th.Mux.HandleFunc("/groups/group-id2", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	k := NewKeystoner(fakeclient.ServiceClient())
+	info, err := k.GetTokenInfo(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "sso", info.identityProvider)
+	th.AssertEquals(t, "openid", info.protocol)
+	th.AssertDeepEquals(t, []string{"group-id1", "group-id2"}, info.groupIDs)
+	th.AssertDeepEquals(t, []string{"member"}, info.roles)
+
+	names, err := k.GetGroupNames(context.TODO(), "token", info.groupIDs)
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{"admins", "group-id2"}, names)
+}
+
+func TestAuthenticateTokenCache(t *testing.T) {
+	keystone := &MockIKeystone{}
+	keystone.
+		On("GetTokenInfo", mock.Anything, "token").
+		Return(&tokenInfo{
+			userName:  "user-name",
+			userID:    "user-id",
+			projectID: "project-id",
+			roles:     []string{"role1"},
+		}, nil).
+		Twice()
+	keystone.
+		On("GetGroups", mock.Anything, "token", "user-id").
+		Return([]string{"group1"}, nil).
+		Twice()
+
+	now := time.Now()
+	cache := newTokenCache(time.Minute, 10)
+	cache.now = func() time.Time { return now }
+	a := &Authenticator{
+		keystoner: keystone,
+		cache:     cache,
+	}
+
+	first, allowed, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, allowed)
+
+	// Served from the cache, modifying the returned user doesn't affect the cache.
+	second, allowed, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, allowed)
+	th.AssertDeepEquals(t, first, second)
+	second.(*user.DefaultInfo).Groups[0] = "modified"
+
+	third, _, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, first, third)
+
+	// The entry expired, Keystone is called again.
+	now = now.Add(2 * time.Minute)
+	_, allowed, err = a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, allowed)
+
+	keystone.AssertExpectations(t)
+}
+
+func TestAuthenticateTokenCacheRevalidation(t *testing.T) {
+	info := &tokenInfo{userName: "user-name", userID: "user-id"}
+	keystone := &MockIKeystone{}
+	keystone.On("GetTokenInfo", mock.Anything, "token").Return(info, nil).Once()
+	keystone.On("GetGroups", mock.Anything, "token", "user-id").Return([]string{"group1"}, nil).Once()
+
+	now := time.Now()
+	cache := newTokenCache(time.Hour, 10)
+	cache.now = func() time.Time { return now }
+	a := &Authenticator{keystoner: keystone, cache: cache}
+
+	_, _, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	// Validated recently, the token isn't validated again
+	th.AssertEquals(t, false, cache.startRevalidation("token"))
+
+	// Validated again in the background, at most once at a time
+	now = now.Add(tokenCacheRevalidateInterval)
+	validated := make(chan struct{})
+	keystone.On("GetTokenInfo", mock.Anything, "token").Return(info, nil).Run(func(mock.Arguments) { <-validated }).Once()
+	_, allowed, err := a.AuthenticateToken(context.TODO(), "token")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, allowed)
+	th.AssertEquals(t, false, cache.startRevalidation("token"))
+	close(validated)
+	th.AssertNoErr(t, wait.PollUntilContextTimeout(context.TODO(), time.Millisecond, time.Second, true, func(context.Context) (bool, error) {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		value, _ := cache.cache.Get(tokenCacheKey("token"))
+		return value.(*tokenCacheEntry).validatedAt.Equal(now), nil
+	}))
+
+	// Keystone is unavailable, the token is kept and validated again
+	now = now.Add(tokenCacheRevalidateInterval)
+	th.AssertEquals(t, true, cache.startRevalidation("token"))
+	cache.finishRevalidation("token", gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusServiceUnavailable})
+	_, ok := cache.get("token")
+	th.AssertEquals(t, true, ok)
+	th.AssertEquals(t, true, cache.startRevalidation("token"))
+
+	// The token is revoked, it's removed from the cache
+	cache.finishRevalidation("token", fmt.Errorf("failed to extract user information from Keystone response: %w",
+		gophercloud.ErrUnexpectedResponseCode{Actual: http.StatusNotFound}))
+	_, ok = cache.get("token")
+	th.AssertEquals(t, false, ok)
+
+	keystone.AssertExpectations(t)
+}
+
+func TestAuthenticateTokenTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, webhookSpan := tp.Tracer("test").Start(context.TODO(), webhookSpanName)
+
+	// The Keystone calls are traced as children of the webhook span.
+	keystoneCall := func(args mock.Arguments) {
+		_, span := startSpan(args.Get(0).(context.Context), "Keystone.Call")
+		span.End()
+	}
+	keystone := &MockIKeystone{}
+	keystone.
+		On("GetTokenInfo", mock.Anything, "token").
+		Run(keystoneCall).
+		Return(&tokenInfo{userName: "user-name", userID: "user-id"}, nil).
+		Once()
+	keystone.
+		On("GetGroups", mock.Anything, "token", "user-id").
+		Run(keystoneCall).
+		Return([]string{"group1"}, nil).
+		Once()
+
+	a := &Authenticator{
+		keystoner: keystone,
+		cache:     newTokenCache(time.Minute, 10),
+	}
+	_, _, err := a.AuthenticateToken(ctx, "token")
+	th.AssertNoErr(t, err)
+	webhookSpan.End()
+
+	spans := recorder.Ended()
+	th.AssertEquals(t, 3, len(spans))
+	for _, span := range spans[:2] {
+		th.AssertEquals(t, "Keystone.Call", span.Name())
+		th.AssertEquals(t, webhookSpan.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+
+	var latency, cacheHit bool
+	for _, attr := range spans[2].Attributes() {
+		latency = latency || attr.Key == attrKeystoneLatency
+		cacheHit = cacheHit || attr.Key == attrTokenCacheHit
+	}
+	th.AssertEquals(t, true, latency)
+	th.AssertEquals(t, false, cacheHit)
+
+	keystone.AssertExpectations(t)
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &requestIDTransport{base: http.DefaultTransport}}
+
+	get := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		th.AssertNoErr(t, err)
+		resp, err := client.Do(req)
+		th.AssertNoErr(t, err)
+		resp.Body.Close()
+	}
+
+	// The request ID of the webhook request is sent to Keystone, as the global request ID when it's a UUID
+	get(withRequestID(context.TODO(), "0b8f5c1e-4f3a-4d6e-9a8b-2c7d1e0f3a4b"))
+	th.AssertEquals(t, "0b8f5c1e-4f3a-4d6e-9a8b-2c7d1e0f3a4b", headers.Get("X-Request-Id"))
+	th.AssertEquals(t, "req-0b8f5c1e-4f3a-4d6e-9a8b-2c7d1e0f3a4b", headers.Get("X-OpenStack-Request-ID"))
+
+	get(withRequestID(context.TODO(), "audit-id"))
+	th.AssertEquals(t, "audit-id", headers.Get("X-Request-Id"))
+	th.AssertEquals(t, "", headers.Get("X-OpenStack-Request-ID"))
+
+	get(context.TODO())
+	th.AssertEquals(t, "", headers.Get("X-Request-Id"))
+}
+
+func TestTokenCacheExpiration(t *testing.T) {
+	now := time.Now()
+	cache := newTokenCache(time.Hour, 10)
+	cache.now = func() time.Time { return now }
+
+	// The token expires before the TTL.
+	cache.add("token", &user.DefaultInfo{Name: "user-name"}, now.Add(time.Minute))
+	_, ok := cache.get("token")
+	th.AssertEquals(t, true, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = cache.get("token")
+	th.AssertEquals(t, false, ok)
+}
+
+func TestTokenCacheSize(t *testing.T) {
+	cache := newTokenCache(time.Hour, 1)
+
+	cache.add("token1", &user.DefaultInfo{Name: "user1"}, time.Time{})
+	cache.add("token2", &user.DefaultInfo{Name: "user2"}, time.Time{})
+
+	_, ok := cache.get("token1")
+	th.AssertEquals(t, false, ok)
+	info, ok := cache.get("token2")
+	th.AssertEquals(t, true, ok)
+	th.AssertEquals(t, "user2", info.Name)
+}
@@ -28,6 +28,7 @@ import (
 	"k8s.io/klog/v2"
 
 	"k8s.io/apiserver/pkg/authorization/authorizer"
+	corelisters "k8s.io/client-go/listers/core/v1"
 )
 
 // Authorizer contacts openstack keystone to check whether the user can perform
@@ -37,7 +38,12 @@ type Authorizer struct {
 	authURL string
 	client  *gophercloud.ServiceClient
 	pl      policyList
-	mu      sync.Mutex
+	// chain are the authorizers evaluated before the Keystone policy
+	chain []chainedAuthorizer
+	// namespaces is set when the namespaces are watched, i.e. when the initial policy has namespace-label-deny
+	// authorizers
+	namespaces corelisters.NamespaceLister
+	mu         sync.Mutex
 }
 
 func findString(a string, list []string) bool {
@@ -98,11 +104,12 @@ func getAllowed(definition string, str string) (sets.Set[string], error) {
 	return allowed, nil
 }
 
-func resourcePermissionAllowed(permissionSpec map[string][]string, attr authorizer.Attributes) bool {
+func resourcePermissionAllowed(permissionSpec map[string][]string, strictSubresources bool, attr authorizer.Attributes) bool {
 	ns := attr.GetNamespace()
 	res := attr.GetResource()
+	subres := attr.GetSubresource()
 	verb := attr.GetVerb()
-	klog.V(4).Infof("Request namespace: %s, resource: %s, verb: %s", ns, res, verb)
+	klog.V(4).Infof("Request namespace: %s, resource: %s, subresource: %s, verb: %s", ns, res, subres, verb)
 
 	for key, value := range permissionSpec {
 		klog.V(4).Infof("Evaluating %s: %s", key, value)
@@ -115,8 +122,9 @@ func resourcePermissionAllowed(permissionSpec map[string][]string, attr authoriz
 			allowedVerbs.Insert(verb)
 		}
 
+		// The key is either "namespace/resource" or "namespace/resource/subresource".
 		keyList := strings.Split(key, "/")
-		if len(keyList) != 2 {
+		if len(keyList) != 2 && len(keyList) != 3 {
 			// Ignore this spec
 			klog.V(4).Infof("Skip the permission definition %s", key)
 			continue
@@ -139,9 +147,26 @@ func resourcePermissionAllowed(permissionSpec map[string][]string, attr authoriz
 			continue
 		}
 
+		// With strict subresources, a subresource request (e.g. pods/exec) is only allowed by a definition naming the
+		// subresource, or by a definition matching all the resources, so that "pods" does not grant "pods/exec".
+		subresAllowed := true
+		if len(keyList) == 3 {
+			subresDef := strings.ToLower(strings.TrimSpace(keyList[2]))
+			if subres == "" {
+				continue
+			}
+			allowedSubresources, err := getAllowed(subresDef, subres)
+			if err != nil {
+				continue
+			}
+			subresAllowed = allowedSubresources.Has(subres)
+		} else if strictSubresources && subres != "" && resDef != "*" {
+			subresAllowed = false
+		}
+
 		klog.V(4).Infof("allowedNamespaces: %s, allowedResources: %s, allowedVerbs: %s", allowedNamespaces, allowedResources, allowedVerbs)
 
-		if allowedNamespaces.Has(ns) && allowedResources.Has(res) && allowedVerbs.Has(verb) {
+		if allowedNamespaces.Has(ns) && allowedResources.Has(res) && subresAllowed && allowedVerbs.Has(verb) {
 			return true
 		}
 	}
@@ -149,6 +174,15 @@ func resourcePermissionAllowed(permissionSpec map[string][]string, attr authoriz
 	return false
 }
 
+// nonResourcePathMatches checks whether the path matches the definition, "*" matches all the paths and a trailing "*"
+// matches all the subpaths, e.g. "/logs/*" matches "/logs/kube-apiserver.log".
+func nonResourcePathMatches(definition string, path string) bool {
+	if definition == "*" || definition == path {
+		return true
+	}
+	return strings.HasSuffix(definition, "*") && strings.HasPrefix(path, strings.TrimRight(definition, "*"))
+}
+
 func nonResourcePermissionAllowed(permissionSpec map[string][]string, attr authorizer.Attributes) bool {
 	path := attr.GetPath()
 	verb := attr.GetVerb()
@@ -156,13 +190,13 @@ func nonResourcePermissionAllowed(permissionSpec map[string][]string, attr autho
 	for key, value := range permissionSpec {
 		allowedVerbs := sets.NewString()
 		for _, val := range value {
-			allowedVerbs.Insert(val)
+			allowedVerbs.Insert(strings.ToLower(val))
 		}
 		if allowedVerbs.Has("*") {
 			allowedVerbs.Insert(verb)
 		}
 
-		if key == path && allowedVerbs.Has(verb) {
+		if nonResourcePathMatches(strings.TrimSpace(key), path) && allowedVerbs.Has(verb) {
 			return true
 		}
 	}
@@ -217,10 +251,7 @@ func nonResourceMatches(p policy, a authorizer.Attributes) bool {
 	if !findString("*", p.NonResourceSpec.Verbs) && !findString(a.GetVerb(), p.NonResourceSpec.Verbs) {
 		return false
 	}
-	if *p.NonResourceSpec.NonResourcePath != "*" && *p.NonResourceSpec.NonResourcePath != a.GetPath() &&
-		// Allow a trailing * subpath match
-		!(strings.HasSuffix(*p.NonResourceSpec.NonResourcePath, "*") &&
-			strings.HasPrefix(a.GetPath(), strings.TrimRight(*p.NonResourceSpec.NonResourcePath, "*"))) {
+	if !nonResourcePathMatches(*p.NonResourceSpec.NonResourcePath, a.GetPath()) {
 		return false
 	}
 	allowed := match(p.Match, a)
@@ -310,11 +341,51 @@ func match(match []policyMatch, attributes authorizer.Attributes) bool {
 	return true
 }
 
-// Authorize checks whether the user can perform an operation
+// Authorize checks whether the user can perform an operation. The authorizers of the chain are evaluated first, the
+// first one denying the request prevails over the Keystone policy.
 func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized authorizer.Decision, reason string, err error) {
+	authorized, reason, _, err = a.authorize(attributes)
+	return authorized, reason, err
+}
+
+// authorize is Authorize, it also returns whether the request is denied by an authorizer of the chain, in which case
+// the other authorizers of the API server must not allow it either.
+func (a *Authorizer) authorize(attributes authorizer.Attributes) (authorized authorizer.Decision, reason string, denied bool, err error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	for _, c := range a.chain {
+		authorized, reason, err = c.Authorize(attributes)
+		if err != nil || authorized != authorizer.DecisionNoOpinion {
+			return authorized, reason, err == nil && authorized == authorizer.DecisionDeny, err
+		}
+	}
+
+	_, authorized, reason = a.pl.authorize(attributes)
+	return authorized, reason, false, nil
+}
+
+// setPolicy replaces the Keystone policy and the authorizers chained before it. Nothing is allowed when the
+// authorizers are invalid, so that a broken change freeze doesn't let the changes through.
+func (a *Authorizer) setPolicy(cfg *policyConfig) error {
+	chain, err := newChainedAuthorizers(cfg.Authorizers, a.namespaces)
+	if err == nil && cfg.requiresNamespaces() && a.namespaces == nil {
+		klog.Warningf("The namespaces aren't watched, the namespace-label-deny authorizers deny the requests in all the namespaces until the webhook is restarted")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.pl, a.chain = nil, nil
+		return err
+	}
+	a.pl, a.chain = cfg.Policies, chain
+	return nil
+}
+
+// authorize evaluates the policies for the request, it returns the index of the policy allowing the request or -1
+// if the request is denied.
+func (pl policyList) authorize(attributes authorizer.Attributes) (int, authorizer.Decision, string) {
 	// Get roles and projects from the request.
 	user := attributes.GetUser()
 	userRoles := sets.NewString()
@@ -326,7 +397,7 @@ func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized aut
 
 	// When the user.Extra does not exist, it means that the keystone user authentication has failed, and the authorization verification should not pass.
 	if user.GetExtra() == nil {
-		return authorizer.DecisionDeny, "No auth info found.", nil
+		return -1, authorizer.DecisionDeny, "No auth info found."
 	}
 
 	// We support both project name and project ID.
@@ -346,7 +417,7 @@ func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized aut
 
 	// The permission is whitelist. Make sure we go through all the policies that match the user roles and projects. If
 	// the operation is allowed explicitly, stop the loop and return "allowed".
-	for _, p := range a.pl {
+	for i, p := range pl {
 		policyRoles := sets.NewString()
 		policyProjects := sets.NewString()
 
@@ -372,27 +443,27 @@ func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized aut
 		// ResourcePermissionsSpec and NonResourcePermissionsSpec take precedence over ResourceSpec and NonResourceSpec
 		if attributes.IsResourceRequest() {
 			if p.ResourcePermissionsSpec != nil {
-				if resourcePermissionAllowed(p.ResourcePermissionsSpec, attributes) {
-					return authorizer.DecisionAllow, "", nil
+				if resourcePermissionAllowed(p.ResourcePermissionsSpec, p.StrictSubresources, attributes) {
+					return i, authorizer.DecisionAllow, ""
 				}
 			} else if p.ResourceSpec != nil {
 				if resourceMatches(*p, attributes) {
-					return authorizer.DecisionAllow, "", nil
+					return i, authorizer.DecisionAllow, ""
 				}
 			}
 		} else {
 			if p.NonResourcePermissionsSpec != nil {
 				if nonResourcePermissionAllowed(p.NonResourcePermissionsSpec, attributes) {
-					return authorizer.DecisionAllow, "", nil
+					return i, authorizer.DecisionAllow, ""
 				}
 			} else if p.NonResourceSpec != nil {
 				if nonResourceMatches(*p, attributes) {
-					return authorizer.DecisionAllow, "", nil
+					return i, authorizer.DecisionAllow, ""
 				}
 			}
 		}
 	}
 
 	klog.V(4).Infof("Authorization failed, user: %#v, attributes: %#v\n", attributes.GetUser(), attributes)
-	return authorizer.DecisionDeny, "No policy matched.", nil
+	return -1, authorizer.DecisionDeny, "No policy matched."
 }
@@ -0,0 +1,259 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Types of the authorizers chained before the Keystone policy.
+const (
+	AuthorizerNamespaceLabelDeny = "namespace-label-deny"
+	AuthorizerTimeWindow         = "time-window"
+)
+
+// chainedAuthorizer is an authorizer of the chain evaluated before the Keystone policy. It denies the request, or has
+// no opinion and leaves the decision to the next authorizers.
+type chainedAuthorizer interface {
+	Authorize(attributes authorizer.Attributes) (authorizer.Decision, string, error)
+}
+
+// authorizerSpec configures an authorizer of the chain in the policy file.
+type authorizerSpec struct {
+	Type string `json:"type"`
+
+	// Verbs are the verbs of the requests the authorizer applies to, all the verbs when empty.
+	Verbs []string `json:"verbs,omitempty"`
+	// Roles are the Keystone roles of the users the authorizer applies to, all the users when empty.
+	Roles []string `json:"roles,omitempty"`
+	// ExemptRoles are the Keystone roles of the users the authorizer never applies to.
+	ExemptRoles []string `json:"exempt_roles,omitempty"`
+
+	// NamespaceSelector is the label selector of the namespaces the namespace-label-deny authorizer denies the
+	// requests in.
+	NamespaceSelector string `json:"namespace_selector,omitempty"`
+
+	// Windows are the time windows the time-window authorizer allows the requests in.
+	Windows []timeWindowSpec `json:"windows,omitempty"`
+	// TimeZone is the IANA time zone of the windows, UTC when empty.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+type timeWindowSpec struct {
+	// Days are the week days of the window, e.g. "Mon", every day when empty.
+	Days []string `json:"days,omitempty"`
+	// Start is the time of the day the window starts at, e.g. "08:00".
+	Start string `json:"start"`
+	// End is the time of the day the window ends at, excluded, "24:00" for the end of the day.
+	End string `json:"end"`
+}
+
+// policyConfig is the content of the policy file, either the list of the Keystone policies or an object with the
+// policies and the authorizers chained before them.
+type policyConfig struct {
+	Policies    policyList       `json:"policies"`
+	Authorizers []authorizerSpec `json:"authorizers,omitempty"`
+}
+
+func (c *policyConfig) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		c.Authorizers = nil
+		return json.Unmarshal(trimmed, &c.Policies)
+	}
+
+	type plain policyConfig
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// requiresNamespaces checks whether the authorizers need the labels of the namespaces.
+func (c *policyConfig) requiresNamespaces() bool {
+	for _, spec := range c.Authorizers {
+		if spec.Type == AuthorizerNamespaceLabelDeny {
+			return true
+		}
+	}
+	return false
+}
+
+// newChainedAuthorizers creates the authorizers configured in the policy file. The namespace lister is nil when the
+// webhook has no Kubernetes client.
+func newChainedAuthorizers(specs []authorizerSpec, namespaces corelisters.NamespaceLister) ([]chainedAuthorizer, error) {
+	var chain []chainedAuthorizer
+	for i, spec := range specs {
+		scope := authorizerScope{
+			verbs:       sets.New(spec.Verbs...),
+			roles:       sets.New(spec.Roles...),
+			exemptRoles: sets.New(spec.ExemptRoles...),
+		}
+
+		switch spec.Type {
+		case AuthorizerNamespaceLabelDeny:
+			selector, err := labels.Parse(spec.NamespaceSelector)
+			if err != nil || selector.Empty() {
+				return nil, fmt.Errorf("authorizer %d: invalid namespace_selector %q: %v", i, spec.NamespaceSelector, err)
+			}
+			chain = append(chain, &namespaceLabelDenyAuthorizer{authorizerScope: scope, selector: selector, namespaces: namespaces})
+		case AuthorizerTimeWindow:
+			a, err := newTimeWindowAuthorizer(scope, spec)
+			if err != nil {
+				return nil, fmt.Errorf("authorizer %d: %v", i, err)
+			}
+			chain = append(chain, a)
+		default:
+			return nil, fmt.Errorf("authorizer %d: unknown type %q", i, spec.Type)
+		}
+	}
+	return chain, nil
+}
+
+// authorizerScope selects the requests an authorizer of the chain applies to.
+type authorizerScope struct {
+	verbs       sets.Set[string]
+	roles       sets.Set[string]
+	exemptRoles sets.Set[string]
+}
+
+func (s authorizerScope) applies(attributes authorizer.Attributes) bool {
+	if s.verbs.Len() > 0 && !s.verbs.Has(attributes.GetVerb()) {
+		return false
+	}
+
+	var userRoles []string
+	if user := attributes.GetUser(); user != nil {
+		userRoles = user.GetExtra()[Roles]
+	}
+	if s.exemptRoles.HasAny(userRoles...) {
+		return false
+	}
+	return s.roles.Len() == 0 || s.roles.HasAny(userRoles...)
+}
+
+// namespaceLabelDenyAuthorizer denies the requests in the namespaces matching a label selector, e.g. to freeze them.
+type namespaceLabelDenyAuthorizer struct {
+	authorizerScope
+	selector   labels.Selector
+	namespaces corelisters.NamespaceLister
+}
+
+func (a *namespaceLabelDenyAuthorizer) Authorize(attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	namespace := attributes.GetNamespace()
+	if !attributes.IsResourceRequest() || namespace == "" || !a.applies(attributes) {
+		return authorizer.DecisionNoOpinion, "", nil
+	}
+
+	if a.namespaces == nil {
+		// The namespaces are only watched when the initial policy has namespace-label-deny authorizers
+		return authorizer.DecisionDeny, fmt.Sprintf("The labels of namespace %s are unknown, the webhook doesn't watch the namespaces.", namespace), nil
+	}
+	ns, err := a.namespaces.Get(namespace)
+	if err != nil {
+		// The request is denied by the Keystone policy or by the API server if the namespace doesn't exist
+		klog.V(4).Infof("Failed to get namespace %s: %v", namespace, err)
+		return authorizer.DecisionNoOpinion, "", nil
+	}
+	if a.selector.Matches(labels.Set(ns.Labels)) {
+		return authorizer.DecisionDeny, fmt.Sprintf("Namespace %s is frozen by the labels %s.", namespace, a.selector), nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+// timeWindowAuthorizer denies the requests outside of time windows, e.g. to restrict the changes to the business
+// hours.
+type timeWindowAuthorizer struct {
+	authorizerScope
+	windows  []timeWindow
+	location *time.Location
+	now      func() time.Time
+}
+
+type timeWindow struct {
+	days sets.Set[time.Weekday]
+	// start and end are the minutes of the day
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func newTimeWindowAuthorizer(scope authorizerScope, spec authorizerSpec) (*timeWindowAuthorizer, error) {
+	if len(spec.Windows) == 0 {
+		return nil, fmt.Errorf("no windows")
+	}
+	location, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time_zone %q: %v", spec.TimeZone, err)
+	}
+
+	a := &timeWindowAuthorizer{authorizerScope: scope, location: location, now: time.Now}
+	for _, w := range spec.Windows {
+		window := timeWindow{days: sets.New[time.Weekday]()}
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q", day)
+			}
+			window.days.Insert(weekday)
+		}
+		if window.start, err = parseMinuteOfDay(w.Start); err != nil {
+			return nil, err
+		}
+		if window.end, err = parseMinuteOfDay(w.End); err != nil {
+			return nil, err
+		}
+		if window.start >= window.end {
+			return nil, fmt.Errorf("window %s-%s ends before it starts", w.Start, w.End)
+		}
+		a.windows = append(a.windows, window)
+	}
+	return a, nil
+}
+
+// parseMinuteOfDay parses a time of the day formatted as HH:MM, up to 24:00.
+func parseMinuteOfDay(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
+
+func (a *timeWindowAuthorizer) Authorize(attributes authorizer.Attributes) (authorizer.Decision, string, error) {
+	if !a.applies(attributes) {
+		return authorizer.DecisionNoOpinion, "", nil
+	}
+
+	now := a.now().In(a.location)
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range a.windows {
+		if (w.days.Len() == 0 || w.days.Has(now.Weekday())) && minute >= w.start && minute < w.end {
+			return authorizer.DecisionNoOpinion, "", nil
+		}
+	}
+	return authorizer.DecisionDeny, fmt.Sprintf("The request is outside of the allowed time windows (%s).", a.location), nil
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const chainTestPolicy = `{
+  "policies": [
+    {
+      "users": {"roles": ["developer"], "projects": ["demo"]},
+      "resource_permissions": {"*/*": ["*"]}
+    }
+  ],
+  "authorizers": [
+    {
+      "type": "namespace-label-deny",
+      "namespace_selector": "freeze=true",
+      "verbs": ["create", "update", "patch", "delete"],
+      "exempt_roles": ["admin"]
+    },
+    {
+      "type": "time-window",
+      "verbs": ["delete"],
+      "windows": [{"days": ["Mon", "Tue", "Wed", "Thu", "Fri"], "start": "08:00", "end": "18:00"}],
+      "time_zone": "Europe/Paris"
+    }
+  ]
+}`
+
+func newTestNamespaceLister(t *testing.T, namespaces ...*corev1.Namespace) corelisters.NamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		th.AssertNoErr(t, indexer.Add(ns))
+	}
+	return corelisters.NewNamespaceLister(indexer)
+}
+
+func TestPolicyConfigUnmarshal(t *testing.T) {
+	// The legacy policy file is the list of the Keystone policies
+	cfg := &policyConfig{}
+	th.AssertNoErr(t, json.Unmarshal([]byte(`[{"users": {"roles": ["developer"]}, "resource_permissions": {"*/*": ["get"]}}]`), cfg))
+	th.AssertEquals(t, 1, len(cfg.Policies))
+	th.AssertEquals(t, 0, len(cfg.Authorizers))
+	th.AssertEquals(t, false, cfg.requiresNamespaces())
+
+	cfg = &policyConfig{}
+	th.AssertNoErr(t, json.Unmarshal([]byte(chainTestPolicy), cfg))
+	th.AssertEquals(t, 1, len(cfg.Policies))
+	th.AssertEquals(t, 2, len(cfg.Authorizers))
+	th.AssertEquals(t, AuthorizerNamespaceLabelDeny, cfg.Authorizers[0].Type)
+	th.AssertEquals(t, "freeze=true", cfg.Authorizers[0].NamespaceSelector)
+	th.AssertEquals(t, "Europe/Paris", cfg.Authorizers[1].TimeZone)
+	th.AssertEquals(t, "18:00", cfg.Authorizers[1].Windows[0].End)
+	th.AssertEquals(t, true, cfg.requiresNamespaces())
+}
+
+func TestNewChainedAuthorizersInvalid(t *testing.T) {
+	for _, spec := range []authorizerSpec{
+		{Type: "unknown"},
+		{Type: AuthorizerNamespaceLabelDeny},
+		{Type: AuthorizerNamespaceLabelDeny, NamespaceSelector: "freeze in (true"},
+		{Type: AuthorizerTimeWindow},
+		{Type: AuthorizerTimeWindow, Windows: []timeWindowSpec{{Start: "08:00", End: "18:00"}}, TimeZone: "Mars/Olympus"},
+		{Type: AuthorizerTimeWindow, Windows: []timeWindowSpec{{Days: []string{"Someday"}, Start: "08:00", End: "18:00"}}},
+		{Type: AuthorizerTimeWindow, Windows: []timeWindowSpec{{Start: "8h", End: "18:00"}}},
+		{Type: AuthorizerTimeWindow, Windows: []timeWindowSpec{{Start: "08:00", End: "24:30"}}},
+		{Type: AuthorizerTimeWindow, Windows: []timeWindowSpec{{Start: "18:00", End: "08:00"}}},
+	} {
+		_, err := newChainedAuthorizers([]authorizerSpec{spec}, nil)
+		if err == nil {
+			t.Errorf("expected an error for %+v", spec)
+		}
+	}
+}
+
+func TestNamespaceLabelDenyAuthorizer(t *testing.T) {
+	namespaces := newTestNamespaceLister(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frozen", Labels: map[string]string{"freeze": "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "open"}},
+	)
+	chain, err := newChainedAuthorizers([]authorizerSpec{{
+		Type:              AuthorizerNamespaceLabelDeny,
+		NamespaceSelector: "freeze=true",
+		Verbs:             []string{"create", "delete"},
+		ExemptRoles:       []string{"admin"},
+	}}, namespaces)
+	th.AssertNoErr(t, err)
+	a := chain[0]
+
+	developer := &user.DefaultInfo{Name: "user1", Extra: map[string][]string{Roles: {"developer"}}}
+	admin := &user.DefaultInfo{Name: "user2", Extra: map[string][]string{Roles: {"developer", "admin"}}}
+
+	tests := []struct {
+		name     string
+		attrs    authorizer.AttributesRecord
+		decision authorizer.Decision
+	}{
+		{
+			name:     "create in frozen namespace",
+			attrs:    authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "frozen"},
+			decision: authorizer.DecisionDeny,
+		},
+		{
+			name:     "get in frozen namespace",
+			attrs:    authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "get", Resource: "pods", Namespace: "frozen"},
+			decision: authorizer.DecisionNoOpinion,
+		},
+		{
+			name:     "create in frozen namespace by exempt role",
+			attrs:    authorizer.AttributesRecord{User: admin, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "frozen"},
+			decision: authorizer.DecisionNoOpinion,
+		},
+		{
+			name:     "create in open namespace",
+			attrs:    authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "open"},
+			decision: authorizer.DecisionNoOpinion,
+		},
+		{
+			name:     "create in unknown namespace",
+			attrs:    authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "unknown"},
+			decision: authorizer.DecisionNoOpinion,
+		},
+		{
+			name:     "cluster scoped request",
+			attrs:    authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "namespaces"},
+			decision: authorizer.DecisionNoOpinion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, _, err := a.Authorize(tt.attrs)
+			th.AssertNoErr(t, err)
+			th.AssertEquals(t, tt.decision, decision)
+		})
+	}
+
+	// The requests are denied when the namespaces aren't watched
+	chain, err = newChainedAuthorizers([]authorizerSpec{{Type: AuthorizerNamespaceLabelDeny, NamespaceSelector: "freeze=true"}}, nil)
+	th.AssertNoErr(t, err)
+	decision, _, err := chain[0].Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "get", Resource: "pods", Namespace: "open"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionDeny, decision)
+}
+
+func TestTimeWindowAuthorizer(t *testing.T) {
+	chain, err := newChainedAuthorizers([]authorizerSpec{{
+		Type:  AuthorizerTimeWindow,
+		Roles: []string{"developer"},
+		Windows: []timeWindowSpec{
+			{Days: []string{"Mon", "tue", "WED", "Thu", "Fri"}, Start: "08:00", End: "18:00"},
+			{Days: []string{"Sat"}, Start: "22:00", End: "24:00"},
+		},
+		TimeZone: "Europe/Paris",
+	}}, nil)
+	th.AssertNoErr(t, err)
+	a := chain[0].(*timeWindowAuthorizer)
+
+	developer := &user.DefaultInfo{Name: "user1", Extra: map[string][]string{Roles: {"developer"}}}
+	viewer := &user.DefaultInfo{Name: "user2", Extra: map[string][]string{Roles: {"viewer"}}}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		user     user.Info
+		decision authorizer.Decision
+	}{
+		// 2024-05-06 is a Monday, Paris is UTC+2
+		{name: "monday morning", now: time.Date(2024, 5, 6, 6, 0, 0, 0, time.UTC), user: developer, decision: authorizer.DecisionNoOpinion},
+		{name: "monday before the window", now: time.Date(2024, 5, 6, 5, 59, 0, 0, time.UTC), user: developer, decision: authorizer.DecisionDeny},
+		{name: "monday end of the window", now: time.Date(2024, 5, 6, 16, 0, 0, 0, time.UTC), user: developer, decision: authorizer.DecisionDeny},
+		{name: "saturday night", now: time.Date(2024, 5, 11, 21, 30, 0, 0, time.UTC), user: developer, decision: authorizer.DecisionNoOpinion},
+		{name: "sunday", now: time.Date(2024, 5, 12, 10, 0, 0, 0, time.UTC), user: developer, decision: authorizer.DecisionDeny},
+		{name: "sunday other role", now: time.Date(2024, 5, 12, 10, 0, 0, 0, time.UTC), user: viewer, decision: authorizer.DecisionNoOpinion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.now = func() time.Time { return tt.now }
+			decision, _, err := a.Authorize(authorizer.AttributesRecord{User: tt.user, ResourceRequest: true, Verb: "delete", Resource: "pods", Namespace: "default"})
+			th.AssertNoErr(t, err)
+			th.AssertEquals(t, tt.decision, decision)
+		})
+	}
+}
+
+func TestAuthorizerChain(t *testing.T) {
+	cfg := &policyConfig{}
+	th.AssertNoErr(t, json.Unmarshal([]byte(chainTestPolicy), cfg))
+
+	a := &Authorizer{namespaces: newTestNamespaceLister(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frozen", Labels: map[string]string{"freeze": "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "open"}},
+	)}
+	th.AssertNoErr(t, a.setPolicy(cfg))
+	// Monday 10:00 in Paris
+	a.chain[1].(*timeWindowAuthorizer).now = func() time.Time { return time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC) }
+
+	developer := &user.DefaultInfo{
+		Name:  "user1",
+		Extra: map[string][]string{ProjectName: {"demo"}, Roles: {"developer"}},
+	}
+
+	// Allowed by the Keystone policy
+	decision, _, err := a.Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "open"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionAllow, decision)
+
+	// Denied by the chain before the Keystone policy
+	decision, reason, err := a.Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "create", Resource: "pods", Namespace: "frozen"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionDeny, decision)
+	th.AssertEquals(t, "Namespace frozen is frozen by the labels freeze=true.", reason)
+
+	decision, _, err = a.Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "get", Resource: "pods", Namespace: "frozen"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionAllow, decision)
+
+	// Sunday
+	a.chain[1].(*timeWindowAuthorizer).now = func() time.Time { return time.Date(2024, 5, 12, 8, 0, 0, 0, time.UTC) }
+	decision, _, err = a.Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "delete", Resource: "pods", Namespace: "open"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionDeny, decision)
+
+	// Nothing is allowed when the authorizers are invalid
+	cfg.Authorizers = append(cfg.Authorizers, authorizerSpec{Type: "unknown"})
+	th.AssertEquals(t, true, a.setPolicy(cfg) != nil)
+	decision, _, err = a.Authorize(authorizer.AttributesRecord{User: developer, ResourceRequest: true, Verb: "get", Resource: "pods", Namespace: "open"})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, authorizer.DecisionDeny, decision)
+}
+
+func TestAuthorizeTokenDenied(t *testing.T) {
+	cfg := &policyConfig{}
+	th.AssertNoErr(t, json.Unmarshal([]byte(chainTestPolicy), cfg))
+
+	a := &Authorizer{namespaces: newTestNamespaceLister(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frozen", Labels: map[string]string{"freeze": "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "open"}},
+	)}
+	th.AssertNoErr(t, a.setPolicy(cfg))
+	k := &Auth{authz: a}
+
+	review := func(project, verb, namespace string) map[string]interface{} {
+		data := map[string]interface{}{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SubjectAccessReview",
+			"spec": map[string]interface{}{
+				"user":  "user1",
+				"group": []interface{}{},
+				"extra": map[string]interface{}{
+					ProjectName: []interface{}{project},
+					Roles:       []interface{}{"developer"},
+				},
+				"resourceAttributes": map[string]interface{}{"verb": verb, "resource": "pods", "namespace": namespace},
+			},
+		}
+		w := httptest.NewRecorder()
+		k.authorizeToken(w, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(nil)), data)
+		th.AssertEquals(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		th.AssertNoErr(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["status"].(map[string]interface{})
+	}
+
+	// Denied by the chain, the other authorizers of the API server must not allow the request
+	th.AssertDeepEquals(t, map[string]interface{}{
+		"allowed": false,
+		"denied":  true,
+		"reason":  "Namespace frozen is frozen by the labels freeze=true.",
+	}, review("demo", "create", "frozen"))
+
+	th.AssertDeepEquals(t, map[string]interface{}{"allowed": true}, review("demo", "create", "open"))
+
+	// Not allowed by the Keystone policy, the other authorizers of the API server can allow the request
+	th.AssertDeepEquals(t, map[string]interface{}{"allowed": false}, review("other", "create", "open"))
+}
+
+func TestAuthorizeTokenDeniedWithoutPolicies(t *testing.T) {
+	// A policy file with the authorizers only still freezes the namespaces
+	cfg := &policyConfig{}
+	th.AssertNoErr(t, json.Unmarshal([]byte(`{"authorizers": [{"type": "namespace-label-deny", "namespace_selector": "freeze=true"}]}`), cfg))
+	a := &Authorizer{namespaces: newTestNamespaceLister(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frozen", Labels: map[string]string{"freeze": "true"}}},
+	)}
+	th.AssertNoErr(t, a.setPolicy(cfg))
+	k := &Auth{authz: a}
+
+	data := map[string]interface{}{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SubjectAccessReview",
+		"spec": map[string]interface{}{
+			"user":               "user1",
+			"group":              []interface{}{},
+			"extra":              map[string]interface{}{Roles: []interface{}{"developer"}},
+			"resourceAttributes": map[string]interface{}{"verb": "create", "resource": "pods", "namespace": "frozen"},
+		},
+	}
+	w := httptest.NewRecorder()
+	k.authorizeToken(w, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(nil)), data)
+	th.AssertEquals(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	th.AssertNoErr(t, json.Unmarshal(w.Body.Bytes(), &response))
+	th.AssertDeepEquals(t, map[string]interface{}{
+		"allowed": false,
+		"denied":  true,
+		"reason":  "Namespace frozen is frozen by the labels freeze=true.",
+	}, response["status"])
+}
@@ -18,6 +18,7 @@ package keystone
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/v2"
@@ -411,4 +412,130 @@ func TestAuthorizerVersion2(t *testing.T) {
 	attrs = authorizer.AttributesRecord{User: testuser2, ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods"}
 	decision, _, _ = a.Authorize(attrs)
 	th.AssertEquals(t, authorizer.DecisionAllow, decision)
+
+	// testuser2 is allowed to access the subresources of the pods, its policy doesn't set strict_subresources
+	attrs = authorizer.AttributesRecord{User: testuser2, ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods", Subresource: "log"}
+	decision, _, _ = a.Authorize(attrs)
+	th.AssertEquals(t, authorizer.DecisionAllow, decision)
+
+	// clusteradmin is allowed to access any subresource
+	attrs = authorizer.AttributesRecord{User: clusteradmin, ResourceRequest: true, Verb: "create", Namespace: "kube-system", Resource: "pods", Subresource: "exec"}
+	decision, _, _ = a.Authorize(attrs)
+	th.AssertEquals(t, authorizer.DecisionAllow, decision)
+}
+
+func TestAuthorizerVersion2Subresources(t *testing.T) {
+	path, err := os.Getwd()
+	th.AssertNoErr(t, err)
+	path += "/authorizer_test_policy_version2.json"
+	policy, err := newFromFile(path)
+	th.AssertNoErr(t, err)
+
+	a := &Authorizer{authURL: "127.0.0.1", pl: policy}
+
+	operator := &user.DefaultInfo{
+		Name:   "operator",
+		Groups: []string{"group1"},
+		Extra: map[string][]string{
+			ProjectName: {"demo"},
+			Roles:       {"operator"},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		attrs    authorizer.AttributesRecord
+		expected authorizer.Decision
+	}{
+		{
+			name:     "get pods",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "get pods/log",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods", Subresource: "log"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "create pods/exec",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "create", Namespace: "default", Resource: "pods", Subresource: "exec"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "create pods/portforward",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "create", Namespace: "default", Resource: "pods", Subresource: "portforward"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "get pods/exec is not granted by get pods",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods", Subresource: "exec"},
+			expected: authorizer.DecisionDeny,
+		},
+		{
+			name:     "create pods/attach",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "create", Namespace: "default", Resource: "pods", Subresource: "attach"},
+			expected: authorizer.DecisionDeny,
+		},
+		{
+			name:     "create pods/exec in another namespace",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: true, Verb: "create", Namespace: "kube-system", Resource: "pods", Subresource: "exec"},
+			expected: authorizer.DecisionDeny,
+		},
+		{
+			name:     "get /metrics",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: false, Verb: "get", Path: "/metrics"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "get /logs subpath",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: false, Verb: "get", Path: "/logs/kube-apiserver.log"},
+			expected: authorizer.DecisionAllow,
+		},
+		{
+			name:     "get /healthz",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: false, Verb: "get", Path: "/healthz"},
+			expected: authorizer.DecisionDeny,
+		},
+		{
+			name:     "post /metrics",
+			attrs:    authorizer.AttributesRecord{ResourceRequest: false, Verb: "post", Path: "/metrics"},
+			expected: authorizer.DecisionDeny,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.attrs.User = operator
+			decision, _, _ := a.Authorize(tc.attrs)
+			th.AssertEquals(t, tc.expected, decision)
+		})
+	}
+}
+
+func TestEvaluatePolicyFile(t *testing.T) {
+	path, err := os.Getwd()
+	th.AssertNoErr(t, err)
+	path += "/authorizer_test_policy.json"
+
+	req := PolicyRequest{User: "user1", ProjectName: "project1", Roles: []string{"role1"}, Verb: "get", Resource: "user_resource1"}
+	decision, err := EvaluatePolicyFile(path, req)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, decision.Allowed)
+	th.AssertEquals(t, 0, decision.Rule)
+	th.AssertEquals(t, true, strings.Contains(decision.Policy, "user_resource1"))
+
+	req.Verb = "delete"
+	decision, err = EvaluatePolicyFile(path, req)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, decision.Allowed)
+	th.AssertEquals(t, -1, decision.Rule)
+	th.AssertEquals(t, "", decision.Policy)
+
+	req = PolicyRequest{User: "user1", Verb: "get", Resource: "pods", Path: "/healthz"}
+	_, err = EvaluatePolicyFile(path, req)
+	th.AssertErr(t, err)
+
+	_, err = EvaluatePolicyFile(path+".missing", PolicyRequest{Verb: "get", Path: "/healthz"})
+	th.AssertErr(t, err)
 }
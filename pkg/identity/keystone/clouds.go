@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/utils/v2/openstack/clientconfig"
+)
+
+// CloudOptions returns the options of the cloud of clouds.yaml, merged with its secure.yaml entry. The OS_*
+// environment variables complete the settings missing from the files.
+func CloudOptions(cloudName string) (Options, error) {
+	return cloudOptions(cloudName, clientconfig.YAMLOpts{})
+}
+
+func cloudOptions(cloudName string, yamlOpts clientconfig.YAMLOptsBuilder) (Options, error) {
+	clientOpts := &clientconfig.ClientOpts{Cloud: cloudName, YAMLOpts: yamlOpts}
+
+	cloud, err := clientconfig.GetCloudFromYAML(clientOpts)
+	if err != nil {
+		return Options{}, fmt.Errorf("failed to read cloud %q: %v", cloudName, err)
+	}
+	authOpts, err := clientconfig.AuthOptions(clientOpts)
+	if err != nil {
+		return Options{}, fmt.Errorf("failed to read the credentials of cloud %q: %v", cloudName, err)
+	}
+
+	return Options{
+		AuthOptions:    *authOpts,
+		ClientCertPath: cloud.ClientCertFile,
+		ClientKeyPath:  cloud.ClientKeyFile,
+		ClientCAPath:   cloud.CACertFile,
+	}, nil
+}
+
+// CloudsYAMLExists checks whether a clouds.yaml file is found in OS_CLIENT_CONFIG_FILE, the current directory,
+// ~/.config/openstack or /etc/openstack.
+func CloudsYAMLExists() bool {
+	_, _, err := clientconfig.FindAndReadCloudsYAML()
+	return err == nil
+}
+
+// NeedsPassword checks whether the credentials authenticate a user whose password is missing.
+func NeedsPassword(options Options) bool {
+	opts := options.AuthOptions
+	if opts.Password != "" || opts.TokenID != "" {
+		return false
+	}
+	if opts.ApplicationCredentialID != "" || opts.ApplicationCredentialName != "" {
+		return false
+	}
+	return opts.Username != "" || opts.UserID != ""
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	"github.com/gophercloud/utils/v2/openstack/clientconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// dirYAMLOpts loads clouds.yaml and secure.yaml from a directory.
+type dirYAMLOpts struct {
+	dir string
+}
+
+func (opts dirYAMLOpts) load(name string) (map[string]clientconfig.Cloud, error) {
+	content, err := os.ReadFile(filepath.Join(opts.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var clouds clientconfig.Clouds
+	if err := yaml.Unmarshal(content, &clouds); err != nil {
+		return nil, err
+	}
+	return clouds.Clouds, nil
+}
+
+func (opts dirYAMLOpts) LoadCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return opts.load("clouds.yaml")
+}
+
+func (opts dirYAMLOpts) LoadSecureCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return opts.load("secure.yaml")
+}
+
+func (opts dirYAMLOpts) LoadPublicCloudsYAML() (map[string]clientconfig.Cloud, error) {
+	return nil, nil
+}
+
+func TestCloudOptions(t *testing.T) {
+	dir := t.TempDir()
+	th.AssertNoErr(t, os.WriteFile(filepath.Join(dir, "clouds.yaml"), []byte(`
+clouds:
+  mycloud:
+    auth:
+      auth_url: https://keystone/v3
+      username: user
+      user_domain_name: default
+      project_name: demo
+      project_domain_name: default
+    cacert: /etc/ssl/ca.pem
+`), 0600))
+	th.AssertNoErr(t, os.WriteFile(filepath.Join(dir, "secure.yaml"), []byte(`
+clouds:
+  mycloud:
+    auth:
+      password: secret
+`), 0600))
+
+	options, err := cloudOptions("mycloud", dirYAMLOpts{dir: dir})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "https://keystone/v3", options.AuthOptions.IdentityEndpoint)
+	th.AssertEquals(t, "user", options.AuthOptions.Username)
+	th.AssertEquals(t, "secret", options.AuthOptions.Password)
+	th.AssertEquals(t, "/etc/ssl/ca.pem", options.ClientCAPath)
+	th.AssertEquals(t, false, NeedsPassword(options))
+
+	_, err = cloudOptions("missing", dirYAMLOpts{dir: dir})
+	th.AssertEquals(t, true, err != nil)
+}
+
+func TestCloudsYAMLExists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clouds.yaml")
+	th.AssertNoErr(t, os.WriteFile(path, []byte("clouds: {}\n"), 0600))
+
+	t.Setenv("OS_CLIENT_CONFIG_FILE", path)
+	th.AssertEquals(t, true, CloudsYAMLExists())
+}
+
+func TestNeedsPassword(t *testing.T) {
+	th.AssertEquals(t, true, NeedsPassword(Options{AuthOptions: gophercloud.AuthOptions{Username: "user"}}))
+	th.AssertEquals(t, false, NeedsPassword(Options{AuthOptions: gophercloud.AuthOptions{Username: "user", Password: "secret"}}))
+	th.AssertEquals(t, false, NeedsPassword(Options{AuthOptions: gophercloud.AuthOptions{ApplicationCredentialID: "id"}}))
+	th.AssertEquals(t, false, NeedsPassword(Options{}))
+}
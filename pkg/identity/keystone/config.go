@@ -19,6 +19,7 @@ package keystone
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -36,6 +37,18 @@ type Config struct {
 	SyncConfigFile      string
 	SyncConfigMapName   string
 	Kubeconfig          string
+	TokenCacheTTL       time.Duration
+	TokenCacheSize      int
+	SecurityHeaders     bool
+	CORSAllowedOrigins  []string
+	HealthAddress       string
+
+	FederationGroupPrefixes map[string]string
+
+	ProjectSyncConfigFile string
+
+	TracingEndpoint               string
+	TracingSamplingRatePerMillion int32
 }
 
 // NewConfig returns a Config
@@ -51,6 +64,9 @@ func NewConfig() *Config {
 		SyncConfigFile:      os.Getenv("KEYSTONE_SYNC_CONFIG_FILE"),
 		SyncConfigMapName:   os.Getenv("KEYSTONE_SYNC_CONFIGMAP_NAME"),
 		Kubeconfig:          os.Getenv("KEYSTONE_KUBECONFIG_FILE"),
+		TokenCacheSize:      1000,
+
+		ProjectSyncConfigFile: os.Getenv("KEYSTONE_PROJECT_SYNC_CONFIG_FILE"),
 	}
 }
 
@@ -73,6 +89,32 @@ func (c *Config) ValidateFlags() error {
 		klog.Warning("Argument --sync-config-file or --sync-configmap-name missing. Data synchronization between Keystone and Kubernetes is disabled.")
 	}
 
+	if c.TokenCacheTTL < 0 {
+		errorsFound = true
+		klog.Errorf("--token-cache-ttl must not be negative.")
+	}
+	if c.TokenCacheTTL > 0 && c.TokenCacheSize <= 0 {
+		errorsFound = true
+		klog.Errorf("--token-cache-size must be positive when the token cache is enabled.")
+	}
+
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "" {
+			errorsFound = true
+			klog.Errorf("--cors-allowed-origins must not contain empty origins.")
+			break
+		}
+	}
+	if c.HealthAddress != "" && c.HealthAddress == c.Address {
+		errorsFound = true
+		klog.Errorf("--health-listen must be different from --listen.")
+	}
+
+	if c.TracingSamplingRatePerMillion < 0 || c.TracingSamplingRatePerMillion > 1000000 {
+		errorsFound = true
+		klog.Errorf("--tracing-sampling-rate-per-million must be between 0 and 1000000.")
+	}
+
 	if errorsFound {
 		return fmt.Errorf("failed to validate the input parameters")
 	}
@@ -90,5 +132,14 @@ func (c *Config) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&c.PolicyConfigMapName, "policy-configmap-name", c.PolicyConfigMapName, "ConfigMap in kube-system namespace containing the policy configuration, the ConfigMap data must contain the key 'policies'")
 	fs.StringVar(&c.SyncConfigFile, "sync-config-file", c.SyncConfigFile, "File containing config values for data synchronization between Keystone and Kubernetes.")
 	fs.StringVar(&c.SyncConfigMapName, "sync-configmap-name", "", "ConfigMap in kube-system namespace containing config values for data synchronization between Keystone and Kubernetes.")
+	fs.StringVar(&c.ProjectSyncConfigFile, "project-sync-config-file", c.ProjectSyncConfigFile, "File containing the configuration of the controller periodically creating a namespace per Keystone project and the role bindings of the users of the projects. The controller is disabled when empty.")
 	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "Kubeconfig file used to connect to Kubernetes API to get policy configmap. If the service is running inside the pod, this option is not necessary, will use in-cluster config instead.")
+	fs.DurationVar(&c.TokenCacheTTL, "token-cache-ttl", c.TokenCacheTTL, "Duration to cache the users authenticated by Keystone, tokens are never cached beyond their expiration. A revoked token keeps being accepted until its cache entry expires. 0 disables the cache.")
+	fs.IntVar(&c.TokenCacheSize, "token-cache-size", c.TokenCacheSize, "Maximum number of tokens in the token cache, the least recently used ones are evicted first.")
+	fs.BoolVar(&c.SecurityHeaders, "security-headers", c.SecurityHeaders, "Add strict security headers (HSTS, no-sniff, deny framing, no-store, restrictive CSP) to the webhook server responses.")
+	fs.StringSliceVar(&c.CORSAllowedOrigins, "cors-allowed-origins", c.CORSAllowedOrigins, "Comma separated list of origins allowed to call the webhook server from a browser, '*' allows any origin. CORS headers are not sent when empty.")
+	fs.StringVar(&c.TracingEndpoint, "tracing-endpoint", c.TracingEndpoint, "<address>:<port> of the OTLP gRPC collector the traces of the webhook requests are exported to, e.g. localhost:4317. Tracing is disabled when empty.")
+	fs.Int32Var(&c.TracingSamplingRatePerMillion, "tracing-sampling-rate-per-million", c.TracingSamplingRatePerMillion, "Number of webhook requests sampled per million when the apiserver didn't sample them, the requests sampled by the apiserver are always traced.")
+	fs.StringToStringVar(&c.FederationGroupPrefixes, "federation-group-prefixes", c.FederationGroupPrefixes, "Comma separated list of <identity provider>=<prefix> pairs, the groups of the users federated through the identity provider are mapped to Kubernetes groups named <prefix><group name>. '<identity provider>:' is used as prefix for the identity providers not in the list.")
+	fs.StringVar(&c.HealthAddress, "health-listen", c.HealthAddress, "<address>:<port> of a plaintext listener only serving /healthz, e.g. 127.0.0.1:8080. Disabled when empty.")
 }
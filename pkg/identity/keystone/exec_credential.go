@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	tokens3 "github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	clientauthv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+)
+
+// ExecInfoEnv is the environment variable used by client-go to pass the ExecCredential to the plugin.
+const ExecInfoEnv = "KUBERNETES_EXEC_INFO"
+
+// ExecInfo is the ExecCredential passed to the plugin by client-go.
+type ExecInfo struct {
+	// APIVersion is the ExecCredential API version expected by client-go.
+	APIVersion string
+	// Interactive is nil when client-go doesn't tell whether stdin has been passed to the plugin.
+	Interactive *bool
+	// Unauthorized is true when the previous credentials were rejected by the Kubernetes API.
+	Unauthorized bool
+}
+
+// ParseExecInfo parses the content of the KUBERNETES_EXEC_INFO environment variable. The v1beta1 API is used when it's
+// not set, as it's what the plugin always returned.
+func ParseExecInfo(execInfo string) (*ExecInfo, error) {
+	if execInfo == "" {
+		return &ExecInfo{APIVersion: clientauthv1beta1.SchemeGroupVersion.String()}, nil
+	}
+
+	var cred struct {
+		metav1.TypeMeta `json:",inline"`
+		Spec            struct {
+			Response *struct {
+				Code int32 `json:"code"`
+			} `json:"response,omitempty"`
+			Interactive *bool `json:"interactive,omitempty"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(execInfo), &cred); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ExecInfoEnv, err)
+	}
+
+	switch cred.APIVersion {
+	case clientauthv1.SchemeGroupVersion.String(), clientauthv1beta1.SchemeGroupVersion.String():
+	default:
+		return nil, fmt.Errorf("unsupported ExecCredential API version %q", cred.APIVersion)
+	}
+
+	return &ExecInfo{
+		APIVersion:   cred.APIVersion,
+		Interactive:  cred.Spec.Interactive,
+		Unauthorized: cred.Spec.Response != nil && cred.Spec.Response.Code == http.StatusUnauthorized,
+	}, nil
+}
+
+// execCredential is the ExecCredential returned by the plugin, the status is the same in the v1beta1 and v1 APIs.
+type execCredential struct {
+	metav1.TypeMeta `json:",inline"`
+	Status          *clientauthv1.ExecCredentialStatus `json:"status"`
+}
+
+// NewExecCredential returns the ExecCredential of the given API version holding the token. The status is empty when
+// the token is nil.
+func NewExecCredential(apiVersion string, token *tokens3.Token) ([]byte, error) {
+	switch apiVersion {
+	case clientauthv1.SchemeGroupVersion.String(), clientauthv1beta1.SchemeGroupVersion.String():
+	default:
+		return nil, fmt.Errorf("unsupported ExecCredential API version %q", apiVersion)
+	}
+
+	cred := execCredential{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: "ExecCredential"},
+		Status:   &clientauthv1.ExecCredentialStatus{},
+	}
+	if token != nil {
+		cred.Status.Token = token.ID
+		cred.Status.ExpirationTimestamp = &metav1.Time{Time: token.ExpiresAt}
+	}
+
+	return json.MarshalIndent(cred, "", "\t")
+}
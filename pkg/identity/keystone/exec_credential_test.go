@@ -0,0 +1,72 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	tokens3 "github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+)
+
+func TestParseExecInfo(t *testing.T) {
+	info, err := ParseExecInfo("")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "client.authentication.k8s.io/v1beta1", info.APIVersion)
+	th.AssertEquals(t, true, info.Interactive == nil)
+
+	info, err = ParseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":false}}`)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "client.authentication.k8s.io/v1", info.APIVersion)
+	th.AssertEquals(t, false, *info.Interactive)
+	th.AssertEquals(t, false, info.Unauthorized)
+
+	info, err = ParseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","spec":{"response":{"code":401,"header":{}},"interactive":true}}`)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, *info.Interactive)
+	th.AssertEquals(t, true, info.Unauthorized)
+
+	_, err = ParseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1alpha1","kind":"ExecCredential"}`)
+	th.AssertEquals(t, true, err != nil)
+}
+
+func TestNewExecCredential(t *testing.T) {
+	expiresAt := time.Date(2018, 3, 5, 17, 30, 20, 0, time.UTC)
+	out, err := NewExecCredential("client.authentication.k8s.io/v1", &tokens3.Token{ID: "my-bearer-token", ExpiresAt: expiresAt})
+	th.AssertNoErr(t, err)
+	th.AssertJSONEquals(t, `{
+		"apiVersion": "client.authentication.k8s.io/v1",
+		"kind": "ExecCredential",
+		"status": {
+			"token": "my-bearer-token",
+			"expirationTimestamp": "2018-03-05T17:30:20Z"
+		}
+	}`, json.RawMessage(out))
+
+	out, err = NewExecCredential("client.authentication.k8s.io/v1beta1", nil)
+	th.AssertNoErr(t, err)
+	th.AssertJSONEquals(t, `{
+		"apiVersion": "client.authentication.k8s.io/v1beta1",
+		"kind": "ExecCredential",
+		"status": {}
+	}`, json.RawMessage(out))
+
+	_, err = NewExecCredential("client.authentication.k8s.io/v1alpha1", nil)
+	th.AssertEquals(t, true, err != nil)
+}
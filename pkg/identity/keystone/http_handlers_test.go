@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	rec := httptest.NewRecorder()
+	securityHeadersMiddleware(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+
+	th.AssertEquals(t, http.StatusOK, rec.Code)
+	for name, value := range securityHeaders {
+		th.AssertEquals(t, value, rec.Header().Get(name))
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		method         string
+		origin         string
+		expectedCode   int
+		expectedOrigin string
+		expectedMethod string
+	}{
+		{
+			name:           "no origin",
+			allowedOrigins: []string{"https://a.example.com"},
+			method:         http.MethodPost,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:           "allowed origin",
+			allowedOrigins: []string{"https://a.example.com"},
+			method:         http.MethodPost,
+			origin:         "https://a.example.com",
+			expectedCode:   http.StatusOK,
+			expectedOrigin: "https://a.example.com",
+		},
+		{
+			name:           "disallowed origin",
+			allowedOrigins: []string{"https://a.example.com"},
+			method:         http.MethodPost,
+			origin:         "https://b.example.com",
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:           "any origin",
+			allowedOrigins: []string{"*"},
+			method:         http.MethodPost,
+			origin:         "https://b.example.com",
+			expectedCode:   http.StatusOK,
+			expectedOrigin: "https://b.example.com",
+		},
+		{
+			name:           "preflight",
+			allowedOrigins: []string{"https://a.example.com"},
+			method:         http.MethodOptions,
+			origin:         "https://a.example.com",
+			expectedCode:   http.StatusNoContent,
+			expectedOrigin: "https://a.example.com",
+			expectedMethod: "GET, POST",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/webhook", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			if test.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			corsMiddleware(test.allowedOrigins)(okHandler).ServeHTTP(rec, req)
+
+			th.AssertEquals(t, test.expectedCode, rec.Code)
+			th.AssertEquals(t, test.expectedOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+			th.AssertEquals(t, test.expectedMethod, rec.Header().Get("Access-Control-Allow-Methods"))
+			th.AssertEquals(t, "Origin", rec.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestHealthzHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	th.AssertEquals(t, http.StatusOK, rec.Code)
+	th.AssertEquals(t, "ok", rec.Body.String())
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+// KeyringService is the service of the passwords stored in the OS keyring.
+const KeyringService = "client-keystone-auth"
+
+// Keyring stores the Keystone passwords in the keyring of the OS, one per auth URL and user. It uses the security
+// command on macOS and the secret-tool command of libsecret on the other systems, so that the password never appears
+// in their arguments.
+type Keyring struct {
+	goos string
+	// run runs the command with the given stdin and returns its stdout.
+	run func(stdin string, name string, args ...string) (string, error)
+}
+
+// NewKeyring returns the keyring of the OS.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		goos: runtime.GOOS,
+		run:  runKeyringCommand,
+	}
+}
+
+func runKeyringCommand(stdin string, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// keyringAccount returns the account of the password of the given credentials.
+func keyringAccount(opts gophercloud.AuthOptions) string {
+	user := opts.UserID
+	if user == "" {
+		domain := opts.DomainID
+		if domain == "" {
+			domain = opts.DomainName
+		}
+		user = opts.Username + "@" + domain
+	}
+	return user + " " + opts.IdentityEndpoint
+}
+
+// quoteSecurityArg quotes an argument of the interactive mode of the security command.
+func quoteSecurityArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// GetPassword returns the password of the given credentials, the empty string when there is none.
+func (k *Keyring) GetPassword(opts gophercloud.AuthOptions) (string, error) {
+	account := keyringAccount(opts)
+
+	var out string
+	var err error
+	switch k.goos {
+	case "windows":
+		return "", fmt.Errorf("the keyring is not supported on %s", k.goos)
+	case "darwin":
+		out, err = k.run("", "security", "find-generic-password", "-s", KeyringService, "-a", account, "-w")
+	default:
+		out, err = k.run("", "secret-tool", "lookup", "service", KeyringService, "account", account)
+	}
+	if err != nil {
+		// The commands fail when the password is missing.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read the password from the keyring: %v", err)
+	}
+
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// SetPassword stores the password of the given credentials, it replaces the previous one.
+func (k *Keyring) SetPassword(opts gophercloud.AuthOptions, password string) error {
+	account := keyringAccount(opts)
+
+	var err error
+	switch k.goos {
+	case "windows":
+		return fmt.Errorf("the keyring is not supported on %s", k.goos)
+	case "darwin":
+		// The interactive mode reads the command, with the password, from stdin.
+		command := strings.Join([]string{"add-generic-password", "-U",
+			"-s", quoteSecurityArg(KeyringService), "-a", quoteSecurityArg(account), "-w", quoteSecurityArg(password)}, " ")
+		_, err = k.run(command+"\n", "security", "-i")
+	default:
+		_, err = k.run(password, "secret-tool", "store", "--label", "Keystone password of "+account,
+			"service", KeyringService, "account", account)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store the password in the keyring: %v", err)
+	}
+
+	return nil
+}
+
+// DeletePassword removes the password of the given credentials, e.g. when Keystone rejected it.
+func (k *Keyring) DeletePassword(opts gophercloud.AuthOptions) error {
+	account := keyringAccount(opts)
+
+	var err error
+	switch k.goos {
+	case "windows":
+		return fmt.Errorf("the keyring is not supported on %s", k.goos)
+	case "darwin":
+		_, err = k.run("", "security", "delete-generic-password", "-s", KeyringService, "-a", account)
+	default:
+		_, err = k.run("", "secret-tool", "clear", "service", KeyringService, "account", account)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete the password from the keyring: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+)
+
+type keyringCall struct {
+	stdin string
+	args  []string
+}
+
+func fakeKeyring(goos string, out string, err error) (*Keyring, *[]keyringCall) {
+	calls := &[]keyringCall{}
+	return &Keyring{
+		goos: goos,
+		run: func(stdin string, name string, args ...string) (string, error) {
+			*calls = append(*calls, keyringCall{stdin: stdin, args: append([]string{name}, args...)})
+			return out, err
+		},
+	}, calls
+}
+
+func TestKeyringSecretTool(t *testing.T) {
+	opts := gophercloud.AuthOptions{IdentityEndpoint: "https://keystone/v3", Username: "user", DomainName: "default"}
+	account := "user@default https://keystone/v3"
+
+	keyring, calls := fakeKeyring("linux", "secret\n", nil)
+	password, err := keyring.GetPassword(opts)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "secret", password)
+
+	th.AssertNoErr(t, keyring.SetPassword(opts, "new secret"))
+	th.AssertNoErr(t, keyring.DeletePassword(opts))
+
+	th.AssertDeepEquals(t, []keyringCall{
+		{args: []string{"secret-tool", "lookup", "service", KeyringService, "account", account}},
+		{stdin: "new secret", args: []string{"secret-tool", "store", "--label", "Keystone password of " + account, "service", KeyringService, "account", account}},
+		{args: []string{"secret-tool", "clear", "service", KeyringService, "account", account}},
+	}, *calls)
+}
+
+func TestKeyringSecurity(t *testing.T) {
+	opts := gophercloud.AuthOptions{IdentityEndpoint: "https://keystone/v3", UserID: "1234"}
+
+	keyring, calls := fakeKeyring("darwin", "", nil)
+	th.AssertNoErr(t, keyring.SetPassword(opts, `pa"ss`))
+
+	th.AssertEquals(t, 1, len(*calls))
+	call := (*calls)[0]
+	th.AssertDeepEquals(t, []string{"security", "-i"}, call.args)
+	th.AssertEquals(t, `add-generic-password -U -s "client-keystone-auth" -a "1234 https://keystone/v3" -w "pa\"ss"`+"\n", call.stdin)
+	// The password isn't passed as an argument
+	th.AssertEquals(t, false, strings.Contains(strings.Join(call.args, " "), "pa"))
+}
+
+func TestKeyringMissingPassword(t *testing.T) {
+	opts := gophercloud.AuthOptions{IdentityEndpoint: "https://keystone/v3", Username: "user", DomainID: "default"}
+
+	keyring, _ := fakeKeyring("linux", "", &exec.ExitError{})
+	password, err := keyring.GetPassword(opts)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "", password)
+
+	keyring, _ = fakeKeyring("windows", "", nil)
+	_, err = keyring.GetPassword(opts)
+	th.AssertEquals(t, true, err != nil)
+}
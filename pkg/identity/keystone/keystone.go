@@ -22,13 +22,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/gophercloud/gophercloud/v2/openstack/utils"
 	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v2"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -46,6 +52,8 @@ import (
 	certutil "k8s.io/client-go/util/cert"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/cloud-provider-openstack/pkg/version"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/component-base/tracing"
 	"k8s.io/klog/v2"
 )
 
@@ -57,6 +65,9 @@ const (
 	ProjectName = "alpha.kubernetes.io/identity/project/name"
 	DomainID    = "alpha.kubernetes.io/identity/user/domain/id"
 	DomainName  = "alpha.kubernetes.io/identity/user/domain/name"
+
+	IdentityProvider = "alpha.kubernetes.io/identity/federation/identity-provider"
+	Protocol         = "alpha.kubernetes.io/identity/federation/protocol"
 )
 
 var userAgentData []string
@@ -90,12 +101,14 @@ type Auth struct {
 	authz          *Authorizer
 	k8sClient      *kubernetes.Clientset
 	syncer         *Syncer
+	projectSyncer  *ProjectSyncer
 	config         *Config
 	stopCh         chan struct{}
 	queue          workqueue.TypedRateLimitingInterface[any]
 	informer       informers.SharedInformerFactory
 	cmLister       corelisters.ConfigMapLister
 	cmListerSynced cache.InformerSynced
+	tracerProvider tracing.TracerProvider
 }
 
 // Run starts the keystone webhook server.
@@ -114,13 +127,64 @@ func (k *Auth) Run() {
 		klog.Info("ConfigMaps synced and ready")
 
 		go wait.Until(k.runWorker, time.Second, k.stopCh)
+
+		if k.projectSyncer != nil {
+			go k.projectSyncer.Run(k.stopCh)
+		}
+	}
+
+	if k.config.HealthAddress != "" {
+		go k.runHealthServer()
 	}
 
 	r := chi.NewRouter()
-	r.HandleFunc("/webhook", k.Handler)
+	if k.config.SecurityHeaders {
+		r.Use(securityHeadersMiddleware)
+	}
+	if len(k.config.CORSAllowedOrigins) > 0 {
+		r.Use(corsMiddleware(k.config.CORSAllowedOrigins))
+	}
+	r.Handle("/webhook", tracing.WithTracing(http.HandlerFunc(k.Handler), k.tracerProvider, webhookSpanName))
+	r.Handle("/metrics", legacyregistry.Handler())
+
+	server := &http.Server{
+		Addr:    k.config.Address,
+		Handler: r,
+		// The Go TLS server never accepts renegotiation, only TLS 1.2 and newer are allowed.
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	}
 
 	klog.Infof("Starting webhook server...")
-	klog.Fatal(http.ListenAndServeTLS(k.config.Address, k.config.CertFile, k.config.KeyFile, r))
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServeTLS(k.config.CertFile, k.config.KeyFile)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serverErr:
+		// The spans of the last requests are exported before exiting
+		shutdownTracerProvider(k.tracerProvider)
+		klog.Fatal(err)
+	case sig := <-sigCh:
+		klog.Infof("Received %v, shutting down the webhook server", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			klog.Errorf("Failed to shut down the webhook server: %v", err)
+		}
+		shutdownTracerProvider(k.tracerProvider)
+	}
+}
+
+// runHealthServer serves the health checks on a plaintext listener, separate from the TLS webhook server.
+func (k *Auth) runHealthServer() {
+	r := chi.NewRouter()
+	r.HandleFunc("/healthz", healthzHandler)
+
+	klog.Infof("Starting health server on %s...", k.config.HealthAddress)
+	klog.Fatal(http.ListenAndServe(k.config.HealthAddress, r))
 }
 
 func (k *Auth) enqueueConfigMap(obj interface{}) {
@@ -175,19 +239,20 @@ func (k *Auth) processNextItem() bool {
 func (k *Auth) updatePolicies(cm *apiv1.ConfigMap, key string) {
 	klog.Info("ConfigMap created or updated, will update the authorization policy.")
 
-	var policy policyList
+	var policy policyConfig
 	if err := json.Unmarshal([]byte(cm.Data["policies"]), &policy); err != nil {
 		runtimeutil.HandleError(fmt.Errorf("failed to parse policies defined in the configmap %s: %v", key, err))
 	}
-	if len(policy) > 0 {
-		if _, err := json.MarshalIndent(policy, "", "  "); err != nil {
+	if len(policy.Policies) > 0 {
+		if _, err := json.MarshalIndent(policy.Policies, "", "  "); err != nil {
 			runtimeutil.HandleError(fmt.Errorf("failed to parse policies defined in the configmap %s: %v", key, err))
 		}
 	}
 
-	k.authz.mu.Lock()
-	k.authz.pl = policy
-	k.authz.mu.Unlock()
+	if err := k.authz.setPolicy(&policy); err != nil {
+		runtimeutil.HandleError(fmt.Errorf("invalid authorizers defined in the configmap %s, denying all the requests: %v", key, err))
+		return
+	}
 
 	klog.Infof("Authorization policy updated.")
 }
@@ -222,6 +287,7 @@ func (k *Auth) processItem(key string) error {
 			klog.Infof("PolicyConfigmap %v has been deleted.", k.config.PolicyConfigMapName)
 			k.authz.mu.Lock()
 			k.authz.pl = make([]*policy, 0)
+			k.authz.chain = nil
 			k.authz.mu.Unlock()
 		}
 		if name == k.config.SyncConfigMapName {
@@ -259,6 +325,17 @@ func (k *Auth) Handler(w http.ResponseWriter, r *http.Request) {
 	var apiVersion = data["apiVersion"].(string)
 	var kind = data["kind"].(string)
 
+	// The request ID correlates the trace with the audit events of the apiserver.
+	requestID := r.Header.Get("X-Request-Id")
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String(attrRequestID, requestID),
+		attribute.String(attrReviewKind, kind),
+	)
+	r = r.WithContext(withRequestID(r.Context(), requestID))
+
 	if apiVersion != "authentication.k8s.io/v1beta1" && apiVersion != "authorization.k8s.io/v1beta1" {
 		http.Error(w, fmt.Sprintf("unknown apiVersion %q", apiVersion), http.StatusBadRequest)
 		return
@@ -284,9 +361,19 @@ func (k *Auth) Handler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (k *Auth) authenticateToken(w http.ResponseWriter, r *http.Request, token string, data map[string]interface{}) *userInfo {
-	user, authenticated, err := k.authn.AuthenticateToken(token)
+	user, authenticated, err := k.authn.AuthenticateToken(r.Context(), token)
 	klog.V(4).Infof("authenticateToken : %v, %v, %v\n", token, user, err)
 
+	span := trace.SpanFromContext(r.Context())
+	if err != nil {
+		recordSpanError(span, err)
+	}
+	if authenticated {
+		span.SetAttributes(attribute.String(attrDecision, "authenticated"))
+	} else {
+		span.SetAttributes(attribute.String(attrDecision, "unauthenticated"))
+	}
+
 	if !authenticated {
 		var response status
 		response.Authenticated = false
@@ -375,25 +462,26 @@ func (k *Auth) authorizeToken(w http.ResponseWriter, r *http.Request, data map[s
 		return
 	}
 
-	var allowed authorizer.Decision
-	if len(k.authz.pl) > 0 {
-		var reason string
-		var err error
-		allowed, reason, err = k.authz.Authorize(attrs)
-		klog.V(4).Infof("<<<< authorizeToken: %v, %v, %v\n", allowed, reason, err)
-		if err != nil {
-			http.Error(w, reason, http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// The operator didn't set authorization policy, deny by default.
-		allowed = authorizer.DecisionDeny
+	// The authorizers of the chain apply even without Keystone policies, which deny the requests by default
+	allowed, reason, denied, err := k.authz.authorize(attrs)
+	klog.V(4).Infof("<<<< authorizeToken: %v, %v, %v\n", allowed, reason, err)
+	if err != nil {
+		http.Error(w, reason, http.StatusInternalServerError)
+		return
 	}
 
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String(attrDecision, decisionString(allowed)))
+
 	delete(data, "spec")
-	data["status"] = map[string]interface{}{
+	status := map[string]interface{}{
 		"allowed": allowed == authorizer.DecisionAllow,
 	}
+	if denied {
+		// The authorizers after the webhook in the authorization modes of the API server, e.g. RBAC, are not consulted
+		status["denied"] = true
+		status["reason"] = reason
+	}
+	data["status"] = status
 	output, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -411,8 +499,18 @@ func NewKeystoneAuth(c *Config) (*Auth, error) {
 		return nil, fmt.Errorf("failed to initialize keystone client: %v", err)
 	}
 
+	// The policy file is read first, the namespace-label-deny authorizers need the Kubernetes client.
+	var policy policyConfig
+	if c.PolicyFile != "" {
+		cfg, err := newPolicyConfigFromFile(c.PolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract policy from policy file %s: %v", c.PolicyFile, err)
+		}
+		policy = *cfg
+	}
+
 	var k8sClient *kubernetes.Clientset
-	if c.PolicyConfigMapName != "" || c.SyncConfigMapName != "" || c.SyncConfigFile != "" {
+	if c.PolicyConfigMapName != "" || c.SyncConfigMapName != "" || c.SyncConfigFile != "" || c.ProjectSyncConfigFile != "" || policy.requiresNamespaces() {
 		k8sClient, err = createKubernetesClient(c.Kubeconfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get kubernetes client: %v", err)
@@ -422,8 +520,7 @@ func NewKeystoneAuth(c *Config) (*Auth, error) {
 	// Get policy definition either from a policy file or the policy configmap. Policy file takes precedence
 	// over the configmap, but the policy definition will be refreshed based on the configmap change on-the-fly. It
 	// is possible that both are not provided, in this case, the keystone webhook authorization will always return deny.
-	var policy policyList
-	if c.PolicyConfigMapName != "" {
+	if c.PolicyConfigMapName != "" && c.PolicyFile == "" {
 		cm, err := k8sClient.CoreV1().ConfigMaps(cmNamespace).Get(context.TODO(), c.PolicyConfigMapName, metav1.GetOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to get configmap %s: %v", c.PolicyConfigMapName, err)
@@ -433,15 +530,9 @@ func NewKeystoneAuth(c *Config) (*Auth, error) {
 			return nil, fmt.Errorf("failed to parse policies defined in the configmap %s: %v", c.PolicyConfigMapName, err)
 		}
 	}
-	if c.PolicyFile != "" {
-		policy, err = newFromFile(c.PolicyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to extract policy from policy file %s: %v", c.PolicyFile, err)
-		}
-	}
 
-	if len(policy) > 0 {
-		output, err := json.MarshalIndent(policy, "", "  ")
+	if len(policy.Policies) > 0 {
+		output, err := json.MarshalIndent(policy.Policies, "", "  ")
 		if err == nil {
 			klog.V(4).Infof("Policy %s", string(output))
 		} else {
@@ -481,18 +572,60 @@ func NewKeystoneAuth(c *Config) (*Auth, error) {
 		}
 	}
 
+	var projectSyncer *ProjectSyncer
+	if c.ProjectSyncConfigFile != "" {
+		psc, err := newProjectSyncConfigFromFile(c.ProjectSyncConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract data from project sync config file %s: %v", c.ProjectSyncConfigFile, err)
+		}
+		projectSyncer, err = newProjectSyncer(context.TODO(), psc, k8sClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	authn := &Authenticator{keystoner: NewKeystoner(keystoneClient), federationGroupPrefixes: c.FederationGroupPrefixes}
+	if c.TokenCacheTTL > 0 {
+		klog.Infof("Token cache enabled with TTL %v and size %d", c.TokenCacheTTL, c.TokenCacheSize)
+		authn.cache = newTokenCache(c.TokenCacheTTL, c.TokenCacheSize)
+		RegisterTokenCacheMetrics()
+	}
+
+	tp, err := newTracerProvider(context.TODO(), c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the tracer provider: %v", err)
+	}
+	if c.TracingEndpoint != "" {
+		klog.Infof("Exporting the traces to %s", c.TracingEndpoint)
+	}
+
+	authz := &Authorizer{authURL: c.KeystoneURL, client: keystoneClient}
+	var kubeInformerFactory informers.SharedInformerFactory
+	if k8sClient != nil {
+		kubeInformerFactory = informers.NewSharedInformerFactory(k8sClient, time.Minute*5)
+		// The namespaces are only watched for the namespace-label-deny authorizers of the initial policy, the
+		// ClusterRole of the webhook may not allow it otherwise
+		if policy.requiresNamespaces() {
+			authz.namespaces = kubeInformerFactory.Core().V1().Namespaces().Lister()
+		}
+	}
+	if err := authz.setPolicy(&policy); err != nil {
+		return nil, fmt.Errorf("invalid authorizers in the policy: %v", err)
+	}
+
 	keystoneAuth := &Auth{
-		authn:     &Authenticator{keystoner: NewKeystoner(keystoneClient)},
-		authz:     &Authorizer{authURL: c.KeystoneURL, client: keystoneClient, pl: policy},
-		syncer:    &Syncer{k8sClient: k8sClient, syncConfig: sc},
-		k8sClient: k8sClient,
-		config:    c,
-		stopCh:    make(chan struct{}),
+		authn:          authn,
+		authz:          authz,
+		syncer:         &Syncer{k8sClient: k8sClient, syncConfig: sc},
+		projectSyncer:  projectSyncer,
+		k8sClient:      k8sClient,
+		config:         c,
+		stopCh:         make(chan struct{}),
+		tracerProvider: tp,
 	}
 
 	if k8sClient != nil {
 		queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[any]())
-		kubeInformerFactory := informers.NewSharedInformerFactory(k8sClient, time.Minute*5)
 		cmInformer := kubeInformerFactory.Core().V1().ConfigMaps()
 		_, err := cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 			AddFunc: keystoneAuth.enqueueConfigMap,
@@ -515,12 +648,28 @@ func NewKeystoneAuth(c *Config) (*Auth, error) {
 		keystoneAuth.informer = kubeInformerFactory
 		keystoneAuth.cmLister = cmInformer.Lister()
 		keystoneAuth.cmListerSynced = cmInformer.Informer().HasSynced
+		if authz.namespaces != nil {
+			nsSynced := kubeInformerFactory.Core().V1().Namespaces().Informer().HasSynced
+			keystoneAuth.cmListerSynced = func() bool { return cmInformer.Informer().HasSynced() && nsSynced() }
+		}
 		keystoneAuth.queue = queue
 	}
 
 	return keystoneAuth, nil
 }
 
+// decisionString returns the name of the authorization decision.
+func decisionString(decision authorizer.Decision) string {
+	switch decision {
+	case authorizer.DecisionAllow:
+		return "allow"
+	case authorizer.DecisionNoOpinion:
+		return "no-opinion"
+	default:
+		return "deny"
+	}
+}
+
 func getField(data map[string]interface{}, name string) string {
 	if v, ok := data[name]; ok {
 		return v.(string)
@@ -596,8 +745,11 @@ func createKeystoneClient(authURL string, caFile string) (*gophercloud.ServiceCl
 		config.RootCAs = roots
 		transport = netutil.SetOldTransportDefaults(&http.Transport{TLSClientConfig: config})
 	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
 	opts := gophercloud.AuthOptions{IdentityEndpoint: authURL}
-	provider, err := createIdentityV3Provider(opts, transport)
+	provider, err := createIdentityV3Provider(opts, &requestIDTransport{base: transport})
 	if err != nil {
 		return nil, err
 	}
@@ -2,20 +2,24 @@
 
 package keystone
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // MockIKeystone is an autogenerated mock type for the IKeystone type
 type MockIKeystone struct {
 	mock.Mock
 }
 
-// GetGroups provides a mock function with given fields: _a0, _a1
-func (_m *MockIKeystone) GetGroups(_a0 string, _a1 string) ([]string, error) {
-	ret := _m.Called(_a0, _a1)
+// GetGroups provides a mock function with given fields: _a0, _a1, _a2
+func (_m *MockIKeystone) GetGroups(_a0 context.Context, _a1 string, _a2 string) ([]string, error) {
+	ret := _m.Called(_a0, _a1, _a2)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(string, string) []string); ok {
-		r0 = rf(_a0, _a1)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(_a0, _a1, _a2)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]string)
@@ -23,8 +27,8 @@ func (_m *MockIKeystone) GetGroups(_a0 string, _a1 string) ([]string, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(_a0, _a1)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -32,13 +36,36 @@ func (_m *MockIKeystone) GetGroups(_a0 string, _a1 string) ([]string, error) {
 	return r0, r1
 }
 
-// GetTokenInfo provides a mock function with given fields: _a0
-func (_m *MockIKeystone) GetTokenInfo(_a0 string) (*tokenInfo, error) {
-	ret := _m.Called(_a0)
+// GetGroupNames provides a mock function with given fields: _a0, _a1, _a2
+func (_m *MockIKeystone) GetGroupNames(_a0 context.Context, _a1 string, _a2 []string) ([]string, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) []string); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTokenInfo provides a mock function with given fields: _a0, _a1
+func (_m *MockIKeystone) GetTokenInfo(_a0 context.Context, _a1 string) (*tokenInfo, error) {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 *tokenInfo
-	if rf, ok := ret.Get(0).(func(string) *tokenInfo); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *tokenInfo); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*tokenInfo)
@@ -46,8 +73,8 @@ func (_m *MockIKeystone) GetTokenInfo(_a0 string) (*tokenInfo, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -19,7 +19,11 @@ package keystone
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"os"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
 )
 
 type policy struct {
@@ -33,6 +37,10 @@ type policy struct {
 
 	NonResourcePermissionsSpec map[string][]string `json:"nonresource_permissions,omitempty"`
 
+	// StrictSubresources restricts the keys of ResourcePermissionsSpec without subresource to the resources
+	// themselves, e.g. "default/pods" doesn't grant "pods/exec". Otherwise they also match all the subresources.
+	StrictSubresources bool `json:"strict_subresources,omitempty"`
+
 	Users map[string][]string `json:"users"`
 }
 
@@ -83,13 +91,22 @@ type policyList []*policy
 
 // newFromFile loads a list of policies from a file
 func newFromFile(path string) (policyList, error) {
+	cfg, err := newPolicyConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Policies, nil
+}
+
+// newPolicyConfigFromFile loads the policies and the authorizers chained before them from a file
+func newPolicyConfigFromFile(path string) (*policyConfig, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	var data policyList
+	var data policyConfig
 
 	reader := bufio.NewReader(file)
 	decoder := json.NewDecoder(reader)
@@ -97,5 +114,93 @@ func newFromFile(path string) (policyList, error) {
 	if err != nil {
 		return nil, err
 	}
-	return data, nil
+	return &data, nil
+}
+
+// PolicyRequest is a synthetic request to evaluate a policy against, it is a resource request unless Path is set.
+type PolicyRequest struct {
+	User        string
+	Groups      []string
+	ProjectID   string
+	ProjectName string
+	Roles       []string
+
+	Verb        string
+	APIGroup    string
+	Namespace   string
+	Resource    string
+	Subresource string
+	Path        string
+}
+
+// PolicyDecision is the result of evaluating a policy.
+type PolicyDecision struct {
+	Allowed bool
+	Reason  string
+	// Rule is the index of the policy rule allowing the request, -1 if the request is denied.
+	Rule int
+	// Policy is the policy rule allowing the request, formatted as JSON.
+	Policy string
+}
+
+// EvaluatePolicyFile evaluates the policy file against the request, the same way the authorization webhook does. The
+// authorizers chained before the Keystone policy are not evaluated, they depend on the time and on the cluster.
+func EvaluatePolicyFile(path string, req PolicyRequest) (*PolicyDecision, error) {
+	pl, err := newFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %v", path, err)
+	}
+
+	return pl.evaluate(req)
+}
+
+func (pl policyList) evaluate(req PolicyRequest) (*PolicyDecision, error) {
+	if req.Verb == "" {
+		return nil, fmt.Errorf("verb must be set")
+	}
+	if (req.Path == "") == (req.Resource == "") {
+		return nil, fmt.Errorf("either a resource or a non-resource path must be set")
+	}
+
+	extra := map[string][]string{}
+	if req.ProjectID != "" {
+		extra[ProjectID] = []string{req.ProjectID}
+	}
+	if req.ProjectName != "" {
+		extra[ProjectName] = []string{req.ProjectName}
+	}
+	if len(req.Roles) > 0 {
+		extra[Roles] = req.Roles
+	}
+
+	attrs := authorizer.AttributesRecord{
+		User: &user.DefaultInfo{
+			Name:   req.User,
+			Groups: req.Groups,
+			Extra:  extra,
+		},
+		Verb:            req.Verb,
+		APIGroup:        req.APIGroup,
+		Namespace:       req.Namespace,
+		Resource:        req.Resource,
+		Subresource:     req.Subresource,
+		ResourceRequest: req.Path == "",
+		Path:            req.Path,
+	}
+
+	rule, decision, reason := pl.authorize(attrs)
+	result := &PolicyDecision{
+		Allowed: decision == authorizer.DecisionAllow,
+		Reason:  reason,
+		Rule:    rule,
+	}
+	if rule >= 0 {
+		output, err := json.MarshalIndent(pl[rule], "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		result.Policy = string(output)
+	}
+
+	return result, nil
 }
@@ -0,0 +1,462 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/roles"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/version"
+)
+
+const (
+	// ProjectSyncManagedByLabel is set on the namespaces and the role bindings created by the project sync controller.
+	ProjectSyncManagedByLabel = "app.kubernetes.io/managed-by"
+	// ProjectSyncProjectIDLabel is the ID of the Keystone project of the namespaces created by the project sync
+	// controller.
+	ProjectSyncProjectIDLabel = "keystone.openstack.org/project-id"
+
+	projectSyncManager = "k8s-keystone-auth"
+
+	// projectSyncLeaseName is the name of the Lease electing the replica of k8s-keystone-auth running the project sync
+	// controller.
+	projectSyncLeaseName = "k8s-keystone-auth-project-sync"
+)
+
+type roleBindingMap struct {
+	KeystoneRole string `yaml:"keystone-role"`
+	ClusterRole  string `yaml:"cluster-role"`
+}
+
+// projectSyncConfig configures the controller synchronizing the Keystone projects with the Kubernetes namespaces
+type projectSyncConfig struct {
+	// Name of the cloud of clouds.yaml with the credentials listing the projects and the role assignments. The OS_*
+	// environment variables are used when empty.
+	Cloud string `yaml:"cloud"`
+
+	// Interval between the synchronizations.
+	Interval time.Duration `yaml:"interval"`
+
+	// Format of the namespace names, see syncConfig.
+	NamespaceFormat string `yaml:"namespace-format"`
+
+	// Only the projects of the domain are synchronized when set.
+	DomainID string `yaml:"domain-id"`
+
+	// Only the projects with all the tags are synchronized when set.
+	ProjectTags []string `yaml:"project-tags"`
+
+	// List of project ids to exclude from syncing.
+	ProjectBlackList []string `yaml:"projects-blacklist"`
+
+	// Labels of the created namespaces.
+	NamespaceLabels map[string]string `yaml:"namespace-labels"`
+
+	// List of mappings of the Keystone roles to the cluster roles bound to their users in the namespaces.
+	RoleBindings []*roleBindingMap `yaml:"role-bindings"`
+
+	// Whether the namespaces created for the deleted projects are deleted.
+	PruneNamespaces bool `yaml:"prune-namespaces"`
+
+	// Namespace of the Lease electing the replica running the controller.
+	LeaseNamespace string `yaml:"lease-namespace"`
+}
+
+func (c *projectSyncConfig) validate() error {
+	if c.Interval < time.Minute {
+		return fmt.Errorf("interval must be at least 1m")
+	}
+	if err := (&syncConfig{NamespaceFormat: c.NamespaceFormat}).validate(); err != nil {
+		return err
+	}
+	for _, m := range c.RoleBindings {
+		if m.KeystoneRole == "" || m.ClusterRole == "" {
+			return fmt.Errorf("role-bindings must have a keystone-role and a cluster-role")
+		}
+	}
+	return nil
+}
+
+// formatNamespaceName generates a namespace name, based on format string
+func (c *projectSyncConfig) formatNamespaceName(p *projects.Project) string {
+	return (&syncConfig{NamespaceFormat: c.NamespaceFormat}).formatNamespaceName(p.ID, p.Name, p.DomainID)
+}
+
+// newProjectSyncConfigFromFile loads a project sync config from a file
+func newProjectSyncConfigFromFile(path string) (*projectSyncConfig, error) {
+	c := &projectSyncConfig{
+		Interval:        10 * time.Minute,
+		NamespaceFormat: "%i",
+		LeaseNamespace:  "kube-system",
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ProjectSyncer periodically creates a namespace per Keystone project and binds the cluster roles mapped to the
+// Keystone roles to the users assigned them in the project.
+type ProjectSyncer struct {
+	identity  *gophercloud.ServiceClient
+	k8sClient kubernetes.Interface
+	config    *projectSyncConfig
+}
+
+// newProjectSyncer authenticates with the credentials of the cloud of the config.
+func newProjectSyncer(ctx context.Context, config *projectSyncConfig, k8sClient kubernetes.Interface) (*ProjectSyncer, error) {
+	options, err := CloudOptions(config.Cloud)
+	if err != nil {
+		return nil, err
+	}
+	options.AuthOptions.AllowReauth = true
+
+	provider, err := newIdentityProvider(options, fmt.Sprintf("k8s-keystone-auth/%s", version.Version))
+	if err != nil {
+		return nil, err
+	}
+	if err := openstack.Authenticate(ctx, provider, options.AuthOptions); err != nil {
+		return nil, fmt.Errorf("failed to authenticate the project sync controller: %v", err)
+	}
+	client, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the identity client: %v", err)
+	}
+
+	return &ProjectSyncer{identity: client, k8sClient: k8sClient, config: config}, nil
+}
+
+// Run synchronizes the projects until the channel is closed. Only the replica of k8s-keystone-auth holding the Lease
+// synchronizes the projects, the others wait to take it over.
+func (s *ProjectSyncer) Run(stopCh <-chan struct{}) {
+	ctx := wait.ContextForChannel(stopCh)
+
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Errorf("Failed to start the project sync controller: %v", err)
+		return
+	}
+	// add a uniquifier so that two processes on the same host don't accidentally both become active
+	id = id + "_" + string(uuid.NewUUID())
+
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, s.config.LeaseNamespace, projectSyncLeaseName,
+		s.k8sClient.CoreV1(), s.k8sClient.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		klog.Errorf("Failed to start the project sync controller: %v", err)
+		return
+	}
+
+	// The Lease is campaigned for again when it is lost
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   60 * time.Second,
+			RenewDeadline:   30 * time.Second,
+			RetryPeriod:     10 * time.Second,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: s.run,
+				OnStoppedLeading: func() {
+					klog.Infof("Stopped leading the project sync controller")
+				},
+			},
+			Name: projectSyncLeaseName,
+		})
+	}, time.Second)
+}
+
+func (s *ProjectSyncer) run(ctx context.Context) {
+	klog.Infof("Starting the project sync controller, synchronizing every %v", s.config.Interval)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.sync(ctx); err != nil {
+			klog.Errorf("Failed to synchronize the Keystone projects: %v", err)
+		}
+	}, s.config.Interval)
+}
+
+// hasProjectTags checks whether the project has all the tags of the config.
+func (c *projectSyncConfig) hasProjectTags(p *projects.Project) bool {
+	for _, tag := range c.ProjectTags {
+		if !slices.Contains(p.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ProjectSyncer) sync(ctx context.Context) error {
+	// All the projects of the domain are listed, the tags are filtered here so that the namespaces of the projects
+	// which are only filtered out aren't pruned.
+	opts := projects.ListOpts{DomainID: s.config.DomainID}
+	pages, err := projects.List(s.identity, opts).AllPages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the projects: %v", err)
+	}
+	projectList, err := projects.ExtractProjects(pages)
+	if err != nil {
+		return fmt.Errorf("failed to list the projects: %v", err)
+	}
+
+	var errs []error
+	// The namespaces of the disabled, blacklisted and filtered out projects are kept, only the namespaces of the
+	// deleted projects are pruned.
+	existing := sets.New[string]()
+	synced := sets.New[string]()
+	for i := range projectList {
+		p := &projectList[i]
+		existing.Insert(p.ID)
+		if !p.Enabled || slices.Contains(s.config.ProjectBlackList, p.ID) || !s.config.hasProjectTags(p) {
+			continue
+		}
+		synced.Insert(p.ID)
+		if err := s.syncProject(ctx, p); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %v", p.ID, err))
+		}
+	}
+
+	if err := s.revokeRoleBindings(ctx, synced); err != nil {
+		errs = append(errs, err)
+	}
+	if s.config.PruneNamespaces {
+		if err := s.pruneNamespaces(ctx, existing); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s *ProjectSyncer) syncProject(ctx context.Context, p *projects.Project) error {
+	namespaceName := s.config.formatNamespaceName(p)
+
+	ns, err := s.k8sClient.CoreV1().Namespaces().Get(ctx, namespaceName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		labels := map[string]string{}
+		for k, v := range s.config.NamespaceLabels {
+			labels[k] = v
+		}
+		labels[ProjectSyncManagedByLabel] = projectSyncManager
+		labels[ProjectSyncProjectIDLabel] = p.ID
+
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespaceName, Labels: labels}}
+		if ns, err = s.k8sClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create namespace %s: %v", namespaceName, err)
+		}
+		klog.Infof("Created namespace %s for project %s", namespaceName, p.ID)
+	} else if err != nil {
+		return fmt.Errorf("failed to get namespace %s: %v", namespaceName, err)
+	}
+
+	// Only the namespaces labelled by the controller are managed, the administrators opt in the existing namespaces
+	// by labelling them.
+	if id, ok := ns.Labels[ProjectSyncProjectIDLabel]; ok && id != p.ID {
+		return fmt.Errorf("namespace %s belongs to project %s", namespaceName, id)
+	}
+	if ns.Labels[ProjectSyncManagedByLabel] != projectSyncManager || ns.Labels[ProjectSyncProjectIDLabel] != p.ID {
+		return fmt.Errorf("namespace %s is not managed by the project sync controller, label it with %s=%s and %s=%s to adopt it",
+			namespaceName, ProjectSyncManagedByLabel, projectSyncManager, ProjectSyncProjectIDLabel, p.ID)
+	}
+
+	if len(s.config.RoleBindings) == 0 {
+		return nil
+	}
+	return s.syncRoleBindings(ctx, p.ID, namespaceName)
+}
+
+// projectRoleUsers returns the names of the users assigned each role in the project, directly or through their groups.
+func (s *ProjectSyncer) projectRoleUsers(ctx context.Context, projectID string) (map[string]sets.Set[string], error) {
+	opts := roles.ListAssignmentsOpts{
+		ScopeProjectID: projectID,
+		Effective:      gophercloud.Enabled,
+		IncludeNames:   gophercloud.Enabled,
+	}
+	pages, err := roles.ListAssignments(s.identity, opts).AllPages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the role assignments: %v", err)
+	}
+	assignments, err := roles.ExtractRoleAssignments(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the role assignments: %v", err)
+	}
+
+	users := map[string]sets.Set[string]{}
+	for _, a := range assignments {
+		if a.User.Name == "" {
+			continue
+		}
+		if users[a.Role.Name] == nil {
+			users[a.Role.Name] = sets.New[string]()
+		}
+		users[a.Role.Name].Insert(a.User.Name)
+	}
+	return users, nil
+}
+
+// projectRoleBindingName returns the name of the role binding of a role mapping.
+func projectRoleBindingName(m *roleBindingMap) string {
+	return fmt.Sprintf("keystone:%s:%s", m.KeystoneRole, m.ClusterRole)
+}
+
+func (s *ProjectSyncer) syncRoleBindings(ctx context.Context, projectID, namespaceName string) error {
+	users, err := s.projectRoleUsers(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]*rbacv1.RoleBinding{}
+	for _, m := range s.config.RoleBindings {
+		if users[m.KeystoneRole].Len() == 0 {
+			continue
+		}
+		rb := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   projectRoleBindingName(m),
+				Labels: map[string]string{ProjectSyncManagedByLabel: projectSyncManager},
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     m.ClusterRole,
+			},
+		}
+		for _, name := range sets.List(users[m.KeystoneRole]) {
+			rb.Subjects = append(rb.Subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: name})
+		}
+		desired[rb.Name] = rb
+	}
+
+	client := s.k8sClient.RbacV1().RoleBindings(namespaceName)
+	existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ProjectSyncManagedByLabel + "=" + projectSyncManager})
+	if err != nil {
+		return fmt.Errorf("failed to list the role bindings of namespace %s: %v", namespaceName, err)
+	}
+
+	for i := range existing.Items {
+		current := &existing.Items[i]
+		rb, ok := desired[current.Name]
+		delete(desired, current.Name)
+
+		switch {
+		case !ok || current.RoleRef != rb.RoleRef:
+			// The role of a role binding can't be changed, it is created again
+			if err := client.Delete(ctx, current.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete role binding %s/%s: %v", namespaceName, current.Name, err)
+			}
+			klog.V(2).Infof("Deleted role binding %s/%s", namespaceName, current.Name)
+			if ok {
+				desired[rb.Name] = rb
+			}
+		case !slices.Equal(current.Subjects, rb.Subjects):
+			current.Subjects = rb.Subjects
+			if _, err := client.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("failed to update role binding %s/%s: %v", namespaceName, current.Name, err)
+			}
+			klog.V(2).Infof("Updated the users of role binding %s/%s", namespaceName, current.Name)
+		}
+	}
+
+	for _, rb := range desired {
+		if _, err := client.Create(ctx, rb, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create role binding %s/%s: %v", namespaceName, rb.Name, err)
+		}
+		klog.V(2).Infof("Created role binding %s/%s", namespaceName, rb.Name)
+	}
+	return nil
+}
+
+// revokeRoleBindings deletes the role bindings created in the namespaces of the projects which aren't synchronized
+// anymore. The users removed from these projects in Keystone would keep their roles in the namespaces otherwise.
+func (s *ProjectSyncer) revokeRoleBindings(ctx context.Context, synced sets.Set[string]) error {
+	namespaces, err := s.k8sClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s", ProjectSyncManagedByLabel, projectSyncManager, ProjectSyncProjectIDLabel),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the namespaces: %v", err)
+	}
+
+	var errs []error
+	for _, ns := range namespaces.Items {
+		if synced.Has(ns.Labels[ProjectSyncProjectIDLabel]) || ns.DeletionTimestamp != nil {
+			continue
+		}
+		client := s.k8sClient.RbacV1().RoleBindings(ns.Name)
+		existing, err := client.List(ctx, metav1.ListOptions{LabelSelector: ProjectSyncManagedByLabel + "=" + projectSyncManager})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list the role bindings of namespace %s: %v", ns.Name, err))
+			continue
+		}
+		for _, rb := range existing.Items {
+			if err := client.Delete(ctx, rb.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete role binding %s/%s: %v", ns.Name, rb.Name, err))
+				continue
+			}
+			klog.Infof("Deleted role binding %s/%s of project %s, which isn't synchronized", ns.Name, rb.Name, ns.Labels[ProjectSyncProjectIDLabel])
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// pruneNamespaces deletes the namespaces created for the deleted projects.
+func (s *ProjectSyncer) pruneNamespaces(ctx context.Context, existing sets.Set[string]) error {
+	namespaces, err := s.k8sClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s", ProjectSyncManagedByLabel, projectSyncManager, ProjectSyncProjectIDLabel),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the namespaces: %v", err)
+	}
+
+	var errs []error
+	for _, ns := range namespaces.Items {
+		if existing.Has(ns.Labels[ProjectSyncProjectIDLabel]) || ns.DeletionTimestamp != nil {
+			continue
+		}
+		if err := s.k8sClient.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete namespace %s: %v", ns.Name, err))
+			continue
+		}
+		klog.Infof("Deleted namespace %s of project %s", ns.Name, ns.Labels[ProjectSyncProjectIDLabel])
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProjectSyncConfigFromFile(t *testing.T) {
+	c, err := newProjectSyncConfigFromFile("project_sync_test.yaml")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "kubernetes-sync", c.Cloud)
+	th.AssertEquals(t, 5*time.Minute, c.Interval)
+	th.AssertEquals(t, "%n-%i", c.NamespaceFormat)
+	th.AssertEquals(t, "default", c.DomainID)
+	th.AssertEquals(t, "kubernetes", c.ProjectTags[0])
+	th.AssertEquals(t, "id1", c.ProjectBlackList[0])
+	th.AssertEquals(t, "keystone", c.NamespaceLabels["team"])
+	th.AssertEquals(t, 2, len(c.RoleBindings))
+	th.AssertEquals(t, "member", c.RoleBindings[0].KeystoneRole)
+	th.AssertEquals(t, "edit", c.RoleBindings[0].ClusterRole)
+	th.AssertEquals(t, true, c.PruneNamespaces)
+}
+
+func TestProjectSyncConfigValidation(t *testing.T) {
+	c := &projectSyncConfig{Interval: 10 * time.Minute, NamespaceFormat: "%i"}
+	th.AssertNoErr(t, c.validate())
+
+	c.NamespaceFormat = "%n"
+	th.AssertEquals(t, true, c.validate() != nil)
+
+	c.NamespaceFormat = "%i"
+	c.Interval = time.Second
+	th.AssertEquals(t, true, c.validate() != nil)
+
+	c.Interval = 10 * time.Minute
+	c.RoleBindings = []*roleBindingMap{{KeystoneRole: "member"}}
+	th.AssertEquals(t, true, c.validate() != nil)
+}
+
+func TestProjectSyncer(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		th.TestFormValues(t, r, map[string]string{"domain_id": "default"})
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"projects": [
+			{"id": "p1", "name": "demo", "domain_id": "default", "enabled": true, "tags": ["kubernetes", "prod"]},
+			{"id": "p2", "name": "disabled", "domain_id": "default", "enabled": false, "tags": ["kubernetes"]},
+			{"id": "p3", "name": "untagged", "domain_id": "default", "enabled": true, "tags": []},
+			{"id": "id1", "name": "blacklisted", "domain_id": "default", "enabled": true, "tags": ["kubernetes"]}
+		], "links": {}}`)
+	})
+	th.Mux.HandleFunc("/role_assignments", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		th.TestFormValues(t, r, map[string]string{"scope.project.id": "p1", "effective": "true", "include_names": "true"})
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"role_assignments": [
+			{"role": {"id": "r1", "name": "member"}, "user": {"id": "u2", "name": "bob"}, "scope": {"project": {"id": "p1"}}},
+			{"role": {"id": "r1", "name": "member"}, "user": {"id": "u1", "name": "alice"}, "scope": {"project": {"id": "p1"}}},
+			{"role": {"id": "r2", "name": "reader"}, "user": {"id": "u3", "name": "carol"}, "scope": {"project": {"id": "p1"}}}
+		], "links": {}}`)
+	})
+
+	managed := map[string]string{ProjectSyncManagedByLabel: projectSyncManager}
+	k8sClient := fake.NewSimpleClientset(
+		// The role binding of a role no longer mapped
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "keystone:admin:admin", Namespace: "demo-p1", Labels: managed},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+		},
+		// The role binding of the users who are no longer readers
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "keystone:reader:view", Namespace: "demo-p1", Labels: managed},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"},
+			Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "dave"}},
+		},
+		// A role binding created by the administrators
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "demo-p1"}},
+		// The role bindings of a project no longer synchronized are revoked, but the ones of the administrators
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "keystone:member:edit", Namespace: "disabled-p2", Labels: managed},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+			Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "alice"}},
+		},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "disabled-p2"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "disabled-p2", Labels: map[string]string{
+			ProjectSyncManagedByLabel: projectSyncManager, ProjectSyncProjectIDLabel: "p2",
+		}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "deleted-p9", Labels: map[string]string{
+			ProjectSyncManagedByLabel: projectSyncManager, ProjectSyncProjectIDLabel: "p9",
+		}}},
+		// The namespaces of the projects filtered out aren't pruned
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "untagged-p3", Labels: map[string]string{
+			ProjectSyncManagedByLabel: projectSyncManager, ProjectSyncProjectIDLabel: "p3",
+		}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "blacklisted-id1", Labels: map[string]string{
+			ProjectSyncManagedByLabel: projectSyncManager, ProjectSyncProjectIDLabel: "id1",
+		}}},
+	)
+
+	config, err := newProjectSyncConfigFromFile("project_sync_test.yaml")
+	th.AssertNoErr(t, err)
+	s := &ProjectSyncer{identity: fakeclient.ServiceClient(), k8sClient: k8sClient, config: config}
+	th.AssertNoErr(t, s.sync(context.TODO()))
+
+	ns, err := k8sClient.CoreV1().Namespaces().Get(context.TODO(), "demo-p1", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "p1", ns.Labels[ProjectSyncProjectIDLabel])
+	th.AssertEquals(t, projectSyncManager, ns.Labels[ProjectSyncManagedByLabel])
+	th.AssertEquals(t, "keystone", ns.Labels["team"])
+
+	_, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "blacklisted-id1", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	_, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "untagged-p3", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	_, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "disabled-p2", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	rbs, err := k8sClient.RbacV1().RoleBindings("untagged-p3").List(context.TODO(), metav1.ListOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 0, len(rbs.Items))
+	_, err = k8sClient.RbacV1().RoleBindings("disabled-p2").Get(context.TODO(), "keystone:member:edit", metav1.GetOptions{})
+	th.AssertEquals(t, true, k8serrors.IsNotFound(err))
+	_, err = k8sClient.RbacV1().RoleBindings("disabled-p2").Get(context.TODO(), "custom", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	_, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "deleted-p9", metav1.GetOptions{})
+	th.AssertEquals(t, true, k8serrors.IsNotFound(err))
+
+	rb, err := k8sClient.RbacV1().RoleBindings("demo-p1").Get(context.TODO(), "keystone:member:edit", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "edit", rb.RoleRef.Name)
+	th.AssertDeepEquals(t, []rbacv1.Subject{
+		{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "alice"},
+		{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "bob"},
+	}, rb.Subjects)
+
+	rb, err = k8sClient.RbacV1().RoleBindings("demo-p1").Get(context.TODO(), "keystone:reader:view", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "carol"}}, rb.Subjects)
+
+	_, err = k8sClient.RbacV1().RoleBindings("demo-p1").Get(context.TODO(), "keystone:admin:admin", metav1.GetOptions{})
+	th.AssertEquals(t, true, k8serrors.IsNotFound(err))
+	_, err = k8sClient.RbacV1().RoleBindings("demo-p1").Get(context.TODO(), "custom", metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+
+	// A namespace of another project isn't adopted
+	k8sClient = fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "demo-p1", Labels: map[string]string{
+		ProjectSyncProjectIDLabel: "p3",
+	}}})
+	s = &ProjectSyncer{identity: fakeclient.ServiceClient(), k8sClient: k8sClient, config: config}
+	th.AssertEquals(t, true, s.sync(context.TODO()) != nil)
+	rbs, err = k8sClient.RbacV1().RoleBindings("demo-p1").List(context.TODO(), metav1.ListOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 0, len(rbs.Items))
+
+	// A namespace not labelled by the controller isn't adopted
+	k8sClient = fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "demo-p1"}})
+	s = &ProjectSyncer{identity: fakeclient.ServiceClient(), k8sClient: k8sClient, config: config}
+	th.AssertEquals(t, true, s.sync(context.TODO()) != nil)
+	rbs, err = k8sClient.RbacV1().RoleBindings("demo-p1").List(context.TODO(), metav1.ListOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 0, len(rbs.Items))
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/utils/lru"
+)
+
+var (
+	tokenCacheHits = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "keystone_auth_token_cache_hits_total",
+			Help: "Total number of token authentications served from the token cache",
+		})
+	tokenCacheMisses = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "keystone_auth_token_cache_misses_total",
+			Help: "Total number of token authentications not found in the token cache",
+		})
+
+	registerTokenCacheMetrics sync.Once
+)
+
+// RegisterTokenCacheMetrics registers the token cache metrics.
+func RegisterTokenCacheMetrics() {
+	registerTokenCacheMetrics.Do(func() {
+		legacyregistry.MustRegister(tokenCacheHits, tokenCacheMisses)
+	})
+}
+
+const (
+	// tokenCacheRevalidateInterval is the minimum time between the validations of a cached token by Keystone.
+	tokenCacheRevalidateInterval = 10 * time.Second
+	// tokenRevalidateTimeout bounds the validation of a cached token in the background.
+	tokenRevalidateTimeout = 30 * time.Second
+)
+
+type tokenCacheEntry struct {
+	user      *user.DefaultInfo
+	expiresAt time.Time
+
+	// validatedAt is the time Keystone last validated the token, revalidating is set while it's validated again.
+	// Both are guarded by the mutex of the cache.
+	validatedAt  time.Time
+	revalidating bool
+}
+
+// tokenCache is a LRU cache of the users authenticated by Keystone. The entries are keyed by the token hash, so that
+// the tokens themselves are never kept in memory, and expire after the TTL or when the token expires, whichever
+// comes first. The cached tokens are validated again by Keystone in the background, and removed once Keystone
+// rejects them, e.g. when they are revoked.
+type tokenCache struct {
+	cache *lru.Cache
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.Mutex
+}
+
+func newTokenCache(ttl time.Duration, size int) *tokenCache {
+	return &tokenCache{
+		cache: lru.New(size),
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+func tokenCacheKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// get returns the cached user of the token, if any. The user is a copy, so the callers are free to modify it.
+func (c *tokenCache) get(token string) (*user.DefaultInfo, bool) {
+	key := tokenCacheKey(token)
+
+	value, ok := c.cache.Get(key)
+	if !ok {
+		tokenCacheMisses.Inc()
+		return nil, false
+	}
+
+	entry := value.(*tokenCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.cache.Remove(key)
+		tokenCacheMisses.Inc()
+		return nil, false
+	}
+
+	tokenCacheHits.Inc()
+	return copyUserInfo(entry.user), true
+}
+
+// add caches the user of the token until the TTL elapses or the token expires.
+func (c *tokenCache) add(token string, info *user.DefaultInfo, tokenExpiresAt time.Time) {
+	expiresAt := c.now().Add(c.ttl)
+	if !tokenExpiresAt.IsZero() && tokenExpiresAt.Before(expiresAt) {
+		expiresAt = tokenExpiresAt
+	}
+
+	c.cache.Add(tokenCacheKey(token), &tokenCacheEntry{user: copyUserInfo(info), expiresAt: expiresAt, validatedAt: c.now()})
+}
+
+// startRevalidation checks whether the cached token must be validated again by Keystone, i.e. it was last validated
+// more than tokenCacheRevalidateInterval ago and isn't being validated. The caller must call finishRevalidation then.
+func (c *tokenCache) startRevalidation(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.cache.Get(tokenCacheKey(token))
+	if !ok {
+		return false
+	}
+	entry := value.(*tokenCacheEntry)
+	if entry.revalidating || c.now().Sub(entry.validatedAt) < tokenCacheRevalidateInterval {
+		return false
+	}
+	entry.revalidating = true
+	return true
+}
+
+// finishRevalidation records the result of the validation of a cached token. The token is removed when Keystone
+// rejected it, and kept on the other errors, e.g. when Keystone is unavailable, to be validated again.
+func (c *tokenCache) finishRevalidation(token string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := tokenCacheKey(token)
+	if isInvalidTokenError(err) {
+		c.cache.Remove(key)
+		return
+	}
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return
+	}
+	entry := value.(*tokenCacheEntry)
+	entry.revalidating = false
+	if err == nil {
+		entry.validatedAt = c.now()
+	}
+}
+
+// isInvalidTokenError checks whether Keystone rejected the token, it answers 404 to the validation of a revoked or
+// expired token.
+func isInvalidTokenError(err error) bool {
+	return gophercloud.ResponseCodeIs(err, http.StatusUnauthorized) || gophercloud.ResponseCodeIs(err, http.StatusNotFound)
+}
+
+func copyUserInfo(info *user.DefaultInfo) *user.DefaultInfo {
+	extra := make(map[string][]string, len(info.Extra))
+	for k, v := range info.Extra {
+		extra[k] = slices.Clone(v)
+	}
+
+	return &user.DefaultInfo{
+		Name:   info.Name,
+		UID:    info.UID,
+		Groups: slices.Clone(info.Groups),
+		Extra:  extra,
+	}
+}
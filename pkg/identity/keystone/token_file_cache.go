@@ -0,0 +1,214 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tokens3 "github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// tokenFileCacheMinTTL is the minimum remaining lifetime of a cached token, so that a token is not returned to
+// client-go right before it expires.
+const tokenFileCacheMinTTL = time.Minute
+
+// tokenFileCacheSaltSize is the size of the random salt prefixing each cache file.
+const tokenFileCacheSaltSize = 16
+
+// tokenFileCacheInfo binds the keys derived from the credentials to the token cache.
+const tokenFileCacheInfo = "client-keystone-auth token cache"
+
+type tokenFileCacheEntry struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TokenFileCache caches the Keystone tokens on disk, one file per auth URL and user. The files are encrypted with a key
+// derived from the password or the application credential secret and the random salt of the file, so that a cached
+// token can only be read with the credentials used to issue it.
+type TokenFileCache struct {
+	dir string
+	now func() time.Time
+}
+
+// NewTokenFileCache returns a token cache storing its files in dir.
+func NewTokenFileCache(dir string) *TokenFileCache {
+	return &TokenFileCache{
+		dir: dir,
+		now: time.Now,
+	}
+}
+
+// tokenFileCacheKey returns the name of the cache file of the given credentials.
+func tokenFileCacheKey(options Options) string {
+	opts := options.AuthOptions
+	key := strings.Join([]string{
+		opts.IdentityEndpoint,
+		opts.UserID, opts.Username, opts.DomainID, opts.DomainName,
+		opts.TenantID, opts.TenantName,
+		opts.ApplicationCredentialID, opts.ApplicationCredentialName,
+	}, "\x00")
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// hasTokenFileCacheSecret checks whether the credentials hold a secret the cache files can be encrypted with.
+func hasTokenFileCacheSecret(options Options) bool {
+	return options.AuthOptions.ApplicationCredentialSecret != "" || options.AuthOptions.Password != ""
+}
+
+// tokenFileCacheAEAD returns the cipher of the cache file of the given credentials with the salt of the file. The key
+// is derived with HKDF from the application credential secrets, which are random, and with scrypt from the passwords,
+// which may be guessed.
+func tokenFileCacheAEAD(options Options, salt []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if secret := options.AuthOptions.ApplicationCredentialSecret; secret != "" {
+		if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), salt, []byte(tokenFileCacheInfo)), key); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		key, err = scrypt.Key([]byte(options.AuthOptions.Password), salt, 1<<15, 8, 1, len(key))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *TokenFileCache) path(options Options) string {
+	return filepath.Join(c.dir, tokenFileCacheKey(options))
+}
+
+// Get returns the cached token of the given credentials, nil when there is none or it expires soon.
+func (c *TokenFileCache) Get(options Options) (*tokens3.Token, error) {
+	if !hasTokenFileCacheSecret(options) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(c.path(options))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the token cache: %v", err)
+	}
+
+	if len(data) < tokenFileCacheSaltSize {
+		return nil, fmt.Errorf("invalid token cache file %s", c.path(options))
+	}
+	aead, err := tokenFileCacheAEAD(options, data[:tokenFileCacheSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[tokenFileCacheSaltSize:]
+
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("invalid token cache file %s", c.path(options))
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		// The credentials changed, the cached token will be replaced
+		return nil, nil
+	}
+
+	var entry tokenFileCacheEntry
+	if err := json.Unmarshal(plaintext, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse the token cache: %v", err)
+	}
+	if entry.Token == "" || !c.now().Add(tokenFileCacheMinTTL).Before(entry.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &tokens3.Token{ID: entry.Token, ExpiresAt: entry.ExpiresAt}, nil
+}
+
+// Set caches the token of the given credentials. Nothing is cached when the credentials hold no secret.
+func (c *TokenFileCache) Set(options Options, token *tokens3.Token) error {
+	if !hasTokenFileCacheSecret(options) {
+		return nil
+	}
+
+	salt := make([]byte, tokenFileCacheSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	aead, err := tokenFileCacheAEAD(options, salt)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(tokenFileCacheEntry{Token: token.ID, ExpiresAt: token.ExpiresAt})
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	// The file holds the salt, the nonce and the encrypted entry
+	data := aead.Seal(append(salt, nonce...), nonce, plaintext, nil)
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create the token cache directory: %v", err)
+	}
+	// Write to a temporary file first, so that concurrent invocations never read a partial file
+	f, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write the token cache: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the token cache: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write the token cache: %v", err)
+	}
+	if err := os.Rename(f.Name(), c.path(options)); err != nil {
+		return fmt.Errorf("failed to write the token cache: %v", err)
+	}
+	return nil
+}
+
+// Delete removes the cached token of the given credentials.
+func (c *TokenFileCache) Delete(options Options) error {
+	if err := os.Remove(c.path(options)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete the token cache: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	tokens3 "github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+)
+
+func TestTokenFileCache(t *testing.T) {
+	now := time.Now()
+	cache := NewTokenFileCache(t.TempDir())
+	cache.now = func() time.Time { return now }
+
+	options := Options{AuthOptions: gophercloud.AuthOptions{
+		IdentityEndpoint: "https://keystone/v3",
+		Username:         "user",
+		Password:         "password",
+	}}
+	expiresAt := now.Add(time.Hour).UTC()
+
+	token, err := cache.Get(options)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, token == nil)
+
+	th.AssertNoErr(t, cache.Set(options, &tokens3.Token{ID: "token", ExpiresAt: expiresAt}))
+	token, err = cache.Get(options)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "token", token.ID)
+	th.AssertEquals(t, true, expiresAt.Equal(token.ExpiresAt))
+
+	// The token is not stored in clear
+	data, err := os.ReadFile(cache.path(options))
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, strings.Contains(string(data), "token"))
+
+	// Another user doesn't get the token
+	other := options
+	other.AuthOptions.Username = "other"
+	token, err = cache.Get(other)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, token == nil)
+
+	// The token can't be read with another password
+	other = options
+	other.AuthOptions.Password = "other"
+	token, err = cache.Get(other)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, token == nil)
+
+	// The token is not returned when it's about to expire
+	now = expiresAt.Add(-tokenFileCacheMinTTL)
+	token, err = cache.Get(options)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, token == nil)
+
+	th.AssertNoErr(t, cache.Delete(options))
+	_, err = os.Stat(cache.path(options))
+	th.AssertEquals(t, true, os.IsNotExist(err))
+}
+
+func TestTokenFileCacheSalt(t *testing.T) {
+	cache := NewTokenFileCache(t.TempDir())
+	options := Options{AuthOptions: gophercloud.AuthOptions{
+		IdentityEndpoint:            "https://keystone/v3",
+		ApplicationCredentialID:     "appcred",
+		ApplicationCredentialSecret: "secret",
+	}}
+	token := &tokens3.Token{ID: "token", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Each file has its own salt, the same token is encrypted with another key
+	th.AssertNoErr(t, cache.Set(options, token))
+	first, err := os.ReadFile(cache.path(options))
+	th.AssertNoErr(t, err)
+	th.AssertNoErr(t, cache.Set(options, token))
+	second, err := os.ReadFile(cache.path(options))
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, false, bytes.Equal(first[:tokenFileCacheSaltSize], second[:tokenFileCacheSaltSize]))
+
+	cached, err := cache.Get(options)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "token", cached.ID)
+
+	// The token can't be read with another application credential secret
+	other := options
+	other.AuthOptions.ApplicationCredentialSecret = "other"
+	cached, err = cache.Get(other)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, true, cached == nil)
+}
+
+func TestTokenFileCacheWithoutSecret(t *testing.T) {
+	cache := NewTokenFileCache(t.TempDir())
+	options := Options{AuthOptions: gophercloud.AuthOptions{
+		IdentityEndpoint: "https://keystone/v3",
+		Username:         "user",
+	}}
+
+	th.AssertNoErr(t, cache.Set(options, &tokens3.Token{ID: "token", ExpiresAt: time.Now().Add(time.Hour)}))
+	_, err := os.Stat(cache.path(options))
+	th.AssertEquals(t, true, os.IsNotExist(err))
+}
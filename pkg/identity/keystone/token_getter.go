@@ -43,38 +43,60 @@ type Options struct {
 // GetToken creates a token by authenticate with keystone.
 func GetToken(options Options) (*tokens3.Token, error) {
 	var token *tokens3.Token
-	var setTransport bool
 
 	// Create new identity client
-	provider, err := openstack.NewClient(options.AuthOptions.IdentityEndpoint)
+	provider, err := newIdentityProvider(options, fmt.Sprintf("client-keystone-auth/%s", version.Version))
+	if err != nil {
+		return token, err
+	}
+
+	v3Client, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
 	if err != nil {
 		msg := fmt.Errorf("failed: Initializing openstack authentication client: %v", err)
 		return token, msg
 	}
+
+	// Issue new unscoped token
+	result := tokens3.Create(context.TODO(), v3Client, &options.AuthOptions)
+	if result.Err != nil {
+		return token, result.Err
+	}
+	token, err = result.ExtractToken()
+	if err != nil {
+		msg := fmt.Errorf("failed: Cannot extract the token from the response")
+		return token, msg
+	}
+
+	return token, nil
+}
+
+// newIdentityProvider creates the provider client of the Keystone endpoint of the options, using their client
+// certificate and CA.
+func newIdentityProvider(options Options, userAgent string) (*gophercloud.ProviderClient, error) {
+	provider, err := openstack.NewClient(options.AuthOptions.IdentityEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed: Initializing openstack authentication client: %v", err)
+	}
 	tlsConfig := &tls.Config{}
-	setTransport = false
+	setTransport := false
 
-	userAgent := gophercloud.UserAgent{}
-	userAgent.Prepend(fmt.Sprintf("client-keystone-auth/%s", version.Version))
-	provider.UserAgent = userAgent
+	provider.UserAgent = gophercloud.UserAgent{}
+	provider.UserAgent.Prepend(userAgent)
 
 	if options.ClientCertPath != "" && options.ClientKeyPath != "" {
 		clientCert, err := os.ReadFile(options.ClientCertPath)
 		if err != nil {
-			msg := fmt.Errorf("failed: Cannot read cert file: %v", err)
-			return token, msg
+			return nil, fmt.Errorf("failed: Cannot read cert file: %v", err)
 		}
 
 		clientKey, err := os.ReadFile(options.ClientKeyPath)
 		if err != nil {
-			msg := fmt.Errorf("failed: Cannot read key file: %v", err)
-			return token, msg
+			return nil, fmt.Errorf("failed: Cannot read key file: %v", err)
 		}
 
 		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
 		if err != nil {
-			msg := fmt.Errorf("failed: Cannot create keypair:: %v", err)
-			return token, msg
+			return nil, fmt.Errorf("failed: Cannot create keypair:: %v", err)
 		}
 		tlsConfig.Certificates = []tls.Certificate{cert}
 		setTransport = true
@@ -83,8 +105,7 @@ func GetToken(options Options) (*tokens3.Token, error) {
 	if options.ClientCAPath != "" {
 		roots, err := certutil.NewPool(options.ClientCAPath)
 		if err != nil {
-			msg := fmt.Errorf("failed: Cannot read CA file: %v", err)
-			return token, msg
+			return nil, fmt.Errorf("failed: Cannot read CA file: %v", err)
 		}
 
 		tlsConfig.RootCAs = roots
@@ -106,22 +127,5 @@ func GetToken(options Options) (*tokens3.Token, error) {
 		}
 	}
 
-	v3Client, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
-	if err != nil {
-		msg := fmt.Errorf("failed: Initializing openstack authentication client: %v", err)
-		return token, msg
-	}
-
-	// Issue new unscoped token
-	result := tokens3.Create(context.TODO(), v3Client, &options.AuthOptions)
-	if result.Err != nil {
-		return token, result.Err
-	}
-	token, err = result.ExtractToken()
-	if err != nil {
-		msg := fmt.Errorf("failed: Cannot extract the token from the response")
-		return token, msg
-	}
-
-	return token, nil
+	return provider, nil
 }
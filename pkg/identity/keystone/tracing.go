@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/component-base/tracing"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/version"
+)
+
+const (
+	tracerName = "k8s.io/cloud-provider-openstack/pkg/identity/keystone"
+
+	// webhookSpanName is the span of the webhook requests, the spans of the Keystone calls are its children.
+	webhookSpanName = "KeystoneWebhook"
+
+	attrRequestID       = "keystone.request_id"
+	attrReviewKind      = "keystone.review_kind"
+	attrDecision        = "keystone.decision"
+	attrTokenCacheHit   = "keystone.token_cache_hit"
+	attrKeystoneLatency = "keystone.latency_ms"
+
+	// tracingShutdownTimeout bounds the export of the remaining spans on exit.
+	tracingShutdownTimeout = 10 * time.Second
+)
+
+// requestIDKey is the context key of the request ID of the webhook request.
+type requestIDKey struct{}
+
+// withRequestID returns the context carrying the request ID, sent along with the Keystone calls made with it.
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// requestIDTransport sends the request ID of the context to Keystone: as X-Request-Id, and as the global request ID
+// of OpenStack when it's a UUID, so the Keystone logs are correlated with the traces and the apiserver audit events.
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID, ok := req.Context().Value(requestIDKey{}).(string)
+	if !ok || requestID == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Request-Id", requestID)
+	if id, err := uuid.Parse(requestID); err == nil {
+		req.Header.Set("X-OpenStack-Request-ID", "req-"+id.String())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newTracerProvider returns the provider exporting the spans to the OTLP collector configured by the flags, the spans
+// are dropped when no collector is configured.
+func newTracerProvider(ctx context.Context, c *Config) (tracing.TracerProvider, error) {
+	if c.TracingEndpoint == "" {
+		return tracing.NewNoopTracerProvider(), nil
+	}
+
+	conf := &tracingapi.TracingConfiguration{
+		Endpoint:               &c.TracingEndpoint,
+		SamplingRatePerMillion: &c.TracingSamplingRatePerMillion,
+	}
+	resourceOpts := []resource.Option{
+		resource.WithAttributes(
+			semconv.ServiceName("k8s-keystone-auth"),
+			semconv.ServiceVersion(version.Version),
+		),
+	}
+
+	return tracing.NewProvider(ctx, conf, nil, resourceOpts)
+}
+
+// shutdownTracerProvider exports the remaining spans of the provider.
+func shutdownTracerProvider(tp tracing.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+	if err := tp.Shutdown(ctx); err != nil {
+		klog.Errorf("Failed to shut down the tracer provider: %v", err)
+	}
+}
+
+// startSpan starts a child span of the span of the context, with the same provider.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// recordSpanError marks the span as failed with the error.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
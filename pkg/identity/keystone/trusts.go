@@ -0,0 +1,250 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/trusts"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/users"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/version"
+)
+
+// The components a trust can be created for.
+const (
+	ComponentOCCM      = "openstack-cloud-controller-manager"
+	ComponentCinderCSI = "cinder-csi-plugin"
+	ComponentManilaCSI = "manila-csi-plugin"
+)
+
+// componentTrustRoles are the minimal roles delegated to each component in the project.
+var componentTrustRoles = map[string][]string{
+	ComponentOCCM:      {"member", "load-balancer_member"},
+	ComponentCinderCSI: {"member"},
+	ComponentManilaCSI: {"member"},
+}
+
+// TrustComponents returns the components a trust can be created for.
+func TrustComponents() []string {
+	components := make([]string, 0, len(componentTrustRoles))
+	for component := range componentTrustRoles {
+		components = append(components, component)
+	}
+	slices.Sort(components)
+	return components
+}
+
+// ComponentTrustRoles returns the default roles delegated to the component.
+func ComponentTrustRoles(component string) ([]string, error) {
+	roles, ok := componentTrustRoles[component]
+	if !ok {
+		return nil, fmt.Errorf("unknown component %q, must be one of %s", component, strings.Join(TrustComponents(), ", "))
+	}
+	return slices.Clone(roles), nil
+}
+
+// TrustRequest describes the trust delegating the roles of the trustor to a component.
+type TrustRequest struct {
+	Component string
+	// TrusteeUserID is the user the component authenticates with. A user named after the component is created in
+	// the domain of the trustor when it is empty.
+	TrusteeUserID string
+	// Roles delegated to the trustee, the default roles of the component when empty.
+	Roles     []string
+	ExpiresAt *time.Time
+}
+
+// ComponentTrust is a trust created for a component.
+type ComponentTrust struct {
+	Component     string
+	TrustID       string
+	TrusteeUserID string
+	// TrusteePassword is only known when the trustee was created with the trust.
+	TrusteePassword string
+	ProjectID       string
+	Roles           []string
+}
+
+// TrustCreator creates the trusts of the components with the credentials of the trustor.
+type TrustCreator struct {
+	client        *gophercloud.ServiceClient
+	trustorUserID string
+	trustorDomain string
+	projectID     string
+	// TrusteePrefix is prepended to the name of the trustees created for the components.
+	TrusteePrefix string
+}
+
+// NewTrustCreator authenticates the trustor with the options, the trusts delegate its roles in the project it is
+// scoped to.
+func NewTrustCreator(ctx context.Context, options Options) (*TrustCreator, error) {
+	provider, err := newIdentityProvider(options, fmt.Sprintf("k8s-keystone-auth/%s", version.Version))
+	if err != nil {
+		return nil, err
+	}
+	if err := openstack.Authenticate(ctx, provider, options.AuthOptions); err != nil {
+		return nil, fmt.Errorf("failed to authenticate the trustor: %v", err)
+	}
+	client, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the identity client: %v", err)
+	}
+
+	result, ok := provider.GetAuthResult().(tokens.CreateResult)
+	if !ok {
+		return nil, fmt.Errorf("failed to get the token of the trustor")
+	}
+	return newTrustCreator(client, result)
+}
+
+func newTrustCreator(client *gophercloud.ServiceClient, result tokens.CreateResult) (*TrustCreator, error) {
+	user, err := result.ExtractUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the trustor from its token: %v", err)
+	}
+	project, err := result.ExtractProject()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the project from the token of the trustor: %v", err)
+	}
+	if project == nil || project.ID == "" {
+		return nil, fmt.Errorf("the credentials of the trustor must be scoped to a project")
+	}
+
+	return &TrustCreator{
+		client:        client,
+		trustorUserID: user.ID,
+		trustorDomain: user.Domain.ID,
+		projectID:     project.ID,
+		TrusteePrefix: "kubernetes-",
+	}, nil
+}
+
+// Create creates the trust of the component, and its trustee if needed. The trustee created is deleted again when
+// the trust cannot be created, so that the creation can be retried as is.
+func (c *TrustCreator) Create(ctx context.Context, req TrustRequest) (*ComponentTrust, error) {
+	roles := req.Roles
+	if len(roles) == 0 {
+		var err error
+		if roles, err = ComponentTrustRoles(req.Component); err != nil {
+			return nil, err
+		}
+	}
+
+	trust := &ComponentTrust{
+		Component:     req.Component,
+		TrusteeUserID: req.TrusteeUserID,
+		ProjectID:     c.projectID,
+		Roles:         roles,
+	}
+	if trust.TrusteeUserID == "" {
+		password, err := generateTrusteePassword()
+		if err != nil {
+			return nil, err
+		}
+		user, err := users.Create(ctx, c.client, users.CreateOpts{
+			Name:        c.TrusteePrefix + req.Component,
+			DomainID:    c.trustorDomain,
+			Password:    password,
+			Description: fmt.Sprintf("Trustee of the %s trust", req.Component),
+		}).Extract()
+		if gophercloud.ResponseCodeIs(err, http.StatusConflict) {
+			return nil, fmt.Errorf("failed to create the trustee of %s, the user %s exists already, use its ID as the trustee: %v",
+				req.Component, c.TrusteePrefix+req.Component, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the trustee of %s: %v", req.Component, err)
+		}
+		klog.InfoS("Created trustee", "component", req.Component, "userID", user.ID)
+		trust.TrusteeUserID = user.ID
+		trust.TrusteePassword = password
+	}
+
+	trustRoles := make([]trusts.Role, 0, len(roles))
+	for _, role := range roles {
+		trustRoles = append(trustRoles, trusts.Role{Name: role})
+	}
+	created, err := trusts.Create(ctx, c.client, trusts.CreateOpts{
+		TrusteeUserID: trust.TrusteeUserID,
+		TrustorUserID: c.trustorUserID,
+		ProjectID:     c.projectID,
+		Roles:         trustRoles,
+		ExpiresAt:     req.ExpiresAt,
+	}).Extract()
+	if err != nil {
+		err = fmt.Errorf("failed to create the trust of %s: %v", req.Component, err)
+		if trust.TrusteePassword != "" {
+			if derr := users.Delete(ctx, c.client, trust.TrusteeUserID).ExtractErr(); derr != nil {
+				return nil, fmt.Errorf("%v, and to delete its trustee %s: %v", err, trust.TrusteeUserID, derr)
+			}
+			klog.InfoS("Deleted trustee", "component", req.Component, "userID", trust.TrusteeUserID)
+		}
+		return nil, err
+	}
+	klog.InfoS("Created trust", "component", req.Component, "trustID", created.ID, "roles", roles)
+	trust.TrustID = created.ID
+
+	return trust, nil
+}
+
+// generateTrusteePassword returns a random password for a trustee.
+func generateTrusteePassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the trustee password: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CloudConfig returns the configuration of the component authenticating with the trust: the [Global] section of the
+// cloud config of openstack-cloud-controller-manager and cinder-csi-plugin, or the Secret of manila-csi-plugin. The
+// password of a trustee which was not created with the trust is left to fill in.
+func (t *ComponentTrust) CloudConfig(authURL, region string) string {
+	password := t.TrusteePassword
+	if password == "" {
+		password = "<password of the trustee>"
+	}
+
+	var b strings.Builder
+	if t.Component == ComponentManilaCSI {
+		fmt.Fprintf(&b, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: csi-manila-secrets\n  namespace: default\nstringData:\n")
+		fmt.Fprintf(&b, "  os-authURL: %q\n", authURL)
+		if region != "" {
+			fmt.Fprintf(&b, "  os-region: %q\n", region)
+		}
+		fmt.Fprintf(&b, "  os-trustID: %q\n  os-trusteeID: %q\n  os-trusteePassword: %q\n", t.TrustID, t.TrusteeUserID, password)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "[Global]\nauth-url=%s\n", authURL)
+	if region != "" {
+		fmt.Fprintf(&b, "region=%s\n", region)
+	}
+	fmt.Fprintf(&b, "trust-id=%s\ntrustee-id=%s\ntrustee-password=%s\n", t.TrustID, t.TrusteeUserID, password)
+	return b.String()
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+)
+
+func fakeTrustorToken(t *testing.T, project string) tokens.CreateResult {
+	var result tokens.CreateResult
+	body := fmt.Sprintf(`{"token": {"user": {"id": "admin-id", "domain": {"id": "default"}}%s}}`, project)
+	th.AssertNoErr(t, json.Unmarshal([]byte(body), &result.Body))
+	return result
+}
+
+func TestTrustCreator(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+		var req struct {
+			User struct {
+				Name     string `json:"name"`
+				DomainID string `json:"domain_id"`
+				Password string `json:"password"`
+			} `json:"user"`
+		}
+		th.AssertNoErr(t, json.NewDecoder(r.Body).Decode(&req))
+		th.AssertEquals(t, "kubernetes-openstack-cloud-controller-manager", req.User.Name)
+		th.AssertEquals(t, "default", req.User.DomainID)
+		th.AssertEquals(t, true, req.User.Password != "")
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"user": {"id": "occm-id"}}`)
+	})
+	th.Mux.HandleFunc("/OS-TRUST/trusts", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+		var req struct {
+			Trust struct {
+				TrusteeUserID string `json:"trustee_user_id"`
+				TrustorUserID string `json:"trustor_user_id"`
+				ProjectID     string `json:"project_id"`
+				Roles         []struct {
+					Name string `json:"name"`
+				} `json:"roles"`
+			} `json:"trust"`
+		}
+		th.AssertNoErr(t, json.NewDecoder(r.Body).Decode(&req))
+		th.AssertEquals(t, "admin-id", req.Trust.TrustorUserID)
+		th.AssertEquals(t, "project-id", req.Trust.ProjectID)
+		var roles []string
+		for _, role := range req.Trust.Roles {
+			roles = append(roles, role.Name)
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"trust": {"id": "trust-%s-%d"}}`, req.Trust.TrusteeUserID, len(roles))
+	})
+
+	creator, err := newTrustCreator(fakeclient.ServiceClient(), fakeTrustorToken(t, `, "project": {"id": "project-id"}`))
+	th.AssertNoErr(t, err)
+
+	// The trustee is created with the trust.
+	trust, err := creator.Create(context.TODO(), TrustRequest{Component: ComponentOCCM})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "trust-occm-id-2", trust.TrustID)
+	th.AssertEquals(t, "occm-id", trust.TrusteeUserID)
+	th.AssertDeepEquals(t, []string{"member", "load-balancer_member"}, trust.Roles)
+	th.AssertEquals(t, true, trust.TrusteePassword != "")
+
+	// The existing trustee is used with the requested roles.
+	trust, err = creator.Create(context.TODO(), TrustRequest{Component: ComponentCinderCSI, TrusteeUserID: "cinder-id", Roles: []string{"member", "reader"}})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "trust-cinder-id-2", trust.TrustID)
+	th.AssertEquals(t, "", trust.TrusteePassword)
+
+	_, err = creator.Create(context.TODO(), TrustRequest{Component: "unknown"})
+	th.AssertEquals(t, true, err != nil)
+}
+
+func TestTrustCreatorUnscoped(t *testing.T) {
+	_, err := newTrustCreator(fakeclient.ServiceClient(), fakeTrustorToken(t, ""))
+	th.AssertEquals(t, true, err != nil)
+}
+
+func TestComponentTrustCloudConfig(t *testing.T) {
+	trust := &ComponentTrust{Component: ComponentCinderCSI, TrustID: "trust-id", TrusteeUserID: "user-id", TrusteePassword: "secret"}
+	th.AssertEquals(t, `[Global]
+auth-url=https://keystone/v3
+region=RegionOne
+trust-id=trust-id
+trustee-id=user-id
+trustee-password=secret
+`, trust.CloudConfig("https://keystone/v3", "RegionOne"))
+
+	trust = &ComponentTrust{Component: ComponentManilaCSI, TrustID: "trust-id", TrusteeUserID: "user-id"}
+	th.AssertEquals(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: csi-manila-secrets
+  namespace: default
+stringData:
+  os-authURL: "https://keystone/v3"
+  os-trustID: "trust-id"
+  os-trusteeID: "user-id"
+  os-trusteePassword: "<password of the trustee>"
+`, trust.CloudConfig("https://keystone/v3", ""))
+}
+
+func TestTrustCreatorRollback(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"user": {"id": "occm-id"}}`)
+	})
+	th.Mux.HandleFunc("/OS-TRUST/trusts", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+		w.WriteHeader(http.StatusForbidden)
+	})
+	deleted := false
+	th.Mux.HandleFunc("/users/occm-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodDelete)
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	creator, err := newTrustCreator(fakeclient.ServiceClient(), fakeTrustorToken(t, `, "project": {"id": "project-id"}`))
+	th.AssertNoErr(t, err)
+
+	// The trustee created is deleted with the failed trust.
+	_, err = creator.Create(context.TODO(), TrustRequest{Component: ComponentOCCM})
+	th.AssertEquals(t, true, err != nil)
+	th.AssertEquals(t, true, deleted)
+
+	// The existing trustee is kept.
+	deleted = false
+	_, err = creator.Create(context.TODO(), TrustRequest{Component: ComponentOCCM, TrusteeUserID: "occm-id"})
+	th.AssertEquals(t, true, err != nil)
+	th.AssertEquals(t, false, deleted)
+}
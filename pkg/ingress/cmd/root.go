@@ -47,6 +47,11 @@ var rootCmd = &cobra.Command{
 	Long:  `Ingress controller for OpenStack`,
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if conf.GatewayAPI.Enabled {
+			osGateway := controller.NewGatewayController(conf)
+			go osGateway.Start()
+		}
+
 		osIngress := controller.NewController(conf)
 		osIngress.Start()
 
@@ -17,15 +17,18 @@ limitations under the License.
 package config
 
 import (
+	"time"
+
 	"k8s.io/cloud-provider-openstack/pkg/client"
 )
 
 // Config struct contains ingress controller configuration
 type Config struct {
-	ClusterName string          `mapstructure:"cluster-name"`
-	Kubernetes  kubeConfig      `mapstructure:"kubernetes"`
-	OpenStack   client.AuthOpts `mapstructure:"openstack"`
-	Octavia     octaviaConfig   `mapstructure:"octavia"`
+	ClusterName string           `mapstructure:"cluster-name"`
+	Kubernetes  kubeConfig       `mapstructure:"kubernetes"`
+	OpenStack   client.AuthOpts  `mapstructure:"openstack"`
+	Octavia     octaviaConfig    `mapstructure:"octavia"`
+	GatewayAPI  gatewayAPIConfig `mapstructure:"gateway-api"`
 }
 
 // Configuration for connecting to Kubernetes API server, either api_host or kubeconfig should be configured.
@@ -62,4 +65,30 @@ type octaviaConfig struct {
 	// the load balancer instead of the bulk update API call.
 	// Default is false.
 	ProviderRequiresSerialAPICalls bool `mapstructure:"provider-requires-serial-api-calls"`
+
+	// (Optional) Timeout of every OpenStack API request made by the controller, so that a stuck request
+	// does not block the reconciliation forever.
+	// Default: 60s
+	RequestTimeout time.Duration `mapstructure:"request-timeout"`
+
+	// (Optional) Time the pools no longer used by the Ingress, e.g. when a path is removed or moved to another
+	// Service, keep serving their established connections before they are deleted.
+	// Default: 0, the pools are deleted right away.
+	ConnectionDrainTimeout time.Duration `mapstructure:"connection-drain-timeout"`
+
+	// (Optional) ID of the Designate zone where the addresses of the Ingresses with the
+	// octavia.ingress.kubernetes.io/dns-name annotation are published.
+	// If empty, no DNS record is managed.
+	DNSZoneID string `mapstructure:"dns-zone-id"`
+}
+
+// Gateway API related configuration
+type gatewayAPIConfig struct {
+	// (Optional) If the controller should also handle Gateway and HTTPRoute resources of the Gateway API.
+	// Requires the Gateway API CRDs to be installed in the cluster. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+
+	// (Optional) The controllerName a GatewayClass has to specify for its Gateways to be handled.
+	// Default: openstack.org/octavia-ingress-controller
+	ControllerName string `mapstructure:"controller-name"`
 }
@@ -26,26 +26,32 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/security/groups"
 	log "github.com/sirupsen/logrus"
 	apiv1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 	nwlisters "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
@@ -77,6 +83,10 @@ const (
 	UpdateEvent EventType = "UPDATE"
 	// DeleteEvent event associated when an object is removed from an informer
 	DeleteEvent EventType = "DELETE"
+	// MembersNotReadyEvent reconciles again an Ingress whose load balancer members weren't ready
+	MembersNotReadyEvent EventType = "MEMBERS_NOT_READY"
+	// PoolsDrainingEvent reconciles again an Ingress whose unused pools were draining their connections
+	PoolsDrainingEvent EventType = "POOLS_DRAINING"
 
 	// IngressKey picks a specific "class" for the Ingress.
 	// The controller only processes Ingresses with this annotation either
@@ -111,6 +121,11 @@ const (
 	// If the floatingIP is not available, an error will be returned.
 	IngressAnnotationFloatingIP = "octavia.ingress.kubernetes.io/floatingip"
 
+	// IngressAnnotationFloatingIPID is the key of the annotation on an ingress to set the ID of an existing floating IP
+	// that will be associated to LoadBalancers. It can be combined with IngressAnnotationFloatingIP, in which case both
+	// must refer to the same floating IP. If the floatingIP is not available, an error will be returned.
+	IngressAnnotationFloatingIPID = "octavia.ingress.kubernetes.io/floatingip-id"
+
 	// IngressAnnotationSourceRangesKey is the key of the annotation on an ingress to set allowed IP ranges on their LoadBalancers.
 	// It should be a comma-separated list of CIDRs.
 	IngressAnnotationSourceRangesKey = "octavia.ingress.kubernetes.io/whitelist-source-range"
@@ -138,13 +153,28 @@ const (
 	// Refer to https://docs.openstack.org/octavia/latest/configuration/configref.html#haproxy_amphora.timeout_tcp_inspect
 	IngressAnnotationTimeoutTCPInspect = "octavia.ingress.kubernetes.io/timeout-tcp-inspect"
 
+	// IngressAnnotationAvailabilityZones is the comma separated list of the Octavia availability zones of the Ingress.
+	// A load balancer is created in each of them and the Ingress status lists the addresses of all of them, so that
+	// the clients resolving the Ingress hosts fail over to another availability zone.
+	IngressAnnotationAvailabilityZones = "octavia.ingress.kubernetes.io/availability-zones"
+
+	// IngressAnnotationDNSName is the fully qualified name of the DNS records publishing the addresses of the Ingress
+	// load balancers in the Designate zone configured with octavia.dns-zone-id, e.g. "www.example.com.".
+	IngressAnnotationDNSName = "octavia.ingress.kubernetes.io/dns-name"
+
+	// IngressAnnotationLocalEndpoints restricts the pool members of each backend service to the nodes running its
+	// ready endpoints, like externalTrafficPolicy: Local does for the Services of type LoadBalancer.
+	// Default to false.
+	IngressAnnotationLocalEndpoints = "octavia.ingress.kubernetes.io/local-endpoints"
+
 	// IngressSecretCertName is certificate key name defined in the secret data.
 	IngressSecretCertName = "tls.crt"
 	// IngressSecretKeyName is private key name defined in the secret data.
 	IngressSecretKeyName = "tls.key"
 
-	// BarbicanSecretNameTemplate is the name format string to create Barbican secret.
-	BarbicanSecretNameTemplate = "kube_ingress_%s_%s_%s_%s"
+	// BarbicanSecretNameTemplate is the name format string to create Barbican secret, the last part is the digest of
+	// the certificate and private key so that a rotated certificate is uploaded as a new Barbican secret.
+	BarbicanSecretNameTemplate = "kube_ingress_%s_%s_%s_%s_%s"
 )
 
 // EventType type of event associated with an informer
@@ -158,10 +188,14 @@ type Event struct {
 
 // Controller ...
 type Controller struct {
-	stopCh              chan struct{}
-	knownNodes          []*apiv1.Node
-	queue               workqueue.TypedRateLimitingInterface[any]
+	stopCh     chan struct{}
+	knownNodes []*apiv1.Node
+	queue      workqueue.TypedRateLimitingInterface[any]
+	// membersBackoff delays the reconciliations of the Ingresses whose members aren't ready yet, by Ingress key.
+	membersBackoff      workqueue.TypedRateLimiter[string]
 	informer            informers.SharedInformerFactory
+	secretInformer      informers.SharedInformerFactory
+	epSliceInformer     informers.SharedInformerFactory
 	recorder            record.EventRecorder
 	ingressLister       nwlisters.IngressLister
 	ingressListerSynced cache.InformerSynced
@@ -169,6 +203,10 @@ type Controller struct {
 	serviceListerSynced cache.InformerSynced
 	nodeLister          corelisters.NodeLister
 	nodeListerSynced    cache.InformerSynced
+	secretLister        corelisters.SecretLister
+	secretListerSynced  cache.InformerSynced
+	epSliceLister       discoverylisters.EndpointSliceLister
+	epSliceListerSynced cache.InformerSynced
 	osClient            *openstack.OpenStack
 	kubeClient          kubernetes.Interface
 	config              config.Config
@@ -286,6 +324,27 @@ func getNodeAddressForLB(node *apiv1.Node) (string, error) {
 	return addrs[0].Address, nil
 }
 
+// getMemberOpts returns the pool members for the given nodes, the protocol port is left to the caller.
+func getMemberOpts(nodes []*apiv1.Node, logger *log.Entry) []pools.BatchUpdateMemberOpts {
+	var memberOpts []pools.BatchUpdateMemberOpts
+	for _, node := range nodes {
+		addr, err := getNodeAddressForLB(node)
+		if err != nil {
+			// Node failure, do not create member
+			logger.WithFields(log.Fields{"node": node.Name, "error": err}).Warn("failed to get node address")
+			continue
+		}
+		nodeName := node.Name
+		member := pools.BatchUpdateMemberOpts{
+			Name:    &nodeName,
+			Address: addr,
+		}
+		memberOpts = append(memberOpts, member)
+	}
+
+	return memberOpts
+}
+
 // NewController creates a new OpenStack Ingress controller.
 func NewController(conf config.Config) *Controller {
 	// initialize k8s client
@@ -310,6 +369,17 @@ func NewController(conf config.Config) *Controller {
 	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, time.Second*30)
 	serviceInformer := kubeInformerFactory.Core().V1().Services()
 	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+	// Only the TLS secrets are watched, the Ingresses may also refer to Opaque secrets which are read from the API
+	// server, their changes aren't watched.
+	secretInformerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30,
+		informers.WithTweakListOptions(func(options *apimetav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("type", string(apiv1.SecretTypeTLS)).String()
+		}))
+	secretInformer := secretInformerFactory.Core().V1().Secrets()
+	// The endpoint slices are only watched once an Ingress is restricted to the local endpoints, see
+	// startEndpointSliceInformer.
+	epSliceInformerFactory := informers.NewSharedInformerFactory(kubeClient, time.Second*30)
+	endpointSliceInformer := epSliceInformerFactory.Discovery().V1().EndpointSlices()
 	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[any]())
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -322,13 +392,20 @@ func NewController(conf config.Config) *Controller {
 	controller := &Controller{
 		config:              conf,
 		queue:               queue,
+		membersBackoff:      workqueue.NewTypedItemExponentialFailureRateLimiter[string](membersReadyInitialDelay, membersReadyMaxDelay),
 		stopCh:              make(chan struct{}),
 		informer:            kubeInformerFactory,
+		secretInformer:      secretInformerFactory,
+		epSliceInformer:     epSliceInformerFactory,
 		recorder:            recorder,
 		serviceLister:       serviceInformer.Lister(),
 		serviceListerSynced: serviceInformer.Informer().HasSynced,
 		nodeLister:          nodeInformer.Lister(),
 		nodeListerSynced:    nodeInformer.Informer().HasSynced,
+		secretLister:        secretInformer.Lister(),
+		secretListerSynced:  secretInformer.Informer().HasSynced,
+		epSliceLister:       endpointSliceInformer.Lister(),
+		epSliceListerSynced: endpointSliceInformer.Informer().HasSynced,
 		knownNodes:          []*apiv1.Node{},
 		osClient:            osClient,
 		kubeClient:          kubeClient,
@@ -356,10 +433,9 @@ func NewController(conf config.Config) *Controller {
 				// Two different versions of the same Ingress will always have different RVs.
 				return
 			}
-			newAnnotations := newIng.ObjectMeta.Annotations
-			oldAnnotations := oldIng.ObjectMeta.Annotations
-			delete(newAnnotations, "kubectl.kubernetes.io/last-applied-configuration")
-			delete(oldAnnotations, "kubectl.kubernetes.io/last-applied-configuration")
+			// The status reported by the controller itself must not trigger a new reconciliation.
+			newAnnotations := filterAnnotations(newIng.ObjectMeta.Annotations, "kubectl.kubernetes.io/last-applied-configuration", IngressAnnotationStatus)
+			oldAnnotations := filterAnnotations(oldIng.ObjectMeta.Annotations, "kubectl.kubernetes.io/last-applied-configuration", IngressAnnotationStatus)
 
 			key := fmt.Sprintf("%s/%s", newIng.Namespace, newIng.Name)
 			validOld := IsValid(oldIng)
@@ -413,6 +489,25 @@ func NewController(conf config.Config) *Controller {
 	controller.ingressLister = ingInformer.Lister()
 	controller.ingressListerSynced = ingInformer.Informer().HasSynced
 
+	// The Ingresses are reconciled again when their TLS secrets change, to upload the rotated certificates to Barbican.
+	_, err = secretInformer.Informer().AddEventHandler(controller.secretEventHandler())
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize secret")
+	}
+
+	// The Ingresses restricted to the local endpoints are reconciled again when the endpoints of their backend
+	// services change, to update the pool members.
+	_, err = endpointSliceInformer.Informer().AddEventHandler(controller.endpointSliceEventHandler())
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize endpoint slice")
+	}
+
 	return controller
 }
 
@@ -422,11 +517,15 @@ func (c *Controller) Start() {
 	defer utilruntime.HandleCrash()
 	defer c.queue.ShutDown()
 
+	// The OpenStack calls of the workers are cancelled when the controller stops.
+	ctx := wait.ContextForChannel(c.stopCh)
+
 	log.Debug("starting Ingress controller")
 	go c.informer.Start(c.stopCh)
+	go c.secretInformer.Start(c.stopCh)
 
 	// wait for the caches to synchronize before starting the worker
-	if !cache.WaitForCacheSync(c.stopCh, c.ingressListerSynced, c.serviceListerSynced, c.nodeListerSynced) {
+	if !cache.WaitForCacheSync(c.stopCh, c.ingressListerSynced, c.serviceListerSynced, c.nodeListerSynced, c.secretListerSynced) {
 		utilruntime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
@@ -440,22 +539,22 @@ func (c *Controller) Start() {
 	c.knownNodes = readyWorkerNodes
 
 	// Get subnet CIDR. The subnet CIDR will be used as source IP range for related security group rules.
-	subnet, err := c.osClient.GetSubnet(c.config.Octavia.SubnetID)
+	subnet, err := c.osClient.GetSubnet(ctx, c.config.Octavia.SubnetID)
 	if err != nil {
 		log.Errorf("Failed to retrieve the subnet %s: %v", c.config.Octavia.SubnetID, err)
 		return
 	}
 	c.subnetCIDR = subnet.CIDR
 
-	go wait.Until(c.runWorker, time.Second, c.stopCh)
-	go wait.Until(c.nodeSyncLoop, 60*time.Second, c.stopCh)
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	go wait.UntilWithContext(ctx, c.nodeSyncLoop, 60*time.Second)
 
 	<-c.stopCh
 }
 
 // nodeSyncLoop handles updating the hosts pointed to by all load
 // balancers whenever the set of nodes in the cluster changes.
-func (c *Controller) nodeSyncLoop() {
+func (c *Controller) nodeSyncLoop(ctx context.Context) {
 	readyWorkerNodes, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
 	if err != nil {
 		log.Errorf("Failed to retrieve current set of nodes from node lister: %v", err)
@@ -477,11 +576,20 @@ func (c *Controller) nodeSyncLoop() {
 	var ings *nwv1.IngressList
 	// NOTE(lingxiankong): only take ingresses without ip address into consideration
 	opts := apimetav1.ListOptions{}
-	if ings, err = c.kubeClient.NetworkingV1().Ingresses("").List(context.TODO(), opts); err != nil {
+	if ings, err = c.kubeClient.NetworkingV1().Ingresses("").List(ctx, opts); err != nil {
 		log.Errorf("Failed to retrieve current set of ingresses: %v", err)
 		return
 	}
 
+	// The load balancers of all the availability zones of the Ingresses of the cluster. Octavia only filters the
+	// names by equality, the load balancers of the project are filtered by the prefix of the names of the cluster.
+	allLoadbalancers, err := openstackutil.GetLoadBalancers(c.osClient.Octavia, loadbalancers.ListOpts{})
+	if err != nil {
+		log.Errorf("Failed to retrieve loadbalancers from OpenStack: %v", err)
+		return
+	}
+	clusterLoadbalancers := filterClusterLoadBalancers(allLoadbalancers, utils.GetResourceNamePrefix(c.config.ClusterName))
+
 	// Update each valid ingress
 	for _, ing := range ings.Items {
 		if !IsValid(&ing) {
@@ -491,17 +599,27 @@ func (c *Controller) nodeSyncLoop() {
 		log.WithFields(log.Fields{"ingress": ing.Name, "namespace": ing.Namespace}).Debug("Starting to handle ingress")
 
 		lbName := utils.GetResourceName(ing.Namespace, ing.Name, c.config.ClusterName)
-		loadbalancer, err := openstackutil.GetLoadbalancerByName(c.osClient.Octavia, lbName)
-		if err != nil {
-			if err != cpoerrors.ErrNotFound {
-				log.WithFields(log.Fields{"name": lbName}).Errorf("Failed to retrieve loadbalancer from OpenStack: %v", err)
-			}
+		ingLoadbalancers := filterIngressLoadBalancers(clusterLoadbalancers, lbName)
+		// If lb doesn't exist, continue
+		if len(ingLoadbalancers) == 0 {
+			continue
+		}
 
-			// If lb doesn't exist or error occurred, continue
+		// The members of the Ingresses restricted to the local endpoints are the nodes running the endpoints, they're
+		// updated by the reconciliation of the Ingress.
+		if localEndpoints, _ := isLocalEndpoints(&ing); localEndpoints {
+			c.queue.AddRateLimited(Event{Obj: ing.DeepCopy(), Type: UpdateEvent})
 			continue
 		}
 
-		if err = c.osClient.UpdateLoadbalancerMembers(loadbalancer.ID, readyWorkerNodes); err != nil {
+		failed := false
+		for _, loadbalancer := range ingLoadbalancers {
+			if err = c.osClient.UpdateLoadbalancerMembers(ctx, loadbalancer.ID, readyWorkerNodes); err != nil {
+				log.WithFields(log.Fields{"ingress": ing.Name, "lbID": loadbalancer.ID}).Errorf("Failed to update loadbalancer members: %v", err)
+				failed = true
+			}
+		}
+		if failed {
 			log.WithFields(log.Fields{"ingress": ing.Name}).Error("Failed to handle ingress")
 			continue
 		}
@@ -514,13 +632,79 @@ func (c *Controller) nodeSyncLoop() {
 	log.Info("Finished to handle node change")
 }
 
-func (c *Controller) runWorker() {
-	for c.processNextItem() {
+// secretEventHandler queues the update of the Ingresses whose TLS secret changed. The initial list is skipped, all the
+// Ingresses are reconciled at startup.
+func (c *Controller) secretEventHandler() cache.ResourceEventHandlerDetailedFuncs {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList {
+				return
+			}
+			c.enqueueSecretIngresses(obj.(*apiv1.Secret))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newSecret := new.(*apiv1.Secret)
+			oldSecret := old.(*apiv1.Secret)
+			if reflect.DeepEqual(newSecret.Data, oldSecret.Data) {
+				return
+			}
+			c.enqueueSecretIngresses(newSecret)
+		},
+		DeleteFunc: func(obj interface{}) {
+			delSecret, ok := obj.(*apiv1.Secret)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delSecret, ok = tombstone.Obj.(*apiv1.Secret)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not a Secret: %#v", obj)
+					return
+				}
+			}
+			c.enqueueSecretIngresses(delSecret)
+		},
+	}
+}
+
+// getTLSSecret returns the TLS secret of an Ingress, from the cache of the TLS secrets or from the API server for the
+// secrets of other types.
+func (c *Controller) getTLSSecret(ctx context.Context, namespace, name string) (*apiv1.Secret, error) {
+	secret, err := c.secretLister.Secrets(namespace).Get(name)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return secret, err
+	}
+	return c.kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, apimetav1.GetOptions{})
+}
+
+// enqueueSecretIngresses queues the update of the Ingresses terminating TLS with the secret.
+func (c *Controller) enqueueSecretIngresses(secret *apiv1.Secret) {
+	ings, err := c.ingressLister.Ingresses(secret.Namespace).List(labels.Everything())
+	if err != nil {
+		log.WithFields(log.Fields{"secret": secret.Name, "namespace": secret.Namespace}).Errorf("Failed to retrieve the ingresses: %v", err)
+		return
+	}
+
+	for _, ing := range ings {
+		if !slices.ContainsFunc(ing.Spec.TLS, func(tls nwv1.IngressTLS) bool { return tls.SecretName == secret.Name }) || !IsValid(ing) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)
+		c.recorder.Event(ing, apiv1.EventTypeNormal, "Updating", fmt.Sprintf("Ingress %s, TLS secret %s changed", key, secret.Name))
+		c.queue.AddRateLimited(Event{Obj: ing.DeepCopy(), Type: UpdateEvent})
+	}
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
 		// continue looping
 	}
 }
 
-func (c *Controller) processNextItem() bool {
+func (c *Controller) processNextItem(ctx context.Context) bool {
 	obj, quit := c.queue.Get()
 
 	if quit {
@@ -528,7 +712,7 @@ func (c *Controller) processNextItem() bool {
 	}
 	defer c.queue.Done(obj)
 
-	err := c.processItem(obj.(Event))
+	err := c.processItem(ctx, obj.(Event))
 	if err == nil {
 		// No error, reset the ratelimit counters
 		c.queue.Forget(obj)
@@ -545,7 +729,7 @@ func (c *Controller) processNextItem() bool {
 	return true
 }
 
-func (c *Controller) processItem(event Event) error {
+func (c *Controller) processItem(ctx context.Context, event Event) error {
 	ing := event.Obj.(*nwv1.Ingress)
 	key := fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)
 	logger := log.WithFields(log.Fields{"ingress": key})
@@ -554,25 +738,46 @@ func (c *Controller) processItem(event Event) error {
 	case CreateEvent:
 		logger.Info("creating ingress")
 
-		if err := c.ensureIngress(ing); err != nil {
+		if err := c.ensureIngress(ctx, ing); err != nil {
 			utilruntime.HandleError(fmt.Errorf("failed to create openstack resources for ingress %s: %v", key, err))
 			c.recorder.Event(ing, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to create openstack resources for ingress %s: %v", key, err))
+			c.reportIngressFailure(ctx, ing, err)
 		} else {
 			c.recorder.Event(ing, apiv1.EventTypeNormal, "Created", fmt.Sprintf("Ingress %s", key))
 		}
 	case UpdateEvent:
 		logger.Info("updating ingress")
 
-		if err := c.ensureIngress(ing); err != nil {
+		if err := c.ensureIngress(ctx, ing); err != nil {
 			utilruntime.HandleError(fmt.Errorf("failed to update openstack resources for ingress %s: %v", key, err))
 			c.recorder.Event(ing, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to update openstack resources for ingress %s: %v", key, err))
+			c.reportIngressFailure(ctx, ing, err)
 		} else {
 			c.recorder.Event(ing, apiv1.EventTypeNormal, "Updated", fmt.Sprintf("Ingress %s", key))
 		}
+	case MembersNotReadyEvent, PoolsDrainingEvent:
+		// The Ingress may have been deleted or changed since it was requeued
+		current, err := c.ingressLister.Ingresses(ing.Namespace).Get(ing.Name)
+		if err != nil || current.UID != ing.UID || !IsValid(current) {
+			c.membersBackoff.Forget(key)
+			return nil
+		}
+		if event.Type == PoolsDrainingEvent {
+			logger.Info("updating ingress, its unused pools were draining")
+		} else {
+			logger.Info("updating ingress, its members weren't ready")
+		}
+
+		if err := c.ensureIngress(ctx, current.DeepCopy()); err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to update openstack resources for ingress %s: %v", key, err))
+			c.recorder.Event(current, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to update openstack resources for ingress %s: %v", key, err))
+			c.reportIngressFailure(ctx, current, err)
+		}
 	case DeleteEvent:
 		logger.Info("deleting ingress")
+		c.membersBackoff.Forget(key)
 
-		if err := c.deleteIngress(ing); err != nil {
+		if err := c.deleteIngress(ctx, ing); err != nil {
 			utilruntime.HandleError(fmt.Errorf("failed to delete openstack resources for ingress %s: %v", key, err))
 			c.recorder.Event(ing, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to delete openstack resources for ingress %s: %v", key, err))
 		} else {
@@ -583,39 +788,25 @@ func (c *Controller) processItem(event Event) error {
 	return nil
 }
 
-func (c *Controller) deleteIngress(ing *nwv1.Ingress) error {
+func (c *Controller) deleteIngress(ctx context.Context, ing *nwv1.Ingress) error {
 	key := fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)
 	lbName := utils.GetResourceName(ing.Namespace, ing.Name, c.config.ClusterName)
 	logger := log.WithFields(log.Fields{"ingress": key})
 
-	// If load balancer doesn't exist, assume it's already deleted.
-	loadbalancer, err := openstackutil.GetLoadbalancerByName(c.osClient.Octavia, lbName)
-	if err != nil {
-		if err != cpoerrors.ErrNotFound {
-			return fmt.Errorf("error getting loadbalancer %s: %v", ing.Name, err)
+	if c.osClient.DNSEnabled() {
+		if err := c.osClient.EnsureDNSRecords(ctx, "", nil, ingressDNSRecordsDescription(ing, c.config.ClusterName)); err != nil {
+			return fmt.Errorf("failed to delete the DNS records of ingress %s: %v", key, err)
 		}
-
-		logger.WithFields(log.Fields{"lbName": lbName}).Info("loadbalancer for ingress deleted")
-		return nil
 	}
 
-	// Manage the floatingIP
-	keepFloatingSetting := getStringFromIngressAnnotation(ing, IngressAnnotationLoadBalancerKeepFloatingIP, "false")
-	keepFloating, err := strconv.ParseBool(keepFloatingSetting)
+	// If load balancer doesn't exist, assume it's already deleted.
+	lbs, err := c.getIngressLoadBalancers(lbName)
 	if err != nil {
-		return fmt.Errorf("unknown annotation %s: %v", IngressAnnotationLoadBalancerKeepFloatingIP, err)
+		return fmt.Errorf("error getting loadbalancer %s: %v", ing.Name, err)
 	}
-
-	if !keepFloating {
-		// Delete the floating IP for the load balancer VIP. We don't check if the Ingress is internal or not, just delete
-		// any floating IPs associated with the load balancer VIP port.
-		logger.WithFields(log.Fields{"lbID": loadbalancer.ID, "VIP": loadbalancer.VipAddress}).Info("deleting floating IPs associated with the load balancer VIP port")
-
-		if _, err = c.osClient.EnsureFloatingIP(true, loadbalancer.VipPortID, "", "", ""); err != nil {
-			return fmt.Errorf("failed to delete floating IP: %v", err)
-		}
-
-		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("VIP or floating IP deleted")
+	if len(lbs) == 0 {
+		logger.WithFields(log.Fields{"lbName": lbName}).Info("loadbalancer for ingress deleted")
+		return nil
 	}
 
 	// Delete security group managed for the Ingress backend service
@@ -623,7 +814,7 @@ func (c *Controller) deleteIngress(ing *nwv1.Ingress) error {
 		sgTags := []string{IngressControllerTag, fmt.Sprintf("%s_%s", ing.Namespace, ing.Name)}
 		tagString := strings.Join(sgTags, ",")
 		opts := groups.ListOpts{Tags: tagString}
-		sgs, err := c.osClient.GetSecurityGroups(opts)
+		sgs, err := c.osClient.GetSecurityGroups(ctx, opts)
 		if err != nil {
 			return fmt.Errorf("failed to get security groups for ingress %s: %v", key, err)
 		}
@@ -634,26 +825,27 @@ func (c *Controller) deleteIngress(ing *nwv1.Ingress) error {
 		}
 
 		for _, sg := range sgs {
-			if err = c.osClient.EnsurePortSecurityGroup(true, sg.ID, nodes); err != nil {
+			if err = c.osClient.EnsurePortSecurityGroup(ctx, true, sg.ID, nodes); err != nil {
 				return fmt.Errorf("failed to operate on the port security groups for ingress %s: %v", key, err)
 			}
-			if _, err = c.osClient.EnsureSecurityGroup(true, "", "", sgTags); err != nil {
+			if _, err = c.osClient.EnsureSecurityGroup(ctx, true, "", "", sgTags); err != nil {
 				return fmt.Errorf("failed to delete the security groups for ingress %s: %v", key, err)
 			}
 		}
 
-		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("security group deleted")
+		logger.Info("security group deleted")
 	}
 
-	err = openstackutil.DeleteLoadbalancer(c.osClient.Octavia, loadbalancer.ID, true)
-	if err != nil {
-		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Infof("loadbalancer delete failed: %v", err)
-	} else {
-		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("loadbalancer deleted")
+	// The load balancers of all the availability zones of the Ingress are deleted
+	for _, loadbalancer := range lbs {
+		if lbErr := c.deleteIngressLoadBalancer(ctx, ing, &loadbalancer); lbErr != nil {
+			logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Infof("loadbalancer delete failed: %v", lbErr)
+			err = lbErr
+		}
 	}
 
-	// Delete Barbican secrets
-	if c.osClient.Barbican != nil && ing.Spec.TLS != nil {
+	// Delete Barbican secrets, the Ingress may have had TLS secrets before its TLS section was removed
+	if c.osClient.Barbican != nil {
 		nameFilter := fmt.Sprintf("kube_ingress_%s_%s_%s", c.config.ClusterName, ing.Namespace, ing.Name)
 		if err := openstackutil.DeleteSecrets(c.osClient.Barbican, nameFilter); err != nil {
 			return fmt.Errorf("failed to remove Barbican secrets: %v", err)
@@ -665,13 +857,142 @@ func (c *Controller) deleteIngress(ing *nwv1.Ingress) error {
 	return err
 }
 
-func (c *Controller) toBarbicanSecret(name string, namespace string, toSecretName string) (string, error) {
-	secret, err := c.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, apimetav1.GetOptions{})
+// deleteIngressLoadBalancer deletes a load balancer of the Ingress together with its floating IP.
+func (c *Controller) deleteIngressLoadBalancer(ctx context.Context, ing *nwv1.Ingress, loadbalancer *loadbalancers.LoadBalancer) error {
+	logger := log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ing.Namespace, ing.Name), "lbID": loadbalancer.ID})
+
+	// Manage the floatingIP
+	keepFloatingSetting := getStringFromIngressAnnotation(ing, IngressAnnotationLoadBalancerKeepFloatingIP, "false")
+	keepFloating, err := strconv.ParseBool(keepFloatingSetting)
 	if err != nil {
-		// TODO(lingxiankong): Creating secret on the fly not supported yet.
-		return "", err
+		return fmt.Errorf("unknown annotation %s: %v", IngressAnnotationLoadBalancerKeepFloatingIP, err)
 	}
 
+	// A floating IP pinned by the user via annotations is never deleted, it's only released together with the VIP port.
+	if !keepFloating && !isFloatingIPPinned(ing) {
+		// Delete the floating IP for the load balancer VIP. We don't check if the Ingress is internal or not, just delete
+		// any floating IPs associated with the load balancer VIP port.
+		logger.WithFields(log.Fields{"VIP": loadbalancer.VipAddress}).Info("deleting floating IPs associated with the load balancer VIP port")
+
+		if _, err = c.osClient.EnsureFloatingIP(ctx, true, loadbalancer.VipPortID, "", "", "", ""); err != nil {
+			return fmt.Errorf("failed to delete floating IP: %v", err)
+		}
+
+		logger.Info("VIP or floating IP deleted")
+	}
+
+	if err := openstackutil.DeleteLoadbalancer(c.osClient.Octavia, loadbalancer.ID, true); err != nil {
+		return err
+	}
+	logger.Info("loadbalancer deleted")
+
+	return nil
+}
+
+// getStaleLoadBalancers returns the load balancers of the Ingress other than the given ones.
+func (c *Controller) getStaleLoadBalancers(ing *nwv1.Ingress, lbIDs []string) ([]loadbalancers.LoadBalancer, error) {
+	resName := utils.GetResourceName(ing.Namespace, ing.Name, c.config.ClusterName)
+	lbs, err := c.getIngressLoadBalancers(resName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting loadbalancers of ingress %s/%s: %v", ing.Namespace, ing.Name, err)
+	}
+
+	return slices.DeleteFunc(lbs, func(lb loadbalancers.LoadBalancer) bool {
+		return slices.Contains(lbIDs, lb.ID)
+	}), nil
+}
+
+// deleteStaleLoadBalancers deletes the stale load balancers of the Ingress.
+func (c *Controller) deleteStaleLoadBalancers(ctx context.Context, ing *nwv1.Ingress, staleLBs []loadbalancers.LoadBalancer) error {
+	for _, loadbalancer := range staleLBs {
+		if err := c.deleteIngressLoadBalancer(ctx, ing, &loadbalancer); err != nil {
+			return fmt.Errorf("failed to delete stale loadbalancer %s: %v", loadbalancer.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// getIngressLoadBalancers returns the load balancers of the Ingress, the one named after the Ingress and the ones
+// of its availability zones, which are tagged with the name of the Ingress resources.
+func (c *Controller) getIngressLoadBalancers(resName string) ([]loadbalancers.LoadBalancer, error) {
+	lbs, err := c.osClient.GetLoadBalancersByTag(resName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The load balancer of an Ingress created by a previous version isn't tagged until the Ingress is reconciled
+	lb, err := openstackutil.GetLoadbalancerByName(c.osClient.Octavia, resName)
+	if err != nil && err != cpoerrors.ErrNotFound {
+		return nil, err
+	}
+	if lb != nil && !slices.ContainsFunc(lbs, func(l loadbalancers.LoadBalancer) bool { return l.ID == lb.ID }) {
+		lbs = append(lbs, *lb)
+	}
+
+	return filterIngressLoadBalancers(lbs, resName), nil
+}
+
+// filterIngressLoadBalancers returns the load balancers of the Ingress named resName.
+func filterIngressLoadBalancers(allLoadbalancers []loadbalancers.LoadBalancer, resName string) []loadbalancers.LoadBalancer {
+	var res []loadbalancers.LoadBalancer
+	for _, lb := range allLoadbalancers {
+		if lb.Name == resName || strings.HasPrefix(lb.Name, resName+"_") {
+			res = append(res, lb)
+		}
+	}
+
+	return res
+}
+
+// filterClusterLoadBalancers returns the load balancers whose name starts with the prefix of the cluster.
+func filterClusterLoadBalancers(allLoadbalancers []loadbalancers.LoadBalancer, prefix string) []loadbalancers.LoadBalancer {
+	var res []loadbalancers.LoadBalancer
+	for _, lb := range allLoadbalancers {
+		if strings.HasPrefix(lb.Name, prefix) {
+			res = append(res, lb)
+		}
+	}
+
+	return res
+}
+
+// getIngressAvailabilityZones returns the availability zones of the Ingress load balancers, none when the Ingress has
+// a single load balancer.
+func getIngressAvailabilityZones(ing *nwv1.Ingress) []string {
+	var zones []string
+	for _, az := range strings.Split(getStringFromIngressAnnotation(ing, IngressAnnotationAvailabilityZones, ""), ",") {
+		az = strings.TrimSpace(az)
+		if az != "" && !slices.Contains(zones, az) {
+			zones = append(zones, az)
+		}
+	}
+
+	return zones
+}
+
+// ingressDNSRecordsDescription returns the description identifying the DNS record sets of the Ingress.
+func ingressDNSRecordsDescription(ing *nwv1.Ingress, clusterName string) string {
+	return fmt.Sprintf("Kubernetes ingress %s in namespace %s from cluster %s", ing.Name, ing.Namespace, clusterName)
+}
+
+// ingressLoadBalancerName returns the name of the Ingress load balancer in the availability zone.
+func ingressLoadBalancerName(resName, availabilityZone string) string {
+	if availabilityZone == "" {
+		return resName
+	}
+	return fmt.Sprintf("%s_%s", resName, availabilityZone)
+}
+
+// tlsSecretDigest returns the digest of the certificate and private key of the secret.
+func tlsSecretDigest(secret *apiv1.Secret) string {
+	return utils.Hash(string(secret.Data[IngressSecretCertName]) + string(secret.Data[IngressSecretKeyName]))[:16]
+}
+
+func (c *Controller) toBarbicanSecret(secret *apiv1.Secret, toSecretName string) (string, error) {
+	var err error
+	name := secret.Name
+
 	var pk crypto.PrivateKey
 	if keyBytes, isPresent := secret.Data[IngressSecretKeyName]; isPresent {
 		pk, err = privateKeyFromPEM(keyBytes)
@@ -707,7 +1028,7 @@ func (c *Controller) toBarbicanSecret(name string, namespace string, toSecretNam
 	return openstackutil.EnsureSecret(c.osClient.Barbican, toSecretName, "application/octet-stream", encoded)
 }
 
-func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
+func (c *Controller) ensureIngress(ctx context.Context, ing *nwv1.Ingress) error {
 	ingName := ing.ObjectMeta.Name
 	ingNamespace := ing.ObjectMeta.Namespace
 	clusterName := c.config.ClusterName
@@ -719,19 +1040,72 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 		return fmt.Errorf("TLS Ingress not supported because of Key Manager service unavailable")
 	}
 
-	lb, err := c.osClient.EnsureLoadBalancer(resName, c.config.Octavia.SubnetID, ingNamespace, ingName, clusterName, c.config.Octavia.FlavorID)
+	// A load balancer is created in each availability zone of the Ingress, or a single one without availability zone.
+	zones := getIngressAvailabilityZones(ing)
+	if len(zones) == 0 {
+		zones = []string{""}
+	}
+	var lbs []*loadbalancers.LoadBalancer
+	for _, az := range zones {
+		lb, err := c.osClient.EnsureLoadBalancer(ctx, ingressLoadBalancerName(resName, az), c.config.Octavia.SubnetID, ingNamespace, ingName, clusterName, c.config.Octavia.FlavorID, az, []string{IngressControllerTag, resName})
+		if err != nil {
+			return err
+		}
+		lbs = append(lbs, lb)
+	}
+
+	logger := log.WithFields(log.Fields{"ingress": ingfullName})
+
+	// TODO(lingxiankong): Creating secret on the fly not supported yet.
+	var tlsSecrets []*apiv1.Secret
+	var digests []string
+	for _, tls := range ing.Spec.TLS {
+		secret, err := c.getTLSSecret(ctx, ingNamespace, tls.SecretName)
+		if err != nil {
+			return fmt.Errorf("failed to get TLS secret %s: %v", tls.SecretName, err)
+		}
+		tlsSecrets = append(tlsSecrets, secret)
+		digests = append(digests, tlsSecretDigest(secret))
+	}
+	// The load balancer description records the certificates, their rotation doesn't change the Ingress version.
+	var certsVersion string
+	if len(digests) > 0 {
+		certsVersion = utils.Hash(strings.Join(digests, ","))[:16]
+	}
+
+	// get nodes information and prepare update member params.
+	nodeObjs, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
 	if err != nil {
 		return err
 	}
 
-	logger := log.WithFields(log.Fields{"ingress": ingfullName, "lbID": lb.ID})
+	// The load balancer description records the nodes running the endpoints, their changes don't change the Ingress
+	// version either.
+	localEndpoints, err := isLocalEndpoints(ing)
+	if err != nil {
+		return err
+	}
+	var localNodes map[string]sets.Set[string]
+	var nodesVersion string
+	if localEndpoints {
+		if err = c.startEndpointSliceInformer(); err != nil {
+			return err
+		}
+		localNodes, err = c.getLocalNodes(ing, nodeObjs)
+		if err != nil {
+			return err
+		}
+		nodesVersion = localNodesVersion(localNodes)
+	}
 
-	if strings.Contains(lb.Description, ing.ResourceVersion) {
+	if !slices.ContainsFunc(lbs, func(lb *loadbalancers.LoadBalancer) bool {
+		return !strings.Contains(lb.Description, ing.ResourceVersion) || !strings.Contains(lb.Description, certsVersion) ||
+			!strings.Contains(lb.Description, nodesVersion)
+	}) {
 		logger.Info("ingress not changed")
 		return nil
 	}
 
-	var nodePorts []int
 	var sgID string
 
 	if c.config.Octavia.ManageSecurityGroups {
@@ -739,7 +1113,7 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 
 		sgDescription := fmt.Sprintf("Security group created for Ingress %s from cluster %s", ingfullName, clusterName)
 		sgTags := []string{IngressControllerTag, fmt.Sprintf("%s_%s", ingNamespace, ingName)}
-		sgID, err = c.osClient.EnsureSecurityGroup(false, resName, sgDescription, sgTags)
+		sgID, err = c.osClient.EnsureSecurityGroup(ctx, false, resName, sgDescription, sgTags)
 		if err != nil {
 			return fmt.Errorf("failed to prepare the security group for the ingress %s: %v", ingfullName, err)
 		}
@@ -749,9 +1123,9 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 
 	// Convert kubernetes secrets to barbican ones
 	var secretRefs []string
-	for _, tls := range ing.Spec.TLS {
-		secretName := fmt.Sprintf(BarbicanSecretNameTemplate, clusterName, ingNamespace, ingName, tls.SecretName)
-		secretRef, err := c.toBarbicanSecret(tls.SecretName, ingNamespace, secretName)
+	for i, secret := range tlsSecrets {
+		secretName := fmt.Sprintf(BarbicanSecretNameTemplate, clusterName, ingNamespace, ingName, secret.Name, digests[i])
+		secretRef, err := c.toBarbicanSecret(secret, secretName)
 		if err != nil {
 			return fmt.Errorf("failed to create Barbican secret: %v", err)
 		}
@@ -760,6 +1134,207 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 
 		secretRefs = append(secretRefs, secretRef)
 	}
+
+	updateMemberOpts := getMemberOpts(nodeObjs, logger)
+	// only allow >= 1 members or it will lead to openstack octavia issue
+	if len(updateMemberOpts) == 0 {
+		return fmt.Errorf("no available nodes")
+	}
+
+	var nodePorts []int
+	var drainingUntil time.Time
+	for _, lb := range lbs {
+		var lbDrainingUntil time.Time
+		nodePorts, lbDrainingUntil, err = c.ensureLoadBalancerResources(ctx, ing, lb, secretRefs, updateMemberOpts, localNodes)
+		if err != nil {
+			return err
+		}
+		if lbDrainingUntil.After(drainingUntil) {
+			drainingUntil = lbDrainingUntil
+		}
+	}
+
+	// The listeners refer to the current certificates only, remove the Barbican secrets of the rotated certificates
+	// and of the TLS secrets removed from the Ingress, including all of them when the Ingress no longer uses TLS.
+	if c.osClient.Barbican != nil {
+		if err := c.cleanupBarbicanSecrets(resName, secretRefs); err != nil {
+			logger.Warnf("failed to remove the unused Barbican secrets: %v", err)
+		}
+	}
+
+	if c.config.Octavia.ManageSecurityGroups {
+		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensuring security group rules")
+
+		if err := c.osClient.EnsureSecurityGroupRules(ctx, sgID, c.subnetCIDR, nodePorts); err != nil {
+			return fmt.Errorf("failed to ensure security group rules for Ingress %s: %v", ingName, err)
+		}
+
+		if err := c.osClient.EnsurePortSecurityGroup(ctx, false, sgID, nodeObjs); err != nil {
+			return fmt.Errorf("failed to operate port security group for Ingress %s: %v", ingName, err)
+		}
+
+		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensured security group rules")
+	}
+
+	var total, ready int
+	var membersErr error
+	for _, lb := range lbs {
+		lbTotal, lbReady, err := c.osClient.GetMembersStatus(ctx, lb.ID)
+		if err != nil {
+			logger.WithFields(log.Fields{"lbID": lb.ID}).Warnf("failed to get the status of the load balancer members: %v", err)
+			membersErr = err
+			break
+		}
+		total += lbTotal
+		ready += lbReady
+	}
+	membersCondition := newMembersReadyCondition(ing.Generation, total, ready, membersErr)
+
+	internalSetting := getStringFromIngressAnnotation(ing, IngressAnnotationInternal, "true")
+	isInternal, err := strconv.ParseBool(internalSetting)
+	if err != nil {
+		return fmt.Errorf("unknown annotation %s: %v", IngressAnnotationInternal, err)
+	}
+
+	var addresses []string
+	for _, lb := range lbs {
+		addresses = append(addresses, lb.VipAddress)
+	}
+	lbIDs := make([]string, 0, len(lbs))
+	for _, lb := range lbs {
+		lbIDs = append(lbIDs, lb.ID)
+	}
+	// The load balancers of the availability zones removed from the Ingress, or the single load balancer when the
+	// Ingress switches to availability zones, are deleted once the new ones are in service.
+	staleLBs, err := c.getStaleLoadBalancers(ing, lbIDs)
+	if err != nil {
+		return err
+	}
+
+	fipCondition := newCondition(IngressConditionFIPAssigned, apimetav1.ConditionFalse, "Internal", "The Ingress is internal", ing.Generation)
+	if !isInternal && c.config.Octavia.FloatingIPNetwork == "" {
+		fipCondition.Reason = "NoFloatingIPNetwork"
+		fipCondition.Message = "No floating IP network is configured"
+	}
+	// Allocate floating ip for loadbalancer vip if the external network is configured and the Ingress is not internal.
+	if !isInternal && c.config.Octavia.FloatingIPNetwork != "" {
+		description := fmt.Sprintf("Floating IP for Kubernetes ingress %s in namespace %s from cluster %s", ingName, ingNamespace, clusterName)
+
+		// The pinned floating IP moves from a stale load balancer, e.g. when the availability zones of the Ingress
+		// change, to the load balancer of the first availability zone.
+		if isFloatingIPPinned(ing) {
+			floatingIPSetting := getStringFromIngressAnnotation(ing, IngressAnnotationFloatingIP, "")
+			floatingIPIDSetting := getStringFromIngressAnnotation(ing, IngressAnnotationFloatingIPID, "")
+			for _, lb := range staleLBs {
+				if err := c.osClient.ReleaseFloatingIP(ctx, lb.VipPortID, floatingIPSetting, floatingIPIDSetting); err != nil {
+					return fmt.Errorf("failed to release the floating IP of stale loadbalancer %s: %v", lb.ID, err)
+				}
+			}
+		}
+
+		for i, lb := range lbs {
+			// The floating IP pinned by the annotations is associated with the load balancer of the first availability
+			// zone, the other ones get a new floating IP.
+			var floatingIPSetting, floatingIPIDSetting string
+			if i == 0 {
+				floatingIPSetting = getStringFromIngressAnnotation(ing, IngressAnnotationFloatingIP, "")
+				floatingIPIDSetting = getStringFromIngressAnnotation(ing, IngressAnnotationFloatingIPID, "")
+			}
+
+			lbLogger := logger.WithFields(log.Fields{"lbID": lb.ID})
+			if floatingIPSetting != "" || floatingIPIDSetting != "" {
+				lbLogger.WithFields(log.Fields{"floatingIP": floatingIPSetting, "floatingIPID": floatingIPIDSetting}).Info("try to use existing floating IP")
+			} else {
+				lbLogger.Info("creating new floating IP")
+			}
+			address, err := c.osClient.EnsureFloatingIP(ctx, false, lb.VipPortID, floatingIPSetting, floatingIPIDSetting, c.config.Octavia.FloatingIPNetwork, description)
+			if err != nil {
+				fipCondition.Reason = "FloatingIPFailed"
+				fipCondition.Message = err.Error()
+				if _, err := c.updateIngressConditions(ctx, ing, fipCondition); err != nil {
+					logger.Warnf("failed to report the ingress status: %v", err)
+				}
+				return fmt.Errorf("failed to ensure floating IP for Ingress %s: %v", ingfullName, err)
+			}
+			lbLogger.Info("floating IP ", address, " configured")
+
+			addresses[i] = address
+		}
+
+		fipCondition.Status = apimetav1.ConditionTrue
+		fipCondition.Reason = "FloatingIPAssigned"
+		if len(addresses) > 1 {
+			fipCondition.Message = fmt.Sprintf("Floating IPs %s are associated with the load balancers", strings.Join(addresses, ", "))
+		} else {
+			fipCondition.Message = fmt.Sprintf("Floating IP %s is associated with the load balancer", addresses[0])
+		}
+	}
+
+	// Update ingress status
+	provisionedMessage := fmt.Sprintf("Load balancer %s is active", lbIDs[0])
+	if len(lbIDs) > 1 {
+		provisionedMessage = fmt.Sprintf("Load balancers %s are active", strings.Join(lbIDs, ", "))
+	}
+	provisionedCondition := newCondition(IngressConditionProvisioned, apimetav1.ConditionTrue, "LoadBalancerActive", provisionedMessage, ing.Generation)
+	newIng, err := c.updateIngressConditions(ctx, ing, provisionedCondition, membersCondition, fipCondition)
+	if err != nil {
+		return err
+	}
+	c.requeueMembersNotReady(newIng, membersCondition)
+	newIng, err = c.updateIngressStatus(ctx, newIng, addresses)
+	if err != nil {
+		return err
+	}
+	c.recorder.Event(ing, apiv1.EventTypeNormal, "Updated", fmt.Sprintf("Successfully associated IP address %s to ingress %s", strings.Join(addresses, ", "), ingfullName))
+
+	// The DNS records stop resolving to the stale load balancers before they are deleted
+	dnsName := getStringFromIngressAnnotation(ing, IngressAnnotationDNSName, "")
+	if c.osClient.DNSEnabled() {
+		if err := c.osClient.EnsureDNSRecords(ctx, dnsName, addresses, ingressDNSRecordsDescription(ing, clusterName)); err != nil {
+			return fmt.Errorf("failed to publish the addresses of Ingress %s: %v", ingfullName, err)
+		}
+	} else if dnsName != "" {
+		logger.Warnf("annotation %s ignored, no DNS zone is configured", IngressAnnotationDNSName)
+	}
+
+	if err := c.deleteStaleLoadBalancers(ctx, ing, staleLBs); err != nil {
+		return err
+	}
+
+	// The version isn't recorded while pools drain their connections, so that the Ingress is reconciled again to
+	// delete them.
+	if !drainingUntil.IsZero() {
+		c.requeuePoolsDraining(newIng, drainingUntil)
+		logger.Info("openstack resources for ingress created, unused pools draining")
+		return nil
+	}
+
+	// Add ingress resource version to the load balancer description
+	newDes := fmt.Sprintf("Kubernetes Ingress %s in namespace %s from cluster %s, version: %s", ingName, ingNamespace, clusterName, newIng.ResourceVersion)
+	if certsVersion != "" {
+		newDes = fmt.Sprintf("%s, certificates: %s", newDes, certsVersion)
+	}
+	if nodesVersion != "" {
+		newDes = fmt.Sprintf("%s, endpoints: %s", newDes, nodesVersion)
+	}
+	for _, lb := range lbs {
+		if err = c.osClient.UpdateLoadBalancerDescription(ctx, lb.ID, newDes); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("openstack resources for ingress created")
+
+	return nil
+}
+
+// ensureLoadBalancerResources ensures the listener, pools and l7 policies of the Ingress in the load balancer, it
+// returns the node ports of the backend services and the end of the drain of the pools no longer in use. The pool
+// members of a backend service are restricted to its local nodes if any.
+func (c *Controller) ensureLoadBalancerResources(ctx context.Context, ing *nwv1.Ingress, lb *loadbalancers.LoadBalancer, secretRefs []string, updateMemberOpts []pools.BatchUpdateMemberOpts, localNodes map[string]sets.Set[string]) ([]int, time.Time, error) {
+	ingNamespace := ing.Namespace
+	ingfullName := fmt.Sprintf("%s/%s", ingNamespace, ing.Name)
+
 	port := 80
 	if len(secretRefs) > 0 {
 		port = 443
@@ -773,49 +1348,26 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 	timeoutTCPInspect := maybeGetIntFromIngressAnnotation(ing, IngressAnnotationTimeoutTCPInspect)
 
 	listenerAllowedCIDRs := strings.Split(sourceRanges, ",")
-	listener, err := c.osClient.EnsureListener(resName, lb.ID, secretRefs, listenerAllowedCIDRs, timeoutClientData, timeoutMemberData, timeoutTCPInspect, timeoutMemberConnect)
+	listener, err := c.osClient.EnsureListener(ctx, lb.Name, lb.ID, port, secretRefs, listenerAllowedCIDRs, timeoutClientData, timeoutMemberData, timeoutTCPInspect, timeoutMemberConnect)
 	if err != nil {
-		return err
+		return nil, time.Time{}, err
 	}
 
-	// get nodes information and prepare update member params.
-	nodeObjs, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
-	if err != nil {
-		return err
-	}
-	var updateMemberOpts []pools.BatchUpdateMemberOpts
-	for _, node := range nodeObjs {
-		addr, err := getNodeAddressForLB(node)
-		if err != nil {
-			// Node failure, do not create member
-			logger.WithFields(log.Fields{"node": node.Name, "error": err}).Warn("failed to get node address")
-			continue
-		}
-		nodeName := node.Name
-		member := pools.BatchUpdateMemberOpts{
-			Name:    &nodeName,
-			Address: addr,
-		}
-		updateMemberOpts = append(updateMemberOpts, member)
-	}
-	// only allow >= 1 members or it will lead to openstack octavia issue
-	if len(updateMemberOpts) == 0 {
-		return fmt.Errorf("no available nodes")
-	}
+	var nodePorts []int
 
 	// Get all the existing pools and l7 policies
 	var newPools []openstack.IngPool
-	var newPolicies []openstack.IngPolicy
+	var newPolicies []ingressPolicy
 	var oldPolicies []openstack.ExistingPolicy
 
 	existingPolicies, err := openstackutil.GetL7policies(c.osClient.Octavia, listener.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get l7 policies for listener %s", listener.ID)
+		return nil, time.Time{}, fmt.Errorf("failed to get l7 policies for listener %s", listener.ID)
 	}
 	for _, policy := range existingPolicies {
 		rules, err := openstackutil.GetL7Rules(c.osClient.Octavia, policy.ID)
 		if err != nil {
-			return fmt.Errorf("failed to get l7 rules for policy %s", policy.ID)
+			return nil, time.Time{}, fmt.Errorf("failed to get l7 rules for policy %s", policy.ID)
 		}
 		oldPolicies = append(oldPolicies, openstack.ExistingPolicy{
 			Policy: policy,
@@ -825,7 +1377,7 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 
 	existingPools, err := openstackutil.GetPools(c.osClient.Octavia, lb.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get pools from load balancer %s, error: %v", lb.ID, err)
+		return nil, time.Time{}, fmt.Errorf("failed to get pools from load balancer %s, error: %v", lb.ID, err)
 	}
 
 	// Add default pool for the listener if 'backend' is defined
@@ -835,12 +1387,11 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 		serviceName := fmt.Sprintf("%s/%s", ingNamespace, ing.Spec.DefaultBackend.Service.Name)
 		nodePort, err := c.getServiceNodePort(serviceName, ing.Spec.DefaultBackend.Service)
 		if err != nil {
-			return err
+			return nil, time.Time{}, err
 		}
 		nodePorts = append(nodePorts, nodePort)
 
-		var members = make([]pools.BatchUpdateMemberOpts, len(updateMemberOpts))
-		copy(members, updateMemberOpts)
+		members := backendMemberOpts(updateMemberOpts, localNodes, ing.Spec.DefaultBackend.Service.Name)
 		for index := range members {
 			members[index].ProtocolPort = nodePort
 		}
@@ -880,12 +1431,11 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 			serviceName := fmt.Sprintf("%s/%s", ingNamespace, path.Backend.Service.Name)
 			nodePort, err := c.getServiceNodePort(serviceName, path.Backend.Service)
 			if err != nil {
-				return err
+				return nil, time.Time{}, err
 			}
 			nodePorts = append(nodePorts, nodePort)
 
-			var members = make([]pools.BatchUpdateMemberOpts, len(updateMemberOpts))
-			copy(members, updateMemberOpts)
+			members := backendMemberOpts(updateMemberOpts, localNodes, path.Backend.Service.Name)
 			for index := range members {
 				members[index].ProtocolPort = nodePort
 			}
@@ -903,101 +1453,75 @@ func (c *Controller) ensureIngress(ing *nwv1.Ingress) error {
 				PoolMembers: members,
 			})
 
-			policyRules = append(policyRules, l7policies.CreateRuleOpts{
-				RuleType:    l7policies.TypePath,
-				CompareType: l7policies.CompareTypeStartWith,
-				Value:       path.Path,
-			})
-
-			newPolicies = append(newPolicies, openstack.IngPolicy{
-				RedirectPoolName: poolName,
-				Opts: l7policies.CreateOpts{
-					ListenerID:  listener.ID,
-					Action:      l7policies.ActionRedirectToPool,
-					Description: "Created by kubernetes ingress",
+			policyRules = append(policyRules, ingressPathRule(path))
+
+			newPolicies = append(newPolicies, ingressPolicy{
+				policy: openstack.IngPolicy{
+					RedirectPoolName: poolName,
+					Opts: l7policies.CreateOpts{
+						ListenerID:  listener.ID,
+						Action:      l7policies.ActionRedirectToPool,
+						Description: "Created by kubernetes ingress",
+					},
+					RulesOpts: policyRules,
 				},
-				RulesOpts: policyRules,
+				host:    host != "",
+				exact:   path.PathType != nil && *path.PathType == nwv1.PathTypeExact,
+				pathLen: len(path.Path),
 			})
 		}
 	}
 
 	// Reconcile octavia resources.
-	rt := openstack.NewResourceTracker(ingfullName, c.osClient.Octavia, lb.ID, listener.ID, newPools, newPolicies, existingPools, oldPolicies)
+	rt := openstack.NewResourceTracker(ingfullName, c.osClient.Octavia, lb.ID, listener.ID, newPools, sortIngressPolicies(newPolicies), existingPools, oldPolicies)
+	rt.SetDrainTimeout(c.config.Octavia.ConnectionDrainTimeout)
 	if err := rt.CreateResources(); err != nil {
-		return err
+		return nil, time.Time{}, err
 	}
 	if err := rt.CleanupResources(); err != nil {
-		return err
+		return nil, time.Time{}, err
 	}
 
-	if c.config.Octavia.ManageSecurityGroups {
-		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensuring security group rules")
-
-		if err := c.osClient.EnsureSecurityGroupRules(sgID, c.subnetCIDR, nodePorts); err != nil {
-			return fmt.Errorf("failed to ensure security group rules for Ingress %s: %v", ingName, err)
-		}
-
-		if err := c.osClient.EnsurePortSecurityGroup(false, sgID, nodeObjs); err != nil {
-			return fmt.Errorf("failed to operate port security group for Ingress %s: %v", ingName, err)
-		}
-
-		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensured security group rules")
-	}
+	return nodePorts, rt.DrainingUntil(), nil
+}
 
-	internalSetting := getStringFromIngressAnnotation(ing, IngressAnnotationInternal, "true")
-	isInternal, err := strconv.ParseBool(internalSetting)
+// cleanupBarbicanSecrets deletes the Barbican secrets of the Ingress not referred by its listener.
+func (c *Controller) cleanupBarbicanSecrets(resName string, secretRefs []string) error {
+	existing, err := openstackutil.GetSecretsByPrefix(c.osClient.Barbican, resName+"_")
 	if err != nil {
-		return fmt.Errorf("unknown annotation %s: %v", IngressAnnotationInternal, err)
+		return err
 	}
 
-	address := lb.VipAddress
-	// Allocate floating ip for loadbalancer vip if the external network is configured and the Ingress is not internal.
-	if !isInternal && c.config.Octavia.FloatingIPNetwork != "" {
-
-		floatingIPSetting := getStringFromIngressAnnotation(ing, IngressAnnotationFloatingIP, "")
-		if err != nil {
-			return fmt.Errorf("unknown annotation %s: %v", IngressAnnotationFloatingIP, err)
-		}
-
-		description := fmt.Sprintf("Floating IP for Kubernetes ingress %s in namespace %s from cluster %s", ingName, ingNamespace, clusterName)
-
-		if floatingIPSetting != "" {
-			logger.Info("try to use floating IP: ", floatingIPSetting)
-		} else {
-			logger.Info("creating new floating IP")
+	for _, secret := range existing {
+		if slices.Contains(secretRefs, secret.SecretRef) {
+			continue
 		}
-		address, err = c.osClient.EnsureFloatingIP(false, lb.VipPortID, floatingIPSetting, c.config.Octavia.FloatingIPNetwork, description)
-		if err != nil {
-			return fmt.Errorf("failed to use provided floating IP %s : %v", floatingIPSetting, err)
+		if err := openstackutil.DeleteSecret(c.osClient.Barbican, secret.SecretRef); err != nil {
+			return fmt.Errorf("failed to delete Barbican secret %s: %v", secret.Name, err)
 		}
-		logger.Info("floating IP ", address, " configured")
+		log.WithFields(log.Fields{"secretName": secret.Name}).Info("unused Barbican secret deleted")
 	}
 
-	// Update ingress status
-	newIng, err := c.updateIngressStatus(ing, address)
-	if err != nil {
-		return err
-	}
-	c.recorder.Event(ing, apiv1.EventTypeNormal, "Updated", fmt.Sprintf("Successfully associated IP address %s to ingress %s", address, ingfullName))
+	return nil
+}
 
-	// Add ingress resource version to the load balancer description
-	newDes := fmt.Sprintf("Kubernetes Ingress %s in namespace %s from cluster %s, version: %s", ingName, ingNamespace, clusterName, newIng.ResourceVersion)
-	if err = c.osClient.UpdateLoadBalancerDescription(lb.ID, newDes); err != nil {
-		return err
+// reportIngressFailure reports the Ingress as not provisioned because of the error.
+func (c *Controller) reportIngressFailure(ctx context.Context, ing *nwv1.Ingress, reconcileErr error) {
+	condition := newCondition(IngressConditionProvisioned, apimetav1.ConditionFalse, "ReconcileFailed", reconcileErr.Error(), ing.Generation)
+	if _, err := c.updateIngressConditions(ctx, ing, condition); err != nil {
+		log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)}).Warnf("failed to report the ingress status: %v", err)
 	}
-
-	logger.Info("openstack resources for ingress created")
-
-	return nil
 }
 
-func (c *Controller) updateIngressStatus(ing *nwv1.Ingress, vip string) (*nwv1.Ingress, error) {
+func (c *Controller) updateIngressStatus(ctx context.Context, ing *nwv1.Ingress, addresses []string) (*nwv1.Ingress, error) {
 	newState := new(nwv1.IngressLoadBalancerStatus)
-	newState.Ingress = []nwv1.IngressLoadBalancerIngress{{IP: vip}}
+	for _, address := range addresses {
+		newState.Ingress = append(newState.Ingress, nwv1.IngressLoadBalancerIngress{IP: address})
+	}
 	newIng := ing.DeepCopy()
 	newIng.Status.LoadBalancer = *newState
 
-	newObj, err := c.kubeClient.NetworkingV1().Ingresses(newIng.Namespace).UpdateStatus(context.TODO(), newIng, apimetav1.UpdateOptions{})
+	newObj, err := c.kubeClient.NetworkingV1().Ingresses(newIng.Namespace).UpdateStatus(ctx, newIng, apimetav1.UpdateOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -1060,6 +1584,24 @@ func (c *Controller) getServiceNodePort(name string, serviceBackend *nwv1.Ingres
 	return nodePort, nil
 }
 
+// isFloatingIPPinned returns true if the Ingress requests a specific existing floating IP, either by address or by ID.
+func isFloatingIPPinned(ingress *nwv1.Ingress) bool {
+	return getStringFromIngressAnnotation(ingress, IngressAnnotationFloatingIP, "") != "" ||
+		getStringFromIngressAnnotation(ingress, IngressAnnotationFloatingIPID, "") != ""
+}
+
+// filterAnnotations returns a copy of the annotations without the given keys.
+func filterAnnotations(annotations map[string]string, keys ...string) map[string]string {
+	filtered := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		filtered[k] = v
+	}
+	for _, k := range keys {
+		delete(filtered, k)
+	}
+	return filtered
+}
+
 // getStringFromIngressAnnotation searches a given Ingress for a specific annotationKey and either returns the
 // annotation's value or a specified defaultSetting
 func getStringFromIngressAnnotation(ingress *nwv1.Ingress, annotationKey string, defaultValue string) string {
@@ -0,0 +1,302 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	nwlisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/controller/openstack"
+	"k8s.io/cloud-provider-openstack/pkg/ingress/utils"
+)
+
+func newTestIngress(namespace, name string, tlsSecrets ...string) *nwv1.Ingress {
+	ing := &nwv1.Ingress{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: map[string]string{IngressKey: IngressClass}},
+	}
+	for _, secret := range tlsSecrets {
+		ing.Spec.TLS = append(ing.Spec.TLS, nwv1.IngressTLS{SecretName: secret})
+	}
+	return ing
+}
+
+// newTestController returns a controller whose listers serve the objects, the secrets of type kubernetes.io/tls are
+// served by the secret lister and all the secrets by the API server.
+func newTestController(objs ...interface{}) *Controller {
+	indexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+	ings, secrets := indexer(), indexer()
+	kubeClient := fake.NewSimpleClientset()
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *nwv1.Ingress:
+			_ = ings.Add(o)
+		case *apiv1.Secret:
+			if o.Type == apiv1.SecretTypeTLS {
+				_ = secrets.Add(o)
+			}
+			_ = kubeClient.Tracker().Add(o)
+		}
+	}
+
+	// The events are queued without delay.
+	return &Controller{
+		queue:          workqueue.NewTypedRateLimitingQueue(workqueue.NewTypedItemExponentialFailureRateLimiter[any](0, 0)),
+		membersBackoff: workqueue.NewTypedItemExponentialFailureRateLimiter[string](0, 0),
+		recorder:       record.NewFakeRecorder(10),
+		ingressLister:  nwlisters.NewIngressLister(ings),
+		secretLister:   corelisters.NewSecretLister(secrets),
+		kubeClient:     kubeClient,
+	}
+}
+
+// queuedIngresses drains the queue and returns the keys of the queued Ingresses.
+func queuedIngresses(c *Controller) []string {
+	var keys []string
+	for c.queue.Len() > 0 {
+		obj, _ := c.queue.Get()
+		if ing, ok := obj.(Event).Obj.(*nwv1.Ingress); ok {
+			keys = append(keys, fmt.Sprintf("%s/%s", ing.Namespace, ing.Name))
+		}
+		c.queue.Done(obj)
+	}
+	return keys
+}
+
+func TestSecretEventHandler(t *testing.T) {
+	unmanaged := newTestIngress("app", "unmanaged", "cert")
+	unmanaged.Annotations = nil
+	c := newTestController(
+		newTestIngress("app", "web", "cert"),
+		newTestIngress("app", "other-cert", "other"),
+		newTestIngress("other", "web", "cert"),
+		unmanaged,
+	)
+	handler := c.secretEventHandler()
+	secret := &apiv1.Secret{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: "app", Name: "cert", ResourceVersion: "1"},
+		Type:       apiv1.SecretTypeTLS,
+		Data:       map[string][]byte{IngressSecretCertName: []byte("cert"), IngressSecretKeyName: []byte("key")},
+	}
+
+	// The initial list is skipped
+	handler.OnAdd(secret, true)
+	assert.Empty(t, queuedIngresses(c))
+
+	handler.OnAdd(secret, false)
+	assert.Equal(t, []string{"app/web"}, queuedIngresses(c))
+
+	// The resync and the changes of the metadata are skipped
+	relabeled := secret.DeepCopy()
+	relabeled.ResourceVersion = "2"
+	relabeled.Labels = map[string]string{"team": "web"}
+	handler.OnUpdate(secret, secret)
+	handler.OnUpdate(secret, relabeled)
+	assert.Empty(t, queuedIngresses(c))
+
+	rotated := relabeled.DeepCopy()
+	rotated.ResourceVersion = "3"
+	rotated.Data[IngressSecretCertName] = []byte("rotated")
+	handler.OnUpdate(relabeled, rotated)
+	assert.Equal(t, []string{"app/web"}, queuedIngresses(c))
+
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "app/cert", Obj: rotated})
+	assert.Equal(t, []string{"app/web"}, queuedIngresses(c))
+}
+
+func TestGetTLSSecret(t *testing.T) {
+	c := newTestController(
+		&apiv1.Secret{ObjectMeta: apimetav1.ObjectMeta{Namespace: "app", Name: "tls"}, Type: apiv1.SecretTypeTLS},
+		&apiv1.Secret{ObjectMeta: apimetav1.ObjectMeta{Namespace: "app", Name: "opaque"}, Type: apiv1.SecretTypeOpaque},
+	)
+
+	secret, err := c.getTLSSecret(context.TODO(), "app", "tls")
+	assert.NoError(t, err)
+	assert.Equal(t, "tls", secret.Name)
+
+	// The secrets of other types aren't cached
+	secret, err = c.getTLSSecret(context.TODO(), "app", "opaque")
+	assert.NoError(t, err)
+	assert.Equal(t, "opaque", secret.Name)
+
+	_, err = c.getTLSSecret(context.TODO(), "app", "missing")
+	assert.Error(t, err)
+}
+
+func TestGetIngressAvailabilityZones(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		expected   []string
+	}{
+		{name: "not set"},
+		{name: "single zone", annotation: "az1", expected: []string{"az1"}},
+		{name: "zones", annotation: "az1, az2 ,,az1", expected: []string{"az1", "az2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := newTestIngress("app", "web")
+			if tt.annotation != "" {
+				ing.Annotations[IngressAnnotationAvailabilityZones] = tt.annotation
+			}
+			assert.Equal(t, tt.expected, getIngressAvailabilityZones(ing))
+		})
+	}
+}
+
+func TestIngressLoadBalancerName(t *testing.T) {
+	assert.Equal(t, "kube_ingress_c_app_web", ingressLoadBalancerName("kube_ingress_c_app_web", ""))
+	assert.Equal(t, "kube_ingress_c_app_web_az1", ingressLoadBalancerName("kube_ingress_c_app_web", "az1"))
+}
+
+func TestFilterLoadBalancers(t *testing.T) {
+	lbs := []loadbalancers.LoadBalancer{
+		{ID: "web", Name: "kube_ingress_c_app_web"},
+		{ID: "web-az1", Name: "kube_ingress_c_app_web_az1"},
+		{ID: "webapp", Name: "kube_ingress_c_app_webapp"},
+		{ID: "other-cluster", Name: "kube_ingress_other_app_web"},
+		{ID: "service", Name: "kube_service_c_app_web"},
+	}
+	ids := func(lbs []loadbalancers.LoadBalancer) []string {
+		var res []string
+		for _, lb := range lbs {
+			res = append(res, lb.ID)
+		}
+		return res
+	}
+
+	clusterLbs := filterClusterLoadBalancers(lbs, utils.GetResourceNamePrefix("c"))
+	assert.Equal(t, []string{"web", "web-az1", "webapp"}, ids(clusterLbs))
+
+	// The load balancers of the zones are named after the Ingress followed by the zone, "webapp" is another Ingress
+	assert.Equal(t, []string{"web", "web-az1"}, ids(filterIngressLoadBalancers(clusterLbs, utils.GetResourceName("app", "web", "c"))))
+	assert.Empty(t, filterIngressLoadBalancers(clusterLbs, utils.GetResourceName("app", "api", "c")))
+}
+
+func TestNewMembersReadyCondition(t *testing.T) {
+	condition := newMembersReadyCondition(2, 3, 3, nil)
+	assert.Equal(t, apimetav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "3/3 members are operational", condition.Message)
+	assert.Equal(t, int64(2), condition.ObservedGeneration)
+
+	condition = newMembersReadyCondition(2, 3, 1, nil)
+	assert.Equal(t, apimetav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "MembersNotReady", condition.Reason)
+	assert.Equal(t, "1/3 members are operational", condition.Message)
+
+	condition = newMembersReadyCondition(2, 0, 0, fmt.Errorf("octavia unavailable"))
+	assert.Equal(t, apimetav1.ConditionUnknown, condition.Status)
+	assert.Equal(t, "octavia unavailable", condition.Message)
+}
+
+func TestRequeueMembersNotReady(t *testing.T) {
+	ing := newTestIngress("app", "web")
+	c := newTestController(ing)
+
+	// Reconciled again with an increasing delay while the members aren't ready
+	c.requeueMembersNotReady(ing, newMembersReadyCondition(1, 3, 1, nil))
+	c.requeueMembersNotReady(ing, newMembersReadyCondition(1, 0, 0, fmt.Errorf("octavia unavailable")))
+	assert.Equal(t, 2, c.membersBackoff.NumRequeues("app/web"))
+	assert.Eventually(t, func() bool { return c.queue.Len() == 2 }, time.Second, time.Millisecond)
+	for c.queue.Len() > 0 {
+		obj, _ := c.queue.Get()
+		assert.Equal(t, MembersNotReadyEvent, obj.(Event).Type)
+		c.queue.Done(obj)
+	}
+
+	// The backoff is reset once they are
+	c.requeueMembersNotReady(ing, newMembersReadyCondition(1, 3, 3, nil))
+	assert.Equal(t, 0, c.membersBackoff.NumRequeues("app/web"))
+	assert.Empty(t, queuedIngresses(c))
+
+	// An Ingress deleted meanwhile isn't reconciled again
+	c.requeueMembersNotReady(ing, newMembersReadyCondition(1, 3, 1, nil))
+	deleted := newTestIngress("app", "deleted")
+	c.requeueMembersNotReady(deleted, newMembersReadyCondition(1, 3, 1, nil))
+	assert.NoError(t, c.processItem(context.TODO(), Event{Obj: deleted, Type: MembersNotReadyEvent}))
+	assert.Equal(t, 0, c.membersBackoff.NumRequeues("app/deleted"))
+}
+
+func TestCleanupBarbicanSecrets(t *testing.T) {
+	tests := []struct {
+		name            string
+		secretIDs       []string
+		expectedDeleted []string
+	}{
+		{
+			name:            "rotated certificate",
+			secretIDs:       []string{"new"},
+			expectedDeleted: []string{"old"},
+		},
+		{
+			name:            "TLS removed from the Ingress",
+			expectedDeleted: []string{"old", "new"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			th.Mux.HandleFunc("/secrets", func(w http.ResponseWriter, r *http.Request) {
+				th.TestMethod(t, r, http.MethodGet)
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"secrets": [
+					{"name": "kube_ingress_kubernetes_default_ing_tls_old", "secret_ref": "%[1]ssecrets/old"},
+					{"name": "kube_ingress_kubernetes_default_ing_tls_new", "secret_ref": "%[1]ssecrets/new"},
+					{"name": "kube_ingress_kubernetes_default_other_tls_new", "secret_ref": "%[1]ssecrets/other"}
+				], "total": 3}`, th.Endpoint())
+			})
+			var deleted []string
+			th.Mux.HandleFunc("/secrets/", func(w http.ResponseWriter, r *http.Request) {
+				th.TestMethod(t, r, http.MethodDelete)
+				deleted = append(deleted, r.URL.Path[len("/secrets/"):])
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			var secretRefs []string
+			for _, id := range test.secretIDs {
+				secretRefs = append(secretRefs, th.Endpoint()+"secrets/"+id)
+			}
+			c := newTestController()
+			c.osClient = &openstack.OpenStack{Barbican: fakeclient.ServiceClient()}
+
+			assert.NoError(t, c.cleanupBarbicanSecrets("kube_ingress_kubernetes_default_ing", secretRefs))
+			assert.Equal(t, test.expectedDeleted, deleted)
+		})
+	}
+}
@@ -0,0 +1,1647 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/security/groups"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwclientset "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	gwscheme "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/scheme"
+	gwinformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions"
+	gwlisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/config"
+	"k8s.io/cloud-provider-openstack/pkg/ingress/controller/openstack"
+	"k8s.io/cloud-provider-openstack/pkg/ingress/utils"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+const (
+	// DefaultGatewayControllerName is the controllerName a GatewayClass has to specify for its Gateways to be
+	// handled, unless configured otherwise.
+	DefaultGatewayControllerName = "openstack.org/octavia-ingress-controller"
+
+	// GatewayAnnotationInternal is the annotation used on the Gateway to indicate that octavia-ingress-controller
+	// shouldn't associate a floating IP to the load balancer VIP. It has the same semantics as
+	// IngressAnnotationInternal and defaults to true.
+	GatewayAnnotationInternal = IngressAnnotationInternal
+
+	// GatewayAnnotationLoadBalancerKeepFloatingIP is the annotation used on the Gateway to indicate that the floating
+	// IP should be kept after the Gateway deletion. Default to false.
+	GatewayAnnotationLoadBalancerKeepFloatingIP = IngressAnnotationLoadBalancerKeepFloatingIP
+
+	// GatewayAnnotationSourceRangesKey is the key of the annotation on a Gateway to set allowed IP ranges on its
+	// listeners. It should be a comma-separated list of CIDRs.
+	GatewayAnnotationSourceRangesKey = IngressAnnotationSourceRangesKey
+
+	// gatewayFinalizer is set on the Gateways handled by the controller, so that their load balancer is deleted even
+	// when the Gateway is no longer handled when it's deleted, e.g. its GatewayClass was deleted first.
+	gatewayFinalizer = "octavia.ingress.kubernetes.io/load-balancer-cleanup"
+
+	httpRouteKind = "HTTPRoute"
+)
+
+// parentRefState is how far a parent reference of a HTTPRoute got in attaching to a listener of the Gateway, a
+// reference matching several listeners gets the highest state.
+type parentRefState int
+
+const (
+	parentRefNoMatchingParent parentRefState = iota
+	parentRefNotAllowed
+	parentRefNoMatchingHostname
+	parentRefAttached
+)
+
+// routeRuleError is the reason a HTTPRoute rule is dropped, it's reported with the condition type and reason in the
+// status of the route.
+type routeRuleError struct {
+	conditionType gwv1.RouteConditionType
+	reason        gwv1.RouteConditionReason
+	message       string
+}
+
+func (e *routeRuleError) Error() string {
+	return e.message
+}
+
+// GatewayController watches Gateway API resources and maps them to Octavia load balancers. Each Gateway gets a load
+// balancer, each HTTP Gateway listener an Octavia listener, and each HTTPRoute rule attached to a listener is mapped to
+// l7 policies redirecting to a pool of the cluster nodes.
+type GatewayController struct {
+	stopCh             chan struct{}
+	knownNodes         []*apiv1.Node
+	queue              workqueue.TypedRateLimitingInterface[any]
+	kubeInformer       informers.SharedInformerFactory
+	gwInformer         gwinformers.SharedInformerFactory
+	recorder           record.EventRecorder
+	gatewayClassLister gwlisters.GatewayClassLister
+	gatewayLister      gwlisters.GatewayLister
+	httpRouteLister    gwlisters.HTTPRouteLister
+	serviceLister      corelisters.ServiceLister
+	namespaceLister    corelisters.NamespaceLister
+	nodeLister         corelisters.NodeLister
+	listersSynced      []cache.InformerSynced
+	osClient           *openstack.OpenStack
+	kubeClient         kubernetes.Interface
+	gwClient           gwclientset.Interface
+	config             config.Config
+	controllerName     gwv1.GatewayController
+	subnetCIDR         string
+}
+
+func createGatewayClient(apiserverHost string, kubeConfig string) (*gwclientset.Clientset, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.QPS = defaultQPS
+	cfg.Burst = defaultBurst
+
+	log.Debug("creating gateway API client")
+
+	return gwclientset.NewForConfig(cfg)
+}
+
+// NewGatewayController creates a new controller for the Gateway API resources.
+func NewGatewayController(conf config.Config) *GatewayController {
+	kubeClient, err := createApiserverClient(conf.Kubernetes.ApiserverHost, conf.Kubernetes.KubeConfig)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"api_server":  conf.Kubernetes.ApiserverHost,
+			"kuberconfig": conf.Kubernetes.KubeConfig,
+			"error":       err,
+		}).Fatal("failed to initialize kubernetes client")
+	}
+
+	gwClient, err := createGatewayClient(conf.Kubernetes.ApiserverHost, conf.Kubernetes.KubeConfig)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"api_server":  conf.Kubernetes.ApiserverHost,
+			"kuberconfig": conf.Kubernetes.KubeConfig,
+			"error":       err,
+		}).Fatal("failed to initialize gateway API client")
+	}
+
+	osClient, err := openstack.NewOpenStack(conf)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize openstack client")
+	}
+
+	controllerName := conf.GatewayAPI.ControllerName
+	if controllerName == "" {
+		controllerName = DefaultGatewayControllerName
+	}
+
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, time.Second*30)
+	serviceInformer := kubeInformerFactory.Core().V1().Services()
+	namespaceInformer := kubeInformerFactory.Core().V1().Namespaces()
+	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+
+	gwInformerFactory := gwinformers.NewSharedInformerFactory(gwClient, time.Second*30)
+	gatewayClassInformer := gwInformerFactory.Gateway().V1().GatewayClasses()
+	gatewayInformer := gwInformerFactory.Gateway().V1().Gateways()
+	httpRouteInformer := gwInformerFactory.Gateway().V1().HTTPRoutes()
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{
+		Interface: kubeClient.CoreV1().Events(""),
+	})
+	// The Gateway API types need to be known to the recorder to build the event references.
+	eventScheme := runtime.NewScheme()
+	utilruntime.Must(scheme.AddToScheme(eventScheme))
+	utilruntime.Must(gwscheme.AddToScheme(eventScheme))
+	recorder := eventBroadcaster.NewRecorder(eventScheme, apiv1.EventSource{Component: "openstack-gateway-controller"})
+
+	controller := &GatewayController{
+		config:             conf,
+		controllerName:     gwv1.GatewayController(controllerName),
+		queue:              workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[any]()),
+		stopCh:             make(chan struct{}),
+		kubeInformer:       kubeInformerFactory,
+		gwInformer:         gwInformerFactory,
+		recorder:           recorder,
+		gatewayClassLister: gatewayClassInformer.Lister(),
+		gatewayLister:      gatewayInformer.Lister(),
+		httpRouteLister:    httpRouteInformer.Lister(),
+		serviceLister:      serviceInformer.Lister(),
+		namespaceLister:    namespaceInformer.Lister(),
+		nodeLister:         nodeInformer.Lister(),
+		listersSynced: []cache.InformerSynced{
+			gatewayClassInformer.Informer().HasSynced,
+			gatewayInformer.Informer().HasSynced,
+			httpRouteInformer.Informer().HasSynced,
+			serviceInformer.Informer().HasSynced,
+			namespaceInformer.Informer().HasSynced,
+			nodeInformer.Informer().HasSynced,
+		},
+		knownNodes: []*apiv1.Node{},
+		osClient:   osClient,
+		kubeClient: kubeClient,
+		gwClient:   gwClient,
+	}
+
+	_, err = gatewayClassInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			controller.enqueueGatewayClass(obj.(*gwv1.GatewayClass))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newClass := new.(*gwv1.GatewayClass)
+			oldClass := old.(*gwv1.GatewayClass)
+			if newClass.Generation == oldClass.Generation {
+				return
+			}
+			controller.enqueueGatewayClass(newClass)
+		},
+		DeleteFunc: func(obj interface{}) {
+			delClass, ok := obj.(*gwv1.GatewayClass)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delClass, ok = tombstone.Obj.(*gwv1.GatewayClass)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not a GatewayClass: %#v", obj)
+					return
+				}
+			}
+			// The load balancers of the Gateways of the deleted GatewayClass are deleted.
+			controller.enqueueClassGateways(delClass.Name)
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize gatewayclass informer")
+	}
+
+	_, err = gatewayInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			addGw := obj.(*gwv1.Gateway)
+			if !controller.isManagedGateway(addGw) && !hasGatewayFinalizer(addGw) {
+				return
+			}
+
+			recorder.Event(addGw, apiv1.EventTypeNormal, "Creating", fmt.Sprintf("Gateway %s/%s", addGw.Namespace, addGw.Name))
+			controller.queue.AddRateLimited(Event{Obj: addGw, Type: CreateEvent})
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newGw := new.(*gwv1.Gateway)
+			oldGw := old.(*gwv1.Gateway)
+			// Status updates don't change the generation.
+			if newGw.DeletionTimestamp == nil && newGw.Generation == oldGw.Generation && reflect.DeepEqual(newGw.Annotations, oldGw.Annotations) {
+				return
+			}
+			// The Gateways without the finalizer are cleaned up on their delete event.
+			if newGw.DeletionTimestamp != nil && !hasGatewayFinalizer(newGw) {
+				return
+			}
+
+			validOld := controller.isManagedGateway(oldGw)
+			validCur := controller.isManagedGateway(newGw) && newGw.DeletionTimestamp == nil
+			if validCur {
+				recorder.Event(newGw, apiv1.EventTypeNormal, "Updating", fmt.Sprintf("Gateway %s/%s", newGw.Namespace, newGw.Name))
+				controller.queue.AddRateLimited(Event{Obj: newGw, Type: UpdateEvent})
+			} else if validOld || hasGatewayFinalizer(newGw) {
+				recorder.Event(newGw, apiv1.EventTypeNormal, "Deleting", fmt.Sprintf("Gateway %s/%s", newGw.Namespace, newGw.Name))
+				controller.queue.AddRateLimited(Event{Obj: newGw, Type: DeleteEvent})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			delGw, ok := obj.(*gwv1.Gateway)
+			if !ok {
+				// If we reached here it means the gateway was deleted but its final state is unrecorded.
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delGw, ok = tombstone.Obj.(*gwv1.Gateway)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not a Gateway: %#v", obj)
+					return
+				}
+			}
+
+			if !controller.isManagedGateway(delGw) && !hasGatewayFinalizer(delGw) {
+				return
+			}
+
+			recorder.Event(delGw, apiv1.EventTypeNormal, "Deleting", fmt.Sprintf("Gateway %s/%s", delGw.Namespace, delGw.Name))
+			controller.queue.AddRateLimited(Event{Obj: delGw, Type: DeleteEvent})
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize gateway informer")
+	}
+
+	_, err = httpRouteInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			controller.enqueueParentGateways(obj.(*gwv1.HTTPRoute))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newRoute := new.(*gwv1.HTTPRoute)
+			oldRoute := old.(*gwv1.HTTPRoute)
+			if newRoute.Generation == oldRoute.Generation {
+				return
+			}
+			// The route may have been detached from a Gateway.
+			controller.enqueueParentGateways(oldRoute)
+			controller.enqueueParentGateways(newRoute)
+		},
+		DeleteFunc: func(obj interface{}) {
+			delRoute, ok := obj.(*gwv1.HTTPRoute)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delRoute, ok = tombstone.Obj.(*gwv1.HTTPRoute)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not a HTTPRoute: %#v", obj)
+					return
+				}
+			}
+			controller.enqueueParentGateways(delRoute)
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize httproute informer")
+	}
+
+	// The Gateways are reconciled again when the backend Services of their routes change, e.g. their NodePort. The
+	// initial list is skipped, all the Gateways are reconciled at startup.
+	_, err = serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList {
+				return
+			}
+			controller.enqueueServiceGateways(obj.(*apiv1.Service))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newSvc := new.(*apiv1.Service)
+			oldSvc := old.(*apiv1.Service)
+			if reflect.DeepEqual(newSvc.Spec.Ports, oldSvc.Spec.Ports) {
+				return
+			}
+			controller.enqueueServiceGateways(newSvc)
+		},
+		DeleteFunc: func(obj interface{}) {
+			delSvc, ok := obj.(*apiv1.Service)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delSvc, ok = tombstone.Obj.(*apiv1.Service)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not a Service: %#v", obj)
+					return
+				}
+			}
+			controller.enqueueServiceGateways(delSvc)
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("failed to initialize service informer")
+	}
+
+	return controller
+}
+
+// Start starts the gateway controller.
+func (c *GatewayController) Start() {
+	defer close(c.stopCh)
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	// The OpenStack calls of the workers are cancelled when the controller stops.
+	ctx := wait.ContextForChannel(c.stopCh)
+
+	log.Debug("starting Gateway controller")
+	go c.kubeInformer.Start(c.stopCh)
+	go c.gwInformer.Start(c.stopCh)
+
+	// wait for the caches to synchronize before starting the worker
+	if !cache.WaitForCacheSync(c.stopCh, c.listersSynced...) {
+		utilruntime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
+		return
+	}
+	log.Info("gateway controller synced and ready")
+
+	readyWorkerNodes, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
+	if err != nil {
+		log.Errorf("Failed to retrieve current set of nodes from node lister: %v", err)
+		return
+	}
+	c.knownNodes = readyWorkerNodes
+
+	// Get subnet CIDR. The subnet CIDR will be used as source IP range for related security group rules.
+	subnet, err := c.osClient.GetSubnet(ctx, c.config.Octavia.SubnetID)
+	if err != nil {
+		log.Errorf("Failed to retrieve the subnet %s: %v", c.config.Octavia.SubnetID, err)
+		return
+	}
+	c.subnetCIDR = subnet.CIDR
+
+	// Events received before the GatewayClasses were synced may have been dropped, resync everything once.
+	classes, err := c.gatewayClassLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("Failed to retrieve current set of gatewayclasses: %v", err)
+		return
+	}
+	for _, class := range classes {
+		c.enqueueGatewayClass(class)
+	}
+
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	go wait.UntilWithContext(ctx, c.nodeSyncLoop, 60*time.Second)
+
+	<-c.stopCh
+}
+
+// isManagedGatewayClass returns true if the GatewayClass is handled by this controller.
+func (c *GatewayController) isManagedGatewayClass(class *gwv1.GatewayClass) bool {
+	return class.Spec.ControllerName == c.controllerName
+}
+
+// isManagedGateway returns true if the Gateway references a GatewayClass handled by this controller.
+func (c *GatewayController) isManagedGateway(gw *gwv1.Gateway) bool {
+	class, err := c.gatewayClassLister.Get(string(gw.Spec.GatewayClassName))
+	if err != nil {
+		return false
+	}
+
+	return c.isManagedGatewayClass(class)
+}
+
+// hasGatewayFinalizer returns true if the load balancer of the Gateway has to be deleted by this controller.
+func hasGatewayFinalizer(gw *gwv1.Gateway) bool {
+	return slices.Contains(gw.Finalizers, gatewayFinalizer)
+}
+
+// enqueueGatewayClass queues the GatewayClass and all the Gateways referencing it.
+func (c *GatewayController) enqueueGatewayClass(class *gwv1.GatewayClass) {
+	if c.isManagedGatewayClass(class) {
+		c.queue.AddRateLimited(Event{Obj: class, Type: UpdateEvent})
+	}
+
+	// The Gateways of a GatewayClass no longer handled by this controller are queued too, for their cleanup.
+	c.enqueueClassGateways(class.Name)
+}
+
+// enqueueClassGateways queues the Gateways of the GatewayClass that are handled by this controller or whose load
+// balancer has to be deleted.
+func (c *GatewayController) enqueueClassGateways(className string) {
+	gws, err := c.gatewayLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("Failed to retrieve current set of gateways: %v", err)
+		return
+	}
+	for _, gw := range gws {
+		if string(gw.Spec.GatewayClassName) == className && (c.isManagedGateway(gw) || hasGatewayFinalizer(gw)) {
+			c.queue.AddRateLimited(Event{Obj: gw, Type: UpdateEvent})
+		}
+	}
+}
+
+// enqueueParentGateways queues the managed Gateways the HTTPRoute is attached to.
+func (c *GatewayController) enqueueParentGateways(route *gwv1.HTTPRoute) {
+	for _, ref := range route.Spec.ParentRefs {
+		namespace, name, ok := parentRefGateway(route.Namespace, ref)
+		if !ok {
+			continue
+		}
+
+		gw, err := c.gatewayLister.Gateways(namespace).Get(name)
+		if err != nil || !c.isManagedGateway(gw) {
+			continue
+		}
+
+		c.queue.AddRateLimited(Event{Obj: gw, Type: UpdateEvent})
+	}
+}
+
+// enqueueServiceGateways queues the managed Gateways of the HTTPRoutes forwarding to the Service.
+func (c *GatewayController) enqueueServiceGateways(svc *apiv1.Service) {
+	routes, err := c.httpRouteLister.HTTPRoutes(svc.Namespace).List(labels.Everything())
+	if err != nil {
+		log.WithFields(log.Fields{"service": svc.Name, "namespace": svc.Namespace}).Errorf("Failed to retrieve the httproutes: %v", err)
+		return
+	}
+
+	for _, route := range routes {
+		if routeForwardsToService(route, svc.Name) {
+			c.enqueueParentGateways(route)
+		}
+	}
+}
+
+// nodeSyncLoop reconciles all the managed Gateways whenever the set of nodes in the cluster changes.
+func (c *GatewayController) nodeSyncLoop(ctx context.Context) {
+	readyWorkerNodes, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
+	if err != nil {
+		log.Errorf("Failed to retrieve current set of nodes from node lister: %v", err)
+		return
+	}
+	if utils.NodeSlicesEqual(readyWorkerNodes, c.knownNodes) {
+		return
+	}
+
+	log.Infof("Detected change in list of current cluster nodes. New node set: %v", utils.NodeNames(readyWorkerNodes))
+
+	gws, err := c.gatewayLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("Failed to retrieve current set of gateways: %v", err)
+		return
+	}
+	for _, gw := range gws {
+		if c.isManagedGateway(gw) {
+			c.queue.AddRateLimited(Event{Obj: gw, Type: UpdateEvent})
+		}
+	}
+
+	c.knownNodes = readyWorkerNodes
+}
+
+func (c *GatewayController) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+		// continue looping
+	}
+}
+
+func (c *GatewayController) processNextItem(ctx context.Context) bool {
+	obj, quit := c.queue.Get()
+
+	if quit {
+		return false
+	}
+	defer c.queue.Done(obj)
+
+	err := c.processItem(ctx, obj.(Event))
+	if err == nil {
+		// No error, reset the ratelimit counters
+		c.queue.Forget(obj)
+	} else if c.queue.NumRequeues(obj) < maxRetries {
+		log.WithFields(log.Fields{"obj": obj, "error": err}).Error("Failed to process obj (will retry)")
+		c.queue.AddRateLimited(obj)
+	} else {
+		// err != nil and too many retries
+		log.WithFields(log.Fields{"obj": obj, "error": err}).Error("Failed to process obj (giving up)")
+		c.queue.Forget(obj)
+		utilruntime.HandleError(err)
+	}
+
+	return true
+}
+
+func (c *GatewayController) processItem(ctx context.Context, event Event) error {
+	if class, ok := event.Obj.(*gwv1.GatewayClass); ok {
+		return c.ensureGatewayClassAccepted(ctx, class)
+	}
+
+	gw := event.Obj.(*gwv1.Gateway)
+	key := fmt.Sprintf("%s/%s", gw.Namespace, gw.Name)
+	logger := log.WithFields(log.Fields{"gateway": key})
+
+	if event.Type != DeleteEvent {
+		// Always work on the latest version of the Gateway, the event may be outdated.
+		latest, err := c.gatewayLister.Gateways(gw.Namespace).Get(gw.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				// The delete event takes care of the cleanup.
+				return nil
+			}
+			return err
+		}
+		gw = latest
+
+		// The Gateway is being deleted or is no longer handled by this controller, e.g. its GatewayClass was deleted.
+		if gw.DeletionTimestamp != nil || !c.isManagedGateway(gw) {
+			if !hasGatewayFinalizer(gw) {
+				return nil
+			}
+			event.Type = DeleteEvent
+		}
+	}
+
+	if event.Type == DeleteEvent {
+		logger.Info("deleting gateway")
+
+		if err := c.deleteGateway(ctx, gw); err != nil {
+			c.recorder.Event(gw, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to delete openstack resources for gateway %s: %v", key, err))
+			return fmt.Errorf("failed to delete openstack resources for gateway %s: %v", key, err)
+		}
+		// The routes no longer have a parent reference status for the deleted Gateway.
+		routes, err := c.httpRouteLister.List(labels.Everything())
+		if err != nil {
+			return fmt.Errorf("failed to list httproutes: %v", err)
+		}
+		if err := c.updateRouteStatuses(ctx, gw, routes, nil); err != nil {
+			return fmt.Errorf("failed to update the status of the httproutes of gateway %s: %v", key, err)
+		}
+		if err := c.removeGatewayFinalizer(ctx, gw); err != nil {
+			return fmt.Errorf("failed to remove the finalizer of gateway %s: %v", key, err)
+		}
+		c.recorder.Event(gw, apiv1.EventTypeNormal, "Deleted", fmt.Sprintf("Gateway %s", key))
+
+		return nil
+	}
+
+	logger.Info("ensuring gateway")
+
+	if err := c.ensureGateway(ctx, gw); err != nil {
+		c.recorder.Event(gw, apiv1.EventTypeWarning, "Failed", fmt.Sprintf("Failed to ensure openstack resources for gateway %s: %v", key, err))
+		return fmt.Errorf("failed to ensure openstack resources for gateway %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// ensureGatewayClassAccepted sets the Accepted condition on a GatewayClass handled by this controller.
+func (c *GatewayController) ensureGatewayClassAccepted(ctx context.Context, class *gwv1.GatewayClass) error {
+	latest, err := c.gatewayClassLister.Get(class.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if apimeta.IsStatusConditionTrue(latest.Status.Conditions, string(gwv1.GatewayClassConditionStatusAccepted)) {
+		return nil
+	}
+
+	newClass := latest.DeepCopy()
+	apimeta.SetStatusCondition(&newClass.Status.Conditions, apimetav1.Condition{
+		Type:               string(gwv1.GatewayClassConditionStatusAccepted),
+		Status:             apimetav1.ConditionTrue,
+		Reason:             string(gwv1.GatewayClassReasonAccepted),
+		Message:            fmt.Sprintf("Handled by %s", c.controllerName),
+		ObservedGeneration: latest.Generation,
+	})
+
+	_, err = c.gwClient.GatewayV1().GatewayClasses().UpdateStatus(ctx, newClass, apimetav1.UpdateOptions{})
+	return err
+}
+
+// addGatewayFinalizer sets the finalizer on the Gateway before its load balancer is created, and returns the updated
+// Gateway.
+func (c *GatewayController) addGatewayFinalizer(ctx context.Context, gw *gwv1.Gateway) (*gwv1.Gateway, error) {
+	if hasGatewayFinalizer(gw) {
+		return gw, nil
+	}
+
+	newGw := gw.DeepCopy()
+	newGw.Finalizers = append(newGw.Finalizers, gatewayFinalizer)
+	return c.gwClient.GatewayV1().Gateways(newGw.Namespace).Update(ctx, newGw, apimetav1.UpdateOptions{})
+}
+
+// removeGatewayFinalizer removes the finalizer from the Gateway once its load balancer is deleted.
+func (c *GatewayController) removeGatewayFinalizer(ctx context.Context, gw *gwv1.Gateway) error {
+	// The Gateway of a delete event may be outdated or already gone.
+	latest, err := c.gwClient.GatewayV1().Gateways(gw.Namespace).Get(ctx, gw.Name, apimetav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !hasGatewayFinalizer(latest) {
+		return nil
+	}
+
+	newGw := latest.DeepCopy()
+	newGw.Finalizers = slices.DeleteFunc(newGw.Finalizers, func(f string) bool { return f == gatewayFinalizer })
+	_, err = c.gwClient.GatewayV1().Gateways(newGw.Namespace).Update(ctx, newGw, apimetav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *GatewayController) deleteGateway(ctx context.Context, gw *gwv1.Gateway) error {
+	key := fmt.Sprintf("%s/%s", gw.Namespace, gw.Name)
+	lbName := utils.GetGatewayResourceName(gw.Namespace, gw.Name, c.config.ClusterName)
+	logger := log.WithFields(log.Fields{"gateway": key})
+
+	// If load balancer doesn't exist, assume it's already deleted.
+	loadbalancer, err := openstackutil.GetLoadbalancerByName(c.osClient.Octavia, lbName)
+	if err != nil {
+		if err != cpoerrors.ErrNotFound {
+			return fmt.Errorf("error getting loadbalancer %s: %v", lbName, err)
+		}
+
+		logger.WithFields(log.Fields{"lbName": lbName}).Info("loadbalancer for gateway deleted")
+		return nil
+	}
+
+	keepFloating, err := strconv.ParseBool(getStringFromGatewayAnnotation(gw, GatewayAnnotationLoadBalancerKeepFloatingIP, "false"))
+	if err != nil {
+		return fmt.Errorf("unknown annotation %s: %v", GatewayAnnotationLoadBalancerKeepFloatingIP, err)
+	}
+
+	// A floating IP requested in the Gateway spec is never deleted, it's only released together with the VIP port.
+	if !keepFloating && getGatewayRequestedAddress(gw) == "" {
+		logger.WithFields(log.Fields{"lbID": loadbalancer.ID, "VIP": loadbalancer.VipAddress}).Info("deleting floating IPs associated with the load balancer VIP port")
+
+		if _, err = c.osClient.EnsureFloatingIP(ctx, true, loadbalancer.VipPortID, "", "", "", ""); err != nil {
+			return fmt.Errorf("failed to delete floating IP: %v", err)
+		}
+
+		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("VIP or floating IP deleted")
+	}
+
+	if c.config.Octavia.ManageSecurityGroups {
+		sgTags := getGatewaySecurityGroupTags(gw)
+		sgs, err := c.osClient.GetSecurityGroups(ctx, groups.ListOpts{Tags: strings.Join(sgTags, ",")})
+		if err != nil {
+			return fmt.Errorf("failed to get security groups for gateway %s: %v", key, err)
+		}
+
+		nodes, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
+		if err != nil {
+			return fmt.Errorf("failed to get nodes: %v", err)
+		}
+
+		for _, sg := range sgs {
+			if err = c.osClient.EnsurePortSecurityGroup(ctx, true, sg.ID, nodes); err != nil {
+				return fmt.Errorf("failed to operate on the port security groups for gateway %s: %v", key, err)
+			}
+			if _, err = c.osClient.EnsureSecurityGroup(ctx, true, "", "", sgTags); err != nil {
+				return fmt.Errorf("failed to delete the security groups for gateway %s: %v", key, err)
+			}
+		}
+
+		logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("security group deleted")
+	}
+
+	if err = openstackutil.DeleteLoadbalancer(c.osClient.Octavia, loadbalancer.ID, true); err != nil {
+		return err
+	}
+	logger.WithFields(log.Fields{"lbID": loadbalancer.ID}).Info("loadbalancer deleted")
+
+	return nil
+}
+
+func (c *GatewayController) ensureGateway(ctx context.Context, gw *gwv1.Gateway) error {
+	gwName := gw.Name
+	gwNamespace := gw.Namespace
+	clusterName := c.config.ClusterName
+
+	gwFullName := fmt.Sprintf("%s/%s", gwNamespace, gwName)
+	resName := utils.GetGatewayResourceName(gwNamespace, gwName, clusterName)
+
+	gw, err := c.addGatewayFinalizer(ctx, gw)
+	if err != nil {
+		return fmt.Errorf("failed to add the finalizer to gateway %s: %v", gwFullName, err)
+	}
+
+	allRoutes, err := c.httpRouteLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list httproutes: %v", err)
+	}
+	routes := getGatewayRoutes(gw, allRoutes)
+
+	nodeObjs, err := listWithPredicate(c.nodeLister, getNodeConditionPredicate())
+	if err != nil {
+		return err
+	}
+	updateMemberOpts := getMemberOpts(nodeObjs, log.WithFields(log.Fields{"gateway": gwFullName}))
+	// only allow >= 1 members or it will lead to openstack octavia issue
+	if len(updateMemberOpts) == 0 {
+		return fmt.Errorf("no available nodes")
+	}
+
+	lb, err := c.osClient.EnsureLoadBalancer(ctx, resName, c.config.Octavia.SubnetID, gwNamespace, gwName, clusterName, c.config.Octavia.FlavorID, "", nil)
+	if err != nil {
+		return err
+	}
+
+	logger := log.WithFields(log.Fields{"gateway": gwFullName, "lbID": lb.ID})
+
+	version := getGatewayVersion(gw, routes, c.getRouteServices(routes), nodeObjs)
+	if strings.Contains(lb.Description, version) {
+		logger.Info("gateway not changed")
+		return nil
+	}
+
+	var sgID string
+	if c.config.Octavia.ManageSecurityGroups {
+		logger.Info("ensuring security group")
+
+		sgDescription := fmt.Sprintf("Security group created for Gateway %s from cluster %s", gwFullName, clusterName)
+		sgID, err = c.osClient.EnsureSecurityGroup(ctx, false, resName, sgDescription, getGatewaySecurityGroupTags(gw))
+		if err != nil {
+			return fmt.Errorf("failed to prepare the security group for the gateway %s: %v", gwFullName, err)
+		}
+
+		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensured security group")
+	}
+
+	existingPools, err := openstackutil.GetPools(c.osClient.Octavia, lb.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get pools from load balancer %s, error: %v", lb.ID, err)
+	}
+
+	sourceRanges := getStringFromGatewayAnnotation(gw, GatewayAnnotationSourceRangesKey, "0.0.0.0/0")
+	listenerAllowedCIDRs := strings.Split(sourceRanges, ",")
+
+	var nodePorts []int
+	var listenerStatuses []gwv1.ListenerStatus
+	wantListeners := sets.New[string]()
+	wantPoolPrefixes := sets.New[string]()
+	conflicts := getListenerConflicts(gw.Spec.Listeners)
+	routeRefStates := map[string][]parentRefState{}
+	for _, route := range routes {
+		routeRefStates[route.Namespace+"/"+route.Name] = make([]parentRefState, len(route.Spec.ParentRefs))
+	}
+	for _, gwListener := range gw.Spec.Listeners {
+		listenerStatus := gwv1.ListenerStatus{
+			Name:           gwListener.Name,
+			SupportedKinds: []gwv1.RouteGroupKind{{Group: ptr.To(gwv1.Group(gwv1.GroupName)), Kind: httpRouteKind}},
+		}
+
+		if gwListener.Protocol != gwv1.HTTPProtocolType {
+			logger.WithFields(log.Fields{"listener": gwListener.Name, "protocol": gwListener.Protocol}).Warn("ignoring listener with unsupported protocol")
+			listenerStatus.SupportedKinds = []gwv1.RouteGroupKind{}
+			listenerStatus.Conditions = []apimetav1.Condition{
+				newCondition(string(gwv1.ListenerConditionAccepted), apimetav1.ConditionFalse, string(gwv1.ListenerReasonUnsupportedProtocol),
+					fmt.Sprintf("Protocol %s is not supported", gwListener.Protocol), gw.Generation),
+				newCondition(string(gwv1.ListenerConditionProgrammed), apimetav1.ConditionFalse, string(gwv1.ListenerReasonInvalid),
+					fmt.Sprintf("Protocol %s is not supported", gwListener.Protocol), gw.Generation),
+			}
+			listenerStatuses = append(listenerStatuses, listenerStatus)
+			continue
+		}
+
+		if first, ok := conflicts[gwListener.Name]; ok {
+			logger.WithFields(log.Fields{"listener": gwListener.Name, "port": gwListener.Port}).Warn("ignoring listener with a port already used by another listener")
+			message := fmt.Sprintf("Port %d is already used by listener %s", gwListener.Port, first)
+			listenerStatus.Conditions = []apimetav1.Condition{
+				newCondition(string(gwv1.ListenerConditionAccepted), apimetav1.ConditionFalse, string(gwv1.ListenerReasonPortUnavailable), message, gw.Generation),
+				newCondition(string(gwv1.ListenerConditionConflicted), apimetav1.ConditionTrue, string(gwv1.ListenerReasonHostnameConflict), message, gw.Generation),
+				newCondition(string(gwv1.ListenerConditionProgrammed), apimetav1.ConditionFalse, string(gwv1.ListenerReasonInvalid), message, gw.Generation),
+			}
+			listenerStatuses = append(listenerStatuses, listenerStatus)
+			continue
+		}
+
+		listenerName := fmt.Sprintf("%s_%s", resName, gwListener.Name)
+		// Pools are shared in the load balancer, they are prefixed with the Gateway listener name so that they can be
+		// tracked per listener. Listener names are DNS labels and can't contain '_'.
+		poolPrefix := fmt.Sprintf("%s_", gwListener.Name)
+		wantListeners.Insert(listenerName)
+		wantPoolPrefixes.Insert(poolPrefix)
+
+		listener, err := c.osClient.EnsureListener(ctx, listenerName, lb.ID, int(gwListener.Port), nil, listenerAllowedCIDRs, nil, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var newPools []openstack.IngPool
+		var newPolicies []openstack.IngPolicy
+		var oldPolicies []openstack.ExistingPolicy
+		var oldPools []pools.Pool
+
+		existingPolicies, err := openstackutil.GetL7policies(c.osClient.Octavia, listener.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get l7 policies for listener %s", listener.ID)
+		}
+		for _, policy := range existingPolicies {
+			rules, err := openstackutil.GetL7Rules(c.osClient.Octavia, policy.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get l7 rules for policy %s", policy.ID)
+			}
+			oldPolicies = append(oldPolicies, openstack.ExistingPolicy{
+				Policy: policy,
+				Rules:  rules,
+			})
+		}
+		for _, pool := range existingPools {
+			if strings.HasPrefix(pool.Name, poolPrefix) {
+				oldPools = append(oldPools, pool)
+			}
+		}
+
+		var attachedRoutes int32
+		for _, route := range routes {
+			refStates := routeRefStates[route.Namespace+"/"+route.Name]
+			allowed := c.isRouteAllowed(gw, gwListener, route)
+			hostnames := intersectHostnames(gwListener.Hostname, route.Spec.Hostnames)
+
+			attached := false
+			for i, ref := range route.Spec.ParentRefs {
+				if !refersToListener(gw, gwListener, route.Namespace, ref) {
+					continue
+				}
+				state := parentRefAttached
+				switch {
+				case !allowed:
+					state = parentRefNotAllowed
+				case len(hostnames) == 0:
+					state = parentRefNoMatchingHostname
+				default:
+					attached = true
+				}
+				refStates[i] = max(refStates[i], state)
+			}
+			if !attached {
+				continue
+			}
+			attachedRoutes++
+
+			for _, rule := range route.Spec.Rules {
+				routeLogger := logger.WithFields(log.Fields{"httproute": fmt.Sprintf("%s/%s", route.Namespace, route.Name)})
+
+				backendName, backendPort, nodePort, err := c.resolveRule(route.Namespace, rule)
+				if err != nil {
+					routeLogger.WithFields(log.Fields{"error": err}).Warn("ignoring httproute rule")
+					continue
+				}
+				nodePorts = append(nodePorts, nodePort)
+
+				// make the pool name unique in the load balancer
+				poolName := poolPrefix + utils.Hash(fmt.Sprintf("%s/%s+%d", route.Namespace, backendName, backendPort))
+
+				var members = make([]pools.BatchUpdateMemberOpts, len(updateMemberOpts))
+				copy(members, updateMemberOpts)
+				for index := range members {
+					members[index].ProtocolPort = nodePort
+				}
+
+				newPools = append(newPools, openstack.IngPool{
+					Name: poolName,
+					Opts: pools.CreateOpts{
+						Name:           poolName,
+						Protocol:       "HTTP",
+						LBMethod:       pools.LBMethodRoundRobin,
+						LoadbalancerID: lb.ID,
+						Persistence:    nil,
+					},
+					PoolMembers: members,
+				})
+
+				for _, policyRules := range getRulePolicyRules(hostnames, int(gwListener.Port), rule.Matches, routeLogger) {
+					newPolicies = append(newPolicies, openstack.IngPolicy{
+						RedirectPoolName: poolName,
+						Opts: l7policies.CreateOpts{
+							ListenerID:  listener.ID,
+							Action:      l7policies.ActionRedirectToPool,
+							Description: "Created by kubernetes gateway",
+						},
+						RulesOpts: policyRules,
+					})
+				}
+			}
+		}
+
+		// Octavia evaluates the l7 policies by position, the more specific ones have to come first.
+		sortPolicies(newPolicies)
+		for i := range newPolicies {
+			newPolicies[i].Opts.Position = int32(i + 1)
+		}
+
+		// Reconcile octavia resources.
+		rt := openstack.NewResourceTracker(gwFullName, c.osClient.Octavia, lb.ID, listener.ID, newPools, newPolicies, oldPools, oldPolicies)
+		if err := rt.CreateResources(); err != nil {
+			return err
+		}
+		if err := rt.CleanupResources(); err != nil {
+			return err
+		}
+
+		listenerStatus.AttachedRoutes = attachedRoutes
+		listenerStatus.Conditions = []apimetav1.Condition{
+			newCondition(string(gwv1.ListenerConditionAccepted), apimetav1.ConditionTrue, string(gwv1.ListenerReasonAccepted), "", gw.Generation),
+			newCondition(string(gwv1.ListenerConditionProgrammed), apimetav1.ConditionTrue, string(gwv1.ListenerReasonProgrammed), "", gw.Generation),
+			newCondition(string(gwv1.ListenerConditionResolvedRefs), apimetav1.ConditionTrue, string(gwv1.ListenerReasonResolvedRefs), "", gw.Generation),
+			newCondition(string(gwv1.ListenerConditionConflicted), apimetav1.ConditionFalse, string(gwv1.ListenerReasonNoConflicts), "", gw.Generation),
+		}
+		listenerStatuses = append(listenerStatuses, listenerStatus)
+	}
+
+	// Cleanup the listeners and pools of the listeners removed from the Gateway.
+	existingListeners, err := openstackutil.GetListenersByLoadBalancerID(c.osClient.Octavia, lb.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get listeners from load balancer %s, error: %v", lb.ID, err)
+	}
+	for _, listener := range existingListeners {
+		if wantListeners.Has(listener.Name) {
+			continue
+		}
+		logger.WithFields(log.Fields{"listenerID": listener.ID}).Info("deleting listener")
+		if err := openstackutil.DeleteListener(c.osClient.Octavia, listener.ID, lb.ID); err != nil {
+			return err
+		}
+	}
+	for _, pool := range existingPools {
+		prefix, _, _ := strings.Cut(pool.Name, "_")
+		if wantPoolPrefixes.Has(prefix + "_") {
+			continue
+		}
+		logger.WithFields(log.Fields{"poolID": pool.ID}).Info("deleting pool")
+		if err := openstackutil.DeletePool(c.osClient.Octavia, pool.ID, lb.ID); err != nil {
+			return fmt.Errorf("failed to delete pool %s, error: %v", pool.ID, err)
+		}
+	}
+
+	if c.config.Octavia.ManageSecurityGroups {
+		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensuring security group rules")
+
+		if err := c.osClient.EnsureSecurityGroupRules(ctx, sgID, c.subnetCIDR, nodePorts); err != nil {
+			return fmt.Errorf("failed to ensure security group rules for Gateway %s: %v", gwFullName, err)
+		}
+
+		if err := c.osClient.EnsurePortSecurityGroup(ctx, false, sgID, nodeObjs); err != nil {
+			return fmt.Errorf("failed to operate port security group for Gateway %s: %v", gwFullName, err)
+		}
+
+		logger.WithFields(log.Fields{"sgID": sgID}).Info("ensured security group rules")
+	}
+
+	isInternal, err := strconv.ParseBool(getStringFromGatewayAnnotation(gw, GatewayAnnotationInternal, "true"))
+	if err != nil {
+		return fmt.Errorf("unknown annotation %s: %v", GatewayAnnotationInternal, err)
+	}
+
+	address := lb.VipAddress
+	requestedAddress := getGatewayRequestedAddress(gw)
+	// Allocate floating ip for loadbalancer vip if the external network is configured and the Gateway is not internal.
+	if (!isInternal || requestedAddress != "") && c.config.Octavia.FloatingIPNetwork != "" {
+		description := fmt.Sprintf("Floating IP for Kubernetes gateway %s in namespace %s from cluster %s", gwName, gwNamespace, clusterName)
+
+		if requestedAddress != "" {
+			logger.WithFields(log.Fields{"floatingIP": requestedAddress}).Info("try to use existing floating IP")
+		} else {
+			logger.Info("creating new floating IP")
+		}
+		address, err = c.osClient.EnsureFloatingIP(ctx, false, lb.VipPortID, requestedAddress, "", c.config.Octavia.FloatingIPNetwork, description)
+		if err != nil {
+			return fmt.Errorf("failed to ensure floating IP for Gateway %s: %v", gwFullName, err)
+		}
+		logger.Info("floating IP ", address, " configured")
+	}
+
+	if err := c.updateGatewayStatus(ctx, gw, address, listenerStatuses); err != nil {
+		return err
+	}
+	c.recorder.Event(gw, apiv1.EventTypeNormal, "Updated", fmt.Sprintf("Successfully associated IP address %s to gateway %s", address, gwFullName))
+
+	if err := c.updateRouteStatuses(ctx, gw, allRoutes, routeRefStates); err != nil {
+		return fmt.Errorf("failed to update the status of the httproutes of gateway %s: %v", gwFullName, err)
+	}
+
+	// Add the gateway version to the load balancer description
+	newDes := fmt.Sprintf("Kubernetes Gateway %s in namespace %s from cluster %s, version: %s", gwName, gwNamespace, clusterName, version)
+	if err = c.osClient.UpdateLoadBalancerDescription(ctx, lb.ID, newDes); err != nil {
+		return err
+	}
+
+	logger.Info("openstack resources for gateway created")
+
+	return nil
+}
+
+func (c *GatewayController) updateGatewayStatus(ctx context.Context, gw *gwv1.Gateway, address string, listenerStatuses []gwv1.ListenerStatus) error {
+	newGw := gw.DeepCopy()
+	newGw.Status.Addresses = []gwv1.GatewayStatusAddress{{Type: ptr.To(gwv1.IPAddressType), Value: address}}
+	newGw.Status.Listeners = listenerStatuses
+	apimeta.SetStatusCondition(&newGw.Status.Conditions,
+		newCondition(string(gwv1.GatewayConditionAccepted), apimetav1.ConditionTrue, string(gwv1.GatewayReasonAccepted), "", gw.Generation))
+	apimeta.SetStatusCondition(&newGw.Status.Conditions,
+		newCondition(string(gwv1.GatewayConditionProgrammed), apimetav1.ConditionTrue, string(gwv1.GatewayReasonProgrammed), "", gw.Generation))
+
+	_, err := c.gwClient.GatewayV1().Gateways(newGw.Namespace).UpdateStatus(ctx, newGw, apimetav1.UpdateOptions{})
+	return err
+}
+
+// refersToListener returns true if the parent reference of a route in routeNamespace points to the Gateway listener.
+func refersToListener(gw *gwv1.Gateway, listener gwv1.Listener, routeNamespace string, ref gwv1.ParentReference) bool {
+	if namespace, name, ok := parentRefGateway(routeNamespace, ref); !ok || namespace != gw.Namespace || name != gw.Name {
+		return false
+	}
+	if ref.SectionName != nil && *ref.SectionName != listener.Name {
+		return false
+	}
+
+	return ref.Port == nil || *ref.Port == listener.Port
+}
+
+// isRouteAllowed returns true if the allowedRoutes of the Gateway listener permit the HTTPRoute to attach to it.
+func (c *GatewayController) isRouteAllowed(gw *gwv1.Gateway, listener gwv1.Listener, route *gwv1.HTTPRoute) bool {
+	allowed := listener.AllowedRoutes
+	if allowed == nil {
+		return route.Namespace == gw.Namespace
+	}
+
+	if len(allowed.Kinds) > 0 {
+		kindAllowed := false
+		for _, kind := range allowed.Kinds {
+			if kind.Kind == httpRouteKind && (kind.Group == nil || *kind.Group == gwv1.GroupName) {
+				kindAllowed = true
+				break
+			}
+		}
+		if !kindAllowed {
+			return false
+		}
+	}
+
+	if allowed.Namespaces == nil || allowed.Namespaces.From == nil {
+		return route.Namespace == gw.Namespace
+	}
+
+	switch *allowed.Namespaces.From {
+	case gwv1.NamespacesFromAll:
+		return true
+	case gwv1.NamespacesFromSelector:
+		if allowed.Namespaces.Selector == nil {
+			return false
+		}
+		selector, err := apimetav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		if err != nil {
+			log.WithFields(log.Fields{"gateway": fmt.Sprintf("%s/%s", gw.Namespace, gw.Name), "error": err}).Warn("invalid namespace selector")
+			return false
+		}
+		ns, err := c.namespaceLister.Get(route.Namespace)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(ns.Labels))
+	default:
+		return route.Namespace == gw.Namespace
+	}
+}
+
+func (c *GatewayController) getServiceNodePort(namespace, name string, port int32) (int, error) {
+	svc, err := c.serviceLister.Services(namespace).Get(name)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range svc.Spec.Ports {
+		if p.Port == port && p.NodePort != 0 {
+			return int(p.NodePort), nil
+		}
+	}
+
+	return 0, fmt.Errorf("failed to find nodeport for service %s/%s", namespace, name)
+}
+
+// resolveRule returns the backend Service name and port of the HTTPRoute rule and the NodePort the pool members
+// listen on. The returned error is a *routeRuleError when the rule can't be mapped to Octavia.
+func (c *GatewayController) resolveRule(namespace string, rule gwv1.HTTPRouteRule) (string, int32, int, error) {
+	if len(rule.Filters) > 0 {
+		return "", 0, 0, &routeRuleError{gwv1.RouteConditionAccepted, gwv1.RouteReasonUnsupportedValue, "filters are not supported"}
+	}
+
+	backendName, backendPort, err := getRuleBackend(namespace, rule)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	nodePort, err := c.getServiceNodePort(namespace, backendName, backendPort)
+	if err != nil {
+		return "", 0, 0, &routeRuleError{gwv1.RouteConditionResolvedRefs, gwv1.RouteReasonBackendNotFound, err.Error()}
+	}
+
+	return backendName, backendPort, nodePort, nil
+}
+
+// updateRouteStatuses sets the status of the parent references of the HTTPRoutes pointing to the Gateway from the
+// states of their parent references, and removes the status set by this controller for the parent references that no
+// longer point to the Gateway. refStates is indexed by the route namespace and name, the HTTPRoutes without states
+// have no parent reference status for the Gateway.
+func (c *GatewayController) updateRouteStatuses(ctx context.Context, gw *gwv1.Gateway, routes []*gwv1.HTTPRoute, refStates map[string][]parentRefState) error {
+	for _, route := range routes {
+		states := refStates[route.Namespace+"/"+route.Name]
+
+		var parents []gwv1.RouteParentStatus
+		for _, parent := range route.Status.Parents {
+			if namespace, name, ok := parentRefGateway(route.Namespace, parent.ParentRef); parent.ControllerName == c.controllerName && ok && namespace == gw.Namespace && name == gw.Name {
+				continue
+			}
+			parents = append(parents, parent)
+		}
+
+		for i, ref := range route.Spec.ParentRefs {
+			if namespace, name, ok := parentRefGateway(route.Namespace, ref); states == nil || !ok || namespace != gw.Namespace || name != gw.Name {
+				continue
+			}
+
+			parent := gwv1.RouteParentStatus{ParentRef: ref, ControllerName: c.controllerName}
+			// The transition times of the conditions are kept as long as their status doesn't change.
+			for _, old := range route.Status.Parents {
+				if old.ControllerName == c.controllerName && reflect.DeepEqual(old.ParentRef, ref) {
+					parent.Conditions = slices.Clone(old.Conditions)
+					break
+				}
+			}
+			// PartiallyInvalid is only set while some of the rules are dropped.
+			apimeta.RemoveStatusCondition(&parent.Conditions, string(gwv1.RouteConditionPartiallyInvalid))
+			for _, condition := range c.getRouteConditions(route, states[i]) {
+				apimeta.SetStatusCondition(&parent.Conditions, condition)
+			}
+			parents = append(parents, parent)
+		}
+
+		if apiequality.Semantic.DeepEqual(parents, route.Status.Parents) {
+			continue
+		}
+
+		newRoute := route.DeepCopy()
+		newRoute.Status.Parents = parents
+		if _, err := c.gwClient.GatewayV1().HTTPRoutes(newRoute.Namespace).UpdateStatus(ctx, newRoute, apimetav1.UpdateOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getRouteConditions returns the Accepted and ResolvedRefs conditions of a parent reference of the HTTPRoute, and the
+// PartiallyInvalid condition when some of the rules are dropped.
+func (c *GatewayController) getRouteConditions(route *gwv1.HTTPRoute, state parentRefState) []apimetav1.Condition {
+	var unsupported, unresolved []string
+	var unresolvedReason gwv1.RouteConditionReason
+	for i, rule := range route.Spec.Rules {
+		_, _, _, err := c.resolveRule(route.Namespace, rule)
+		var ruleErr *routeRuleError
+		if !errors.As(err, &ruleErr) {
+			continue
+		}
+		message := fmt.Sprintf("rule %d: %s", i, ruleErr.message)
+		if ruleErr.conditionType == gwv1.RouteConditionResolvedRefs {
+			if unresolvedReason == "" {
+				unresolvedReason = ruleErr.reason
+			}
+			unresolved = append(unresolved, message)
+		} else {
+			unsupported = append(unsupported, message)
+		}
+	}
+
+	var conditions []apimetav1.Condition
+	switch state {
+	case parentRefNoMatchingParent:
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionAccepted), apimetav1.ConditionFalse, string(gwv1.RouteReasonNoMatchingParent),
+			"No HTTP listener of the Gateway matches the parent reference", route.Generation))
+	case parentRefNotAllowed:
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionAccepted), apimetav1.ConditionFalse, string(gwv1.RouteReasonNotAllowedByListeners),
+			"The allowed routes of the listeners don't permit the route", route.Generation))
+	case parentRefNoMatchingHostname:
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionAccepted), apimetav1.ConditionFalse, string(gwv1.RouteReasonNoMatchingListenerHostname),
+			"No hostname of the route matches the hostname of the listeners", route.Generation))
+	case parentRefAttached:
+		if len(unsupported) == len(route.Spec.Rules) && len(unsupported) > 0 {
+			conditions = append(conditions, newCondition(string(gwv1.RouteConditionAccepted), apimetav1.ConditionFalse, string(gwv1.RouteReasonUnsupportedValue),
+				strings.Join(unsupported, "; "), route.Generation))
+			break
+		}
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionAccepted), apimetav1.ConditionTrue, string(gwv1.RouteReasonAccepted), "", route.Generation))
+		if len(unsupported) > 0 {
+			conditions = append(conditions, newCondition(string(gwv1.RouteConditionPartiallyInvalid), apimetav1.ConditionTrue, string(gwv1.RouteReasonUnsupportedValue),
+				"Dropped Rule(s): "+strings.Join(unsupported, "; "), route.Generation))
+		}
+	}
+
+	if len(unresolved) > 0 {
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionResolvedRefs), apimetav1.ConditionFalse, string(unresolvedReason),
+			strings.Join(unresolved, "; "), route.Generation))
+	} else {
+		conditions = append(conditions, newCondition(string(gwv1.RouteConditionResolvedRefs), apimetav1.ConditionTrue, string(gwv1.RouteReasonResolvedRefs), "", route.Generation))
+	}
+
+	return conditions
+}
+
+// getRouteServices returns the existing backend Services of the HTTPRoutes.
+func (c *GatewayController) getRouteServices(routes []*gwv1.HTTPRoute) []*apiv1.Service {
+	var services []*apiv1.Service
+	for _, route := range routes {
+		for _, rule := range route.Spec.Rules {
+			name, _, err := getRuleBackend(route.Namespace, rule)
+			if err != nil {
+				continue
+			}
+			if svc, err := c.serviceLister.Services(route.Namespace).Get(name); err == nil {
+				services = append(services, svc)
+			}
+		}
+	}
+
+	return services
+}
+
+// parentRefGateway returns the namespace and name of the Gateway the parent reference of a route in routeNamespace
+// points to, or false if it doesn't point to a Gateway.
+func parentRefGateway(routeNamespace string, ref gwv1.ParentReference) (string, string, bool) {
+	if (ref.Group != nil && *ref.Group != gwv1.GroupName) || (ref.Kind != nil && *ref.Kind != "Gateway") {
+		return "", "", false
+	}
+
+	namespace := routeNamespace
+	if ref.Namespace != nil {
+		namespace = string(*ref.Namespace)
+	}
+
+	return namespace, string(ref.Name), true
+}
+
+// getGatewayRoutes returns the HTTPRoutes with a parent reference to the Gateway.
+func getGatewayRoutes(gw *gwv1.Gateway, routes []*gwv1.HTTPRoute) []*gwv1.HTTPRoute {
+	var gwRoutes []*gwv1.HTTPRoute
+	for _, route := range routes {
+		for _, ref := range route.Spec.ParentRefs {
+			if namespace, name, ok := parentRefGateway(route.Namespace, ref); ok && namespace == gw.Namespace && name == gw.Name {
+				gwRoutes = append(gwRoutes, route)
+				break
+			}
+		}
+	}
+
+	return gwRoutes
+}
+
+// routeForwardsToService returns true if a rule of the HTTPRoute has a backend reference to the Service in the
+// namespace of the route.
+func routeForwardsToService(route *gwv1.HTTPRoute, svcName string) bool {
+	for _, rule := range route.Spec.Rules {
+		for _, backend := range rule.BackendRefs {
+			ref := backend.BackendObjectReference
+			if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+				continue
+			}
+			if (ref.Namespace == nil || string(*ref.Namespace) == route.Namespace) && string(ref.Name) == svcName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getRuleBackend returns the Service name and port the rule forwards to. Octavia l7 policies redirect to a single
+// pool, so only one Service backend in the route namespace is supported. The returned error is a *routeRuleError.
+func getRuleBackend(namespace string, rule gwv1.HTTPRouteRule) (string, int32, error) {
+	if len(rule.BackendRefs) != 1 {
+		return "", 0, &routeRuleError{gwv1.RouteConditionAccepted, gwv1.RouteReasonUnsupportedValue,
+			fmt.Sprintf("exactly one backendRef is supported, got %d", len(rule.BackendRefs))}
+	}
+
+	ref := rule.BackendRefs[0].BackendObjectReference
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+		return "", 0, &routeRuleError{gwv1.RouteConditionResolvedRefs, gwv1.RouteReasonInvalidKind, "only Service backends are supported"}
+	}
+	if ref.Namespace != nil && string(*ref.Namespace) != namespace {
+		return "", 0, &routeRuleError{gwv1.RouteConditionResolvedRefs, gwv1.RouteReasonRefNotPermitted, "cross namespace backends are not supported"}
+	}
+	if ref.Port == nil {
+		return "", 0, &routeRuleError{gwv1.RouteConditionAccepted, gwv1.RouteReasonUnsupportedValue,
+			fmt.Sprintf("port is required for Service backend %s", ref.Name)}
+	}
+
+	return string(ref.Name), int32(*ref.Port), nil
+}
+
+// getListenerConflicts returns the HTTP listeners of the Gateway that can't be mapped to an Octavia listener, with the
+// name of the listener using their port. An Octavia listener is bound to a port, the first HTTP listener of the Gateway
+// on a port gets it.
+func getListenerConflicts(listeners []gwv1.Listener) map[gwv1.SectionName]gwv1.SectionName {
+	portListeners := map[gwv1.PortNumber]gwv1.SectionName{}
+	conflicts := map[gwv1.SectionName]gwv1.SectionName{}
+	for _, listener := range listeners {
+		if listener.Protocol != gwv1.HTTPProtocolType {
+			continue
+		}
+		if first, ok := portListeners[listener.Port]; ok {
+			conflicts[listener.Name] = first
+			continue
+		}
+		portListeners[listener.Port] = listener.Name
+	}
+
+	return conflicts
+}
+
+// intersectHostnames returns the hostnames a route accepts on a listener, an empty string stands for any hostname.
+func intersectHostnames(listenerHostname *gwv1.Hostname, routeHostnames []gwv1.Hostname) []string {
+	if len(routeHostnames) == 0 {
+		if listenerHostname == nil {
+			return []string{""}
+		}
+		return []string{string(*listenerHostname)}
+	}
+
+	var hostnames []string
+	for _, h := range routeHostnames {
+		routeHost := string(h)
+		if listenerHostname == nil {
+			hostnames = append(hostnames, routeHost)
+			continue
+		}
+
+		listenerHost := string(*listenerHostname)
+		switch {
+		case routeHost == listenerHost:
+			hostnames = append(hostnames, routeHost)
+		case hostnameMatchesWildcard(routeHost, listenerHost):
+			hostnames = append(hostnames, routeHost)
+		case hostnameMatchesWildcard(listenerHost, routeHost):
+			hostnames = append(hostnames, listenerHost)
+		}
+	}
+
+	return hostnames
+}
+
+// hostnameMatchesWildcard returns true if the hostname is covered by the wildcard hostname, e.g. "foo.example.com"
+// and "*.foo.example.com" are both covered by "*.example.com".
+func hostnameMatchesWildcard(hostname, wildcard string) bool {
+	if !strings.HasPrefix(wildcard, "*.") {
+		return false
+	}
+
+	suffix := wildcard[1:]
+	hostname = strings.TrimPrefix(hostname, "*")
+
+	return len(hostname) > len(suffix) && strings.HasSuffix(hostname, suffix)
+}
+
+// hostnameRegex returns the regex matching the Host header for the hostname.
+func hostnameRegex(hostname string, port int) string {
+	if strings.HasPrefix(hostname, "*.") {
+		return fmt.Sprintf("^[^.]+(\\.[^.]+)*%s(:%d)?$", strings.ReplaceAll(hostname[1:], ".", "\\."), port)
+	}
+
+	return fmt.Sprintf("^%s(:%d)?$", strings.ReplaceAll(hostname, ".", "\\."), port)
+}
+
+// getRulePolicyRules converts the matches of a HTTPRoute rule to the l7 rules of the Octavia l7 policies. The l7 rules
+// of a policy are ANDed, so each hostname and match pair needs its own policy.
+func getRulePolicyRules(hostnames []string, port int, matches []gwv1.HTTPRouteMatch, logger *log.Entry) [][]l7policies.CreateRuleOpts {
+	if len(matches) == 0 {
+		// An empty match list matches all the requests.
+		matches = []gwv1.HTTPRouteMatch{{}}
+	}
+
+	var policies [][]l7policies.CreateRuleOpts
+	for _, match := range matches {
+		if len(match.QueryParams) > 0 || match.Method != nil {
+			logger.Warn("ignoring httproute match, query parameter and method matches are not supported")
+			continue
+		}
+
+		var matchRules []l7policies.CreateRuleOpts
+
+		pathType := gwv1.PathMatchPathPrefix
+		pathValue := "/"
+		if match.Path != nil {
+			if match.Path.Type != nil {
+				pathType = *match.Path.Type
+			}
+			if match.Path.Value != nil {
+				pathValue = *match.Path.Value
+			}
+		}
+		pathRule := l7policies.CreateRuleOpts{RuleType: l7policies.TypePath, Value: pathValue}
+		switch pathType {
+		case gwv1.PathMatchExact:
+			pathRule.CompareType = l7policies.CompareTypeEqual
+		case gwv1.PathMatchRegularExpression:
+			pathRule.CompareType = l7policies.CompareTypeRegex
+		default:
+			pathRule.CompareType = l7policies.CompareTypeStartWith
+		}
+		matchRules = append(matchRules, pathRule)
+
+		for _, header := range match.Headers {
+			headerRule := l7policies.CreateRuleOpts{
+				RuleType:    l7policies.TypeHeader,
+				CompareType: l7policies.CompareTypeEqual,
+				Key:         string(header.Name),
+				Value:       header.Value,
+			}
+			if header.Type != nil && *header.Type == gwv1.HeaderMatchRegularExpression {
+				headerRule.CompareType = l7policies.CompareTypeRegex
+			}
+			matchRules = append(matchRules, headerRule)
+		}
+
+		for _, hostname := range hostnames {
+			var policyRules []l7policies.CreateRuleOpts
+			if hostname != "" {
+				policyRules = append(policyRules, l7policies.CreateRuleOpts{
+					RuleType:    l7policies.TypeHostName,
+					CompareType: l7policies.CompareTypeRegex,
+					Value:       hostnameRegex(hostname, port),
+				})
+			}
+			policies = append(policies, append(policyRules, matchRules...))
+		}
+	}
+
+	return policies
+}
+
+// sortPolicies sorts the l7 policies following the Gateway API precedence: exact hostnames first, then wildcard
+// hostnames, then exact paths, then the longest path prefixes and at last the number of header matches.
+func sortPolicies(policies []openstack.IngPolicy) {
+	rank := func(policy openstack.IngPolicy) (int, int, int, int) {
+		hostRank, pathRank, pathLen, headers := 2, 1, 0, 0
+		for _, rule := range policy.RulesOpts {
+			switch rule.RuleType {
+			case l7policies.TypeHostName:
+				hostRank = 0
+				if strings.HasPrefix(rule.Value, "^[^.]+") {
+					hostRank = 1
+				}
+			case l7policies.TypePath:
+				if rule.CompareType == l7policies.CompareTypeEqual {
+					pathRank = 0
+				}
+				pathLen = len(rule.Value)
+			case l7policies.TypeHeader:
+				headers++
+			}
+		}
+		return hostRank, pathRank, pathLen, headers
+	}
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		hi, pi, li, ni := rank(policies[i])
+		hj, pj, lj, nj := rank(policies[j])
+		if hi != hj {
+			return hi < hj
+		}
+		if pi != pj {
+			return pi < pj
+		}
+		if li != lj {
+			return li > lj
+		}
+		return ni > nj
+	})
+}
+
+// getGatewayVersion returns a hash of everything the Octavia resources of a Gateway are built from: the Gateway, its
+// HTTPRoutes, the ports of their backend Services and the nodes.
+func getGatewayVersion(gw *gwv1.Gateway, routes []*gwv1.HTTPRoute, services []*apiv1.Service, nodes []*apiv1.Node) string {
+	items := []string{fmt.Sprintf("gateway:%d:%v", gw.Generation, gw.Annotations)}
+	for _, route := range routes {
+		items = append(items, fmt.Sprintf("httproute:%s/%s:%d", route.Namespace, route.Name, route.Generation))
+	}
+	for _, svc := range services {
+		var ports []string
+		for _, p := range svc.Spec.Ports {
+			ports = append(ports, fmt.Sprintf("%d:%d", p.Port, p.NodePort))
+		}
+		items = append(items, fmt.Sprintf("service:%s/%s:%s", svc.Namespace, svc.Name, strings.Join(ports, ";")))
+	}
+	for _, name := range utils.NodeNames(nodes) {
+		items = append(items, fmt.Sprintf("node:%s", name))
+	}
+	sort.Strings(items)
+
+	return utils.Hash(strings.Join(items, ","))
+}
+
+// getGatewayRequestedAddress returns the IP address requested in the Gateway spec, it's used as an existing
+// floating IP.
+func getGatewayRequestedAddress(gw *gwv1.Gateway) string {
+	for _, addr := range gw.Spec.Addresses {
+		if addr.Type == nil || *addr.Type == gwv1.IPAddressType {
+			return addr.Value
+		}
+	}
+
+	return ""
+}
+
+func getGatewaySecurityGroupTags(gw *gwv1.Gateway) []string {
+	return []string{IngressControllerTag, fmt.Sprintf("gateway_%s_%s", gw.Namespace, gw.Name)}
+}
+
+// getStringFromGatewayAnnotation searches a given Gateway for a specific annotationKey and either returns the
+// annotation's value or a specified defaultSetting
+func getStringFromGatewayAnnotation(gw *gwv1.Gateway, annotationKey string, defaultValue string) string {
+	if annotationValue, ok := gw.Annotations[annotationKey]; ok {
+		return annotationValue
+	}
+
+	return defaultValue
+}
+
+func newCondition(conditionType string, status apimetav1.ConditionStatus, reason, message string, generation int64) apimetav1.Condition {
+	return apimetav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+		LastTransitionTime: apimetav1.Now(),
+	}
+}
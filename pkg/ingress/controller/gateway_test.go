@@ -0,0 +1,534 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	gwv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwfake "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+	gwlisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/config"
+	"k8s.io/cloud-provider-openstack/pkg/ingress/controller/openstack"
+)
+
+func newTestGateway(namespace, name, className string) *gwv1.Gateway {
+	return &gwv1.Gateway{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: gwv1.GatewaySpec{
+			GatewayClassName: gwv1.ObjectName(className),
+			Listeners:        []gwv1.Listener{{Name: "http", Port: 80, Protocol: gwv1.HTTPProtocolType}},
+		},
+	}
+}
+
+func newTestRoute(namespace, name string, parentRefs []gwv1.ParentReference, backend string) *gwv1.HTTPRoute {
+	return &gwv1.HTTPRoute{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: gwv1.HTTPRouteSpec{
+			CommonRouteSpec: gwv1.CommonRouteSpec{ParentRefs: parentRefs},
+			Rules: []gwv1.HTTPRouteRule{{
+				BackendRefs: []gwv1.HTTPBackendRef{{BackendRef: gwv1.BackendRef{
+					BackendObjectReference: gwv1.BackendObjectReference{Name: gwv1.ObjectName(backend), Port: ptr.To(gwv1.PortNumber(8080))},
+				}}},
+			}},
+		},
+	}
+}
+
+// newTestGatewayController returns a controller whose listers serve the objects.
+func newTestGatewayController(objs ...interface{}) *GatewayController {
+	indexer := func() cache.Indexer {
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+	classes, gws, routes, services, namespaces := indexer(), indexer(), indexer(), indexer(), indexer()
+	for _, obj := range objs {
+		switch obj.(type) {
+		case *gwv1.GatewayClass:
+			_ = classes.Add(obj)
+		case *gwv1.Gateway:
+			_ = gws.Add(obj)
+		case *gwv1.HTTPRoute:
+			_ = routes.Add(obj)
+		case *apiv1.Service:
+			_ = services.Add(obj)
+		case *apiv1.Namespace:
+			_ = namespaces.Add(obj)
+		}
+	}
+
+	// The events are queued without delay.
+	return &GatewayController{
+		controllerName:     DefaultGatewayControllerName,
+		queue:              workqueue.NewTypedRateLimitingQueue(workqueue.NewTypedItemExponentialFailureRateLimiter[any](0, 0)),
+		recorder:           record.NewFakeRecorder(10),
+		gatewayClassLister: gwlisters.NewGatewayClassLister(classes),
+		gatewayLister:      gwlisters.NewGatewayLister(gws),
+		httpRouteLister:    gwlisters.NewHTTPRouteLister(routes),
+		serviceLister:      corelisters.NewServiceLister(services),
+		namespaceLister:    corelisters.NewNamespaceLister(namespaces),
+	}
+}
+
+// queuedGateways drains the queue and returns the keys of the queued Gateways.
+func queuedGateways(c *GatewayController) []string {
+	var keys []string
+	for c.queue.Len() > 0 {
+		obj, _ := c.queue.Get()
+		if gw, ok := obj.(Event).Obj.(*gwv1.Gateway); ok {
+			keys = append(keys, fmt.Sprintf("%s/%s", gw.Namespace, gw.Name))
+		}
+		c.queue.Done(obj)
+	}
+	return keys
+}
+
+func TestGetGatewayRoutes(t *testing.T) {
+	gw := newTestGateway("infra", "gw", "octavia")
+	routes := []*gwv1.HTTPRoute{
+		newTestRoute("infra", "same-namespace", []gwv1.ParentReference{{Name: "gw"}}, "svc"),
+		newTestRoute("app", "cross-namespace", []gwv1.ParentReference{{Name: "gw", Namespace: ptr.To(gwv1.Namespace("infra"))}}, "svc"),
+		newTestRoute("app", "other-namespace", []gwv1.ParentReference{{Name: "gw"}}, "svc"),
+		newTestRoute("infra", "other-kind", []gwv1.ParentReference{{Name: "gw", Kind: ptr.To(gwv1.Kind("Service"))}}, "svc"),
+		newTestRoute("infra", "other-group", []gwv1.ParentReference{{Name: "gw", Group: ptr.To(gwv1.Group("example.com"))}}, "svc"),
+		newTestRoute("infra", "explicit", []gwv1.ParentReference{{Name: "gw", Group: ptr.To(gwv1.Group(gwv1.GroupName)), Kind: ptr.To(gwv1.Kind("Gateway"))}}, "svc"),
+		newTestRoute("infra", "other-name", []gwv1.ParentReference{{Name: "gw2"}}, "svc"),
+	}
+
+	var names []string
+	for _, route := range getGatewayRoutes(gw, routes) {
+		names = append(names, route.Name)
+	}
+	assert.Equal(t, []string{"same-namespace", "cross-namespace", "explicit"}, names)
+}
+
+func TestRouteForwardsToService(t *testing.T) {
+	route := newTestRoute("app", "route", nil, "svc")
+	assert.True(t, routeForwardsToService(route, "svc"))
+	assert.False(t, routeForwardsToService(route, "other"))
+
+	route.Spec.Rules[0].BackendRefs[0].Namespace = ptr.To(gwv1.Namespace("other"))
+	assert.False(t, routeForwardsToService(route, "svc"))
+
+	route = newTestRoute("app", "route", nil, "svc")
+	route.Spec.Rules[0].BackendRefs[0].Kind = ptr.To(gwv1.Kind("ServiceImport"))
+	route.Spec.Rules[0].BackendRefs[0].Group = ptr.To(gwv1.Group("multicluster.x-k8s.io"))
+	assert.False(t, routeForwardsToService(route, "svc"))
+}
+
+func TestIsRouteAllowed(t *testing.T) {
+	fromAll := gwv1.NamespacesFromAll
+	fromSelector := gwv1.NamespacesFromSelector
+	parentRefs := []gwv1.ParentReference{{Name: "gw", Namespace: ptr.To(gwv1.Namespace("infra"))}}
+
+	tests := []struct {
+		name     string
+		allowed  *gwv1.AllowedRoutes
+		route    *gwv1.HTTPRoute
+		expected bool
+	}{
+		{name: "same namespace", route: newTestRoute("infra", "r", []gwv1.ParentReference{{Name: "gw"}}, "svc"), expected: true},
+		{name: "other namespace by default", route: newTestRoute("app", "r", parentRefs, "svc")},
+		{name: "all namespaces", allowed: &gwv1.AllowedRoutes{Namespaces: &gwv1.RouteNamespaces{From: &fromAll}}, route: newTestRoute("app", "r", parentRefs, "svc"), expected: true},
+		{
+			name:     "selected namespace",
+			allowed:  &gwv1.AllowedRoutes{Namespaces: &gwv1.RouteNamespaces{From: &fromSelector, Selector: &apimetav1.LabelSelector{MatchLabels: map[string]string{"gateway": "true"}}}},
+			route:    newTestRoute("app", "r", parentRefs, "svc"),
+			expected: true,
+		},
+		{
+			name:    "unselected namespace",
+			allowed: &gwv1.AllowedRoutes{Namespaces: &gwv1.RouteNamespaces{From: &fromSelector, Selector: &apimetav1.LabelSelector{MatchLabels: map[string]string{"gateway": "false"}}}},
+			route:   newTestRoute("app", "r", parentRefs, "svc"),
+		},
+		{
+			name:    "other kind",
+			allowed: &gwv1.AllowedRoutes{Kinds: []gwv1.RouteGroupKind{{Kind: "GRPCRoute"}}},
+			route:   newTestRoute("infra", "r", []gwv1.ParentReference{{Name: "gw"}}, "svc"),
+		},
+		{name: "other section", route: newTestRoute("infra", "r", []gwv1.ParentReference{{Name: "gw", SectionName: ptr.To(gwv1.SectionName("https"))}}, "svc")},
+		{name: "other port", route: newTestRoute("infra", "r", []gwv1.ParentReference{{Name: "gw", Port: ptr.To(gwv1.PortNumber(443))}}, "svc")},
+		{name: "other gateway kind", route: newTestRoute("infra", "r", []gwv1.ParentReference{{Name: "gw", Kind: ptr.To(gwv1.Kind("ListenerSet"))}}, "svc")},
+	}
+
+	c := newTestGatewayController(&apiv1.Namespace{ObjectMeta: apimetav1.ObjectMeta{Name: "app", Labels: map[string]string{"gateway": "true"}}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := newTestGateway("infra", "gw", "octavia")
+			gw.Spec.Listeners[0].AllowedRoutes = tt.allowed
+			listener := gw.Spec.Listeners[0]
+			attached := refersToListener(gw, listener, tt.route.Namespace, tt.route.Spec.ParentRefs[0]) && c.isRouteAllowed(gw, listener, tt.route)
+			assert.Equal(t, tt.expected, attached)
+		})
+	}
+}
+
+func TestEnqueueServiceGateways(t *testing.T) {
+	class := &gwv1.GatewayClass{
+		ObjectMeta: apimetav1.ObjectMeta{Name: "octavia"},
+		Spec:       gwv1.GatewayClassSpec{ControllerName: DefaultGatewayControllerName},
+	}
+	c := newTestGatewayController(
+		class,
+		newTestGateway("infra", "gw", "octavia"),
+		newTestGateway("infra", "other-class", "other"),
+		newTestRoute("app", "route", []gwv1.ParentReference{
+			{Name: "gw", Namespace: ptr.To(gwv1.Namespace("infra"))},
+			{Name: "other-class", Namespace: ptr.To(gwv1.Namespace("infra"))},
+		}, "svc"),
+		newTestRoute("app", "other-backend", []gwv1.ParentReference{{Name: "gw", Namespace: ptr.To(gwv1.Namespace("infra"))}}, "other"),
+	)
+
+	c.enqueueServiceGateways(&apiv1.Service{ObjectMeta: apimetav1.ObjectMeta{Namespace: "app", Name: "svc"}})
+	assert.Equal(t, []string{"infra/gw"}, queuedGateways(c))
+
+	c.enqueueServiceGateways(&apiv1.Service{ObjectMeta: apimetav1.ObjectMeta{Namespace: "infra", Name: "svc"}})
+	assert.Empty(t, queuedGateways(c))
+}
+
+func TestEnqueueClassGateways(t *testing.T) {
+	orphan := newTestGateway("infra", "orphan", "deleted")
+	orphan.Finalizers = []string{gatewayFinalizer}
+	c := newTestGatewayController(orphan, newTestGateway("infra", "unmanaged", "deleted"))
+
+	// The Gateways of a deleted GatewayClass are only queued for the cleanup of their load balancer.
+	c.enqueueClassGateways("deleted")
+	assert.Equal(t, []string{"infra/orphan"}, queuedGateways(c))
+}
+
+func TestGetGatewayVersion(t *testing.T) {
+	gw := newTestGateway("infra", "gw", "octavia")
+	routes := []*gwv1.HTTPRoute{newTestRoute("infra", "route", []gwv1.ParentReference{{Name: "gw"}}, "svc")}
+	svc := &apiv1.Service{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: "infra", Name: "svc"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Port: 8080, NodePort: 30080}}},
+	}
+	nodes := []*apiv1.Node{{ObjectMeta: apimetav1.ObjectMeta{Name: "node-1"}}}
+
+	version := getGatewayVersion(gw, routes, []*apiv1.Service{svc}, nodes)
+	assert.Equal(t, version, getGatewayVersion(gw, routes, []*apiv1.Service{svc.DeepCopy()}, nodes))
+
+	changedSvc := svc.DeepCopy()
+	changedSvc.Spec.Ports[0].NodePort = 30081
+	assert.NotEqual(t, version, getGatewayVersion(gw, routes, []*apiv1.Service{changedSvc}, nodes))
+
+	changedRoute := routes[0].DeepCopy()
+	changedRoute.Generation++
+	assert.NotEqual(t, version, getGatewayVersion(gw, []*gwv1.HTTPRoute{changedRoute}, []*apiv1.Service{svc}, nodes))
+
+	assert.NotEqual(t, version, getGatewayVersion(gw, routes, []*apiv1.Service{svc}, nil))
+}
+
+func TestIntersectHostnames(t *testing.T) {
+	hostname := func(h string) *gwv1.Hostname { return ptr.To(gwv1.Hostname(h)) }
+
+	tests := []struct {
+		name     string
+		listener *gwv1.Hostname
+		route    []gwv1.Hostname
+		expected []string
+	}{
+		{name: "any", expected: []string{""}},
+		{name: "listener only", listener: hostname("foo.example.com"), expected: []string{"foo.example.com"}},
+		{name: "route only", route: []gwv1.Hostname{"foo.example.com", "bar.example.com"}, expected: []string{"foo.example.com", "bar.example.com"}},
+		{name: "exact match", listener: hostname("foo.example.com"), route: []gwv1.Hostname{"foo.example.com", "bar.example.com"}, expected: []string{"foo.example.com"}},
+		{name: "listener wildcard", listener: hostname("*.example.com"), route: []gwv1.Hostname{"foo.example.com", "example.com"}, expected: []string{"foo.example.com"}},
+		{name: "route wildcard", listener: hostname("foo.example.com"), route: []gwv1.Hostname{"*.example.com"}, expected: []string{"foo.example.com"}},
+		{name: "no match", listener: hostname("foo.example.com"), route: []gwv1.Hostname{"foo.example.org"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, intersectHostnames(tt.listener, tt.route))
+		})
+	}
+}
+
+func TestGetRulePolicyRules(t *testing.T) {
+	exact := gwv1.PathMatchExact
+	matches := []gwv1.HTTPRouteMatch{
+		{Path: &gwv1.HTTPPathMatch{Type: &exact, Value: ptr.To("/api")}, Headers: []gwv1.HTTPHeaderMatch{{Name: "version", Value: "v2"}}},
+		{Method: ptr.To(gwv1.HTTPMethodGet)},
+	}
+
+	logger := log.NewEntry(log.StandardLogger())
+	policies := getRulePolicyRules([]string{"foo.example.com", "*.example.org"}, 80, matches, logger)
+	assert.Equal(t, [][]l7policies.CreateRuleOpts{
+		{
+			{RuleType: l7policies.TypeHostName, CompareType: l7policies.CompareTypeRegex, Value: `^foo\.example\.com(:80)?$`},
+			{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeEqual, Value: "/api"},
+			{RuleType: l7policies.TypeHeader, CompareType: l7policies.CompareTypeEqual, Key: "version", Value: "v2"},
+		},
+		{
+			{RuleType: l7policies.TypeHostName, CompareType: l7policies.CompareTypeRegex, Value: `^[^.]+(\.[^.]+)*\.example\.org(:80)?$`},
+			{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeEqual, Value: "/api"},
+			{RuleType: l7policies.TypeHeader, CompareType: l7policies.CompareTypeEqual, Key: "version", Value: "v2"},
+		},
+	}, policies)
+
+	assert.Equal(t, [][]l7policies.CreateRuleOpts{
+		{{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeStartWith, Value: "/"}},
+	}, getRulePolicyRules([]string{""}, 80, nil, logger))
+}
+
+func TestSortPolicies(t *testing.T) {
+	policy := func(name string, rules ...l7policies.CreateRuleOpts) openstack.IngPolicy {
+		return openstack.IngPolicy{RedirectPoolName: name, RulesOpts: rules}
+	}
+	host := l7policies.CreateRuleOpts{RuleType: l7policies.TypeHostName, Value: hostnameRegex("foo.example.com", 80)}
+	wildcard := l7policies.CreateRuleOpts{RuleType: l7policies.TypeHostName, Value: hostnameRegex("*.example.com", 80)}
+	prefix := l7policies.CreateRuleOpts{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeStartWith, Value: "/"}
+	longPrefix := l7policies.CreateRuleOpts{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeStartWith, Value: "/api"}
+	exactPath := l7policies.CreateRuleOpts{RuleType: l7policies.TypePath, CompareType: l7policies.CompareTypeEqual, Value: "/"}
+	header := l7policies.CreateRuleOpts{RuleType: l7policies.TypeHeader, Key: "version", Value: "v2"}
+
+	policies := []openstack.IngPolicy{
+		policy("any", prefix),
+		policy("wildcard", wildcard, prefix),
+		policy("host-prefix", host, prefix),
+		policy("host-header", host, prefix, header),
+		policy("host-long-prefix", host, longPrefix),
+		policy("host-exact", host, exactPath),
+	}
+	sortPolicies(policies)
+
+	var names []string
+	for _, p := range policies {
+		names = append(names, p.RedirectPoolName)
+	}
+	assert.Equal(t, []string{"host-exact", "host-long-prefix", "host-header", "host-prefix", "wildcard", "any"}, names)
+}
+
+func TestProcessItemDeletesGatewayOfDeletedClass(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancers": []}`)
+	})
+
+	gw := newTestGateway("infra", "gw", "deleted")
+	gw.Finalizers = []string{gatewayFinalizer, "example.com/other"}
+	c := newTestGatewayController(gw)
+	// The Gateway API types are registered in several versions, the fake tracker only finds the created objects.
+	gwClient := gwfake.NewSimpleClientset()
+	_, err := gwClient.GatewayV1().Gateways("infra").Create(context.TODO(), gw, apimetav1.CreateOptions{})
+	assert.NoError(t, err)
+	c.gwClient = gwClient
+	c.config = config.Config{ClusterName: "kubernetes"}
+	c.osClient = &openstack.OpenStack{Octavia: fakeclient.ServiceClient()}
+
+	assert.NoError(t, c.processItem(context.TODO(), Event{Obj: gw, Type: UpdateEvent}))
+
+	updated, err := gwClient.GatewayV1().Gateways("infra").Get(context.TODO(), "gw", apimetav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com/other"}, updated.Finalizers)
+
+	// Without the finalizer, the Gateway of another GatewayClass is ignored.
+	th.Mux.HandleFunc("/lbaas/loadbalancers/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+	c = newTestGatewayController(updated)
+	c.gwClient = gwClient
+	assert.NoError(t, c.processItem(context.TODO(), Event{Obj: updated, Type: UpdateEvent}))
+}
+
+func TestGetListenerConflicts(t *testing.T) {
+	listeners := []gwv1.Listener{
+		{Name: "http", Port: 80, Protocol: gwv1.HTTPProtocolType},
+		{Name: "tcp", Port: 8080, Protocol: gwv1.TCPProtocolType},
+		{Name: "other-host", Port: 80, Protocol: gwv1.HTTPProtocolType, Hostname: ptr.To(gwv1.Hostname("example.com"))},
+		{Name: "alt", Port: 8080, Protocol: gwv1.HTTPProtocolType},
+		{Name: "alt-again", Port: 8080, Protocol: gwv1.HTTPProtocolType},
+	}
+
+	assert.Equal(t, map[gwv1.SectionName]gwv1.SectionName{"other-host": "http", "alt-again": "alt"}, getListenerConflicts(listeners))
+}
+
+func TestGetRouteConditions(t *testing.T) {
+	svc := &apiv1.Service{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: "app", Name: "svc"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Port: 8080, NodePort: 30080}}},
+	}
+	c := newTestGatewayController(svc)
+
+	withFilter := newTestRoute("app", "r", nil, "svc")
+	withFilter.Spec.Rules[0].Filters = []gwv1.HTTPRouteFilter{{Type: gwv1.HTTPRouteFilterRequestHeaderModifier}}
+
+	partial := newTestRoute("app", "r", nil, "svc")
+	partial.Spec.Rules = append(partial.Spec.Rules, gwv1.HTTPRouteRule{BackendRefs: append(partial.Spec.Rules[0].BackendRefs, partial.Spec.Rules[0].BackendRefs...)})
+
+	otherKind := newTestRoute("app", "r", nil, "svc")
+	otherKind.Spec.Rules[0].BackendRefs[0].Kind = ptr.To(gwv1.Kind("ConfigMap"))
+
+	type condition struct {
+		status apimetav1.ConditionStatus
+		reason gwv1.RouteConditionReason
+	}
+	tests := []struct {
+		name     string
+		route    *gwv1.HTTPRoute
+		state    parentRefState
+		expected map[gwv1.RouteConditionType]condition
+	}{
+		{
+			name:  "accepted",
+			route: newTestRoute("app", "r", nil, "svc"),
+			state: parentRefAttached,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionTrue, gwv1.RouteReasonAccepted},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionTrue, gwv1.RouteReasonResolvedRefs},
+			},
+		},
+		{
+			name:  "missing backend",
+			route: newTestRoute("app", "r", nil, "missing"),
+			state: parentRefAttached,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionTrue, gwv1.RouteReasonAccepted},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionFalse, gwv1.RouteReasonBackendNotFound},
+			},
+		},
+		{
+			name:  "other backend kind",
+			route: otherKind,
+			state: parentRefAttached,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionTrue, gwv1.RouteReasonAccepted},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionFalse, gwv1.RouteReasonInvalidKind},
+			},
+		},
+		{
+			name:  "filters",
+			route: withFilter,
+			state: parentRefAttached,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionFalse, gwv1.RouteReasonUnsupportedValue},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionTrue, gwv1.RouteReasonResolvedRefs},
+			},
+		},
+		{
+			name:  "additional backendRefs",
+			route: partial,
+			state: parentRefAttached,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:         {apimetav1.ConditionTrue, gwv1.RouteReasonAccepted},
+				gwv1.RouteConditionPartiallyInvalid: {apimetav1.ConditionTrue, gwv1.RouteReasonUnsupportedValue},
+				gwv1.RouteConditionResolvedRefs:     {apimetav1.ConditionTrue, gwv1.RouteReasonResolvedRefs},
+			},
+		},
+		{
+			name:  "not allowed",
+			route: newTestRoute("app", "r", nil, "svc"),
+			state: parentRefNotAllowed,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionFalse, gwv1.RouteReasonNotAllowedByListeners},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionTrue, gwv1.RouteReasonResolvedRefs},
+			},
+		},
+		{
+			name:  "no matching hostname",
+			route: newTestRoute("app", "r", nil, "svc"),
+			state: parentRefNoMatchingHostname,
+			expected: map[gwv1.RouteConditionType]condition{
+				gwv1.RouteConditionAccepted:     {apimetav1.ConditionFalse, gwv1.RouteReasonNoMatchingListenerHostname},
+				gwv1.RouteConditionResolvedRefs: {apimetav1.ConditionTrue, gwv1.RouteReasonResolvedRefs},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions := map[gwv1.RouteConditionType]condition{}
+			for _, cond := range c.getRouteConditions(tt.route, tt.state) {
+				conditions[gwv1.RouteConditionType(cond.Type)] = condition{cond.Status, gwv1.RouteConditionReason(cond.Reason)}
+			}
+			assert.Equal(t, tt.expected, conditions)
+		})
+	}
+}
+
+func TestUpdateRouteStatuses(t *testing.T) {
+	gw := newTestGateway("infra", "gw", "octavia")
+	svc := &apiv1.Service{
+		ObjectMeta: apimetav1.ObjectMeta{Namespace: "infra", Name: "svc"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Port: 8080, NodePort: 30080}}},
+	}
+	otherParent := gwv1.RouteParentStatus{
+		ParentRef:      gwv1.ParentReference{Name: "other"},
+		ControllerName: "example.com/other",
+		Conditions:     []apimetav1.Condition{{Type: string(gwv1.RouteConditionAccepted), Status: apimetav1.ConditionTrue}},
+	}
+
+	attached := newTestRoute("infra", "attached", []gwv1.ParentReference{{Name: "gw"}, {Name: "gw", SectionName: ptr.To(gwv1.SectionName("https"))}}, "svc")
+	attached.Status.Parents = []gwv1.RouteParentStatus{otherParent}
+	// The route was detached from the Gateway, the status of its former parent reference is removed.
+	detached := newTestRoute("infra", "detached", []gwv1.ParentReference{{Name: "other"}}, "svc")
+	detached.Status.Parents = []gwv1.RouteParentStatus{
+		otherParent,
+		{ParentRef: gwv1.ParentReference{Name: "gw"}, ControllerName: DefaultGatewayControllerName},
+	}
+
+	c := newTestGatewayController(gw, svc, attached, detached)
+	gwClient := gwfake.NewSimpleClientset()
+	for _, route := range []*gwv1.HTTPRoute{attached, detached} {
+		_, err := gwClient.GatewayV1().HTTPRoutes("infra").Create(context.TODO(), route, apimetav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	c.gwClient = gwClient
+
+	refStates := map[string][]parentRefState{"infra/attached": {parentRefAttached, parentRefNoMatchingParent}}
+	assert.NoError(t, c.updateRouteStatuses(context.TODO(), gw, []*gwv1.HTTPRoute{attached, detached}, refStates))
+
+	updated, err := gwClient.GatewayV1().HTTPRoutes("infra").Get(context.TODO(), "attached", apimetav1.GetOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, updated.Status.Parents, 3) {
+		assert.Equal(t, otherParent, updated.Status.Parents[0])
+		assert.Equal(t, attached.Spec.ParentRefs[0], updated.Status.Parents[1].ParentRef)
+		assert.Equal(t, gwv1.GatewayController(DefaultGatewayControllerName), updated.Status.Parents[1].ControllerName)
+		assert.True(t, apimeta.IsStatusConditionTrue(updated.Status.Parents[1].Conditions, string(gwv1.RouteConditionAccepted)))
+		assert.True(t, apimeta.IsStatusConditionTrue(updated.Status.Parents[1].Conditions, string(gwv1.RouteConditionResolvedRefs)))
+		assert.Equal(t, attached.Spec.ParentRefs[1], updated.Status.Parents[2].ParentRef)
+		accepted := apimeta.FindStatusCondition(updated.Status.Parents[2].Conditions, string(gwv1.RouteConditionAccepted))
+		if assert.NotNil(t, accepted) {
+			assert.Equal(t, apimetav1.ConditionFalse, accepted.Status)
+			assert.Equal(t, string(gwv1.RouteReasonNoMatchingParent), accepted.Reason)
+		}
+	}
+
+	updated, err = gwClient.GatewayV1().HTTPRoutes("infra").Get(context.TODO(), "detached", apimetav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []gwv1.RouteParentStatus{otherParent}, updated.Status.Parents)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	nwv1 "k8s.io/api/networking/v1"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/controller/openstack"
+)
+
+// ingressPathRule returns the l7 rule matching the requests of an Ingress path following its pathType:
+//   - Exact matches the path exactly.
+//   - Prefix matches the path element by element, "/foo" matches "/foo" and "/foo/bar" but not "/foobar", the trailing
+//     slash is ignored.
+//   - ImplementationSpecific, or no pathType, matches the path as a string prefix.
+func ingressPathRule(path nwv1.HTTPIngressPath) l7policies.CreateRuleOpts {
+	rule := l7policies.CreateRuleOpts{
+		RuleType:    l7policies.TypePath,
+		CompareType: l7policies.CompareTypeStartWith,
+		Value:       path.Path,
+	}
+
+	if path.PathType == nil {
+		return rule
+	}
+
+	switch *path.PathType {
+	case nwv1.PathTypeExact:
+		rule.CompareType = l7policies.CompareTypeEqual
+	case nwv1.PathTypePrefix:
+		prefix := strings.TrimRight(path.Path, "/")
+		if prefix == "" {
+			// "/" matches all the paths
+			rule.Value = "/"
+			break
+		}
+		rule.CompareType = l7policies.CompareTypeRegex
+		rule.Value = fmt.Sprintf("^%s(/.*)?$", regexp.QuoteMeta(prefix))
+	}
+
+	return rule
+}
+
+// ingressPolicy is an l7 policy of an Ingress path along with the precedence of the path.
+type ingressPolicy struct {
+	policy  openstack.IngPolicy
+	host    bool
+	exact   bool
+	pathLen int
+}
+
+// sortIngressPolicies returns the l7 policies of the Ingress paths by precedence, positioned in that order in the
+// listener: the paths of a host first, then the exact paths and at last the longest prefixes.
+func sortIngressPolicies(policies []ingressPolicy) []openstack.IngPolicy {
+	sort.SliceStable(policies, func(i, j int) bool {
+		pi, pj := policies[i], policies[j]
+		if pi.host != pj.host {
+			return pi.host
+		}
+		if pi.exact != pj.exact {
+			return pi.exact
+		}
+		return pi.pathLen > pj.pathLen
+	})
+
+	sorted := make([]openstack.IngPolicy, 0, len(policies))
+	for i, p := range policies {
+		p.policy.Opts.Position = int32(i + 1)
+		sorted = append(sorted, p.policy)
+	}
+
+	return sorted
+}
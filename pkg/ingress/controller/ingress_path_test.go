@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	"github.com/stretchr/testify/assert"
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/utils/ptr"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/controller/openstack"
+)
+
+func TestIngressPathRule(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		pathType    *nwv1.PathType
+		compareType l7policies.CompareType
+		value       string
+		matches     []string
+		misses      []string
+	}{
+		{
+			name:        "no pathType",
+			path:        "/foo",
+			compareType: l7policies.CompareTypeStartWith,
+			value:       "/foo",
+		},
+		{
+			name:        "implementation specific",
+			path:        "/foo",
+			pathType:    ptr.To(nwv1.PathTypeImplementationSpecific),
+			compareType: l7policies.CompareTypeStartWith,
+			value:       "/foo",
+		},
+		{
+			name:        "exact",
+			path:        "/foo",
+			pathType:    ptr.To(nwv1.PathTypeExact),
+			compareType: l7policies.CompareTypeEqual,
+			value:       "/foo",
+		},
+		{
+			name:        "prefix",
+			path:        "/foo",
+			pathType:    ptr.To(nwv1.PathTypePrefix),
+			compareType: l7policies.CompareTypeRegex,
+			value:       "^/foo(/.*)?$",
+			matches:     []string{"/foo", "/foo/", "/foo/bar"},
+			misses:      []string{"/foobar", "/", "/bar/foo"},
+		},
+		{
+			name:        "prefix with trailing slash",
+			path:        "/foo/bar/",
+			pathType:    ptr.To(nwv1.PathTypePrefix),
+			compareType: l7policies.CompareTypeRegex,
+			value:       "^/foo/bar(/.*)?$",
+			matches:     []string{"/foo/bar", "/foo/bar/baz"},
+			misses:      []string{"/foo/barbaz", "/foo"},
+		},
+		{
+			name:        "prefix with special characters",
+			path:        "/v1.0+",
+			pathType:    ptr.To(nwv1.PathTypePrefix),
+			compareType: l7policies.CompareTypeRegex,
+			value:       `^/v1\.0\+(/.*)?$`,
+			matches:     []string{"/v1.0+", "/v1.0+/x"},
+			misses:      []string{"/v1x0+", "/v1.00"},
+		},
+		{
+			name:        "root prefix",
+			path:        "/",
+			pathType:    ptr.To(nwv1.PathTypePrefix),
+			compareType: l7policies.CompareTypeStartWith,
+			value:       "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := ingressPathRule(nwv1.HTTPIngressPath{Path: tt.path, PathType: tt.pathType})
+			assert.Equal(t, l7policies.TypePath, rule.RuleType)
+			assert.Equal(t, tt.compareType, rule.CompareType)
+			assert.Equal(t, tt.value, rule.Value)
+
+			if rule.CompareType != l7policies.CompareTypeRegex {
+				return
+			}
+			re := regexp.MustCompile(rule.Value)
+			for _, p := range tt.matches {
+				assert.True(t, re.MatchString(p), "%s should match %s", rule.Value, p)
+			}
+			for _, p := range tt.misses {
+				assert.False(t, re.MatchString(p), "%s should not match %s", rule.Value, p)
+			}
+		})
+	}
+}
+
+func TestSortIngressPolicies(t *testing.T) {
+	policy := func(name string, host, exact bool, pathLen int) ingressPolicy {
+		return ingressPolicy{
+			policy:  openstack.IngPolicy{RedirectPoolName: name},
+			host:    host,
+			exact:   exact,
+			pathLen: pathLen,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		policies []ingressPolicy
+		expected []string
+	}{
+		{
+			name:     "empty",
+			expected: []string{},
+		},
+		{
+			name: "host first",
+			policies: []ingressPolicy{
+				policy("any-host", false, true, 10),
+				policy("host", true, false, 1),
+			},
+			expected: []string{"host", "any-host"},
+		},
+		{
+			name: "exact before prefix",
+			policies: []ingressPolicy{
+				policy("prefix", true, false, 10),
+				policy("exact", true, true, 1),
+			},
+			expected: []string{"exact", "prefix"},
+		},
+		{
+			name: "longest prefix first",
+			policies: []ingressPolicy{
+				policy("/", false, false, 1),
+				policy("/foo/bar", false, false, 8),
+				policy("/foo", false, false, 4),
+			},
+			expected: []string{"/foo/bar", "/foo", "/"},
+		},
+		{
+			name: "same precedence keeps the order of the Ingress",
+			policies: []ingressPolicy{
+				policy("first", false, false, 4),
+				policy("second", false, false, 4),
+				policy("third", false, false, 4),
+			},
+			expected: []string{"first", "second", "third"},
+		},
+		{
+			name: "all criteria",
+			policies: []ingressPolicy{
+				policy("prefix", false, false, 4),
+				policy("host-prefix", true, false, 4),
+				policy("exact", false, true, 4),
+				policy("host-long-prefix", true, false, 8),
+				policy("host-exact", true, true, 1),
+			},
+			expected: []string{"host-exact", "host-long-prefix", "host-prefix", "exact", "prefix"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := sortIngressPolicies(tt.policies)
+			names := make([]string, 0, len(sorted))
+			for i, p := range sorted {
+				names = append(names, p.RedirectPoolName)
+				// The positions in the listener start at 1
+				assert.Equal(t, int32(i+1), p.Opts.Position)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/utils"
+)
+
+// isLocalEndpoints returns true if the pool members of the Ingress are restricted to the nodes running the endpoints.
+func isLocalEndpoints(ing *nwv1.Ingress) (bool, error) {
+	localEndpoints, err := strconv.ParseBool(getStringFromIngressAnnotation(ing, IngressAnnotationLocalEndpoints, "false"))
+	if err != nil {
+		return false, fmt.Errorf("unknown annotation %s: %v", IngressAnnotationLocalEndpoints, err)
+	}
+	return localEndpoints, nil
+}
+
+// getIngressBackendServices returns the names of the backend services of the Ingress.
+func getIngressBackendServices(ing *nwv1.Ingress) []string {
+	var services []string
+	if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+		services = append(services, ing.Spec.DefaultBackend.Service.Name)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && !slices.Contains(services, path.Backend.Service.Name) {
+				services = append(services, path.Backend.Service.Name)
+			}
+		}
+	}
+	return services
+}
+
+// getEndpointNodes returns the names of the nodes running the ready endpoints of the service.
+func getEndpointNodes(endpointSlices []*discoveryv1.EndpointSlice) sets.Set[string] {
+	nodes := sets.New[string]()
+	for _, endpointSlice := range endpointSlices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			// A nil ready condition means ready
+			if endpoint.NodeName == nil || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+			nodes.Insert(*endpoint.NodeName)
+		}
+	}
+	return nodes
+}
+
+// getLocalNodes returns, for each backend service of the Ingress, the ready nodes running its ready endpoints.
+func (c *Controller) getLocalNodes(ing *nwv1.Ingress, nodes []*apiv1.Node) (map[string]sets.Set[string], error) {
+	nodeNames := sets.New(utils.NodeNames(nodes)...)
+	localNodes := map[string]sets.Set[string]{}
+	for _, service := range getIngressBackendServices(ing) {
+		selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service})
+		endpointSlices, err := c.epSliceLister.EndpointSlices(ing.Namespace).List(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the endpoint slices of service %s/%s: %v", ing.Namespace, service, err)
+		}
+		localNodes[service] = getEndpointNodes(endpointSlices).Intersection(nodeNames)
+	}
+	return localNodes, nil
+}
+
+// localNodesVersion returns the digest of the nodes of the backend services, recorded in the load balancer
+// description so that the Ingress is reconciled when the endpoints move to other nodes.
+func localNodesVersion(localNodes map[string]sets.Set[string]) string {
+	services := make([]string, 0, len(localNodes))
+	for service, nodes := range localNodes {
+		services = append(services, fmt.Sprintf("%s=%s", service, strings.Join(sets.List(nodes), ",")))
+	}
+	slices.Sort(services)
+	return utils.Hash(strings.Join(services, ";"))[:16]
+}
+
+// filterMemberOpts returns the pool members of the nodes, the members are named after their node.
+func filterMemberOpts(memberOpts []pools.BatchUpdateMemberOpts, nodes sets.Set[string]) []pools.BatchUpdateMemberOpts {
+	filtered := make([]pools.BatchUpdateMemberOpts, 0, len(memberOpts))
+	for _, member := range memberOpts {
+		if member.Name != nil && nodes.Has(*member.Name) {
+			filtered = append(filtered, member)
+		}
+	}
+	return filtered
+}
+
+// backendMemberOpts returns a copy of the pool members of the backend service, restricted to its local nodes if any.
+func backendMemberOpts(memberOpts []pools.BatchUpdateMemberOpts, localNodes map[string]sets.Set[string], service string) []pools.BatchUpdateMemberOpts {
+	if localNodes != nil {
+		return filterMemberOpts(memberOpts, localNodes[service])
+	}
+	members := make([]pools.BatchUpdateMemberOpts, len(memberOpts))
+	copy(members, memberOpts)
+	return members
+}
+
+// startEndpointSliceInformer starts watching the endpoint slices, the first time an Ingress restricted to the local
+// endpoints is reconciled, and waits for their cache to sync. The clusters without such Ingresses don't cache the
+// endpoint slices of all the services.
+func (c *Controller) startEndpointSliceInformer() error {
+	// The informers already started aren't started again
+	c.epSliceInformer.Start(c.stopCh)
+	if !cache.WaitForCacheSync(c.stopCh, c.epSliceListerSynced) {
+		return fmt.Errorf("timed out waiting for the endpoint slices cache to sync")
+	}
+	return nil
+}
+
+// endpointSliceEventHandler queues the update of the Ingresses whose local endpoints changed. The initial list is
+// skipped, the Ingress starting the informer is being reconciled.
+func (c *Controller) endpointSliceEventHandler() cache.ResourceEventHandlerDetailedFuncs {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList {
+				return
+			}
+			c.enqueueEndpointSliceIngresses(obj.(*discoveryv1.EndpointSlice))
+		},
+		UpdateFunc: func(old, new interface{}) {
+			newSlice := new.(*discoveryv1.EndpointSlice)
+			oldSlice := old.(*discoveryv1.EndpointSlice)
+			if reflect.DeepEqual(newSlice.Endpoints, oldSlice.Endpoints) {
+				return
+			}
+			c.enqueueEndpointSliceIngresses(newSlice)
+		},
+		DeleteFunc: func(obj interface{}) {
+			delSlice, ok := obj.(*discoveryv1.EndpointSlice)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					log.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				delSlice, ok = tombstone.Obj.(*discoveryv1.EndpointSlice)
+				if !ok {
+					log.Errorf("Tombstone contained object that is not an EndpointSlice: %#v", obj)
+					return
+				}
+			}
+			c.enqueueEndpointSliceIngresses(delSlice)
+		},
+	}
+}
+
+// enqueueEndpointSliceIngresses queues the update of the Ingresses restricted to the local endpoints of the service
+// of the endpoint slice.
+func (c *Controller) enqueueEndpointSliceIngresses(endpointSlice *discoveryv1.EndpointSlice) {
+	service := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	if service == "" {
+		return
+	}
+
+	ings, err := c.ingressLister.Ingresses(endpointSlice.Namespace).List(labels.Everything())
+	if err != nil {
+		log.WithFields(log.Fields{"service": service, "namespace": endpointSlice.Namespace}).Errorf("Failed to retrieve the ingresses: %v", err)
+		return
+	}
+
+	for _, ing := range ings {
+		if localEndpoints, _ := isLocalEndpoints(ing); !localEndpoints || !IsValid(ing) || !slices.Contains(getIngressBackendServices(ing), service) {
+			continue
+		}
+
+		log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ing.Namespace, ing.Name), "service": service}).Debug("endpoints changed")
+		c.queue.AddRateLimited(Event{Obj: ing.DeepCopy(), Type: UpdateEvent})
+	}
+}
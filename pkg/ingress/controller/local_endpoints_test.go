@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/stretchr/testify/assert"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+)
+
+func newTestEndpointSlice(namespace, service string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: apimetav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      service + "-abcde",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		Endpoints: endpoints,
+	}
+}
+
+func TestGetEndpointNodes(t *testing.T) {
+	slices := []*discoveryv1.EndpointSlice{
+		newTestEndpointSlice("app", "svc",
+			discoveryv1.Endpoint{NodeName: ptr.To("node-1")},
+			discoveryv1.Endpoint{NodeName: ptr.To("node-2"), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+			discoveryv1.Endpoint{NodeName: ptr.To("node-3"), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+			discoveryv1.Endpoint{},
+		),
+		newTestEndpointSlice("app", "svc", discoveryv1.Endpoint{NodeName: ptr.To("node-1")}),
+	}
+
+	// The endpoints without node or not ready are skipped
+	assert.Equal(t, sets.New("node-1", "node-2"), getEndpointNodes(slices))
+	assert.Empty(t, getEndpointNodes(nil))
+}
+
+func TestLocalNodesVersion(t *testing.T) {
+	version := localNodesVersion(map[string]sets.Set[string]{
+		"svc-a": sets.New("node-1", "node-2"),
+		"svc-b": sets.New("node-3"),
+	})
+	assert.Len(t, version, 16)
+
+	// The order of the services and of the nodes doesn't matter
+	assert.Equal(t, version, localNodesVersion(map[string]sets.Set[string]{
+		"svc-b": sets.New("node-3"),
+		"svc-a": sets.New("node-2", "node-1"),
+	}))
+
+	// An endpoint moving to another node changes the version
+	assert.NotEqual(t, version, localNodesVersion(map[string]sets.Set[string]{
+		"svc-a": sets.New("node-1", "node-3"),
+		"svc-b": sets.New("node-3"),
+	}))
+	assert.NotEqual(t, version, localNodesVersion(map[string]sets.Set[string]{
+		"svc-a": sets.New("node-1", "node-2", "node-3"),
+	}))
+}
+
+func TestFilterMemberOpts(t *testing.T) {
+	members := []pools.BatchUpdateMemberOpts{
+		{Name: ptr.To("node-1"), Address: "10.0.0.1", ProtocolPort: 30080},
+		{Name: ptr.To("node-2"), Address: "10.0.0.2", ProtocolPort: 30080},
+		{Address: "10.0.0.3", ProtocolPort: 30080},
+	}
+
+	filtered := filterMemberOpts(members, sets.New("node-2", "node-4"))
+	assert.Equal(t, []pools.BatchUpdateMemberOpts{members[1]}, filtered)
+
+	// No local node means no member, not all of them
+	assert.Empty(t, filterMemberOpts(members, nil))
+	assert.NotNil(t, filterMemberOpts(members, nil))
+
+	// The members of the services without local endpoints are all the nodes
+	assert.Equal(t, members, backendMemberOpts(members, nil, "svc"))
+	assert.Empty(t, backendMemberOpts(members, map[string]sets.Set[string]{"other": sets.New("node-1")}, "svc"))
+}
+
+func TestEndpointSliceEventHandler(t *testing.T) {
+	local := newTestIngress("app", "local")
+	local.Annotations[IngressAnnotationLocalEndpoints] = "true"
+	local.Spec.DefaultBackend = &nwv1.IngressBackend{Service: &nwv1.IngressServiceBackend{Name: "svc"}}
+	all := newTestIngress("app", "all")
+	all.Spec.DefaultBackend = &nwv1.IngressBackend{Service: &nwv1.IngressServiceBackend{Name: "svc"}}
+	c := newTestController(local, all)
+	handler := c.endpointSliceEventHandler()
+	slice := newTestEndpointSlice("app", "svc", discoveryv1.Endpoint{NodeName: ptr.To("node-1")})
+
+	// The initial list is skipped
+	handler.OnAdd(slice, true)
+	assert.Empty(t, queuedIngresses(c))
+
+	handler.OnAdd(slice, false)
+	assert.Equal(t, []string{"app/local"}, queuedIngresses(c))
+
+	// The changes other than the endpoints are skipped
+	relabeled := slice.DeepCopy()
+	relabeled.Annotations = map[string]string{"changed": "true"}
+	handler.OnUpdate(slice, relabeled)
+	assert.Empty(t, queuedIngresses(c))
+
+	moved := slice.DeepCopy()
+	moved.Endpoints[0].NodeName = ptr.To("node-2")
+	handler.OnUpdate(slice, moved)
+	assert.Equal(t, []string{"app/local"}, queuedIngresses(c))
+
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "app/svc-abcde", Obj: moved})
+	assert.Equal(t, []string{"app/local"}, queuedIngresses(c))
+
+	// The slices of other services or without service are skipped
+	handler.OnAdd(newTestEndpointSlice("app", "other"), false)
+	handler.OnAdd(newTestEndpointSlice("app", ""), false)
+	assert.Empty(t, queuedIngresses(c))
+}
+
+func TestEnqueueEndpointSliceIngressesCopiesIngress(t *testing.T) {
+	local := newTestIngress("app", "local")
+	local.Annotations[IngressAnnotationLocalEndpoints] = "true"
+	local.Spec.DefaultBackend = &nwv1.IngressBackend{Service: &nwv1.IngressServiceBackend{Name: "svc"}}
+	c := newTestController(local)
+
+	c.enqueueEndpointSliceIngresses(newTestEndpointSlice("app", "svc"))
+	obj, _ := c.queue.Get()
+	defer c.queue.Done(obj)
+
+	// The worker may modify the queued Ingress, the cached one must be left untouched
+	queued := obj.(Event).Obj.(*nwv1.Ingress)
+	cached, err := c.ingressLister.Ingresses("app").Get("local")
+	assert.NoError(t, err)
+	assert.Equal(t, cached, queued)
+	assert.NotSame(t, cached, queued)
+}
@@ -18,6 +18,7 @@ package openstack
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
@@ -27,13 +28,18 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/ingress/config"
 )
 
+// defaultRequestTimeout is the timeout of an OpenStack API request when octavia.request-timeout is not configured.
+const defaultRequestTimeout = 60 * time.Second
+
 // OpenStack is an implementation of cloud provider Interface for OpenStack.
 type OpenStack struct {
 	Octavia  *gophercloud.ServiceClient
 	nova     *gophercloud.ServiceClient
 	neutron  *gophercloud.ServiceClient
 	Barbican *gophercloud.ServiceClient
-	config   config.Config
+	// designate is only set when the addresses of the Ingresses are published in a DNS zone
+	designate *gophercloud.ServiceClient
+	config    config.Config
 }
 
 // NewOpenStack gets openstack struct
@@ -43,6 +49,14 @@ func NewOpenStack(cfg config.Config) (*OpenStack, error) {
 		return nil, err
 	}
 
+	// Bound every request, including the ones made by the shared openstackutil helpers. The reconciliation
+	// contexts only cancel the calls when the controller stops.
+	requestTimeout := cfg.Octavia.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	provider.HTTPClient.Timeout = requestTimeout
+
 	epOpts := gophercloud.EndpointOpts{
 		Region:       cfg.OpenStack.Region,
 		Availability: cfg.OpenStack.EndpointType,
@@ -77,12 +91,22 @@ func NewOpenStack(cfg config.Config) (*OpenStack, error) {
 		barbican = nil
 	}
 
+	// Get designate service client.
+	var dns *gophercloud.ServiceClient
+	if cfg.Octavia.DNSZoneID != "" {
+		dns, err = openstack.NewDNSV2(provider, epOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find designate endpoint for region %s: %v", cfg.OpenStack.Region, err)
+		}
+	}
+
 	os := OpenStack{
-		Octavia:  lb,
-		nova:     compute,
-		neutron:  network,
-		Barbican: barbican,
-		config:   cfg,
+		Octavia:   lb,
+		nova:      compute,
+		neutron:   network,
+		Barbican:  barbican,
+		designate: dns,
+		config:    cfg,
 	}
 
 	log.Debug("openstack client initialized")
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/dns/v2/recordsets"
+	log "github.com/sirupsen/logrus"
+)
+
+// DNSEnabled returns whether the addresses of the Ingresses can be published in a Designate zone.
+func (os *OpenStack) DNSEnabled() bool {
+	return os.designate != nil
+}
+
+// getRecordSets gets the record sets of the DNS zone with the description.
+func (os *OpenStack) getRecordSets(ctx context.Context, description string) ([]recordsets.RecordSet, error) {
+	allPages, err := recordsets.ListByZone(os.designate, os.config.Octavia.DNSZoneID, recordsets.ListOpts{Description: description}).AllPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return recordsets.ExtractRecordSets(allPages)
+}
+
+// EnsureDNSRecords publishes the addresses in the A and AAAA record sets named name of the DNS zone. The record sets
+// are identified by their description, the ones with another name, e.g. after the name changed, are deleted. An
+// empty name deletes all the record sets with the description.
+func (os *OpenStack) EnsureDNSRecords(ctx context.Context, name string, addresses []string, description string) error {
+	logger := log.WithFields(log.Fields{"zoneID": os.config.Octavia.DNSZoneID, "name": name})
+
+	existing, err := os.getRecordSets(ctx, description)
+	if err != nil {
+		return fmt.Errorf("failed to get the DNS record sets: %v", err)
+	}
+
+	records := map[string][]string{}
+	if name != "" {
+		for _, addr := range addresses {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				continue
+			}
+			rrType := "A"
+			if ip.To4() == nil {
+				rrType = "AAAA"
+			}
+			records[rrType] = append(records[rrType], addr)
+		}
+	}
+
+	for _, rs := range existing {
+		wanted, ok := records[rs.Type]
+		if rs.Name != name || !ok {
+			if err := recordsets.Delete(ctx, os.designate, rs.ZoneID, rs.ID).ExtractErr(); err != nil {
+				return fmt.Errorf("failed to delete DNS record set %s: %v", rs.ID, err)
+			}
+			logger.WithFields(log.Fields{"recordSet": rs.Name, "type": rs.Type}).Info("DNS record set deleted")
+			continue
+		}
+
+		delete(records, rs.Type)
+		current := slices.Clone(rs.Records)
+		slices.Sort(current)
+		slices.Sort(wanted)
+		if slices.Equal(current, wanted) {
+			continue
+		}
+		if _, err := recordsets.Update(ctx, os.designate, rs.ZoneID, rs.ID, recordsets.UpdateOpts{Records: wanted}).Extract(); err != nil {
+			return fmt.Errorf("failed to update DNS record set %s: %v", rs.ID, err)
+		}
+		logger.WithFields(log.Fields{"type": rs.Type, "records": wanted}).Info("DNS record set updated")
+	}
+
+	for rrType, wanted := range records {
+		createOpts := recordsets.CreateOpts{
+			Name:        name,
+			Description: description,
+			Records:     wanted,
+			Type:        rrType,
+		}
+		if _, err := recordsets.Create(ctx, os.designate, os.config.Octavia.DNSZoneID, createOpts).Extract(); err != nil {
+			return fmt.Errorf("failed to create DNS record set %s of type %s: %v", name, rrType, err)
+		}
+		logger.WithFields(log.Fields{"type": rrType, "records": wanted}).Info("DNS record set created")
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/cloud-provider-openstack/pkg/ingress/config"
+)
+
+func TestEnsureDNSRecords(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var created []string
+	var updated, deleted bool
+	th.Mux.HandleFunc("/zones/zone-id/recordsets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, "ingress", r.URL.Query().Get("description"))
+			fmt.Fprint(w, `{"recordsets": [
+				{"id": "a-id", "zone_id": "zone-id", "name": "www.example.com.", "type": "A", "records": ["10.0.0.1"], "description": "ingress"},
+				{"id": "old-id", "zone_id": "zone-id", "name": "old.example.com.", "type": "A", "records": ["10.0.0.1"], "description": "ingress"}
+			]}`)
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			created = append(created, string(body))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": "aaaa-id", "zone_id": "zone-id", "name": "www.example.com.", "type": "AAAA"}`)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+	th.Mux.HandleFunc("/zones/zone-id/recordsets/a-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPut)
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"records":["10.0.0.1","10.0.0.2"]`)
+		updated = true
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "a-id", "zone_id": "zone-id", "name": "www.example.com.", "type": "A"}`)
+	})
+	th.Mux.HandleFunc("/zones/zone-id/recordsets/old-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodDelete)
+		deleted = true
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	os := &OpenStack{designate: fakeclient.ServiceClient(), config: config.Config{}}
+	os.config.Octavia.DNSZoneID = "zone-id"
+	err := os.EnsureDNSRecords(context.TODO(), "www.example.com.", []string{"10.0.0.2", "10.0.0.1", "2001:db8::1"}, "ingress")
+	assert.NoError(t, err)
+	// The record set of the previous name is deleted, the A record set gets the new address
+	assert.True(t, deleted)
+	assert.True(t, updated)
+	if assert.Len(t, created, 1) {
+		assert.Contains(t, created[0], `"type":"AAAA"`)
+		assert.Contains(t, created[0], `"records":["2001:db8::1"]`)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseFloatingIP(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var released bool
+	th.Mux.HandleFunc("/floatingips", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		assert.Equal(t, "old-vip-port", r.URL.Query().Get("port_id"))
+		assert.Equal(t, "172.24.4.10", r.URL.Query().Get("floating_ip_address"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"floatingips": [{"id": "fip-id", "floating_ip_address": "172.24.4.10", "port_id": "old-vip-port"}]}`)
+	})
+	th.Mux.HandleFunc("/floatingips/fip-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPut)
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"port_id":null`)
+		released = true
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"floatingip": {"id": "fip-id", "floating_ip_address": "172.24.4.10"}}`)
+	})
+
+	os := &OpenStack{neutron: fakeclient.ServiceClient()}
+	assert.NoError(t, os.ReleaseFloatingIP(context.TODO(), "old-vip-port", "172.24.4.10", ""))
+	assert.True(t, released)
+}
@@ -21,6 +21,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,6 +35,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
 
 	"k8s.io/cloud-provider-openstack/pkg/ingress/utils"
 	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
@@ -44,8 +47,14 @@ const (
 	loadbalancerActiveFactor    = 1
 	loadbalancerActiveSteps     = 240
 
-	activeStatus = "ACTIVE"
-	errorStatus  = "ERROR"
+	activeStatus    = "ACTIVE"
+	errorStatus     = "ERROR"
+	onlineStatus    = "ONLINE"
+	noMonitorStatus = "NO_MONITOR"
+
+	// poolDrainingPrefix starts the description of the pools no longer in use that drain their connections, it's
+	// followed by the start time of the drain.
+	poolDrainingPrefix = "Draining since "
 )
 
 func getNodeAddressForLB(node *apiv1.Node) (string, error) {
@@ -99,6 +108,11 @@ type ResourceTracker struct {
 	oldPools       []pools.Pool
 	// A map from rule hash key to policy.
 	oldPolicyMapping map[string]ExistingPolicy
+
+	// The pools no longer in use are deleted once they have drained their connections for drainTimeout.
+	drainTimeout time.Duration
+	// The end of the drain of the last draining pool, zero if no pool is draining.
+	drainingUntil time.Time
 }
 
 func NewResourceTracker(ingressName string, client *gophercloud.ServiceClient, lbID string, listenerID string, newPools []IngPool, newPolicies []IngPolicy, oldPools []pools.Pool, oldPolicies []ExistingPolicy) *ResourceTracker {
@@ -152,6 +166,18 @@ func NewResourceTracker(ingressName string, client *gophercloud.ServiceClient, l
 	return rt
 }
 
+// SetDrainTimeout keeps the pools no longer in use for the timeout before deleting them, so that the connections they
+// still serve complete. The pools are deleted right away by default.
+func (rt *ResourceTracker) SetDrainTimeout(timeout time.Duration) {
+	rt.drainTimeout = timeout
+}
+
+// DrainingUntil returns the time when the last pool draining its connections can be deleted, zero if no pool is
+// draining.
+func (rt *ResourceTracker) DrainingUntil() time.Time {
+	return rt.drainingUntil
+}
+
 // createResources only creates resources when necessary.
 func (rt *ResourceTracker) CreateResources() error {
 	poolMapping := make(map[string]string)
@@ -171,6 +197,8 @@ func (rt *ResourceTracker) CreateResources() error {
 
 			poolID = newPool.ID
 			rt.logger.WithFields(log.Fields{"poolName": pool.Name, "poolID": poolID}).Info("pool created")
+		} else if err := rt.stopPoolDrain(poolID); err != nil {
+			return err
 		}
 
 		poolMapping[pool.Name] = poolID
@@ -188,6 +216,10 @@ func (rt *ResourceTracker) CreateResources() error {
 	}
 	rt.logger.Debugf("Current pools: %v", curPoolIDs)
 
+	// The policies are reconciled without removing the routes in use: the policies whose rules are unchanged are
+	// updated in place, the new ones are in place before the stale ones are deleted by CleanupResources, which deletes
+	// the pools once no policy redirects to them.
+	order := rt.oldPolicyOrder()
 	for _, policy := range rt.newPolicies {
 		newRuleIden := sets.NewString()
 		for _, opt := range policy.RulesOpts {
@@ -198,14 +230,17 @@ func (rt *ResourceTracker) CreateResources() error {
 		poolID := poolMapping[policy.RedirectPoolName]
 
 		oldPolicy, isPresent := rt.oldPolicyMapping[rulesKey]
-		if !isPresent || oldPolicy.Policy.RedirectPoolID != poolID {
-			// Create new policy with rules
+		if !isPresent {
+			// The policy is disabled until all its rules are created, so that it never routes the requests matching a
+			// part of its rules.
 			rt.logger.WithFields(log.Fields{"listenerID": rt.listenerID, "poolID": poolID}).Info("creating l7 policy")
 			policy.Opts.RedirectPoolID = poolID
+			policy.Opts.AdminStateUp = ptr.To(false)
 			newPolicy, err := openstackutil.CreateL7Policy(rt.client, policy.Opts, rt.lbID)
 			if err != nil {
 				return fmt.Errorf("failed to create l7policy, error: %v", err)
 			}
+			order = movePolicy(order, newPolicy.ID, policy.Opts.Position)
 			rt.logger.WithFields(log.Fields{"listenerID": rt.listenerID, "poolID": poolID}).Info("l7 policy created")
 
 			rt.logger.WithFields(log.Fields{"listenerID": rt.listenerID, "policyID": newPolicy.ID}).Info("creating l7 rules")
@@ -214,7 +249,25 @@ func (rt *ResourceTracker) CreateResources() error {
 					return fmt.Errorf("failed to create l7 rules for policy %s, error: %v", newPolicy.ID, err)
 				}
 			}
+			if err := openstackutil.UpdateL7Policy(rt.client, newPolicy.ID, l7policies.UpdateOpts{AdminStateUp: ptr.To(true)}, rt.lbID); err != nil {
+				return fmt.Errorf("failed to enable l7policy %s, error: %v", newPolicy.ID, err)
+			}
 			rt.logger.WithFields(log.Fields{"listenerID": rt.listenerID, "policyID": newPolicy.ID}).Info("l7 rules created")
+		} else {
+			updateOpts, changed := policyUpdateOpts(oldPolicy.Policy, poolID, policy.Opts.Position, order)
+			if changed {
+				rt.logger.WithFields(log.Fields{"policyID": oldPolicy.Policy.ID, "poolID": poolID, "position": policy.Opts.Position}).Info("updating l7 policy")
+				if err := openstackutil.UpdateL7Policy(rt.client, oldPolicy.Policy.ID, updateOpts, rt.lbID); err != nil {
+					return fmt.Errorf("failed to update l7policy %s, error: %v", oldPolicy.Policy.ID, err)
+				}
+				rt.logger.WithFields(log.Fields{"policyID": oldPolicy.Policy.ID}).Info("l7 policy updated")
+
+				oldPolicy.Policy.RedirectPoolID = poolID
+				rt.oldPolicyMapping[rulesKey] = oldPolicy
+				if updateOpts.Position > 0 {
+					order = movePolicy(order, oldPolicy.Policy.ID, updateOpts.Position)
+				}
+			}
 		}
 
 		rt.newPolicyRuleMapping[rulesKey] = poolID
@@ -225,6 +278,61 @@ func (rt *ResourceTracker) CreateResources() error {
 	return nil
 }
 
+// policyUpdateOpts returns the update of an existing policy whose rules are unchanged, and whether it's needed: the pool
+// it redirects to, its position when set and its admin state.
+func policyUpdateOpts(oldPolicy l7policies.L7Policy, poolID string, position int32, order []string) (l7policies.UpdateOpts, bool) {
+	updateOpts := l7policies.UpdateOpts{}
+	changed := false
+	if oldPolicy.RedirectPoolID != poolID {
+		updateOpts.RedirectPoolID = &poolID
+		changed = true
+	}
+	if position > 0 && policyPosition(order, oldPolicy.ID) != position {
+		updateOpts.Position = position
+		changed = true
+	}
+	if !oldPolicy.AdminStateUp {
+		// Left disabled by a failure after the creation of the policy
+		updateOpts.AdminStateUp = ptr.To(true)
+		changed = true
+	}
+	return updateOpts, changed
+}
+
+// oldPolicyOrder returns the IDs of the existing policies in the order Octavia evaluates them.
+func (rt *ResourceTracker) oldPolicyOrder() []string {
+	policies := make([]l7policies.L7Policy, 0, len(rt.oldPolicyMapping))
+	for _, policy := range rt.oldPolicyMapping {
+		policies = append(policies, policy.Policy)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Position < policies[j].Position
+	})
+
+	order := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		order = append(order, policy.ID)
+	}
+	return order
+}
+
+// policyPosition returns the current position of the policy, Octavia positions start at 1.
+func policyPosition(order []string, policyID string) int32 {
+	return int32(slices.Index(order, policyID) + 1)
+}
+
+// movePolicy places the policy at the position the way Octavia does, the following policies are shifted. The policy is
+// appended when the position is not set or beyond the last one.
+func movePolicy(order []string, policyID string, position int32) []string {
+	if i := slices.Index(order, policyID); i >= 0 {
+		order = slices.Delete(order, i, i+1)
+	}
+	if position <= 0 || int(position) > len(order) {
+		return append(order, policyID)
+	}
+	return slices.Insert(order, int(position)-1, policyID)
+}
+
 func (rt *ResourceTracker) CleanupResources() error {
 	for key, oldPolicy := range rt.oldPolicyMapping {
 		poolID, isPresent := rt.newPolicyRuleMapping[key]
@@ -240,6 +348,14 @@ func (rt *ResourceTracker) CleanupResources() error {
 
 	for _, pool := range rt.oldPools {
 		if !rt.newPoolNames.Has(pool.Name) {
+			draining, err := rt.drainPool(pool, time.Now())
+			if err != nil {
+				return err
+			}
+			if draining {
+				continue
+			}
+
 			// Delete unused pool
 			rt.logger.WithFields(log.Fields{"poolID": pool.ID}).Info("deleting pool")
 			if err := openstackutil.DeletePool(rt.client, pool.ID, rt.lbID); err != nil {
@@ -252,7 +368,64 @@ func (rt *ResourceTracker) CleanupResources() error {
 	return nil
 }
 
-func (os *OpenStack) waitLoadbalancerActiveProvisioningStatus(loadbalancerID string) (string, error) {
+// drainPool returns true while the pool no longer in use drains its connections, no policy redirects the new requests
+// to it. The start of the drain is recorded in the pool description, so that it survives the restarts.
+func (rt *ResourceTracker) drainPool(pool pools.Pool, now time.Time) (bool, error) {
+	if rt.drainTimeout <= 0 {
+		return false, nil
+	}
+
+	since, draining := poolDrainingSince(pool)
+	if !draining {
+		rt.logger.WithFields(log.Fields{"poolID": pool.ID}).Info("draining pool")
+		description := poolDrainingPrefix + now.UTC().Format(time.RFC3339)
+		if err := openstackutil.UpdatePool(rt.client, rt.lbID, pool.ID, pools.UpdateOpts{Description: &description}); err != nil {
+			return false, fmt.Errorf("failed to drain pool %s, error: %v", pool.ID, err)
+		}
+		since = now
+	}
+
+	until := since.Add(rt.drainTimeout)
+	if !now.Before(until) {
+		return false, nil
+	}
+	if until.After(rt.drainingUntil) {
+		rt.drainingUntil = until
+	}
+	return true, nil
+}
+
+// stopPoolDrain clears the drain of the pool in use again, e.g. when a path removed from the Ingress is restored.
+func (rt *ResourceTracker) stopPoolDrain(poolID string) error {
+	i := slices.IndexFunc(rt.oldPools, func(pool pools.Pool) bool { return pool.ID == poolID })
+	if i < 0 {
+		return nil
+	}
+	if _, draining := poolDrainingSince(rt.oldPools[i]); !draining {
+		return nil
+	}
+
+	rt.logger.WithFields(log.Fields{"poolID": poolID}).Info("pool in use again, stopping its drain")
+	if err := openstackutil.UpdatePool(rt.client, rt.lbID, poolID, pools.UpdateOpts{Description: ptr.To("")}); err != nil {
+		return fmt.Errorf("failed to stop the drain of pool %s, error: %v", poolID, err)
+	}
+	return nil
+}
+
+// poolDrainingSince returns the start of the drain of the pool, and false if it's not draining.
+func poolDrainingSince(pool pools.Pool) (time.Time, bool) {
+	value, ok := strings.CutPrefix(pool.Description, poolDrainingPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+func (os *OpenStack) waitLoadbalancerActiveProvisioningStatus(ctx context.Context, loadbalancerID string) (string, error) {
 	backoff := wait.Backoff{
 		Duration: loadbalancerActiveInitDealy,
 		Factor:   loadbalancerActiveFactor,
@@ -260,8 +433,8 @@ func (os *OpenStack) waitLoadbalancerActiveProvisioningStatus(loadbalancerID str
 	}
 
 	var provisioningStatus string
-	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
-		loadbalancer, err := loadbalancers.Get(context.TODO(), os.Octavia, loadbalancerID).Extract()
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		loadbalancer, err := loadbalancers.Get(ctx, os.Octavia, loadbalancerID).Extract()
 		if err != nil {
 			return false, err
 		}
@@ -283,7 +456,8 @@ func (os *OpenStack) waitLoadbalancerActiveProvisioningStatus(loadbalancerID str
 }
 
 // EnsureLoadBalancer creates a loadbalancer in octavia if it does not exist, wait for the loadbalancer to be ACTIVE.
-func (os *OpenStack) EnsureLoadBalancer(name string, subnetID string, ingNamespace string, ingName string, clusterName string, flavorId string) (*loadbalancers.LoadBalancer, error) {
+// The loadbalancer is created in the availability zone if not empty.
+func (os *OpenStack) EnsureLoadBalancer(ctx context.Context, name string, subnetID string, ingNamespace string, ingName string, clusterName string, flavorId string, availabilityZone string, tags []string) (*loadbalancers.LoadBalancer, error) {
 	logger := log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ingNamespace, ingName)})
 
 	loadbalancer, err := openstackutil.GetLoadbalancerByName(os.Octavia, name)
@@ -298,8 +472,11 @@ func (os *OpenStack) EnsureLoadBalancer(name string, subnetID string, ingNamespa
 			VipSubnetID: subnetID,
 			Provider:    os.config.Octavia.Provider,
 			FlavorID:    flavorId,
+
+			AvailabilityZone: availabilityZone,
+			Tags:             tags,
 		}
-		loadbalancer, err = loadbalancers.Create(context.TODO(), os.Octavia, createOpts).Extract()
+		loadbalancer, err = loadbalancers.Create(ctx, os.Octavia, createOpts).Extract()
 		if err != nil {
 			return nil, fmt.Errorf("error creating loadbalancer %v: %v", createOpts, err)
 		}
@@ -309,17 +486,62 @@ func (os *OpenStack) EnsureLoadBalancer(name string, subnetID string, ingNamespa
 		logger.WithFields(log.Fields{"lbName": name, "lbID": loadbalancer.ID}).Debug("loadbalancer exists")
 	}
 
-	_, err = os.waitLoadbalancerActiveProvisioningStatus(loadbalancer.ID)
+	_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, loadbalancer.ID)
 	if err != nil {
 		return nil, fmt.Errorf("loadbalancer %s not in ACTIVE status, error: %v", loadbalancer.ID, err)
 	}
 
+	// The load balancers created by previous versions are tagged once ensured
+	if slices.ContainsFunc(tags, func(tag string) bool { return !slices.Contains(loadbalancer.Tags, tag) }) {
+		newTags := append(slices.Clone(loadbalancer.Tags), tags...)
+		slices.Sort(newTags)
+		newTags = slices.Compact(newTags)
+		if err := openstackutil.UpdateLoadBalancerTags(os.Octavia, loadbalancer.ID, newTags); err != nil {
+			return nil, fmt.Errorf("failed to tag loadbalancer %s: %v", loadbalancer.ID, err)
+		}
+		loadbalancer.Tags = newTags
+		if _, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, loadbalancer.ID); err != nil {
+			return nil, fmt.Errorf("loadbalancer %s not in ACTIVE status, error: %v", loadbalancer.ID, err)
+		}
+	}
+
 	return loadbalancer, nil
 }
 
+// GetLoadBalancersByTag returns the load balancers with the tag.
+func (os *OpenStack) GetLoadBalancersByTag(tag string) ([]loadbalancers.LoadBalancer, error) {
+	return openstackutil.GetLoadBalancers(os.Octavia, loadbalancers.ListOpts{Tags: []string{tag}})
+}
+
+// GetMembersStatus returns the number of members in the load balancer pools and how many of them are operational.
+func (os *OpenStack) GetMembersStatus(ctx context.Context, lbID string) (int, int, error) {
+	lbPools, err := openstackutil.GetPools(os.Octavia, lbID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get pools from load balancer %s: %v", lbID, err)
+	}
+
+	var total, ready int
+	for _, pool := range lbPools {
+		members, err := openstackutil.GetMembersbyPool(os.Octavia, pool.ID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get members of pool %s: %v", pool.ID, err)
+		}
+
+		for _, member := range members {
+			total++
+			// The members report NO_MONITOR when the pool has no health monitor, they're considered operational.
+			if member.OperatingStatus == onlineStatus || member.OperatingStatus == noMonitorStatus {
+				ready++
+			}
+		}
+	}
+
+	return total, ready, nil
+}
+
 // UpdateLoadBalancerDescription updates the load balancer description field.
-func (os *OpenStack) UpdateLoadBalancerDescription(lbID string, newDescription string) error {
-	_, err := loadbalancers.Update(context.TODO(), os.Octavia, lbID, loadbalancers.UpdateOpts{
+func (os *OpenStack) UpdateLoadBalancerDescription(ctx context.Context, lbID string, newDescription string) error {
+	_, err := loadbalancers.Update(ctx, os.Octavia, lbID, loadbalancers.UpdateOpts{
 		Description: &newDescription,
 	}).Extract()
 	if err != nil {
@@ -331,8 +553,16 @@ func (os *OpenStack) UpdateLoadBalancerDescription(lbID string, newDescription s
 }
 
 // EnsureListener creates a loadbalancer listener in octavia if it does not exist, wait for the loadbalancer to be ACTIVE.
-func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []string, listenerAllowedCIDRs []string, timeoutClientData, timeoutMemberData, timeoutTCPInspect, timeoutMemberConnect *int) (*listeners.Listener, error) {
+func (os *OpenStack) EnsureListener(ctx context.Context, name string, lbID string, port int, secretRefs []string, listenerAllowedCIDRs []string, timeoutClientData, timeoutMemberData, timeoutTCPInspect, timeoutMemberConnect *int) (*listeners.Listener, error) {
 	listener, err := openstackutil.GetListenerByName(os.Octavia, name, lbID)
+	if err == nil && listener.ProtocolPort != port {
+		// The port of a listener can't be updated, the listener is recreated together with its l7 policies.
+		log.WithFields(log.Fields{"listenerID": listener.ID, "oldPort": listener.ProtocolPort, "port": port}).Info("recreating listener on the new port")
+		if err = openstackutil.DeleteListener(os.Octavia, listener.ID, lbID); err != nil {
+			return nil, fmt.Errorf("error deleting listener %s: %v", listener.ID, err)
+		}
+		err = cpoerrors.ErrNotFound
+	}
 	if err != nil {
 		if err != cpoerrors.ErrNotFound {
 			return nil, fmt.Errorf("error getting listener %s: %v", name, err)
@@ -343,7 +573,7 @@ func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []strin
 		opts := listeners.CreateOpts{
 			Name:                 name,
 			Protocol:             "HTTP",
-			ProtocolPort:         80, // Ingress Controller only supports http/https for now
+			ProtocolPort:         port,
 			LoadbalancerID:       lbID,
 			TimeoutClientData:    timeoutClientData,
 			TimeoutMemberData:    timeoutMemberData,
@@ -353,13 +583,12 @@ func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []strin
 		if len(secretRefs) > 0 {
 			opts.DefaultTlsContainerRef = secretRefs[0]
 			opts.SniContainerRefs = secretRefs
-			opts.ProtocolPort = 443
 			opts.Protocol = "TERMINATED_HTTPS"
 		}
 		if len(listenerAllowedCIDRs) > 0 {
 			opts.AllowedCIDRs = listenerAllowedCIDRs
 		}
-		listener, err = listeners.Create(context.TODO(), os.Octavia, opts).Extract()
+		listener, err = listeners.Create(ctx, os.Octavia, opts).Extract()
 		if err != nil {
 			return nil, fmt.Errorf("error creating listener: %v", err)
 		}
@@ -381,8 +610,15 @@ func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []strin
 			updateOpts.TimeoutTCPInspect = timeoutTCPInspect
 		}
 
+		// The certificates of a TERMINATED_HTTPS listener are replaced when rotated, the protocol can't be changed.
+		if len(secretRefs) > 0 && listener.Protocol == "TERMINATED_HTTPS" &&
+			(listener.DefaultTlsContainerRef != secretRefs[0] || !reflect.DeepEqual(listener.SniContainerRefs, secretRefs)) {
+			updateOpts.DefaultTlsContainerRef = &secretRefs[0]
+			updateOpts.SniContainerRefs = &secretRefs
+		}
+
 		if updateOpts != (listeners.UpdateOpts{}) {
-			_, err := listeners.Update(context.TODO(), os.Octavia, listener.ID, updateOpts).Extract()
+			_, err := listeners.Update(ctx, os.Octavia, listener.ID, updateOpts).Extract()
 			if err != nil {
 				return nil, fmt.Errorf("failed to update listener options: %v", err)
 			}
@@ -391,7 +627,7 @@ func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []strin
 		}
 	}
 
-	_, err = os.waitLoadbalancerActiveProvisioningStatus(lbID)
+	_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, lbID)
 	if err != nil {
 		return nil, fmt.Errorf("loadbalancer %s not in ACTIVE status after creating listener, error: %v", lbID, err)
 	}
@@ -400,7 +636,7 @@ func (os *OpenStack) EnsureListener(name string, lbID string, secretRefs []strin
 }
 
 // EnsurePoolMembers ensure the pool and its members exist if deleted flag is not set, delete the pool and all its members otherwise.
-func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID string, listenerID string, nodePort *int, nodes []*apiv1.Node) (*string, error) {
+func (os *OpenStack) EnsurePoolMembers(ctx context.Context, deleted bool, poolName string, lbID string, listenerID string, nodePort *int, nodes []*apiv1.Node) (*string, error) {
 	logger := log.WithFields(log.Fields{"lbID": lbID, "listenerID": listenerID, "poolName": poolName})
 
 	if deleted {
@@ -413,12 +649,12 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 		}
 
 		// Delete the existing pool, members are deleted automatically
-		err = pools.Delete(context.TODO(), os.Octavia, pool.ID).ExtractErr()
+		err = pools.Delete(ctx, os.Octavia, pool.ID).ExtractErr()
 		if err != nil && !cpoerrors.IsNotFound(err) {
 			return nil, fmt.Errorf("error deleting pool %s: %v", pool.ID, err)
 		}
 
-		_, err = os.waitLoadbalancerActiveProvisioningStatus(lbID)
+		_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, lbID)
 		if err != nil {
 			return nil, fmt.Errorf("error waiting for loadbalancer %s to be active: %v", lbID, err)
 		}
@@ -453,7 +689,7 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 				Persistence:    nil,
 			}
 		}
-		pool, err = pools.Create(context.TODO(), os.Octavia, opts).Extract()
+		pool, err = pools.Create(ctx, os.Octavia, opts).Extract()
 		if err != nil {
 			return nil, fmt.Errorf("error creating pool: %v", err)
 		}
@@ -462,7 +698,7 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 
 	}
 
-	_, err = os.waitLoadbalancerActiveProvisioningStatus(lbID)
+	_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, lbID)
 	if err != nil {
 		return nil, fmt.Errorf("error waiting for loadbalancer %s to be active: %v", lbID, err)
 	}
@@ -474,7 +710,7 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 		if err != nil {
 			return nil, fmt.Errorf("error reconciling pool members for pool %s: %v", pool.ID, err)
 		}
-		_, err = os.waitLoadbalancerActiveProvisioningStatus(lbID)
+		_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, lbID)
 		if err != nil {
 			return nil, fmt.Errorf("error waiting for loadbalancer %s to be active: %v", lbID, err)
 		}
@@ -506,10 +742,10 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 		return nil, fmt.Errorf("error because no members in pool: %s", pool.ID)
 	}
 
-	if err := pools.BatchUpdateMembers(context.TODO(), os.Octavia, pool.ID, members).ExtractErr(); err != nil {
+	if err := pools.BatchUpdateMembers(ctx, os.Octavia, pool.ID, members).ExtractErr(); err != nil {
 		return nil, fmt.Errorf("error batch updating members for pool %s: %v", pool.ID, err)
 	}
-	_, err = os.waitLoadbalancerActiveProvisioningStatus(lbID)
+	_, err = os.waitLoadbalancerActiveProvisioningStatus(ctx, lbID)
 	if err != nil {
 		return nil, fmt.Errorf("error waiting for loadbalancer %s to be active: %v", lbID, err)
 	}
@@ -520,7 +756,7 @@ func (os *OpenStack) EnsurePoolMembers(deleted bool, poolName string, lbID strin
 }
 
 // UpdateLoadbalancerMembers update members for all the pools in the specified load balancer.
-func (os *OpenStack) UpdateLoadbalancerMembers(lbID string, nodes []*apiv1.Node) error {
+func (os *OpenStack) UpdateLoadbalancerMembers(ctx context.Context, lbID string, nodes []*apiv1.Node) error {
 	lbPools, err := openstackutil.GetPools(os.Octavia, lbID)
 	if err != nil {
 		return err
@@ -538,7 +774,7 @@ func (os *OpenStack) UpdateLoadbalancerMembers(lbID string, nodes []*apiv1.Node)
 		// Members have the same ProtocolPort
 		nodePort := members[0].ProtocolPort
 
-		if _, err = os.EnsurePoolMembers(false, pool.Name, lbID, "", &nodePort, nodes); err != nil {
+		if _, err = os.EnsurePoolMembers(ctx, false, pool.Name, lbID, "", &nodePort, nodes); err != nil {
 			return err
 		}
 
@@ -0,0 +1,299 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/l7policies"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+)
+
+func TestMovePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    []string
+		policyID string
+		position int32
+		expected []string
+	}{
+		{name: "append a new policy without position", order: []string{"a", "b"}, policyID: "c", expected: []string{"a", "b", "c"}},
+		{name: "insert a new policy", order: []string{"a", "b"}, policyID: "c", position: 1, expected: []string{"c", "a", "b"}},
+		{name: "insert a new policy in the middle", order: []string{"a", "b"}, policyID: "c", position: 2, expected: []string{"a", "c", "b"}},
+		{name: "append a new policy beyond the last position", order: []string{"a", "b"}, policyID: "c", position: 5, expected: []string{"a", "b", "c"}},
+		{name: "move a policy up", order: []string{"a", "b", "c"}, policyID: "c", position: 1, expected: []string{"c", "a", "b"}},
+		{name: "move a policy down", order: []string{"a", "b", "c"}, policyID: "a", position: 3, expected: []string{"b", "c", "a"}},
+		{name: "move a policy to the last position", order: []string{"a", "b", "c"}, policyID: "a", position: 9, expected: []string{"b", "c", "a"}},
+		{name: "keep a policy in place", order: []string{"a", "b", "c"}, policyID: "b", position: 2, expected: []string{"a", "b", "c"}},
+		{name: "first policy", policyID: "a", position: 1, expected: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, movePolicy(tt.order, tt.policyID, tt.position))
+		})
+	}
+}
+
+func TestPolicyPosition(t *testing.T) {
+	order := []string{"a", "b", "c"}
+	assert.Equal(t, int32(1), policyPosition(order, "a"))
+	assert.Equal(t, int32(3), policyPosition(order, "c"))
+	// A policy not in the listener has no position
+	assert.Equal(t, int32(0), policyPosition(order, "d"))
+}
+
+func TestOldPolicyOrder(t *testing.T) {
+	rt := &ResourceTracker{oldPolicyMapping: map[string]ExistingPolicy{
+		"rules-c": {Policy: l7policies.L7Policy{ID: "c", Position: 3}},
+		"rules-a": {Policy: l7policies.L7Policy{ID: "a", Position: 1}},
+		"rules-b": {Policy: l7policies.L7Policy{ID: "b", Position: 2}},
+	}}
+	assert.Equal(t, []string{"a", "b", "c"}, rt.oldPolicyOrder())
+
+	assert.Empty(t, (&ResourceTracker{}).oldPolicyOrder())
+}
+
+func TestPolicyUpdateOpts(t *testing.T) {
+	order := []string{"a", "b"}
+	tests := []struct {
+		name      string
+		oldPolicy l7policies.L7Policy
+		poolID    string
+		position  int32
+		expected  l7policies.UpdateOpts
+		changed   bool
+	}{
+		{
+			name:      "unchanged",
+			oldPolicy: l7policies.L7Policy{ID: "b", RedirectPoolID: "pool", AdminStateUp: true},
+			poolID:    "pool",
+			position:  2,
+		},
+		{
+			name:      "position not set",
+			oldPolicy: l7policies.L7Policy{ID: "b", RedirectPoolID: "pool", AdminStateUp: true},
+			poolID:    "pool",
+		},
+		{
+			name:      "new pool",
+			oldPolicy: l7policies.L7Policy{ID: "b", RedirectPoolID: "old-pool", AdminStateUp: true},
+			poolID:    "pool",
+			position:  2,
+			expected:  l7policies.UpdateOpts{RedirectPoolID: ptr.To("pool")},
+			changed:   true,
+		},
+		{
+			name:      "moved",
+			oldPolicy: l7policies.L7Policy{ID: "b", RedirectPoolID: "pool", AdminStateUp: true},
+			poolID:    "pool",
+			position:  1,
+			expected:  l7policies.UpdateOpts{Position: 1},
+			changed:   true,
+		},
+		{
+			name:      "left disabled",
+			oldPolicy: l7policies.L7Policy{ID: "b", RedirectPoolID: "pool"},
+			poolID:    "pool",
+			position:  2,
+			expected:  l7policies.UpdateOpts{AdminStateUp: ptr.To(true)},
+			changed:   true,
+		},
+		{
+			name:      "all changed",
+			oldPolicy: l7policies.L7Policy{ID: "a", RedirectPoolID: "old-pool"},
+			poolID:    "pool",
+			position:  2,
+			expected:  l7policies.UpdateOpts{RedirectPoolID: ptr.To("pool"), Position: 2, AdminStateUp: ptr.To(true)},
+			changed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updateOpts, changed := policyUpdateOpts(tt.oldPolicy, tt.poolID, tt.position, order)
+			assert.Equal(t, tt.changed, changed)
+			assert.Equal(t, tt.expected, updateOpts)
+		})
+	}
+}
+
+func TestPoolDrainingSince(t *testing.T) {
+	since := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	got, draining := poolDrainingSince(pools.Pool{Description: poolDrainingPrefix + since.Format(time.RFC3339)})
+	assert.True(t, draining)
+	assert.True(t, since.Equal(got))
+
+	_, draining = poolDrainingSince(pools.Pool{})
+	assert.False(t, draining)
+	_, draining = poolDrainingSince(pools.Pool{Description: poolDrainingPrefix + "yesterday"})
+	assert.False(t, draining)
+}
+
+// mockPools records the description updates and the deletions of the pools.
+func mockPools(t *testing.T, ids ...string) (map[string]string, *[]string) {
+	descriptions := map[string]string{}
+	var deleted []string
+	for _, id := range ids {
+		th.Mux.HandleFunc("/lbaas/pools/"+id, func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPut:
+				var body struct {
+					Pool struct {
+						Description string `json:"description"`
+					} `json:"pool"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode pool update request: %v", err)
+				}
+				descriptions[id] = body.Pool.Description
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"pool": {"id": %q}}`, id)
+			case http.MethodDelete:
+				deleted = append(deleted, id)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				t.Errorf("unexpected method %s", r.Method)
+			}
+		})
+	}
+	return descriptions, &deleted
+}
+
+func TestCleanupResourcesDrainsPools(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	mockLoadBalancerActive(t, "lb-id")
+	descriptions, deleted := mockPools(t, "unused", "drained", "draining", "used")
+
+	now := time.Now()
+	drainingSince := now.Add(-time.Minute).UTC().Truncate(time.Second)
+	rt := &ResourceTracker{
+		client:       fakeclient.ServiceClient(),
+		logger:       log.WithFields(log.Fields{}),
+		lbID:         "lb-id",
+		newPoolNames: sets.New("used"),
+		oldPools: []pools.Pool{
+			{ID: "unused", Name: "unused"},
+			{ID: "drained", Name: "drained", Description: poolDrainingPrefix + now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+			{ID: "draining", Name: "draining", Description: poolDrainingPrefix + drainingSince.Format(time.RFC3339)},
+			{ID: "used", Name: "used"},
+		},
+	}
+	rt.SetDrainTimeout(10 * time.Minute)
+
+	assert.NoError(t, rt.CleanupResources())
+
+	// The unused pool starts draining, the drained one is deleted
+	assert.Equal(t, []string{"drained"}, *deleted)
+	assert.True(t, strings.HasPrefix(descriptions["unused"], poolDrainingPrefix))
+	assert.Len(t, descriptions, 1)
+	assert.False(t, rt.DrainingUntil().Before(now.Add(10*time.Minute).Truncate(time.Second)))
+
+	// Without drain timeout, the unused pools are deleted right away
+	*deleted = nil
+	rt = &ResourceTracker{client: rt.client, logger: rt.logger, lbID: "lb-id", newPoolNames: rt.newPoolNames, oldPools: rt.oldPools}
+	assert.NoError(t, rt.CleanupResources())
+	assert.Equal(t, []string{"unused", "drained", "draining"}, *deleted)
+	assert.True(t, rt.DrainingUntil().IsZero())
+}
+
+func TestStopPoolDrain(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	mockLoadBalancerActive(t, "lb-id")
+	descriptions, _ := mockPools(t, "draining", "used")
+
+	rt := &ResourceTracker{
+		client: fakeclient.ServiceClient(),
+		logger: log.WithFields(log.Fields{}),
+		lbID:   "lb-id",
+		oldPools: []pools.Pool{
+			{ID: "draining", Description: poolDrainingPrefix + time.Now().UTC().Format(time.RFC3339)},
+			{ID: "used"},
+		},
+	}
+
+	assert.NoError(t, rt.stopPoolDrain("draining"))
+	assert.NoError(t, rt.stopPoolDrain("used"))
+	assert.Equal(t, map[string]string{"draining": ""}, descriptions)
+}
+
+// mockLoadBalancerActive serves the load balancer as ACTIVE to the GET requests.
+func mockLoadBalancerActive(t *testing.T, id string) {
+	th.Mux.HandleFunc("/lbaas/loadbalancers/"+id, func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"loadbalancer": {"id": %q, "provisioning_status": "ACTIVE"}}`, id)
+	})
+}
+
+func TestEnsureListenerRecreatesOnNewPort(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var deleted, created bool
+	th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, "ing-listener", r.URL.Query().Get("name"))
+			fmt.Fprint(w, `{"listeners": [{"id": "old-listener", "name": "ing-listener", "protocol": "HTTP", "protocol_port": 80}]}`)
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			assert.Contains(t, string(body), `"protocol_port":8080`)
+			created = true
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"listener": {"id": "new-listener", "name": "ing-listener", "protocol": "HTTP", "protocol_port": 8080}}`)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+	th.Mux.HandleFunc("/lbaas/listeners/old-listener", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodDelete)
+		assert.False(t, created, "the listener must be deleted before it's created again")
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mockLoadBalancerActive(t, "lb-id")
+
+	os := &OpenStack{Octavia: fakeclient.ServiceClient()}
+	listener, err := os.EnsureListener(context.TODO(), "ing-listener", "lb-id", 8080, nil, nil, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.True(t, created)
+	if assert.NotNil(t, listener) {
+		assert.Equal(t, "new-listener", listener.ID)
+		assert.Equal(t, 8080, listener.ProtocolPort)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	nwv1 "k8s.io/api/networking/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	apimetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// IngressAnnotationStatus is the key of the annotation the controller reports the state of the Ingress load
+	// balancer in, as JSON. The Ingress status doesn't support conditions.
+	IngressAnnotationStatus = "octavia.ingress.kubernetes.io/status"
+
+	// IngressConditionProvisioned reports whether the load balancer of the Ingress is reconciled.
+	IngressConditionProvisioned = "Provisioned"
+	// IngressConditionMembersReady reports whether the members of the load balancer pools are operational.
+	IngressConditionMembersReady = "MembersReady"
+	// IngressConditionFIPAssigned reports whether a floating IP is associated with the load balancer.
+	IngressConditionFIPAssigned = "FIPAssigned"
+)
+
+const (
+	// membersReadyInitialDelay and membersReadyMaxDelay bound the backoff of the reconciliations of an Ingress whose
+	// members aren't ready yet, until they are.
+	membersReadyInitialDelay = 5 * time.Second
+	membersReadyMaxDelay     = 5 * time.Minute
+)
+
+// IngressStatus is the content of the IngressAnnotationStatus annotation.
+type IngressStatus struct {
+	// ObservedGeneration is the generation of the Ingress last processed by the controller.
+	ObservedGeneration int64                 `json:"observedGeneration"`
+	Conditions         []apimetav1.Condition `json:"conditions,omitempty"`
+}
+
+// getIngressStatus returns the status reported in the Ingress annotation, an invalid annotation is ignored.
+func getIngressStatus(ing *nwv1.Ingress) *IngressStatus {
+	status := &IngressStatus{}
+	if value, ok := ing.Annotations[IngressAnnotationStatus]; ok {
+		if err := json.Unmarshal([]byte(value), status); err != nil {
+			log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)}).Warnf("ignoring invalid %s annotation: %v", IngressAnnotationStatus, err)
+			return &IngressStatus{}
+		}
+	}
+	return status
+}
+
+// setIngressStatusConditions merges the conditions into the status of the Ingress. It returns the new value of the
+// annotation and whether it changed.
+func setIngressStatusConditions(ing *nwv1.Ingress, conditions ...apimetav1.Condition) (string, bool, error) {
+	status := getIngressStatus(ing)
+	status.ObservedGeneration = ing.Generation
+	for _, condition := range conditions {
+		apimeta.SetStatusCondition(&status.Conditions, condition)
+	}
+
+	value, err := json.Marshal(status)
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(value), string(value) != ing.Annotations[IngressAnnotationStatus], nil
+}
+
+// updateIngressConditions reports the conditions in the Ingress annotation, the other conditions are kept.
+func (c *Controller) updateIngressConditions(ctx context.Context, ing *nwv1.Ingress, conditions ...apimetav1.Condition) (*nwv1.Ingress, error) {
+	value, changed, err := setIngressStatusConditions(ing, conditions...)
+	if err != nil || !changed {
+		return ing, err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{IngressAnnotationStatus: value},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newIng, err := c.kubeClient.NetworkingV1().Ingresses(ing.Namespace).Patch(ctx, ing.Name, types.MergePatchType, patch, apimetav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update the %s annotation: %v", IngressAnnotationStatus, err)
+	}
+
+	return newIng, nil
+}
+
+// newMembersReadyCondition returns the MembersReady condition of the load balancer members, err is the failure to get
+// their status.
+func newMembersReadyCondition(generation int64, total, ready int, err error) apimetav1.Condition {
+	if err != nil {
+		return newCondition(IngressConditionMembersReady, apimetav1.ConditionUnknown, "StatusUnavailable", err.Error(), generation)
+	}
+
+	message := fmt.Sprintf("%d/%d members are operational", ready, total)
+	if ready < total {
+		return newCondition(IngressConditionMembersReady, apimetav1.ConditionFalse, "MembersNotReady", message, generation)
+	}
+	return newCondition(IngressConditionMembersReady, apimetav1.ConditionTrue, "MembersOnline", message, generation)
+}
+
+// requeueMembersNotReady reconciles the Ingress again, with an increasing delay, while its members aren't ready, so
+// that the MembersReady condition is updated once they are.
+func (c *Controller) requeueMembersNotReady(ing *nwv1.Ingress, condition apimetav1.Condition) {
+	key := fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)
+	if condition.Status == apimetav1.ConditionTrue {
+		c.membersBackoff.Forget(key)
+		return
+	}
+
+	delay := c.membersBackoff.When(key)
+	log.WithFields(log.Fields{"ingress": key}).Infof("the members of the load balancer aren't ready, reconciling again in %v", delay)
+	c.queue.AddAfter(Event{Obj: ing.DeepCopy(), Type: MembersNotReadyEvent}, delay)
+}
+
+// requeuePoolsDraining reconciles the Ingress again once its unused pools have drained their connections, to delete
+// them.
+func (c *Controller) requeuePoolsDraining(ing *nwv1.Ingress, until time.Time) {
+	delay := time.Until(until)
+	log.WithFields(log.Fields{"ingress": fmt.Sprintf("%s/%s", ing.Namespace, ing.Name)}).Infof("unused pools draining, reconciling again in %v", delay)
+	c.queue.AddAfter(Event{Obj: ing.DeepCopy(), Type: PoolsDrainingEvent}, delay)
+}
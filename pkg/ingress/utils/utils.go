@@ -37,6 +37,16 @@ func GetResourceName(namespace, name, clusterName string) string {
 	return fmt.Sprintf("kube_ingress_%s_%s_%s", clusterName, namespace, name)
 }
 
+// GetResourceNamePrefix gets the prefix of the names of the Ingress related resources of the cluster.
+func GetResourceNamePrefix(clusterName string) string {
+	return fmt.Sprintf("kube_ingress_%s_", clusterName)
+}
+
+// GetGatewayResourceName get Gateway related resource name.
+func GetGatewayResourceName(namespace, name, clusterName string) string {
+	return fmt.Sprintf("kube_gateway_%s_%s_%s", clusterName, namespace, name)
+}
+
 // NodeNames get all the node names.
 func NodeNames(nodes []*apiv1.Node) []string {
 	ret := make([]string, len(nodes))
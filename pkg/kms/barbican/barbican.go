@@ -2,15 +2,29 @@ package barbican
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
 	"k8s.io/cloud-provider-openstack/pkg/client"
+	"k8s.io/cloud-provider-openstack/pkg/util"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
 )
 
 type KMSOpts struct {
 	KeyID string `gcfg:"key-id"`
+	// KeyAlias is the name of the Barbican secrets holding the rotated keys, the newest one is the active key
+	KeyAlias       string          `gcfg:"key-alias"`
+	RotationPeriod util.MyDuration `gcfg:"rotation-period"`
+}
+
+// Key is a key stored in Barbican
+type Key struct {
+	ID      string
+	Created time.Time
 }
 
 // Config to read config options
@@ -50,3 +64,56 @@ func (barbican *Barbican) GetSecret(keyID string) ([]byte, error) {
 
 	return key, nil
 }
+
+// ListKeys lists the keys with the name
+func (barbican *Barbican) ListKeys(name string) ([]Key, error) {
+	allPages, err := secrets.List(barbican.Client, secrets.ListOpts{Name: name}).AllPages(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	allSecrets, err := secrets.ExtractSecrets(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(allSecrets))
+	for _, secret := range allSecrets {
+		id, err := openstackutil.ParseSecretID(secret.SecretRef)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, Key{ID: id, Created: secret.Created})
+	}
+
+	return keys, nil
+}
+
+// CreateKey creates a random 256-bit AES key with the name
+func (barbican *Barbican) CreateKey(name string) (*Key, error) {
+	payload := make([]byte, 32)
+	if _, err := rand.Read(payload); err != nil {
+		return nil, err
+	}
+
+	createOpts := secrets.CreateOpts{
+		Name:                   name,
+		Algorithm:              "aes",
+		Mode:                   "cbc",
+		BitLength:              256,
+		Payload:                base64.StdEncoding.EncodeToString(payload),
+		PayloadContentType:     "application/octet-stream",
+		PayloadContentEncoding: "base64",
+		SecretType:             secrets.SymmetricSecret,
+	}
+	secret, err := secrets.Create(context.TODO(), barbican.Client, createOpts).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := openstackutil.ParseSecretID(secret.SecretRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: id, Created: time.Now()}, nil
+}
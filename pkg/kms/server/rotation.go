@@ -0,0 +1,201 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cloud-provider-openstack/pkg/kms/barbican"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+// keyCheckInterval is the interval of the checks of the active key, which catch up with the keys rotated by the
+// plugins of the other control plane nodes.
+const keyCheckInterval = time.Minute
+
+// rotationRaceWindow is the time within which the keys of an alias are considered created by the same rotation, e.g.
+// by the plugins of several control plane nodes rotating at the same time. The oldest of them is the active key on
+// all the nodes, the others are left unused.
+const rotationRaceWindow = 2 * keyCheckInterval
+
+var (
+	keyAge = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "barbican_kms_key_age_seconds",
+			Help: "Age of the active key encrypting the DEKs",
+		})
+	keyRotations = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "barbican_kms_key_rotations_total",
+			Help: "Total number of keys created by the plugin to rotate the active key",
+		})
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the key rotation metrics.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(keyAge, keyRotations)
+	})
+}
+
+// KeyStore lists and creates the keys of an alias
+type KeyStore interface {
+	ListKeys(name string) ([]barbican.Key, error)
+	CreateKey(name string) (*barbican.Key, error)
+}
+
+// keyRotator tracks the active key of an alias, the newest Barbican secret named after the alias. A new key is
+// created once the active one is older than the rotation period, the previous keys are kept to decrypt the DEKs.
+type keyRotator struct {
+	store  KeyStore
+	alias  string
+	period time.Duration
+	now    func() time.Time
+	// jitter is the random delay before creating a key, so that the plugins of the other control plane nodes
+	// rotating at the same time likely find the key created by the first one instead of creating theirs.
+	jitter func() time.Duration
+
+	mu     sync.RWMutex
+	active barbican.Key
+}
+
+func newKeyRotator(store KeyStore, alias string, period time.Duration) *keyRotator {
+	return &keyRotator{
+		store:  store,
+		alias:  alias,
+		period: period,
+		now:    time.Now,
+		jitter: func() time.Duration { return rand.N(keyCheckInterval / 2) },
+	}
+}
+
+// keyID returns the ID of the active key
+func (r *keyRotator) keyID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active.ID
+}
+
+// activeKey returns the active key of the alias: the oldest of the keys created within rotationRaceWindow of the
+// newest one, so that all the plugins agree on it when several of them rotated at the same time. It returns nil if
+// the alias has no key.
+func activeKey(keys []barbican.Key) *barbican.Key {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sorted := append([]barbican.Key(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Created.Equal(sorted[j].Created) {
+			return sorted[i].Created.Before(sorted[j].Created)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	newest := sorted[len(sorted)-1]
+	for i := range sorted {
+		if newest.Created.Sub(sorted[i].Created) <= rotationRaceWindow {
+			return &sorted[i]
+		}
+	}
+	return &newest
+}
+
+// sync looks up the active key, and rotates it when it's older than the rotation period. A key is created if the
+// alias has none yet. Before creating a key, the keys are looked up again after a random delay, a key created
+// meanwhile by another plugin is used instead.
+func (r *keyRotator) sync() error {
+	active, err := r.lookup()
+	if err != nil {
+		return err
+	}
+
+	if r.due(active) {
+		if delay := r.jitter(); delay > 0 {
+			time.Sleep(delay)
+		}
+		previous := active
+		if active, err = r.lookup(); err != nil {
+			return err
+		}
+
+		if r.due(active) {
+			key, err := r.store.CreateKey(r.alias)
+			if err != nil {
+				return fmt.Errorf("failed to create a key for alias %s: %v", r.alias, err)
+			}
+			// Another plugin may have created a key at the same time
+			if active, err = r.lookup(); err != nil {
+				return err
+			}
+			if active == nil || active.ID != key.ID {
+				klog.Infof("Created key %s for alias %s at the same time as another plugin, it is left unused", key.ID, r.alias)
+			} else if previous != nil {
+				keyRotations.Inc()
+				klog.Infof("Rotated key of alias %s from %s to %s", r.alias, previous.ID, key.ID)
+			} else {
+				klog.Infof("Created key %s for alias %s", key.ID, r.alias)
+			}
+			if active == nil {
+				return fmt.Errorf("key %s of alias %s is not listed", key.ID, r.alias)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	if r.active.ID != active.ID {
+		klog.V(2).Infof("Active key of alias %s is %s", r.alias, active.ID)
+	}
+	r.active = *active
+	r.mu.Unlock()
+
+	keyAge.Set(r.now().Sub(active.Created).Seconds())
+
+	return nil
+}
+
+// lookup returns the active key of the alias, nil if it has none.
+func (r *keyRotator) lookup() (*barbican.Key, error) {
+	keys, err := r.store.ListKeys(r.alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the keys of alias %s: %v", r.alias, err)
+	}
+	return activeKey(keys), nil
+}
+
+// due checks whether a key must be created, the alias has none or the active one is older than the rotation period.
+func (r *keyRotator) due(active *barbican.Key) bool {
+	return active == nil || (r.period > 0 && r.now().Sub(active.Created) >= r.period)
+}
+
+// run keeps the active key up to date until stopCh is closed
+func (r *keyRotator) run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := r.sync(); err != nil {
+			klog.Errorf("Failed to sync the key of alias %s: %v", r.alias, err)
+		}
+	}, keyCheckInterval, stopCh)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/cloud-provider-openstack/pkg/kms/barbican"
+	pb "k8s.io/kms/apis/v2"
+)
+
+// fakeKeyStore is a KeyStore and a BarbicanService creating keys at the current fake time
+type fakeKeyStore struct {
+	now  time.Time
+	keys []barbican.Key
+	data fakeKeys
+	// onCreate is called when a key is created, e.g. to create the key of another plugin at the same time
+	onCreate func()
+}
+
+func (f *fakeKeyStore) ListKeys(name string) ([]barbican.Key, error) {
+	return f.keys, nil
+}
+
+func (f *fakeKeyStore) CreateKey(name string) (*barbican.Key, error) {
+	key := barbican.Key{ID: fmt.Sprintf("key-%d", len(f.keys)), Created: f.now}
+	f.keys = append(f.keys, key)
+	f.data[key.ID] = []byte(fmt.Sprintf("0123456789abcde%d", len(f.keys)))
+	if f.onCreate != nil {
+		f.onCreate()
+	}
+	return &key, nil
+}
+
+func (f *fakeKeyStore) GetSecret(keyID string) ([]byte, error) {
+	return f.data.GetSecret(keyID)
+}
+
+func newTestKeyRotator(store *fakeKeyStore, period time.Duration) *keyRotator {
+	r := newKeyRotator(store, "alias", period)
+	r.now = func() time.Time { return store.now }
+	r.jitter = func() time.Duration { return 0 }
+	return r
+}
+
+func TestKeyRotatorSync(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeKeyStore{now: start, data: fakeKeys{}}
+	r := newTestKeyRotator(store, 24*time.Hour)
+
+	// The first key is created
+	if err := r.sync(); err != nil || r.keyID() != "key-0" {
+		t.Fatalf("expected key-0, got %s: %v", r.keyID(), err)
+	}
+
+	// The key is kept within the rotation period
+	store.now = start.Add(12 * time.Hour)
+	if err := r.sync(); err != nil || r.keyID() != "key-0" || len(store.keys) != 1 {
+		t.Fatalf("expected key-0 to be kept, got %s: %v", r.keyID(), err)
+	}
+
+	// The key is rotated after the rotation period
+	store.now = start.Add(24 * time.Hour)
+	if err := r.sync(); err != nil || r.keyID() != "key-1" || len(store.keys) != 2 {
+		t.Fatalf("expected key-1, got %s: %v", r.keyID(), err)
+	}
+
+	// The newest key is the active one, e.g. rotated by another plugin
+	store.keys = append(store.keys, barbican.Key{ID: "other", Created: store.now.Add(time.Hour)})
+	if err := r.sync(); err != nil || r.keyID() != "other" {
+		t.Fatalf("expected other, got %s: %v", r.keyID(), err)
+	}
+}
+
+func TestKeyRotatorWithoutPeriod(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeKeyStore{now: start, keys: []barbican.Key{{ID: "existing", Created: start}}, data: fakeKeys{}}
+	r := newTestKeyRotator(store, 0)
+	r.now = func() time.Time { return start.Add(365 * 24 * time.Hour) }
+
+	if err := r.sync(); err != nil || r.keyID() != "existing" || len(store.keys) != 1 {
+		t.Fatalf("expected existing key to be kept, got %s: %v", r.keyID(), err)
+	}
+}
+
+func TestKeyRotatorConcurrentRotation(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeKeyStore{now: start, keys: []barbican.Key{{ID: "old", Created: start}}, data: fakeKeys{}}
+	r := newTestKeyRotator(store, time.Hour)
+	store.now = start.Add(time.Hour)
+
+	// Another plugin created its key during the delay before the creation
+	r.jitter = func() time.Duration {
+		store.keys = append(store.keys, barbican.Key{ID: "racer", Created: store.now})
+		return 0
+	}
+	if err := r.sync(); err != nil || r.keyID() != "racer" || len(store.keys) != 2 {
+		t.Fatalf("expected the key of the other plugin to be used, got %s with %d keys: %v", r.keyID(), len(store.keys), err)
+	}
+
+	// Another plugin created its key at the same time, the oldest of both is active on both plugins
+	store.keys = []barbican.Key{{ID: "old", Created: start}}
+	r.jitter = func() time.Duration { return 0 }
+	store.onCreate = func() {
+		store.keys = append(store.keys, barbican.Key{ID: "racer", Created: store.now.Add(-time.Second)})
+	}
+	if err := r.sync(); err != nil || r.keyID() != "racer" {
+		t.Fatalf("expected the oldest key of the rotation, got %s: %v", r.keyID(), err)
+	}
+	other := newTestKeyRotator(store, time.Hour)
+	if err := other.sync(); err != nil || other.keyID() != "racer" {
+		t.Fatalf("expected the other plugin to agree on the key, got %s: %v", other.keyID(), err)
+	}
+}
+
+func TestActiveKey(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if key := activeKey(nil); key != nil {
+		t.Fatalf("expected no key, got %s", key.ID)
+	}
+	keys := []barbican.Key{
+		{ID: "b", Created: start.Add(24 * time.Hour)},
+		{ID: "first", Created: start},
+		{ID: "a", Created: start.Add(24 * time.Hour)},
+		{ID: "c", Created: start.Add(24*time.Hour + time.Minute)},
+	}
+	if key := activeKey(keys); key.ID != "a" {
+		t.Fatalf("expected a, got %s", key.ID)
+	}
+	if key := activeKey(keys[:2]); key.ID != "b" {
+		t.Fatalf("expected b, got %s", key.ID)
+	}
+}
+
+func TestEncryptDecryptAcrossRotation(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeKeyStore{now: start, data: fakeKeys{}}
+	srv := &KMSserver{barbican: store, rotator: newTestKeyRotator(store, time.Hour)}
+	if err := srv.rotator.sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeData := []byte("fakedata")
+	encresp, err := srv.Encrypt(context.TODO(), &pb.EncryptRequest{Plaintext: fakeData})
+	if err != nil || encresp.KeyId != "key-0" {
+		t.Fatalf("expected encryption with key-0, got %s: %v", encresp.GetKeyId(), err)
+	}
+
+	store.now = start.Add(time.Hour)
+	if err := srv.rotator.sync(); err != nil {
+		t.Fatal(err)
+	}
+	status, err := srv.Status(context.TODO(), &pb.StatusRequest{})
+	if err != nil || status.KeyId != "key-1" {
+		t.Fatalf("expected active key-1, got %s: %v", status.GetKeyId(), err)
+	}
+
+	decresp, err := srv.Decrypt(context.TODO(), &pb.DecryptRequest{Ciphertext: encresp.Ciphertext, KeyId: encresp.KeyId})
+	if err != nil || !bytes.Equal(decresp.Plaintext, fakeData) {
+		t.Fatalf("failed to decrypt with the previous key: %v", err)
+	}
+}
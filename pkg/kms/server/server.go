@@ -6,9 +6,11 @@ import (
 	"net"
 	"os"
 
+	"github.com/gophercloud/gophercloud/v2"
 	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
 	gcfg "gopkg.in/gcfg.v1"
+	"k8s.io/cloud-provider-openstack/pkg/client"
 	"k8s.io/cloud-provider-openstack/pkg/kms/barbican"
 	"k8s.io/cloud-provider-openstack/pkg/kms/encryption/aescbc"
 	"k8s.io/klog/v2"
@@ -29,37 +31,149 @@ type BarbicanService interface {
 type KMSserver struct {
 	cfg      barbican.Config
 	barbican BarbicanService
+	// rotator tracks the active key when a key alias is configured
+	rotator *keyRotator
 }
 
-func initConfig(configFilePath string, cfg *barbican.Config) error {
-	config, err := os.Open(configFilePath)
-	defer func() { _ = config.Close() }()
-	if err != nil {
-		return err
+// keyID returns the ID of the key encrypting the DEKs
+func (s *KMSserver) keyID() string {
+	if s.rotator != nil {
+		return s.rotator.keyID()
 	}
-	err = gcfg.FatalOnly(gcfg.ReadInto(cfg, config))
-	if err != nil {
-		return err
+	return s.cfg.KeyManager.KeyID
+}
+
+// Options are the options of the KMS plugin that are not part of the cloud config
+type Options struct {
+	// CloudConfig is the path to the cloud-config ini file
+	CloudConfig string
+	// CloudsFile is the path to the clouds.yaml file
+	CloudsFile string
+	// Cloud is the name of the cloud in the clouds.yaml file
+	Cloud string
+	// KeyID and KeyAlias override the [KeyManager] section of the cloud config
+	KeyID    string
+	KeyAlias string
+}
+
+// authEnvOverrides maps the OpenStack environment variables to the auth options they override
+func authEnvOverrides(authOpts *client.AuthOpts) map[string]*string {
+	return map[string]*string{
+		"OS_AUTH_URL":                      &authOpts.AuthURL,
+		"OS_USER_ID":                       &authOpts.UserID,
+		"OS_USERNAME":                      &authOpts.Username,
+		"OS_PASSWORD":                      &authOpts.Password,
+		"OS_PROJECT_ID":                    &authOpts.TenantID,
+		"OS_PROJECT_NAME":                  &authOpts.TenantName,
+		"OS_DOMAIN_ID":                     &authOpts.DomainID,
+		"OS_DOMAIN_NAME":                   &authOpts.DomainName,
+		"OS_PROJECT_DOMAIN_ID":             &authOpts.TenantDomainID,
+		"OS_PROJECT_DOMAIN_NAME":           &authOpts.TenantDomainName,
+		"OS_USER_DOMAIN_ID":                &authOpts.UserDomainID,
+		"OS_USER_DOMAIN_NAME":              &authOpts.UserDomainName,
+		"OS_REGION_NAME":                   &authOpts.Region,
+		"OS_CACERT":                        &authOpts.CAFile,
+		"OS_CERT":                          &authOpts.CertFile,
+		"OS_KEY":                           &authOpts.KeyFile,
+		"OS_APPLICATION_CREDENTIAL_ID":     &authOpts.ApplicationCredentialID,
+		"OS_APPLICATION_CREDENTIAL_NAME":   &authOpts.ApplicationCredentialName,
+		"OS_APPLICATION_CREDENTIAL_SECRET": &authOpts.ApplicationCredentialSecret,
+	}
+}
+
+// initConfig reads the configuration of the plugin. The auth options are taken, from the highest to the lowest
+// priority, from the OS_* environment variables, the [Global] section of the cloud-config file and the cloud of the
+// clouds.yaml. The clouds.yaml is only read without a cloud-config file, or when the cloud-config file or the flags
+// set use-clouds, the clouds file or the cloud. The flags take priority over the cloud-config file.
+func initConfig(opts Options, cfg *barbican.Config) error {
+	if opts.CloudConfig != "" {
+		config, err := os.Open(opts.CloudConfig)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = config.Close() }()
+		err = gcfg.FatalOnly(gcfg.ReadInto(cfg, config))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.CloudsFile != "" {
+		cfg.Global.CloudsFile = opts.CloudsFile
+	}
+	if opts.Cloud != "" {
+		cfg.Global.Cloud = opts.Cloud
+	}
+	// without a cloud config the credentials can only come from a clouds.yaml, OS_CLOUD only selects its cloud
+	if opts.CloudConfig == "" || cfg.Global.CloudsFile != "" || cfg.Global.Cloud != "" {
+		cfg.Global.UseClouds = true
+	}
+
+	if cfg.Global.UseClouds {
+		if err := client.ReadClouds(&cfg.Global); err != nil {
+			return err
+		}
+		klog.V(5).Infof("Config, loaded from the %s:", cfg.Global.CloudsFile)
 	}
+
+	// the environment variables take priority over the config files
+	for env, opt := range authEnvOverrides(&cfg.Global) {
+		if v := os.Getenv(env); v != "" {
+			if *opt != "" && *opt != v {
+				klog.Infof("The %s environment variable overrides the value of the config files", env)
+			}
+			*opt = v
+		}
+	}
+	if v := os.Getenv("OS_INTERFACE"); v != "" {
+		cfg.Global.EndpointType = gophercloud.Availability(v)
+	}
+
+	if opts.KeyID != "" {
+		cfg.KeyManager.KeyID = opts.KeyID
+	}
+	if opts.KeyAlias != "" {
+		cfg.KeyManager.KeyAlias = opts.KeyAlias
+	}
+	client.LogCfg(cfg.Global)
+
 	return nil
 }
 
 // Run Grpc server for barbican KMS
-func Run(configFilePath string, socketpath string, sigchan <-chan os.Signal) (err error) {
+func Run(opts Options, socketpath string, sigchan <-chan os.Signal) (err error) {
 	klog.Infof("Barbican KMS Plugin Starting Version: %s, RunTimeVersion: %s", version, runtimeversion)
 	s := new(KMSserver)
-	err = initConfig(configFilePath, &s.cfg)
+	err = initConfig(opts, &s.cfg)
 	if err != nil {
 		klog.V(4).Infof("Error in Getting Config File: %v", err)
 		return err
 	}
 
-	client, err := barbican.NewBarbicanClient(s.cfg)
+	barbicanClient, err := barbican.NewBarbicanClient(s.cfg)
 	if err != nil {
 		klog.V(4).Infof("Failed to get Barbican client: %v", err)
 		return err
 	}
-	s.barbican = &barbican.Barbican{Client: client}
+	b := &barbican.Barbican{Client: barbicanClient}
+	s.barbican = b
+
+	if s.cfg.KeyManager.KeyAlias != "" {
+		if period := s.cfg.KeyManager.RotationPeriod.Duration; period > 0 && period <= 2*rotationRaceWindow {
+			return fmt.Errorf("rotation-period must be longer than %v", 2*rotationRaceWindow)
+		}
+		RegisterMetrics()
+		s.rotator = newKeyRotator(b, s.cfg.KeyManager.KeyAlias, s.cfg.KeyManager.RotationPeriod.Duration)
+		if err = s.rotator.sync(); err != nil {
+			klog.V(4).Infof("Failed to get the active key: %v", err)
+			return err
+		}
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go s.rotator.run(stopCh)
+	} else if s.cfg.KeyManager.KeyID == "" {
+		return fmt.Errorf("either key-id or key-alias must be set")
+	}
 
 	// unlink the unix socket
 	if err = unix.Unlink(socketpath); err != nil {
@@ -98,14 +212,20 @@ func Run(configFilePath string, socketpath string, sigchan <-chan os.Signal) (er
 	}
 }
 
-// Version returns KMS service version
+// Status returns KMS service version and health, the plugin is healthy when the configured key can be fetched
 func (s *KMSserver) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
 	klog.V(4).Infof("Version Information Requested by Kubernetes api server")
 
+	keyID := s.keyID()
+	if _, err := s.barbican.GetSecret(keyID); err != nil {
+		klog.V(4).Infof("Failed to get key %v: ", err)
+		return nil, err
+	}
+
 	res := &pb.StatusResponse{
 		Version: version,
 		Healthz: "ok",
-		KeyId:   s.cfg.KeyManager.KeyID,
+		KeyId:   keyID,
 	}
 
 	return res, nil
@@ -115,8 +235,13 @@ func (s *KMSserver) Status(ctx context.Context, req *pb.StatusRequest) (*pb.Stat
 func (s *KMSserver) Decrypt(ctx context.Context, req *pb.DecryptRequest) (*pb.DecryptResponse, error) {
 	klog.V(4).Infof("Decrypt Request by Kubernetes api server")
 
-	// TODO: consider using req.KeyId
-	key, err := s.barbican.GetSecret(s.cfg.KeyManager.KeyID)
+	// Use the key the data was encrypted with, so that data encrypted before a key rotation can still be decrypted
+	keyID := req.KeyId
+	if keyID == "" {
+		keyID = s.keyID()
+	}
+
+	key, err := s.barbican.GetSecret(keyID)
 	if err != nil {
 		klog.V(4).Infof("Failed to get key %v: ", err)
 		return nil, err
@@ -135,7 +260,8 @@ func (s *KMSserver) Decrypt(ctx context.Context, req *pb.DecryptRequest) (*pb.De
 func (s *KMSserver) Encrypt(ctx context.Context, req *pb.EncryptRequest) (*pb.EncryptResponse, error) {
 	klog.V(4).Infof("Encrypt Request by Kubernetes api server")
 
-	key, err := s.barbican.GetSecret(s.cfg.KeyManager.KeyID)
+	keyID := s.keyID()
+	key, err := s.barbican.GetSecret(keyID)
 
 	if err != nil {
 		klog.V(4).Infof("Failed to get key %v: ", err)
@@ -148,5 +274,5 @@ func (s *KMSserver) Encrypt(ctx context.Context, req *pb.EncryptRequest) (*pb.En
 		klog.V(4).Infof("Failed to encrypt data %v: ", err)
 		return nil, err
 	}
-	return &pb.EncryptResponse{Ciphertext: cipher, KeyId: s.cfg.KeyManager.KeyID}, nil
+	return &pb.EncryptResponse{Ciphertext: cipher, KeyId: keyID}, nil
 }
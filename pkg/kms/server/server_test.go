@@ -3,21 +3,178 @@ package server
 import (
 	"bytes"
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"k8s.io/cloud-provider-openstack/pkg/client"
 	"k8s.io/cloud-provider-openstack/pkg/kms/barbican"
 	pb "k8s.io/kms/apis/v2"
 )
 
 var s = new(KMSserver)
 
+// fakeKeys is a BarbicanService serving a set of keys by ID
+type fakeKeys map[string][]byte
+
+func (f fakeKeys) GetSecret(keyID string) ([]byte, error) {
+	key, ok := f[keyID]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return key, nil
+}
+
 func TestInitConfig(t *testing.T) {
+	dir := t.TempDir()
+	cloudConfig := filepath.Join(dir, "cloud.conf")
+	cloudsFile := filepath.Join(dir, "clouds.yaml")
+	err := os.WriteFile(cloudConfig, []byte(`[Global]
+region = "ini-region"
+
+[KeyManager]
+key-id = "ini-key"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(cloudsFile, []byte(`clouds:
+  kms:
+    auth:
+      auth_url: "https://keystone.example.com/v3"
+      username: "yaml-user"
+      password: "yaml-password"
+      project_name: "yaml-project"
+    region_name: "yaml-region"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"OS_CLOUD", "OS_CLIENT_CONFIG_FILE", "OS_PASSWORD", "OS_PROJECT_NAME", "OS_REGION_NAME"} {
+		t.Setenv(env, "")
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		env      map[string]string
+		expected barbican.Config
+	}{
+		{
+			name: "clouds.yaml only",
+			opts: Options{CloudsFile: cloudsFile, Cloud: "kms", KeyID: "flag-key"},
+			expected: barbican.Config{
+				Global: client.AuthOpts{
+					AuthURL:    "https://keystone.example.com/v3",
+					Username:   "yaml-user",
+					Password:   "yaml-password",
+					TenantName: "yaml-project",
+					Region:     "yaml-region",
+					UseClouds:  true,
+					CloudsFile: cloudsFile,
+					Cloud:      "kms",
+				},
+				KeyManager: barbican.KMSOpts{KeyID: "flag-key"},
+			},
+		},
+		{
+			name: "OS_CLOUD doesn't read the clouds.yaml along with a cloud config",
+			opts: Options{CloudConfig: cloudConfig},
+			env:  map[string]string{"OS_CLOUD": "kms"},
+			expected: barbican.Config{
+				Global:     client.AuthOpts{Region: "ini-region"},
+				KeyManager: barbican.KMSOpts{KeyID: "ini-key"},
+			},
+		},
+		{
+			name: "cloud config takes priority over the clouds.yaml of the clouds file flag",
+			opts: Options{CloudConfig: cloudConfig, CloudsFile: cloudsFile},
+			env:  map[string]string{"OS_CLOUD": "kms"},
+			expected: barbican.Config{
+				Global: client.AuthOpts{
+					AuthURL:    "https://keystone.example.com/v3",
+					Username:   "yaml-user",
+					Password:   "yaml-password",
+					TenantName: "yaml-project",
+					Region:     "ini-region",
+					UseClouds:  true,
+					CloudsFile: cloudsFile,
+				},
+				KeyManager: barbican.KMSOpts{KeyID: "ini-key"},
+			},
+		},
+		{
+			name: "environment variables override the cloud config",
+			opts: Options{CloudConfig: cloudConfig},
+			env:  map[string]string{"OS_REGION_NAME": "env-region", "OS_PASSWORD": "env-password"},
+			expected: barbican.Config{
+				Global:     client.AuthOpts{Region: "env-region", Password: "env-password"},
+				KeyManager: barbican.KMSOpts{KeyID: "ini-key"},
+			},
+		},
+		{
+			name: "environment variables override the clouds.yaml",
+			opts: Options{CloudsFile: cloudsFile, Cloud: "kms", KeyAlias: "flag-alias"},
+			env:  map[string]string{"OS_PASSWORD": "env-password", "OS_PROJECT_NAME": "env-project"},
+			expected: barbican.Config{
+				Global: client.AuthOpts{
+					AuthURL:    "https://keystone.example.com/v3",
+					Username:   "yaml-user",
+					Password:   "env-password",
+					TenantName: "env-project",
+					Region:     "yaml-region",
+					UseClouds:  true,
+					CloudsFile: cloudsFile,
+					Cloud:      "kms",
+				},
+				KeyManager: barbican.KMSOpts{KeyAlias: "flag-alias"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			var cfg barbican.Config
+			if err := initConfig(test.opts, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, cfg)
+			}
+			if v := os.Getenv("OS_CLIENT_CONFIG_FILE"); v != "" {
+				t.Errorf("expected the environment to be left unchanged, got OS_CLIENT_CONFIG_FILE=%s", v)
+			}
+		})
+	}
+}
+
+func TestInitConfigMissingFile(t *testing.T) {
+	var cfg barbican.Config
+	if err := initConfig(Options{CloudConfig: filepath.Join(t.TempDir(), "missing")}, &cfg); err == nil {
+		t.FailNow()
+	}
 }
 
 func TestStatus(t *testing.T) {
+	s.barbican = &barbican.FakeBarbican{}
 	req := &pb.StatusRequest{}
-	_, err := s.Status(context.TODO(), req)
-	if err != nil {
+	resp, err := s.Status(context.TODO(), req)
+	if err != nil || resp.Healthz != "ok" || resp.Version != version {
+		t.Log(err)
+		t.FailNow()
+	}
+}
+
+func TestStatusKeyUnavailable(t *testing.T) {
+	srv := &KMSserver{barbican: fakeKeys{}}
+	srv.cfg.KeyManager.KeyID = "missing"
+	_, err := srv.Status(context.TODO(), &pb.StatusRequest{})
+	if err == nil {
 		t.FailNow()
 	}
 }
@@ -38,3 +195,26 @@ func TestEncryptDecrypt(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestDecryptRotatedKey(t *testing.T) {
+	keys := fakeKeys{
+		"old": []byte("0123456789abcdef"),
+		"new": []byte("fedcba9876543210"),
+	}
+	srv := &KMSserver{barbican: keys}
+	fakeData := []byte("fakedata")
+
+	srv.cfg.KeyManager.KeyID = "old"
+	encresp, err := srv.Encrypt(context.TODO(), &pb.EncryptRequest{Plaintext: fakeData})
+	if err != nil || encresp.KeyId != "old" {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	srv.cfg.KeyManager.KeyID = "new"
+	decresp, err := srv.Decrypt(context.TODO(), &pb.DecryptRequest{Ciphertext: encresp.Ciphertext, KeyId: encresp.KeyId})
+	if err != nil || !bytes.Equal(decresp.Plaintext, fakeData) {
+		t.Log(err)
+		t.FailNow()
+	}
+}
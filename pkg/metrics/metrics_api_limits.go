@@ -0,0 +1,35 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+)
+
+var apiThrottleWait = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Name:    "cloudprovider_openstack_api_throttle_wait_seconds",
+		Help:    "Time OpenStack API calls waited for the rate limiter or the concurrency limiter, by limiter",
+		Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0},
+	}, []string{"limiter"})
+
+// ObserveAPIThrottleWait records the time an OpenStack API call waited for the given limiter.
+func ObserveAPIThrottleWait(limiter string, wait time.Duration) {
+	apiThrottleWait.WithLabelValues(limiter).Observe(wait.Seconds())
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"k8s.io/component-base/metrics"
+)
+
+var (
+	applicationCredentialExpiration = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cloudprovider_openstack_application_credential_expiration_timestamp_seconds",
+			Help: "Expiration time of the Keystone application credential used by OpenStack cloud controller manager, in seconds since the Unix epoch",
+		}, []string{"application_credential_id"})
+
+	credentialsReloads = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "cloudprovider_openstack_credentials_reloads_total",
+			Help: "Total number of reloads of the credentials of the cloud config by OpenStack cloud controller manager, by result",
+		}, []string{"result"})
+)
+
+// SetApplicationCredentialExpiration records the expiration time of the application credential.
+func SetApplicationCredentialExpiration(id string, expiresAt time.Time) {
+	applicationCredentialExpiration.WithLabelValues(id).Set(float64(expiresAt.Unix()))
+}
+
+// ResetApplicationCredentialExpiration removes the expiration time of the previous application credentials.
+func ResetApplicationCredentialExpiration() {
+	applicationCredentialExpiration.Reset()
+}
+
+// ObserveCredentialsReload records a reload of the credentials of the cloud config.
+func ObserveCredentialsReload(err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	credentialsReloads.WithLabelValues(result).Inc()
+}
@@ -58,6 +58,13 @@ func doRegisterOccmMetrics() {
 			occmReconcileMetrics.Duration,
 			occmReconcileMetrics.Total,
 			occmReconcileMetrics.Errors,
+			applicationCredentialExpiration,
+			credentialsReloads,
+			serviceReconcileDuration,
+			serviceReconcileAPICalls,
+			serviceAPIErrors,
+			serviceDestructiveChanges,
+			apiThrottleWait,
 		)
 	})
 }
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/component-base/metrics"
+)
+
+// ServiceReconcileHeader marks the OpenStack API requests sent on behalf of a Service reconciliation. The header is
+// set on the service clients returned by ServiceReconcile.ServiceClientHeaders and removed by the transport returned by
+// NewServiceReconcileTransport before sending the request.
+const ServiceReconcileHeader = "X-Cloud-Provider-Openstack-Reconcile"
+
+// OtherServiceLabel is the namespace and name label value of the Services beyond the label limit.
+const OtherServiceLabel = "_other"
+
+// DefaultServiceLabelLimit is the default number of Services having their own metrics labels.
+const DefaultServiceLabelLimit = 100
+
+var (
+	serviceReconcileDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:    "cloudprovider_openstack_service_reconcile_duration_seconds",
+			Help:    "Time taken by the load balancer reconciliations of a Service",
+			Buckets: []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0, 20.0, 30.0, 60.0, 120.0, 300.0, 600.0},
+		}, []string{"namespace", "name", "operation"})
+	serviceReconcileAPICalls = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:    "cloudprovider_openstack_service_reconcile_api_calls",
+			Help:    "Number of OpenStack load balancer API calls of the load balancer reconciliations of a Service",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+		}, []string{"namespace", "name", "operation"})
+	serviceAPIErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "cloudprovider_openstack_service_api_errors_total",
+			Help: "Total number of failed OpenStack load balancer API calls of the load balancer reconciliations of a Service, by HTTP status code",
+		}, []string{"namespace", "name", "code"})
+	serviceDestructiveChanges = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "cloudprovider_openstack_service_destructive_changes_total",
+			Help: "Total number of load balancer reconciliations of a Service deleting or recreating more listeners and pools than allowed, by result",
+		}, []string{"namespace", "name", "result"})
+)
+
+var (
+	serviceLabelsMu    sync.Mutex
+	serviceLabelLimit  = DefaultServiceLabelLimit
+	serviceLabelsInUse = make(map[string]bool)
+
+	// serviceReconciles are the reconciliations in progress by ID.
+	serviceReconciles sync.Map
+)
+
+// SetServiceLabelLimit sets the number of Services having their own metrics labels, the metrics of the other ones are
+// aggregated under OtherServiceLabel. 0 aggregates all the Services.
+func SetServiceLabelLimit(limit int) {
+	serviceLabelsMu.Lock()
+	defer serviceLabelsMu.Unlock()
+
+	serviceLabelLimit = limit
+}
+
+// serviceLabels returns the metrics labels of the Service, OtherServiceLabel once the label limit is reached.
+func serviceLabels(namespace, name string) (string, string) {
+	serviceLabelsMu.Lock()
+	defer serviceLabelsMu.Unlock()
+
+	key := namespace + "/" + name
+	if serviceLabelsInUse[key] {
+		return namespace, name
+	}
+	if len(serviceLabelsInUse) >= serviceLabelLimit {
+		return OtherServiceLabel, OtherServiceLabel
+	}
+	serviceLabelsInUse[key] = true
+
+	return namespace, name
+}
+
+// ForgetService removes the metrics of the deleted Service, freeing its labels for another Service.
+func ForgetService(namespace, name string) {
+	serviceLabelsMu.Lock()
+	defer serviceLabelsMu.Unlock()
+
+	key := namespace + "/" + name
+	if !serviceLabelsInUse[key] {
+		return
+	}
+	delete(serviceLabelsInUse, key)
+
+	// The metrics are only created once registered
+	if !serviceReconcileDuration.IsCreated() {
+		return
+	}
+	labels := map[string]string{"namespace": namespace, "name": name}
+	serviceReconcileDuration.DeletePartialMatch(labels)
+	serviceReconcileAPICalls.DeletePartialMatch(labels)
+	serviceAPIErrors.DeletePartialMatch(labels)
+	serviceDestructiveChanges.DeletePartialMatch(labels)
+}
+
+// ObserveDestructiveChanges counts a reconciliation of the Service deleting or recreating more listeners and pools
+// than allowed, blocked or applied once acknowledged.
+func ObserveDestructiveChanges(namespace, name string, acknowledged bool) {
+	namespace, name = serviceLabels(namespace, name)
+	result := "blocked"
+	if acknowledged {
+		result = "acknowledged"
+	}
+	serviceDestructiveChanges.WithLabelValues(namespace, name, result).Inc()
+}
+
+// ServiceReconcile records the duration and the OpenStack API calls of a load balancer reconciliation of a Service.
+type ServiceReconcile struct {
+	id        string
+	namespace string
+	name      string
+	operation string
+	start     time.Time
+	calls     atomic.Int64
+}
+
+// NewServiceReconcile starts recording the reconciliation of the Service.
+func NewServiceReconcile(namespace, name, operation string) *ServiceReconcile {
+	namespace, name = serviceLabels(namespace, name)
+	sr := &ServiceReconcile{
+		id:        uuid.NewString(),
+		namespace: namespace,
+		name:      name,
+		operation: operation,
+		start:     time.Now(),
+	}
+	serviceReconciles.Store(sr.id, sr)
+
+	return sr
+}
+
+// ServiceClientHeaders returns the MoreHeaders of a service client whose API calls are counted for the
+// reconciliation.
+func (sr *ServiceReconcile) ServiceClientHeaders(headers map[string]string) map[string]string {
+	res := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		res[k] = v
+	}
+	res[ServiceReconcileHeader] = sr.id
+
+	return res
+}
+
+// Calls returns the number of API calls of the reconciliation so far.
+func (sr *ServiceReconcile) Calls() int64 {
+	return sr.calls.Load()
+}
+
+// Observe records the duration and the number of API calls of the finished reconciliation.
+func (sr *ServiceReconcile) Observe() {
+	serviceReconciles.Delete(sr.id)
+
+	serviceReconcileDuration.WithLabelValues(sr.namespace, sr.name, sr.operation).Observe(time.Since(sr.start).Seconds())
+	serviceReconcileAPICalls.WithLabelValues(sr.namespace, sr.name, sr.operation).Observe(float64(sr.calls.Load()))
+}
+
+type serviceReconcileTransport struct {
+	rt http.RoundTripper
+}
+
+// NewServiceReconcileTransport returns a transport counting the API calls and errors of the requests marked with
+// ServiceReconcileHeader for their reconciliation.
+func NewServiceReconcileTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &serviceReconcileTransport{rt: rt}
+}
+
+func (t *serviceReconcileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := req.Header.Get(ServiceReconcileHeader)
+	if id == "" {
+		return t.rt.RoundTrip(req)
+	}
+
+	// The transport must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Del(ServiceReconcileHeader)
+
+	v, ok := serviceReconciles.Load(id)
+	if !ok {
+		return t.rt.RoundTrip(req)
+	}
+	sr := v.(*ServiceReconcile)
+	sr.calls.Add(1)
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		serviceAPIErrors.WithLabelValues(sr.namespace, sr.name, "error").Inc()
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		serviceAPIErrors.WithLabelValues(sr.namespace, sr.name, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+
+	return resp, err
+}
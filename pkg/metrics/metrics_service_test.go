@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceLabels(t *testing.T) {
+	SetServiceLabelLimit(1)
+	defer SetServiceLabelLimit(DefaultServiceLabelLimit)
+
+	namespace, name := serviceLabels("default", "first")
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, "first", name)
+
+	namespace, name = serviceLabels("default", "second")
+	assert.Equal(t, OtherServiceLabel, namespace)
+	assert.Equal(t, OtherServiceLabel, name)
+
+	// The labels of a deleted Service are reused
+	ForgetService("default", "first")
+	namespace, name = serviceLabels("default", "second")
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, "second", name)
+	ForgetService("default", "second")
+}
+
+func TestServiceReconcileTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get(ServiceReconcileHeader))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sr := NewServiceReconcile("default", "svc", "ensure")
+	defer ForgetService("default", "svc")
+	client := &http.Client{Transport: NewServiceReconcileTransport(nil)}
+
+	for _, path := range []string{"/", "/missing"} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		assert.NoError(t, err)
+		for k, v := range sr.ServiceClientHeaders(nil) {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The requests of other clients aren't counted
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.EqualValues(t, 2, sr.Calls())
+	sr.Observe()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+)
+
+// APILimitsOpts is used to throttle the OpenStack API calls of OCCM, e.g. not to trip the rate limits of Keystone and
+// Neutron when all the Services are reconciled after a restart of the cluster.
+type APILimitsOpts struct {
+	QPS                   float64 `gcfg:"qps"`                     // default 0, no rate limit
+	Burst                 int     `gcfg:"burst"`                   // default 0, max(1, qps)
+	MaxConcurrentRequests int     `gcfg:"max-concurrent-requests"` // default 0, no limit
+}
+
+// throttleTransport sends the API requests once allowed by the token bucket and the concurrency cap, the requests
+// waiting for them are canceled with their context.
+type throttleTransport struct {
+	rt       http.RoundTripper
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+}
+
+// newThrottleTransport returns a transport applying the limits to all the requests, rt when there is no limit.
+func newThrottleTransport(rt http.RoundTripper, opts APILimitsOpts) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if opts.QPS <= 0 && opts.MaxConcurrentRequests <= 0 {
+		return rt
+	}
+
+	t := &throttleTransport{rt: rt}
+	if opts.QPS > 0 {
+		burst := opts.Burst
+		if burst <= 0 {
+			burst = max(1, int(opts.QPS))
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(opts.QPS), burst)
+	}
+	if opts.MaxConcurrentRequests > 0 {
+		t.inFlight = make(chan struct{}, opts.MaxConcurrentRequests)
+	}
+	return t
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if t.inFlight != nil {
+		start := time.Now()
+		select {
+		case t.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-t.inFlight }()
+		metrics.ObserveAPIThrottleWait("concurrency", time.Since(start))
+	}
+
+	if t.limiter != nil {
+		start := time.Now()
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		metrics.ObserveAPIThrottleWait("rate", time.Since(start))
+	}
+
+	return t.rt.RoundTrip(req)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestThrottleTransportDisabled(t *testing.T) {
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	_, ok := newThrottleTransport(rt, APILimitsOpts{}).(roundTripperFunc)
+	assert.True(t, ok)
+}
+
+func TestThrottleTransportConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	transport := newThrottleTransport(rt, APILimitsOpts{MaxConcurrentRequests: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://openstack", nil)
+			_, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestThrottleTransportRate(t *testing.T) {
+	var calls atomic.Int32
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	transport := newThrottleTransport(rt, APILimitsOpts{QPS: 0.1, Burst: 1})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://openstack", nil)
+	_, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+
+	// The bucket is empty, the next request waits beyond its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = transport.RoundTrip(req.WithContext(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
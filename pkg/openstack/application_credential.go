@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/applicationcredentials"
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/tokens"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/client"
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	"k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+// ApplicationCredentialOpts is used to check the expiration of the application credential OCCM authenticates with
+type ApplicationCredentialOpts struct {
+	ExpiryCheckInterval util.MyDuration `gcfg:"expiry-check-interval"` // default 1h, 0 disables the check
+	ExpiryWarningDays   int             `gcfg:"expiry-warning-days"`   // default 14
+}
+
+// tokenResult is implemented by the results of the Keystone token requests.
+type tokenResult interface {
+	ExtractUser() (*tokens.User, error)
+	ExtractInto(v any) error
+}
+
+// getApplicationCredential returns the application credential the token was issued for. The token is introspected
+// when authResult doesn't hold the token details.
+func getApplicationCredential(ctx context.Context, identity *gophercloud.ServiceClient, authResult gophercloud.AuthResult) (*applicationcredentials.ApplicationCredential, error) {
+	result, ok := authResult.(tokenResult)
+	if !ok {
+		mc := metrics.NewMetricContext("token", "get")
+		r := tokens.Get(ctx, identity, identity.Token())
+		if mc.ObserveRequest(r.Err) != nil {
+			return nil, fmt.Errorf("failed to get the token details: %v", r.Err)
+		}
+		result = r
+	}
+
+	user, err := result.ExtractUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the token user: %v", err)
+	}
+	var token struct {
+		ApplicationCredential struct {
+			ID string `json:"id"`
+		} `json:"application_credential"`
+	}
+	if err := result.ExtractInto(&token); err != nil {
+		return nil, fmt.Errorf("failed to get the token application credential: %v", err)
+	}
+	if token.ApplicationCredential.ID == "" {
+		return nil, fmt.Errorf("the token was not issued for an application credential")
+	}
+
+	mc := metrics.NewMetricContext("application_credential", "get")
+	appCred, err := applicationcredentials.Get(ctx, identity, user.ID, token.ApplicationCredential.ID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("failed to get application credential %s: %v", token.ApplicationCredential.ID, err)
+	}
+	return appCred, nil
+}
+
+// applicationCredentialExpiryMessage returns the warning to report for the application credential, or an empty string
+// when it doesn't expire within warningDays.
+func applicationCredentialExpiryMessage(appCred *applicationcredentials.ApplicationCredential, now time.Time, warningDays int) string {
+	if appCred.ExpiresAt.IsZero() {
+		return ""
+	}
+	remaining := appCred.ExpiresAt.Sub(now)
+	if remaining <= 0 {
+		return fmt.Sprintf("Application credential %s (%s) expired at %s", appCred.Name, appCred.ID, appCred.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if remaining > time.Duration(warningDays)*24*time.Hour {
+		return ""
+	}
+	return fmt.Sprintf("Application credential %s (%s) expires at %s, in %d days", appCred.Name, appCred.ID, appCred.ExpiresAt.UTC().Format(time.RFC3339), int(remaining.Hours()/24))
+}
+
+// usesApplicationCredential checks whether the auth options authenticate with an application credential.
+func usesApplicationCredential(opts client.AuthOpts) bool {
+	return opts.ApplicationCredentialID != "" || opts.ApplicationCredentialName != ""
+}
+
+// runApplicationCredentialExpiryCheck periodically checks the expiration of the application credential OCCM
+// authenticates with, and records a Warning event when it expires within the configured number of days. The check is
+// skipped while enabled returns false, i.e. while OCCM authenticates with other credentials.
+func runApplicationCredentialExpiryCheck(provider *gophercloud.ProviderClient, epOpts *gophercloud.EndpointOpts, opts ApplicationCredentialOpts, enabled func() bool, recorder record.EventRecorder, stopCh <-chan struct{}) {
+	identity, err := client.NewIdentityV3(provider, epOpts)
+	if err != nil {
+		klog.Errorf("Failed to create the identity client, the application credential expiration is not checked: %v", err)
+		return
+	}
+
+	wait.Until(func() {
+		if !enabled() {
+			return
+		}
+		appCred, err := getApplicationCredential(context.TODO(), identity, provider.GetAuthResult())
+		if err != nil {
+			klog.Errorf("Failed to check the application credential expiration: %v", err)
+			return
+		}
+		if appCred.ExpiresAt.IsZero() {
+			klog.V(4).Infof("Application credential %s doesn't expire", appCred.ID)
+			return
+		}
+		metrics.SetApplicationCredentialExpiration(appCred.ID, appCred.ExpiresAt)
+
+		message := applicationCredentialExpiryMessage(appCred, time.Now(), opts.ExpiryWarningDays)
+		if message == "" {
+			return
+		}
+		klog.Warning(message)
+		ref := &v1.ObjectReference{
+			Kind:      "ApplicationCredential",
+			Name:      appCred.ID,
+			Namespace: metav1.NamespaceSystem,
+		}
+		recorder.Event(ref, v1.EventTypeWarning, eventApplicationCredentialExpiring, message)
+	}, opts.ExpiryCheckInterval.Duration, stopCh)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/identity/v3/applicationcredentials"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetApplicationCredential(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		th.TestHeader(t, r, "X-Subject-Token", fakeclient.TokenID)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"token": {
+			"user": {"id": "user-id", "name": "occm"},
+			"application_credential": {"id": "appcred-id", "name": "occm", "restricted": true}
+		}}`)
+	})
+	th.Mux.HandleFunc("/users/user-id/application_credentials/appcred-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"application_credential": {"id": "appcred-id", "name": "occm", "expires_at": "2024-06-01T12:00:00.000000"}}`)
+	})
+
+	appCred, err := getApplicationCredential(context.TODO(), fakeclient.ServiceClient(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "appcred-id", appCred.ID)
+	assert.Equal(t, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), appCred.ExpiresAt)
+}
+
+func TestGetApplicationCredentialWithoutApplicationCredential(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"token": {"user": {"id": "user-id", "name": "occm"}}}`)
+	})
+
+	_, err := getApplicationCredential(context.TODO(), fakeclient.ServiceClient(), nil)
+	assert.Error(t, err)
+}
+
+func TestApplicationCredentialExpiryMessage(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		expiresAt time.Time
+		expected  string
+	}{
+		{
+			name: "no expiration",
+		},
+		{
+			name:      "expires later",
+			expiresAt: now.Add(15 * 24 * time.Hour),
+		},
+		{
+			name:      "expires soon",
+			expiresAt: now.Add(3*24*time.Hour + time.Hour),
+			expected:  "Application credential occm (appcred-id) expires at 2024-06-04T13:00:00Z, in 3 days",
+		},
+		{
+			name:      "expired",
+			expiresAt: now.Add(-time.Hour),
+			expected:  "Application credential occm (appcred-id) expired at 2024-06-01T11:00:00Z",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appCred := &applicationcredentials.ApplicationCredential{ID: "appcred-id", Name: "occm", ExpiresAt: tc.expiresAt}
+			assert.Equal(t, tc.expected, applicationCredentialExpiryMessage(appCred, now, 14))
+		})
+	}
+}
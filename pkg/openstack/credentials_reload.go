@@ -0,0 +1,246 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	goos "os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gophercloud/gophercloud/v2"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/client"
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+)
+
+// reloadCloudConfig is set to reload the credentials of the cloud config file whenever it changes
+var reloadCloudConfig bool
+
+// credentials swaps the credentials of the provider client shared by all the OpenStack clients of OCCM. The clients
+// keep their endpoints, only the token and the way to renew it are replaced, once the requests in flight are done.
+type credentials struct {
+	// drain is held for reading by the requests in flight, and for writing while the credentials are swapped
+	drain sync.RWMutex
+
+	mu sync.Mutex
+	// reauth renews the token of the provider client with the current credentials
+	reauth func(context.Context) error
+}
+
+// newCredentials makes the credentials of the provider client swappable, its requests are drained by the returned
+// credentials and it is re-authenticated with the current credentials.
+func newCredentials(provider *gophercloud.ProviderClient) *credentials {
+	c := &credentials{reauth: provider.ReauthFunc}
+	provider.HTTPClient.Transport = &drainTransport{rt: provider.HTTPClient.Transport, drain: &c.drain}
+	provider.ReauthFunc = c.reauthenticate
+	return c
+}
+
+func (c *credentials) reauthenticate(ctx context.Context) error {
+	c.mu.Lock()
+	reauth := c.reauth
+	c.mu.Unlock()
+
+	if reauth == nil {
+		return fmt.Errorf("the credentials don't allow re-authentication")
+	}
+	return reauth(ctx)
+}
+
+// swap replaces the credentials of provider with the ones fresh is authenticated with. The requests in flight are
+// done with the previous token, the following ones wait for the swap.
+func (c *credentials) swap(provider, fresh *gophercloud.ProviderClient) {
+	c.drain.Lock()
+	defer c.drain.Unlock()
+
+	provider.CopyTokenFrom(fresh)
+
+	c.mu.Lock()
+	c.reauth = func(ctx context.Context) error {
+		if fresh.ReauthFunc == nil {
+			return fmt.Errorf("the credentials don't allow re-authentication")
+		}
+		if err := fresh.ReauthFunc(ctx); err != nil {
+			return err
+		}
+		provider.CopyTokenFrom(fresh)
+		return nil
+	}
+	c.mu.Unlock()
+}
+
+// drainTransport holds the drain lock for reading along the requests, so that the credentials are swapped once they
+// are done.
+type drainTransport struct {
+	rt    http.RoundTripper
+	drain *sync.RWMutex
+}
+
+func (t *drainTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := t.rt
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	t.drain.RLock()
+	defer t.drain.RUnlock()
+	return rt.RoundTrip(req)
+}
+
+// withoutCredentials returns the auth options but the credentials, the clients have to be recreated when they change.
+func withoutCredentials(opts client.AuthOpts) client.AuthOpts {
+	opts.UserID = ""
+	opts.Username = ""
+	opts.Password = ""
+	opts.TrusteeID = ""
+	opts.TrusteePassword = ""
+	opts.ApplicationCredentialID = ""
+	opts.ApplicationCredentialName = ""
+	opts.ApplicationCredentialSecret = ""
+	return opts
+}
+
+// credentialsChanged checks whether the credentials of the cloud config changed, the changes of the other options
+// can't be applied without a restart.
+func credentialsChanged(current, cfg Config) (bool, error) {
+	if reflect.DeepEqual(current.Global, cfg.Global) {
+		return false, nil
+	}
+	if !reflect.DeepEqual(withoutCredentials(current.Global), withoutCredentials(cfg.Global)) {
+		return false, fmt.Errorf("options of the [Global] section other than the credentials changed, a restart is required to apply them")
+	}
+	return true, nil
+}
+
+// SetCloudConfigFile sets the path of the cloud config file, reloaded when --reload-cloud-config is set
+func (os *OpenStack) SetCloudConfigFile(path string) {
+	os.cloudConfigFile = path
+}
+
+// reloadCredentials authenticates with the credentials of the cloud config when they changed, and swaps them for the
+// ones of the clients on success. The clients keep the previous credentials when the authentication fails.
+func (os *OpenStack) reloadCredentials(cfg Config) (bool, error) {
+	changed, err := credentialsChanged(os.config, cfg)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	current := os.config
+	current.Global = cfg.Global
+	if !reflect.DeepEqual(current, cfg) {
+		klog.Warning("Only the credentials of the cloud config are reloaded, a restart is required to apply the changes of the other options")
+	}
+
+	fresh, err := client.NewOpenStackClient(&cfg.Global, "openstack-cloud-controller-manager", userAgentData...)
+	if err != nil {
+		return false, fmt.Errorf("failed to authenticate with the new credentials: %v", err)
+	}
+	fresh.HTTPClient.Timeout = os.provider.HTTPClient.Timeout
+
+	os.credentials.swap(os.provider, fresh)
+	os.config.Global = cfg.Global
+	os.useApplicationCredential.Store(usesApplicationCredential(cfg.Global))
+	// The expiration of the previous application credential isn't relevant anymore, the one of the new credentials is
+	// recorded by the next expiry check
+	metrics.ResetApplicationCredentialExpiration()
+
+	return true, nil
+}
+
+// readConfigFile reads the cloud config file at path.
+func readConfigFile(path string) (Config, error) {
+	f, err := goos.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+
+	return ReadConfig(f)
+}
+
+// watchCloudConfig reloads the credentials whenever the cloud config file, or the clouds.yaml file it refers to,
+// changes, until stopCh is closed.
+func (os *OpenStack) watchCloudConfig(stopCh <-chan struct{}) {
+	if os.cloudConfigFile == "" {
+		klog.Warning("No cloud config file to reload, --reload-cloud-config is ignored")
+		return
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Errorf("Failed to create the cloud config file watcher, the credentials are not reloaded: %v", err)
+		return
+	}
+	defer w.Close()
+
+	// The directories are watched rather than the files, as Secret volumes update the files by swapping a symlink,
+	// which doesn't generate any event for the files themselves.
+	dirs := []string{filepath.Dir(os.cloudConfigFile)}
+	if os.config.Global.UseClouds && os.config.Global.CloudsFile != "" {
+		dirs = append(dirs, filepath.Dir(os.config.Global.CloudsFile))
+	}
+	for _, dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			klog.Errorf("Failed to watch %s, the credentials are not reloaded: %v", dir, err)
+			return
+		}
+	}
+	klog.InfoS("Watching the cloud config for credentials changes", "path", os.cloudConfigFile)
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			os.reloadCloudConfigFile()
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("Error watching the cloud config file %s: %v", os.cloudConfigFile, err)
+		}
+	}
+}
+
+func (os *OpenStack) reloadCloudConfigFile() {
+	cfg, err := readConfigFile(os.cloudConfigFile)
+	reloaded := false
+	if err == nil {
+		reloaded, err = os.reloadCredentials(cfg)
+	}
+	if err != nil {
+		klog.Errorf("Failed to reload the credentials of the cloud config file %s, keeping the previous ones: %v", os.cloudConfigFile, err)
+		metrics.ObserveCredentialsReload(err)
+		return
+	}
+	if reloaded {
+		klog.InfoS("Reloaded the credentials of the cloud config", "path", os.cloudConfigFile)
+		metrics.ObserveCredentialsReload(nil)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	goos "os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/cloud-provider-openstack/pkg/client"
+)
+
+func TestCredentialsChanged(t *testing.T) {
+	current := Config{Global: client.AuthOpts{
+		AuthURL:                     "https://keystone.example.com/v3",
+		Region:                      "RegionOne",
+		ApplicationCredentialID:     "old-id",
+		ApplicationCredentialSecret: "old-secret",
+	}}
+
+	testCases := []struct {
+		name        string
+		update      func(cfg *Config)
+		expected    bool
+		expectedErr bool
+	}{
+		{
+			name:   "unchanged",
+			update: func(cfg *Config) {},
+		},
+		{
+			name: "rotated application credential",
+			update: func(cfg *Config) {
+				cfg.Global.ApplicationCredentialID = "new-id"
+				cfg.Global.ApplicationCredentialSecret = "new-secret"
+			},
+			expected: true,
+		},
+		{
+			name: "other section changed",
+			update: func(cfg *Config) {
+				cfg.LoadBalancer.Enabled = true
+			},
+		},
+		{
+			name: "region changed",
+			update: func(cfg *Config) {
+				cfg.Global.ApplicationCredentialSecret = "new-secret"
+				cfg.Global.Region = "RegionTwo"
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := current
+			tc.update(&cfg)
+
+			changed, err := credentialsChanged(current, cfg)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, changed)
+		})
+	}
+}
+
+func TestCredentials_swap(t *testing.T) {
+	provider := &gophercloud.ProviderClient{}
+	provider.SetToken("old-token")
+	provider.ReauthFunc = func(context.Context) error {
+		provider.SetToken("old-token-renewed")
+		return nil
+	}
+	c := newCredentials(provider)
+
+	assert.NoError(t, provider.ReauthFunc(context.TODO()))
+	assert.Equal(t, "old-token-renewed", provider.Token())
+
+	fresh := &gophercloud.ProviderClient{}
+	fresh.SetToken("new-token")
+	fresh.ReauthFunc = func(context.Context) error {
+		fresh.SetToken("new-token-renewed")
+		return nil
+	}
+	c.swap(provider, fresh)
+	assert.Equal(t, "new-token", provider.Token())
+
+	// The token is renewed with the new credentials
+	assert.NoError(t, provider.ReauthFunc(context.TODO()))
+	assert.Equal(t, "new-token-renewed", provider.Token())
+}
+
+type blockingTransport struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.started <- struct{}{}
+	<-t.release
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func TestCredentials_swapDrainsRequests(t *testing.T) {
+	rt := &blockingTransport{started: make(chan struct{}), release: make(chan struct{})}
+	provider := &gophercloud.ProviderClient{HTTPClient: http.Client{Transport: rt}}
+	provider.SetToken("old-token")
+	c := newCredentials(provider)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://openstack.example.com", nil)
+	go func() { _, _ = provider.HTTPClient.Transport.RoundTrip(req) }()
+	<-rt.started
+
+	fresh := &gophercloud.ProviderClient{}
+	fresh.SetToken("new-token")
+	swapped := make(chan struct{})
+	go func() {
+		c.swap(provider, fresh)
+		close(swapped)
+	}()
+
+	select {
+	case <-swapped:
+		t.Fatal("the credentials were swapped while a request was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, "old-token", provider.Token())
+
+	close(rt.release)
+	<-swapped
+	assert.Equal(t, "new-token", provider.Token())
+}
+
+// newFakeKeystone returns a Keystone API issuing the token "token-<id>" for the application credential <id>, and the
+// token "token-password" for the password credentials. The application credential "revoked" is refused.
+func newFakeKeystone(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Auth struct {
+				Identity struct {
+					Methods               []string `json:"methods"`
+					ApplicationCredential struct {
+						ID string `json:"id"`
+					} `json:"application_credential"`
+				} `json:"identity"`
+			} `json:"auth"`
+		}
+		if r.URL.Path != "/v3/auth/tokens" || json.NewDecoder(r.Body).Decode(&req) != nil || len(req.Auth.Identity.Methods) != 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token := "token-password"
+		if id := req.Auth.Identity.ApplicationCredential.ID; id != "" {
+			token = "token-" + id
+		}
+		if token == "token-revoked" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Subject-Token", token)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"token": {"methods": [%q], "expires_at": "2099-01-01T00:00:00Z", "catalog": []}}`, req.Auth.Identity.Methods[0])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeCloudConfig writes a cloud config authenticating with the application credential appCredID, or with a password
+// when it's empty.
+func writeCloudConfig(t *testing.T, path, authURL, appCredID string) {
+	credentials := "user-id=user\npassword=password\n"
+	if appCredID != "" {
+		credentials = fmt.Sprintf("application-credential-id=%s\napplication-credential-secret=secret\n", appCredID)
+	}
+	content := fmt.Sprintf("[Global]\nauth-url=%s/v3/\n%s", authURL, credentials)
+	assert.NoError(t, goos.WriteFile(path, []byte(content), 0600))
+}
+
+// newReloadableOpenStack returns the OpenStack provider authenticated with the cloud config file at path, whose
+// credentials are reloadable.
+func newReloadableOpenStack(t *testing.T, path string) *OpenStack {
+	cfg, err := readConfigFile(path)
+	assert.NoError(t, err)
+	provider, err := client.NewOpenStackClient(&cfg.Global, "openstack-cloud-controller-manager")
+	assert.NoError(t, err)
+
+	os := &OpenStack{
+		provider:        provider,
+		config:          cfg,
+		cloudConfigFile: path,
+		credentials:     newCredentials(provider),
+	}
+	os.useApplicationCredential.Store(usesApplicationCredential(cfg.Global))
+	return os
+}
+
+func TestReloadCloudConfigFile(t *testing.T) {
+	keystone := newFakeKeystone(t)
+	path := filepath.Join(t.TempDir(), "cloud.conf")
+	writeCloudConfig(t, path, keystone.URL, "")
+	os := newReloadableOpenStack(t, path)
+	assert.Equal(t, "token-password", os.provider.Token())
+	assert.False(t, os.useApplicationCredential.Load())
+
+	// Switching to an application credential enables its expiry check
+	writeCloudConfig(t, path, keystone.URL, "first")
+	os.reloadCloudConfigFile()
+	assert.Equal(t, "token-first", os.provider.Token())
+	assert.Equal(t, "first", os.config.Global.ApplicationCredentialID)
+	assert.True(t, os.useApplicationCredential.Load())
+
+	// The clients keep the previous credentials when the new ones are refused
+	writeCloudConfig(t, path, keystone.URL, "revoked")
+	os.reloadCloudConfigFile()
+	assert.Equal(t, "token-first", os.provider.Token())
+	assert.Equal(t, "first", os.config.Global.ApplicationCredentialID)
+
+	// The token is renewed with the reloaded credentials
+	writeCloudConfig(t, path, keystone.URL, "second")
+	os.reloadCloudConfigFile()
+	os.provider.SetToken("expired")
+	assert.NoError(t, os.provider.Reauthenticate(context.TODO(), "expired"))
+	assert.Equal(t, "token-second", os.provider.Token())
+
+	// The other options aren't reloaded
+	assert.NoError(t, goos.WriteFile(path, []byte(fmt.Sprintf("[Global]\nauth-url=%s/v3/\nregion=RegionTwo\napplication-credential-id=third\napplication-credential-secret=secret\n", keystone.URL)), 0600))
+	os.reloadCloudConfigFile()
+	assert.Equal(t, "token-second", os.provider.Token())
+}
+
+func TestWatchCloudConfig(t *testing.T) {
+	keystone := newFakeKeystone(t)
+	path := filepath.Join(t.TempDir(), "cloud.conf")
+	writeCloudConfig(t, path, keystone.URL, "first")
+	os := newReloadableOpenStack(t, path)
+
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		os.watchCloudConfig(stopCh)
+		close(stopped)
+	}()
+
+	// The file is rewritten until the watcher, started asynchronously, notices it
+	assert.Eventually(t, func() bool {
+		writeCloudConfig(t, path, keystone.URL, "second")
+		return os.provider.Token() == "token-second"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	close(stopCh)
+	<-stopped
+}
@@ -19,9 +19,32 @@ package openstack
 const (
 	eventLBForceInternal               = "LoadBalancerForcedInternal"
 	eventLBExternalNetworkSearchFailed = "LoadBalancerExternalNetworkSearchFailed"
+	eventLBFloatingNetworkMissing      = "LoadBalancerFloatingNetworkMissing"
+	eventLBFloatingIPRequired          = "LoadBalancerFloatingIPRequired"
 	eventLBSourceRangesIgnored         = "LoadBalancerSourceRangesIgnored"
 	eventLBAZIgnored                   = "LoadBalancerAvailabilityZonesIgnored"
+	eventLBAdditionalVIPsIgnored       = "LoadBalancerAdditionalVIPsIgnored"
+	eventLBListenerTLSIgnored          = "LoadBalancerListenerTLSIgnored"
+	eventLBClientAuthIgnored           = "LoadBalancerClientAuthenticationIgnored"
+	eventLBClientAuthLeftEnforced      = "LoadBalancerClientAuthenticationLeftEnforced"
 	eventLBFloatingIPSkipped           = "LoadBalancerFloatingIPSkipped"
 	eventLBRename                      = "LoadBalancerRename"
 	eventLBLbMethodUnknown             = "LoadBalancerLbMethodUnknown"
+	eventLBTLSCertificateRotated       = "LoadBalancerTLSCertificateRotated"
+	eventLBDryRun                      = "LoadBalancerDryRun"
+	eventLBInTreeMigrated              = "LoadBalancerInTreeMigrated"
+	eventLBDestructiveChangesBlocked   = "LoadBalancerDestructiveChangesBlocked"
+	eventLBDestructiveChangesApplied   = "LoadBalancerDestructiveChangesApplied"
+	eventLBMemberAddressesRefreshed    = "LoadBalancerMemberAddressesRefreshed"
+	eventLBPaused                      = "LoadBalancerPaused"
+	eventLBAddressScopeMismatch        = "LoadBalancerAddressScopeMismatch"
+
+	// The events of the load balancer class controller are the ones of the service controller.
+	eventLBEnsuring   = "EnsuringLoadBalancer"
+	eventLBEnsured    = "EnsuredLoadBalancer"
+	eventLBSyncFailed = "SyncLoadBalancerFailed"
+	eventLBDeleting   = "DeletingLoadBalancer"
+	eventLBDeleted    = "DeletedLoadBalancer"
+
+	eventApplicationCredentialExpiring = "ApplicationCredentialExpiring"
 )
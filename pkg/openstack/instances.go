@@ -21,7 +21,9 @@ import (
 	"fmt"
 	sysos "os"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/flavors"
@@ -36,6 +38,7 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/metrics"
 	"k8s.io/cloud-provider-openstack/pkg/util"
 	"k8s.io/cloud-provider-openstack/pkg/util/errors"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/klog/v2"
 )
 
@@ -51,6 +54,10 @@ type InstancesV2 struct {
 	region           string
 	regionProviderID bool
 	networkingOpts   NetworkingOpts
+	instancesOpts    InstancesOpts
+	// metadataSearchOrder is the search order of the local instance metadata, used when the instance of the node
+	// running OCCM can't be retrieved from Nova.
+	metadataSearchOrder string
 }
 
 // InstancesV2 returns an implementation of InstancesV2 for OpenStack.
@@ -75,11 +82,13 @@ func (os *OpenStack) InstancesV2() (cloudprovider.InstancesV2, bool) {
 	}
 
 	return &InstancesV2{
-		compute:          compute,
-		network:          network,
-		region:           os.epOpts.Region,
-		regionProviderID: regionalProviderID,
-		networkingOpts:   os.networkingOpts,
+		compute:             compute,
+		network:             network,
+		region:              os.epOpts.Region,
+		regionProviderID:    regionalProviderID,
+		networkingOpts:      os.networkingOpts,
+		instancesOpts:       os.instancesOpts,
+		metadataSearchOrder: os.metadataOpts.SearchOrder,
 	}, true
 }
 
@@ -98,25 +107,51 @@ func (i *InstancesV2) InstanceExists(ctx context.Context, node *v1.Node) (bool,
 	return true, nil
 }
 
-// InstanceShutdown returns true if the instance is shutdown according to the cloud provider.
+// InstanceShutdown returns true if the instance is shutdown according to the cloud provider. The node lifecycle
+// controller then taints the node with node.cloudprovider.kubernetes.io/shutdown instead of deleting it.
 func (i *InstancesV2) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
 	server, err := i.getInstance(ctx, node)
 	if err != nil {
 		return false, err
 	}
 
-	// SHUTOFF is the only state where we can detach volumes immediately
-	if server.Status == instanceShutoff {
-		return true, nil
+	shutdown := isServerShutdown(server, i.instancesOpts, time.Now())
+	if shutdown {
+		klog.V(4).Infof("instance %s of node %s is shut down, status: %s", server.ID, node.Name, server.Status)
+	}
+
+	return shutdown, nil
+}
+
+// isServerShutdown returns true if the server has been in one of the shutdown states, SHUTOFF by default, for the
+// grace period. The last update of the server is taken as the time of the state change.
+func isServerShutdown(server *servers.Server, opts InstancesOpts, now time.Time) bool {
+	states := opts.ShutdownStates
+	if len(states) == 0 {
+		// SHUTOFF is the only state where we can detach volumes immediately
+		states = []string{instanceShutoff}
+	}
+	if !slices.ContainsFunc(states, func(state string) bool { return strings.EqualFold(state, server.Status) }) {
+		return false
 	}
 
-	return false, nil
+	return now.Sub(server.Updated) >= opts.ShutdownGracePeriod.Duration
 }
 
 // InstanceMetadata returns the instance's metadata.
 func (i *InstancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
 	srv, err := i.getInstance(ctx, node)
 	if err != nil {
+		if err != cloudprovider.InstanceNotFound && !errors.IsNotFound(err) {
+			if md := i.readLocalMetadata(); md != nil {
+				// Only the node running OCCM is described by the local metadata, the other nodes wait for Nova
+				if !isLocalInstance(node, md) {
+					return nil, fmt.Errorf("%w, the local instance metadata only describes the node running OCCM", err)
+				}
+				klog.Warningf("Failed to get the instance of node %s, using the local instance metadata: %v", node.Name, err)
+				return i.localInstanceMetadata(md), nil
+			}
+		}
 		return nil, err
 	}
 	var server servers.Server
@@ -150,6 +185,44 @@ func (i *InstancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*clo
 	}, nil
 }
 
+// readLocalMetadata returns the metadata of the instance running OCCM from the config drive or the metadata service,
+// following the [Metadata] search-order. It returns nil if the metadata is not available.
+func (i *InstancesV2) readLocalMetadata() *metadata.Metadata {
+	if i.metadataSearchOrder == "" {
+		return nil
+	}
+
+	md, err := metadata.Get(i.metadataSearchOrder)
+	if err != nil {
+		klog.V(4).Infof("Local instance metadata is not available: %v", err)
+		return nil
+	}
+	return md
+}
+
+// localInstanceMetadata returns the instance metadata of the node running OCCM from its local metadata, so that the
+// node can be initialized when Nova is unreachable. The node addresses are not known, they are kept as reported by
+// the kubelet.
+func (i *InstancesV2) localInstanceMetadata(md *metadata.Metadata) *cloudprovider.InstanceMetadata {
+	return &cloudprovider.InstanceMetadata{
+		ProviderID:   i.makeInstanceID(&servers.Server{ID: md.UUID}),
+		InstanceType: md.InstanceType,
+		Zone:         util.SanitizeLabel(md.AvailabilityZone),
+		Region:       i.region,
+	}
+}
+
+// isLocalInstance returns true if the node is the instance described by the metadata, by provider ID or else by name.
+func isLocalInstance(node *v1.Node, md *metadata.Metadata) bool {
+	if node.Spec.ProviderID != "" {
+		instanceID, _, err := instanceIDFromProviderID(node.Spec.ProviderID)
+		return err == nil && instanceID == md.UUID
+	}
+
+	hostname, _, _ := strings.Cut(md.Hostname, ".")
+	return node.Name == md.Name || node.Name == md.Hostname || node.Name == hostname
+}
+
 func (i *InstancesV2) makeInstanceID(srv *servers.Server) string {
 	if i.regionProviderID {
 		return fmt.Sprintf("%s://%s/%s", ProviderName, i.region, srv.ID)
@@ -30,6 +30,7 @@ import (
 	"github.com/mitchellh/mapstructure"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/cloud-provider-openstack/pkg/util"
 	"k8s.io/klog/v2"
 )
@@ -139,6 +140,12 @@ func removeFromNodeAddresses(addresses *[]v1.NodeAddress, removeAddresses ...v1.
 	}
 }
 
+// isInternalSubnet returns true if the fixed IPs of the subnet are internal addresses of the nodes, all the subnets
+// are when internal-subnet-id is not set.
+func isInternalSubnet(networkingOpts NetworkingOpts, subnetID string) bool {
+	return len(networkingOpts.InternalSubnetID) == 0 || slices.Contains(networkingOpts.InternalSubnetID, subnetID)
+}
+
 // IP addresses order:
 // * interfaces private IPs
 // * access IPs
@@ -146,13 +153,22 @@ func removeFromNodeAddresses(addresses *[]v1.NodeAddress, removeAddresses ...v1.
 // * server object Addresses (floating type)
 func nodeAddresses(ctx context.Context, srv *servers.Server, ports []PortWithTrunkDetails, client *gophercloud.ServiceClient, networkingOpts NetworkingOpts) ([]v1.NodeAddress, error) {
 	addrs := []v1.NodeAddress{}
+	// The fixed IPs of the subnets excluded by internal-subnet-id, Nova lists them in the server addresses too. They
+	// are only excluded from the InternalIP addresses, the fixed IPs of the public networks are still ExternalIP.
+	excludedAddrs := sets.New[string]()
 
-	// parse private IP addresses first in an ordered manner
+	// parse private IP addresses first in an ordered manner, both the IPv4 and the IPv6 fixed IPs of the ports are
+	// reported for dual-stack nodes
 	for _, port := range ports {
 		for _, fixedIP := range port.FixedIPs {
 			if port.Status != "ACTIVE" {
 				continue
 			}
+			if !isInternalSubnet(networkingOpts, fixedIP.SubnetID) {
+				klog.V(5).Infof("Node '%s' address '%s' ignored due to 'internal-subnet-id' option", srv.Name, fixedIP.IPAddress)
+				excludedAddrs.Insert(fixedIP.IPAddress)
+				continue
+			}
 			isIPv6 := net.ParseIP(fixedIP.IPAddress).To4() == nil
 			if !(isIPv6 && networkingOpts.IPv6SupportDisabled) {
 				addToNodeAddresses(&addrs,
@@ -221,6 +237,9 @@ func nodeAddresses(ctx context.Context, srv *servers.Server, ports []PortWithTru
 			}
 			for _, fixedIP := range p.FixedIPs {
 				klog.V(5).Infof("Node '%s' is found subport '%s' address '%s/%s'", srv.Name, p.Name, n.Name, fixedIP.IPAddress)
+				if !isInternalSubnet(networkingOpts, fixedIP.SubnetID) {
+					excludedAddrs.Insert(fixedIP.IPAddress)
+				}
 				isIPv6 := net.ParseIP(fixedIP.IPAddress).To4() == nil
 				if !(isIPv6 && networkingOpts.IPv6SupportDisabled) {
 					addr := Address{IPType: "fixed", Addr: fixedIP.IPAddress}
@@ -259,6 +278,10 @@ func nodeAddresses(ctx context.Context, srv *servers.Server, ports []PortWithTru
 					},
 				)
 			} else {
+				if excludedAddrs.Has(props.Addr) {
+					klog.V(5).Infof("Node '%s' address '%s' ignored due to 'internal-subnet-id' option", srv.Name, props.Addr)
+					continue
+				}
 				if len(networkingOpts.InternalNetworkName) == 0 || slices.Contains(networkingOpts.InternalNetworkName, network) {
 					addressType = v1.NodeInternalIP
 				} else {
@@ -17,9 +17,21 @@ limitations under the License.
 package openstack
 
 import (
+	"context"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"k8s.io/cloud-provider-openstack/pkg/util"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 )
 
 func Test_instanceIDFromProviderID(t *testing.T) {
@@ -92,3 +104,143 @@ func Test_instanceIDFromProviderID(t *testing.T) {
 		})
 	}
 }
+
+func TestIsServerShutdown(t *testing.T) {
+	now := time.Now()
+	gracePeriod := InstancesOpts{ShutdownGracePeriod: util.MyDuration{Duration: 5 * time.Minute}}
+
+	tests := []struct {
+		name     string
+		status   string
+		updated  time.Time
+		opts     InstancesOpts
+		expected bool
+	}{
+		{
+			name:     "active server",
+			status:   "ACTIVE",
+			updated:  now,
+			expected: false,
+		},
+		{
+			name:     "shutoff server",
+			status:   "SHUTOFF",
+			updated:  now,
+			expected: true,
+		},
+		{
+			name:     "shutoff server within the grace period",
+			status:   "SHUTOFF",
+			updated:  now.Add(-time.Minute),
+			opts:     gracePeriod,
+			expected: false,
+		},
+		{
+			name:     "shutoff server after the grace period",
+			status:   "SHUTOFF",
+			updated:  now.Add(-10 * time.Minute),
+			opts:     gracePeriod,
+			expected: true,
+		},
+		{
+			name:     "state not configured",
+			status:   "SHUTOFF",
+			updated:  now,
+			opts:     InstancesOpts{ShutdownStates: []string{"SHELVED_OFFLOADED"}},
+			expected: false,
+		},
+		{
+			name:     "configured state",
+			status:   "SHELVED_OFFLOADED",
+			updated:  now,
+			opts:     InstancesOpts{ShutdownStates: []string{"shelved_offloaded", "SHUTOFF"}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &servers.Server{Status: tt.status, Updated: tt.updated}
+			assert.Equal(t, tt.expected, isServerShutdown(server, tt.opts, now))
+		})
+	}
+}
+
+func TestInstanceMetadataLocalFallback(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	for _, id := range []string{"unreachable", "other"} {
+		th.Mux.HandleFunc("/servers/"+id, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+	}
+	th.Mux.HandleFunc("/servers/deleted", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	metadata.Set(&metadata.Metadata{
+		UUID:             "unreachable",
+		Name:             "node",
+		Hostname:         "node.novalocal",
+		AvailabilityZone: "zone 1",
+		InstanceType:     "m1.small",
+	})
+	defer metadata.Clear()
+
+	i := &InstancesV2{compute: fakeclient.ServiceClient(), region: "region", metadataSearchOrder: metadata.ConfigDriveID}
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///unreachable"},
+	}
+	md, err := i.InstanceMetadata(context.TODO(), node)
+	assert.NoError(t, err)
+	assert.Equal(t, &cloudprovider.InstanceMetadata{
+		ProviderID:   "openstack:///unreachable",
+		InstanceType: "m1.small",
+		Zone:         "zone-1",
+		Region:       "region",
+	}, md)
+
+	// Another node is not described by the local metadata.
+	node.Spec.ProviderID = "openstack:///other"
+	_, err = i.InstanceMetadata(context.TODO(), node)
+	assert.ErrorContains(t, err, "the local instance metadata only describes the node running OCCM")
+
+	// A deleted instance is not replaced by the local metadata.
+	node.Spec.ProviderID = "openstack:///deleted"
+	_, err = i.InstanceMetadata(context.TODO(), node)
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+
+	// The fallback is disabled without search order.
+	i.metadataSearchOrder = ""
+	node.Spec.ProviderID = "openstack:///unreachable"
+	_, err = i.InstanceMetadata(context.TODO(), node)
+	assert.Error(t, err)
+}
+
+func TestIsLocalInstance(t *testing.T) {
+	md := &metadata.Metadata{UUID: "uuid", Name: "server", Hostname: "host.novalocal"}
+
+	tests := []struct {
+		name       string
+		nodeName   string
+		providerID string
+		expected   bool
+	}{
+		{name: "provider ID", nodeName: "other", providerID: "openstack:///uuid", expected: true},
+		{name: "other provider ID", nodeName: "server", providerID: "openstack://region/other", expected: false},
+		{name: "server name", nodeName: "server", expected: true},
+		{name: "hostname", nodeName: "host.novalocal", expected: true},
+		{name: "short hostname", nodeName: "host", expected: true},
+		{name: "other name", nodeName: "other", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: tt.nodeName}, Spec: v1.NodeSpec{ProviderID: tt.providerID}}
+			assert.Equal(t, tt.expected, isLocalInstance(node, md))
+		})
+	}
+}
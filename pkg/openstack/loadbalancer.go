@@ -17,18 +17,17 @@ limitations under the License.
 package openstack
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/gophercloud/gophercloud/v2"
-	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/containers"
-	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
 	v2monitors "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/monitors"
@@ -36,6 +35,8 @@ import (
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/subnets"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
@@ -80,6 +81,7 @@ const (
 	ServiceAnnotationLoadBalancerXForwardedFor        = "loadbalancer.openstack.org/x-forwarded-for"
 	ServiceAnnotationLoadBalancerFlavorID             = "loadbalancer.openstack.org/flavor-id"
 	ServiceAnnotationLoadBalancerAvailabilityZone     = "loadbalancer.openstack.org/availability-zone"
+	ServiceAnnotationLoadBalancerAdditionalVIPs       = "loadbalancer.openstack.org/additional-vips"
 	// ServiceAnnotationLoadBalancerEnableHealthMonitor defines whether to create health monitor for the load balancer
 	// pool, if not specified, use 'create-monitor' config. The health monitor can be created or deleted dynamically.
 	ServiceAnnotationLoadBalancerEnableHealthMonitor         = "loadbalancer.openstack.org/enable-health-monitor"
@@ -89,6 +91,62 @@ const (
 	ServiceAnnotationLoadBalancerHealthMonitorMaxRetriesDown = "loadbalancer.openstack.org/health-monitor-max-retries-down"
 	ServiceAnnotationLoadBalancerLoadbalancerHostname        = "loadbalancer.openstack.org/hostname"
 	ServiceAnnotationLoadBalancerAddress                     = "loadbalancer.openstack.org/load-balancer-address"
+	// ServiceAnnotationLoadBalancerHealthMonitorType forces the type of the health monitors, HTTP or TCP. By default,
+	// the health monitors are HTTP ones checking the healthCheckNodePort of the Services with the Local traffic policy.
+	ServiceAnnotationLoadBalancerHealthMonitorType = "loadbalancer.openstack.org/health-monitor-type"
+	// ServiceAnnotationLoadBalancerHealthMonitorHTTPPath is the URL path requested by the HTTP health monitors.
+	ServiceAnnotationLoadBalancerHealthMonitorHTTPPath = "loadbalancer.openstack.org/health-monitor-http-path"
+	// ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod is the HTTP method of the requests of the HTTP health monitors.
+	ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod = "loadbalancer.openstack.org/health-monitor-http-method"
+	// ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes is the list or range of the HTTP status codes of the
+	// healthy members, e.g. "200,202" or "200-299".
+	ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes = "loadbalancer.openstack.org/health-monitor-expected-codes"
+	// ServiceAnnotationLoadBalancerAdminStateUp defines the administrative state of the load balancer and its listeners,
+	// it allows to provision a load balancer that doesn't serve traffic until the annotation is set to "true".
+	ServiceAnnotationLoadBalancerAdminStateUp = "loadbalancer.openstack.org/admin-state-up"
+	// ServiceAnnotationLoadBalancerIncludeControlPlaneNodes defines whether the control-plane nodes, which are excluded
+	// from the load balancers by Kubernetes, are used as members of the load balancer.
+	ServiceAnnotationLoadBalancerIncludeControlPlaneNodes = "loadbalancer.openstack.org/include-control-plane-nodes"
+	// ServiceAnnotationLoadBalancerSNIContainerRefs is the comma-separated list of the tls containers or secrets served
+	// by the TERMINATED_HTTPS listeners according to the SNI hostname, in addition to the default-tls-container-ref.
+	ServiceAnnotationLoadBalancerSNIContainerRefs = "loadbalancer.openstack.org/sni-container-refs"
+	// ServiceAnnotationLoadBalancerEndpointMembers defines whether the members of the pools are the ready endpoints of the
+	// Service, at the port their EndpointSlice resolves the target port to, instead of the nodes at the NodePort.
+	ServiceAnnotationLoadBalancerEndpointMembers = "loadbalancer.openstack.org/endpoint-members"
+	// ServiceAnnotationLoadBalancerMemberAddressCIDRs is the comma-separated list of CIDRs the member addresses are
+	// selected in, for the nodes with several addresses.
+	ServiceAnnotationLoadBalancerMemberAddressCIDRs = "loadbalancer.openstack.org/member-address-cidrs"
+	// ServiceAnnotationLoadBalancerMemberNetworkID is the ID of the network whose port addresses are used as member
+	// addresses, for the nodes with several NICs.
+	ServiceAnnotationLoadBalancerMemberNetworkID = "loadbalancer.openstack.org/member-network-id"
+	// ServiceAnnotationLoadBalancerMemberDNSDomain is the DNS domain the node names are resolved in to get the member
+	// addresses, for the nodes whose addresses aren't stable.
+	ServiceAnnotationLoadBalancerMemberDNSDomain = "loadbalancer.openstack.org/member-dns-domain"
+	// ServiceAnnotationLoadBalancerTLSCiphers is the colon-separated list of the OpenSSL ciphers of the TERMINATED_HTTPS
+	// listeners, the default ciphers of Octavia are used when it's not set.
+	ServiceAnnotationLoadBalancerTLSCiphers = "loadbalancer.openstack.org/tls-ciphers"
+	// ServiceAnnotationLoadBalancerTLSVersions is the comma-separated list of the TLS versions of the TERMINATED_HTTPS
+	// listeners, e.g. "TLSv1.2,TLSv1.3", the default versions of Octavia are used when it's not set.
+	ServiceAnnotationLoadBalancerTLSVersions = "loadbalancer.openstack.org/tls-versions"
+	// ServiceAnnotationLoadBalancerTags is the comma-separated list of tags added to the load balancer, along with the
+	// tags identifying the Services using it, e.g. for the cost allocation.
+	ServiceAnnotationLoadBalancerTags = "loadbalancer.openstack.org/tags"
+	// ServiceAnnotationLoadBalancerRequireFloatingIP fails the external load balancers no floating network is found for,
+	// instead of falling back to internal load balancers.
+	ServiceAnnotationLoadBalancerRequireFloatingIP = "loadbalancer.openstack.org/require-floating-ip"
+	// ServiceAnnotationLoadBalancerClientAuthentication is the TLS client authentication mode of the TERMINATED_HTTPS
+	// listeners, NONE, OPTIONAL or MANDATORY.
+	ServiceAnnotationLoadBalancerClientAuthentication = "loadbalancer.openstack.org/client-authentication"
+	// ServiceAnnotationLoadBalancerClientCATLSContainerRef is the Barbican secret of the CA certificates the client
+	// certificates are verified with.
+	ServiceAnnotationLoadBalancerClientCATLSContainerRef = "loadbalancer.openstack.org/client-ca-tls-container-ref"
+	// ServiceAnnotationLoadBalancerClientCRLContainerRef is the Barbican secret of the revocation list of the client
+	// certificates.
+	ServiceAnnotationLoadBalancerClientCRLContainerRef = "loadbalancer.openstack.org/client-crl-container-ref"
+
+	// Labels of the control-plane nodes
+	labelNodeRoleControlPlane = "node-role.kubernetes.io/control-plane"
+	labelNodeRoleMaster       = "node-role.kubernetes.io/master"
 	// revive:disable:var-naming
 	ServiceAnnotationTlsContainerRef = "loadbalancer.openstack.org/default-tls-container-ref"
 	// revive:enable:var-naming
@@ -136,23 +194,73 @@ type serviceConfig struct {
 	enableMonitor               bool
 	flavorID                    string
 	availabilityZone            string
+	additionalVIPs              []loadbalancers.AdditionalVip
 	tlsContainerRef             string
+	tlsFingerprint              string // fingerprint of the Barbican container or secret, empty when not checked
+	sniContainerRefs            []string
+	tlsCiphers                  string                 // empty to use the default ciphers of Octavia
+	tlsVersions                 []listeners.TLSVersion // nil to use the default versions of Octavia
+	clientCATLSContainerRef     string
+	clientCRLContainerRef       string
+	clientAuthentication        listeners.ClientAuthentication // empty when the client authentication is not managed
 	lbID                        string
 	lbName                      string
 	supportLBTags               bool
+	lbTags                      []string // tags of the load balancer requested by the Service
 	healthCheckNodePort         int
 	healthMonitorDelay          int
 	healthMonitorTimeout        int
 	healthMonitorMaxRetries     int
 	healthMonitorMaxRetriesDown int
-	preferredIPFamily           corev1.IPFamily // preferred (the first) IP family indicated in service's `spec.ipFamilies`
+	healthMonitorType           string // empty for the default type
+	healthMonitorHTTPPath       string
+	healthMonitorHTTPMethod     string
+	healthMonitorExpectedCodes  string
+	includeControlPlaneNodes    bool
+	externalFloatingIP          bool             // the floating IP is attached by an external controller
+	adminStateUp                *bool            // nil when the administrative state is not managed
+	preferredIPFamily           corev1.IPFamily  // preferred (the first) IP family indicated in service's `spec.ipFamilies`
+	localEndpointNodes          sets.Set[string] // nodes with a ready endpoint, nil when the member weights are not managed
+	endpointMembers             bool             // the members are the ready endpoints of endpointSlices instead of the nodes
+	endpointSlices              []*discoveryv1.EndpointSlice
+	memberAddressCIDRs          []*net.IPNet      // the member addresses are selected in these CIDRs, nil to use the first address of the nodes
+	memberNodeAddresses         map[string]string // the member addresses on the member network by node name, nil when not set
+	savedJournal                *reconcileJournal // the reconcile journal saved on the Service, see persistReconcileStep
 }
 
+// listenerKey identifies a listener by its protocol and port, so that a Service using
+// the same port number for different protocols (MixedProtocolLBService) gets one
+// listener per protocol.
 type listenerKey struct {
 	Protocol listeners.Protocol
 	Port     int
 }
 
+// getListenerKey returns the key of the listener serving the given Service port.
+func getListenerKey(port corev1.ServicePort, svcConf *serviceConfig) listenerKey {
+	return listenerKey{
+		Protocol: getListenerProtocol(port.Protocol, svcConf),
+		Port:     int(port.Port),
+	}
+}
+
+// getListenerMapping returns the given listeners indexed by their protocol and port.
+func getListenerMapping(listenerList []listeners.Listener) map[listenerKey]*listeners.Listener {
+	mapping := make(map[listenerKey]*listeners.Listener, len(listenerList))
+	for i, l := range listenerList {
+		key := listenerKey{Protocol: listeners.Protocol(l.Protocol), Port: l.ProtocolPort}
+		mapping[key] = &listenerList[i]
+	}
+	return mapping
+}
+
+// isL7CapableProtocol returns true if HTTP based listeners and pools can be used
+// for the given Service port protocol. Only TCP ports can be upgraded to HTTP or
+// TERMINATED_HTTPS, UDP and SCTP ports always keep their own protocol.
+func isL7CapableProtocol(protocol corev1.Protocol) bool {
+	return protocol != corev1.ProtocolUDP && protocol != corev1.ProtocolSCTP
+}
+
 // getLoadbalancerByName get the load balancer which is in valid status by the given name/legacy name.
 func getLoadbalancerByName(client *gophercloud.ServiceClient, name string, legacyName string) (*loadbalancers.LoadBalancer, error) {
 	var validLBs []loadbalancers.LoadBalancer
@@ -209,7 +317,7 @@ func popListener(existingListeners []listeners.Listener, id string) []listeners.
 
 func getListenerProtocol(protocol corev1.Protocol, svcConf *serviceConfig) listeners.Protocol {
 	// Make neutron-lbaas code work
-	if svcConf != nil {
+	if svcConf != nil && isL7CapableProtocol(protocol) {
 		if svcConf.tlsContainerRef != "" {
 			return listeners.ProtocolTerminatedHTTPS
 		} else if svcConf.keepClientIP {
@@ -235,7 +343,7 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 	}
 
 	if svcConf.supportLBTags {
-		createOpts.Tags = []string{svcConf.lbName}
+		createOpts.Tags = append([]string{svcConf.lbName}, svcConf.lbTags...)
 	}
 
 	if svcConf.flavorID != "" {
@@ -246,6 +354,14 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 		createOpts.AvailabilityZone = svcConf.availabilityZone
 	}
 
+	if svcConf.adminStateUp != nil {
+		createOpts.AdminStateUp = svcConf.adminStateUp
+	}
+
+	if len(svcConf.additionalVIPs) > 0 {
+		createOpts.AdditionalVips = svcConf.additionalVIPs
+	}
+
 	vipPort := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerPortID, "")
 	lbClass := lbaas.opts.LBClasses[svcConf.configClassName]
 
@@ -267,6 +383,10 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 		}
 	}
 
+	if err := lbaas.validateLoadBalancerSubnets(ctx, service, createOpts.VipSubnetID, svcConf.lbMemberSubnetID); err != nil {
+		return nil, err
+	}
+
 	// For external load balancer, the LoadBalancerIP is a public IP address.
 	loadBalancerIP := service.Spec.LoadBalancerIP
 	if loadBalancerIP != "" {
@@ -298,6 +418,11 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 		}
 	}
 
+	// The planned load balancer has no ID, it would be created with its listeners and pools
+	if lbaas.plan.planned(planActionCreate, "loadbalancer", name, describeOpts(createOpts)) {
+		return &loadbalancers.LoadBalancer{Name: name}, nil
+	}
+
 	mc := metrics.NewMetricContext("loadbalancer", "create")
 	loadbalancer, err := loadbalancers.Create(ctx, lbaas.lb, createOpts).Extract()
 	if mc.ObserveRequest(err) != nil {
@@ -308,6 +433,9 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 		return nil, fmt.Errorf("error creating loadbalancer %v: %v", printObj, err)
 	}
 
+	// Save the load balancer ID before waiting for it, the name lookup cannot be relied on to find it again.
+	lbaas.persistReconcileStep(ctx, service, svcConf, journalStepLoadBalancerCreated, loadbalancer)
+
 	// In case subnet ID is not configured
 	if svcConf.lbMemberSubnetID == "" {
 		svcConf.lbMemberSubnetID = loadbalancer.VipSubnetID
@@ -330,6 +458,12 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(ctx context.Context, name, clust
 
 // GetLoadBalancer returns whether the specified load balancer exists and its status
 func (lbaas *LbaasV2) GetLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service) (*corev1.LoadBalancerStatus, bool, error) {
+	if !lbaas.managesService(service) {
+		return nil, false, nil
+	}
+	if isAttached(service) {
+		return lbaas.getAttachedLoadBalancer(ctx, service)
+	}
 	name := lbaas.GetLoadBalancerName(ctx, clusterName, service)
 	legacyName := lbaas.getLoadBalancerLegacyName(service)
 	lbID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "")
@@ -388,22 +522,10 @@ func nodeAddressForLB(node *corev1.Node, preferredIPFamily corev1.IPFamily) (str
 		return "", cpoerrors.ErrNoAddressFound
 	}
 
-	allowedAddrTypes := []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP}
-	for _, allowedAddrType := range allowedAddrTypes {
+	for _, allowedAddrType := range nodeAddressTypesForLB {
 		for _, addr := range addrs {
-			if addr.Type == allowedAddrType {
-				switch preferredIPFamily {
-				case corev1.IPv4Protocol:
-					if netutils.IsIPv4String(addr.Address) {
-						return addr.Address, nil
-					}
-				case corev1.IPv6Protocol:
-					if netutils.IsIPv6String(addr.Address) {
-						return addr.Address, nil
-					}
-				default:
-					return addr.Address, nil
-				}
+			if addr.Type == allowedAddrType && addressInIPFamily(addr.Address, preferredIPFamily) {
+				return addr.Address, nil
 			}
 		}
 	}
@@ -411,6 +533,21 @@ func nodeAddressForLB(node *corev1.Node, preferredIPFamily corev1.IPFamily) (str
 	return "", cpoerrors.ErrNoAddressFound
 }
 
+// nodeAddressTypesForLB are the types of the node addresses used as members, by order of preference.
+var nodeAddressTypesForLB = []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP}
+
+// addressInIPFamily returns true if the address is of the IP family, any address is in the empty IP family.
+func addressInIPFamily(address string, ipFamily corev1.IPFamily) bool {
+	switch ipFamily {
+	case corev1.IPv4Protocol:
+		return netutils.IsIPv4String(address)
+	case corev1.IPv6Protocol:
+		return netutils.IsIPv6String(address)
+	default:
+		return true
+	}
+}
+
 // getKeyValueFromServiceAnnotation converts a comma-separated list of key-value
 // pairs from the specified annotation into a map or returns the specified
 // defaultSetting if the annotation is empty
@@ -492,8 +629,8 @@ func getProxyProtocolFromServiceAnnotation(service *corev1.Service) *v2pools.Pro
 }
 
 // getSubnetIDForLB returns subnet-id for a specific node
-func getSubnetIDForLB(ctx context.Context, network *gophercloud.ServiceClient, node corev1.Node, preferredIPFamily corev1.IPFamily) (string, error) {
-	ipAddress, err := nodeAddressForLB(&node, preferredIPFamily)
+func getSubnetIDForLB(ctx context.Context, network *gophercloud.ServiceClient, node corev1.Node, svcConf *serviceConfig) (string, error) {
+	ipAddress, err := memberAddressForLB(&node, svcConf)
 	if err != nil {
 		return "", err
 	}
@@ -532,6 +669,9 @@ func isPortMember(port PortWithPortSecurity, IP string, subnetID string) bool {
 // deleteListeners deletes listeners and its default pool.
 func (lbaas *LbaasV2) deleteListeners(lbID string, listenerList []listeners.Listener) error {
 	for _, listener := range listenerList {
+		if lbaas.plan.planned(planActionDelete, "listener", listener.ID, fmt.Sprintf("port %d, with its pool", listener.ProtocolPort)) {
+			continue
+		}
 		klog.InfoS("Deleting listener", "listenerID", listener.ID, "lbID", lbID)
 
 		pool, err := openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
@@ -561,6 +701,9 @@ func (lbaas *LbaasV2) deleteOctaviaListeners(lbID string, listenerList []listene
 	for _, listener := range listenerList {
 		// If the listener was created by this Service before or after supporting shared LB.
 		if (isLBOwner && len(listener.Tags) == 0) || slices.Contains(listener.Tags, lbName) {
+			if lbaas.plan.planned(planActionDelete, "listener", listener.ID, fmt.Sprintf("port %d, with its pool", listener.ProtocolPort)) {
+				continue
+			}
 			klog.InfoS("Deleting listener", "listenerID", listener.ID, "lbID", lbID)
 
 			pool, err := openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
@@ -593,6 +736,9 @@ func (lbaas *LbaasV2) deleteOctaviaListeners(lbID string, listenerList []listene
 }
 
 func (lbaas *LbaasV2) createFloatingIP(ctx context.Context, msg string, floatIPOpts floatingips.CreateOpts) (*floatingips.FloatingIP, error) {
+	if lbaas.plan.planned(planActionCreate, "floatingip", floatIPOpts.FloatingIP, describeOpts(floatIPOpts)) {
+		return &floatingips.FloatingIP{FloatingIP: floatIPOpts.FloatingIP, PortID: floatIPOpts.PortID}, nil
+	}
 	klog.V(4).Infof("%s floating ip with opts %+v", msg, floatIPOpts)
 	mc := metrics.NewMetricContext("floating_ip", "create")
 	floatIP, err := floatingips.Create(ctx, lbaas.network, floatIPOpts).Extract()
@@ -607,6 +753,11 @@ func (lbaas *LbaasV2) updateFloatingIP(ctx context.Context, floatingip *floating
 	floatUpdateOpts := floatingips.UpdateOpts{
 		PortID: portID,
 	}
+	if lbaas.plan.planned(planActionUpdate, "floatingip", floatingip.FloatingIP, describeOpts(floatUpdateOpts)) {
+		updated := *floatingip
+		updated.PortID = ptr.Deref(portID, "")
+		return &updated, nil
+	}
 	if portID != nil {
 		klog.V(4).Infof("Attaching floating ip %q to loadbalancer port %q", floatingip.FloatingIP, *portID)
 	} else {
@@ -621,22 +772,22 @@ func (lbaas *LbaasV2) updateFloatingIP(ctx context.Context, floatingip *floating
 }
 
 // ensureFloatingIP manages a FIP for a Service and returns the address that should be advertised in the
-// .Status.LoadBalancer. In particular it will:
-//  1. Lookup if any FIP is already attached to the VIP port of the LB.
-//     a) If it is and Service is internal, it will attempt to detach the FIP and delete it if it was created
-//     by cloud provider. This is to support cases of changing the internal annotation.
-//     b) If the Service is not the owner of the LB it will not contiue to prevent accidental exposure of the
+// .Status.LoadBalancer. The FIP attached to the VIP port of the LB and the internal annotation of the Service decide of
+// the transition to apply, see getFloatingIPTransition. In particular it will:
+//  1. Keep the FIP already attached to the VIP port of an external Service.
+//  2. Detach the FIP from the VIP port of an internal Service, and delete it if it was created by the cloud provider and
+//     the Service doesn't ask to keep it. This is to support cases of changing the internal annotation.
+//  3. Attach a FIP to the VIP port of an external Service:
+//     a) If the Service is not the owner of the LB it will not contiue to prevent accidental exposure of the
 //     possible internal Services already existing on that LB.
-//     c) If it's external Service, it will use that existing FIP.
-//  2. Lookup FIP specified in Spec.LoadBalancerIP and try to assign it to the LB VIP port.
-//  3. Try to create and assign a new FIP:
-//     a) If Spec.LoadBalancerIP is not set, just create a random FIP in the external network and use that.
-//     b) If Spec.LoadBalancerIP is specified, try to create a FIP with that address. By default this is not allowed by
-//     the Neutron policy for regular users!
-func (lbaas *LbaasV2) ensureFloatingIP(ctx context.Context, clusterName string, service *corev1.Service, lb *loadbalancers.LoadBalancer, svcConf *serviceConfig, isLBOwner bool) (string, error) {
+//     b) Lookup FIP specified in Spec.LoadBalancerIP and try to assign it to the LB VIP port.
+//     c) Lookup the FIP created for the Service and detached when it became internal, and reassign it.
+//     d) Try to create and assign a new FIP. If Spec.LoadBalancerIP is specified, try to create a FIP with that address.
+//     By default this is not allowed by the Neutron policy for regular users!
+func (lbaas *LbaasV2) ensureFloatingIP(ctx context.Context, clusterName string, service *corev1.Service, lb *loadbalancers.LoadBalancer, svcConf *serviceConfig, isLBOwner bool) (addr string, err error) {
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 
-	// We need to fetch the FIP attached to load balancer's VIP port for both codepaths
+	// We need to fetch the FIP attached to load balancer's VIP port for all the transitions
 	portID := lb.VipPortID
 	floatIP, err := openstackutil.GetFloatingIPByPortID(ctx, lbaas.network, portID)
 	if err != nil {
@@ -647,127 +798,157 @@ func (lbaas *LbaasV2) ensureFloatingIP(ctx context.Context, clusterName string,
 		klog.V(4).Infof("Found floating ip %v by loadbalancer port id %q", floatIP, portID)
 	}
 
-	if svcConf.internal && isLBOwner {
-		// if we found a FIP, this is an internal service and we are the owner we should attempt to delete it
+	// The planned reconciliation of a dry-run or paused Service keeps reporting the current address
+	if lbaas.plan != nil {
+		current := lb.VipAddress
 		if floatIP != nil {
-			keepFloatingAnnotation := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerKeepFloatingIP, false)
-			fipDeleted := false
-			if !keepFloatingAnnotation {
-				klog.V(4).Infof("Deleting floating IP %v attached to loadbalancer port id %q for internal service %s", floatIP, portID, serviceName)
-				fipDeleted, err = lbaas.deleteFIPIfCreatedByProvider(ctx, floatIP, portID, service)
-				if err != nil {
-					return "", err
-				}
-			}
-			if !fipDeleted {
-				// if FIP wasn't deleted (because of keep-floatingip annotation or not being created by us) we should still detach it
-				_, err = lbaas.updateFloatingIP(ctx, floatIP, nil)
-				if err != nil {
-					return "", err
-				}
-			}
+			current = floatIP.FloatingIP
+		}
+		defer func() { addr = current }()
+	}
+
+	keepFloatingIP := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerKeepFloatingIP, false)
+	transition := getFloatingIPTransition(floatIP, svcConf.internal, keepFloatingIP)
+	klog.V(4).InfoS("Ensuring floating IP", "service", klog.KObj(service), "lbID", lb.ID, "internal", svcConf.internal, "transition", transition)
+
+	switch transition {
+	case fipDelete:
+		klog.V(4).Infof("Deleting floating IP %v attached to loadbalancer port id %q for internal service %s", floatIP, portID, serviceName)
+		if _, err := lbaas.deleteFIPIfCreatedByProvider(ctx, floatIP, portID, service); err != nil {
+			return "", err
+		}
+		return lb.VipAddress, nil
+	case fipDetach:
+		// the FIP is kept because of keep-floatingip annotation or not being created by us
+		if _, err := lbaas.updateFloatingIP(ctx, floatIP, nil); err != nil {
+			return "", err
 		}
 		return lb.VipAddress, nil
+	case fipAttach:
+		floatIP, err = lbaas.attachFloatingIP(ctx, clusterName, service, lb, svcConf, isLBOwner)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	// first attempt: if we've found a FIP attached to LBs VIP port, we'll be using that.
+	if floatIP != nil {
+		return floatIP.FloatingIP, nil
+	}
+
+	return lb.VipAddress, nil
+}
+
+// attachFloatingIP attaches a FIP to the VIP port of the LB of an external Service. It returns nil when no floating
+// network is configured, the Service is then forced internal.
+func (lbaas *LbaasV2) attachFloatingIP(ctx context.Context, clusterName string, service *corev1.Service, lb *loadbalancers.LoadBalancer, svcConf *serviceConfig, isLBOwner bool) (*floatingips.FloatingIP, error) {
+	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+	portID := lb.VipPortID
 
 	// we cannot add a FIP to a shared LB when we're a secondary Service or we risk adding it to an internal
 	// Service and exposing it to the world unintentionally.
-	if floatIP == nil && !isLBOwner {
-		return "", fmt.Errorf("cannot attach a floating IP to a load balancer for a shared Service %s/%s, only owner Service can do that",
+	if !isLBOwner {
+		return nil, fmt.Errorf("cannot attach a floating IP to a load balancer for a shared Service %s/%s, only owner Service can do that",
 			service.Namespace, service.Name)
 	}
 
-	// second attempt: fetch floating IP specified in service Spec.LoadBalancerIP
+	// first attempt: fetch floating IP specified in service Spec.LoadBalancerIP
 	// if found, associate floating IP with loadbalancer's VIP port
 	loadBalancerIP := service.Spec.LoadBalancerIP
-	if floatIP == nil && loadBalancerIP != "" {
+	if loadBalancerIP != "" {
 		opts := floatingips.ListOpts{
 			FloatingIP: loadBalancerIP,
 		}
 		existingIPs, err := openstackutil.GetFloatingIPs(ctx, lbaas.network, opts)
 		if err != nil {
-			return "", fmt.Errorf("failed when trying to get existing floating IP %s, error: %v", loadBalancerIP, err)
+			return nil, fmt.Errorf("failed when trying to get existing floating IP %s, error: %v", loadBalancerIP, err)
 		}
 		klog.V(4).Infof("Found floating ips %v by loadbalancer ip %q", existingIPs, loadBalancerIP)
 
 		if len(existingIPs) > 0 {
 			floatingip := existingIPs[0]
-			if len(floatingip.PortID) == 0 {
-				floatIP, err = lbaas.updateFloatingIP(ctx, &floatingip, &portID)
-				if err != nil {
-					return "", err
-				}
-			} else {
-				return "", fmt.Errorf("floating IP %s is not available", loadBalancerIP)
+			if len(floatingip.PortID) != 0 {
+				return nil, fmt.Errorf("floating IP %s is not available", loadBalancerIP)
 			}
+			return lbaas.updateFloatingIP(ctx, &floatingip, &portID)
 		}
 	}
 
-	// third attempt: create a new floating IP
-	if floatIP == nil {
-		if svcConf.lbPublicNetworkID != "" {
-			klog.V(2).Infof("Creating floating IP %s for loadbalancer %s", loadBalancerIP, lb.ID)
+	if svcConf.lbPublicNetworkID == "" {
+		if getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerRequireFloatingIP, false) {
+			msg := "No floating network found for Service %s, which requires a floating IP"
+			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBFloatingIPRequired, msg, serviceName)
+			return nil, fmt.Errorf(msg, serviceName)
+		}
+		msg := "No floating network found for Service %s, falling back to an internal load balancer, set the %s annotation to fail instead"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBForceInternal, msg, serviceName, ServiceAnnotationLoadBalancerRequireFloatingIP)
+		klog.Warningf(msg, serviceName, ServiceAnnotationLoadBalancerRequireFloatingIP)
+		return nil, nil
+	}
 
-			floatIPOpts := floatingips.CreateOpts{
-				FloatingNetworkID: svcConf.lbPublicNetworkID,
-				PortID:            portID,
-				Description:       fmt.Sprintf("Floating IP for Kubernetes external service %s from cluster %s", serviceName, clusterName),
-			}
+	// second attempt: reattach the FIP kept when the Service became internal
+	if loadBalancerIP == "" {
+		floatIP, err := lbaas.getDetachedFloatingIP(ctx, clusterName, serviceName, svcConf.lbPublicNetworkID)
+		if err != nil {
+			return nil, err
+		}
+		if floatIP != nil {
+			klog.V(2).Infof("Reattaching floating IP %s to loadbalancer %s", floatIP.FloatingIP, lb.ID)
+			return lbaas.updateFloatingIP(ctx, floatIP, &portID)
+		}
+	}
 
-			if loadBalancerIP == "" && svcConf.lbPublicSubnetSpec.matcherConfigured() {
-				var foundSubnet subnets.Subnet
-				// tweak list options for tags
-				foundSubnets, err := svcConf.lbPublicSubnetSpec.listSubnetsForNetwork(ctx, lbaas, svcConf.lbPublicNetworkID)
-				if err != nil {
-					return "", err
-				}
-				if len(foundSubnets) == 0 {
-					return "", fmt.Errorf("no subnet matching %s found for network %s",
-						svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID)
-				}
+	// third attempt: create a new floating IP
+	klog.V(2).Infof("Creating floating IP %s for loadbalancer %s", loadBalancerIP, lb.ID)
 
-				// try to create floating IP in matching subnets (tags already filtered by list options)
-				klog.V(4).Infof("found %d subnets matching %s for network %s", len(foundSubnets),
-					svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID)
-				for _, subnet := range foundSubnets {
-					floatIPOpts.SubnetID = subnet.ID
-					floatIP, err = lbaas.createFloatingIP(ctx, fmt.Sprintf("Trying subnet %s for creating", subnet.Name), floatIPOpts)
-					if err == nil {
-						foundSubnet = subnet
-						break
-					}
-					klog.V(2).Infof("cannot use subnet %s: %v", subnet.Name, err)
-				}
-				if err != nil {
-					return "", fmt.Errorf("no free subnet matching %q found for network %s (last error %v)",
-						svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID, err)
-				}
-				klog.V(2).Infof("Successfully created floating IP %s for loadbalancer %s on subnet %s(%s)", floatIP.FloatingIP, lb.ID, foundSubnet.Name, foundSubnet.ID)
-			} else {
-				if svcConf.lbPublicSubnetSpec != nil {
-					floatIPOpts.SubnetID = svcConf.lbPublicSubnetSpec.subnetID
-				}
-				floatIPOpts.FloatingIP = loadBalancerIP
-				floatIP, err = lbaas.createFloatingIP(ctx, "Creating", floatIPOpts)
-				if err != nil {
-					return "", err
-				}
-				klog.V(2).Infof("Successfully created floating IP %s for loadbalancer %s", floatIP.FloatingIP, lb.ID)
+	floatIPOpts := floatingips.CreateOpts{
+		FloatingNetworkID: svcConf.lbPublicNetworkID,
+		PortID:            portID,
+		Description:       getFloatingIPDescription(clusterName, serviceName),
+	}
+
+	if loadBalancerIP == "" && svcConf.lbPublicSubnetSpec.matcherConfigured() {
+		var foundSubnet subnets.Subnet
+		// tweak list options for tags
+		foundSubnets, err := svcConf.lbPublicSubnetSpec.listSubnetsForNetwork(ctx, lbaas, svcConf.lbPublicNetworkID)
+		if err != nil {
+			return nil, err
+		}
+		if len(foundSubnets) == 0 {
+			return nil, fmt.Errorf("no subnet matching %s found for network %s",
+				svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID)
+		}
+
+		// try to create floating IP in matching subnets (tags already filtered by list options)
+		klog.V(4).Infof("found %d subnets matching %s for network %s", len(foundSubnets),
+			svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID)
+		var floatIP *floatingips.FloatingIP
+		for _, subnet := range foundSubnets {
+			floatIPOpts.SubnetID = subnet.ID
+			floatIP, err = lbaas.createFloatingIP(ctx, fmt.Sprintf("Trying subnet %s for creating", subnet.Name), floatIPOpts)
+			if err == nil {
+				foundSubnet = subnet
+				break
 			}
-		} else {
-			msg := "Floating network configuration not provided for Service %s, forcing to ensure an internal load balancer service"
-			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBForceInternal, msg, serviceName)
-			klog.Warningf(msg, serviceName)
+			klog.V(2).Infof("cannot use subnet %s: %v", subnet.Name, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("no free subnet matching %q found for network %s (last error %v)",
+				svcConf.lbPublicSubnetSpec, svcConf.lbPublicNetworkID, err)
 		}
+		klog.V(2).Infof("Successfully created floating IP %s for loadbalancer %s on subnet %s(%s)", floatIP.FloatingIP, lb.ID, foundSubnet.Name, foundSubnet.ID)
+		return floatIP, nil
 	}
 
-	if floatIP != nil {
-		return floatIP.FloatingIP, nil
+	if svcConf.lbPublicSubnetSpec != nil {
+		floatIPOpts.SubnetID = svcConf.lbPublicSubnetSpec.subnetID
 	}
-
-	return lb.VipAddress, nil
+	floatIPOpts.FloatingIP = loadBalancerIP
+	floatIP, err := lbaas.createFloatingIP(ctx, "Creating", floatIPOpts)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("Successfully created floating IP %s for loadbalancer %s", floatIP.FloatingIP, lb.ID)
+	return floatIP, nil
 }
 
 func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, name string, pool *v2pools.Pool, port corev1.ServicePort, svcConf *serviceConfig) error {
@@ -787,6 +968,9 @@ func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, name string, pool
 
 	// an existing monitor must be deleted
 	if !svcConf.enableMonitor {
+		if lbaas.plan.planned(planActionDelete, "healthmonitor", monitorID, "") {
+			return nil
+		}
 		klog.Infof("Deleting health monitor %s for pool %s", monitorID, pool.ID)
 		return openstackutil.DeleteHealthMonitor(lbaas.lb, monitorID, lbID)
 	}
@@ -801,26 +985,17 @@ func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, name string, pool
 	// recreate health monitor with a new type
 	createOpts := lbaas.buildMonitorCreateOpts(svcConf, port, name)
 	if createOpts.Type != monitor.Type {
-		klog.InfoS("Recreating health monitor for the pool", "pool", pool.ID, "oldMonitor", monitorID)
-		if err := openstackutil.DeleteHealthMonitor(lbaas.lb, monitorID, lbID); err != nil {
-			return err
+		if !lbaas.plan.planned(planActionDelete, "healthmonitor", monitorID, fmt.Sprintf("type %s changes", monitor.Type)) {
+			klog.InfoS("Recreating health monitor for the pool", "pool", pool.ID, "oldMonitor", monitorID)
+			if err := openstackutil.DeleteHealthMonitor(lbaas.lb, monitorID, lbID); err != nil {
+				return err
+			}
 		}
 		return lbaas.createOctaviaHealthMonitor(createOpts, pool.ID, lbID)
 	}
 
 	// update new monitor parameters
-	if name != monitor.Name ||
-		svcConf.healthMonitorDelay != monitor.Delay ||
-		svcConf.healthMonitorTimeout != monitor.Timeout ||
-		svcConf.healthMonitorMaxRetries != monitor.MaxRetries ||
-		svcConf.healthMonitorMaxRetriesDown != monitor.MaxRetriesDown {
-		updateOpts := v2monitors.UpdateOpts{
-			Name:           &name,
-			Delay:          svcConf.healthMonitorDelay,
-			Timeout:        svcConf.healthMonitorTimeout,
-			MaxRetries:     svcConf.healthMonitorMaxRetries,
-			MaxRetriesDown: svcConf.healthMonitorMaxRetriesDown,
-		}
+	if updateOpts, ok := buildMonitorUpdateOpts(monitor, createOpts); ok && !lbaas.plan.planned(planActionUpdate, "healthmonitor", monitorID, describeOpts(updateOpts)) {
 		klog.Infof("Updating health monitor %s updateOpts %+v", monitorID, updateOpts)
 		return openstackutil.UpdateHealthMonitor(lbaas.lb, monitorID, updateOpts, lbID)
 	}
@@ -828,6 +1003,36 @@ func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, name string, pool
 	return nil
 }
 
+// buildMonitorUpdateOpts returns the v2monitors.UpdateOpts bringing the health monitor to the options it would be
+// created with, and whether the health monitor needs to be updated at all.
+func buildMonitorUpdateOpts(monitor *v2monitors.Monitor, opts v2monitors.CreateOpts) (v2monitors.UpdateOpts, bool) {
+	httpChanged := opts.Type == "HTTP" &&
+		(opts.URLPath != monitor.URLPath || opts.HTTPMethod != monitor.HTTPMethod || opts.ExpectedCodes != monitor.ExpectedCodes)
+	if opts.Name == monitor.Name &&
+		opts.Delay == monitor.Delay &&
+		opts.Timeout == monitor.Timeout &&
+		opts.MaxRetries == monitor.MaxRetries &&
+		opts.MaxRetriesDown == monitor.MaxRetriesDown &&
+		!httpChanged {
+		return v2monitors.UpdateOpts{}, false
+	}
+
+	name := opts.Name
+	updateOpts := v2monitors.UpdateOpts{
+		Name:           &name,
+		Delay:          opts.Delay,
+		Timeout:        opts.Timeout,
+		MaxRetries:     opts.MaxRetries,
+		MaxRetriesDown: opts.MaxRetriesDown,
+	}
+	if opts.Type == "HTTP" {
+		updateOpts.URLPath = opts.URLPath
+		updateOpts.HTTPMethod = opts.HTTPMethod
+		updateOpts.ExpectedCodes = opts.ExpectedCodes
+	}
+	return updateOpts, true
+}
+
 func (lbaas *LbaasV2) canUseHTTPMonitor(port corev1.ServicePort) bool {
 	if lbaas.opts.LBProvider == "ovn" {
 		// ovn-octavia-provider doesn't support HTTP monitors at all. We got to avoid creating it with ovn.
@@ -855,11 +1060,11 @@ func (lbaas *LbaasV2) buildMonitorCreateOpts(svcConf *serviceConfig, port corev1
 	if port.Protocol == corev1.ProtocolUDP {
 		opts.Type = "UDP-CONNECT"
 	}
-	if svcConf.healthCheckNodePort > 0 && lbaas.canUseHTTPMonitor(port) {
+	if lbaas.useHTTPMonitor(port, svcConf) {
 		opts.Type = "HTTP"
-		opts.URLPath = "/healthz"
-		opts.HTTPMethod = "GET"
-		opts.ExpectedCodes = "200"
+		opts.URLPath = cmp.Or(svcConf.healthMonitorHTTPPath, defaultMonitorHTTPPath)
+		opts.HTTPMethod = cmp.Or(svcConf.healthMonitorHTTPMethod, defaultMonitorHTTPMethod)
+		opts.ExpectedCodes = cmp.Or(svcConf.healthMonitorExpectedCodes, defaultMonitorExpectedCodes)
 	}
 	return opts
 }
@@ -867,6 +1072,9 @@ func (lbaas *LbaasV2) buildMonitorCreateOpts(svcConf *serviceConfig, port corev1
 func (lbaas *LbaasV2) createOctaviaHealthMonitor(createOpts v2monitors.CreateOpts, poolID, lbID string) error {
 	// populate PoolID, attribute is omitted for consumption of the createOpts for fully populated Loadbalancer
 	createOpts.PoolID = poolID
+	if lbaas.plan.planned(planActionCreate, "healthmonitor", createOpts.Name, describeOpts(createOpts)) {
+		return nil
+	}
 	monitor, err := openstackutil.CreateHealthMonitor(lbaas.lb, createOpts, lbID)
 	if err != nil {
 		return err
@@ -878,48 +1086,46 @@ func (lbaas *LbaasV2) createOctaviaHealthMonitor(createOpts v2monitors.CreateOpt
 
 // Make sure the pool is created for the Service, nodes are added as pool members.
 func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, name string, listener *listeners.Listener, service *corev1.Service, port corev1.ServicePort, nodes []*corev1.Node, svcConf *serviceConfig) (*v2pools.Pool, error) {
-	pool, err := openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
-	if err != nil && err != cpoerrors.ErrNotFound {
-		return nil, fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
+	var pool *v2pools.Pool
+	var err error
+	// A planned listener has no pool yet
+	if listener.ID != "" {
+		pool, err = openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
+		if err != nil && err != cpoerrors.ErrNotFound {
+			return nil, fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
+		}
 	}
 
-	// By default, use the protocol of the listener
-	poolProto := v2pools.Protocol(listener.Protocol)
-	if svcConf.proxyProtocolVersion != nil {
-		poolProto = *svcConf.proxyProtocolVersion
-	} else if (svcConf.keepClientIP || svcConf.tlsContainerRef != "") && poolProto != v2pools.ProtocolHTTP {
-		poolProto = v2pools.ProtocolHTTP
-	}
+	poolProto := getPoolProtocol(listener.Protocol, svcConf)
 
 	// Delete the pool and its members if it already exists and has the wrong protocol
 	if pool != nil && v2pools.Protocol(pool.Protocol) != poolProto {
-		klog.InfoS("Deleting unused pool", "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
+		if !lbaas.plan.planned(planActionDelete, "pool", pool.ID, fmt.Sprintf("protocol %s changes", pool.Protocol)) {
+			klog.InfoS("Deleting unused pool", "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
 
-		// Delete pool automatically deletes all its members.
-		if err := openstackutil.DeletePool(lbaas.lb, pool.ID, lbID); err != nil {
-			return nil, err
+			// Delete pool automatically deletes all its members.
+			if err := openstackutil.DeletePool(lbaas.lb, pool.ID, lbID); err != nil {
+				return nil, err
+			}
 		}
 		pool = nil
 	}
 
 	// If LBMethod changes, update the Pool with the new value
-	var poolLbMethod string
-	if svcConf.poolLbMethod != "" {
-		poolLbMethod = svcConf.poolLbMethod
-	} else {
-		// if LBMethod is not defined, fallback on default OCCM's default method
-		poolLbMethod = lbaas.opts.LBMethod
-	}
+	poolLbMethod := lbaas.getPoolLBMethod(svcConf)
 	if pool != nil && pool.LBMethod != poolLbMethod {
-		klog.InfoS("Updating LoadBalancer LBMethod", "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
-		err = openstackutil.UpdatePool(lbaas.lb, lbID, pool.ID, v2pools.UpdateOpts{LBMethod: v2pools.LBMethod(poolLbMethod)})
-		if err != nil {
-			err = PreserveGopherError(err)
-			msg := fmt.Sprintf("Error updating LB method for LoadBalancer: %v", err)
-			klog.Errorf(msg, "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
-			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBLbMethodUnknown, msg)
-		} else {
-			pool.LBMethod = poolLbMethod
+		updateOpts := v2pools.UpdateOpts{LBMethod: v2pools.LBMethod(poolLbMethod)}
+		if !lbaas.plan.planned(planActionUpdate, "pool", pool.ID, describeOpts(updateOpts)) {
+			klog.InfoS("Updating LoadBalancer LBMethod", "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
+			err = openstackutil.UpdatePool(lbaas.lb, lbID, pool.ID, updateOpts)
+			if err != nil {
+				err = PreserveGopherError(err)
+				msg := fmt.Sprintf("Error updating LB method for LoadBalancer: %v", err)
+				klog.Errorf(msg, "poolID", pool.ID, "listenerID", listener.ID, "lbID", lbID)
+				lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBLbMethodUnknown, msg)
+			} else {
+				pool.LBMethod = poolLbMethod
+			}
 		}
 	}
 
@@ -927,6 +1133,11 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, name string, listener *list
 		createOpt := lbaas.buildPoolCreateOpt(listener.Protocol, service, svcConf, name)
 		createOpt.ListenerID = listener.ID
 
+		// The planned pool has no ID, its members and health monitor are planned in it
+		if lbaas.plan.planned(planActionCreate, "pool", name, describeOpts(createOpt)) {
+			return lbaas.planPoolMembers(&v2pools.Pool{Name: name, Protocol: string(createOpt.Protocol)}, nil, port, nodes, svcConf)
+		}
+
 		klog.InfoS("Creating pool", "listenerID", listener.ID, "protocol", createOpt.Protocol)
 		pool, err = openstackutil.CreatePool(lbaas.lb, createOpt, lbID)
 		if err != nil {
@@ -935,7 +1146,7 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, name string, listener *list
 		klog.V(2).Infof("Pool %s created for listener %s", pool.ID, listener.ID)
 	}
 
-	if lbaas.opts.ProviderRequiresSerialAPICalls {
+	if lbaas.opts.ProviderRequiresSerialAPICalls && lbaas.plan == nil {
 		klog.V(2).Infof("Using serial API calls to update members for pool %s", pool.ID)
 		var nodePort int = int(port.NodePort)
 
@@ -945,13 +1156,14 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, name string, listener *list
 		return pool, nil
 	}
 
-	curMembers := sets.New[string]()
 	poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
 	if err != nil {
 		klog.Errorf("failed to get members in the pool %s: %v", pool.ID, err)
 	}
-	for _, m := range poolMembers {
-		curMembers.Insert(fmt.Sprintf("%s-%s-%d-%d", m.Name, m.Address, m.ProtocolPort, m.MonitorPort))
+	curMembers := getPoolMemberKeys(poolMembers)
+
+	if lbaas.plan != nil {
+		return lbaas.planPoolMembers(pool, curMembers, port, nodes, svcConf)
 	}
 
 	members, newMembers, err := lbaas.buildBatchUpdateMemberOpts(port, nodes, svcConf)
@@ -970,10 +1182,49 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, name string, listener *list
 	return pool, nil
 }
 
+// planPoolMembers adds the update of the members of the pool to the plan. The members of a planned pool, with no ID,
+// are all added.
+func (lbaas *LbaasV2) planPoolMembers(pool *v2pools.Pool, curMembers sets.Set[string], port corev1.ServicePort, nodes []*corev1.Node, svcConf *serviceConfig) (*v2pools.Pool, error) {
+	_, newMembers, err := lbaas.buildBatchUpdateMemberOpts(port, nodes, svcConf)
+	if err != nil {
+		return nil, err
+	}
+	if added, removed := newMembers.Difference(curMembers), curMembers.Difference(newMembers); added.Len() > 0 || removed.Len() > 0 {
+		lbaas.plan.add(planActionUpdate, "members", cmp.Or(pool.ID, pool.Name), fmt.Sprintf("add [%s], remove [%s]",
+			strings.Join(sets.List(added), ", "), strings.Join(sets.List(removed), ", ")))
+	}
+	return pool, nil
+}
+
+// getPoolProtocol returns the protocol of the pool behind a listener of the given protocol.
+func getPoolProtocol(listenerProtocol string, svcConf *serviceConfig) v2pools.Protocol {
+	// By default, use the protocol of the listener
+	poolProto := v2pools.Protocol(listenerProtocol)
+	// PROXY and HTTP pools can't be used with UDP and SCTP listeners
+	l4Only := poolProto == v2pools.ProtocolUDP || poolProto == v2pools.ProtocolSCTP
+	if !l4Only && svcConf.proxyProtocolVersion != nil {
+		poolProto = *svcConf.proxyProtocolVersion
+	} else if !l4Only && (svcConf.keepClientIP || svcConf.tlsContainerRef != "") && poolProto != v2pools.ProtocolHTTP {
+		poolProto = v2pools.ProtocolHTTP
+	}
+	return poolProto
+}
+
+// getPoolLBMethod returns the load balancing algorithm of the pools of the Service.
+func (lbaas *LbaasV2) getPoolLBMethod(svcConf *serviceConfig) string {
+	if svcConf.poolLbMethod != "" {
+		return svcConf.poolLbMethod
+	}
+	// if LBMethod is not defined, fallback on default OCCM's default method
+	return lbaas.opts.LBMethod
+}
+
 func (lbaas *LbaasV2) buildPoolCreateOpt(listenerProtocol string, service *corev1.Service, svcConf *serviceConfig, name string) v2pools.CreateOpts {
 	// By default, use the protocol of the listener
 	poolProto := v2pools.Protocol(listenerProtocol)
-	if svcConf.proxyProtocolVersion != nil {
+	if poolProto == v2pools.ProtocolUDP || poolProto == v2pools.ProtocolSCTP {
+		klog.V(4).Infof("Keeping %q protocol for pool because PROXY and HTTP pools require a TCP based listener", poolProto)
+	} else if svcConf.proxyProtocolVersion != nil {
 		poolProto = *svcConf.proxyProtocolVersion
 	} else if (svcConf.keepClientIP || svcConf.tlsContainerRef != "") && poolProto != v2pools.ProtocolHTTP {
 		if svcConf.keepClientIP && svcConf.tlsContainerRef != "" {
@@ -1011,13 +1262,27 @@ func (lbaas *LbaasV2) buildPoolCreateOpt(listenerProtocol string, service *corev
 	}
 }
 
+// getPoolMemberKeys returns the keys of the pool members compared with the ones of buildBatchUpdateMemberOpts.
+func getPoolMemberKeys(poolMembers []v2pools.Member) sets.Set[string] {
+	keys := sets.New[string]()
+	for _, m := range poolMembers {
+		keys.Insert(fmt.Sprintf("%s-%s-%d-%d-%d", m.Name, m.Address, m.ProtocolPort, m.MonitorPort, m.Weight))
+	}
+	return keys
+}
+
 // buildBatchUpdateMemberOpts returns v2pools.BatchUpdateMemberOpts array for Services and Nodes alongside a list of member names
 func (lbaas *LbaasV2) buildBatchUpdateMemberOpts(port corev1.ServicePort, nodes []*corev1.Node, svcConf *serviceConfig) ([]v2pools.BatchUpdateMemberOpts, sets.Set[string], error) {
+	if svcConf.endpointMembers {
+		members, newMembers := buildEndpointMemberOpts(port, svcConf)
+		return members, newMembers, nil
+	}
+
 	var members []v2pools.BatchUpdateMemberOpts
 	newMembers := sets.New[string]()
 
 	for _, node := range nodes {
-		addr, err := nodeAddressForLB(node, svcConf.preferredIPFamily)
+		addr, err := memberAddressForLB(node, svcConf)
 		if err != nil {
 			if err == cpoerrors.ErrNoAddressFound {
 				// Node failure, do not create member
@@ -1039,12 +1304,13 @@ func (lbaas *LbaasV2) buildBatchUpdateMemberOpts(port corev1.ServicePort, nodes
 				ProtocolPort: int(port.NodePort),
 				Name:         &node.Name,
 				SubnetID:     memberSubnetID,
+				Weight:       memberWeight(node.Name, svcConf),
 			}
 			if svcConf.healthCheckNodePort > 0 && lbaas.canUseHTTPMonitor(port) {
 				member.MonitorPort = &svcConf.healthCheckNodePort
 			}
 			members = append(members, member)
-			newMembers.Insert(fmt.Sprintf("%s-%s-%d-%d", node.Name, addr, member.ProtocolPort, svcConf.healthCheckNodePort))
+			newMembers.Insert(fmt.Sprintf("%s-%s-%d-%d-%d", node.Name, addr, member.ProtocolPort, svcConf.healthCheckNodePort, ptr.Deref(member.Weight, 1)))
 		}
 	}
 	return members, newMembers, nil
@@ -1075,16 +1341,56 @@ func (lbaas *LbaasV2) buildCreateMemberOpts(port corev1.ServicePort, nodes []*co
 	return createMemberOpts, newMembers, nil
 }
 
+// ensureOctaviaListeners makes sure the listeners, pools and health monitors of the Service ports exist on the load
+// balancer, and deletes the remaining listeners created by the Service.
+func (lbaas *LbaasV2) ensureOctaviaListeners(loadbalancer *loadbalancers.LoadBalancer, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig, isLBOwner bool) error {
+	curListeners := loadbalancer.Listeners
+	curListenerMapping := getListenerMapping(curListeners)
+	klog.V(4).InfoS("Existing listeners", "portProtocolMapping", curListenerMapping)
+
+	// Check port conflicts
+	if err := lbaas.checkListenerPorts(service, curListenerMapping, isLBOwner, svcConf.lbName, svcConf); err != nil {
+		return err
+	}
+
+	for portIndex, port := range service.Spec.Ports {
+		listener, err := lbaas.ensureOctaviaListener(loadbalancer.ID, cpoutil.Sprintf255(listenerFormat, portIndex, svcConf.lbName), curListenerMapping, port, svcConf)
+		if err != nil {
+			return err
+		}
+		lbaas.checkClientAuthLeftEnforced(service, listener, svcConf)
+
+		pool, err := lbaas.ensureOctaviaPool(loadbalancer.ID, cpoutil.Sprintf255(poolFormat, portIndex, svcConf.lbName), listener, service, port, nodes, svcConf)
+		if err != nil {
+			return err
+		}
+
+		if err := lbaas.ensureOctaviaHealthMonitor(loadbalancer.ID, cpoutil.Sprintf255(monitorFormat, portIndex, svcConf.lbName), pool, port, svcConf); err != nil {
+			return err
+		}
+
+		// After all ports have been processed, remaining listeners are removed if they were created by this Service.
+		// The remove of the listener must always happen at the end of the loop to avoid wrong assignment.
+		// Modifying the curListeners would also change the mapping.
+		curListeners = popListener(curListeners, listener.ID)
+	}
+
+	// Deal with the remaining listeners, delete the listener if it was created by this Service previously.
+	return lbaas.deleteOctaviaListeners(loadbalancer.ID, curListeners, isLBOwner, svcConf.lbName)
+}
+
 // Make sure the listener is created for Service
 func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, name string, curListenerMapping map[listenerKey]*listeners.Listener, port corev1.ServicePort, svcConf *serviceConfig) (*listeners.Listener, error) {
-	listener, isPresent := curListenerMapping[listenerKey{
-		Protocol: getListenerProtocol(port.Protocol, svcConf),
-		Port:     int(port.Port),
-	}]
+	listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
 	if !isPresent {
 		listenerCreateOpt := lbaas.buildListenerCreateOpt(port, svcConf, name)
 		listenerCreateOpt.LoadbalancerID = lbID
 
+		// The planned listener has no ID, the pool is planned behind it
+		if lbaas.plan.planned(planActionCreate, "listener", name, describeOpts(listenerCreateOpt)) {
+			return &listeners.Listener{Name: name, Protocol: string(listenerCreateOpt.Protocol), ProtocolPort: int(port.Port)}, nil
+		}
+
 		klog.V(2).Infof("Creating listener for port %d using protocol %s", int(port.Port), listenerCreateOpt.Protocol)
 
 		var err error
@@ -1095,76 +1401,146 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, name string, curListene
 
 		klog.V(2).Infof("Listener %s created for loadbalancer %s", listener.ID, lbID)
 	} else {
-		listenerChanged := false
-		updateOpts := listeners.UpdateOpts{}
-
-		if svcConf.supportLBTags {
-			if !slices.Contains(listener.Tags, svcConf.lbName) {
-				var newTags []string
-				copy(newTags, listener.Tags)
-				newTags = append(newTags, svcConf.lbName)
-				updateOpts.Tags = &newTags
-				listenerChanged = true
+		updateOpts, listenerChanged := lbaas.buildListenerUpdateOpts(lbID, listener, port, svcConf)
+		if listenerChanged && !lbaas.plan.planned(planActionUpdate, "listener", listener.ID, describeOpts(updateOpts)) {
+			klog.InfoS("Updating listener", "listenerID", listener.ID, "lbID", lbID, "updateOpts", updateOpts)
+			if err := openstackutil.UpdateListener(lbaas.lb, lbID, listener.ID, updateOpts); err != nil {
+				return nil, fmt.Errorf("failed to update listener %s of loadbalancer %s: %v", listener.ID, lbID, err)
 			}
+			klog.InfoS("Updated listener", "listenerID", listener.ID, "lbID", lbID)
 		}
+	}
 
-		if svcConf.connLimit != listener.ConnLimit {
-			updateOpts.ConnLimit = &svcConf.connLimit
+	return listener, nil
+}
+
+// buildListenerUpdateOpts returns the listeners.UpdateOpts bringing the listener serving the Service port to the
+// configuration, and whether the listener needs to be updated at all.
+func (lbaas *LbaasV2) buildListenerUpdateOpts(lbID string, listener *listeners.Listener, port corev1.ServicePort, svcConf *serviceConfig) (listeners.UpdateOpts, bool) {
+	listenerChanged := false
+	updateOpts := listeners.UpdateOpts{}
+
+	if svcConf.supportLBTags {
+		if !slices.Contains(listener.Tags, svcConf.lbName) {
+			newTags := append(slices.Clone(listener.Tags), svcConf.lbName)
+			updateOpts.Tags = &newTags
 			listenerChanged = true
 		}
+	}
 
-		listenerKeepClientIP := listener.InsertHeaders[annotationXForwardedFor] == "true"
-		if svcConf.keepClientIP != listenerKeepClientIP {
-			updateOpts.InsertHeaders = &listener.InsertHeaders
-			if svcConf.keepClientIP {
-				if *updateOpts.InsertHeaders == nil {
-					*updateOpts.InsertHeaders = make(map[string]string)
-				}
-				(*updateOpts.InsertHeaders)[annotationXForwardedFor] = "true"
-			} else {
-				delete(*updateOpts.InsertHeaders, annotationXForwardedFor)
+	if svcConf.connLimit != listener.ConnLimit {
+		updateOpts.ConnLimit = &svcConf.connLimit
+		listenerChanged = true
+	}
+
+	if svcConf.adminStateUp != nil && *svcConf.adminStateUp != listener.AdminStateUp {
+		updateOpts.AdminStateUp = svcConf.adminStateUp
+		listenerChanged = true
+	}
+
+	// HTTP headers and TLS termination only apply to TCP based listeners
+	keepClientIP := svcConf.keepClientIP && isL7CapableProtocol(port.Protocol)
+	tlsContainerRef := svcConf.tlsContainerRef
+	if !isL7CapableProtocol(port.Protocol) {
+		tlsContainerRef = ""
+	}
+
+	listenerKeepClientIP := listener.InsertHeaders[annotationXForwardedFor] == "true"
+	if keepClientIP != listenerKeepClientIP {
+		updateOpts.InsertHeaders = &listener.InsertHeaders
+		if keepClientIP {
+			if *updateOpts.InsertHeaders == nil {
+				*updateOpts.InsertHeaders = make(map[string]string)
+			}
+			(*updateOpts.InsertHeaders)[annotationXForwardedFor] = "true"
+		} else {
+			delete(*updateOpts.InsertHeaders, annotationXForwardedFor)
+		}
+		listenerChanged = true
+	}
+	if tlsContainerRef != listener.DefaultTlsContainerRef {
+		updateOpts.DefaultTlsContainerRef = &tlsContainerRef
+		listenerChanged = true
+	}
+	if sniContainerRefs := getSNIContainerRefs(port, svcConf); !cpoutil.StringListEqual(sniContainerRefs, listener.SniContainerRefs) {
+		updateOpts.SniContainerRefs = &sniContainerRefs
+		listenerChanged = true
+	}
+	if tlsContainerRef != "" && svcConf.tlsCiphers != "" && svcConf.tlsCiphers != listener.TLSCiphers {
+		updateOpts.TLSCiphers = &svcConf.tlsCiphers
+		listenerChanged = true
+	}
+	if tlsContainerRef != "" && svcConf.tlsVersions != nil && !cpoutil.StringListEqual(tlsVersionStrings(svcConf.tlsVersions), listener.TLSVersions) {
+		updateOpts.TLSVersions = &svcConf.tlsVersions
+		listenerChanged = true
+	}
+	if tlsContainerRef != "" && svcConf.tlsFingerprint != "" && svcConf.supportLBTags && getListenerTLSFingerprint(listener.Tags) != svcConf.tlsFingerprint {
+		// Updating the listener makes Octavia fetch the rotated certificate
+		if listenerNeedsTLSReload(listener, svcConf.tlsFingerprint) {
+			klog.InfoS("Reloading rotated TLS certificate of listener", "listenerID", listener.ID, "lbID", lbID)
+			updateOpts.DefaultTlsContainerRef = &tlsContainerRef
+			if svcConf.clientCATLSContainerRef != "" {
+				updateOpts.ClientCATLSContainerRef = &svcConf.clientCATLSContainerRef
+				updateOpts.ClientCRLContainerRef = &svcConf.clientCRLContainerRef
 			}
+		}
+		tags := listener.Tags
+		if updateOpts.Tags != nil {
+			tags = *updateOpts.Tags
+		}
+		tags = setListenerTLSFingerprint(tags, svcConf.tlsFingerprint)
+		updateOpts.Tags = &tags
+		listenerChanged = true
+	}
+	if tlsContainerRef != "" && svcConf.clientAuthentication != "" {
+		if string(svcConf.clientAuthentication) != listener.ClientAuthentication {
+			updateOpts.ClientAuthentication = &svcConf.clientAuthentication
 			listenerChanged = true
 		}
-		if svcConf.tlsContainerRef != listener.DefaultTlsContainerRef {
-			updateOpts.DefaultTlsContainerRef = &svcConf.tlsContainerRef
+		if svcConf.clientCATLSContainerRef != listener.ClientCATLSContainerRef {
+			updateOpts.ClientCATLSContainerRef = &svcConf.clientCATLSContainerRef
 			listenerChanged = true
 		}
-		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout, lbaas.opts.LBProvider) {
-			if svcConf.timeoutClientData != listener.TimeoutClientData {
-				updateOpts.TimeoutClientData = &svcConf.timeoutClientData
-				listenerChanged = true
-			}
-			if svcConf.timeoutMemberConnect != listener.TimeoutMemberConnect {
-				updateOpts.TimeoutMemberConnect = &svcConf.timeoutMemberConnect
-				listenerChanged = true
-			}
-			if svcConf.timeoutMemberData != listener.TimeoutMemberData {
-				updateOpts.TimeoutMemberData = &svcConf.timeoutMemberData
-				listenerChanged = true
-			}
-			if svcConf.timeoutTCPInspect != listener.TimeoutTCPInspect {
-				updateOpts.TimeoutTCPInspect = &svcConf.timeoutTCPInspect
-				listenerChanged = true
-			}
+		if svcConf.clientCRLContainerRef != listener.ClientCRLContainerRef {
+			updateOpts.ClientCRLContainerRef = &svcConf.clientCRLContainerRef
+			listenerChanged = true
 		}
-		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureVIPACL, lbaas.opts.LBProvider) {
-			if !cpoutil.StringListEqual(svcConf.allowedCIDR, listener.AllowedCIDRs) {
-				updateOpts.AllowedCIDRs = &svcConf.allowedCIDR
-				listenerChanged = true
-			}
+	}
+	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout, lbaas.opts.LBProvider) {
+		if svcConf.timeoutClientData != listener.TimeoutClientData {
+			updateOpts.TimeoutClientData = &svcConf.timeoutClientData
+			listenerChanged = true
 		}
-
-		if listenerChanged {
-			klog.InfoS("Updating listener", "listenerID", listener.ID, "lbID", lbID, "updateOpts", updateOpts)
-			if err := openstackutil.UpdateListener(lbaas.lb, lbID, listener.ID, updateOpts); err != nil {
-				return nil, fmt.Errorf("failed to update listener %s of loadbalancer %s: %v", listener.ID, lbID, err)
-			}
-			klog.InfoS("Updated listener", "listenerID", listener.ID, "lbID", lbID)
+		if svcConf.timeoutMemberConnect != listener.TimeoutMemberConnect {
+			updateOpts.TimeoutMemberConnect = &svcConf.timeoutMemberConnect
+			listenerChanged = true
+		}
+		if svcConf.timeoutMemberData != listener.TimeoutMemberData {
+			updateOpts.TimeoutMemberData = &svcConf.timeoutMemberData
+			listenerChanged = true
+		}
+		if svcConf.timeoutTCPInspect != listener.TimeoutTCPInspect {
+			updateOpts.TimeoutTCPInspect = &svcConf.timeoutTCPInspect
+			listenerChanged = true
+		}
+	}
+	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureVIPACL, lbaas.opts.LBProvider) {
+		if !cpoutil.StringListEqual(svcConf.allowedCIDR, listener.AllowedCIDRs) {
+			updateOpts.AllowedCIDRs = &svcConf.allowedCIDR
+			listenerChanged = true
 		}
 	}
 
-	return listener, nil
+	return updateOpts, listenerChanged
+}
+
+// getSNIContainerRefs returns the SNI container refs of the listener serving the given Service port, only the
+// TERMINATED_HTTPS listeners use them.
+func getSNIContainerRefs(port corev1.ServicePort, svcConf *serviceConfig) []string {
+	if svcConf.tlsContainerRef == "" || !isL7CapableProtocol(port.Protocol) {
+		return []string{}
+	}
+	return svcConf.sniContainerRefs
 }
 
 // buildListenerCreateOpt returns listeners.CreateOpts for a specific Service port and configuration
@@ -1180,6 +1556,10 @@ func (lbaas *LbaasV2) buildListenerCreateOpt(port corev1.ServicePort, svcConf *s
 		listenerCreateOpt.Tags = []string{svcConf.lbName}
 	}
 
+	if svcConf.adminStateUp != nil {
+		listenerCreateOpt.AdminStateUp = svcConf.adminStateUp
+	}
+
 	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout, lbaas.opts.LBProvider) {
 		listenerCreateOpt.TimeoutClientData = &svcConf.timeoutClientData
 		listenerCreateOpt.TimeoutMemberConnect = &svcConf.timeoutMemberConnect
@@ -1187,16 +1567,27 @@ func (lbaas *LbaasV2) buildListenerCreateOpt(port corev1.ServicePort, svcConf *s
 		listenerCreateOpt.TimeoutTCPInspect = &svcConf.timeoutTCPInspect
 	}
 
-	if svcConf.keepClientIP {
+	if svcConf.keepClientIP && isL7CapableProtocol(port.Protocol) {
 		listenerCreateOpt.InsertHeaders = map[string]string{annotationXForwardedFor: "true"}
 	}
 
-	if svcConf.tlsContainerRef != "" {
+	if svcConf.tlsContainerRef != "" && isL7CapableProtocol(port.Protocol) {
 		listenerCreateOpt.DefaultTlsContainerRef = svcConf.tlsContainerRef
+		listenerCreateOpt.SniContainerRefs = getSNIContainerRefs(port, svcConf)
+		listenerCreateOpt.TLSCiphers = svcConf.tlsCiphers
+		listenerCreateOpt.TLSVersions = svcConf.tlsVersions
+		if svcConf.supportLBTags && svcConf.tlsFingerprint != "" {
+			listenerCreateOpt.Tags = setListenerTLSFingerprint(listenerCreateOpt.Tags, svcConf.tlsFingerprint)
+		}
+		listenerCreateOpt.ClientAuthentication = svcConf.clientAuthentication
+		listenerCreateOpt.ClientCATLSContainerRef = svcConf.clientCATLSContainerRef
+		listenerCreateOpt.ClientCRLContainerRef = svcConf.clientCRLContainerRef
 	}
 
 	// protocol selection
-	if svcConf.tlsContainerRef != "" && listenerCreateOpt.Protocol != listeners.ProtocolTerminatedHTTPS {
+	if !isL7CapableProtocol(port.Protocol) {
+		klog.V(4).Infof("Keeping %q protocol for listener of port %d", port.Protocol, port.Port)
+	} else if svcConf.tlsContainerRef != "" && listenerCreateOpt.Protocol != listeners.ProtocolTerminatedHTTPS {
 		klog.V(4).Infof("Forcing to use %q protocol for listener because %q annotation is set", listeners.ProtocolTerminatedHTTPS, ServiceAnnotationTlsContainerRef)
 		listenerCreateOpt.Protocol = listeners.ProtocolTerminatedHTTPS
 	} else if svcConf.keepClientIP && listenerCreateOpt.Protocol != listeners.ProtocolHTTP {
@@ -1300,6 +1691,10 @@ func (lbaas *LbaasV2) checkServiceUpdate(ctx context.Context, service *corev1.Se
 	if len(service.Spec.Ports) == 0 {
 		return fmt.Errorf("no ports provided to openstack load balancer")
 	}
+	service, err := lbaas.withLoadBalancerProfile(ctx, service)
+	if err != nil {
+		return err
+	}
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 
 	if len(service.Spec.IPFamilies) > 0 {
@@ -1308,6 +1703,10 @@ func (lbaas *LbaasV2) checkServiceUpdate(ctx context.Context, service *corev1.Se
 		svcConf.preferredIPFamily = service.Spec.IPFamilies[0]
 	}
 
+	if err := lbaas.setMemberAddressSelection(ctx, service, nodes, svcConf); err != nil {
+		return err
+	}
+
 	// Find subnet ID for creating members
 	memberSubnetID, err := lbaas.getMemberSubnetID(service)
 	if err != nil {
@@ -1331,7 +1730,7 @@ func (lbaas *LbaasV2) checkServiceUpdate(ctx context.Context, service *corev1.Se
 		} else {
 			svcConf.lbMemberSubnetID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerSubnetID, lbaas.opts.SubnetID)
 			if len(svcConf.lbMemberSubnetID) == 0 && len(nodes) > 0 {
-				subnetID, err := getSubnetIDForLB(ctx, lbaas.network, *nodes[0], svcConf.preferredIPFamily)
+				subnetID, err := getSubnetIDForLB(ctx, lbaas.network, *nodes[0], svcConf)
 				if err != nil {
 					return fmt.Errorf("no subnet-id found for service %s: %v", serviceName, err)
 				}
@@ -1343,6 +1742,13 @@ func (lbaas *LbaasV2) checkServiceUpdate(ctx context.Context, service *corev1.Se
 }
 
 func (lbaas *LbaasV2) checkServiceDelete(service *corev1.Service, svcConf *serviceConfig) error {
+	// The load balancer is cleaned up even when its profile is gone.
+	if merged, err := lbaas.withLoadBalancerProfile(context.TODO(), service); err != nil {
+		klog.Warningf("Ignoring the load balancer profile of Service %s/%s: %v", service.Namespace, service.Name, err)
+	} else {
+		service = merged
+	}
+
 	svcConf.lbID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "")
 	svcConf.supportLBTags = openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTags, lbaas.opts.LBProvider)
 
@@ -1350,11 +1756,17 @@ func (lbaas *LbaasV2) checkServiceDelete(service *corev1.Service, svcConf *servi
 	svcConf.keepClientIP = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerXForwardedFor, false)
 	svcConf.proxyProtocolVersion = getProxyProtocolFromServiceAnnotation(service)
 	svcConf.tlsContainerRef = getStringFromServiceAnnotation(service, ServiceAnnotationTlsContainerRef, lbaas.opts.TlsContainerRef)
+	svcConf.enableMonitor = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerEnableHealthMonitor, lbaas.opts.CreateMonitor)
+	svcConf.healthMonitorType = getHealthMonitorType(service)
 
 	return nil
 }
 
 func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig) error {
+	service, err := lbaas.withLoadBalancerProfile(ctx, service)
+	if err != nil {
+		return err
+	}
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 
 	if len(nodes) == 0 {
@@ -1371,6 +1783,10 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 		svcConf.preferredIPFamily = service.Spec.IPFamilies[0]
 	}
 
+	if err := lbaas.setMemberAddressSelection(ctx, service, nodes, svcConf); err != nil {
+		return err
+	}
+
 	// If in the config file internal-lb=true, user is not allowed to create external service.
 	if lbaas.opts.InternalLB {
 		if !getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerInternal, false) {
@@ -1389,32 +1805,34 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 		}
 
 		// check if container or secret exists for 'barbican' container store
-		// tls container ref has the format: https://{keymanager_host}/v1/containers/{uuid} or https://{keymanager_host}/v1/secrets/{uuid}
 		if lbaas.opts.ContainerStore == "barbican" {
-			slice := strings.Split(svcConf.tlsContainerRef, "/")
-			if len(slice) < 2 {
-				return fmt.Errorf("invalid tlsContainerRef for service %s", serviceName)
-			}
-			barbicanUUID := slice[len(slice)-1]
-			barbicanType := slice[len(slice)-2]
-			if barbicanType == "containers" {
-				container, err := containers.Get(ctx, lbaas.secret, barbicanUUID).Extract()
-				if err != nil {
-					return fmt.Errorf("failed to get tls container %q: %v", svcConf.tlsContainerRef, err)
-				}
-				klog.V(4).Infof("Default TLS container %q found", container.ContainerRef)
-			} else if barbicanType == "secrets" {
-				secret, err := secrets.Get(ctx, lbaas.secret, barbicanUUID).Extract()
-				if err != nil {
-					return fmt.Errorf("failed to get tls secret %q: %v", svcConf.tlsContainerRef, err)
+			fingerprint, err := getTLSContainerFingerprint(ctx, lbaas.secret, svcConf.tlsContainerRef)
+			if err != nil {
+				return fmt.Errorf("failed to validate tlsContainerRef for service %s: %v", serviceName, err)
+			}
+			svcConf.tlsFingerprint = fingerprint
+		}
+	}
+
+	svcConf.sniContainerRefs = cpoutil.SplitTrim(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerSNIContainerRefs, ""), ',')
+	if len(svcConf.sniContainerRefs) > 0 {
+		if svcConf.tlsContainerRef == "" {
+			return fmt.Errorf("annotation %s requires a default tls container ref to be set for service %s", ServiceAnnotationLoadBalancerSNIContainerRefs, serviceName)
+		}
+		if lbaas.opts.ContainerStore == "barbican" {
+			for _, ref := range svcConf.sniContainerRefs {
+				// The fingerprint is only used for the default container, it checks that the SNI one exists
+				if _, err := getTLSContainerFingerprint(ctx, lbaas.secret, ref); err != nil {
+					return fmt.Errorf("failed to validate SNI container ref for service %s: %v", serviceName, err)
 				}
-				klog.V(4).Infof("Default TLS secret %q found", secret.SecretRef)
-			} else {
-				return fmt.Errorf("failed to validate tlsContainerRef for service %s: tlsContainerRef type %s unknown", serviceName, barbicanType)
 			}
 		}
 	}
 
+	if err := lbaas.setClientAuthentication(ctx, service, svcConf); err != nil {
+		return fmt.Errorf("invalid client authentication of service %s: %v", serviceName, err)
+	}
+
 	lbNetworkID, err := lbaas.getNetworkID(service, svcConf)
 	if err != nil {
 		return fmt.Errorf("failed to get network id to create load balancer for service %s: %v", serviceName, err)
@@ -1433,7 +1851,7 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 		svcConf.lbMemberSubnetID = svcConf.lbSubnetID
 	}
 	if len(svcConf.lbNetworkID) == 0 && len(svcConf.lbSubnetID) == 0 {
-		subnetID, err := getSubnetIDForLB(ctx, lbaas.network, *nodes[0], svcConf.preferredIPFamily)
+		subnetID, err := getSubnetIDForLB(ctx, lbaas.network, *nodes[0], svcConf)
 		if err != nil {
 			return fmt.Errorf("failed to get subnet to create load balancer for service %s: %v", serviceName, err)
 		}
@@ -1451,7 +1869,9 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 		svcConf.lbMemberSubnetID = memberSubnetID
 	}
 
-	if !svcConf.internal {
+	svcConf.externalFloatingIP = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerExternalFloatingIP, lbaas.opts.ExternalFloatingIP)
+
+	if !svcConf.internal && !svcConf.externalFloatingIP {
 		var lbClass *LBClass
 		var floatingNetworkID string
 		var floatingSubnet floatingSubnetSpec
@@ -1483,11 +1903,9 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 
 		// If there's no annotation and configuration, try to autodetect the FIP network by looking up external nets
 		if floatingNetworkID == "" {
-			floatingNetworkID, err = openstackutil.GetFloatingNetworkID(ctx, lbaas.network)
+			floatingNetworkID, err = lbaas.detectFloatingNetworkID(ctx, service, serviceName)
 			if err != nil {
-				msg := "Failed to find floating-network-id for Service %s: %v"
-				lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBExternalNetworkSearchFailed, msg, serviceName, err)
-				klog.Warningf(msg, serviceName, err)
+				return err
 			}
 		}
 
@@ -1530,17 +1948,51 @@ func (lbaas *LbaasV2) checkService(ctx context.Context, service *corev1.Service,
 		} else {
 			klog.V(4).Infof("no subnet spec found for %s", serviceName)
 		}
+	} else if !svcConf.internal {
+		klog.V(4).Infof("Ensure an external loadbalancer service with a floating IP attached by an external controller")
 	} else {
 		klog.V(4).Infof("Ensure an internal loadbalancer service.")
 	}
 	return lbaas.makeSvcConf(serviceName, service, svcConf)
 }
 
+// detectFloatingNetworkID returns the first external network of the cloud, or an error if require-floating-network-id
+// is set. A failed lookup is only reported, the load balancer is created without floating IP, unless the Service
+// requires one with the require-floating-ip annotation.
+func (lbaas *LbaasV2) detectFloatingNetworkID(ctx context.Context, service *corev1.Service, serviceName string) (string, error) {
+	if lbaas.opts.RequireFloatingNetworkID {
+		msg := "No floating network configured for Service %s, set the %s annotation or the floating-network-id option"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBFloatingNetworkMissing, msg, serviceName, ServiceAnnotationLoadBalancerFloatingNetworkID)
+		return "", fmt.Errorf(msg, serviceName, ServiceAnnotationLoadBalancerFloatingNetworkID)
+	}
+
+	floatingNetworkID, err := openstackutil.GetFloatingNetworkID(ctx, lbaas.network)
+	if err != nil {
+		if getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerRequireFloatingIP, false) {
+			msg := "Failed to find a floating network for Service %s, which requires a floating IP: %v"
+			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBFloatingIPRequired, msg, serviceName, err)
+			return "", fmt.Errorf(msg, serviceName, err)
+		}
+		msg := "Failed to find floating-network-id for Service %s: %v"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBExternalNetworkSearchFailed, msg, serviceName, err)
+		klog.Warningf(msg, serviceName, err)
+	}
+	return floatingNetworkID, nil
+}
+
 func (lbaas *LbaasV2) makeSvcConf(serviceName string, service *corev1.Service, svcConf *serviceConfig) error {
 	svcConf.connLimit = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerConnLimit, -1)
 	svcConf.lbID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "")
 	svcConf.poolLbMethod = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerLbMethod, "")
 	svcConf.supportLBTags = openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTags, lbaas.opts.LBProvider)
+	var err error
+	svcConf.lbTags, err = parseLoadBalancerTags(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTags, ""))
+	if err != nil {
+		return fmt.Errorf("invalid annotation %s for service %s: %v", ServiceAnnotationLoadBalancerTags, serviceName, err)
+	}
+	if len(svcConf.lbTags) > 0 && !svcConf.supportLBTags {
+		return fmt.Errorf("annotation %s is only supported with the tag feature in the cloud load balancer service", ServiceAnnotationLoadBalancerTags)
+	}
 
 	// Get service node-selector annotations
 	svcConf.nodeSelectors = getKeyValueFromServiceAnnotation(service, ServiceAnnotationLoadBalancerNodeSelector, lbaas.opts.NodeSelector)
@@ -1595,22 +2047,98 @@ func (lbaas *LbaasV2) makeSvcConf(serviceName string, service *corev1.Service, s
 		klog.Warningf(msg, serviceName)
 	}
 
+	if additionalVIPs := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAdditionalVIPs, ""); additionalVIPs != "" {
+		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureAdditionalVIPs, lbaas.opts.LBProvider) {
+			svcConf.additionalVIPs, err = parseAdditionalVIPs(additionalVIPs)
+			if err != nil {
+				return fmt.Errorf("failed to parse annotation %s of Service %s: %v", ServiceAnnotationLoadBalancerAdditionalVIPs, serviceName, err)
+			}
+		} else {
+			msg := "LoadBalancer additional VIPs aren't supported. Please, upgrade Octavia API to version 2.26 or later (Antelope release) to use them for Service %s"
+			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBAdditionalVIPsIgnored, msg, serviceName)
+			klog.Warningf(msg, serviceName)
+		}
+	}
+
 	svcConf.tlsContainerRef = getStringFromServiceAnnotation(service, ServiceAnnotationTlsContainerRef, lbaas.opts.TlsContainerRef)
+	tlsCiphers := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTLSCiphers, "")
+	tlsVersions := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTLSVersions, "")
+	if tlsCiphers != "" || tlsVersions != "" {
+		if svcConf.tlsContainerRef == "" {
+			return fmt.Errorf("annotations %s and %s require a default tls container ref to be set for service %s",
+				ServiceAnnotationLoadBalancerTLSCiphers, ServiceAnnotationLoadBalancerTLSVersions, serviceName)
+		}
+		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureListenerTLS, lbaas.opts.LBProvider) {
+			svcConf.tlsCiphers = tlsCiphers
+			svcConf.tlsVersions, err = parseTLSVersions(tlsVersions)
+			if err != nil {
+				return fmt.Errorf("failed to parse annotation %s of Service %s: %v", ServiceAnnotationLoadBalancerTLSVersions, serviceName, err)
+			}
+		} else {
+			msg := "Listener TLS ciphers and versions aren't supported. Please, upgrade Octavia API to version 2.17 or later (Victoria release) to use them for Service %s"
+			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBListenerTLSIgnored, msg, serviceName)
+			klog.Warningf(msg, serviceName)
+		}
+	}
 	svcConf.enableMonitor = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerEnableHealthMonitor, lbaas.opts.CreateMonitor)
-	if svcConf.enableMonitor && service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal && service.Spec.HealthCheckNodePort > 0 {
+	if err := setHealthMonitorHTTPOptions(service, svcConf); err != nil {
+		return fmt.Errorf("invalid health monitor annotations of Service %s: %v", serviceName, err)
+	}
+	// A TCP health monitor checks the member port, the healthCheckNodePort always accepts connections.
+	if svcConf.enableMonitor && svcConf.healthMonitorType != monitorTypeTCP && service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal && service.Spec.HealthCheckNodePort > 0 {
 		svcConf.healthCheckNodePort = int(service.Spec.HealthCheckNodePort)
 	}
 	svcConf.healthMonitorDelay = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorDelay, int(lbaas.opts.MonitorDelay.Duration.Seconds()))
 	svcConf.healthMonitorTimeout = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorTimeout, int(lbaas.opts.MonitorTimeout.Duration.Seconds()))
 	svcConf.healthMonitorMaxRetries = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorMaxRetries, int(lbaas.opts.MonitorMaxRetries))
 	svcConf.healthMonitorMaxRetriesDown = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorMaxRetriesDown, int(lbaas.opts.MonitorMaxRetriesDown))
+
+	svcConf.includeControlPlaneNodes = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerIncludeControlPlaneNodes, false)
+
+	// The endpoint members follow the EndpointSlices through the EndpointSlice controller, they're not updated with the
+	// nodes otherwise.
+	svcConf.endpointMembers = getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerEndpointMembers, false)
+	if svcConf.endpointMembers {
+		if lbaas.endpointSliceLister == nil {
+			return fmt.Errorf("annotation %s requires the enable-endpointslice-member-updates option", ServiceAnnotationLoadBalancerEndpointMembers)
+		}
+		if !lbaas.endpointSliceSynced() {
+			return fmt.Errorf("the EndpointSlices of Service %s are not synced yet", serviceName)
+		}
+		svcConf.endpointSlices, err = listServiceEndpointSlices(lbaas.endpointSliceLister, service)
+		if err != nil {
+			return err
+		}
+		// The endpoints are health checked at their own port
+		svcConf.healthCheckNodePort = 0
+	} else if lbaas.endpointSliceLister != nil && lbaas.endpointSliceSynced() && service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		// The member weights follow the EndpointSlices only when the EndpointSlice controller runs, so that they are not
+		// reverted by the periodic updates.
+		localNodes, err := getLocalEndpointNodes(lbaas.endpointSliceLister, service)
+		if err != nil {
+			return err
+		}
+		// The weights are left as they are when no endpoint is ready
+		if localNodes.Len() > 0 {
+			svcConf.localEndpointNodes = localNodes
+		}
+	}
+
+	// The administrative state is only managed when the annotation is set, so that it's possible to change it outside of
+	// the cluster for the other Services.
+	if _, ok := service.Annotations[ServiceAnnotationLoadBalancerAdminStateUp]; ok {
+		adminStateUp := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAdminStateUp, true)
+		svcConf.adminStateUp = &adminStateUp
+	}
 	return nil
 }
 
-// checkListenerPorts checks if there is conflict for ports.
-func (lbaas *LbaasV2) checkListenerPorts(service *corev1.Service, curListenerMapping map[listenerKey]*listeners.Listener, isLBOwner bool, lbName string) error {
+// checkListenerPorts checks if there is conflict for ports. Listeners are
+// identified by protocol and port, so the same port number can be used by
+// both a TCP and a UDP listener.
+func (lbaas *LbaasV2) checkListenerPorts(service *corev1.Service, curListenerMapping map[listenerKey]*listeners.Listener, isLBOwner bool, lbName string, svcConf *serviceConfig) error {
 	for _, svcPort := range service.Spec.Ports {
-		key := listenerKey{Protocol: listeners.Protocol(svcPort.Protocol), Port: int(svcPort.Port)}
+		key := getListenerKey(svcPort, svcConf)
 
 		if listener, isPresent := curListenerMapping[key]; isPresent {
 			// The listener is used by this Service if LB name is in the tags, or
@@ -1618,7 +2146,7 @@ func (lbaas *LbaasV2) checkListenerPorts(service *corev1.Service, curListenerMap
 			if slices.Contains(listener.Tags, lbName) || (len(listener.Tags) == 0 && isLBOwner) {
 				continue
 			} else {
-				return fmt.Errorf("the listener port %d already exists", svcPort.Port)
+				return fmt.Errorf("the listener port %d already exists for protocol %s", svcPort.Port, key.Protocol)
 			}
 		}
 	}
@@ -1633,8 +2161,9 @@ func (lbaas *LbaasV2) updateServiceAnnotation(service *corev1.Service, key, valu
 	service.ObjectMeta.Annotations[key] = value
 }
 
-// createLoadBalancerStatus creates the loadbalancer status from the different possible sources
-func (lbaas *LbaasV2) createLoadBalancerStatus(service *corev1.Service, svcConf *serviceConfig, addr string) *corev1.LoadBalancerStatus {
+// createLoadBalancerStatus creates the loadbalancer status from the different possible sources, the additional
+// addresses are the ones of the additional VIPs of the load balancer.
+func (lbaas *LbaasV2) createLoadBalancerStatus(service *corev1.Service, svcConf *serviceConfig, addr string, additionalAddrs []string) *corev1.LoadBalancerStatus {
 	status := &corev1.LoadBalancerStatus{}
 	// If hostname is explicetly set
 	if hostname := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerLoadbalancerHostname, ""); hostname != "" {
@@ -1661,20 +2190,93 @@ func (lbaas *LbaasV2) createLoadBalancerStatus(service *corev1.Service, svcConf
 		IP:     addr,
 		IPMode: &ipMode,
 	}}
+	for _, additionalAddr := range additionalAddrs {
+		status.Ingress = append(status.Ingress, corev1.LoadBalancerIngress{
+			IP:     additionalAddr,
+			IPMode: &ipMode,
+		})
+	}
 	return status
 }
 
+// parseAdditionalVIPs parses the additional VIPs annotation, a comma-separated list of subnet IDs, each optionally
+// followed by "=" and the IP address of the VIP in the subnet.
+func parseAdditionalVIPs(annotation string) ([]loadbalancers.AdditionalVip, error) {
+	var vips []loadbalancers.AdditionalVip
+	for _, item := range strings.Split(annotation, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		subnetID, ip, _ := strings.Cut(item, "=")
+		subnetID = strings.TrimSpace(subnetID)
+		ip = strings.TrimSpace(ip)
+		if subnetID == "" {
+			return nil, fmt.Errorf("missing subnet ID in %q", item)
+		}
+		if ip != "" && netutils.ParseIPSloppy(ip) == nil {
+			return nil, fmt.Errorf("invalid IP address %q for subnet %s", ip, subnetID)
+		}
+		vips = append(vips, loadbalancers.AdditionalVip{SubnetID: subnetID, IPAddress: ip})
+	}
+	return vips, nil
+}
+
+// parseTLSVersions parses the TLS versions annotation, a comma-separated list of TLS versions. It returns nil when the
+// annotation is empty.
+func parseTLSVersions(annotation string) ([]listeners.TLSVersion, error) {
+	var versions []listeners.TLSVersion
+	for _, item := range cpoutil.SplitTrim(annotation, ',') {
+		version := listeners.TLSVersion(item)
+		switch version {
+		case listeners.TLSVersionSSLv3, listeners.TLSVersionTLSv1, listeners.TLSVersionTLSv1_1, listeners.TLSVersionTLSv1_2, listeners.TLSVersionTLSv1_3:
+			versions = append(versions, version)
+		default:
+			return nil, fmt.Errorf("unknown TLS version %q", item)
+		}
+	}
+	return versions, nil
+}
+
+// tlsVersionStrings returns the TLS versions as strings, as the listeners return them.
+func tlsVersionStrings(versions []listeners.TLSVersion) []string {
+	strs := make([]string, 0, len(versions))
+	for _, version := range versions {
+		strs = append(strs, string(version))
+	}
+	return strs
+}
+
+// getAdditionalVIPAddresses returns the addresses of the additional VIPs of the load balancer.
+func getAdditionalVIPAddresses(loadbalancer *loadbalancers.LoadBalancer) []string {
+	var addrs []string
+	for _, vip := range loadbalancer.AdditionalVips {
+		if vip.IPAddress != "" {
+			addrs = append(addrs, vip.IPAddress)
+		}
+	}
+	return addrs
+}
+
 func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) (lbs *corev1.LoadBalancerStatus, err error) {
 	svcConf := new(serviceConfig)
+	svcConf.savedJournal = getReconcileJournal(service)
 
 	// Update the service annotations(e.g. add loadbalancer.openstack.org/load-balancer-id) in the end if it doesn't exist.
-	patcher := newServicePatcher(lbaas.kclient, service)
-	defer func() { err = patcher.Patch(ctx, err) }()
+	// The Service of a planned reconciliation is left as is.
+	if lbaas.plan == nil {
+		patcher := newServicePatcher(lbaas.kclient, service)
+		defer func() { err = patcher.Patch(ctx, err) }()
+	}
 
 	if err := lbaas.checkService(ctx, service, nodes, svcConf); err != nil {
 		return nil, err
 	}
 
+	if svcConf.includeControlPlaneNodes {
+		nodes = lbaas.addControlPlaneNodes(nodes)
+	}
+
 	// apply node-selector to a list of nodes
 	filteredNodes := filterNodes(nodes, svcConf.nodeSelectors)
 
@@ -1695,12 +2297,16 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 
 		// Here we test for a clusterName that could have had changed in the deployment.
 		if lbHasOldClusterName(loadbalancer, clusterName) {
-			msg := "Loadbalancer %s has a name of %s with incorrect cluster-name component. Renaming it to %s."
-			klog.Infof(msg, loadbalancer.ID, loadbalancer.Name, lbName)
-			lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBRename, msg, loadbalancer.ID, loadbalancer.Name, lbName)
-			loadbalancer, err = renameLoadBalancer(lbaas.lb, loadbalancer, lbName, clusterName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update load balancer %s with an updated name", svcConf.lbID)
+			if lbaas.plan.planned(planActionUpdate, "loadbalancer", loadbalancer.ID, fmt.Sprintf("rename %s to %s", loadbalancer.Name, lbName)) {
+				loadbalancer.Name = lbName
+			} else {
+				msg := "Loadbalancer %s has a name of %s with incorrect cluster-name component. Renaming it to %s."
+				klog.Infof(msg, loadbalancer.ID, loadbalancer.Name, lbName)
+				lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBRename, msg, loadbalancer.ID, loadbalancer.Name, lbName)
+				loadbalancer, err = renameLoadBalancer(lbaas.lb, loadbalancer, lbName, clusterName)
+				if err != nil {
+					return nil, fmt.Errorf("failed to update load balancer %s with an updated name", svcConf.lbID)
+				}
 			}
 		}
 
@@ -1719,24 +2325,59 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		if svcConf.supportLBTags {
 			// The load balancer can only be shared with the configured number of Services.
 			sharedCount := 0
+			otherServices := 0
 			for _, tag := range loadbalancer.Tags {
 				if strings.HasPrefix(tag, servicePrefix) {
 					sharedCount++
+					if tag != lbName {
+						otherServices++
+					}
 				}
 			}
 			if !isLBOwner && !slices.Contains(loadbalancer.Tags, lbName) && sharedCount+1 > lbaas.opts.MaxSharedLB {
 				return nil, fmt.Errorf("load balancer %s already shared with %d Services", loadbalancer.ID, sharedCount)
 			}
 
+			// The tags of a shared load balancer are managed by its owner.
+			if !isLBOwner && len(svcConf.lbTags) > 0 {
+				return nil, fmt.Errorf("annotation %s can only be set on the Service owning the load balancer", ServiceAnnotationLoadBalancerTags)
+			}
+
 			// Internal load balancer cannot be shared to prevent situations when we accidentally expose it because the
 			// owner Service becomes external.
 			if !isLBOwner && svcConf.internal {
 				return nil, fmt.Errorf("internal Service cannot share a load balancer")
 			}
+
+			// The owner Service cannot become internal either while the load balancer is shared, the floating IP the
+			// other Services are exposed with would be removed.
+			if isLBOwner && svcConf.internal && otherServices > 0 {
+				return nil, fmt.Errorf("internal Service cannot own a load balancer shared with %d other Services", otherServices)
+			}
 		}
 	} else {
+		// Resume a reconciliation interrupted before the load balancer ID annotation was saved.
+		loadbalancer, err = lbaas.getJournaledLoadBalancer(service, lbName)
+		if err != nil {
+			return nil, err
+		}
+		if loadbalancer != nil && loadbalancer.ProvisioningStatus == errorStatus {
+			// The interrupted creation failed, delete the load balancer and retry the creation later.
+			if err = lbaas.deleteLoadBalancer(loadbalancer, service, svcConf, true); err != nil {
+				return nil, fmt.Errorf("loadbalancer %s is in ERROR state and there was an error when removing it: %v", loadbalancer.ID, err)
+			}
+			if lbaas.plan != nil {
+				return &corev1.LoadBalancerStatus{}, nil
+			}
+			delete(service.Annotations, ServiceAnnotationLoadBalancerReconcileJournal)
+			return nil, fmt.Errorf("loadbalancer %s has gone into ERROR state, please check Octavia for details. Load balancer was "+
+				"deleted and its creation will be retried", loadbalancer.ID)
+		}
+
 		legacyName := lbaas.getLoadBalancerLegacyName(service)
-		loadbalancer, err = getLoadbalancerByName(lbaas.lb, lbName, legacyName)
+		if loadbalancer == nil {
+			loadbalancer, err = getLoadbalancerByName(lbaas.lb, lbName, legacyName)
+		}
 		if err != nil {
 			if err != cpoerrors.ErrNotFound {
 				return nil, fmt.Errorf("error getting loadbalancer for Service %s: %v", serviceName, err)
@@ -1752,6 +2393,11 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		isLBOwner = true
 	}
 
+	if loadbalancer.ID == "" {
+		// The creation of the load balancer is planned, there is nothing more to plan before it exists.
+		return &corev1.LoadBalancerStatus{}, nil
+	}
+
 	// Make sure LB ID will be saved at this point.
 	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerID, loadbalancer.ID)
 
@@ -1769,45 +2415,23 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 	// This is an existing load balancer, either created by occm for other Services or by the user outside of cluster, or
 	// a newly created, unpopulated loadbalancer that needs populating.
 	if !createNewLB || (lbaas.opts.ProviderRequiresSerialAPICalls && createNewLB) {
-		curListeners := loadbalancer.Listeners
-		curListenerMapping := make(map[listenerKey]*listeners.Listener)
-		for i, l := range curListeners {
-			key := listenerKey{Protocol: listeners.Protocol(l.Protocol), Port: l.ProtocolPort}
-			curListenerMapping[key] = &curListeners[i]
-		}
-		klog.V(4).InfoS("Existing listeners", "portProtocolMapping", curListenerMapping)
-
-		// Check port conflicts
-		if err := lbaas.checkListenerPorts(service, curListenerMapping, isLBOwner, lbName); err != nil {
-			return nil, err
-		}
-
-		for portIndex, port := range service.Spec.Ports {
-			listener, err := lbaas.ensureOctaviaListener(loadbalancer.ID, cpoutil.Sprintf255(listenerFormat, portIndex, lbName), curListenerMapping, port, svcConf)
-			if err != nil {
-				return nil, err
-			}
-
-			pool, err := lbaas.ensureOctaviaPool(loadbalancer.ID, cpoutil.Sprintf255(poolFormat, portIndex, lbName), listener, service, port, filteredNodes, svcConf)
-			if err != nil {
-				return nil, err
-			}
-
-			if err := lbaas.ensureOctaviaHealthMonitor(loadbalancer.ID, cpoutil.Sprintf255(monitorFormat, portIndex, lbName), pool, port, svcConf); err != nil {
+		// A planned reconciliation reports the destructive changes as they are.
+		if !createNewLB && lbaas.plan == nil {
+			if err := lbaas.checkDestructiveChanges(loadbalancer, service, filteredNodes, svcConf, isLBOwner); err != nil {
 				return nil, err
 			}
+		}
 
-			// After all ports have been processed, remaining listeners are removed if they were created by this Service.
-			// The remove of the listener must always happen at the end of the loop to avoid wrong assignment.
-			// Modifying the curListeners would also change the mapping.
-			curListeners = popListener(curListeners, listener.ID)
+		if err := lbaas.ensureOctaviaListeners(loadbalancer, service, filteredNodes, svcConf, isLBOwner); err != nil {
+			return nil, err
 		}
 
-		// Deal with the remaining listeners, delete the listener if it was created by this Service previously.
-		if err := lbaas.deleteOctaviaListeners(loadbalancer.ID, curListeners, isLBOwner, lbName); err != nil {
+		loadbalancer.Listeners, err = openstackutil.GetListenersByLoadBalancerID(lbaas.lb, loadbalancer.ID)
+		if err != nil {
 			return nil, err
 		}
 	}
+	lbaas.persistReconcileStep(ctx, service, svcConf, journalStepListenersEnsured, loadbalancer)
 
 	addr := loadbalancer.VipAddress
 	// IPv6 Load Balancers have no support for Floating IP.
@@ -1815,6 +2439,11 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		msg := "Floating IP not supported for IPv6 Service %s. Using IPv6 address instead %s."
 		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBFloatingIPSkipped, msg, serviceName, addr)
 		klog.Infof(msg, serviceName, addr)
+	} else if svcConf.externalFloatingIP {
+		addr, err = lbaas.ensureExternalFloatingIP(ctx, service, loadbalancer, svcConf)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		addr, err = lbaas.ensureFloatingIP(ctx, clusterName, service, loadbalancer, svcConf, isLBOwner)
 		if err != nil {
@@ -1824,21 +2453,35 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 
 	// save address into the annotation
 	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerAddress, addr)
+	lbaas.persistReconcileStep(ctx, service, svcConf, journalStepFloatingIPEnsured, loadbalancer)
+
+	// The listeners are already in the desired administrative state, a shared load balancer is only managed by its owner.
+	if isLBOwner && svcConf.adminStateUp != nil && *svcConf.adminStateUp != loadbalancer.AdminStateUp {
+		updateOpts := loadbalancers.UpdateOpts{AdminStateUp: svcConf.adminStateUp}
+		if !lbaas.plan.planned(planActionUpdate, "loadbalancer", loadbalancer.ID, describeOpts(updateOpts)) {
+			klog.InfoS("Updating load balancer administrative state", "lbID", loadbalancer.ID, "adminStateUp", *svcConf.adminStateUp)
+			if _, err := openstackutil.UpdateLoadBalancer(lbaas.lb, loadbalancer.ID, updateOpts); err != nil {
+				return nil, fmt.Errorf("failed to update administrative state of load balancer %s: %v", loadbalancer.ID, err)
+			}
+		}
+	}
 
-	// add LB name to load balancer tags.
+	// add LB name and the requested tags to load balancer tags.
 	if svcConf.supportLBTags {
-		lbTags := loadbalancer.Tags
-		if !slices.Contains(lbTags, lbName) {
-			lbTags = append(lbTags, lbName)
+		if lbTags, changed := desiredLoadBalancerTags(service, loadbalancer.Tags, svcConf, isLBOwner); changed &&
+			!lbaas.plan.planned(planActionUpdate, "loadbalancer", loadbalancer.ID, fmt.Sprintf("set tags %s", strings.Join(lbTags, ","))) {
 			klog.InfoS("Updating load balancer tags", "lbID", loadbalancer.ID, "tags", lbTags)
 			if err := openstackutil.UpdateLoadBalancerTags(lbaas.lb, loadbalancer.ID, lbTags); err != nil {
 				return nil, err
 			}
 		}
+		if isLBOwner {
+			lbaas.setAppliedLoadBalancerTags(service, svcConf.lbTags)
+		}
 	}
 
 	// Create status the load balancer
-	status := lbaas.createLoadBalancerStatus(service, svcConf, addr)
+	status := lbaas.createLoadBalancerStatus(service, svcConf, addr, getAdditionalVIPAddresses(loadbalancer))
 
 	if lbaas.opts.ManageSecurityGroups {
 		err := lbaas.ensureAndUpdateOctaviaSecurityGroup(ctx, clusterName, service, filteredNodes, svcConf)
@@ -1853,14 +2496,48 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		}
 	}
 
+	lbaas.clearDestructiveChangesAcknowledgment(service)
+	lbaas.recordReconcileStep(service, journalStepCompleted, loadbalancer.ID, loadbalancer.Listeners)
+
 	return status, nil
 }
 
 // EnsureLoadBalancer creates a new load balancer or updates the existing one.
 func (lbaas *LbaasV2) EnsureLoadBalancer(ctx context.Context, clusterName string, apiService *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
 	mc := metrics.NewMetricContext("loadbalancer", "ensure")
+	sr := metrics.NewServiceReconcile(apiService.Namespace, apiService.Name, "ensure")
+	defer sr.Observe()
+	lbaas = lbaas.withServiceReconcile(sr)
+
 	klog.InfoS("EnsureLoadBalancer", "cluster", clusterName, "service", klog.KObj(apiService))
+	if !lbaas.managesService(apiService) {
+		return nil, cloudprovider.ImplementedElsewhere
+	}
+	if isAttached(apiService) {
+		status, err := lbaas.ensureAttachedMembers(ctx, clusterName, apiService, nodes)
+		lbaas.recordSyncStatus(ctx, apiService, err)
+		return status, mc.ObserveReconcile(err)
+	}
+	if lbaas.isDryRun(apiService) {
+		status, err := lbaas.dryRunOctaviaLoadBalancer(ctx, clusterName, apiService, nodes)
+		return status, mc.ObserveReconcile(err)
+	}
+	if lbaas.isPaused(apiService) {
+		status, err := lbaas.pausedOctaviaLoadBalancer(ctx, clusterName, apiService, nodes)
+		return status, mc.ObserveReconcile(err)
+	}
+	unlock, err := lbaas.lockLoadBalancer(ctx, apiService)
+	if err != nil {
+		return nil, mc.ObserveReconcile(err)
+	}
+	defer unlock()
+	// The members of a Service whose attach annotations were removed are detached before it gets its own load balancer
+	if err := lbaas.ensureAttachedMembersDeleted(ctx, clusterName, apiService, false); err != nil {
+		lbaas.recordSyncStatus(ctx, apiService, err)
+		return nil, mc.ObserveReconcile(err)
+	}
 	status, err := lbaas.ensureOctaviaLoadBalancer(ctx, clusterName, apiService, nodes)
+	lbaas.recordSyncStatus(ctx, apiService, err)
 	return status, mc.ObserveReconcile(err)
 }
 
@@ -1894,6 +2571,10 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 		return err
 	}
 
+	if svcConf.includeControlPlaneNodes {
+		nodes = lbaas.addControlPlaneNodes(nodes)
+	}
+
 	// apply node-selector to a list of nodes
 	filteredNodes := filterNodes(nodes, svcConf.nodeSelectors)
 
@@ -1928,24 +2609,16 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 	// Now, we have a load balancer.
 
 	// Get all listeners for this loadbalancer, by "port&protocol".
-	lbListeners := make(map[listenerKey]listeners.Listener)
-	for _, l := range loadbalancer.Listeners {
-		key := listenerKey{Protocol: listeners.Protocol(l.Protocol), Port: l.ProtocolPort}
-		lbListeners[key] = l
-	}
+	lbListeners := getListenerMapping(loadbalancer.Listeners)
 
 	// Update pool members for each listener.
 	for portIndex, port := range service.Spec.Ports {
-		proto := getListenerProtocol(port.Protocol, svcConf)
-		listener, ok := lbListeners[listenerKey{
-			Protocol: proto,
-			Port:     int(port.Port),
-		}]
+		listener, ok := lbListeners[getListenerKey(port, svcConf)]
 		if !ok {
 			return fmt.Errorf("loadbalancer %s does not contain required listener for port %d and protocol %s", loadbalancer.ID, port.Port, port.Protocol)
 		}
 
-		pool, err := lbaas.ensureOctaviaPool(loadbalancer.ID, cpoutil.Sprintf255(poolFormat, portIndex, loadbalancer.Name), &listener, service, port, filteredNodes, svcConf)
+		pool, err := lbaas.ensureOctaviaPool(loadbalancer.ID, cpoutil.Sprintf255(poolFormat, portIndex, loadbalancer.Name), listener, service, port, filteredNodes, svcConf)
 		if err != nil {
 			return err
 		}
@@ -1972,30 +2645,98 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 // UpdateLoadBalancer updates hosts under the specified load balancer.
 func (lbaas *LbaasV2) UpdateLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) error {
 	mc := metrics.NewMetricContext("loadbalancer", "update")
-	err := lbaas.updateOctaviaLoadBalancer(ctx, clusterName, service, nodes)
+	sr := metrics.NewServiceReconcile(service.Namespace, service.Name, "update")
+	defer sr.Observe()
+	lbaas = lbaas.withServiceReconcile(sr)
+
+	if !lbaas.managesService(service) {
+		return cloudprovider.ImplementedElsewhere
+	}
+	if isAttached(service) {
+		_, err := lbaas.ensureAttachedMembers(ctx, clusterName, service, nodes)
+		lbaas.recordSyncStatus(ctx, service, err)
+		return mc.ObserveReconcile(err)
+	}
+	if lbaas.isDryRun(service) {
+		_, err := lbaas.dryRunOctaviaLoadBalancer(ctx, clusterName, service, nodes)
+		return mc.ObserveReconcile(err)
+	}
+	if lbaas.isPaused(service) {
+		_, err := lbaas.pausedOctaviaLoadBalancer(ctx, clusterName, service, nodes)
+		return mc.ObserveReconcile(err)
+	}
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err != nil {
+		return mc.ObserveReconcile(err)
+	}
+	defer unlock()
+	err = lbaas.updateOctaviaLoadBalancer(ctx, clusterName, service, nodes)
+	lbaas.recordSyncStatus(ctx, service, err)
 	return mc.ObserveReconcile(err)
 }
 
 // EnsureLoadBalancerDeleted deletes the specified load balancer
 func (lbaas *LbaasV2) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *corev1.Service) error {
+	if !lbaas.managesServiceDeletion(service) {
+		klog.V(4).InfoS("Ignoring the deletion of the load balancer of another class", "service", klog.KObj(service))
+		return cloudprovider.ImplementedElsewhere
+	}
+	if lbaas.isDryRun(service) {
+		return lbaas.dryRunLoadBalancerDeletion(ctx, clusterName, service)
+	}
+	if lbaas.isPaused(service) {
+		// The Service keeps its finalizer until the reconciliation is resumed, the load balancer is not orphaned.
+		return fmt.Errorf("reconciliation of Service %s/%s is paused, remove the %s annotation to delete its load balancer",
+			service.Namespace, service.Name, ServiceAnnotationLoadBalancerPaused)
+	}
 	mc := metrics.NewMetricContext("loadbalancer", "delete")
-	err := lbaas.ensureLoadBalancerDeleted(ctx, clusterName, service)
+	sr := metrics.NewServiceReconcile(service.Namespace, service.Name, "delete")
+	lbaas = lbaas.withServiceReconcile(sr)
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err == nil {
+		err = lbaas.ensureAttachedMembersDeleted(ctx, clusterName, service, true)
+		if err == nil && !isAttached(service) {
+			err = lbaas.ensureLoadBalancerDeleted(ctx, clusterName, service)
+		}
+		unlock()
+	}
+	sr.Observe()
+	if err == nil {
+		metrics.ForgetService(service.Namespace, service.Name)
+	}
 	return mc.ObserveReconcile(err)
 }
 
-func (lbaas *LbaasV2) deleteFIPIfCreatedByProvider(ctx context.Context, fip *floatingips.FloatingIP, portID string, service *corev1.Service) (bool, error) {
-	matched, err := regexp.Match("Floating IP for Kubernetes external service", []byte(fip.Description))
-	if err != nil {
-		return false, err
+// withServiceReconcile returns a copy of the load balancer whose Octavia API calls are counted for the reconciliation.
+func (lbaas *LbaasV2) withServiceReconcile(sr *metrics.ServiceReconcile) *LbaasV2 {
+	if lbaas.lb == nil {
+		return lbaas
 	}
 
-	if !matched {
+	lb := *lbaas.lb
+	lb.MoreHeaders = sr.ServiceClientHeaders(lbaas.lb.MoreHeaders)
+	res := *lbaas
+	res.lb = &lb
+
+	return &res
+}
+
+func (lbaas *LbaasV2) deleteFIPIfCreatedByProvider(ctx context.Context, fip *floatingips.FloatingIP, portID string, service *corev1.Service) (bool, error) {
+	if !isFloatingIPCreatedByProvider(fip) {
 		// It's not a FIP created by us, don't touch it.
 		return false, nil
 	}
+	if lbaas.plan.planned(planActionDelete, "floatingip", fip.FloatingIP, "") {
+		return true, nil
+	}
 	klog.InfoS("Deleting floating IP for service", "floatingIP", fip.FloatingIP, "service", klog.KObj(service))
 	mc := metrics.NewMetricContext("floating_ip", "delete")
-	err = floatingips.Delete(ctx, lbaas.network, fip.ID).ExtractErr()
+	err := floatingips.Delete(ctx, lbaas.network, fip.ID).ExtractErr()
+	if cpoerrors.IsNotFound(err) {
+		_ = mc.ObserveRequest(nil)
+		klog.InfoS("Floating IP already deleted", "floatingIP", fip.FloatingIP, "service", klog.KObj(service))
+		return true, nil
+	}
 	if mc.ObserveRequest(err) != nil {
 		return false, fmt.Errorf("failed to delete floating IP %s for loadbalancer VIP port %s: %v", fip.FloatingIP, portID, err)
 	}
@@ -2006,6 +2747,9 @@ func (lbaas *LbaasV2) deleteFIPIfCreatedByProvider(ctx context.Context, fip *flo
 // deleteLoadBalancer removes the LB and its children either by using Octavia cascade deletion or manually
 func (lbaas *LbaasV2) deleteLoadBalancer(loadbalancer *loadbalancers.LoadBalancer, service *corev1.Service, svcConf *serviceConfig, needDeleteLB bool) error {
 	if needDeleteLB && lbaas.opts.CascadeDelete {
+		if lbaas.plan.planned(planActionDelete, "loadbalancer", loadbalancer.ID, "with its children") {
+			return nil
+		}
 		klog.InfoS("Deleting load balancer", "lbID", loadbalancer.ID, "service", klog.KObj(service))
 		if err := openstackutil.DeleteLoadbalancer(lbaas.lb, loadbalancer.ID, true); err != nil {
 			return err
@@ -2015,23 +2759,19 @@ func (lbaas *LbaasV2) deleteLoadBalancer(loadbalancer *loadbalancers.LoadBalance
 		// get all listeners associated with this loadbalancer
 		listenerList, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, loadbalancer.ID)
 		if err != nil {
+			if cpoerrors.IsNotFound(err) {
+				klog.InfoS("Load balancer already deleted", "lbID", loadbalancer.ID, "service", klog.KObj(service))
+				return nil
+			}
 			return fmt.Errorf("error getting LB %s listeners: %v", loadbalancer.ID, err)
 		}
 
 		if !needDeleteLB {
 			var listenersToDelete []listeners.Listener
-			curListenerMapping := make(map[listenerKey]*listeners.Listener)
-			for i, l := range listenerList {
-				key := listenerKey{Protocol: listeners.Protocol(l.Protocol), Port: l.ProtocolPort}
-				curListenerMapping[key] = &listenerList[i]
-			}
+			curListenerMapping := getListenerMapping(listenerList)
 
 			for _, port := range service.Spec.Ports {
-				proto := getListenerProtocol(port.Protocol, svcConf)
-				listener, isPresent := curListenerMapping[listenerKey{
-					Protocol: proto,
-					Port:     int(port.Port),
-				}]
+				listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
 				if isPresent && slices.Contains(listener.Tags, svcConf.lbName) {
 					listenersToDelete = append(listenersToDelete, *listener)
 				}
@@ -2055,6 +2795,9 @@ func (lbaas *LbaasV2) deleteLoadBalancer(loadbalancer *loadbalancers.LoadBalance
 
 		// delete monitors
 		for _, monitorID := range monitorIDs {
+			if lbaas.plan.planned(planActionDelete, "healthmonitor", monitorID, "") {
+				continue
+			}
 			klog.InfoS("Deleting health monitor", "monitorID", monitorID, "lbID", loadbalancer.ID)
 			if err := openstackutil.DeleteHealthMonitor(lbaas.lb, monitorID, loadbalancer.ID); err != nil {
 				return err
@@ -2067,7 +2810,7 @@ func (lbaas *LbaasV2) deleteLoadBalancer(loadbalancer *loadbalancers.LoadBalance
 			return err
 		}
 
-		if needDeleteLB {
+		if needDeleteLB && !lbaas.plan.planned(planActionDelete, "loadbalancer", loadbalancer.ID, "") {
 			// delete the loadbalancer in old way, i.e. no cascading.
 			klog.InfoS("Deleting load balancer", "lbID", loadbalancer.ID, "service", klog.KObj(service))
 			if err := openstackutil.DeleteLoadbalancer(lbaas.lb, loadbalancer.ID, false); err != nil {
@@ -2099,13 +2842,18 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 		loadbalancer, err = openstackutil.GetLoadbalancerByID(lbaas.lb, svcConf.lbID)
 	} else {
 		// This may happen when this Service creation was failed previously.
-		loadbalancer, err = getLoadbalancerByName(lbaas.lb, lbName, legacyName)
+		loadbalancer, err = lbaas.getJournaledLoadBalancer(service, lbName)
+		if err == nil && loadbalancer == nil {
+			loadbalancer, err = getLoadbalancerByName(lbaas.lb, lbName, legacyName)
+		}
 	}
 	if err != nil && !cpoerrors.IsNotFound(err) {
 		return err
 	}
 	if loadbalancer == nil {
-		return nil
+		// The load balancer may have been deleted out-of-band, clean up what it left behind.
+		klog.InfoS("Load balancer not found, cleaning up the resources of the Service", "service", klog.KObj(service), "lbID", svcConf.lbID)
+		return lbaas.cleanupDeletedLoadBalancer(ctx, clusterName, service)
 	}
 
 	if loadbalancer.ProvisioningStatus != activeStatus && loadbalancer.ProvisioningStatus != errorStatus {
@@ -2157,11 +2905,12 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 		return err
 	}
 
-	// Remove the Service's tag from the load balancer.
+	// Remove the Service's tag and the tags it applied from the load balancer.
 	if !needDeleteLB && updateLBTag {
 		var newTags []string
+		applied := getAppliedLoadBalancerTags(service)
 		for _, tag := range loadbalancer.Tags {
-			if tag != lbName {
+			if tag != lbName && !slices.Contains(applied, tag) {
 				newTags = append(newTags, tag)
 			}
 		}
@@ -2169,11 +2918,13 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 		if len(newTags) == 0 {
 			newTags = []string{""}
 		}
-		klog.InfoS("Updating load balancer tags", "lbID", loadbalancer.ID, "tags", newTags)
-		if err := openstackutil.UpdateLoadBalancerTags(lbaas.lb, loadbalancer.ID, newTags); err != nil {
-			return err
+		if !lbaas.plan.planned(planActionUpdate, "loadbalancer", loadbalancer.ID, fmt.Sprintf("set tags %s", strings.Join(newTags, ","))) {
+			klog.InfoS("Updating load balancer tags", "lbID", loadbalancer.ID, "tags", newTags)
+			if err := openstackutil.UpdateLoadBalancerTags(lbaas.lb, loadbalancer.ID, newTags); err != nil && !cpoerrors.IsNotFound(err) {
+				return err
+			}
+			klog.InfoS("Updated load balancer tags", "lbID", loadbalancer.ID)
 		}
-		klog.InfoS("Updated load balancer tags", "lbID", loadbalancer.ID)
 	}
 
 	// Delete the Security Group. We're doing that even if `manage-security-groups` is disabled to make sure we don't
@@ -2182,9 +2933,59 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 		return err
 	}
 
+	lbaas.removeLoadBalancerAnnotations(ctx, service, needDeleteLB)
+
+	return nil
+}
+
+// cleanupDeletedLoadBalancer deletes the resources left behind by a load balancer deleted out-of-band: the floating IP
+// created for the Service, detached from the deleted VIP port, and the security group.
+func (lbaas *LbaasV2) cleanupDeletedLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service) error {
+	if !getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerKeepFloatingIP, false) {
+		serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+		fip, err := lbaas.getDetachedFloatingIP(ctx, clusterName, serviceName, "")
+		if err != nil {
+			return err
+		}
+		if fip != nil {
+			if _, err := lbaas.deleteFIPIfCreatedByProvider(ctx, fip, "", service); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := lbaas.ensureSecurityGroupDeleted(ctx, service); err != nil {
+		return err
+	}
+
+	lbaas.removeLoadBalancerAnnotations(ctx, service, true)
+
 	return nil
 }
 
+// removeLoadBalancerAnnotations removes the annotations occm set on the Service for its load balancer, the ID only
+// when the load balancer is gone. A failure, e.g. when the Service is already deleted, is only logged.
+func (lbaas *LbaasV2) removeLoadBalancerAnnotations(ctx context.Context, service *corev1.Service, lbGone bool) {
+	if lbaas.kclient == nil || lbaas.plan != nil {
+		return
+	}
+
+	updated := service.DeepCopy()
+	delete(updated.Annotations, ServiceAnnotationLoadBalancerAddress)
+	delete(updated.Annotations, ServiceAnnotationLoadBalancerReconcileJournal)
+	if lbGone {
+		delete(updated.Annotations, ServiceAnnotationLoadBalancerID)
+		delete(updated.Annotations, ServiceAnnotationLoadBalancerLastSuccessfulSync)
+	}
+
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, service, updated); err != nil {
+		klog.Warningf("Failed to remove the load balancer annotations of Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	if lbGone {
+		lbaas.removeSyncStatus(ctx, service)
+	}
+}
+
 // GetLoadBalancerSourceRanges first try to parse and verify LoadBalancerSourceRanges field from a service.
 // If the field is not specified, turn to parse and verify the AnnotationLoadBalancerSourceRangesKey annotation from a service,
 // extracting the source ranges to allow, and if not present returns a default (allow-all) value.
@@ -2270,6 +3071,52 @@ func PreserveGopherError(rawError error) error {
 	return rawError
 }
 
+// addControlPlaneNodes adds the ready control-plane nodes, which are excluded from the nodes given to the cloud
+// provider, to the nodes
+func (lbaas *LbaasV2) addControlPlaneNodes(nodes []*corev1.Node) []*corev1.Node {
+	if lbaas.nodeLister == nil {
+		klog.Warningf("Node informer is not initialized, control-plane nodes can't be added to the load balancer")
+		return nodes
+	}
+
+	allNodes, err := lbaas.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list nodes, control-plane nodes won't be added to the load balancer: %v", err)
+		return nodes
+	}
+
+	names := sets.New[string]()
+	for _, node := range nodes {
+		names.Insert(node.Name)
+	}
+
+	result := slices.Clone(nodes)
+	for _, node := range allNodes {
+		if names.Has(node.Name) || !isControlPlaneNode(node) || !isNodeReady(node) || node.DeletionTimestamp != nil {
+			continue
+		}
+		klog.V(4).Infof("Adding control-plane node %s to the load balancer nodes", node.Name)
+		result = append(result, node)
+	}
+
+	return result
+}
+
+func isControlPlaneNode(node *corev1.Node) bool {
+	_, controlPlane := node.Labels[labelNodeRoleControlPlane]
+	_, master := node.Labels[labelNodeRoleMaster]
+	return controlPlane || master
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // filterNodes uses node labels to filter the nodes that should be targeted by the LB,
 // ensuring that all the labels provided in an annotation are present on the nodes
 func filterNodes(nodes []*corev1.Node, filterLabels map[string]string) []*corev1.Node {
@@ -0,0 +1,425 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+const (
+	// ServiceAnnotationLoadBalancerAttachPoolIDs is the comma-separated list of <port>=<pool ID> pairs attaching the
+	// Service ports to existing pools managed outside of Kubernetes. Only the members of the nodes are managed, no load
+	// balancer, listener, pool or floating IP is created for the Service.
+	ServiceAnnotationLoadBalancerAttachPoolIDs = "loadbalancer.openstack.org/attach-pool-ids"
+	// ServiceAnnotationLoadBalancerAttachListenerIDs is the comma-separated list of <port>=<listener ID> pairs attaching
+	// the Service ports to the default pools of existing listeners managed outside of Kubernetes.
+	ServiceAnnotationLoadBalancerAttachListenerIDs = "loadbalancer.openstack.org/attach-listener-ids"
+	// ServiceAnnotationLoadBalancerAttachedPoolIDs is set by the OCCM to the comma-separated list of the pools the
+	// members of the Service are attached to, they're detached from them once the Service or its attach annotations are
+	// removed.
+	ServiceAnnotationLoadBalancerAttachedPoolIDs = "loadbalancer.openstack.org/attached-pool-ids"
+)
+
+// attachedPool is an existing pool the members of a Service port are attached to.
+type attachedPool struct {
+	port corev1.ServicePort
+	pool *v2pools.Pool
+	lbID string
+}
+
+// isAttached checks whether the Service ports are attached to existing pools instead of a load balancer of its own.
+func isAttached(service *corev1.Service) bool {
+	return getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAttachPoolIDs, "") != "" ||
+		getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAttachListenerIDs, "") != ""
+}
+
+// parseAttachIDs parses the <port>=<ID> pairs of an attach annotation.
+func parseAttachIDs(service *corev1.Service, annotation string) (map[int32]string, error) {
+	ids := make(map[int32]string)
+	for port, id := range getKeyValueFromServiceAnnotation(service, annotation, "") {
+		p, err := strconv.ParseInt(port, 10, 32)
+		if err != nil || id == "" {
+			return nil, fmt.Errorf("invalid annotation %s, expected <port>=<ID> pairs: %q", annotation, port)
+		}
+		ids[int32(p)] = id
+	}
+	return ids, nil
+}
+
+// getAttachedPools returns the existing pools the Service ports are attached to. Every port must be attached to one
+// pool, directly or through the default pool of a listener.
+func (lbaas *LbaasV2) getAttachedPools(service *corev1.Service) ([]attachedPool, error) {
+	poolIDs, err := parseAttachIDs(service, ServiceAnnotationLoadBalancerAttachPoolIDs)
+	if err != nil {
+		return nil, err
+	}
+	listenerIDs, err := parseAttachIDs(service, ServiceAnnotationLoadBalancerAttachListenerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var attached []attachedPool
+	for _, port := range service.Spec.Ports {
+		poolID, hasPool := poolIDs[port.Port]
+		listenerID, hasListener := listenerIDs[port.Port]
+		switch {
+		case hasPool && hasListener:
+			return nil, fmt.Errorf("port %d is attached to both pool %s and listener %s", port.Port, poolID, listenerID)
+		case hasListener:
+			listener, err := openstackutil.GetListenerByID(lbaas.lb, listenerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get listener %s of port %d: %w", listenerID, port.Port, err)
+			}
+			if listener.DefaultPoolID == "" {
+				return nil, fmt.Errorf("listener %s of port %d has no default pool", listenerID, port.Port)
+			}
+			poolID = listener.DefaultPoolID
+		case !hasPool:
+			return nil, fmt.Errorf("port %d is not attached to any pool or listener, set the %s or the %s annotation",
+				port.Port, ServiceAnnotationLoadBalancerAttachPoolIDs, ServiceAnnotationLoadBalancerAttachListenerIDs)
+		}
+
+		pool, err := openstackutil.GetPoolByID(lbaas.lb, poolID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pool %s of port %d: %w", poolID, port.Port, err)
+		}
+		if len(pool.Loadbalancers) == 0 {
+			return nil, fmt.Errorf("pool %s of port %d has no load balancer", poolID, port.Port)
+		}
+		lbID := pool.Loadbalancers[0].ID
+		if err := lbaas.checkAttachAllowed(lbID); err != nil {
+			return nil, fmt.Errorf("pool %s of port %d: %w", poolID, port.Port, err)
+		}
+		attached = append(attached, attachedPool{port: port, pool: pool, lbID: lbID})
+	}
+
+	return attached, nil
+}
+
+// checkAttachAllowed checks whether the operator allows the Services to attach their members to the pools of the load
+// balancer, by its ID or by one of its tags. Everything else is refused, so that the users able to annotate a Service
+// can't add their nodes to any pool of the project.
+func (lbaas *LbaasV2) checkAttachAllowed(lbID string) error {
+	if slices.Contains(lbaas.opts.AttachAllowedLoadBalancerIDs, lbID) {
+		return nil
+	}
+	if len(lbaas.opts.AttachAllowedTags) > 0 {
+		lb, err := openstackutil.GetLoadbalancerByID(lbaas.lb, lbID)
+		if err != nil {
+			return fmt.Errorf("failed to get load balancer %s: %w", lbID, err)
+		}
+		for _, tag := range lb.Tags {
+			if slices.Contains(lbaas.opts.AttachAllowedTags, tag) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("attaching to load balancer %s is not allowed by the attach-allowed-load-balancer-id and attach-allowed-tag options", lbID)
+}
+
+// getRecordedAttachedPoolIDs returns the pools the members of the Service were attached to by the last reconciliations.
+func getRecordedAttachedPoolIDs(service *corev1.Service) sets.Set[string] {
+	ids := sets.New[string]()
+	for _, id := range strings.Split(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAttachedPoolIDs, ""), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids.Insert(id)
+		}
+	}
+	return ids
+}
+
+// recordAttachedPools saves the pools the members of the Service are attached to right away, so that they're detached
+// even if the reconciliation stops before its end.
+func (lbaas *LbaasV2) recordAttachedPools(ctx context.Context, service *corev1.Service, poolIDs sets.Set[string]) error {
+	if getRecordedAttachedPoolIDs(service).Equal(poolIDs) {
+		return nil
+	}
+
+	base := service.DeepCopy()
+	if poolIDs.Len() == 0 {
+		delete(service.Annotations, ServiceAnnotationLoadBalancerAttachedPoolIDs)
+	} else {
+		lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerAttachedPoolIDs, strings.Join(sets.List(poolIDs), ","))
+	}
+
+	if lbaas.kclient == nil || lbaas.plan != nil {
+		return nil
+	}
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, base, service); err != nil {
+		return fmt.Errorf("failed to record the attached pools of Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	return nil
+}
+
+// attachedMemberName returns the name of the member of the node attached by the Service, it identifies the members
+// the Service owns among the other members of the pool.
+func attachedMemberName(lbName, nodeName string) string {
+	return cpoutil.Sprintf255("%s_%s", lbName, nodeName)
+}
+
+// isAttachedMember checks whether the member was attached by the Service.
+func isAttachedMember(lbName string, member v2pools.Member) bool {
+	return strings.HasPrefix(member.Name, lbName+"_")
+}
+
+// ensureAttachedMembers attaches the nodes as members of the existing pools of the Service ports, and detaches the ones
+// gone. The members of the pools not attached by the Service are left as they are. The load balancer the Service had
+// before it was attached is deleted.
+func (lbaas *LbaasV2) ensureAttachedMembers(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
+	if lbaas.isDryRun(service) || lbaas.isPaused(service) {
+		return nil, fmt.Errorf("annotations %s and %s are not supported along with %s or %s", ServiceAnnotationLoadBalancerDryRun,
+			ServiceAnnotationLoadBalancerPaused, ServiceAnnotationLoadBalancerAttachPoolIDs, ServiceAnnotationLoadBalancerAttachListenerIDs)
+	}
+
+	lbName := lbaas.GetLoadBalancerName(ctx, clusterName, service)
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	svcConf := new(serviceConfig)
+	if err := lbaas.checkServiceUpdate(ctx, service, nodes, svcConf); err != nil {
+		return nil, err
+	}
+	if svcConf.includeControlPlaneNodes {
+		nodes = lbaas.addControlPlaneNodes(nodes)
+	}
+	filteredNodes := filterNodes(nodes, svcConf.nodeSelectors)
+
+	attached, err := lbaas.getAttachedPools(service)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lbaas.ensureOwnLoadBalancerDeleted(ctx, clusterName, service); err != nil {
+		return nil, err
+	}
+
+	recorded := getRecordedAttachedPoolIDs(service)
+	poolIDs := sets.New[string]()
+	for _, a := range attached {
+		poolIDs.Insert(a.pool.ID)
+	}
+	if err := lbaas.recordAttachedPools(ctx, service, recorded.Union(poolIDs)); err != nil {
+		return nil, err
+	}
+
+	for _, a := range attached {
+		members, _, err := lbaas.buildCreateMemberOpts(a.port, filteredNodes, svcConf)
+		if err != nil {
+			return nil, err
+		}
+		if err := lbaas.updateAttachedMembers(lbName, a, members); err != nil {
+			return nil, err
+		}
+	}
+
+	// The pools the Service is not attached to anymore
+	if err := lbaas.detachMembers(lbName, recorded.Difference(poolIDs)); err != nil {
+		return nil, err
+	}
+	if err := lbaas.recordAttachedPools(ctx, service, poolIDs); err != nil {
+		return nil, err
+	}
+
+	if lbaas.opts.ManageSecurityGroups {
+		if err := lbaas.ensureAndUpdateOctaviaSecurityGroup(ctx, clusterName, service, filteredNodes, svcConf); err != nil {
+			return nil, fmt.Errorf("failed to update Security Group for attached service %s/%s: %v", service.Namespace, service.Name, err)
+		}
+	} else if err := lbaas.ensureSecurityGroupDeleted(ctx, service); err != nil {
+		return nil, err
+	}
+
+	return lbaas.getAttachedStatus(ctx, attached)
+}
+
+// ensureOwnLoadBalancerDeleted deletes the load balancer of the Service, or its listeners of a shared one, when it gets
+// attached to existing pools.
+func (lbaas *LbaasV2) ensureOwnLoadBalancerDeleted(ctx context.Context, clusterName string, service *corev1.Service) error {
+	lbID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "")
+	if lbID == "" {
+		return nil
+	}
+
+	klog.InfoS("Deleting the load balancer of the Service attached to existing pools", "service", klog.KObj(service), "lbID", lbID)
+	if err := lbaas.ensureLoadBalancerDeleted(ctx, clusterName, service); err != nil {
+		return err
+	}
+
+	base := service.DeepCopy()
+	delete(service.Annotations, ServiceAnnotationLoadBalancerID)
+	delete(service.Annotations, ServiceAnnotationLoadBalancerAddress)
+	delete(service.Annotations, ServiceAnnotationLoadBalancerReconcileJournal)
+	if lbaas.kclient == nil {
+		return nil
+	}
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, base, service); err != nil {
+		return fmt.Errorf("failed to remove the load balancer of Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	return nil
+}
+
+// detachMembers deletes the members attached by the Service from the pools, the pools already gone are skipped.
+func (lbaas *LbaasV2) detachMembers(lbName string, poolIDs sets.Set[string]) error {
+	for _, poolID := range sets.List(poolIDs) {
+		pool, err := openstackutil.GetPoolByID(lbaas.lb, poolID)
+		if err != nil {
+			if cpoerrors.IsNotFound(err) {
+				klog.InfoS("Attached pool already deleted", "poolID", poolID)
+				continue
+			}
+			return fmt.Errorf("failed to get attached pool %s: %w", poolID, err)
+		}
+		if len(pool.Loadbalancers) == 0 {
+			continue
+		}
+		if err := lbaas.updateAttachedMembers(lbName, attachedPool{pool: pool, lbID: pool.Loadbalancers[0].ID}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateAttachedMembers creates the members of the pool missing and deletes the ones attached by the Service not
+// desired anymore, the changed members are recreated.
+func (lbaas *LbaasV2) updateAttachedMembers(lbName string, a attachedPool, members []v2pools.CreateMemberOpts) error {
+	existing, err := openstackutil.GetMembersbyPool(lbaas.lb, a.pool.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get members of pool %s: %v", a.pool.ID, err)
+	}
+
+	desired := make(map[string]v2pools.CreateMemberOpts, len(members))
+	for _, m := range members {
+		m.Name = attachedMemberName(lbName, m.Name)
+		desired[m.Name] = m
+	}
+
+	for _, member := range existing {
+		if !isAttachedMember(lbName, member) {
+			continue
+		}
+		m, ok := desired[member.Name]
+		if ok && m.Address == member.Address && m.ProtocolPort == member.ProtocolPort && ptr.Deref(m.Weight, 1) == member.Weight {
+			delete(desired, member.Name)
+			continue
+		}
+		if lbaas.plan.planned(planActionDelete, "member", member.ID, fmt.Sprintf("%s of pool %s", member.Name, a.pool.ID)) {
+			continue
+		}
+		klog.InfoS("Deleting attached member", "member", member.Name, "poolID", a.pool.ID, "lbID", a.lbID)
+		if err := openstackutil.DeleteMember(lbaas.lb, a.lbID, a.pool.ID, member.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range desired {
+		if lbaas.plan.planned(planActionCreate, "member", m.Name, fmt.Sprintf("%s in pool %s", m.Address, a.pool.ID)) {
+			continue
+		}
+		klog.InfoS("Creating attached member", "member", m.Name, "address", m.Address, "poolID", a.pool.ID, "lbID", a.lbID)
+		if _, err := openstackutil.CreateMember(lbaas.lb, a.lbID, a.pool.ID, m); err != nil {
+			return fmt.Errorf("failed to create member %s of pool %s: %v", m.Name, a.pool.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// getAttachedStatus returns the addresses of the load balancers of the attached pools, their floating IP when they
+// have one or else their VIP address.
+func (lbaas *LbaasV2) getAttachedStatus(ctx context.Context, attached []attachedPool) (*corev1.LoadBalancerStatus, error) {
+	status := &corev1.LoadBalancerStatus{}
+	seen := make(map[string]bool)
+	for _, a := range attached {
+		if seen[a.lbID] {
+			continue
+		}
+		seen[a.lbID] = true
+
+		lb, err := openstackutil.GetLoadbalancerByID(lbaas.lb, a.lbID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get load balancer %s: %v", a.lbID, err)
+		}
+		addr := lb.VipAddress
+		floatIP, err := openstackutil.GetFloatingIPByPortID(ctx, lbaas.network, lb.VipPortID)
+		if err != nil {
+			return nil, fmt.Errorf("failed when getting floating IP for port %s: %v", lb.VipPortID, err)
+		}
+		if floatIP != nil {
+			addr = floatIP.FloatingIP
+		}
+		status.Ingress = append(status.Ingress, corev1.LoadBalancerIngress{IP: addr})
+	}
+
+	return status, nil
+}
+
+// ensureAttachedMembersDeleted detaches the members of the Service from the pools recorded by the last
+// reconciliations, whether the Service is deleted or its attach annotations are removed. The security group of the
+// members is deleted unless the Service gets its own load balancer, which reuses it.
+func (lbaas *LbaasV2) ensureAttachedMembersDeleted(ctx context.Context, clusterName string, service *corev1.Service, deleteSecurityGroup bool) error {
+	recorded := getRecordedAttachedPoolIDs(service)
+	if recorded.Len() == 0 {
+		return nil
+	}
+
+	klog.InfoS("Detaching the members of the Service", "service", klog.KObj(service), "poolIDs", sets.List(recorded))
+	if err := lbaas.detachMembers(lbaas.GetLoadBalancerName(ctx, clusterName, service), recorded); err != nil {
+		return err
+	}
+	if deleteSecurityGroup {
+		if err := lbaas.ensureSecurityGroupDeleted(ctx, service); err != nil {
+			return err
+		}
+	}
+
+	return lbaas.recordAttachedPools(ctx, service, nil)
+}
+
+// getAttachedLoadBalancer returns the status of the load balancers of the pools the Service ports are attached to,
+// whether they exist.
+func (lbaas *LbaasV2) getAttachedLoadBalancer(ctx context.Context, service *corev1.Service) (*corev1.LoadBalancerStatus, bool, error) {
+	attached, err := lbaas.getAttachedPools(service)
+	if err != nil {
+		if cpoerrors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	status, err := lbaas.getAttachedStatus(ctx, attached)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return status, true, nil
+}
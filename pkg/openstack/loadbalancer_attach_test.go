@@ -0,0 +1,283 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeAttachedPool struct {
+	lbTags  []string
+	members []v2pools.Member
+	created []string
+	deleted []string
+}
+
+func (f *fakeAttachedPool) register(t *testing.T) {
+	mockLoadBalancer(t, loadbalancers.LoadBalancer{ID: "lb-id", VipAddress: "10.0.0.10", Tags: f.lbTags})
+	th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"listener": listeners.Listener{ID: "listener-id", DefaultPoolID: "pool-id"}})
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool-id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"pool": v2pools.Pool{
+			ID: "pool-id", Loadbalancers: []v2pools.LoadBalancerID{{ID: "lb-id"}},
+		}})
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool-id/members", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"members": f.members})
+		case http.MethodPost:
+			var body struct {
+				Member v2pools.Member `json:"member"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode member create request: %v", err)
+			}
+			f.created = append(f.created, body.Member.Name)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"member": body.Member})
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool-id/members/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		f.deleted = append(f.deleted, strings.TrimPrefix(r.URL.Path, "/lbaas/pools/pool-id/members/"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func TestLbaasV2_getAttachedPools(t *testing.T) {
+	allowedIDs := LoadBalancerOpts{AttachAllowedLoadBalancerIDs: []string{"other-lb-id", "lb-id"}}
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		opts        *LoadBalancerOpts
+		lbTags      []string
+		expectedErr bool
+	}{
+		{
+			name:        "attached to a pool",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "80=pool-id"},
+		},
+		{
+			name:        "attached to a pool of an allowed tag",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "80=pool-id"},
+			opts:        &LoadBalancerOpts{AttachAllowedTags: []string{"shared-with-k8s"}},
+			lbTags:      []string{"prod", "shared-with-k8s"},
+		},
+		{
+			name:        "attaching not allowed",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "80=pool-id"},
+			opts:        &LoadBalancerOpts{},
+			expectedErr: true,
+		},
+		{
+			name:        "load balancer not allowed",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "80=pool-id"},
+			opts:        &LoadBalancerOpts{AttachAllowedLoadBalancerIDs: []string{"other-lb-id"}, AttachAllowedTags: []string{"shared-with-k8s"}},
+			lbTags:      []string{"prod"},
+			expectedErr: true,
+		},
+		{
+			name:        "attached to a listener",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachListenerIDs: "80=listener-id"},
+		},
+		{
+			name:        "port not attached",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "443=pool-id"},
+			expectedErr: true,
+		},
+		{
+			name: "port attached twice",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAttachPoolIDs:     "80=pool-id",
+				ServiceAnnotationLoadBalancerAttachListenerIDs: "80=listener-id",
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid port",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerAttachPoolIDs: "http=pool-id"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			fake := &fakeAttachedPool{lbTags: tc.lbTags}
+			fake.register(t)
+
+			opts := allowedIDs
+			if tc.opts != nil {
+				opts = *tc.opts
+			}
+			lbaas := &LbaasV2{
+				LoadBalancer{
+					lb:   fakeclient.ServiceClient(),
+					opts: opts,
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: tc.annotations},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, NodePort: 30080}}},
+			}
+
+			assert.True(t, isAttached(service))
+			attached, err := lbaas.getAttachedPools(service)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, attached, 1)
+			assert.Equal(t, "pool-id", attached[0].pool.ID)
+			assert.Equal(t, "lb-id", attached[0].lbID)
+		})
+	}
+}
+
+func TestLbaasV2_updateAttachedMembers(t *testing.T) {
+	const lbName = "kube_service_cluster_default_svc"
+
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	fake := &fakeAttachedPool{
+		members: []v2pools.Member{
+			{ID: "foreign", Name: "web-1", Address: "10.0.1.1", ProtocolPort: 8080, Weight: 1},
+			{ID: "unchanged", Name: lbName + "_node-1", Address: "10.0.0.1", ProtocolPort: 30080, Weight: 1},
+			{ID: "changed", Name: lbName + "_node-2", Address: "10.0.0.2", ProtocolPort: 30081, Weight: 1},
+			{ID: "gone", Name: lbName + "_node-3", Address: "10.0.0.3", ProtocolPort: 30080, Weight: 1},
+		},
+	}
+	fake.register(t)
+
+	lbaas := &LbaasV2{
+		LoadBalancer{
+			lb: fakeclient.ServiceClient(),
+		},
+	}
+	a := attachedPool{pool: &v2pools.Pool{ID: "pool-id"}, lbID: "lb-id"}
+	members := []v2pools.CreateMemberOpts{
+		{Name: "node-1", Address: "10.0.0.1", ProtocolPort: 30080},
+		{Name: "node-2", Address: "10.0.0.2", ProtocolPort: 30080},
+		{Name: "node-4", Address: "10.0.0.4", ProtocolPort: 30080},
+	}
+
+	err := lbaas.updateAttachedMembers(lbName, a, members)
+	assert.NoError(t, err)
+	sort.Strings(fake.created)
+	assert.Equal(t, []string{lbName + "_node-2", lbName + "_node-4"}, fake.created)
+	assert.Equal(t, []string{"changed", "gone"}, fake.deleted)
+
+	// Detaching deletes only the members of the Service
+	fake.created, fake.deleted = nil, nil
+	err = lbaas.updateAttachedMembers(lbName, a, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, fake.created)
+	assert.Equal(t, []string{"unchanged", "changed", "gone"}, fake.deleted)
+}
+
+func TestLbaasV2_ensureAttachedMembersDeleted(t *testing.T) {
+	const lbName = "kube_service_cluster_default_svc"
+
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	pools := &fakeAttachedPool{
+		members: []v2pools.Member{
+			{ID: "foreign", Name: "web-1", Address: "10.0.1.1", ProtocolPort: 8080, Weight: 1},
+			{ID: "attached", Name: lbName + "_node-1", Address: "10.0.0.1", ProtocolPort: 30080, Weight: 1},
+		},
+	}
+	pools.register(t)
+
+	// The attach annotations were removed, the members are detached from the recorded pools, the deleted ones skipped
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{
+			ServiceAnnotationLoadBalancerAttachedPoolIDs: "pool-id,deleted-pool-id",
+		}},
+	}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := &LbaasV2{
+		LoadBalancer{
+			lb:      fakeclient.ServiceClient(),
+			kclient: kclient,
+		},
+	}
+
+	err := lbaas.ensureAttachedMembersDeleted(context.TODO(), "cluster", service, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"attached"}, pools.deleted)
+	assert.NotContains(t, service.Annotations, ServiceAnnotationLoadBalancerAttachedPoolIDs)
+	updated, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, updated.Annotations, ServiceAnnotationLoadBalancerAttachedPoolIDs)
+
+	// Nothing is detached from the pools of the Services never attached
+	pools.deleted = nil
+	err = lbaas.ensureAttachedMembersDeleted(context.TODO(), "cluster", service, false)
+	assert.NoError(t, err)
+	assert.Empty(t, pools.deleted)
+}
+
+func TestRecordAttachedPools(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := &LbaasV2{LoadBalancer{kclient: kclient}}
+
+	err := lbaas.recordAttachedPools(context.TODO(), service, sets.New("pool-2", "pool-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "pool-1,pool-2", service.Annotations[ServiceAnnotationLoadBalancerAttachedPoolIDs])
+	assert.Equal(t, sets.New("pool-1", "pool-2"), getRecordedAttachedPoolIDs(service))
+	updated, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "pool-1,pool-2", updated.Annotations[ServiceAnnotationLoadBalancerAttachedPoolIDs])
+
+	err = lbaas.recordAttachedPools(context.TODO(), service, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, getRecordedAttachedPoolIDs(service))
+	updated, err = kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, updated.Annotations, ServiceAnnotationLoadBalancerAttachedPoolIDs)
+}
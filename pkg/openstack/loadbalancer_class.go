@@ -0,0 +1,313 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	servicehelper "k8s.io/cloud-provider/service/helpers"
+	"k8s.io/klog/v2"
+
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+const (
+	// loadBalancerClassFinalizer protects the load balancers of the Services of the load balancer class, the
+	// finalizer of the service controller can't be used as the service controller would delete their load balancers.
+	loadBalancerClassFinalizer = "loadbalancer.openstack.org/load-balancer-cleanup"
+
+	// toBeDeletedTaint is the taint of the nodes being removed by the cluster autoscaler.
+	toBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+
+	// serviceControllerFinalizer protects the load balancers of the Services managed by the service controller.
+	serviceControllerFinalizer = "service.kubernetes.io/load-balancer-cleanup"
+)
+
+// managesService returns whether the load balancer of the Service is managed by this OCCM. Without
+// service-load-balancer-class, the Services without spec.loadBalancerClass are managed by the service controller,
+// otherwise only the Services of the class are managed, by the load balancer class controller.
+func (lbaas *LbaasV2) managesService(service *corev1.Service) bool {
+	if lbaas.opts.ServiceLoadBalancerClass == "" {
+		return service.Spec.LoadBalancerClass == nil
+	}
+	return service.Spec.LoadBalancerClass != nil && *service.Spec.LoadBalancerClass == lbaas.opts.ServiceLoadBalancerClass
+}
+
+// managesServiceDeletion returns whether the load balancer of the Service being deleted, or not of type LoadBalancer
+// anymore, is managed by this OCCM. The spec.loadBalancerClass is cleared when the type of a Service changes, the
+// Services of the class are then recognized by the finalizer of the load balancer class controller, or by the load
+// balancer ID annotation when the service controller doesn't protect them.
+func (lbaas *LbaasV2) managesServiceDeletion(service *corev1.Service) bool {
+	if lbaas.managesService(service) || slices.Contains(service.Finalizers, loadBalancerClassFinalizer) {
+		return true
+	}
+	return service.Spec.LoadBalancerClass == nil && !slices.Contains(service.Finalizers, serviceControllerFinalizer) &&
+		getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "") != ""
+}
+
+// isLoadBalancerDeleted returns whether the load balancer of the Service is to be deleted.
+func isLoadBalancerDeleted(service *corev1.Service) bool {
+	return service.DeletionTimestamp != nil || service.Spec.Type != corev1.ServiceTypeLoadBalancer
+}
+
+// loadBalancerClassController reconciles the load balancers of the Services of the service-load-balancer-class, the
+// service controller ignores the Services having a spec.loadBalancerClass.
+type loadBalancerClassController struct {
+	lbaas         *LbaasV2
+	clusterName   string
+	serviceLister corelisters.ServiceLister
+	nodeLister    corelisters.NodeLister
+	listersSynced []cache.InformerSynced
+	queue         workqueue.TypedRateLimitingInterface[string]
+}
+
+func newLoadBalancerClassController(lbaas *LbaasV2, clusterName string, serviceInformer coreinformers.ServiceInformer, nodeInformer coreinformers.NodeInformer) *loadBalancerClassController {
+	c := &loadBalancerClassController{
+		lbaas:         lbaas,
+		clusterName:   clusterName,
+		serviceLister: serviceInformer.Lister(),
+		nodeLister:    nodeInformer.Lister(),
+		listersSynced: []cache.InformerSynced{
+			serviceInformer.Informer().HasSynced,
+			nodeInformer.Informer().HasSynced,
+		},
+		queue: workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+	}
+
+	_, err := serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueService,
+		UpdateFunc: func(_, new interface{}) {
+			c.enqueueService(new)
+		},
+	})
+	if err != nil {
+		klog.Errorf("Failed to add the Service event handler: %v", err)
+	}
+
+	_, err = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			c.enqueueAllServices()
+		},
+		UpdateFunc: func(old, new interface{}) {
+			oldNode, ok1 := old.(*corev1.Node)
+			newNode, ok2 := new.(*corev1.Node)
+			if ok1 && ok2 && isLoadBalancerNode(oldNode) != isLoadBalancerNode(newNode) {
+				c.enqueueAllServices()
+			}
+		},
+		DeleteFunc: func(_ interface{}) {
+			c.enqueueAllServices()
+		},
+	})
+	if err != nil {
+		klog.Errorf("Failed to add the Node event handler: %v", err)
+	}
+
+	return c
+}
+
+// enqueueService queues the Service when it is of the load balancer class, or was before its type changed.
+func (c *loadBalancerClassController) enqueueService(obj interface{}) {
+	service, ok := obj.(*corev1.Service)
+	if !ok || !c.lbaas.managesService(service) && !(isLoadBalancerDeleted(service) && c.lbaas.managesServiceDeletion(service)) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(service)
+	if err != nil {
+		klog.Errorf("Failed to get the key of Service %s: %v", klog.KObj(service), err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// enqueueAllServices queues the Services of the load balancer class, their members follow the nodes.
+func (c *loadBalancerClassController) enqueueAllServices() {
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list Services: %v", err)
+		return
+	}
+	for _, service := range services {
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			c.enqueueService(service)
+		}
+	}
+}
+
+// Run processes the queued Services until stopCh is closed.
+func (c *loadBalancerClassController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting the load balancer controller of class %q", c.lbaas.opts.ServiceLoadBalancerClass)
+	if !cache.WaitForCacheSync(stopCh, c.listersSynced...) {
+		klog.Error("Timed out waiting for the caches of the load balancer class controller to sync")
+		return
+	}
+
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	<-stopCh
+	klog.Info("Shutting down the load balancer class controller")
+}
+
+func (c *loadBalancerClassController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *loadBalancerClassController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(context.TODO(), key); err != nil {
+		klog.Errorf("Failed to sync the load balancer of Service %s: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *loadBalancerClassController) sync(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if isLoadBalancerDeleted(service) {
+		if !c.lbaas.managesServiceDeletion(service) {
+			return nil
+		}
+		return c.deleteLoadBalancer(ctx, service)
+	}
+	if !c.lbaas.managesService(service) {
+		return nil
+	}
+	return c.ensureLoadBalancer(ctx, service)
+}
+
+func (c *loadBalancerClassController) ensureLoadBalancer(ctx context.Context, service *corev1.Service) error {
+	if !slices.Contains(service.Finalizers, loadBalancerClassFinalizer) {
+		updated := service.DeepCopy()
+		updated.Finalizers = append(updated.Finalizers, loadBalancerClassFinalizer)
+		if err := cpoutil.PatchService(ctx, c.lbaas.kclient, service, updated); err != nil {
+			return err
+		}
+	}
+
+	nodes, err := c.listNodes()
+	if err != nil {
+		return err
+	}
+
+	c.recordEvent(service, corev1.EventTypeNormal, eventLBEnsuring, "Ensuring load balancer")
+	status, err := c.lbaas.EnsureLoadBalancer(ctx, c.clusterName, service.DeepCopy(), nodes)
+	if err != nil {
+		c.recordEvent(service, corev1.EventTypeWarning, eventLBSyncFailed, fmt.Sprintf("Error syncing load balancer: %v", err))
+		return err
+	}
+
+	if err := c.patchStatus(service, status); err != nil {
+		return err
+	}
+	c.recordEvent(service, corev1.EventTypeNormal, eventLBEnsured, "Ensured load balancer")
+	return nil
+}
+
+func (c *loadBalancerClassController) deleteLoadBalancer(ctx context.Context, service *corev1.Service) error {
+	if !slices.Contains(service.Finalizers, loadBalancerClassFinalizer) &&
+		getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "") == "" {
+		return nil
+	}
+
+	c.recordEvent(service, corev1.EventTypeNormal, eventLBDeleting, "Deleting load balancer")
+	if err := c.lbaas.EnsureLoadBalancerDeleted(ctx, c.clusterName, service.DeepCopy()); err != nil {
+		c.recordEvent(service, corev1.EventTypeWarning, eventLBSyncFailed, fmt.Sprintf("Error deleting load balancer: %v", err))
+		return err
+	}
+
+	updated := service.DeepCopy()
+	updated.Finalizers = slices.DeleteFunc(updated.Finalizers, func(f string) bool { return f == loadBalancerClassFinalizer })
+	if err := cpoutil.PatchService(ctx, c.lbaas.kclient, service, updated); err != nil {
+		return err
+	}
+	if service.DeletionTimestamp == nil {
+		if err := c.patchStatus(service, &corev1.LoadBalancerStatus{}); err != nil {
+			return err
+		}
+	}
+	c.recordEvent(service, corev1.EventTypeNormal, eventLBDeleted, "Deleted load balancer")
+	return nil
+}
+
+// patchStatus sets the load balancer status of the Service.
+func (c *loadBalancerClassController) patchStatus(service *corev1.Service, status *corev1.LoadBalancerStatus) error {
+	if status == nil || servicehelper.LoadBalancerStatusEqual(&service.Status.LoadBalancer, status) {
+		return nil
+	}
+	updated := service.DeepCopy()
+	updated.Status.LoadBalancer = *status
+	_, err := servicehelper.PatchService(c.lbaas.kclient.CoreV1(), service, updated)
+	return err
+}
+
+// listNodes returns the nodes that can be members of the load balancers.
+func (c *loadBalancerClassController) listNodes() ([]*corev1.Node, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(nodes, func(node *corev1.Node) bool { return !isLoadBalancerNode(node) }), nil
+}
+
+func (c *loadBalancerClassController) recordEvent(service *corev1.Service, eventType, reason, message string) {
+	if c.lbaas.eventRecorder != nil {
+		c.lbaas.eventRecorder.Event(service, eventType, reason, message)
+	}
+}
+
+// isLoadBalancerNode returns whether the node can be a member of the load balancers, as the service controller the
+// nodes excluded by label or being removed by the cluster autoscaler are not.
+func isLoadBalancerNode(node *corev1.Node) bool {
+	if _, ok := node.Labels[corev1.LabelNodeExcludeBalancers]; ok {
+		return false
+	}
+	return !slices.ContainsFunc(node.Spec.Taints, func(taint corev1.Taint) bool { return taint.Key == toBeDeletedTaint })
+}
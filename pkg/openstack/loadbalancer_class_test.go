@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/utils/ptr"
+)
+
+func TestManagesService(t *testing.T) {
+	testCases := []struct {
+		name         string
+		className    string
+		serviceClass *string
+		expected     bool
+	}{
+		{name: "default class", expected: true},
+		{name: "default class ignores the other classes", serviceClass: ptr.To("other"), expected: false},
+		{name: "class", className: "openstack", serviceClass: ptr.To("openstack"), expected: true},
+		{name: "class ignores the other classes", className: "openstack", serviceClass: ptr.To("other"), expected: false},
+		{name: "class ignores the Services without class", className: "openstack", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lbaas := &LbaasV2{LoadBalancer{opts: LoadBalancerOpts{ServiceLoadBalancerClass: tc.className}}}
+			service := &corev1.Service{Spec: corev1.ServiceSpec{LoadBalancerClass: tc.serviceClass}}
+			assert.Equal(t, tc.expected, lbaas.managesService(service))
+		})
+	}
+}
+
+func TestManagesServiceDeletion(t *testing.T) {
+	lbIDAnnotation := map[string]string{ServiceAnnotationLoadBalancerID: "lb-id"}
+	testCases := []struct {
+		name         string
+		className    string
+		serviceClass *string
+		finalizers   []string
+		annotations  map[string]string
+		expected     bool
+	}{
+		{name: "default class", expected: true},
+		{name: "class", className: "openstack", serviceClass: ptr.To("openstack"), expected: true},
+		{name: "class cleared with the finalizer", className: "openstack", finalizers: []string{loadBalancerClassFinalizer}, expected: true},
+		{name: "class cleared with the load balancer", className: "openstack", annotations: lbIDAnnotation, expected: true},
+		{name: "load balancer of the service controller", className: "openstack", finalizers: []string{serviceControllerFinalizer}, annotations: lbIDAnnotation, expected: false},
+		{name: "no class without load balancer", className: "openstack", expected: false},
+		{name: "other class with the load balancer", className: "openstack", serviceClass: ptr.To("other"), annotations: lbIDAnnotation, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lbaas := &LbaasV2{LoadBalancer{opts: LoadBalancerOpts{ServiceLoadBalancerClass: tc.className}}}
+			service := &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{Finalizers: tc.finalizers, Annotations: tc.annotations},
+				Spec:       corev1.ServiceSpec{LoadBalancerClass: tc.serviceClass},
+			}
+			assert.Equal(t, tc.expected, lbaas.managesServiceDeletion(service))
+		})
+	}
+}
+
+func TestLoadBalancerOfOtherClass(t *testing.T) {
+	lbaas := &LbaasV2{LoadBalancer{opts: LoadBalancerOpts{ServiceLoadBalancerClass: "openstack"}}}
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+
+	_, err := lbaas.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nil)
+	assert.Equal(t, cloudprovider.ImplementedElsewhere, err)
+	err = lbaas.UpdateLoadBalancer(context.TODO(), "kubernetes", service, nil)
+	assert.Equal(t, cloudprovider.ImplementedElsewhere, err)
+	err = lbaas.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", service)
+	assert.Equal(t, cloudprovider.ImplementedElsewhere, err)
+	status, exists, err := lbaas.GetLoadBalancer(context.TODO(), "kubernetes", service)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Nil(t, status)
+}
+
+func TestIsLoadBalancerNode(t *testing.T) {
+	assert.True(t, isLoadBalancerNode(&corev1.Node{}))
+	assert.False(t, isLoadBalancerNode(&corev1.Node{
+		ObjectMeta: v1.ObjectMeta{Labels: map[string]string{corev1.LabelNodeExcludeBalancers: ""}},
+	}))
+	assert.False(t, isLoadBalancerNode(&corev1.Node{
+		Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: toBeDeletedTaint, Effect: corev1.TaintEffectNoSchedule}}},
+	}))
+}
+
+func TestLoadBalancerClassControllerSync(t *testing.T) {
+	services := []*corev1.Service{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "other-class", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, LoadBalancerClass: ptr.To("other")},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "cluster-ip", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, LoadBalancerClass: ptr.To("openstack")},
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, s := range services {
+		assert.NoError(t, indexer.Add(s))
+	}
+	kclient := fake.NewSimpleClientset()
+	c := &loadBalancerClassController{
+		lbaas:         &LbaasV2{LoadBalancer{kclient: kclient, opts: LoadBalancerOpts{ServiceLoadBalancerClass: "openstack"}}},
+		serviceLister: corelisters.NewServiceLister(indexer),
+	}
+
+	// The Services of the other classes and the ones without load balancer are ignored.
+	for _, key := range []string{"default/other-class", "default/cluster-ip", "default/missing"} {
+		assert.NoError(t, c.sync(context.TODO(), key))
+	}
+	assert.Empty(t, kclient.Actions())
+
+	// The class is cleared when the type changes, the load balancer is deleted nevertheless. The paused Service keeps it.
+	changed := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "changed", Namespace: "default", Finalizers: []string{loadBalancerClassFinalizer},
+			Annotations: map[string]string{ServiceAnnotationLoadBalancerPaused: "true"},
+		},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+	}
+	assert.NoError(t, indexer.Add(changed))
+	err := c.sync(context.TODO(), "default/changed")
+	assert.ErrorContains(t, err, "paused")
+}
@@ -0,0 +1,492 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// endpointSliceController updates the members of the load balancers of the externalTrafficPolicy=Local Services when
+// the nodes running their ready endpoints change, instead of waiting for the next node update or resync. Only the
+// weight and the monitor port of the existing members are updated, the members themselves are still managed by
+// EnsureLoadBalancer and UpdateLoadBalancer.
+type endpointSliceController struct {
+	lbaas               *LbaasV2
+	clusterName         string
+	serviceLister       corelisters.ServiceLister
+	endpointSliceLister discoverylisters.EndpointSliceLister
+	listersSynced       []cache.InformerSynced
+	queue               workqueue.TypedRateLimitingInterface[string]
+
+	// localNodes are the nodes with a ready local endpoint of each Service the last time its members were updated.
+	localNodesLock sync.Mutex
+	localNodes     map[string]sets.Set[string]
+}
+
+func newEndpointSliceController(lbaas *LbaasV2, clusterName string, serviceInformer coreinformers.ServiceInformer, endpointSliceInformer discoveryinformers.EndpointSliceInformer) *endpointSliceController {
+	c := &endpointSliceController{
+		lbaas:               lbaas,
+		clusterName:         clusterName,
+		serviceLister:       serviceInformer.Lister(),
+		endpointSliceLister: endpointSliceInformer.Lister(),
+		listersSynced: []cache.InformerSynced{
+			serviceInformer.Informer().HasSynced,
+			endpointSliceInformer.Informer().HasSynced,
+		},
+		queue:      workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+		localNodes: make(map[string]sets.Set[string]),
+	}
+
+	_, err := endpointSliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueEndpointSlice,
+		UpdateFunc: func(_, cur interface{}) {
+			c.enqueueEndpointSlice(cur)
+		},
+		DeleteFunc: c.enqueueEndpointSlice,
+	})
+	if err != nil {
+		klog.Errorf("Failed to add the EndpointSlice event handler: %v", err)
+	}
+
+	return c
+}
+
+// enqueueEndpointSlice queues the Service owning the EndpointSlice.
+func (c *endpointSliceController) enqueueEndpointSlice(obj interface{}) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Couldn't get object from tombstone %#v", obj)
+			return
+		}
+		slice, ok = tombstone.Obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			klog.Errorf("Tombstone contained object that is not an EndpointSlice %#v", obj)
+			return
+		}
+	}
+
+	serviceName := slice.Labels[discoveryv1.LabelServiceName]
+	if serviceName == "" {
+		return
+	}
+	c.queue.Add(fmt.Sprintf("%s/%s", slice.Namespace, serviceName))
+}
+
+// Run processes the Services queued by the EndpointSlice events until stopCh is closed.
+func (c *endpointSliceController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Info("Starting the EndpointSlice member controller")
+	if !cache.WaitForCacheSync(stopCh, c.listersSynced...) {
+		klog.Error("Timed out waiting for the caches of the EndpointSlice member controller to sync")
+		return
+	}
+
+	// A single worker is used so that the members of a load balancer shared by several Services are updated in turn.
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	<-stopCh
+	klog.Info("Shutting down the EndpointSlice member controller")
+}
+
+func (c *endpointSliceController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *endpointSliceController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(context.TODO(), key); err != nil {
+		klog.Errorf("Failed to update the load balancer members of Service %s: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *endpointSliceController) sync(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.setLocalNodes(key, nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer ||
+		!c.lbaas.managesService(service) ||
+		getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "") == "" {
+		// The load balancer doesn't exist yet, EnsureLoadBalancer will set the members.
+		c.setLocalNodes(key, nil)
+		return nil
+	}
+
+	if getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerEndpointMembers, false) {
+		c.setLocalNodes(key, nil)
+		return c.lbaas.updateEndpointMembers(ctx, c.clusterName, service)
+	}
+
+	if service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+		// The load balancer doesn't use the local endpoints.
+		c.setLocalNodes(key, nil)
+		return nil
+	}
+
+	localNodes, err := getLocalEndpointNodes(c.endpointSliceLister, service)
+	if err != nil {
+		return err
+	}
+	if localNodes.Len() == 0 {
+		// The weights are kept when no endpoint is ready, e.g. during a rollout, rather than draining all the members.
+		klog.V(4).InfoS("No ready endpoint, keeping the load balancer member weights", "service", klog.KObj(service))
+		return nil
+	}
+	if prev, ok := c.getLocalNodes(key); ok && prev.Equal(localNodes) {
+		return nil
+	}
+
+	klog.V(2).InfoS("Updating load balancer member weights", "service", klog.KObj(service), "nodes", sets.List(localNodes))
+	if err := c.lbaas.updateMemberWeights(ctx, service, localNodes); err != nil {
+		return err
+	}
+	c.setLocalNodes(key, localNodes)
+	return nil
+}
+
+func (c *endpointSliceController) getLocalNodes(key string) (sets.Set[string], bool) {
+	c.localNodesLock.Lock()
+	defer c.localNodesLock.Unlock()
+	nodes, ok := c.localNodes[key]
+	return nodes, ok
+}
+
+func (c *endpointSliceController) setLocalNodes(key string, nodes sets.Set[string]) {
+	c.localNodesLock.Lock()
+	defer c.localNodesLock.Unlock()
+	if nodes == nil {
+		delete(c.localNodes, key)
+		return
+	}
+	c.localNodes[key] = nodes
+}
+
+// listServiceEndpointSlices returns the EndpointSlices of the Service.
+func listServiceEndpointSlices(lister discoverylisters.EndpointSliceLister, service *corev1.Service) ([]*discoveryv1.EndpointSlice, error) {
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name})
+	slices, err := lister.EndpointSlices(service.Namespace).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list EndpointSlices of Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	return slices, nil
+}
+
+// isEndpointReady checks whether the endpoint is ready, a nil ready condition means ready.
+func isEndpointReady(ep discoveryv1.Endpoint) bool {
+	return ep.Conditions.Ready == nil || *ep.Conditions.Ready
+}
+
+// getLocalEndpointNodes returns the names of the nodes running a ready endpoint of the Service.
+func getLocalEndpointNodes(lister discoverylisters.EndpointSliceLister, service *corev1.Service) (sets.Set[string], error) {
+	slices, err := listServiceEndpointSlices(lister, service)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := sets.New[string]()
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			if ep.NodeName == nil || !isEndpointReady(ep) {
+				continue
+			}
+			nodes.Insert(*ep.NodeName)
+		}
+	}
+	return nodes, nil
+}
+
+// getEndpointSlicePort returns the port of the EndpointSlice the Service port targets. The EndpointSlices resolve the
+// named target ports, so the port may differ between the EndpointSlices of the same Service.
+func getEndpointSlicePort(slice *discoveryv1.EndpointSlice, port corev1.ServicePort) *discoveryv1.EndpointPort {
+	for i, p := range slice.Ports {
+		if p.Port != nil && ptr.Deref(p.Name, "") == port.Name && ptr.Deref(p.Protocol, corev1.ProtocolTCP) == port.Protocol {
+			return &slice.Ports[i]
+		}
+	}
+	return nil
+}
+
+// buildEndpointMemberOpts returns the members of the pool of the Service port, the ready endpoints at the port their
+// EndpointSlice resolves, alongside a list of member names as buildBatchUpdateMemberOpts.
+func buildEndpointMemberOpts(port corev1.ServicePort, svcConf *serviceConfig) ([]v2pools.BatchUpdateMemberOpts, sets.Set[string]) {
+	var members []v2pools.BatchUpdateMemberOpts
+	newMembers := sets.New[string]()
+
+	var memberSubnetID *string
+	if svcConf.lbMemberSubnetID != "" {
+		memberSubnetID = ptr.To(svcConf.lbMemberSubnetID)
+	}
+
+	seen := sets.New[string]()
+	for _, slice := range svcConf.endpointSlices {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN ||
+			(svcConf.preferredIPFamily != "" && string(slice.AddressType) != string(svcConf.preferredIPFamily)) {
+			continue
+		}
+		slicePort := getEndpointSlicePort(slice, port)
+		if slicePort == nil {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if len(ep.Addresses) == 0 || !isEndpointReady(ep) {
+				continue
+			}
+			addr := ep.Addresses[0]
+			protocolPort := int(*slicePort.Port)
+			// An endpoint may be in several EndpointSlices meanwhile they are updated
+			key := fmt.Sprintf("%s:%d", addr, protocolPort)
+			if seen.Has(key) {
+				continue
+			}
+			seen.Insert(key)
+
+			name := addr
+			if ep.TargetRef != nil && ep.TargetRef.Name != "" {
+				name = ep.TargetRef.Name
+			}
+			members = append(members, v2pools.BatchUpdateMemberOpts{
+				Address:      addr,
+				ProtocolPort: protocolPort,
+				Name:         ptr.To(name),
+				SubnetID:     memberSubnetID,
+			})
+			newMembers.Insert(fmt.Sprintf("%s-%s-%d-%d-%d", name, addr, protocolPort, 0, 1))
+		}
+	}
+	return members, newMembers
+}
+
+// memberWeight returns the weight of the member of the given node, nil when the weights are not managed.
+func memberWeight(nodeName string, svcConf *serviceConfig) *int {
+	if svcConf.localEndpointNodes == nil {
+		return nil
+	}
+	if svcConf.localEndpointNodes.Has(nodeName) {
+		return ptr.To(1)
+	}
+	return ptr.To(0)
+}
+
+// updateMemberWeights updates the weight and the monitor port of the existing members of the pools of the Service
+// from the nodes running its ready endpoints. The members are left as they are otherwise.
+func (lbaas *LbaasV2) updateMemberWeights(ctx context.Context, service *corev1.Service, localNodes sets.Set[string]) error {
+	if lbaas.isDryRun(service) {
+		klog.V(4).InfoS("Dry-run: skipping the member weights update", "service", klog.KObj(service))
+		return nil
+	}
+	if lbaas.isPaused(service) {
+		klog.V(4).InfoS("Reconciliation paused, skipping the member weights update", "service", klog.KObj(service))
+		return nil
+	}
+
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	svcConf := new(serviceConfig)
+	if err := lbaas.checkServiceDelete(service, svcConf); err != nil {
+		return err
+	}
+	svcConf.localEndpointNodes = localNodes
+	if svcConf.enableMonitor && svcConf.healthMonitorType != monitorTypeTCP && service.Spec.HealthCheckNodePort > 0 {
+		svcConf.healthCheckNodePort = int(service.Spec.HealthCheckNodePort)
+	}
+
+	listenerList, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, svcConf.lbID)
+	if err != nil {
+		return fmt.Errorf("error getting LB %s listeners: %v", svcConf.lbID, err)
+	}
+	curListenerMapping := getListenerMapping(listenerList)
+
+	for _, port := range service.Spec.Ports {
+		listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
+		if !isPresent {
+			continue
+		}
+		pool, err := openstackutil.GetPoolByListener(lbaas.lb, svcConf.lbID, listener.ID)
+		if err != nil {
+			if err == cpoerrors.ErrNotFound {
+				continue
+			}
+			return fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
+		}
+		poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
+		if err != nil {
+			return fmt.Errorf("error getting members of pool %s: %v", pool.ID, err)
+		}
+
+		members, changed := lbaas.buildMemberWeightUpdateOpts(port, poolMembers, svcConf)
+		if !changed {
+			continue
+		}
+		klog.V(2).Infof("Updating the weights of %d members for pool %s", len(members), pool.ID)
+		if err := openstackutil.BatchUpdatePoolMembers(lbaas.lb, svcConf.lbID, pool.ID, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateEndpointMembers updates the members of the pools of the Service using the endpoint members from its
+// EndpointSlices, and the security group rules of their target ports.
+func (lbaas *LbaasV2) updateEndpointMembers(ctx context.Context, clusterName string, service *corev1.Service) error {
+	if lbaas.isDryRun(service) {
+		klog.V(4).InfoS("Dry-run: skipping the endpoint members update", "service", klog.KObj(service))
+		return nil
+	}
+	if lbaas.isPaused(service) {
+		klog.V(4).InfoS("Reconciliation paused, skipping the endpoint members update", "service", klog.KObj(service))
+		return nil
+	}
+
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The EndpointSlices are listed with the configuration of the Service
+	svcConf := new(serviceConfig)
+	if err := lbaas.checkServiceUpdate(ctx, service, nil, svcConf); err != nil {
+		return err
+	}
+
+	// The target ports of the new endpoints are allowed before they become members
+	if lbaas.opts.ManageSecurityGroups {
+		if _, _, err := lbaas.ensureOctaviaSecurityGroupRules(ctx, clusterName, service, svcConf); err != nil {
+			return fmt.Errorf("failed to update the security group rules of Service %s: %v", klog.KObj(service), err)
+		}
+	}
+
+	listenerList, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, svcConf.lbID)
+	if err != nil {
+		return fmt.Errorf("error getting LB %s listeners: %v", svcConf.lbID, err)
+	}
+	curListenerMapping := getListenerMapping(listenerList)
+
+	for _, port := range service.Spec.Ports {
+		listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
+		if !isPresent {
+			continue
+		}
+		pool, err := openstackutil.GetPoolByListener(lbaas.lb, svcConf.lbID, listener.ID)
+		if err != nil {
+			if err == cpoerrors.ErrNotFound {
+				continue
+			}
+			return fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
+		}
+		poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
+		if err != nil {
+			return fmt.Errorf("error getting members of pool %s: %v", pool.ID, err)
+		}
+
+		members, newMembers := buildEndpointMemberOpts(port, svcConf)
+		if getPoolMemberKeys(poolMembers).Equal(newMembers) {
+			continue
+		}
+		klog.V(2).Infof("Updating %d endpoint members for pool %s", len(members), pool.ID)
+		if err := openstackutil.BatchUpdatePoolMembers(lbaas.lb, svcConf.lbID, pool.ID, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildMemberWeightUpdateOpts returns the batch update of the given pool members setting their weight and monitor
+// port, and whether any member changes.
+func (lbaas *LbaasV2) buildMemberWeightUpdateOpts(port corev1.ServicePort, poolMembers []v2pools.Member, svcConf *serviceConfig) ([]v2pools.BatchUpdateMemberOpts, bool) {
+	changed := false
+	members := make([]v2pools.BatchUpdateMemberOpts, 0, len(poolMembers))
+	for _, m := range poolMembers {
+		member := v2pools.BatchUpdateMemberOpts{
+			Address:      m.Address,
+			ProtocolPort: m.ProtocolPort,
+			Name:         ptr.To(m.Name),
+			Weight:       memberWeight(m.Name, svcConf),
+			AdminStateUp: ptr.To(m.AdminStateUp),
+			Backup:       ptr.To(m.Backup),
+			Tags:         m.Tags,
+		}
+		if m.SubnetID != "" {
+			member.SubnetID = ptr.To(m.SubnetID)
+		}
+		if m.MonitorAddress != "" {
+			member.MonitorAddress = ptr.To(m.MonitorAddress)
+		}
+		if svcConf.healthCheckNodePort > 0 && lbaas.canUseHTTPMonitor(port) {
+			member.MonitorPort = &svcConf.healthCheckNodePort
+		} else if m.MonitorPort != 0 {
+			member.MonitorPort = ptr.To(m.MonitorPort)
+		}
+		if ptr.Deref(member.Weight, m.Weight) != m.Weight || ptr.Deref(member.MonitorPort, m.MonitorPort) != m.MonitorPort {
+			changed = true
+		}
+		members = append(members, member)
+	}
+	return members, changed
+}
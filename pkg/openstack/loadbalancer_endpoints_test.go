@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+
+	"k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+func TestGetLocalEndpointNodes(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	slices := []*discoveryv1.EndpointSlice{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "svc-a", Namespace: "default", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			Endpoints: []discoveryv1.Endpoint{
+				{NodeName: ptr.To("node-1")},
+				{NodeName: ptr.To("node-2"), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+				{NodeName: ptr.To("node-3"), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+				{},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "svc-b", Namespace: "default", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			Endpoints:  []discoveryv1.Endpoint{{NodeName: ptr.To("node-4")}},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "default", Labels: map[string]string{discoveryv1.LabelServiceName: "other"}},
+			Endpoints:  []discoveryv1.Endpoint{{NodeName: ptr.To("node-5")}},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "other", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			Endpoints:  []discoveryv1.Endpoint{{NodeName: ptr.To("node-6")}},
+		},
+	}
+	for _, s := range slices {
+		assert.NoError(t, indexer.Add(s))
+	}
+
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	nodes, err := getLocalEndpointNodes(discoverylisters.NewEndpointSliceLister(indexer), service)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-1", "node-2", "node-4"}, sets.List(nodes))
+}
+
+func TestMemberWeight(t *testing.T) {
+	assert.Nil(t, memberWeight("node-1", &serviceConfig{}))
+
+	svcConf := &serviceConfig{localEndpointNodes: sets.New("node-1")}
+	assert.Equal(t, ptr.To(1), memberWeight("node-1", svcConf))
+	assert.Equal(t, ptr.To(0), memberWeight("node-2", svcConf))
+}
+
+func TestBuildBatchUpdateMemberOptsWeights(t *testing.T) {
+	nodes := []*corev1.Node{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "node-1"},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.1.1"}}},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "node-2"},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.1.2"}}},
+		},
+	}
+	svcConf := &serviceConfig{
+		preferredIPFamily:  corev1.IPv4Protocol,
+		localEndpointNodes: sets.New("node-2"),
+	}
+
+	lbaas := &LbaasV2{}
+	members, newMembers, err := lbaas.buildBatchUpdateMemberOpts(corev1.ServicePort{NodePort: 30000}, nodes, svcConf)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, ptr.To(0), members[0].Weight)
+	assert.Equal(t, ptr.To(1), members[1].Weight)
+	assert.True(t, newMembers.Equal(sets.New("node-1-192.168.1.1-30000-0-0", "node-2-192.168.1.2-30000-0-1")))
+}
+
+func TestBuildMemberWeightUpdateOpts(t *testing.T) {
+	poolMembers := []v2pools.Member{
+		{Name: "node-1", Address: "192.168.1.1", ProtocolPort: 30000, Weight: 1, SubnetID: "subnet-id", AdminStateUp: true, MonitorPort: 30001},
+		{Name: "node-2", Address: "192.168.1.2", ProtocolPort: 30000, Weight: 1, SubnetID: "subnet-id", AdminStateUp: true, MonitorPort: 30001},
+	}
+	port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, NodePort: 30000}
+	lbaas := &LbaasV2{}
+
+	members, changed := lbaas.buildMemberWeightUpdateOpts(port, poolMembers, &serviceConfig{
+		healthCheckNodePort: 30001,
+		localEndpointNodes:  sets.New("node-1", "node-2"),
+	})
+	assert.False(t, changed)
+	assert.Len(t, members, 2)
+
+	members, changed = lbaas.buildMemberWeightUpdateOpts(port, poolMembers, &serviceConfig{
+		healthCheckNodePort: 30001,
+		localEndpointNodes:  sets.New("node-2"),
+	})
+	assert.True(t, changed)
+	assert.Equal(t, ptr.To(0), members[0].Weight)
+	assert.Equal(t, ptr.To(1), members[1].Weight)
+	for _, m := range members {
+		assert.Equal(t, ptr.To("subnet-id"), m.SubnetID)
+		assert.Equal(t, ptr.To(30001), m.MonitorPort)
+		assert.Equal(t, ptr.To(true), m.AdminStateUp)
+	}
+}
+
+func TestBuildEndpointMemberOpts(t *testing.T) {
+	port := corev1.ServicePort{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}
+	svcConf := &serviceConfig{
+		endpointMembers:   true,
+		lbMemberSubnetID:  "pod-subnet-id",
+		preferredIPFamily: corev1.IPv4Protocol,
+		endpointSlices: []*discoveryv1.EndpointSlice{
+			{
+				// The named target port resolves to 8080 for these pods
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports: []discoveryv1.EndpointPort{
+					{Name: ptr.To("metrics"), Port: ptr.To[int32](9090)},
+					{Name: ptr.To("http"), Port: ptr.To[int32](8080)},
+				},
+				Endpoints: []discoveryv1.Endpoint{
+					{Addresses: []string{"10.0.0.1"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-1"}},
+					{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+				},
+			},
+			{
+				// And to 8443 for these ones
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8443)}},
+				Endpoints: []discoveryv1.Endpoint{
+					{Addresses: []string{"10.0.0.3"}},
+				},
+			},
+			{
+				// Also in the first EndpointSlice meanwhile it's updated
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+				Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod-1"}}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv6,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+				Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"fd00::1"}}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Protocol: ptr.To(corev1.ProtocolUDP), Port: ptr.To[int32](8080)}},
+				Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.4"}}},
+			},
+		},
+	}
+
+	members, keys := buildEndpointMemberOpts(port, svcConf)
+	assert.Equal(t, []v2pools.BatchUpdateMemberOpts{
+		{Address: "10.0.0.1", ProtocolPort: 8080, Name: ptr.To("pod-1"), SubnetID: ptr.To("pod-subnet-id")},
+		{Address: "10.0.0.3", ProtocolPort: 8443, Name: ptr.To("10.0.0.3"), SubnetID: ptr.To("pod-subnet-id")},
+	}, members)
+	assert.Equal(t, sets.New("pod-1-10.0.0.1-8080-0-1", "10.0.0.3-10.0.0.3-8443-0-1"), keys)
+
+	// The keys match the ones of the pool members once created
+	poolMembers := []v2pools.Member{
+		{Name: "pod-1", Address: "10.0.0.1", ProtocolPort: 8080, Weight: 1},
+		{Name: "10.0.0.3", Address: "10.0.0.3", ProtocolPort: 8443, Weight: 1},
+	}
+	assert.True(t, getPoolMemberKeys(poolMembers).Equal(keys))
+}
+
+func TestEndpointSliceControllerSyncWithoutReadyEndpoint(t *testing.T) {
+	serviceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{ServiceAnnotationLoadBalancerID: "lb-id"}},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal},
+	}
+	assert.NoError(t, serviceIndexer.Add(service))
+	sliceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, sliceIndexer.Add(&discoveryv1.EndpointSlice{
+		ObjectMeta: v1.ObjectMeta{Name: "svc-a", Namespace: "default", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+		Endpoints:  []discoveryv1.Endpoint{{NodeName: ptr.To("node-1"), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}}},
+	}))
+
+	// The member weights aren't updated, the load balancer isn't even read
+	c := &endpointSliceController{
+		lbaas:               &LbaasV2{},
+		serviceLister:       corelisters.NewServiceLister(serviceIndexer),
+		endpointSliceLister: discoverylisters.NewEndpointSliceLister(sliceIndexer),
+		localNodes:          map[string]sets.Set[string]{"default/svc": sets.New("node-1")},
+	}
+	assert.NoError(t, c.sync(context.TODO(), "default/svc"))
+	nodes, ok := c.getLocalNodes("default/svc")
+	assert.True(t, ok)
+	assert.Equal(t, sets.New("node-1"), nodes)
+}
+
+func TestUpdateMemberWeightsLocksLoadBalancer(t *testing.T) {
+	ctx := context.TODO()
+	kclient := fake.NewSimpleClientset()
+	lbaas := &LbaasV2{LoadBalancer{
+		opts:    LoadBalancerOpts{SharedLBLeaseDuration: util.MyDuration{Duration: time.Minute}},
+		kclient: kclient,
+	}}
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{
+		Namespace:   "ns",
+		Name:        "svc",
+		Annotations: map[string]string{ServiceAnnotationLoadBalancerID: "lb-id"},
+	}}
+
+	// The members of the load balancer being updated by another OCCM are left as they are
+	renewTime := v1.NewMicroTime(time.Now())
+	_, err := kclient.CoordinationV1().Leases(sharedLBLeaseNamespace).Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: v1.ObjectMeta{Name: "cpo-lb-lb-id", Namespace: sharedLBLeaseNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To("other"),
+			LeaseDurationSeconds: ptr.To(int32(60)),
+			RenewTime:            &renewTime,
+		},
+	}, v1.CreateOptions{})
+	assert.NoError(t, err)
+
+	assert.ErrorContains(t, lbaas.updateMemberWeights(ctx, service, sets.New("node-1")), "being updated by other")
+	assert.ErrorContains(t, lbaas.updateEndpointMembers(ctx, "cluster", service), "being updated by other")
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+const (
+	// ServiceAnnotationLoadBalancerExternalFloatingIP delegates the floating IP of the load balancer of an external
+	// Service to an external controller, overrides the external-floating-ip option.
+	ServiceAnnotationLoadBalancerExternalFloatingIP = "loadbalancer.openstack.org/external-floating-ip"
+	// ServiceAnnotationLoadBalancerVIPPortID is set to the VIP port of the load balancer, the external controller
+	// attaches the floating IP to it.
+	ServiceAnnotationLoadBalancerVIPPortID = "loadbalancer.openstack.org/vip-port-id"
+	// ServiceAnnotationLoadBalancerFloatingIPAttached is set by the external controller to the address of the floating IP
+	// once attached to the VIP port.
+	ServiceAnnotationLoadBalancerFloatingIPAttached = "loadbalancer.openstack.org/floating-ip-attached"
+	// ServiceAnnotationLoadBalancerFloatingIPAcknowledged is set to the address of the floating IP once its attachment to
+	// the VIP port is verified, the Service is then exposed with it.
+	ServiceAnnotationLoadBalancerFloatingIPAcknowledged = "loadbalancer.openstack.org/floating-ip-acknowledged"
+)
+
+// ensureExternalFloatingIP exposes the VIP port of the load balancer for the external controller managing its floating
+// IP, and returns the floating IP once the controller reports it attached. The floating IPs are neither created,
+// attached nor detached, the address of an internal Service is the VIP address whether a floating IP is attached or not.
+func (lbaas *LbaasV2) ensureExternalFloatingIP(ctx context.Context, service *corev1.Service, lb *loadbalancers.LoadBalancer, svcConf *serviceConfig) (string, error) {
+	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerVIPPortID, lb.VipPortID)
+
+	if svcConf.internal {
+		delete(service.Annotations, ServiceAnnotationLoadBalancerFloatingIPAcknowledged)
+		return lb.VipAddress, nil
+	}
+
+	attached := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerFloatingIPAttached, "")
+	if attached == "" {
+		delete(service.Annotations, ServiceAnnotationLoadBalancerFloatingIPAcknowledged)
+		return "", fmt.Errorf("waiting for the floating IP of VIP port %s of load balancer %s, the external controller sets the %s annotation once attached",
+			lb.VipPortID, lb.ID, ServiceAnnotationLoadBalancerFloatingIPAttached)
+	}
+
+	fip, err := openstackutil.GetFloatingIPByPortID(ctx, lbaas.network, lb.VipPortID)
+	if err != nil {
+		return "", fmt.Errorf("failed when getting floating IP for port %s: %v", lb.VipPortID, err)
+	}
+	if fip == nil || fip.FloatingIP != attached {
+		delete(service.Annotations, ServiceAnnotationLoadBalancerFloatingIPAcknowledged)
+		return "", fmt.Errorf("floating IP %s of the %s annotation is not attached to VIP port %s of load balancer %s",
+			attached, ServiceAnnotationLoadBalancerFloatingIPAttached, lb.VipPortID, lb.ID)
+	}
+
+	if service.Annotations[ServiceAnnotationLoadBalancerFloatingIPAcknowledged] != fip.FloatingIP {
+		klog.InfoS("Acknowledging floating IP attached by external controller", "floatingIP", fip.FloatingIP, "lbID", lb.ID, "service", klog.KObj(service))
+	}
+	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerFloatingIPAcknowledged, fip.FloatingIP)
+
+	return fip.FloatingIP, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLbaasV2_ensureExternalFloatingIP(t *testing.T) {
+	const vipPortID = "vip-port-id"
+	lb := &loadbalancers.LoadBalancer{ID: "lb-id", VipPortID: vipPortID, VipAddress: "10.0.0.10"}
+
+	testCases := []struct {
+		name                 string
+		fip                  *floatingips.FloatingIP
+		internal             bool
+		annotations          map[string]string
+		expectedAddr         string
+		expectedAcknowledged string
+		expectedErr          bool
+	}{
+		{
+			name:        "waiting for the external controller",
+			expectedErr: true,
+		},
+		{
+			name:        "floating IP not attached yet",
+			fip:         &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10"},
+			annotations: map[string]string{ServiceAnnotationLoadBalancerFloatingIPAttached: "172.24.4.10"},
+			expectedErr: true,
+		},
+		{
+			name:        "another floating IP attached",
+			fip:         &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.11", PortID: vipPortID},
+			annotations: map[string]string{ServiceAnnotationLoadBalancerFloatingIPAttached: "172.24.4.10"},
+			expectedErr: true,
+		},
+		{
+			name:                 "floating IP attached",
+			fip:                  &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID},
+			annotations:          map[string]string{ServiceAnnotationLoadBalancerFloatingIPAttached: "172.24.4.10"},
+			expectedAddr:         "172.24.4.10",
+			expectedAcknowledged: "172.24.4.10",
+		},
+		{
+			name:     "internal Service",
+			fip:      &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID},
+			internal: true,
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerFloatingIPAttached:     "172.24.4.10",
+				ServiceAnnotationLoadBalancerFloatingIPAcknowledged: "172.24.4.10",
+			},
+			expectedAddr: "10.0.0.10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			var portID string
+			if tc.fip != nil {
+				portID = tc.fip.PortID
+			}
+			fake := &fakeFloatingIPs{fip: tc.fip}
+			fake.register(t)
+
+			lbaas := &LbaasV2{
+				LoadBalancer{
+					network: fakeclient.ServiceClient(),
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: tc.annotations},
+			}
+			svcConf := &serviceConfig{internal: tc.internal, externalFloatingIP: true}
+
+			addr, err := lbaas.ensureExternalFloatingIP(context.TODO(), service, lb, svcConf)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedAddr, addr)
+			assert.Equal(t, vipPortID, service.Annotations[ServiceAnnotationLoadBalancerVIPPortID])
+			assert.Equal(t, tc.expectedAcknowledged, service.Annotations[ServiceAnnotationLoadBalancerFloatingIPAcknowledged])
+			assert.False(t, fake.created)
+			assert.False(t, fake.deleted)
+			if fake.fip != nil {
+				assert.Equal(t, portID, fake.fip.PortID)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
+
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// fipDescriptionPrefix prefixes the description of the floating IPs created by the cloud provider.
+const fipDescriptionPrefix = "Floating IP for Kubernetes external service"
+
+// fipTransition is the change applied to the floating IP of the load balancer VIP port to reach the exposure of the
+// Service, internal or external.
+type fipTransition int
+
+const (
+	// fipKeep leaves the VIP port as it is: external with a floating IP or internal without.
+	fipKeep fipTransition = iota
+	// fipAttach attaches a floating IP to the VIP port of an external Service, reusing an existing one when possible.
+	fipAttach
+	// fipDetach detaches the floating IP from the VIP port of an internal Service and keeps it.
+	fipDetach
+	// fipDelete deletes the floating IP created for an internal Service.
+	fipDelete
+)
+
+func (t fipTransition) String() string {
+	switch t {
+	case fipKeep:
+		return "keep"
+	case fipAttach:
+		return "attach"
+	case fipDetach:
+		return "detach"
+	case fipDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("fipTransition(%d)", int(t))
+}
+
+// getFloatingIPTransition returns the transition from the floating IP attached to the VIP port, if any, to the
+// exposure of the Service. The floating IPs not created by the cloud provider or the ones the Service asks to keep are
+// only detached when the Service becomes internal.
+func getFloatingIPTransition(fip *floatingips.FloatingIP, internal, keepFloatingIP bool) fipTransition {
+	switch {
+	case internal && fip == nil:
+		return fipKeep
+	case internal && !keepFloatingIP && isFloatingIPCreatedByProvider(fip):
+		return fipDelete
+	case internal:
+		return fipDetach
+	case fip == nil:
+		return fipAttach
+	default:
+		return fipKeep
+	}
+}
+
+// isFloatingIPCreatedByProvider checks whether the floating IP was created by the cloud provider.
+func isFloatingIPCreatedByProvider(fip *floatingips.FloatingIP) bool {
+	return strings.Contains(fip.Description, fipDescriptionPrefix)
+}
+
+// getFloatingIPDescription returns the description of the floating IP created for the Service.
+func getFloatingIPDescription(clusterName, serviceName string) string {
+	return fmt.Sprintf("%s %s from cluster %s", fipDescriptionPrefix, serviceName, clusterName)
+}
+
+// getDetachedFloatingIP returns the floating IP created for the Service and detached when the Service became
+// internal, so that the Service gets its address back when it becomes external again.
+func (lbaas *LbaasV2) getDetachedFloatingIP(ctx context.Context, clusterName, serviceName, networkID string) (*floatingips.FloatingIP, error) {
+	opts := floatingips.ListOpts{
+		Description:       getFloatingIPDescription(clusterName, serviceName),
+		FloatingNetworkID: networkID,
+	}
+	fips, err := openstackutil.GetFloatingIPs(ctx, lbaas.network, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list floating IPs of Service %s: %v", serviceName, err)
+	}
+	for i := range fips {
+		if fips[i].PortID == "" {
+			return &fips[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetFloatingIPTransition(t *testing.T) {
+	providerFIP := &floatingips.FloatingIP{Description: getFloatingIPDescription("kubernetes", "default/svc")}
+	userFIP := &floatingips.FloatingIP{Description: "reserved"}
+
+	testCases := []struct {
+		name           string
+		fip            *floatingips.FloatingIP
+		internal       bool
+		keepFloatingIP bool
+		expected       fipTransition
+	}{
+		{name: "external with floating IP", fip: providerFIP, expected: fipKeep},
+		{name: "external without floating IP", expected: fipAttach},
+		{name: "internal without floating IP", internal: true, expected: fipKeep},
+		{name: "external to internal", fip: providerFIP, internal: true, expected: fipDelete},
+		{name: "external to internal keeping the floating IP", fip: providerFIP, internal: true, keepFloatingIP: true, expected: fipDetach},
+		{name: "external to internal with a user floating IP", fip: userFIP, internal: true, expected: fipDetach},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, getFloatingIPTransition(tc.fip, tc.internal, tc.keepFloatingIP))
+		})
+	}
+}
+
+// fakeFloatingIPs serves the floating IP API of Neutron for a single floating IP.
+type fakeFloatingIPs struct {
+	fip     *floatingips.FloatingIP
+	deleted bool
+	created bool
+}
+
+func (f *fakeFloatingIPs) register(t *testing.T) {
+	th.Mux.HandleFunc("/floatingips", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			var fips []*floatingips.FloatingIP
+			q := r.URL.Query()
+			if f.fip != nil && !f.deleted &&
+				(!q.Has("port_id") || q.Get("port_id") == f.fip.PortID) &&
+				(!q.Has("description") || q.Get("description") == f.fip.Description) &&
+				(!q.Has("floating_ip_address") || q.Get("floating_ip_address") == f.fip.FloatingIP) {
+				fips = append(fips, f.fip)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"floatingips": fips})
+		case http.MethodPost:
+			var body struct {
+				FloatingIP floatingips.FloatingIP `json:"floatingip"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode floating IP create request: %v", err)
+			}
+			f.created = true
+			f.fip = &body.FloatingIP
+			f.fip.ID = "new-fip-id"
+			f.fip.FloatingIP = "172.24.4.20"
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"floatingip": f.fip})
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+	th.Mux.HandleFunc("/floatingips/fip-id", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			var body struct {
+				FloatingIP struct {
+					PortID *string `json:"port_id"`
+				} `json:"floatingip"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode floating IP update request: %v", err)
+			}
+			f.fip.PortID = ""
+			if body.FloatingIP.PortID != nil {
+				f.fip.PortID = *body.FloatingIP.PortID
+			}
+			w.Header().Add("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"floatingip": f.fip})
+		case http.MethodDelete:
+			f.deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	})
+}
+
+func TestLbaasV2_ensureFloatingIPTransitions(t *testing.T) {
+	const vipPortID = "vip-port-id"
+	description := getFloatingIPDescription("kubernetes", "default/svc")
+	lb := &loadbalancers.LoadBalancer{ID: "lb-id", VipPortID: vipPortID, VipAddress: "10.0.0.10"}
+
+	testCases := []struct {
+		name           string
+		fip            *floatingips.FloatingIP
+		internal       bool
+		annotations    map[string]string
+		expectedAddr   string
+		expectedPortID string
+		deleted        bool
+		created        bool
+	}{
+		{
+			name:         "external to internal deletes the floating IP",
+			fip:          &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID, Description: description},
+			internal:     true,
+			expectedAddr: "10.0.0.10",
+			deleted:      true,
+		},
+		{
+			name:         "external to internal detaches the kept floating IP",
+			fip:          &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID, Description: description},
+			internal:     true,
+			annotations:  map[string]string{ServiceAnnotationLoadBalancerKeepFloatingIP: "true"},
+			expectedAddr: "10.0.0.10",
+		},
+		{
+			name:         "external to internal detaches a user floating IP",
+			fip:          &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID, Description: "reserved"},
+			internal:     true,
+			expectedAddr: "10.0.0.10",
+		},
+		{
+			name:           "internal to external reattaches the kept floating IP",
+			fip:            &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", Description: description},
+			expectedAddr:   "172.24.4.10",
+			expectedPortID: vipPortID,
+		},
+		{
+			name:           "internal to external creates a floating IP",
+			expectedAddr:   "172.24.4.20",
+			expectedPortID: vipPortID,
+			created:        true,
+		},
+		{
+			name:           "external keeps the floating IP",
+			fip:            &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: vipPortID, Description: description},
+			expectedAddr:   "172.24.4.10",
+			expectedPortID: vipPortID,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			fake := &fakeFloatingIPs{fip: tc.fip}
+			fake.register(t)
+
+			lbaas := &LbaasV2{
+				LoadBalancer{
+					network: fakeclient.ServiceClient(),
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: tc.annotations},
+			}
+			svcConf := &serviceConfig{internal: tc.internal, lbPublicNetworkID: "public-network-id"}
+
+			addr, err := lbaas.ensureFloatingIP(context.TODO(), "kubernetes", service, lb, svcConf, true)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAddr, addr)
+			assert.Equal(t, tc.deleted, fake.deleted)
+			assert.Equal(t, tc.created, fake.created)
+			if fake.fip != nil && !fake.deleted {
+				assert.Equal(t, tc.expectedPortID, fake.fip.PortID)
+			}
+		})
+	}
+}
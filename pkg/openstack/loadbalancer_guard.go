@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+)
+
+// ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges allows the next reconciliation of the Service to delete or
+// recreate more listeners and pools than max-destructive-changes. It is removed by occm once the reconciliation went
+// through, so that it only acknowledges the changes at hand.
+const ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges = "loadbalancer.openstack.org/acknowledge-destructive-changes"
+
+// destructiveChanges returns the deletions of listeners and pools, including the ones recreated with another protocol,
+// of the reconciliation of the listeners of the Service. They are planned by ensureOctaviaListeners.
+func (lbaas *LbaasV2) destructiveChanges(loadbalancer *loadbalancers.LoadBalancer, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig, isLBOwner bool) ([]plannedOperation, error) {
+	planner := lbaas.withPlan()
+	if err := planner.ensureOctaviaListeners(loadbalancer, service, nodes, svcConf, isLBOwner); err != nil {
+		return nil, err
+	}
+
+	var ops []plannedOperation
+	for _, op := range planner.plan.operations {
+		if op.action == planActionDelete && (op.resource == "listener" || op.resource == "pool") {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// checkDestructiveChanges fails the reconciliation of an existing load balancer deleting or recreating more listeners
+// and pools than max-destructive-changes, unless the Service acknowledges them. The acknowledgment annotation is
+// left in place, it is only cleared by clearDestructiveChangesAcknowledgment once the reconciliation succeeded.
+func (lbaas *LbaasV2) checkDestructiveChanges(loadbalancer *loadbalancers.LoadBalancer, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig, isLBOwner bool) error {
+	if lbaas.opts.MaxDestructiveChanges <= 0 {
+		return nil
+	}
+
+	acknowledged := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges, false)
+
+	ops, err := lbaas.destructiveChanges(loadbalancer, service, nodes, svcConf, isLBOwner)
+	if err != nil {
+		return fmt.Errorf("failed to check the changes of load balancer %s: %v", loadbalancer.ID, err)
+	}
+	if len(ops) <= lbaas.opts.MaxDestructiveChanges {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(ops))
+	for _, op := range ops {
+		descriptions = append(descriptions, op.String())
+	}
+	changes := strings.Join(descriptions, "; ")
+	metrics.ObserveDestructiveChanges(service.Namespace, service.Name, acknowledged)
+
+	if acknowledged {
+		msg := "Applying the acknowledged deletion of %d listeners and pools of load balancer %s: %s"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBDestructiveChangesApplied, msg, len(ops), loadbalancer.ID, changes)
+		klog.InfoS("Applying acknowledged destructive changes", "service", klog.KObj(service), "lbID", loadbalancer.ID, "changes", changes)
+		return nil
+	}
+
+	msg := "Not deleting %d listeners and pools of load balancer %s, more than the %d allowed, set the annotation %s to \"true\" to proceed: %s"
+	lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBDestructiveChangesBlocked, msg,
+		len(ops), loadbalancer.ID, lbaas.opts.MaxDestructiveChanges, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges, changes)
+	return fmt.Errorf("the reconciliation would delete %d listeners and pools of load balancer %s, more than the %d allowed by max-destructive-changes without the annotation %s",
+		len(ops), loadbalancer.ID, lbaas.opts.MaxDestructiveChanges, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges)
+}
+
+// clearDestructiveChangesAcknowledgment removes the acknowledgment annotation after a successful reconciliation, so that
+// a failed one keeps it for the retry and it does not acknowledge the changes of later updates of the Service.
+func (lbaas *LbaasV2) clearDestructiveChangesAcknowledgment(service *corev1.Service) {
+	if lbaas.opts.MaxDestructiveChanges <= 0 {
+		return
+	}
+	delete(service.Annotations, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLbaasV2_checkDestructiveChanges(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	recorder := record.NewFakeRecorder(10)
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:            fakeclient.ServiceClient(),
+		opts:          LoadBalancerOpts{LBMethod: "ROUND_ROBIN"},
+		eventRecorder: recorder,
+	}}
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Protocol: corev1.ProtocolTCP, Port: 8080, NodePort: 30080},
+		}},
+	}
+	// The Service ports were changed from 80 and 443 to 8080 by mistake.
+	loadbalancer := &loadbalancers.LoadBalancer{ID: "lb-id", Listeners: []listeners.Listener{
+		{ID: "listener-80", Protocol: "TCP", ProtocolPort: 80, ConnLimit: -1},
+		{ID: "listener-443", Protocol: "TCP", ProtocolPort: 443, ConnLimit: -1},
+	}}
+	svcConf := &serviceConfig{lbName: "lb", connLimit: -1}
+
+	// Not limited
+	assert.NoError(t, lbaas.checkDestructiveChanges(loadbalancer, service, nil, svcConf, true))
+	assert.Empty(t, recorder.Events)
+
+	lbaas.opts.MaxDestructiveChanges = 2
+	assert.NoError(t, lbaas.checkDestructiveChanges(loadbalancer, service, nil, svcConf, true))
+	assert.Empty(t, recorder.Events)
+
+	lbaas.opts.MaxDestructiveChanges = 1
+	err := lbaas.checkDestructiveChanges(loadbalancer, service, nil, svcConf, true)
+	assert.ErrorContains(t, err, "would delete 2 listeners and pools of load balancer lb-id")
+	assert.Contains(t, <-recorder.Events, "LoadBalancerDestructiveChangesBlocked")
+
+	service.Annotations[ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges] = "true"
+	assert.NoError(t, lbaas.checkDestructiveChanges(loadbalancer, service, nil, svcConf, true))
+	assert.Contains(t, <-recorder.Events, "delete listener listener-80")
+	// The acknowledgment is kept until the reconciliation succeeded
+	assert.Contains(t, service.Annotations, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges)
+	assert.NoError(t, lbaas.checkDestructiveChanges(loadbalancer, service, nil, svcConf, true))
+	assert.Contains(t, <-recorder.Events, "delete listener listener-80")
+
+	lbaas.clearDestructiveChangesAcknowledgment(service)
+	assert.NotContains(t, service.Annotations, ServiceAnnotationLoadBalancerAcknowledgeDestructiveChanges)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// ServiceAnnotationLoadBalancerReconcileJournal is the Service annotation recording the progress of the load balancer
+// reconciliation. It is managed by occm.
+const ServiceAnnotationLoadBalancerReconcileJournal = "loadbalancer.openstack.org/reconcile-journal"
+
+// The reconciliation steps recorded in the journal, in order.
+const (
+	journalStepLoadBalancerCreated = "LoadBalancerCreated"
+	journalStepListenersEnsured    = "ListenersEnsured"
+	journalStepFloatingIPEnsured   = "FloatingIPEnsured"
+	journalStepCompleted           = "Completed"
+)
+
+var journalSteps = []string{journalStepLoadBalancerCreated, journalStepListenersEnsured, journalStepFloatingIPEnsured, journalStepCompleted}
+
+// reconcileJournal is the last completed step of the load balancer reconciliation and the resources it created.
+// A reconciliation interrupted before the load balancer ID annotation is saved resumes with the journaled load
+// balancer instead of looking it up by name.
+type reconcileJournal struct {
+	Step           string   `json:"step"`
+	LoadBalancerID string   `json:"lbID,omitempty"`
+	ListenerIDs    []string `json:"listenerIDs,omitempty"`
+	PoolIDs        []string `json:"poolIDs,omitempty"`
+}
+
+// getReconcileJournal returns the journal of the Service, nil if it has none or it cannot be parsed.
+func getReconcileJournal(service *corev1.Service) *reconcileJournal {
+	value := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerReconcileJournal, "")
+	if value == "" {
+		return nil
+	}
+
+	journal := &reconcileJournal{}
+	if err := json.Unmarshal([]byte(value), journal); err != nil {
+		klog.Warningf("Ignoring the invalid annotation %s of Service %s/%s: %v", ServiceAnnotationLoadBalancerReconcileJournal, service.Namespace, service.Name, err)
+		return nil
+	}
+
+	return journal
+}
+
+// recordReconcileStep records the step in the journal of the Service. The annotation is saved with the other
+// annotations at the end of the reconciliation.
+func (lbaas *LbaasV2) recordReconcileStep(service *corev1.Service, step, lbID string, lbListeners []listeners.Listener) {
+	journal := &reconcileJournal{Step: step, LoadBalancerID: lbID}
+	for _, listener := range lbListeners {
+		journal.ListenerIDs = append(journal.ListenerIDs, listener.ID)
+		if listener.DefaultPoolID != "" {
+			journal.PoolIDs = append(journal.PoolIDs, listener.DefaultPoolID)
+		}
+	}
+
+	value, err := json.Marshal(journal)
+	if err != nil {
+		klog.Warningf("Failed to serialize the reconcile journal of Service %s/%s: %v", service.Namespace, service.Name, err)
+		return
+	}
+	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerReconcileJournal, string(value))
+}
+
+// persistReconcileStep records the step in the journal of the Service and saves it right away, so that a crash before
+// the end of the reconciliation does not lose the progress nor the created load balancer. The step is only saved when
+// it goes past the saved journal or the resources changed: the reconciliations of an unchanged load balancer go
+// through the steps again without patching the Service, which would trigger another reconciliation. The Completed step
+// is saved with the other annotations at the end of the reconciliation.
+func (lbaas *LbaasV2) persistReconcileStep(ctx context.Context, service *corev1.Service, svcConf *serviceConfig, step string, loadbalancer *loadbalancers.LoadBalancer) {
+	base := service.DeepCopy()
+	lbaas.recordReconcileStep(service, step, loadbalancer.ID, loadbalancer.Listeners)
+
+	// The planned reconciliation of a dry-run Service applies nothing, there is nothing to save
+	journal := getReconcileJournal(service)
+	if lbaas.kclient == nil || lbaas.plan != nil || journal == nil || !journalProgressed(svcConf.savedJournal, journal) {
+		return
+	}
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, base, service); err != nil {
+		// The journal is saved again at the end of the reconciliation.
+		klog.Warningf("Failed to save the reconcile journal of Service %s/%s: %v", service.Namespace, service.Name, err)
+		return
+	}
+	svcConf.savedJournal = journal
+}
+
+// journalProgressed returns whether the journal is past the saved one: a later step or other resources.
+func journalProgressed(saved, journal *reconcileJournal) bool {
+	if saved == nil || saved.LoadBalancerID != journal.LoadBalancerID ||
+		!slices.Equal(saved.ListenerIDs, journal.ListenerIDs) || !slices.Equal(saved.PoolIDs, journal.PoolIDs) {
+		return true
+	}
+	return slices.Index(journalSteps, journal.Step) > slices.Index(journalSteps, saved.Step)
+}
+
+// getJournaledLoadBalancer returns the load balancer named lbName recorded in the journal of the Service, nil if there
+// is none or it is gone.
+func (lbaas *LbaasV2) getJournaledLoadBalancer(service *corev1.Service, lbName string) (*loadbalancers.LoadBalancer, error) {
+	journal := getReconcileJournal(service)
+	if journal == nil || journal.LoadBalancerID == "" {
+		return nil, nil
+	}
+
+	loadbalancer, err := openstackutil.GetLoadbalancerByID(lbaas.lb, journal.LoadBalancerID)
+	if err != nil {
+		if cpoerrors.IsNotFound(err) {
+			klog.InfoS("Load balancer of the reconcile journal not found", "lbID", journal.LoadBalancerID, "service", klog.KObj(service))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get load balancer %s of the reconcile journal: %v", journal.LoadBalancerID, err)
+	}
+
+	// Only trust the journal for the load balancer this Service owns.
+	if loadbalancer.Name != lbName {
+		klog.Warningf("Ignoring load balancer %s of the reconcile journal of Service %s/%s, its name %s is not %s",
+			loadbalancer.ID, service.Namespace, service.Name, loadbalancer.Name, lbName)
+		return nil, nil
+	}
+
+	return loadbalancer, nil
+}
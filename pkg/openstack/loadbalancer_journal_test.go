@@ -0,0 +1,228 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecordReconcileStep(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	lbaas := &LbaasV2{}
+
+	lbaas.recordReconcileStep(service, journalStepListenersEnsured, "lb-id", []listeners.Listener{
+		{ID: "listener-1", DefaultPoolID: "pool-1"},
+		{ID: "listener-2"},
+	})
+
+	assert.Equal(t, `{"step":"ListenersEnsured","lbID":"lb-id","listenerIDs":["listener-1","listener-2"],"poolIDs":["pool-1"]}`,
+		service.Annotations[ServiceAnnotationLoadBalancerReconcileJournal])
+	assert.Equal(t, &reconcileJournal{
+		Step:           journalStepListenersEnsured,
+		LoadBalancerID: "lb-id",
+		ListenerIDs:    []string{"listener-1", "listener-2"},
+		PoolIDs:        []string{"pool-1"},
+	}, getReconcileJournal(service))
+}
+
+func TestGetReconcileJournal(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    *reconcileJournal
+	}{
+		{
+			name:     "no annotation",
+			expected: nil,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerReconcileJournal: "{"},
+			expected:    nil,
+		},
+		{
+			name:        "valid annotation",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerReconcileJournal: `{"step":"LoadBalancerCreated","lbID":"lb-id"}`},
+			expected:    &reconcileJournal{Step: journalStepLoadBalancerCreated, LoadBalancerID: "lb-id"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Annotations: tc.annotations}}
+			assert.Equal(t, tc.expected, getReconcileJournal(service))
+		})
+	}
+}
+
+func TestPersistReconcileStep(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := &LbaasV2{LoadBalancer{kclient: kclient}}
+	saved := func() string {
+		svc, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+		assert.NoError(t, err)
+		return svc.Annotations[ServiceAnnotationLoadBalancerReconcileJournal]
+	}
+	patches := func() int {
+		n := 0
+		for _, action := range kclient.Actions() {
+			if action.GetVerb() == "patch" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The steps of the creation are saved right away
+	svcConf := &serviceConfig{savedJournal: getReconcileJournal(service)}
+	lb := &loadbalancers.LoadBalancer{ID: "lb-id"}
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepLoadBalancerCreated, lb)
+	assert.Equal(t, `{"step":"LoadBalancerCreated","lbID":"lb-id"}`, saved())
+
+	lb.Listeners = []listeners.Listener{{ID: "listener-id", DefaultPoolID: "pool-id"}}
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepListenersEnsured, lb)
+	assert.Equal(t, `{"step":"ListenersEnsured","lbID":"lb-id","listenerIDs":["listener-id"],"poolIDs":["pool-id"]}`, saved())
+
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepFloatingIPEnsured, lb)
+	assert.Equal(t, `{"step":"FloatingIPEnsured","lbID":"lb-id","listenerIDs":["listener-id"],"poolIDs":["pool-id"]}`, saved())
+	assert.Equal(t, 3, patches())
+
+	// The reconciliation of the unchanged load balancer doesn't patch the Service
+	lbaas.recordReconcileStep(service, journalStepCompleted, lb.ID, lb.Listeners)
+	_, err := kclient.CoreV1().Services("default").Update(context.TODO(), service, v1.UpdateOptions{})
+	assert.NoError(t, err)
+	svcConf = &serviceConfig{savedJournal: getReconcileJournal(service)}
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepListenersEnsured, lb)
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepFloatingIPEnsured, lb)
+	assert.Equal(t, 3, patches())
+	assert.Contains(t, saved(), `"step":"Completed"`)
+
+	// A new listener is saved right away
+	lb.Listeners = append(lb.Listeners, listeners.Listener{ID: "listener-2"})
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepListenersEnsured, lb)
+	assert.Equal(t, 4, patches())
+	assert.Equal(t, `{"step":"ListenersEnsured","lbID":"lb-id","listenerIDs":["listener-id","listener-2"],"poolIDs":["pool-id"]}`, saved())
+}
+
+func TestPersistReconcileStepDryRun(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := (&LbaasV2{LoadBalancer{kclient: kclient}}).withPlan()
+
+	svcConf := &serviceConfig{}
+	lb := &loadbalancers.LoadBalancer{ID: "lb-id"}
+	lbaas.persistReconcileStep(context.TODO(), service, svcConf, journalStepLoadBalancerCreated, lb)
+
+	for _, action := range kclient.Actions() {
+		assert.NotEqual(t, "patch", action.GetVerb(), "the planned reconciliation patched the Service")
+	}
+	assert.Nil(t, svcConf.savedJournal)
+}
+
+func TestJournalProgressed(t *testing.T) {
+	saved := &reconcileJournal{Step: journalStepListenersEnsured, LoadBalancerID: "lb-id", ListenerIDs: []string{"l1"}, PoolIDs: []string{"p1"}}
+
+	testCases := []struct {
+		name     string
+		saved    *reconcileJournal
+		journal  *reconcileJournal
+		expected bool
+	}{
+		{name: "nothing saved", journal: saved, expected: true},
+		{name: "same step", saved: saved, journal: saved},
+		{name: "earlier step", saved: saved, journal: &reconcileJournal{Step: journalStepLoadBalancerCreated, LoadBalancerID: "lb-id", ListenerIDs: []string{"l1"}, PoolIDs: []string{"p1"}}},
+		{name: "later step", saved: saved, journal: &reconcileJournal{Step: journalStepFloatingIPEnsured, LoadBalancerID: "lb-id", ListenerIDs: []string{"l1"}, PoolIDs: []string{"p1"}}, expected: true},
+		{name: "other load balancer", saved: saved, journal: &reconcileJournal{Step: journalStepLoadBalancerCreated, LoadBalancerID: "other-id"}, expected: true},
+		{name: "other listeners", saved: saved, journal: &reconcileJournal{Step: journalStepListenersEnsured, LoadBalancerID: "lb-id", ListenerIDs: []string{"l2"}, PoolIDs: []string{"p1"}}, expected: true},
+		{name: "other pools", saved: saved, journal: &reconcileJournal{Step: journalStepListenersEnsured, LoadBalancerID: "lb-id", ListenerIDs: []string{"l1"}}, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, journalProgressed(tc.saved, tc.journal))
+		})
+	}
+}
+
+func TestLbaasV2_getJournaledLoadBalancer(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	mockLoadBalancerActive(t, "lb-id")
+	th.Mux.HandleFunc("/lbaas/loadbalancers/gone-id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+
+	testCases := []struct {
+		name       string
+		journal    string
+		lbName     string
+		expectedID string
+	}{
+		{
+			name:   "no journal",
+			lbName: "kube_service_kubernetes_default_svc",
+		},
+		{
+			name:    "load balancer gone",
+			journal: `{"step":"LoadBalancerCreated","lbID":"gone-id"}`,
+			lbName:  "kube_service_kubernetes_default_svc",
+		},
+		{
+			name:    "load balancer of another Service",
+			journal: `{"step":"LoadBalancerCreated","lbID":"lb-id"}`,
+			lbName:  "kube_service_kubernetes_default_other",
+		},
+		{
+			name:       "resume",
+			journal:    `{"step":"LoadBalancerCreated","lbID":"lb-id"}`,
+			lbName:     "kube_service_kubernetes_default_svc",
+			expectedID: "lb-id",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+			if tc.journal != "" {
+				service.Annotations = map[string]string{ServiceAnnotationLoadBalancerReconcileJournal: tc.journal}
+			}
+
+			loadbalancer, err := lbaas.getJournaledLoadBalancer(service, tc.lbName)
+			assert.NoError(t, err)
+			if tc.expectedID == "" {
+				assert.Nil(t, loadbalancer)
+			} else {
+				assert.Equal(t, tc.expectedID, loadbalancer.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	sysos "os"
+	"time"
+
+	"github.com/google/uuid"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/keymutex"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// sharedLBLeasePrefix prefixes the name of the coordination.k8s.io Lease serializing the changes of a load balancer
+	// across the OCCM replicas, the Lease is named after the load balancer ID.
+	sharedLBLeasePrefix = "cpo-lb-"
+	// sharedLBLeaseNamespace is the namespace of the load balancer Leases.
+	sharedLBLeaseNamespace = "kube-system"
+)
+
+var (
+	// sharedLBLocks serializes the reconciliations of the Services sharing a load balancer across the workers.
+	sharedLBLocks = keymutex.NewHashed(0)
+	// sharedLBLeaseHolder identifies this OCCM in the load balancer Leases.
+	sharedLBLeaseHolder = newSharedLBLeaseHolder()
+)
+
+func newSharedLBLeaseHolder() string {
+	hostname, _ := sysos.Hostname()
+	return fmt.Sprintf("%s_%s", hostname, uuid.NewString()[:8])
+}
+
+// lockLoadBalancer serializes the changes of the load balancer set by the Service annotation with the other Services
+// sharing it. The changes are serialized across the workers, and across the OCCM replicas with a Lease when
+// shared-lb-lease-duration is set. It returns the function releasing the lock.
+func (lbaas *LbaasV2) lockLoadBalancer(ctx context.Context, service *corev1.Service) (func(), error) {
+	lbID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "")
+	if lbID == "" {
+		// The load balancer isn't shared before the annotation is set
+		return func() {}, nil
+	}
+
+	sharedLBLocks.LockKey(lbID)
+	unlock := func() {
+		_ = sharedLBLocks.UnlockKey(lbID)
+	}
+
+	if lbaas.opts.SharedLBLeaseDuration.Duration <= 0 || lbaas.kclient == nil {
+		return unlock, nil
+	}
+
+	if err := lbaas.acquireSharedLBLease(ctx, lbID); err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		lbaas.releaseSharedLBLease(context.WithoutCancel(ctx), lbID)
+		unlock()
+	}, nil
+}
+
+// acquireSharedLBLease takes the Lease of the load balancer for this OCCM. It returns an error when another OCCM
+// holds an unexpired Lease. The Lease is created or updated with the resource version it was read with, so only one
+// OCCM can take it.
+func (lbaas *LbaasV2) acquireSharedLBLease(ctx context.Context, lbID string) error {
+	leases := lbaas.kclient.CoordinationV1().Leases(sharedLBLeaseNamespace)
+	name := sharedLBLeasePrefix + lbID
+	now := metav1.NewMicroTime(time.Now())
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity:       ptr.To(sharedLBLeaseHolder),
+		LeaseDurationSeconds: ptr.To(int32(lbaas.opts.SharedLBLeaseDuration.Seconds())),
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sharedLBLeaseNamespace}, Spec: spec}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("load balancer %s is being updated by another OCCM", lbID)
+			}
+			return fmt.Errorf("failed to create the lease of load balancer %s: %v", lbID, err)
+		}
+		klog.V(4).InfoS("Acquired the lease of the load balancer", "lbID", lbID, "holder", sharedLBLeaseHolder)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get the lease of load balancer %s: %v", lbID, err)
+	}
+
+	if holder, expiry, ok := getSharedLBLease(lease); ok && holder != sharedLBLeaseHolder && now.Time.Before(expiry) {
+		return fmt.Errorf("load balancer %s is being updated by %s until %s", lbID, holder, expiry.Format(time.RFC3339))
+	}
+
+	lease = lease.DeepCopy()
+	lease.Spec = spec
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return fmt.Errorf("load balancer %s is being updated by another OCCM", lbID)
+		}
+		return fmt.Errorf("failed to update the lease of load balancer %s: %v", lbID, err)
+	}
+	klog.V(4).InfoS("Acquired the lease of the load balancer", "lbID", lbID, "holder", sharedLBLeaseHolder)
+
+	return nil
+}
+
+// releaseSharedLBLease deletes the Lease of the load balancer if it's still held by this OCCM, the Lease expires
+// otherwise.
+func (lbaas *LbaasV2) releaseSharedLBLease(ctx context.Context, lbID string) {
+	leases := lbaas.kclient.CoordinationV1().Leases(sharedLBLeaseNamespace)
+	name := sharedLBLeasePrefix + lbID
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to release the lease of the load balancer", "lbID", lbID)
+		}
+		return
+	}
+	if holder, _, ok := getSharedLBLease(lease); !ok || holder != sharedLBLeaseHolder {
+		return
+	}
+
+	// The Lease is only deleted if it wasn't taken over in the meantime
+	err = leases.Delete(ctx, name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion}})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		klog.ErrorS(err, "Failed to release the lease of the load balancer", "lbID", lbID)
+	}
+}
+
+// getSharedLBLease returns the holder and the expiry of the Lease.
+func getSharedLBLease(lease *coordinationv1.Lease) (string, time.Time, bool) {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return "", time.Time{}, false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return *lease.Spec.HolderIdentity, expiry, true
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	"k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+func TestLockLoadBalancerLease(t *testing.T) {
+	ctx := context.TODO()
+	kclient := fake.NewSimpleClientset()
+	lbaas := &LbaasV2{LoadBalancer{
+		opts:    LoadBalancerOpts{SharedLBLeaseDuration: util.MyDuration{Duration: time.Minute}},
+		kclient: kclient,
+	}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "ns",
+		Name:        "svc",
+		Annotations: map[string]string{ServiceAnnotationLoadBalancerID: "lb-id"},
+	}}
+	leases := kclient.CoordinationV1().Leases(sharedLBLeaseNamespace)
+
+	// The Lease is held while the load balancer is locked and deleted on unlock
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	assert.NoError(t, err)
+	lease, err := leases.Get(ctx, "cpo-lb-lb-id", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, sharedLBLeaseHolder, *lease.Spec.HolderIdentity)
+	assert.Equal(t, int32(60), *lease.Spec.LeaseDurationSeconds)
+	unlock()
+	_, err = leases.Get(ctx, "cpo-lb-lb-id", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// The unexpired Lease of another OCCM is respected
+	renewTime := metav1.NewMicroTime(time.Now())
+	_, err = leases.Create(ctx, &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "cpo-lb-lb-id", Namespace: sharedLBLeaseNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To("other"),
+			LeaseDurationSeconds: ptr.To(int32(60)),
+			RenewTime:            &renewTime,
+		},
+	}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = lbaas.lockLoadBalancer(ctx, service)
+	assert.ErrorContains(t, err, "being updated by other")
+
+	// The expired one is taken over, and kept on unlock if it was taken over again
+	lease, err = leases.Get(ctx, "cpo-lb-lb-id", metav1.GetOptions{})
+	assert.NoError(t, err)
+	expired := metav1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+	lease.Spec.RenewTime = &expired
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	unlock, err = lbaas.lockLoadBalancer(ctx, service)
+	assert.NoError(t, err)
+	lease, err = leases.Get(ctx, "cpo-lb-lb-id", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, sharedLBLeaseHolder, *lease.Spec.HolderIdentity)
+	lease.Spec.HolderIdentity = ptr.To("other")
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	unlock()
+	lease, err = leases.Get(ctx, "cpo-lb-lb-id", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "other", *lease.Spec.HolderIdentity)
+
+	// No Lease is taken before the load balancer ID is known
+	unlock, err = lbaas.lockLoadBalancer(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "new"}})
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestGetSharedLBLease(t *testing.T) {
+	renewTime := metav1.NewMicroTime(time.Unix(1700000000, 0))
+	lease := &coordinationv1.Lease{Spec: coordinationv1.LeaseSpec{
+		HolderIdentity:       ptr.To("host_1234"),
+		LeaseDurationSeconds: ptr.To(int32(120)),
+		RenewTime:            &renewTime,
+	}}
+
+	holder, expiry, ok := getSharedLBLease(lease)
+	assert.True(t, ok)
+	assert.Equal(t, "host_1234", holder)
+	assert.True(t, time.Unix(1700000120, 0).Equal(expiry))
+
+	lease.Spec.HolderIdentity = ptr.To("")
+	_, _, ok = getSharedLBLease(lease)
+	assert.False(t, ok)
+
+	_, _, ok = getSharedLBLease(&coordinationv1.Lease{})
+	assert.False(t, ok)
+}
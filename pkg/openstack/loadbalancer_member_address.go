@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	neutronports "github.com/gophercloud/gophercloud/v2/openstack/networking/v2/ports"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// setMemberAddressSelection configures how the addresses of the members are selected on the nodes with several
+// addresses, e.g. with several NICs, according to the member-address-cidrs and member-network-id annotations, or on
+// the nodes whose addresses aren't stable according to the member DNS domain. Without them, the first InternalIP or
+// ExternalIP of the nodes is used.
+func (lbaas *LbaasV2) setMemberAddressSelection(ctx context.Context, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig) error {
+	cidrs := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberAddressCIDRs, "")
+	networkID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberNetworkID, "")
+	if cidrs != "" && networkID != "" {
+		return fmt.Errorf("annotations %s and %s are mutually exclusive",
+			ServiceAnnotationLoadBalancerMemberAddressCIDRs, ServiceAnnotationLoadBalancerMemberNetworkID)
+	}
+	if (cidrs != "" || networkID != "") && getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberDNSDomain, "") != "" {
+		return fmt.Errorf("annotation %s is mutually exclusive with %s and %s", ServiceAnnotationLoadBalancerMemberDNSDomain,
+			ServiceAnnotationLoadBalancerMemberAddressCIDRs, ServiceAnnotationLoadBalancerMemberNetworkID)
+	}
+
+	if domain := lbaas.memberDNSDomain(service); domain != "" {
+		nodeNames := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			nodeNames = append(nodeNames, node.Name)
+		}
+		addrs, err := resolveMemberAddresses(ctx, nodeNames, domain, svcConf.preferredIPFamily)
+		if err != nil {
+			return err
+		}
+		svcConf.memberNodeAddresses = addrs
+		return nil
+	}
+
+	if cidrs != "" {
+		parsed, err := netutils.ParseCIDRs(strings.Split(strings.ReplaceAll(cidrs, " ", ""), ","))
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation %q: %v", ServiceAnnotationLoadBalancerMemberAddressCIDRs, cidrs, err)
+		}
+		svcConf.memberAddressCIDRs = parsed
+	}
+
+	if networkID != "" {
+		addrs, err := lbaas.getNodeAddressesOnNetwork(ctx, networkID, nodes, svcConf.preferredIPFamily)
+		if err != nil {
+			return err
+		}
+		svcConf.memberNodeAddresses = addrs
+	}
+
+	return nil
+}
+
+// getNodeAddressesOnNetwork returns the addresses of the ports of the nodes on the network, indexed by node name.
+func (lbaas *LbaasV2) getNodeAddressesOnNetwork(ctx context.Context, networkID string, nodes []*corev1.Node, preferredIPFamily corev1.IPFamily) (map[string]string, error) {
+	nodeNames := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		serverID, _, err := instanceIDFromProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting server ID from the node %s: %w", node.Name, err)
+		}
+		nodeNames[serverID] = node.Name
+	}
+
+	ports, err := openstackutil.GetPorts[neutronports.Port](ctx, lbaas.network, neutronports.ListOpts{NetworkID: networkID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ports of network %s: %v", networkID, err)
+	}
+
+	addrs := make(map[string]string, len(nodes))
+	for _, port := range ports {
+		nodeName, ok := nodeNames[port.DeviceID]
+		if !ok {
+			continue
+		}
+		if _, ok := addrs[nodeName]; ok {
+			continue
+		}
+		for _, fixedIP := range port.FixedIPs {
+			if addressInIPFamily(fixedIP.IPAddress, preferredIPFamily) {
+				addrs[nodeName] = fixedIP.IPAddress
+				break
+			}
+		}
+	}
+
+	if missing := len(nodeNames) - len(addrs); missing > 0 {
+		klog.Warningf("%d nodes have no port with an address on the member network %s", missing, networkID)
+	}
+
+	return addrs, nil
+}
+
+// nodeAddressInCIDRs returns the first InternalIP or ExternalIP of the node in one of the CIDRs.
+func nodeAddressInCIDRs(node *corev1.Node, preferredIPFamily corev1.IPFamily, cidrs []*net.IPNet) (string, error) {
+	for _, allowedAddrType := range nodeAddressTypesForLB {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != allowedAddrType || !addressInIPFamily(addr.Address, preferredIPFamily) {
+				continue
+			}
+			ip := netutils.ParseIPSloppy(addr.Address)
+			for _, cidr := range cidrs {
+				if ip != nil && cidr.Contains(ip) {
+					return addr.Address, nil
+				}
+			}
+		}
+	}
+
+	return "", cpoerrors.ErrNoAddressFound
+}
+
+// memberAddressForLB returns the address of the node used as member of the load balancer of the Service.
+func memberAddressForLB(node *corev1.Node, svcConf *serviceConfig) (string, error) {
+	if svcConf.memberNodeAddresses != nil {
+		addr, ok := svcConf.memberNodeAddresses[node.Name]
+		if !ok {
+			return "", cpoerrors.ErrNoAddressFound
+		}
+		return addr, nil
+	}
+
+	if len(svcConf.memberAddressCIDRs) > 0 {
+		return nodeAddressInCIDRs(node, svcConf.preferredIPFamily, svcConf.memberAddressCIDRs)
+	}
+
+	return nodeAddressForLB(node, svcConf.preferredIPFamily)
+}
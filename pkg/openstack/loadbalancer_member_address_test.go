@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+func multiNICNode(name, serverID string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{ProviderID: "openstack:///" + serverID},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: corev1.NodeInternalIP, Address: "192.168.1.10"},
+				{Type: corev1.NodeInternalIP, Address: "fd00::10"},
+				{Type: corev1.NodeExternalIP, Address: "172.24.4.10"},
+			},
+		},
+	}
+}
+
+func TestMemberAddressForLB(t *testing.T) {
+	node := multiNICNode("node", "server")
+
+	testCases := []struct {
+		name         string
+		annotations  map[string]string
+		ipFamily     corev1.IPFamily
+		expectedAddr string
+		expectedErr  error
+	}{
+		{name: "first address", expectedAddr: "10.0.0.10"},
+		{name: "IPv6", ipFamily: corev1.IPv6Protocol, expectedAddr: "fd00::10"},
+		{
+			name:         "CIDR of the second NIC",
+			annotations:  map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "192.168.0.0/16"},
+			expectedAddr: "192.168.1.10",
+		},
+		{
+			name:         "internal address preferred to external address",
+			annotations:  map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "172.24.4.0/24, 192.168.0.0/16"},
+			expectedAddr: "192.168.1.10",
+		},
+		{
+			name:         "external address",
+			annotations:  map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "172.24.4.0/24"},
+			expectedAddr: "172.24.4.10",
+		},
+		{
+			name:         "IPv6 CIDR",
+			annotations:  map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "192.168.0.0/16,fd00::/64"},
+			ipFamily:     corev1.IPv6Protocol,
+			expectedAddr: "fd00::10",
+		},
+		{
+			name:        "no address in CIDR",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "10.1.0.0/16"},
+			expectedErr: cpoerrors.ErrNoAddressFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations}}
+			svcConf := &serviceConfig{preferredIPFamily: tc.ipFamily}
+			lbaas := &LbaasV2{}
+			assert.NoError(t, lbaas.setMemberAddressSelection(context.TODO(), service, []*corev1.Node{node}, svcConf))
+
+			addr, err := memberAddressForLB(node, svcConf)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedAddr, addr)
+		})
+	}
+}
+
+func TestSetMemberAddressSelectionInvalid(t *testing.T) {
+	lbaas := &LbaasV2{}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerMemberAddressCIDRs: "192.168.0.0",
+	}}}
+	err := lbaas.setMemberAddressSelection(context.TODO(), service, nil, &serviceConfig{})
+	assert.ErrorContains(t, err, "invalid loadbalancer.openstack.org/member-address-cidrs annotation")
+
+	service.Annotations[ServiceAnnotationLoadBalancerMemberNetworkID] = "network"
+	err = lbaas.setMemberAddressSelection(context.TODO(), service, nil, &serviceConfig{})
+	assert.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestMemberAddressOnNetwork(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		th.TestFormValues(t, r, map[string]string{"network_id": "data-plane"})
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ports": [
+			{"id": "port1", "device_id": "server1", "network_id": "data-plane", "fixed_ips": [{"subnet_id": "v6", "ip_address": "fd01::11"}, {"subnet_id": "v4", "ip_address": "192.168.2.11"}]},
+			{"id": "port2", "device_id": "server2", "network_id": "data-plane", "fixed_ips": [{"subnet_id": "v4", "ip_address": "192.168.2.12"}]},
+			{"id": "port3", "device_id": "other-server", "network_id": "data-plane", "fixed_ips": [{"subnet_id": "v4", "ip_address": "192.168.2.13"}]}
+		]}`)
+	})
+
+	nodes := []*corev1.Node{multiNICNode("node1", "server1"), multiNICNode("node2", "server2"), multiNICNode("node3", "server3")}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerMemberNetworkID: "data-plane",
+	}}}
+	svcConf := &serviceConfig{preferredIPFamily: corev1.IPv4Protocol}
+	lbaas := &LbaasV2{LoadBalancer{network: fakeclient.ServiceClient()}}
+	assert.NoError(t, lbaas.setMemberAddressSelection(context.TODO(), service, nodes, svcConf))
+
+	addr, err := memberAddressForLB(nodes[0], svcConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.2.11", addr)
+
+	addr, err = memberAddressForLB(nodes[1], svcConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.2.12", addr)
+
+	// The node has no port on the network, it is not a member.
+	_, err = memberAddressForLB(nodes[2], svcConf)
+	assert.Equal(t, cpoerrors.ErrNoAddressFound, err)
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// lookupIPAddr resolves the hostnames of the members, overridden by the tests.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// memberDNSLookupTimeout bounds the resolution of the hostname of a member.
+const memberDNSLookupTimeout = 5 * time.Second
+
+// memberDNSDomain returns the DNS domain the node names of the members of the Service are resolved in, empty when the
+// node addresses are used. The member-dns-domain option only applies to the Services selecting the member addresses
+// with neither member-address-cidrs nor member-network-id.
+func (lbaas *LbaasV2) memberDNSDomain(service *corev1.Service) string {
+	if domain := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberDNSDomain, ""); domain != "" {
+		return domain
+	}
+	if getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberAddressCIDRs, "") != "" ||
+		getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerMemberNetworkID, "") != "" {
+		return ""
+	}
+	return lbaas.opts.MemberDNSDomain
+}
+
+// memberHostname returns the fully qualified hostname of the node in the domain, the node name is kept when it's
+// already in the domain.
+func memberHostname(nodeName, domain string) string {
+	domain = strings.Trim(domain, ".")
+	nodeName = strings.TrimSuffix(nodeName, ".")
+	if strings.HasSuffix(nodeName, "."+domain) {
+		return nodeName + "."
+	}
+	return nodeName + "." + domain + "."
+}
+
+// resolveMemberAddress returns the first address of the hostname of the node in the IP family.
+func resolveMemberAddress(ctx context.Context, nodeName, domain string, ipFamily corev1.IPFamily) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, memberDNSLookupTimeout)
+	defer cancel()
+
+	addrs, err := lookupIPAddr(ctx, memberHostname(nodeName, domain))
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ip := addr.IP.String(); addressInIPFamily(ip, ipFamily) {
+			return ip, nil
+		}
+	}
+	return "", cpoerrors.ErrNoAddressFound
+}
+
+// resolveMemberAddresses returns the addresses of the hostnames of the nodes, indexed by node name. The nodes whose
+// hostname doesn't exist or has no address in the IP family are not members. Any other resolver error, e.g. a timeout,
+// fails the resolution, so that the members aren't removed because of a DNS outage.
+func resolveMemberAddresses(ctx context.Context, nodeNames []string, domain string, ipFamily corev1.IPFamily) (map[string]string, error) {
+	addrs := make(map[string]string, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		addr, err := resolveMemberAddress(ctx, nodeName, domain, ipFamily)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if err != cpoerrors.ErrNoAddressFound && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+				return nil, fmt.Errorf("failed to resolve the member address of node %s in %s: %v", nodeName, domain, err)
+			}
+			klog.Warningf("No member address of node %s in %s: %v", nodeName, domain, err)
+			continue
+		}
+		addrs[nodeName] = addr
+	}
+	return addrs, nil
+}
+
+// buildMemberAddressUpdateOpts returns the members of the pool with the addresses of their node, alongside whether any
+// of them changed. The members whose node isn't resolved keep their address.
+func buildMemberAddressUpdateOpts(poolMembers []v2pools.Member, addrs map[string]string) ([]v2pools.BatchUpdateMemberOpts, bool) {
+	changed := false
+	members := make([]v2pools.BatchUpdateMemberOpts, 0, len(poolMembers))
+	for _, m := range poolMembers {
+		address := m.Address
+		if addr, ok := addrs[m.Name]; ok && addr != m.Address {
+			address = addr
+			changed = true
+		}
+		member := v2pools.BatchUpdateMemberOpts{
+			Address:      address,
+			ProtocolPort: m.ProtocolPort,
+			Name:         ptr.To(m.Name),
+			Weight:       ptr.To(m.Weight),
+			AdminStateUp: ptr.To(m.AdminStateUp),
+			Backup:       ptr.To(m.Backup),
+			Tags:         m.Tags,
+		}
+		if m.SubnetID != "" {
+			member.SubnetID = ptr.To(m.SubnetID)
+		}
+		if m.MonitorAddress != "" {
+			member.MonitorAddress = ptr.To(m.MonitorAddress)
+		}
+		if m.MonitorPort != 0 {
+			member.MonitorPort = ptr.To(m.MonitorPort)
+		}
+		members = append(members, member)
+	}
+	return members, changed
+}
+
+// refreshMemberDNSAddresses resolves the hostnames of the nodes of the existing members of the pools of the Service
+// again, and updates the members whose address changed. The members themselves are still managed by
+// EnsureLoadBalancer and UpdateLoadBalancer.
+func (lbaas *LbaasV2) refreshMemberDNSAddresses(ctx context.Context, clusterName string, service *corev1.Service) error {
+	if lbaas.isDryRun(service) || lbaas.isPaused(service) {
+		return nil
+	}
+	merged, err := lbaas.withLoadBalancerProfile(ctx, service)
+	if err != nil {
+		return err
+	}
+	domain := lbaas.memberDNSDomain(merged)
+	if domain == "" || getBoolFromServiceAnnotation(merged, ServiceAnnotationLoadBalancerEndpointMembers, false) {
+		return nil
+	}
+
+	svcConf := new(serviceConfig)
+	if err := lbaas.checkServiceDelete(service, svcConf); err != nil {
+		return err
+	}
+	if svcConf.lbID == "" {
+		// The load balancer doesn't exist yet, EnsureLoadBalancer will set the members.
+		return nil
+	}
+	if len(service.Spec.IPFamilies) > 0 {
+		svcConf.preferredIPFamily = service.Spec.IPFamilies[0]
+	}
+
+	unlock, err := lbaas.lockLoadBalancer(ctx, service)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	listenerList, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, svcConf.lbID)
+	if err != nil {
+		return fmt.Errorf("error getting LB %s listeners: %v", svcConf.lbID, err)
+	}
+	curListenerMapping := getListenerMapping(listenerList)
+
+	// The hostnames are resolved once for all the pools
+	addrs := make(map[string]string)
+	for _, port := range service.Spec.Ports {
+		listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
+		if !isPresent {
+			continue
+		}
+		pool, err := openstackutil.GetPoolByListener(lbaas.lb, svcConf.lbID, listener.ID)
+		if err != nil {
+			if err == cpoerrors.ErrNotFound {
+				continue
+			}
+			return fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
+		}
+		poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
+		if err != nil {
+			return fmt.Errorf("error getting members of pool %s: %v", pool.ID, err)
+		}
+
+		var nodeNames []string
+		for _, m := range poolMembers {
+			if _, ok := addrs[m.Name]; !ok {
+				nodeNames = append(nodeNames, m.Name)
+			}
+		}
+		resolved, err := resolveMemberAddresses(ctx, nodeNames, domain, svcConf.preferredIPFamily)
+		if err != nil {
+			return err
+		}
+		for nodeName, addr := range resolved {
+			addrs[nodeName] = addr
+		}
+
+		members, changed := buildMemberAddressUpdateOpts(poolMembers, addrs)
+		if !changed {
+			continue
+		}
+		klog.InfoS("Updating the member addresses resolved in the DNS", "poolID", pool.ID, "lbID", svcConf.lbID, "service", klog.KObj(service))
+		if err := openstackutil.BatchUpdatePoolMembers(lbaas.lb, svcConf.lbID, pool.ID, members); err != nil {
+			return err
+		}
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBMemberAddressesRefreshed, "Updated the member addresses of pool %s resolved in %s", pool.ID, domain)
+	}
+	return nil
+}
+
+// runMemberDNSRefresh periodically refreshes the member addresses of the LoadBalancer Services resolved in the DNS, so
+// that the pools follow the nodes whose address changed without waiting for a node update.
+func (lbaas *LbaasV2) runMemberDNSRefresh(clusterName string, serviceLister corelisters.ServiceLister, hasSynced cache.InformerSynced, interval time.Duration, stopCh <-chan struct{}) {
+	if !cache.WaitForCacheSync(stopCh, hasSynced) {
+		klog.Error("Timed out waiting for the Service cache to sync")
+		return
+	}
+
+	ctx := wait.ContextForChannel(stopCh)
+	wait.Until(func() {
+		services, err := serviceLister.List(labels.Everything())
+		if err != nil {
+			klog.Errorf("Failed to list Services: %v", err)
+			return
+		}
+		for _, service := range services {
+			if service.Spec.Type != corev1.ServiceTypeLoadBalancer || !lbaas.managesService(service) {
+				continue
+			}
+			if err := lbaas.refreshMemberDNSAddresses(ctx, clusterName, service); err != nil {
+				klog.Errorf("Failed to refresh the member addresses of Service %s: %v", klog.KObj(service), err)
+			}
+		}
+	}, interval, stopCh)
+}
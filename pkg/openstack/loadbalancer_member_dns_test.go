@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+func fakeLookupIPAddr(t *testing.T, records map[string][]string) {
+	oldLookupIPAddr := lookupIPAddr
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		ips, ok := records[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if ips == nil {
+			return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+		}
+		var addrs []net.IPAddr
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return addrs, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = oldLookupIPAddr })
+}
+
+func TestMemberHostname(t *testing.T) {
+	assert.Equal(t, "node1.k8s.example.com.", memberHostname("node1", "k8s.example.com."))
+	assert.Equal(t, "node1.k8s.example.com.", memberHostname("node1", "k8s.example.com"))
+	assert.Equal(t, "node1.k8s.example.com.", memberHostname("node1.k8s.example.com", "k8s.example.com."))
+}
+
+func TestMemberAddressFromDNS(t *testing.T) {
+	fakeLookupIPAddr(t, map[string][]string{
+		"node1.k8s.example.com.": {"fd00::11", "10.0.0.11"},
+		"node2.k8s.example.com.": {"10.0.0.12"},
+	})
+	nodes := []*corev1.Node{multiNICNode("node1", "server1"), multiNICNode("node2", "server2"), multiNICNode("node3", "server3")}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerMemberDNSDomain: "k8s.example.com.",
+	}}}
+	svcConf := &serviceConfig{preferredIPFamily: corev1.IPv4Protocol}
+	lbaas := &LbaasV2{}
+	assert.NoError(t, lbaas.setMemberAddressSelection(context.TODO(), service, nodes, svcConf))
+
+	addr, err := memberAddressForLB(nodes[0], svcConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.11", addr)
+
+	addr, err = memberAddressForLB(nodes[1], svcConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.12", addr)
+
+	// The hostname of the node doesn't resolve, it is not a member.
+	_, err = memberAddressForLB(nodes[2], svcConf)
+	assert.Equal(t, cpoerrors.ErrNoAddressFound, err)
+
+	// The default domain of the configuration is ignored with member-address-cidrs
+	lbaas = &LbaasV2{LoadBalancer{opts: LoadBalancerOpts{MemberDNSDomain: "k8s.example.com"}}}
+	assert.Equal(t, "k8s.example.com", lbaas.memberDNSDomain(&corev1.Service{}))
+	service.Annotations = map[string]string{ServiceAnnotationLoadBalancerMemberAddressCIDRs: "192.168.0.0/16"}
+	assert.Equal(t, "", lbaas.memberDNSDomain(service))
+
+	service.Annotations[ServiceAnnotationLoadBalancerMemberDNSDomain] = "k8s.example.com"
+	err = lbaas.setMemberAddressSelection(context.TODO(), service, nodes, &serviceConfig{})
+	assert.ErrorContains(t, err, "mutually exclusive")
+}
+
+func TestResolveMemberAddressesFailure(t *testing.T) {
+	fakeLookupIPAddr(t, map[string][]string{
+		"node1.k8s.example.com.": {"10.0.0.11"},
+		"node2.k8s.example.com.": nil,
+	})
+
+	// A resolver failure fails the resolution instead of dropping the member.
+	_, err := resolveMemberAddresses(context.TODO(), []string{"node1", "node2"}, "k8s.example.com", corev1.IPv4Protocol)
+	assert.ErrorContains(t, err, "node2")
+
+	addrs, err := resolveMemberAddresses(context.TODO(), []string{"node1", "node3"}, "k8s.example.com", corev1.IPv4Protocol)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"node1": "10.0.0.11"}, addrs)
+}
+
+func TestBuildMemberAddressUpdateOpts(t *testing.T) {
+	poolMembers := []v2pools.Member{
+		{Name: "node1", Address: "10.0.0.11", ProtocolPort: 30000, Weight: 1, AdminStateUp: true, SubnetID: "subnet"},
+		{Name: "node2", Address: "10.0.0.12", ProtocolPort: 30000, Weight: 0, AdminStateUp: true, MonitorPort: 32000},
+	}
+
+	_, changed := buildMemberAddressUpdateOpts(poolMembers, map[string]string{"node1": "10.0.0.11"})
+	assert.False(t, changed)
+
+	members, changed := buildMemberAddressUpdateOpts(poolMembers, map[string]string{"node2": "10.0.0.22"})
+	assert.True(t, changed)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "10.0.0.11", members[0].Address)
+	assert.Equal(t, "subnet", *members[0].SubnetID)
+	assert.Equal(t, "10.0.0.22", members[1].Address)
+	assert.Equal(t, 0, *members[1].Weight)
+	assert.Equal(t, 32000, *members[1].MonitorPort)
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/monitors"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// migrateInTreeLoadBalancers renames the load balancers created by the legacy in-tree OpenStack provider, named
+// after the Service UID, and their listeners, pools and health monitors to the naming conventions of this provider.
+// The floating IPs they use get the description of the floating IPs created by this provider, so that they are
+// managed the same way. The security groups of both providers are named alike and are left as is. The ID of the
+// migrated load balancers is recorded on their Service.
+func (lbaas *LbaasV2) migrateInTreeLoadBalancers(ctx context.Context, clusterName string) error {
+	services, err := lbaas.kclient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list Services: %v", err)
+	}
+
+	var migrated, failed int
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer || getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerID, "") != "" {
+			continue
+		}
+		if lbaas.isPaused(service) {
+			klog.InfoS("Reconciliation paused, skipping the migration of the load balancer", "service", klog.KObj(service))
+			continue
+		}
+
+		ok, err := lbaas.migrateInTreeLoadBalancer(ctx, clusterName, service)
+		if err != nil {
+			klog.ErrorS(err, "Failed to migrate the load balancer created by the in-tree provider", "service", klog.KObj(service))
+			failed++
+		} else if ok {
+			migrated++
+		}
+	}
+	klog.InfoS("Migrated the load balancers created by the in-tree provider", "migrated", migrated, "failed", failed, "dryRun", lbaas.opts.DryRun)
+
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d load balancers created by the in-tree provider", failed)
+	}
+	return nil
+}
+
+// migrateInTreeLoadBalancer migrates the load balancer of the Service if it still has its in-tree name. The children
+// are renamed first, so that the migration is retried as long as the load balancer keeps its in-tree name.
+func (lbaas *LbaasV2) migrateInTreeLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service) (bool, error) {
+	legacyName := lbaas.getLoadBalancerLegacyName(service)
+	lbName := lbaas.GetLoadBalancerName(ctx, clusterName, service)
+
+	loadbalancer, err := openstackutil.GetLoadbalancerByName(lbaas.lb, legacyName)
+	if err != nil {
+		if err == cpoerrors.ErrNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get load balancer %s: %v", legacyName, err)
+	}
+
+	if lbaas.opts.DryRun {
+		msg := "Would migrate load balancer %s created by the in-tree provider to %s"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBDryRun, msg, loadbalancer.ID, lbName)
+		klog.InfoS("Would migrate the load balancer created by the in-tree provider", "service", klog.KObj(service), "lbID", loadbalancer.ID, "lbName", lbName)
+		return true, nil
+	}
+
+	if err := lbaas.migrateInTreeLoadBalancerChildren(loadbalancer.ID, legacyName, lbName); err != nil {
+		return false, err
+	}
+
+	if err := lbaas.migrateInTreeFloatingIP(ctx, clusterName, service, loadbalancer); err != nil {
+		return false, err
+	}
+
+	description := fmt.Sprintf("Kubernetes external service %s/%s from cluster %s", service.Namespace, service.Name, clusterName)
+	if _, err := openstackutil.UpdateLoadBalancer(lbaas.lb, loadbalancer.ID, loadbalancers.UpdateOpts{Name: &lbName, Description: &description}); err != nil {
+		return false, fmt.Errorf("failed to rename load balancer %s to %s: %v", loadbalancer.ID, lbName, err)
+	}
+
+	// The Service adopts the load balancer as if it created it, the next reconciliation records the ID otherwise
+	updated := service.DeepCopy()
+	lbaas.updateServiceAnnotation(updated, ServiceAnnotationLoadBalancerID, loadbalancer.ID)
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, service, updated); err != nil {
+		klog.Warningf("Failed to record the ID of load balancer %s on Service %s/%s: %v", loadbalancer.ID, service.Namespace, service.Name, err)
+	}
+
+	msg := "Migrated load balancer %s created by the in-tree provider to %s"
+	lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBInTreeMigrated, msg, loadbalancer.ID, lbName)
+	klog.InfoS("Migrated the load balancer created by the in-tree provider", "service", klog.KObj(service), "lbID", loadbalancer.ID, "lbName", lbName)
+
+	return true, nil
+}
+
+// migrateInTreeLoadBalancerChildren renames the listeners, pools and health monitors named after the in-tree name
+// of the load balancer.
+func (lbaas *LbaasV2) migrateInTreeLoadBalancerChildren(lbID, legacyName, lbName string) error {
+	lbPools, err := openstackutil.GetPools(lbaas.lb, lbID)
+	if err != nil {
+		return err
+	}
+	for _, pool := range lbPools {
+		if pool.MonitorID != "" {
+			monitor, err := openstackutil.GetHealthMonitor(lbaas.lb, pool.MonitorID)
+			if err != nil {
+				return err
+			}
+			if name, ok := getMigratedName(monitor.Name, monitorPrefix, legacyName, lbName); ok {
+				if err := openstackutil.UpdateHealthMonitor(lbaas.lb, monitor.ID, monitors.UpdateOpts{Name: &name}, lbID); err != nil {
+					return err
+				}
+			}
+		}
+		if name, ok := getMigratedName(pool.Name, poolPrefix, legacyName, lbName); ok {
+			if err := openstackutil.UpdatePool(lbaas.lb, lbID, pool.ID, pools.UpdateOpts{Name: &name}); err != nil {
+				return err
+			}
+		}
+	}
+
+	lbListeners, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, lbID)
+	if err != nil {
+		return err
+	}
+	for _, listener := range lbListeners {
+		if name, ok := getMigratedName(listener.Name, listenerPrefix, legacyName, lbName); ok {
+			if err := openstackutil.UpdateListener(lbaas.lb, lbID, listener.ID, listeners.UpdateOpts{Name: &name}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateInTreeFloatingIP sets the description of the floating IPs created by this provider on the floating IP of the
+// load balancer, the in-tree provider created them without description. The floating IP requested by the Service is
+// left as is.
+func (lbaas *LbaasV2) migrateInTreeFloatingIP(ctx context.Context, clusterName string, service *corev1.Service, loadbalancer *loadbalancers.LoadBalancer) error {
+	fip, err := openstackutil.GetFloatingIPByPortID(ctx, lbaas.network, loadbalancer.VipPortID)
+	if err != nil {
+		return fmt.Errorf("failed to get the floating IP of load balancer %s: %v", loadbalancer.ID, err)
+	}
+	// The floating IP requested by the Service was likely allocated beforehand
+	if fip == nil || fip.Description != "" || fip.FloatingIP == service.Spec.LoadBalancerIP {
+		return nil
+	}
+
+	description := getFloatingIPDescription(clusterName, fmt.Sprintf("%s/%s", service.Namespace, service.Name))
+	mc := metrics.NewMetricContext("floating_ip", "update")
+	_, err = floatingips.Update(ctx, lbaas.network, fip.ID, floatingips.UpdateOpts{Description: &description}).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("failed to update the description of floating IP %s: %v", fip.FloatingIP, err)
+	}
+
+	return nil
+}
+
+// getMigratedName returns the name of a load balancer child after the migration, the in-tree provider named them
+// like this provider does, after the in-tree name of the load balancer.
+func getMigratedName(name, prefix, legacyName, lbName string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "_"+legacyName) {
+		return "", false
+	}
+	return cpoutil.CutString255(strings.TrimSuffix(name, legacyName) + lbName), true
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/monitors"
+	v2pools "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetMigratedName(t *testing.T) {
+	legacyName := "a8e5b5c3c1d2e4f5a6b7c8d9e0f1a2b3"
+	lbName := "kube_service_kubernetes_default_web"
+
+	tests := []struct {
+		name     string
+		object   string
+		prefix   string
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "listener",
+			object:   "listener_0_" + legacyName,
+			prefix:   listenerPrefix,
+			expected: "listener_0_" + lbName,
+			ok:       true,
+		},
+		{
+			name:     "monitor",
+			object:   "monitor_1_" + legacyName,
+			prefix:   monitorPrefix,
+			expected: "monitor_1_" + lbName,
+			ok:       true,
+		},
+		{
+			name:   "already migrated",
+			object: "pool_0_" + lbName,
+			prefix: poolPrefix,
+		},
+		{
+			name:   "not created by the provider",
+			object: "custom_" + legacyName,
+			prefix: poolPrefix,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, ok := getMigratedName(test.object, test.prefix, legacyName, lbName)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, name)
+		})
+	}
+}
+
+// fakeInTreeLoadBalancer serves a load balancer created by the in-tree provider and records the renamed resources by
+// their path.
+type fakeInTreeLoadBalancer struct {
+	lb      loadbalancers.LoadBalancer
+	renamed map[string]string
+	fipDesc string
+}
+
+func (f *fakeInTreeLoadBalancer) register(t *testing.T) {
+	f.renamed = map[string]string{}
+	rename := func(w http.ResponseWriter, r *http.Request, resource string) {
+		th.TestMethod(t, r, http.MethodPut)
+		var body map[string]map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode update request: %v", err)
+		}
+		f.renamed[r.URL.Path] = body[resource]["name"]
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{resource: map[string]string{"id": "id"}})
+	}
+
+	f.lb.ProvisioningStatus = activeStatus
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		var lbs []loadbalancers.LoadBalancer
+		if r.URL.Query().Get("name") == f.lb.Name {
+			lbs = append(lbs, f.lb)
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"loadbalancers": lbs})
+	})
+	th.Mux.HandleFunc("/lbaas/loadbalancers/"+f.lb.ID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			rename(w, r, "loadbalancer")
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"loadbalancer": f.lb})
+	})
+	th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"pools": []v2pools.Pool{
+			{ID: "pool-id", Name: "pool_0_" + f.lb.Name, MonitorID: "monitor-id"},
+		}})
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool-id", func(w http.ResponseWriter, r *http.Request) {
+		rename(w, r, "pool")
+	})
+	th.Mux.HandleFunc("/lbaas/healthmonitors/monitor-id", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			rename(w, r, "healthmonitor")
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"healthmonitor": monitors.Monitor{ID: "monitor-id", Name: "monitor_0_" + f.lb.Name}})
+	})
+	th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"listeners": []listeners.Listener{
+			{ID: "listener-id", Name: "listener_0_" + f.lb.Name},
+			{ID: "custom-listener-id", Name: "custom"},
+		}})
+	})
+	th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+		rename(w, r, "listener")
+	})
+	th.Mux.HandleFunc("/floatingips", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		var fips []floatingips.FloatingIP
+		if r.URL.Query().Get("port_id") == f.lb.VipPortID {
+			fips = append(fips, floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", PortID: f.lb.VipPortID})
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"floatingips": fips})
+	})
+	th.Mux.HandleFunc("/floatingips/fip-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPut)
+		var body struct {
+			FloatingIP struct {
+				Description string `json:"description"`
+			} `json:"floatingip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode floating IP update request: %v", err)
+		}
+		f.fipDesc = body.FloatingIP.Description
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"floatingip": floatingips.FloatingIP{ID: "fip-id"}})
+	})
+}
+
+func TestLbaasV2_migrateInTreeLoadBalancers(t *testing.T) {
+	newService := func(name, uid string, annotations map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + uid), Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		}
+	}
+	legacy := newService("legacy", "legacy", nil)
+	// Its load balancer was already migrated, it isn't found under its in-tree name anymore
+	migrated := newService("migrated", "migrated", nil)
+	adopted := newService("adopted", "adopted", map[string]string{ServiceAnnotationLoadBalancerID: "other-lb-id"})
+	clusterIP := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "cluster-ip", Namespace: "default", UID: "uid-cluster-ip"}}
+
+	tests := []struct {
+		name              string
+		dryRun            bool
+		expectedRenamed   map[string]string
+		expectedFIPDesc   string
+		expectedLBID      string
+		expectedEventPart string
+	}{
+		{
+			name: "migration",
+			expectedRenamed: map[string]string{
+				"/lbaas/loadbalancers/lb-id":       "kube_service_kubernetes_default_legacy",
+				"/lbaas/pools/pool-id":             "pool_0_kube_service_kubernetes_default_legacy",
+				"/lbaas/healthmonitors/monitor-id": "monitor_0_kube_service_kubernetes_default_legacy",
+				"/lbaas/listeners/listener-id":     "listener_0_kube_service_kubernetes_default_legacy",
+			},
+			expectedFIPDesc:   getFloatingIPDescription("kubernetes", "default/legacy"),
+			expectedLBID:      "lb-id",
+			expectedEventPart: "Migrated load balancer lb-id created by the in-tree provider to kube_service_kubernetes_default_legacy",
+		},
+		{
+			name:              "dry-run",
+			dryRun:            true,
+			expectedRenamed:   map[string]string{},
+			expectedEventPart: "Would migrate load balancer lb-id created by the in-tree provider to kube_service_kubernetes_default_legacy",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			f := &fakeInTreeLoadBalancer{lb: loadbalancers.LoadBalancer{ID: "lb-id", Name: "auidlegacy", VipPortID: "vip-port-id"}}
+			f.register(t)
+
+			kclient := fake.NewSimpleClientset(legacy.DeepCopy(), migrated.DeepCopy(), adopted.DeepCopy(), clusterIP.DeepCopy())
+			recorder := record.NewFakeRecorder(10)
+			lbaas := &LbaasV2{LoadBalancer{
+				lb:            fakeclient.ServiceClient(),
+				network:       fakeclient.ServiceClient(),
+				kclient:       kclient,
+				eventRecorder: recorder,
+				opts:          LoadBalancerOpts{DryRun: test.dryRun},
+			}}
+
+			assert.NoError(t, lbaas.migrateInTreeLoadBalancers(context.TODO(), "kubernetes"))
+
+			assert.Equal(t, test.expectedRenamed, f.renamed)
+			assert.Equal(t, test.expectedFIPDesc, f.fipDesc)
+			assert.Len(t, recorder.Events, 1)
+			assert.Contains(t, <-recorder.Events, test.expectedEventPart)
+
+			saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "legacy", v1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedLBID, saved.Annotations[ServiceAnnotationLoadBalancerID])
+
+			// The Services without a load balancer to migrate are left as is
+			for _, service := range []*corev1.Service{migrated, adopted, clusterIP} {
+				saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), service.Name, v1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, service.Annotations, saved.Annotations, service.Name)
+			}
+		})
+	}
+}
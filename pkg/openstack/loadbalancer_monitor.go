@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The health monitor types which can be forced with ServiceAnnotationLoadBalancerHealthMonitorType.
+const (
+	monitorTypeHTTP = "HTTP"
+	monitorTypeTCP  = "TCP"
+)
+
+// The settings of the HTTP health monitors checking the healthCheckNodePort of kube-proxy.
+const (
+	defaultMonitorHTTPPath      = "/healthz"
+	defaultMonitorHTTPMethod    = http.MethodGet
+	defaultMonitorExpectedCodes = "200"
+)
+
+// expectedCodesRegexp matches the expected codes accepted by Octavia: a code, a list of codes or a range of codes.
+var expectedCodesRegexp = regexp.MustCompile(`^[1-5][0-9]{2}(-[1-5][0-9]{2}|(,[1-5][0-9]{2})*)$`)
+
+// monitorHTTPMethods are the HTTP methods of the health monitors supported by Octavia.
+var monitorHTTPMethods = []string{
+	http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+}
+
+// getHealthMonitorType returns the health monitor type forced by the annotation, empty for the default type.
+func getHealthMonitorType(service *corev1.Service) string {
+	return strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorType, ""))
+}
+
+// setHealthMonitorHTTPOptions sets the type of the health monitors and the settings of the HTTP health monitors from
+// the annotations of the Service.
+func setHealthMonitorHTTPOptions(service *corev1.Service, svcConf *serviceConfig) error {
+	svcConf.healthMonitorType = getHealthMonitorType(service)
+	if svcConf.healthMonitorType != "" && svcConf.healthMonitorType != monitorTypeHTTP && svcConf.healthMonitorType != monitorTypeTCP {
+		return fmt.Errorf("annotation %s must be %s or %s, got %q", ServiceAnnotationLoadBalancerHealthMonitorType,
+			monitorTypeHTTP, monitorTypeTCP, svcConf.healthMonitorType)
+	}
+
+	svcConf.healthMonitorHTTPPath = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorHTTPPath, defaultMonitorHTTPPath)
+	if !strings.HasPrefix(svcConf.healthMonitorHTTPPath, "/") {
+		return fmt.Errorf("annotation %s must be an absolute path, got %q", ServiceAnnotationLoadBalancerHealthMonitorHTTPPath, svcConf.healthMonitorHTTPPath)
+	}
+
+	svcConf.healthMonitorHTTPMethod = strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod, defaultMonitorHTTPMethod))
+	if !slices.Contains(monitorHTTPMethods, svcConf.healthMonitorHTTPMethod) {
+		return fmt.Errorf("annotation %s must be one of %s, got %q", ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod,
+			strings.Join(monitorHTTPMethods, ", "), svcConf.healthMonitorHTTPMethod)
+	}
+
+	svcConf.healthMonitorExpectedCodes = strings.ReplaceAll(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes, defaultMonitorExpectedCodes), " ", "")
+	if !expectedCodesRegexp.MatchString(svcConf.healthMonitorExpectedCodes) {
+		return fmt.Errorf("annotation %s must be a status code, a comma separated list or a range of status codes, got %q",
+			ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes, svcConf.healthMonitorExpectedCodes)
+	}
+	return nil
+}
+
+// useHTTPMonitor checks whether the health monitor of the pool of the port is an HTTP one: by default when it checks
+// the healthCheckNodePort, or when forced by the annotation for the TCP ports.
+func (lbaas *LbaasV2) useHTTPMonitor(port corev1.ServicePort, svcConf *serviceConfig) bool {
+	if svcConf.healthCheckNodePort == 0 && (svcConf.healthMonitorType != monitorTypeHTTP || port.Protocol != corev1.ProtocolTCP) {
+		return false
+	}
+	return lbaas.canUseHTTPMonitor(port)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"testing"
+
+	v2monitors "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/monitors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetHealthMonitorHTTPOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    serviceConfig
+		expectedErr bool
+	}{
+		{
+			name: "defaults",
+			expected: serviceConfig{
+				healthMonitorHTTPPath:      "/healthz",
+				healthMonitorHTTPMethod:    "GET",
+				healthMonitorExpectedCodes: "200",
+			},
+		},
+		{
+			name: "overridden",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHealthMonitorType:          "http",
+				ServiceAnnotationLoadBalancerHealthMonitorHTTPPath:      "/ready",
+				ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod:    "head",
+				ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes: "200, 204",
+			},
+			expected: serviceConfig{
+				healthMonitorType:          "HTTP",
+				healthMonitorHTTPPath:      "/ready",
+				healthMonitorHTTPMethod:    "HEAD",
+				healthMonitorExpectedCodes: "200,204",
+			},
+		},
+		{
+			name:        "invalid type",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorType: "PING"},
+			expectedErr: true,
+		},
+		{
+			name:        "relative path",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorHTTPPath: "healthz"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid method",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod: "FETCH"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid expected codes",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes: "200-"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+			svcConf := &serviceConfig{}
+			err := setHealthMonitorHTTPOptions(service, svcConf)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, *svcConf)
+		})
+	}
+
+	for _, codes := range []string{"200", "200,202", "200-299"} {
+		assert.True(t, expectedCodesRegexp.MatchString(codes), codes)
+	}
+}
+
+func TestBuildMonitorCreateOptsHTTPAnnotations(t *testing.T) {
+	lbaas := &LbaasV2{}
+	tcpPort := corev1.ServicePort{Protocol: corev1.ProtocolTCP}
+	svcConf := &serviceConfig{
+		healthMonitorType:          monitorTypeHTTP,
+		healthMonitorHTTPPath:      "/ready",
+		healthMonitorHTTPMethod:    "HEAD",
+		healthMonitorExpectedCodes: "200-299",
+	}
+
+	// The HTTP monitor checks the member port without healthCheckNodePort.
+	opts := lbaas.buildMonitorCreateOpts(svcConf, tcpPort, "monitor")
+	assert.Equal(t, "HTTP", opts.Type)
+	assert.Equal(t, "/ready", opts.URLPath)
+	assert.Equal(t, "HEAD", opts.HTTPMethod)
+	assert.Equal(t, "200-299", opts.ExpectedCodes)
+
+	// HTTP can't be forced on the UDP ports.
+	opts = lbaas.buildMonitorCreateOpts(svcConf, corev1.ServicePort{Protocol: corev1.ProtocolUDP}, "monitor")
+	assert.Equal(t, "UDP-CONNECT", opts.Type)
+
+	svcConf.healthMonitorType = monitorTypeTCP
+	opts = lbaas.buildMonitorCreateOpts(svcConf, tcpPort, "monitor")
+	assert.Equal(t, "TCP", opts.Type)
+	assert.Empty(t, opts.URLPath)
+}
+
+func TestBuildMonitorUpdateOpts(t *testing.T) {
+	monitor := &v2monitors.Monitor{
+		Name:           "monitor",
+		Type:           "HTTP",
+		Delay:          5,
+		Timeout:        3,
+		MaxRetries:     1,
+		MaxRetriesDown: 3,
+		URLPath:        "/healthz",
+		HTTPMethod:     "GET",
+		ExpectedCodes:  "200",
+	}
+	opts := v2monitors.CreateOpts{
+		Name:           "monitor",
+		Type:           "HTTP",
+		Delay:          5,
+		Timeout:        3,
+		MaxRetries:     1,
+		MaxRetriesDown: 3,
+		URLPath:        "/healthz",
+		HTTPMethod:     "GET",
+		ExpectedCodes:  "200",
+	}
+
+	_, changed := buildMonitorUpdateOpts(monitor, opts)
+	assert.False(t, changed)
+
+	opts.URLPath = "/ready"
+	updateOpts, changed := buildMonitorUpdateOpts(monitor, opts)
+	assert.True(t, changed)
+	assert.Equal(t, "/ready", updateOpts.URLPath)
+	assert.Equal(t, "GET", updateOpts.HTTPMethod)
+	assert.Equal(t, "200", updateOpts.ExpectedCodes)
+
+	// The HTTP settings of the TCP monitors are not managed.
+	monitor.Type, opts.Type, opts.URLPath = "TCP", "TCP", ""
+	_, changed = buildMonitorUpdateOpts(monitor, opts)
+	assert.False(t, changed)
+
+	opts.Delay = 10
+	updateOpts, changed = buildMonitorUpdateOpts(monitor, opts)
+	assert.True(t, changed)
+	assert.Equal(t, 10, updateOpts.Delay)
+	assert.Empty(t, updateOpts.URLPath)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ServiceAnnotationLoadBalancerPaused pauses the reconciliation of the load balancer of the Service: no OpenStack
+// resource is created, updated or deleted for the Service while it is set, its status is still reported.
+const ServiceAnnotationLoadBalancerPaused = "loadbalancer.openstack.org/paused"
+
+// isPaused checks whether the reconciliation of the Service is paused.
+func (lbaas *LbaasV2) isPaused(service *corev1.Service) bool {
+	return getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerPaused, false)
+}
+
+// pausedOctaviaLoadBalancer returns the current status of the load balancer of a paused Service, and reports the
+// number of OpenStack operations the reconciliation skipped. The operations are planned by ensureOctaviaLoadBalancer.
+func (lbaas *LbaasV2) pausedOctaviaLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
+	planner := lbaas.withPlan()
+	status, err := planner.ensureOctaviaLoadBalancer(ctx, clusterName, service.DeepCopy(), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the load balancer status of paused Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+
+	operations := len(planner.plan.operations)
+	klog.InfoS("Reconciliation paused, skipping operations", "service", klog.KObj(service), "operations", operations)
+	if operations > 0 {
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBPaused, "Reconciliation paused, skipped %d operations, remove the %s annotation to apply them",
+			operations, ServiceAnnotationLoadBalancerPaused)
+	}
+	return status, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestLbaasV2_isPaused(t *testing.T) {
+	lbaas := &LbaasV2{}
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{}}}
+	assert.False(t, lbaas.isPaused(service))
+
+	service.Annotations[ServiceAnnotationLoadBalancerPaused] = "true"
+	assert.True(t, lbaas.isPaused(service))
+
+	service.Annotations[ServiceAnnotationLoadBalancerPaused] = "false"
+	assert.False(t, lbaas.isPaused(service))
+}
+
+func TestLbaasV2_pausedServiceNotMutated(t *testing.T) {
+	// No OpenStack client is set, any request would panic.
+	lbaas := &LbaasV2{}
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{
+		Name:        "svc",
+		Namespace:   "default",
+		Annotations: map[string]string{ServiceAnnotationLoadBalancerPaused: "true"},
+	}}
+
+	err := lbaas.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", service)
+	assert.ErrorContains(t, err, "is paused")
+
+	assert.NoError(t, lbaas.updateMemberWeights(context.TODO(), service, sets.New("node-1")))
+	assert.NoError(t, lbaas.updateEndpointMembers(context.TODO(), "kubernetes", service))
+	assert.NoError(t, lbaas.refreshMemberDNSAddresses(context.TODO(), "kubernetes", service))
+}
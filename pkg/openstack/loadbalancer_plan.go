@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// ServiceAnnotationLoadBalancerDryRun makes the reconciliation of the Service only report the OpenStack operations
+// it would apply, as events and logs. It is enforced for all the Services by the dry-run option.
+const ServiceAnnotationLoadBalancerDryRun = "loadbalancer.openstack.org/dry-run"
+
+const (
+	planActionCreate = "create"
+	planActionUpdate = "update"
+	planActionDelete = "delete"
+)
+
+// plannedOperation is an OpenStack operation the reconciliation of a Service would apply.
+type plannedOperation struct {
+	action   string
+	resource string
+	name     string
+	details  string
+}
+
+func (op plannedOperation) String() string {
+	s := fmt.Sprintf("%s %s %s", op.action, op.resource, op.name)
+	if op.details != "" {
+		s += ": " + op.details
+	}
+	return s
+}
+
+// reconcilePlan is the list of the OpenStack operations the reconciliation of a Service would apply, in order.
+type reconcilePlan struct {
+	operations []plannedOperation
+}
+
+func (p *reconcilePlan) add(action, resource, name, details string) {
+	p.operations = append(p.operations, plannedOperation{action: action, resource: resource, name: name, details: details})
+}
+
+// planned adds the operation to the plan, and returns whether the operation must be skipped. It returns false without
+// a plan, the operation is then applied.
+func (p *reconcilePlan) planned(action, resource, name, details string) bool {
+	if p == nil {
+		return false
+	}
+	p.add(action, resource, name, details)
+	return true
+}
+
+// describeOpts renders the request options of a planned operation, the unset fields are omitted.
+func describeOpts(opts interface{}) string {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return fmt.Sprintf("%+v", opts)
+	}
+	return string(data)
+}
+
+// withPlan returns a copy of the load balancer recording the OpenStack operations of the reconciliation in a new plan
+// instead of applying them. The reconciliation then only sends read requests.
+func (lbaas *LbaasV2) withPlan() *LbaasV2 {
+	res := *lbaas
+	res.plan = &reconcilePlan{}
+	return &res
+}
+
+// isDryRun checks whether the reconciliation of the Service must only report the changes.
+func (lbaas *LbaasV2) isDryRun(service *corev1.Service) bool {
+	return lbaas.opts.DryRun || getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerDryRun, false)
+}
+
+// dryRunOctaviaLoadBalancer reports the OpenStack operations the reconciliation of the Service would apply without
+// applying them. The operations are planned by ensureOctaviaLoadBalancer, the Service is left as is. It returns the
+// current status of the load balancer.
+func (lbaas *LbaasV2) dryRunOctaviaLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
+	planner := lbaas.withPlan()
+	status, err := planner.ensureOctaviaLoadBalancer(ctx, clusterName, service.DeepCopy(), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the load balancer reconciliation in dry-run mode: %v", err)
+	}
+
+	lbaas.reportPlan(service, planner.plan)
+	return status, nil
+}
+
+// dryRunLoadBalancerDeletion reports the OpenStack operations the deletion of the load balancer of the Service would
+// apply without applying them. It always fails, the Service keeps its finalizer until the dry-run is disabled and its
+// load balancer is not orphaned.
+func (lbaas *LbaasV2) dryRunLoadBalancerDeletion(ctx context.Context, clusterName string, service *corev1.Service) error {
+	planner := lbaas.withPlan()
+	service = service.DeepCopy()
+	err := planner.ensureAttachedMembersDeleted(ctx, clusterName, service, true)
+	if err == nil && !isAttached(service) {
+		err = planner.ensureLoadBalancerDeleted(ctx, clusterName, service)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to plan the load balancer deletion in dry-run mode: %v", err)
+	}
+
+	lbaas.reportPlan(service, planner.plan)
+	return fmt.Errorf("the load balancer of Service %s/%s is not deleted in dry-run mode, disable it to delete the load balancer",
+		service.Namespace, service.Name)
+}
+
+// reportPlan reports the planned operations as events and logs.
+func (lbaas *LbaasV2) reportPlan(service *corev1.Service, plan *reconcilePlan) {
+	if len(plan.operations) == 0 {
+		klog.InfoS("Dry-run: load balancer up to date", "service", klog.KObj(service))
+		lbaas.eventRecorder.Event(service, corev1.EventTypeNormal, eventLBDryRun, "Dry-run: no change to apply")
+	}
+	for _, op := range plan.operations {
+		klog.InfoS("Dry-run: skipping operation", "service", klog.KObj(service), "action", op.action, "resource", op.resource, "name", op.name, "details", op.details)
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBDryRun, "Dry-run: would %s", op)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPlannedOperationString(t *testing.T) {
+	assert.Equal(t, "delete listener listener-id", plannedOperation{action: planActionDelete, resource: "listener", name: "listener-id"}.String())
+	assert.Equal(t, `update listener listener-id: {"connection_limit":-1}`, plannedOperation{
+		action:   planActionUpdate,
+		resource: "listener",
+		name:     "listener-id",
+		details:  `{"connection_limit":-1}`,
+	}.String())
+}
+
+func TestLbaasV2_isDryRun(t *testing.T) {
+	testCases := []struct {
+		name        string
+		dryRun      bool
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name:     "disabled",
+			expected: false,
+		},
+		{
+			name:        "enabled by the annotation",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerDryRun: "true"},
+			expected:    true,
+		},
+		{
+			name:        "enforced by the configuration",
+			dryRun:      true,
+			annotations: map[string]string{ServiceAnnotationLoadBalancerDryRun: "false"},
+			expected:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lbaas := &LbaasV2{LoadBalancer{opts: LoadBalancerOpts{DryRun: tc.dryRun}}}
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Annotations: tc.annotations}}
+			assert.Equal(t, tc.expected, lbaas.isDryRun(service))
+		})
+	}
+}
+
+func TestLbaasV2_ensureOctaviaListenersPlanned(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// Only read requests are sent
+	th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pools": [{"id": "pool-80", "protocol": "TCP", "lb_algorithm": "ROUND_ROBIN", "listeners": [{"id": "listener-80"}]}]}`)
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool-80/members", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"members": []}`)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:   fakeclient.ServiceClient(),
+		opts: LoadBalancerOpts{LBMethod: "ROUND_ROBIN"},
+	}}
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Protocol: corev1.ProtocolTCP, Port: 80, NodePort: 30080},
+			{Protocol: corev1.ProtocolTCP, Port: 443, NodePort: 30443},
+		}},
+	}
+	nodes := []*corev1.Node{{
+		ObjectMeta: v1.ObjectMeta{Name: "node-1"},
+		Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+	}}
+	svcConf := &serviceConfig{lbName: "lb", connLimit: -1}
+	loadbalancer := &loadbalancers.LoadBalancer{ID: "lb-id", Listeners: []listeners.Listener{
+		{ID: "listener-80", Protocol: "TCP", ProtocolPort: 80, ConnLimit: 100},
+		{ID: "listener-8080", Protocol: "TCP", ProtocolPort: 8080, ConnLimit: -1},
+	}}
+
+	planner := lbaas.withPlan()
+	err := planner.ensureOctaviaListeners(loadbalancer, service, nodes, svcConf, true)
+	assert.NoError(t, err)
+
+	var operations []string
+	for _, op := range planner.plan.operations {
+		operations = append(operations, fmt.Sprintf("%s %s %s", op.action, op.resource, op.name))
+	}
+	assert.Equal(t, []string{
+		"update listener listener-80",
+		"update members pool-80",
+		"create listener listener_1_lb",
+		"create pool pool_1_lb",
+		"update members pool_1_lb",
+		"delete listener listener-8080",
+	}, operations)
+	assert.Equal(t, `{"connection_limit":-1}`, planner.plan.operations[0].details)
+	assert.Contains(t, planner.plan.operations[1].details, "10.0.0.1")
+	// The load balancer itself doesn't plan
+	assert.Nil(t, lbaas.plan)
+}
+
+func TestLbaasV2_dryRunLoadBalancerDeletion(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// Only read requests are sent
+	mockLoadBalancerActive(t, "lb-id")
+	th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"listeners": [{"id": "listener-id", "protocol": "TCP", "protocol_port": 80}]}`)
+	})
+	th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pools": []}`)
+	})
+	th.Mux.HandleFunc("/security-groups", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"security_groups": []}`)
+	})
+
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerID:     "lb-id",
+		ServiceAnnotationLoadBalancerDryRun: "true",
+	}}}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	recorder := record.NewFakeRecorder(10)
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:            fakeclient.ServiceClient(),
+		network:       fakeclient.ServiceClient(),
+		kclient:       kclient,
+		eventRecorder: recorder,
+	}}
+
+	// The Service keeps its finalizer
+	err := lbaas.EnsureLoadBalancerDeleted(context.TODO(), "kubernetes", service)
+	assert.ErrorContains(t, err, "not deleted in dry-run mode")
+	assert.Contains(t, <-recorder.Events, "Dry-run: would delete listener listener-id: port 80, with its pool")
+	assert.Contains(t, <-recorder.Events, "Dry-run: would delete loadbalancer lb-id")
+
+	saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "lb-id", saved.Annotations[ServiceAnnotationLoadBalancerID])
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ServiceAnnotationLoadBalancerProfile is the name of the ConfigMap, in the namespace of the Service, holding
+	// load balancer settings shared by several Services. The annotations of the Service take precedence over them.
+	ServiceAnnotationLoadBalancerProfile = "loadbalancer.openstack.org/profile"
+
+	// loadBalancerProfileKey is the key of the ConfigMap data holding the settings, as a YAML map of annotations.
+	loadBalancerProfileKey = "profile"
+)
+
+// loadBalancerProfileAnnotations are the annotations allowed in the load balancer profiles. The annotations
+// identifying the resources of a single Service, e.g. its load balancer or its port, aren't.
+var loadBalancerProfileAnnotations = sets.New(
+	ServiceAnnotationLoadBalancerInternal,
+	ServiceAnnotationLoadBalancerNodeSelector,
+	ServiceAnnotationLoadBalancerConnLimit,
+	ServiceAnnotationLoadBalancerFloatingNetworkID,
+	ServiceAnnotationLoadBalancerFloatingSubnet,
+	ServiceAnnotationLoadBalancerFloatingSubnetID,
+	ServiceAnnotationLoadBalancerFloatingSubnetTags,
+	ServiceAnnotationLoadBalancerClass,
+	ServiceAnnotationLoadBalancerLbMethod,
+	ServiceAnnotationLoadBalancerProxyEnabled,
+	ServiceAnnotationLoadBalancerSubnetID,
+	ServiceAnnotationLoadBalancerNetworkID,
+	ServiceAnnotationLoadBalancerMemberSubnetID,
+	ServiceAnnotationLoadBalancerTimeoutClientData,
+	ServiceAnnotationLoadBalancerTimeoutMemberConnect,
+	ServiceAnnotationLoadBalancerTimeoutMemberData,
+	ServiceAnnotationLoadBalancerTimeoutTCPInspect,
+	ServiceAnnotationLoadBalancerXForwardedFor,
+	ServiceAnnotationLoadBalancerFlavorID,
+	ServiceAnnotationLoadBalancerAvailabilityZone,
+	ServiceAnnotationLoadBalancerEnableHealthMonitor,
+	ServiceAnnotationLoadBalancerHealthMonitorDelay,
+	ServiceAnnotationLoadBalancerHealthMonitorTimeout,
+	ServiceAnnotationLoadBalancerHealthMonitorMaxRetries,
+	ServiceAnnotationLoadBalancerHealthMonitorMaxRetriesDown,
+	ServiceAnnotationLoadBalancerHealthMonitorType,
+	ServiceAnnotationLoadBalancerHealthMonitorHTTPPath,
+	ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod,
+	ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes,
+	ServiceAnnotationLoadBalancerAdminStateUp,
+	ServiceAnnotationLoadBalancerIncludeControlPlaneNodes,
+	ServiceAnnotationLoadBalancerSNIContainerRefs,
+	ServiceAnnotationLoadBalancerMemberAddressCIDRs,
+	ServiceAnnotationLoadBalancerMemberNetworkID,
+	ServiceAnnotationLoadBalancerMemberDNSDomain,
+	ServiceAnnotationLoadBalancerTLSCiphers,
+	ServiceAnnotationLoadBalancerTLSVersions,
+	ServiceAnnotationLoadBalancerTags,
+	ServiceAnnotationLoadBalancerExternalFloatingIP,
+	ServiceAnnotationLoadBalancerClientAuthentication,
+	ServiceAnnotationLoadBalancerClientCATLSContainerRef,
+	ServiceAnnotationLoadBalancerClientCRLContainerRef,
+	ServiceAnnotationTlsContainerRef,
+)
+
+// withLoadBalancerProfile returns a copy of the Service with the settings of its load balancer profile merged into its
+// annotations, or the Service itself when it has no profile.
+func (lbaas *LbaasV2) withLoadBalancerProfile(ctx context.Context, service *corev1.Service) (*corev1.Service, error) {
+	name := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProfile, "")
+	if name == "" {
+		return service, nil
+	}
+	if lbaas.kclient == nil {
+		return nil, fmt.Errorf("annotation %s requires the Kubernetes client", ServiceAnnotationLoadBalancerProfile)
+	}
+
+	cm, err := lbaas.kclient.CoreV1().ConfigMaps(service.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get load balancer profile %s/%s: %v", service.Namespace, name, err)
+	}
+	settings, err := parseLoadBalancerProfile(cm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid load balancer profile %s/%s: %v", service.Namespace, name, err)
+	}
+
+	merged := service.DeepCopy()
+	for key, value := range settings {
+		if _, ok := merged.Annotations[key]; ok {
+			klog.V(4).Infof("Annotation %s of Service %s/%s overrides its load balancer profile %s", key, service.Namespace, service.Name, name)
+			continue
+		}
+		merged.Annotations[key] = value
+	}
+
+	return merged, nil
+}
+
+// parseLoadBalancerProfile returns the annotations set by the profile ConfigMap.
+func parseLoadBalancerProfile(cm *corev1.ConfigMap) (map[string]string, error) {
+	data, ok := cm.Data[loadBalancerProfileKey]
+	if !ok {
+		return nil, fmt.Errorf("missing %q key", loadBalancerProfileKey)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the %q key: %v", loadBalancerProfileKey, err)
+	}
+
+	settings := make(map[string]string, len(values))
+	var unknown []string
+	for key, value := range values {
+		if !loadBalancerProfileAnnotations.Has(key) {
+			unknown = append(unknown, key)
+			continue
+		}
+		switch value.(type) {
+		case string, bool, int, float64:
+			settings[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("the value of %s must be a string, a number or a boolean", key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("annotations %v aren't allowed in load balancer profiles", unknown)
+	}
+
+	return settings, nil
+}
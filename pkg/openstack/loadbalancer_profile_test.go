@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseLoadBalancerProfile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        map[string]string
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "settings",
+			data: map[string]string{loadBalancerProfileKey: `
+loadbalancer.openstack.org/lb-method: SOURCE_IP
+loadbalancer.openstack.org/enable-health-monitor: true
+loadbalancer.openstack.org/timeout-client-data: 60000
+service.beta.kubernetes.io/openstack-internal-load-balancer: "true"
+`},
+			expected: map[string]string{
+				ServiceAnnotationLoadBalancerLbMethod:            "SOURCE_IP",
+				ServiceAnnotationLoadBalancerEnableHealthMonitor: "true",
+				ServiceAnnotationLoadBalancerTimeoutClientData:   "60000",
+				ServiceAnnotationLoadBalancerInternal:            "true",
+			},
+		},
+		{
+			name:        "missing key",
+			data:        map[string]string{"settings": ""},
+			expectedErr: `missing "profile" key`,
+		},
+		{
+			name:        "invalid YAML",
+			data:        map[string]string{loadBalancerProfileKey: "loadbalancer.openstack.org/lb-method: [SOURCE_IP"},
+			expectedErr: "failed to parse",
+		},
+		{
+			name:        "annotation of a single Service",
+			data:        map[string]string{loadBalancerProfileKey: "loadbalancer.openstack.org/load-balancer-id: lb\nloadbalancer.openstack.org/port-id: port"},
+			expectedErr: "annotations [loadbalancer.openstack.org/load-balancer-id loadbalancer.openstack.org/port-id] aren't allowed",
+		},
+		{
+			name:        "structured value",
+			data:        map[string]string{loadBalancerProfileKey: "loadbalancer.openstack.org/node-selector: {env: prod}"},
+			expectedErr: "must be a string, a number or a boolean",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := parseLoadBalancerProfile(&corev1.ConfigMap{Data: tc.data})
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, settings)
+		})
+	}
+}
+
+func TestWithLoadBalancerProfile(t *testing.T) {
+	profile := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shared"},
+		Data: map[string]string{loadBalancerProfileKey: `
+loadbalancer.openstack.org/lb-method: SOURCE_IP
+loadbalancer.openstack.org/flavor-id: small
+`},
+	}
+	lbaas := &LbaasV2{LoadBalancer{kclient: fake.NewSimpleClientset(profile)}}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "svc",
+		Annotations: map[string]string{
+			ServiceAnnotationLoadBalancerProfile:  "shared",
+			ServiceAnnotationLoadBalancerFlavorID: "large",
+		},
+	}}
+	merged, err := lbaas.withLoadBalancerProfile(context.TODO(), service)
+	assert.NoError(t, err)
+	assert.Equal(t, "SOURCE_IP", merged.Annotations[ServiceAnnotationLoadBalancerLbMethod])
+	// The annotations of the Service take precedence
+	assert.Equal(t, "large", merged.Annotations[ServiceAnnotationLoadBalancerFlavorID])
+	// The Service is left as it is
+	assert.NotContains(t, service.Annotations, ServiceAnnotationLoadBalancerLbMethod)
+
+	withoutProfile := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+	merged, err = lbaas.withLoadBalancerProfile(context.TODO(), withoutProfile)
+	assert.NoError(t, err)
+	assert.Same(t, withoutProfile, merged)
+
+	service.Annotations[ServiceAnnotationLoadBalancerProfile] = "missing"
+	_, err = lbaas.withLoadBalancerProfile(context.TODO(), service)
+	assert.ErrorContains(t, err, "failed to get load balancer profile default/missing")
+}
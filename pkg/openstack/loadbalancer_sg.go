@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"text/template"
 
 	"github.com/gophercloud/gophercloud/v2"
 	neutrontags "github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/attributestags"
@@ -29,16 +30,54 @@ import (
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/subnets"
 	secgroups "github.com/gophercloud/utils/v2/openstack/networking/v2/extensions/security/groups"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	netutils "k8s.io/utils/net"
 	"k8s.io/utils/strings/slices"
 
 	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
 	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
 )
 
+const (
+	defaultSecurityGroupRuleDescription = "Kubernetes cluster {{.ClusterName}}, Service {{.Namespace}}/{{.Name}}, port {{.PortName}} {{.Protocol}}/{{.NodePort}}"
+	healthCheckPortName                 = "health-check"
+)
+
+// securityGroupRuleDescriptionData holds the values available in the
+// security-group-rule-description template.
+type securityGroupRuleDescriptionData struct {
+	ClusterName string
+	Namespace   string
+	Name        string
+	PortName    string
+	Protocol    string
+	Port        int
+	NodePort    int
+}
+
+func parseSecurityGroupRuleDescription(text string) (*template.Template, error) {
+	return template.New("security-group-rule-description").Option("missingkey=error").Parse(text)
+}
+
+// getSecurityGroupRuleDescription renders the description of a security group rule for the given Service port.
+func getSecurityGroupRuleDescription(text string, data securityGroupRuleDescriptionData) (string, error) {
+	tmpl, err := parseSecurityGroupRuleDescription(text)
+	if err != nil {
+		return "", err
+	}
+
+	var description strings.Builder
+	if err := tmpl.Execute(&description, data); err != nil {
+		return "", err
+	}
+
+	return cpoutil.CutString255(description.String()), nil
+}
+
 func getSecurityGroupName(service *corev1.Service) string {
 	securityGroupName := fmt.Sprintf("lb-sg-%s-%s-%s", service.UID, service.Namespace, service.Name)
 	//OpenStack requires that the name of a security group is shorter than 255 bytes.
@@ -49,39 +88,88 @@ func getSecurityGroupName(service *corev1.Service) string {
 	return securityGroupName
 }
 
+// portListDeviceIDBatchSize is the number of servers whose ports are listed by a request, it keeps the URL of the
+// request below the usual limits of the proxies in front of Neutron.
+const portListDeviceIDBatchSize = 50
+
+// listServerPorts returns the ports of the servers by server ID. The ports of the member subnet are listed by a single
+// request, otherwise the ports of the servers are listed in batches.
+func listServerPorts(ctx context.Context, network *gophercloud.ServiceClient, memberSubnetID string, serverIDs []string) (map[string][]PortWithPortSecurity, error) {
+	servers := sets.New(serverIDs...)
+	index := make(map[string][]PortWithPortSecurity, len(servers))
+	addPorts := func(ports []PortWithPortSecurity) {
+		for _, port := range ports {
+			if servers.Has(port.DeviceID) {
+				index[port.DeviceID] = append(index[port.DeviceID], port)
+			}
+		}
+	}
+
+	if memberSubnetID != "" {
+		listOpts := neutronports.ListOpts{FixedIPs: []neutronports.FixedIPOpts{{SubnetID: memberSubnetID}}}
+		ports, err := openstackutil.GetPorts[PortWithPortSecurity](ctx, network, listOpts)
+		if err != nil {
+			return nil, err
+		}
+		addPorts(ports)
+		return index, nil
+	}
+
+	ids := sets.List(servers)
+	for start := 0; start < len(ids); start += portListDeviceIDBatchSize {
+		end := min(start+portListDeviceIDBatchSize, len(ids))
+		listOpts := openstackutil.PortsByDeviceIDsListOpts{DeviceIDs: ids[start:end]}
+		ports, err := openstackutil.GetPorts[PortWithPortSecurity](ctx, network, listOpts)
+		if err != nil {
+			return nil, err
+		}
+		addPorts(ports)
+	}
+	return index, nil
+}
+
 // applyNodeSecurityGroupIDForLB associates the security group with the ports being members of the LB on the nodes.
-func applyNodeSecurityGroupIDForLB(ctx context.Context, network *gophercloud.ServiceClient, svcConf *serviceConfig, nodes []*corev1.Node, sg string) error {
+// It returns the IDs of all the member ports, including the ones which already had the security group. The updates of
+// the ports are only added to the plan when it's set.
+func applyNodeSecurityGroupIDForLB(ctx context.Context, network *gophercloud.ServiceClient, plan *reconcilePlan, svcConf *serviceConfig, nodes []*corev1.Node, sg string) (sets.Set[string], error) {
+	nodeAddrs := make(map[string]string, len(nodes))
+	serverIDs := make([]string, 0, len(nodes))
 	for _, node := range nodes {
 		serverID, _, err := instanceIDFromProviderID(node.Spec.ProviderID)
 		if err != nil {
-			return fmt.Errorf("error getting server ID from the node: %w", err)
+			return nil, fmt.Errorf("error getting server ID from the node: %w", err)
 		}
 
-		addr, _ := nodeAddressForLB(node, svcConf.preferredIPFamily)
+		addr, _ := memberAddressForLB(node, svcConf)
 		if addr == "" {
 			// If node has no viable address let's ignore it.
 			continue
 		}
+		nodeAddrs[serverID] = addr
+		serverIDs = append(serverIDs, serverID)
+	}
 
-		listOpts := neutronports.ListOpts{DeviceID: serverID}
-		allPorts, err := openstackutil.GetPorts[PortWithPortSecurity](ctx, network, listOpts)
-		if err != nil {
-			return err
-		}
+	serverPorts, err := listServerPorts(ctx, network, svcConf.lbMemberSubnetID, serverIDs)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, port := range allPorts {
+	memberPorts := sets.New[string]()
+	for _, serverID := range serverIDs {
+		for _, port := range serverPorts[serverID] {
 			// You can't assign an SG to a port with port_security_enabled=false, skip them.
 			if !port.PortSecurityEnabled {
 				continue
 			}
 
-			// If the Security Group is already present on the port, skip it.
-			if slices.Contains(port.SecurityGroups, sg) {
+			// Only add SGs to the port actually attached to the LB
+			if !isPortMember(port, nodeAddrs[serverID], svcConf.lbMemberSubnetID) {
 				continue
 			}
+			memberPorts.Insert(port.ID)
 
-			// Only add SGs to the port actually attached to the LB
-			if !isPortMember(port, addr, svcConf.lbMemberSubnetID) {
+			// If the Security Group is already present on the port, skip it.
+			if slices.Contains(port.SecurityGroups, sg) {
 				continue
 			}
 
@@ -91,19 +179,23 @@ func applyNodeSecurityGroupIDForLB(ctx context.Context, network *gophercloud.Ser
 			//              by a different one. Same applies to a removal of the SG.
 			newSGs := append(port.SecurityGroups, sg)
 			updateOpts := neutronports.UpdateOpts{SecurityGroups: &newSGs}
+			if plan.planned(planActionUpdate, "port", port.ID, fmt.Sprintf("add security group %s", sg)) {
+				continue
+			}
 			mc := metrics.NewMetricContext("port", "update")
 			res := neutronports.Update(ctx, network, port.ID, updateOpts)
 			if mc.ObserveRequest(res.Err) != nil {
-				return fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
+				return nil, fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
 			}
 		}
 	}
 
-	return nil
+	return memberPorts, nil
 }
 
-// disassociateSecurityGroupForLB removes the given security group from the ports
-func disassociateSecurityGroupForLB(ctx context.Context, network *gophercloud.ServiceClient, sg string) error {
+// disassociateSecurityGroupForLB removes the given security group from the ports, except from the ones in keepPorts.
+// The updates of the ports are only added to the plan when it's set.
+func disassociateSecurityGroupForLB(ctx context.Context, network *gophercloud.ServiceClient, plan *reconcilePlan, sg string, keepPorts sets.Set[string]) error {
 	// Find all the ports that have the security group associated.
 	listOpts := neutronports.ListOpts{SecurityGroups: []string{sg}}
 	allPorts, err := openstackutil.GetPorts[neutronports.Port](ctx, network, listOpts)
@@ -113,6 +205,11 @@ func disassociateSecurityGroupForLB(ctx context.Context, network *gophercloud.Se
 
 	// Disassocate security group and remove the tag.
 	for _, port := range allPorts {
+		if keepPorts.Has(port.ID) || plan.planned(planActionUpdate, "port", port.ID, fmt.Sprintf("remove security group %s", sg)) {
+			continue
+		}
+
+		klog.V(4).Infof("Removing security group %s from port %s", sg, port.ID)
 		existingSGs := sets.NewString()
 		for _, sgID := range port.SecurityGroups {
 			existingSGs.Insert(sgID)
@@ -144,8 +241,32 @@ func disassociateSecurityGroupForLB(ctx context.Context, network *gophercloud.Se
 	return nil
 }
 
+// getSecurityGroupRemoteCIDRs returns the CIDRs allowed to reach the node ports of the LB members.
+func getSecurityGroupRemoteCIDRs(lbProvider, subnetCIDR string, allowedCIDR []string) []string {
+	if lbProvider != "ovn" {
+		// Amphorae proxy the traffic from the member subnet.
+		return []string{subnetCIDR}
+	}
+
+	// OVN keeps the source IP of the incoming traffic. This means that we cannot just open the LB range, but we
+	// need to open for the whole world. This can be restricted by using the service.spec.loadBalancerSourceRanges.
+	// allowedCIDR will give us the ranges calculated by GetLoadBalancerSourceRanges() earlier. The ranges of the other
+	// IP family can't reach the members and Neutron would reject rules mixing the IP families, so they're skipped.
+	isIPv6 := netutils.IsIPv6CIDRString(subnetCIDR)
+	cidrs := make([]string, 0, len(allowedCIDR))
+	for _, cidr := range allowedCIDR {
+		if netutils.IsIPv6CIDRString(cidr) == isIPv6 {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
 // group, if it not present.
 func (lbaas *LbaasV2) ensureSecurityRule(ctx context.Context, sgRuleCreateOpts rules.CreateOpts) error {
+	if lbaas.plan.planned(planActionCreate, "securitygrouprule", sgRuleCreateOpts.SecGroupID, describeOpts(sgRuleCreateOpts)) {
+		return nil
+	}
 	mc := metrics.NewMetricContext("security_group_rule", "create")
 	_, err := rules.Create(ctx, lbaas.network, sgRuleCreateOpts).Extract()
 	if err != nil && cpoerrors.IsConflictError(err) {
@@ -159,6 +280,8 @@ func (lbaas *LbaasV2) ensureSecurityRule(ctx context.Context, sgRuleCreateOpts r
 	return nil
 }
 
+// compareSecurityGroupRuleAndCreateOpts checks if the rule matches the create opts. The description is ignored,
+// as Neutron doesn't allow to create two rules differing only in their descriptions.
 func compareSecurityGroupRuleAndCreateOpts(rule rules.SecGroupRule, opts rules.CreateOpts) bool {
 	return rule.Direction == string(opts.Direction) &&
 		strings.EqualFold(rule.Protocol, string(opts.Protocol)) &&
@@ -205,10 +328,46 @@ func getRulesToCreateAndDelete(wantedRules []rules.CreateOpts, existingRules []r
 
 // ensureAndUpdateOctaviaSecurityGroup handles the creation and update of the security group and the securiry rules for the octavia load balancer
 func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, clusterName string, apiService *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig) error {
+	lbSecGroupID, plannedSecGroup, err := lbaas.ensureOctaviaSecurityGroupRules(ctx, clusterName, apiService, svcConf)
+	if err != nil {
+		return err
+	}
+
+	memberPorts, err := applyNodeSecurityGroupIDForLB(ctx, lbaas.network, lbaas.plan, svcConf, nodes, lbSecGroupID)
+	if err != nil || plannedSecGroup {
+		return err
+	}
+
+	// Remove the security group from the ports which aren't LB members anymore, e.g. of the removed nodes.
+	if err := disassociateSecurityGroupForLB(ctx, lbaas.network, lbaas.plan, lbSecGroupID, memberPorts); err != nil {
+		return fmt.Errorf("failed to disassociate security group %s from the former members: %v", lbSecGroupID, err)
+	}
+	return nil
+}
+
+// getEndpointMemberPorts returns the ports of the endpoint members of the Service port, the target ports resolved by
+// its EndpointSlices.
+func getEndpointMemberPorts(port corev1.ServicePort, svcConf *serviceConfig) []int {
+	memberPorts := sets.New[int]()
+	for _, slice := range svcConf.endpointSlices {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN ||
+			(svcConf.preferredIPFamily != "" && string(slice.AddressType) != string(svcConf.preferredIPFamily)) {
+			continue
+		}
+		if slicePort := getEndpointSlicePort(slice, port); slicePort != nil {
+			memberPorts.Insert(int(*slicePort.Port))
+		}
+	}
+	return sets.List(memberPorts)
+}
+
+// ensureOctaviaSecurityGroupRules creates the security group of the Service if needed and reconciles its rules. It
+// returns the ID of the security group and whether its creation is only planned, the ID is then its name.
+func (lbaas *LbaasV2) ensureOctaviaSecurityGroupRules(ctx context.Context, clusterName string, apiService *corev1.Service, svcConf *serviceConfig) (string, bool, error) {
 	// get service ports
 	ports := apiService.Spec.Ports
 	if len(ports) == 0 {
-		return fmt.Errorf("no ports provided to openstack load balancer")
+		return "", false, fmt.Errorf("no ports provided to openstack load balancer")
 	}
 
 	// ensure security group for LB
@@ -219,9 +378,10 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 		if cpoerrors.IsNotFound(err) {
 			lbSecGroupID = ""
 		} else {
-			return fmt.Errorf("error occurred finding security group: %s: %v", lbSecGroupName, err)
+			return "", false, fmt.Errorf("error occurred finding security group: %s: %v", lbSecGroupName, err)
 		}
 	}
+	plannedSecGroup := false
 	if len(lbSecGroupID) == 0 {
 		// create security group
 		lbSecGroupCreateOpts := groups.CreateOpts{
@@ -229,18 +389,24 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 			Description: fmt.Sprintf("Security Group for %s/%s Service LoadBalancer in cluster %s", apiService.Namespace, apiService.Name, clusterName),
 		}
 
-		mc := metrics.NewMetricContext("security_group", "create")
-		lbSecGroup, err := groups.Create(ctx, lbaas.network, lbSecGroupCreateOpts).Extract()
-		if mc.ObserveRequest(err) != nil {
-			return fmt.Errorf("failed to create Security Group for loadbalancer service %s/%s: %v", apiService.Namespace, apiService.Name, err)
+		if lbaas.plan.planned(planActionCreate, "securitygroup", lbSecGroupName, describeOpts(lbSecGroupCreateOpts)) {
+			// The rules and the ports of the planned security group are planned with its name
+			lbSecGroupID = lbSecGroupName
+			plannedSecGroup = true
+		} else {
+			mc := metrics.NewMetricContext("security_group", "create")
+			lbSecGroup, err := groups.Create(ctx, lbaas.network, lbSecGroupCreateOpts).Extract()
+			if mc.ObserveRequest(err) != nil {
+				return "", false, fmt.Errorf("failed to create Security Group for loadbalancer service %s/%s: %v", apiService.Namespace, apiService.Name, err)
+			}
+			lbSecGroupID = lbSecGroup.ID
 		}
-		lbSecGroupID = lbSecGroup.ID
 	}
 
 	mc := metrics.NewMetricContext("subnet", "get")
 	subnet, err := subnets.Get(ctx, lbaas.network, svcConf.lbMemberSubnetID).Extract()
 	if mc.ObserveRequest(err) != nil {
-		return fmt.Errorf(
+		return "", false, fmt.Errorf(
 			"failed to find subnet %s from openstack: %v", svcConf.lbMemberSubnetID, err)
 	}
 
@@ -248,30 +414,42 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 	if netutils.IsIPv6CIDRString(subnet.CIDR) {
 		etherType = rules.EtherType6
 	}
-	cidrs := []string{subnet.CIDR}
-	if lbaas.opts.LBProvider == "ovn" {
-		// OVN keeps the source IP of the incoming traffic. This means that we cannot just open the LB range, but we
-		// need to open for the whole world. This can be restricted by using the service.spec.loadBalancerSourceRanges.
-		// svcConf.allowedCIDR will give us the ranges calculated by GetLoadBalancerSourceRanges() earlier.
-		cidrs = svcConf.allowedCIDR
-	}
+	cidrs := getSecurityGroupRemoteCIDRs(lbaas.opts.LBProvider, subnet.CIDR, svcConf.allowedCIDR)
 
-	existingRules, err := openstackutil.GetSecurityGroupRules(lbaas.network, rules.ListOpts{SecGroupID: lbSecGroupID})
-	if err != nil {
-		return fmt.Errorf(
-			"failed to find security group rules in %s: %v", lbSecGroupID, err)
+	var existingRules []rules.SecGroupRule
+	if !plannedSecGroup {
+		existingRules, err = openstackutil.GetSecurityGroupRules(lbaas.network, rules.ListOpts{SecGroupID: lbSecGroupID})
+		if err != nil {
+			return "", false, fmt.Errorf(
+				"failed to find security group rules in %s: %v", lbSecGroupID, err)
+		}
 	}
 
 	// List of the security group rules wanted in the SG.
 	// Number of Ports plus the potential HealthCheckNodePort.
 	wantedRules := make([]rules.CreateOpts, 0, len(ports)+1)
 
+	descriptionData := securityGroupRuleDescriptionData{
+		ClusterName: clusterName,
+		Namespace:   apiService.Namespace,
+		Name:        apiService.Name,
+	}
+
 	if apiService.Spec.HealthCheckNodePort != 0 {
-		// TODO(dulek): How should this work with OVN…? Do we need to allow all?
-		//              Probably the traffic goes from the compute node?
+		descriptionData.PortName = healthCheckPortName
+		descriptionData.Protocol = string(corev1.ProtocolTCP)
+		descriptionData.Port = int(apiService.Spec.HealthCheckNodePort)
+		descriptionData.NodePort = int(apiService.Spec.HealthCheckNodePort)
+		description, err := getSecurityGroupRuleDescription(lbaas.opts.SecurityGroupRuleDescription, descriptionData)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to render security group rule description: %v", err)
+		}
+
+		// Both Amphora and OVN health checks come from the member subnet, OVN uses a dedicated port in that subnet.
 		wantedRules = append(wantedRules,
 			rules.CreateOpts{
 				Direction:      rules.DirIngress,
+				Description:    description,
 				Protocol:       rules.ProtocolTCP,
 				EtherType:      etherType,
 				RemoteIPPrefix: subnet.CIDR,
@@ -286,11 +464,22 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 		if port.NodePort == 0 { // It's 0 when AllocateLoadBalancerNodePorts=False
 			continue
 		}
+
+		descriptionData.PortName = port.Name
+		descriptionData.Protocol = string(port.Protocol)
+		descriptionData.Port = int(port.Port)
+		descriptionData.NodePort = int(port.NodePort)
+		description, err := getSecurityGroupRuleDescription(lbaas.opts.SecurityGroupRuleDescription, descriptionData)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to render security group rule description: %v", err)
+		}
+
 		for _, cidr := range cidrs {
 			protocol := strings.ToLower(string(port.Protocol)) // K8s uses TCP, Neutron uses tcp, etc.
 			wantedRules = append(wantedRules,
 				rules.CreateOpts{
 					Direction:      rules.DirIngress,
+					Description:    description,
 					Protocol:       rules.RuleProtocol(protocol),
 					EtherType:      etherType,
 					RemoteIPPrefix: cidr,
@@ -302,18 +491,52 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 		}
 	}
 
+	// The endpoint members receive the traffic at the target ports of their EndpointSlices
+	if svcConf.endpointMembers {
+		for _, port := range ports {
+			for _, memberPort := range getEndpointMemberPorts(port, svcConf) {
+				descriptionData.PortName = port.Name
+				descriptionData.Protocol = string(port.Protocol)
+				descriptionData.Port = int(port.Port)
+				descriptionData.NodePort = memberPort
+				description, err := getSecurityGroupRuleDescription(lbaas.opts.SecurityGroupRuleDescription, descriptionData)
+				if err != nil {
+					return "", false, fmt.Errorf("failed to render security group rule description: %v", err)
+				}
+
+				for _, cidr := range cidrs {
+					wantedRules = append(wantedRules,
+						rules.CreateOpts{
+							Direction:      rules.DirIngress,
+							Description:    description,
+							Protocol:       rules.RuleProtocol(strings.ToLower(string(port.Protocol))),
+							EtherType:      etherType,
+							RemoteIPPrefix: cidr,
+							SecGroupID:     lbSecGroupID,
+							PortRangeMin:   memberPort,
+							PortRangeMax:   memberPort,
+						},
+					)
+				}
+			}
+		}
+	}
+
 	toCreate, toDelete := getRulesToCreateAndDelete(wantedRules, existingRules)
 
 	// create new rules
 	for _, opts := range toCreate {
 		err := lbaas.ensureSecurityRule(ctx, opts)
 		if err != nil {
-			return fmt.Errorf("failed to apply security rule (%v), %w", opts, err)
+			return "", false, fmt.Errorf("failed to apply security rule (%v), %w", opts, err)
 		}
 	}
 
 	// delete unneeded rules
 	for _, existingRule := range toDelete {
+		if lbaas.plan.planned(planActionDelete, "securitygrouprule", existingRule.ID, "") {
+			continue
+		}
 		klog.Infof("Deleting rule %s from security group %s (%s)", existingRule.ID, existingRule.SecGroupID, lbSecGroupName)
 		mc := metrics.NewMetricContext("security_group_rule", "delete")
 		err := rules.Delete(ctx, lbaas.network, existingRule.ID).ExtractErr()
@@ -321,16 +544,13 @@ func (lbaas *LbaasV2) ensureAndUpdateOctaviaSecurityGroup(ctx context.Context, c
 			// ignore 404
 			klog.Warningf("Security group rule %s found missing when trying to delete it. This indicates concurrent "+
 				"updates to the SG %s and is unexpected", existingRule.ID, existingRule.SecGroupID)
-			return mc.ObserveRequest(nil)
+			_ = mc.ObserveRequest(nil)
 		} else if mc.ObserveRequest(err) != nil {
-			return fmt.Errorf("failed to delete security group rule %s: %w", existingRule.ID, err)
+			return "", false, fmt.Errorf("failed to delete security group rule %s: %w", existingRule.ID, err)
 		}
 	}
 
-	if err := applyNodeSecurityGroupIDForLB(ctx, lbaas.network, svcConf, nodes, lbSecGroupID); err != nil {
-		return err
-	}
-	return nil
+	return lbSecGroupID, plannedSecGroup, nil
 }
 
 // ensureSecurityGroupDeleted deleting security group for specific loadbalancer service.
@@ -347,10 +567,14 @@ func (lbaas *LbaasV2) ensureSecurityGroupDeleted(ctx context.Context, service *c
 	}
 
 	// Disassociate the security group from the neutron ports on the nodes.
-	if err := disassociateSecurityGroupForLB(ctx, lbaas.network, lbSecGroupID); err != nil {
+	if err := disassociateSecurityGroupForLB(ctx, lbaas.network, lbaas.plan, lbSecGroupID, nil); err != nil {
 		return fmt.Errorf("failed to disassociate security group %s: %v", lbSecGroupID, err)
 	}
 
+	if lbaas.plan.planned(planActionDelete, "securitygroup", lbSecGroupID, "") {
+		return nil
+	}
+
 	mc := metrics.NewMetricContext("security_group", "delete")
 	lbSecGroup := groups.Delete(ctx, lbaas.network, lbSecGroupID)
 	if lbSecGroup.Err != nil && !cpoerrors.IsNotFound(lbSecGroup.Err) {
@@ -0,0 +1,229 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/security/rules"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+)
+
+// fakeServerPorts serves the port API of Neutron for a port per server on the member subnet, and a port per server on
+// another subnet. It returns the number of port list requests and the updated ports.
+func fakeServerPorts(t *testing.T, servers int) (*int, sets.Set[string]) {
+	lists := 0
+	updated := sets.New[string]()
+
+	var ports []map[string]any
+	for i := 0; i < servers; i++ {
+		for octet, subnet := range []string{"member", "other"} {
+			ports = append(ports, map[string]any{
+				"id":                    fmt.Sprintf("port-%s-%d", subnet, i),
+				"device_id":             fmt.Sprintf("server-%d", i),
+				"port_security_enabled": true,
+				"security_groups":       []string{"default"},
+				"fixed_ips":             []map[string]string{{"subnet_id": subnet, "ip_address": fmt.Sprintf("10.0.%d.%d", octet, i)}},
+			})
+		}
+	}
+
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		lists++
+		query := r.URL.Query()
+		deviceIDs := sets.New(query["device_id"]...)
+		assert.LessOrEqual(t, deviceIDs.Len(), portListDeviceIDBatchSize)
+		var res []map[string]any
+		for _, port := range ports {
+			fixedIPs := port["fixed_ips"].([]map[string]string)
+			if query.Get("fixed_ips") != "" && query.Get("fixed_ips") != "subnet_id="+fixedIPs[0]["subnet_id"] {
+				continue
+			}
+			if deviceIDs.Len() > 0 && !deviceIDs.Has(port["device_id"].(string)) {
+				continue
+			}
+			res = append(res, port)
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"ports": res})
+	})
+	for _, port := range ports {
+		id := port["id"].(string)
+		th.Mux.HandleFunc("/ports/"+id, func(w http.ResponseWriter, r *http.Request) {
+			th.TestMethod(t, r, http.MethodPut)
+			updated.Insert(id)
+			w.Header().Add("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"port": map[string]any{"id": id}})
+		})
+	}
+
+	return &lists, updated
+}
+
+func TestApplyNodeSecurityGroupIDForLB(t *testing.T) {
+	const servers = 120
+
+	var nodes []*corev1.Node
+	expected := sets.New[string]()
+	for i := 0; i < servers; i++ {
+		nodes = append(nodes, &corev1.Node{
+			ObjectMeta: v1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+			Spec:       corev1.NodeSpec{ProviderID: fmt.Sprintf("openstack:///server-%d", i)},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: fmt.Sprintf("10.0.0.%d", i)}},
+			},
+		})
+		expected.Insert(fmt.Sprintf("port-member-%d", i))
+	}
+
+	testCases := []struct {
+		name           string
+		memberSubnetID string
+		expectedLists  int
+	}{
+		{name: "member subnet", memberSubnetID: "member", expectedLists: 1},
+		{name: "no member subnet", expectedLists: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+			lists, updated := fakeServerPorts(t, servers)
+
+			svcConf := &serviceConfig{lbMemberSubnetID: tc.memberSubnetID, preferredIPFamily: corev1.IPv4Protocol}
+			memberPorts, err := applyNodeSecurityGroupIDForLB(context.TODO(), fakeclient.ServiceClient(), nil, svcConf, nodes, "sg")
+			assert.NoError(t, err)
+			assert.Equal(t, expected, memberPorts)
+			assert.Equal(t, expected, updated)
+			assert.Equal(t, tc.expectedLists, *lists)
+		})
+	}
+}
+
+func TestGetEndpointMemberPorts(t *testing.T) {
+	port := corev1.ServicePort{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}
+	svcConf := &serviceConfig{
+		preferredIPFamily: corev1.IPv4Protocol,
+		endpointSlices: []*discoveryv1.EndpointSlice{
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports: []discoveryv1.EndpointPort{
+					{Name: ptr.To("metrics"), Port: ptr.To[int32](9090)},
+					{Name: ptr.To("http"), Port: ptr.To[int32](8443)},
+				},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv6,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8000)}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeFQDN,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8001)}},
+			},
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Protocol: ptr.To(corev1.ProtocolUDP), Port: ptr.To[int32](8002)}},
+			},
+		},
+	}
+
+	assert.Equal(t, []int{8080, 8443}, getEndpointMemberPorts(port, svcConf))
+	assert.Empty(t, getEndpointMemberPorts(corev1.ServicePort{Name: "other", Protocol: corev1.ProtocolTCP}, svcConf))
+}
+
+func TestEnsureOctaviaSecurityGroupRulesEndpointMembers(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "ns", UID: "uid"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, NodePort: 30080}},
+		},
+	}
+	th.Mux.HandleFunc("/security-groups", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"security_groups": []map[string]any{
+			{"id": "sg", "name": getSecurityGroupName(service)},
+		}})
+	})
+	th.Mux.HandleFunc("/subnets/member", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"subnet": map[string]any{"id": "member", "cidr": "10.0.0.0/24"}})
+	})
+	// The rule of the node port already exists
+	created := sets.New[int]()
+	th.Mux.HandleFunc("/security-group-rules", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(map[string]any{"security_group_rules": []map[string]any{
+				{"id": "rule", "security_group_id": "sg", "direction": "ingress", "protocol": "tcp", "ethertype": "IPv4",
+					"remote_ip_prefix": "10.0.0.0/24", "port_range_min": 30080, "port_range_max": 30080},
+			}})
+			return
+		}
+		th.TestMethod(t, r, http.MethodPost)
+		var body struct {
+			Rule rules.CreateOpts `json:"security_group_rule"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, body.Rule.PortRangeMin, body.Rule.PortRangeMax)
+		assert.Equal(t, "10.0.0.0/24", body.Rule.RemoteIPPrefix)
+		created.Insert(body.Rule.PortRangeMin)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"security_group_rule": map[string]any{"id": "new"}})
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{network: fakeclient.ServiceClient(), opts: LoadBalancerOpts{LBProvider: "amphora"}}}
+	svcConf := &serviceConfig{
+		endpointMembers:   true,
+		lbMemberSubnetID:  "member",
+		preferredIPFamily: corev1.IPv4Protocol,
+		endpointSlices: []*discoveryv1.EndpointSlice{
+			{
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To[int32](8080)}},
+			},
+		},
+	}
+	secGroupID, planned, err := lbaas.ensureOctaviaSecurityGroupRules(context.TODO(), "kubernetes", service, svcConf)
+	assert.NoError(t, err)
+	assert.Equal(t, "sg", secGroupID)
+	assert.False(t, planned)
+	assert.Equal(t, sets.New(8080), created)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/subnetpools"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/subnets"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/metrics"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+)
+
+// validateLoadBalancerSubnets checks the VIP and member subnets before the load balancer is created, so that the
+// errors Octavia reports for them, or the load balancers it creates without connectivity to their members, are
+// replaced by actionable errors. The subnets must be visible to the project of the cluster, i.e. owned by it or shared
+// with it by an RBAC policy. The subnets of the same IP version in different Neutron address scopes are reported with a
+// warning event only, the routers don't route between address scopes but static routes or a shared router may.
+func (lbaas *LbaasV2) validateLoadBalancerSubnets(ctx context.Context, service *corev1.Service, vipSubnetID, memberSubnetID string) error {
+	if vipSubnetID == "" {
+		return nil
+	}
+	vipSubnet, err := lbaas.getVisibleSubnet(ctx, "VIP", vipSubnetID)
+	if err != nil {
+		return err
+	}
+
+	if memberSubnetID == "" || memberSubnetID == vipSubnetID {
+		return nil
+	}
+	memberSubnet, err := lbaas.getVisibleSubnet(ctx, "member", memberSubnetID)
+	if err != nil {
+		return err
+	}
+	if vipSubnet.IPVersion != memberSubnet.IPVersion {
+		return nil
+	}
+
+	vipScope, vipKnown, err := lbaas.getSubnetAddressScope(ctx, vipSubnet)
+	if err != nil {
+		return err
+	}
+	memberScope, memberKnown, err := lbaas.getSubnetAddressScope(ctx, memberSubnet)
+	if err != nil {
+		return err
+	}
+	if vipKnown && memberKnown && vipScope != memberScope {
+		msg := "The VIP subnet %s is in address scope %q and the member subnet %s in address scope %q, " +
+			"the traffic between different address scopes is not routed unless a route is configured: use subnets allocated from subnet pools of the same address scope"
+		klog.Warningf(msg, vipSubnetID, vipScope, memberSubnetID, memberScope)
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBAddressScopeMismatch, msg, vipSubnetID, vipScope, memberSubnetID, memberScope)
+	}
+	return nil
+}
+
+// getVisibleSubnet gets the subnet, reporting the subnets not visible to the project as not shared with it.
+func (lbaas *LbaasV2) getVisibleSubnet(ctx context.Context, kind, subnetID string) (*subnets.Subnet, error) {
+	mc := metrics.NewMetricContext("subnet", "get")
+	subnet, err := subnets.Get(ctx, lbaas.network, subnetID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		if cpoerrors.IsNotFound(err) {
+			return nil, fmt.Errorf("the %s subnet %s is not found: it does not exist or its network is not shared with the project of the cluster, "+
+				"share it with an RBAC policy of the access_as_shared action", kind, subnetID)
+		}
+		return nil, fmt.Errorf("failed to get the %s subnet %s: %v", kind, subnetID, err)
+	}
+	return subnet, nil
+}
+
+// getSubnetAddressScope returns the address scope of the subnet pool of the subnet, empty for the subnets outside of
+// address scopes. The address scope is unknown when the subnet pool isn't visible to the project.
+func (lbaas *LbaasV2) getSubnetAddressScope(ctx context.Context, subnet *subnets.Subnet) (string, bool, error) {
+	if subnet.SubnetPoolID == "" {
+		return "", true, nil
+	}
+
+	mc := metrics.NewMetricContext("subnetpool", "get")
+	pool, err := subnetpools.Get(ctx, lbaas.network, subnet.SubnetPoolID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		if cpoerrors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get the subnet pool %s of subnet %s: %v", subnet.SubnetPoolID, subnet.ID, err)
+	}
+	return pool.AddressScopeID, true, nil
+}
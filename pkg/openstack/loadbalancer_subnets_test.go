@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeSubnets serves the subnet and subnet pool API of Neutron for the given subnets and their subnet pools.
+func fakeSubnets(subnets map[string]map[string]any, pools map[string]string) {
+	for id, subnet := range subnets {
+		th.Mux.HandleFunc("/subnets/"+id, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"subnet": subnet})
+		})
+	}
+	for id, scope := range pools {
+		th.Mux.HandleFunc("/subnetpools/"+id, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"subnetpool": map[string]any{"id": id, "address_scope_id": scope, "default_prefixlen": 24, "min_prefixlen": 8, "max_prefixlen": 32}})
+		})
+	}
+}
+
+func TestValidateLoadBalancerSubnets(t *testing.T) {
+	subnets := map[string]map[string]any{
+		"vip":           {"id": "vip", "ip_version": 4, "subnetpool_id": "pool-a"},
+		"member":        {"id": "member", "ip_version": 4, "subnetpool_id": "pool-a2"},
+		"other-scope":   {"id": "other-scope", "ip_version": 4, "subnetpool_id": "pool-b"},
+		"unscoped":      {"id": "unscoped", "ip_version": 4},
+		"hidden-pool":   {"id": "hidden-pool", "ip_version": 4, "subnetpool_id": "pool-hidden"},
+		"other-version": {"id": "other-version", "ip_version": 6, "subnetpool_id": "pool-b"},
+	}
+	pools := map[string]string{"pool-a": "scope-a", "pool-a2": "scope-a", "pool-b": "scope-b"}
+
+	testCases := []struct {
+		name           string
+		vipSubnetID    string
+		memberSubnetID string
+		expectedErr    string
+		expectedEvent  string
+	}{
+		{name: "no VIP subnet", memberSubnetID: "missing"},
+		{name: "same subnet", vipSubnetID: "vip", memberSubnetID: "vip"},
+		{name: "same address scope", vipSubnetID: "vip", memberSubnetID: "member"},
+		{name: "VIP subnet not shared", vipSubnetID: "missing", memberSubnetID: "member", expectedErr: "the VIP subnet missing is not found"},
+		{name: "member subnet not shared", vipSubnetID: "vip", memberSubnetID: "missing", expectedErr: "the member subnet missing is not found"},
+		{name: "different address scopes", vipSubnetID: "vip", memberSubnetID: "other-scope", expectedEvent: `address scope "scope-a"`},
+		{name: "scoped and unscoped", vipSubnetID: "vip", memberSubnetID: "unscoped", expectedEvent: `address scope ""`},
+		{name: "subnet pool not visible", vipSubnetID: "vip", memberSubnetID: "hidden-pool"},
+		{name: "different IP versions", vipSubnetID: "vip", memberSubnetID: "other-version"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+			fakeSubnets(subnets, pools)
+
+			recorder := record.NewFakeRecorder(10)
+			lbaas := &LbaasV2{LoadBalancer{network: fakeclient.ServiceClient(), eventRecorder: recorder}}
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+			err := lbaas.validateLoadBalancerSubnets(context.TODO(), service, tc.vipSubnetID, tc.memberSubnetID)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+			if tc.expectedEvent == "" {
+				assert.Empty(t, recorder.Events)
+			} else {
+				event := <-recorder.Events
+				assert.Contains(t, event, eventLBAddressScopeMismatch)
+				assert.Contains(t, event, tc.expectedEvent)
+			}
+		})
+	}
+}
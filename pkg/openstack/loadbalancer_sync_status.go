@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	cpoutil "k8s.io/cloud-provider-openstack/pkg/util"
+)
+
+const (
+	// ServiceConditionLoadBalancerSynced is the condition of the status of the Service recording the result of the
+	// last reconciliation of its load balancer, set by occm when sync-status-condition is true. It's set in the status
+	// so that updating it doesn't trigger another reconciliation.
+	ServiceConditionLoadBalancerSynced = "LoadBalancerSynced"
+	// ServiceAnnotationLoadBalancerLastSuccessfulSync is the time of the last successful reconciliation of the load
+	// balancer, set by occm when sync-status-condition is true.
+	ServiceAnnotationLoadBalancerLastSuccessfulSync = "loadbalancer.openstack.org/last-successful-sync-time"
+
+	// lastSuccessfulSyncRefreshInterval throttles the updates of the last-successful-sync-time annotation. Updating
+	// an annotation triggers another reconciliation, which must not update it again.
+	lastSuccessfulSyncRefreshInterval = 5 * time.Minute
+
+	syncResultSucceeded = "Succeeded"
+	syncResultFailed    = "Failed"
+
+	// maxSyncErrorLength bounds the error recorded in the condition, the full error is in the events and the logs.
+	maxSyncErrorLength = 512
+)
+
+// updateSyncCondition records the result of the reconciliation in the LoadBalancerSynced condition of the Service,
+// and returns whether it changed. The time of the condition is the time the result last changed, the reconciliations
+// with the same result don't update it.
+func updateSyncCondition(service *corev1.Service, reconcileErr error) bool {
+	condition := metav1.Condition{
+		Type:               ServiceConditionLoadBalancerSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             syncResultSucceeded,
+		ObservedGeneration: service.Generation,
+	}
+	if reconcileErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = syncResultFailed
+		condition.Message = reconcileErr.Error()
+		if len(condition.Message) > maxSyncErrorLength {
+			condition.Message = condition.Message[:maxSyncErrorLength] + "..."
+		}
+	}
+	return apimeta.SetStatusCondition(&service.Status.Conditions, condition)
+}
+
+// recordSyncStatus saves the result of the reconciliation of the load balancer in the LoadBalancerSynced condition
+// of the Service when sync-status-condition is true, and the time of a success in the last-successful-sync-time
+// annotation.
+func (lbaas *LbaasV2) recordSyncStatus(ctx context.Context, service *corev1.Service, reconcileErr error) {
+	if !lbaas.opts.SyncStatusCondition || lbaas.kclient == nil {
+		return
+	}
+
+	lbaas.recordSyncCondition(ctx, service, reconcileErr)
+	if reconcileErr == nil {
+		lbaas.recordLastSuccessfulSync(ctx, service, time.Now())
+	}
+}
+
+// recordSyncCondition saves the LoadBalancerSynced condition of the Service. The condition is patched through the
+// status subresource, only when it changed. A failure is only logged, the condition is saved again by the next
+// reconciliation.
+func (lbaas *LbaasV2) recordSyncCondition(ctx context.Context, service *corev1.Service, reconcileErr error) {
+	updated := service.DeepCopy()
+	if !updateSyncCondition(updated, reconcileErr) {
+		return
+	}
+	condition := apimeta.FindStatusCondition(updated.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	// The conditions of the Service status are merged by type, the other conditions are kept
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []metav1.Condition{*condition},
+		},
+	})
+	if err != nil {
+		klog.Warningf("Failed to serialize the sync status of Service %s/%s: %v", service.Namespace, service.Name, err)
+		return
+	}
+	_, err = lbaas.kclient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		klog.Warningf("Failed to save the sync status of Service %s/%s: %v", service.Namespace, service.Name, err)
+		return
+	}
+	service.Status.Conditions = updated.Status.Conditions
+}
+
+// recordLastSuccessfulSync sets the last-successful-sync-time annotation of the Service to the time of a successful
+// reconciliation, unless it was set less than lastSuccessfulSyncRefreshInterval before. The monitoring can alert on
+// the Services not synced successfully for longer than a given duration, beyond the refresh interval.
+func (lbaas *LbaasV2) recordLastSuccessfulSync(ctx context.Context, service *corev1.Service, now time.Time) {
+	if last, err := time.Parse(time.RFC3339, service.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync]); err == nil && now.Sub(last) < lastSuccessfulSyncRefreshInterval {
+		return
+	}
+
+	updated := service.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	updated.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync] = now.UTC().Format(time.RFC3339)
+	if err := cpoutil.PatchService(ctx, lbaas.kclient, service, updated); err != nil {
+		klog.Warningf("Failed to save the last successful sync time of Service %s/%s: %v", service.Namespace, service.Name, err)
+		return
+	}
+	service.Annotations = updated.Annotations
+}
+
+// removeSyncStatus removes the LoadBalancerSynced condition of a Service whose load balancer is deleted.
+func (lbaas *LbaasV2) removeSyncStatus(ctx context.Context, service *corev1.Service) {
+	if apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced) == nil {
+		return
+	}
+	patch := []byte(`{"status":{"conditions":[{"type":"` + ServiceConditionLoadBalancerSynced + `","$patch":"delete"}]}}`)
+	_, err := lbaas.kclient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		klog.Warningf("Failed to remove the sync status of Service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+}
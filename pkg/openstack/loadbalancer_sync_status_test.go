@@ -0,0 +1,152 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateSyncCondition(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Generation: 1}}
+
+	// The first result is recorded
+	assert.True(t, updateSyncCondition(service, nil))
+	condition := apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, v1.ConditionTrue, condition.Status)
+		assert.Equal(t, syncResultSucceeded, condition.Reason)
+		assert.Equal(t, int64(1), condition.ObservedGeneration)
+	}
+
+	// The same result doesn't change the condition
+	assert.False(t, updateSyncCondition(service, nil))
+
+	// A new generation is recorded, the time of the condition is kept
+	since := condition.LastTransitionTime
+	service.Generation = 2
+	assert.True(t, updateSyncCondition(service, nil))
+	condition = apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	assert.Equal(t, int64(2), condition.ObservedGeneration)
+	assert.Equal(t, since, condition.LastTransitionTime)
+
+	// A failure is recorded with its error
+	assert.True(t, updateSyncCondition(service, fmt.Errorf("load balancer lb-id is not ACTIVE")))
+	condition = apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	assert.Equal(t, v1.ConditionFalse, condition.Status)
+	assert.Equal(t, syncResultFailed, condition.Reason)
+	assert.Equal(t, "load balancer lb-id is not ACTIVE", condition.Message)
+
+	// The same failure doesn't change the condition, another error does
+	assert.False(t, updateSyncCondition(service, fmt.Errorf("load balancer lb-id is not ACTIVE")))
+	assert.True(t, updateSyncCondition(service, fmt.Errorf("%s", strings.Repeat("x", 2*maxSyncErrorLength))))
+	condition = apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	assert.Len(t, condition.Message, maxSyncErrorLength+3)
+
+	// A success is recorded right away
+	assert.True(t, updateSyncCondition(service, nil))
+	condition = apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	assert.Equal(t, v1.ConditionTrue, condition.Status)
+	assert.Empty(t, condition.Message)
+	assert.Len(t, service.Status.Conditions, 1)
+}
+
+func TestLbaasV2_recordSyncStatus(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"},
+		Status: corev1.ServiceStatus{
+			Conditions: []v1.Condition{{Type: "Other", Status: v1.ConditionTrue, Reason: "Other"}},
+		},
+	}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+
+	// Not recorded by default
+	lbaas := &LbaasV2{LoadBalancer{kclient: kclient}}
+	lbaas.recordSyncStatus(context.TODO(), service, nil)
+	assert.Len(t, service.Status.Conditions, 1)
+
+	lbaas.opts.SyncStatusCondition = true
+	lbaas.recordSyncStatus(context.TODO(), service, fmt.Errorf("quota exceeded"))
+
+	saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	condition := apimeta.FindStatusCondition(saved.Status.Conditions, ServiceConditionLoadBalancerSynced)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, v1.ConditionFalse, condition.Status)
+		assert.Equal(t, "quota exceeded", condition.Message)
+	}
+	// The other conditions and the annotations are untouched
+	assert.NotNil(t, apimeta.FindStatusCondition(saved.Status.Conditions, "Other"))
+	assert.Empty(t, saved.Annotations)
+	assert.NotNil(t, apimeta.FindStatusCondition(service.Status.Conditions, ServiceConditionLoadBalancerSynced))
+}
+
+func TestLbaasV2_recordLastSuccessfulSync(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := &LbaasV2{LoadBalancer{kclient: kclient}}
+	lbaas.opts.SyncStatusCondition = true
+
+	// A failure doesn't set the time
+	lbaas.recordSyncStatus(context.TODO(), service, fmt.Errorf("quota exceeded"))
+	assert.Empty(t, service.Annotations)
+
+	lbaas.recordSyncStatus(context.TODO(), service, nil)
+	synced := service.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync]
+	assert.NotEmpty(t, synced)
+	saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, synced, saved.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync])
+
+	// The time is refreshed once the refresh interval elapsed, not at each success
+	now, err := time.Parse(time.RFC3339, synced)
+	assert.NoError(t, err)
+	lbaas.recordLastSuccessfulSync(context.TODO(), service, now.Add(lastSuccessfulSyncRefreshInterval/2))
+	assert.Equal(t, synced, service.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync])
+	lbaas.recordLastSuccessfulSync(context.TODO(), service, now.Add(lastSuccessfulSyncRefreshInterval))
+	assert.Equal(t, now.Add(lastSuccessfulSyncRefreshInterval).UTC().Format(time.RFC3339), service.Annotations[ServiceAnnotationLoadBalancerLastSuccessfulSync])
+}
+
+func TestLbaasV2_removeSyncStatus(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"},
+		Status: corev1.ServiceStatus{
+			Conditions: []v1.Condition{
+				{Type: "Other", Status: v1.ConditionTrue, Reason: "Other"},
+				{Type: ServiceConditionLoadBalancerSynced, Status: v1.ConditionTrue, Reason: syncResultSucceeded},
+			},
+		},
+	}
+	kclient := fake.NewSimpleClientset(service.DeepCopy())
+	lbaas := &LbaasV2{LoadBalancer{kclient: kclient}}
+
+	lbaas.removeSyncStatus(context.TODO(), service)
+
+	saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, apimeta.FindStatusCondition(saved.Status.Conditions, ServiceConditionLoadBalancerSynced))
+	assert.NotNil(t, apimeta.FindStatusCondition(saved.Status.Conditions, "Other"))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ServiceAnnotationLoadBalancerAppliedTags is the Service annotation recording the tags of the
+// ServiceAnnotationLoadBalancerTags annotation last applied to the load balancer, so that the tags removed from it
+// are removed from the load balancer without touching the tags set by others. It is managed by occm.
+const ServiceAnnotationLoadBalancerAppliedTags = "loadbalancer.openstack.org/applied-tags"
+
+// maxTagLength is the maximum length of an Octavia tag.
+const maxTagLength = 255
+
+// parseLoadBalancerTags parses the comma-separated list of tags of the ServiceAnnotationLoadBalancerTags annotation.
+// The tags identifying the Services sharing the load balancer are reserved.
+func parseLoadBalancerTags(annotation string) ([]string, error) {
+	var tags []string
+	for _, tag := range strings.Split(annotation, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if strings.HasPrefix(tag, servicePrefix) {
+			return nil, fmt.Errorf("tag %q uses the reserved prefix %s", tag, servicePrefix)
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// getAppliedLoadBalancerTags returns the tags last applied to the load balancer by the Service.
+func getAppliedLoadBalancerTags(service *corev1.Service) []string {
+	var tags []string
+	for _, tag := range strings.Split(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAppliedTags, ""), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// setAppliedLoadBalancerTags records the tags applied to the load balancer by the Service.
+func (lbaas *LbaasV2) setAppliedLoadBalancerTags(service *corev1.Service, tags []string) {
+	if len(tags) == 0 {
+		delete(service.Annotations, ServiceAnnotationLoadBalancerAppliedTags)
+		return
+	}
+	lbaas.updateServiceAnnotation(service, ServiceAnnotationLoadBalancerAppliedTags, strings.Join(tags, ","))
+}
+
+// mergeLoadBalancerTags returns the load balancer tags with the tags previously applied by the Service and no longer
+// requested removed and the requested tags added. The other tags are kept in their order. It returns false when the
+// tags don't need to be updated.
+func mergeLoadBalancerTags(current, applied, requested []string) ([]string, bool) {
+	merged := make([]string, 0, len(current)+len(requested))
+	changed := false
+	for _, tag := range current {
+		if slices.Contains(applied, tag) && !slices.Contains(requested, tag) {
+			changed = true
+			continue
+		}
+		merged = append(merged, tag)
+	}
+	for _, tag := range requested {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+// desiredLoadBalancerTags returns the tags of the load balancer of the Service, i.e. its current tags with the name
+// of the load balancer identifying the Service and, for the owner of the load balancer, the tags of the
+// ServiceAnnotationLoadBalancerTags annotation. It returns false when the tags don't need to be updated.
+func desiredLoadBalancerTags(service *corev1.Service, current []string, svcConf *serviceConfig, isLBOwner bool) ([]string, bool) {
+	requested := []string{svcConf.lbName}
+	var applied []string
+	if isLBOwner {
+		requested = append(requested, svcConf.lbTags...)
+		applied = getAppliedLoadBalancerTags(service)
+	}
+	return mergeLoadBalancerTags(current, applied, requested)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseLoadBalancerTags(t *testing.T) {
+	tags, err := parseLoadBalancerTags("")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	tags, err = parseLoadBalancerTags("cost-center=42, team=web ,,cost-center=42")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cost-center=42", "team=web"}, tags)
+
+	_, err = parseLoadBalancerTags("team=web,kube_service_cluster_ns_svc")
+	assert.Error(t, err)
+
+	_, err = parseLoadBalancerTags(strings.Repeat("a", maxTagLength+1))
+	assert.Error(t, err)
+}
+
+func TestMergeLoadBalancerTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   []string
+		applied   []string
+		requested []string
+		expected  []string
+		changed   bool
+	}{
+		{
+			name:      "in sync",
+			current:   []string{"kube_service_a", "team=web"},
+			applied:   []string{"team=web"},
+			requested: []string{"kube_service_a", "team=web"},
+			expected:  []string{"kube_service_a", "team=web"},
+		},
+		{
+			name:      "tags added",
+			current:   []string{"kube_service_a"},
+			requested: []string{"kube_service_a", "team=web"},
+			expected:  []string{"kube_service_a", "team=web"},
+			changed:   true,
+		},
+		{
+			name:      "applied tag removed, other tags kept",
+			current:   []string{"manual", "kube_service_a", "kube_service_b", "team=web", "cost-center=42"},
+			applied:   []string{"team=web", "cost-center=42"},
+			requested: []string{"kube_service_a", "cost-center=42"},
+			expected:  []string{"manual", "kube_service_a", "kube_service_b", "cost-center=42"},
+			changed:   true,
+		},
+		{
+			name:      "manual tag adopted",
+			current:   []string{"kube_service_a", "team=web"},
+			requested: []string{"kube_service_a", "team=web"},
+			expected:  []string{"kube_service_a", "team=web"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, changed := mergeLoadBalancerTags(test.current, test.applied, test.requested)
+			assert.Equal(t, test.expected, tags)
+			assert.Equal(t, test.changed, changed)
+		})
+	}
+}
+
+func TestDesiredLoadBalancerTags(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerAppliedTags: "team=web,cost-center=42",
+	}}}
+	svcConf := &serviceConfig{lbName: "kube_service_a", lbTags: []string{"team=api"}}
+	current := []string{"kube_service_b", "team=web", "cost-center=42"}
+
+	tags, changed := desiredLoadBalancerTags(service, current, svcConf, true)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"kube_service_b", "kube_service_a", "team=api"}, tags)
+
+	// A Service sharing the load balancer only adds its name.
+	tags, changed = desiredLoadBalancerTags(service, current, svcConf, false)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"kube_service_b", "team=web", "cost-center=42", "kube_service_a"}, tags)
+
+	lbaas := &LbaasV2{}
+	lbaas.setAppliedLoadBalancerTags(service, svcConf.lbTags)
+	assert.Equal(t, []string{"team=api"}, getAppliedLoadBalancerTags(service))
+	lbaas.setAppliedLoadBalancerTags(service, nil)
+	assert.NotContains(t, service.Annotations, ServiceAnnotationLoadBalancerAppliedTags)
+}
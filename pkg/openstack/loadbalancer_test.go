@@ -2,20 +2,32 @@ package openstack
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"k8s.io/utils/ptr"
+	"net/http"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/loadbalancers"
 	v2monitors "github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/monitors"
 	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/pools"
+	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/floatingips"
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/security/rules"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 )
 
@@ -533,6 +545,17 @@ func Test_getListenerProtocol(t *testing.T) {
 			},
 			expected: listeners.ProtocolTerminatedHTTPS,
 		},
+		{
+			name: "UDP protocol is kept with tls container ref and keep client IP",
+			testArg: testArg{
+				svcConf: &serviceConfig{
+					tlsContainerRef: "tls-container-ref",
+					keepClientIP:    true,
+				},
+				protocol: corev1.ProtocolUDP,
+			},
+			expected: listeners.ProtocolUDP,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -549,6 +572,7 @@ func TestLbaasV2_checkListenerPorts(t *testing.T) {
 		curListenerMapping map[listenerKey]*listeners.Listener
 		isLBOwner          bool
 		lbName             string
+		svcConf            *serviceConfig
 	}
 	tests := []struct {
 		name    string
@@ -694,13 +718,70 @@ func TestLbaasV2_checkListenerPorts(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "error is not thrown if the same port is used by another Service with a different protocol",
+			args: args{
+				service: &corev1.Service{
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{
+							{
+								Name:     "dns-udp",
+								Protocol: corev1.ProtocolUDP,
+								Port:     53,
+							},
+						},
+					},
+				},
+				curListenerMapping: map[listenerKey]*listeners.Listener{
+					{
+						Protocol: listeners.ProtocolTCP,
+						Port:     53,
+					}: {
+						ID:   "listenerid",
+						Tags: []string{"test-lb1"},
+					},
+				},
+				isLBOwner: false,
+				lbName:    "test-lb2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error is thrown if the same port and protocol is used by another Service",
+			args: args{
+				service: &corev1.Service{
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{
+							{
+								Name:     "dns-udp",
+								Protocol: corev1.ProtocolUDP,
+								Port:     53,
+							},
+						},
+					},
+				},
+				curListenerMapping: map[listenerKey]*listeners.Listener{
+					{
+						Protocol: listeners.ProtocolUDP,
+						Port:     53,
+					}: {
+						ID:   "listenerid",
+						Tags: []string{"test-lb1"},
+					},
+				},
+				isLBOwner: false,
+				lbName:    "test-lb2",
+				svcConf:   &serviceConfig{keepClientIP: true},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			lbaas := &LbaasV2{
 				LoadBalancer: LoadBalancer{},
 			}
-			err := lbaas.checkListenerPorts(tt.args.service, tt.args.curListenerMapping, tt.args.isLBOwner, tt.args.lbName)
+			err := lbaas.checkListenerPorts(tt.args.service, tt.args.curListenerMapping, tt.args.isLBOwner, tt.args.lbName, tt.args.svcConf)
 			if tt.wantErr == true {
 				assert.ErrorContains(t, err, "already exists")
 			} else {
@@ -709,6 +790,105 @@ func TestLbaasV2_checkListenerPorts(t *testing.T) {
 		})
 	}
 }
+
+func TestLbaasV2_ensureOctaviaListenerMixedProtocol(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const lbID = "lb-id"
+	var createdProtocols []string
+
+	th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+
+		var body struct {
+			Listener listeners.Listener `json:"listener"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode listener create request: %v", err)
+		}
+		createdProtocols = append(createdProtocols, body.Listener.Protocol)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"listener": {"id": "listener-%s", "protocol": "%s", "protocol_port": %d}}`,
+			body.Listener.Protocol, body.Listener.Protocol, body.Listener.ProtocolPort)
+	})
+	mockLoadBalancerActive(t, lbID)
+
+	lbaas := &LbaasV2{
+		LoadBalancer{
+			lb: fakeclient.ServiceClient(),
+		},
+	}
+	svcConf := &serviceConfig{connLimit: -1, keepClientIP: true}
+
+	curListeners := []listeners.Listener{
+		{ID: "listener-tcp", Protocol: string(listeners.ProtocolHTTP), ProtocolPort: 53, ConnLimit: -1,
+			InsertHeaders: map[string]string{annotationXForwardedFor: "true"}},
+	}
+	curListenerMapping := getListenerMapping(curListeners)
+
+	ports := []corev1.ServicePort{
+		{Name: "dns-tcp", Protocol: corev1.ProtocolTCP, Port: 53},
+		{Name: "dns-udp", Protocol: corev1.ProtocolUDP, Port: 53},
+	}
+
+	var ids []string
+	for i, port := range ports {
+		listener, err := lbaas.ensureOctaviaListener(lbID, fmt.Sprintf("listener_%d", i), curListenerMapping, port, svcConf)
+		assert.NoError(t, err)
+		ids = append(ids, listener.ID)
+	}
+
+	// The existing HTTP listener is reused for the TCP port, a UDP listener is created for the UDP port.
+	assert.Equal(t, []string{"listener-tcp", "listener-UDP"}, ids)
+	assert.Equal(t, []string{string(listeners.ProtocolUDP)}, createdProtocols)
+}
+
+func TestLbaasV2_ensureOctaviaListenerAdminStateUp(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const lbID = "lb-id"
+	var updates []map[string]interface{}
+
+	th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPut)
+
+		var body struct {
+			Listener map[string]interface{} `json:"listener"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode listener update request: %v", err)
+		}
+		updates = append(updates, body.Listener)
+
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"listener": {"id": "listener-id"}}`)
+	})
+	mockLoadBalancerActive(t, lbID)
+
+	lbaas := &LbaasV2{
+		LoadBalancer{
+			lb: fakeclient.ServiceClient(),
+		},
+	}
+	port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 80}
+	curListenerMapping := getListenerMapping([]listeners.Listener{
+		{ID: "listener-id", Protocol: string(listeners.ProtocolTCP), ProtocolPort: 80, ConnLimit: -1, AdminStateUp: false},
+	})
+
+	// The administrative state is left alone when it's not managed.
+	_, err := lbaas.ensureOctaviaListener(lbID, "listener_0", curListenerMapping, port, &serviceConfig{connLimit: -1})
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+
+	_, err = lbaas.ensureOctaviaListener(lbID, "listener_0", curListenerMapping, port, &serviceConfig{connLimit: -1, adminStateUp: ptr.To(true)})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"admin_state_up": true}}, updates)
+}
+
 func TestLbaasV2_createLoadBalancerStatus(t *testing.T) {
 	ipmodeProxy := corev1.LoadBalancerIPModeProxy
 	ipmodeVIP := corev1.LoadBalancerIPModeVIP
@@ -840,7 +1020,7 @@ func TestLbaasV2_createLoadBalancerStatus(t *testing.T) {
 				LoadBalancer: tt.fields.LoadBalancer,
 			}
 
-			result := lbaas.createLoadBalancerStatus(tt.args.service, tt.args.svcConf, tt.args.addr)
+			result := lbaas.createLoadBalancerStatus(tt.args.service, tt.args.svcConf, tt.args.addr, nil)
 			assert.Equal(t, tt.want.HostName, result.Ingress[0].Hostname)
 			assert.Equal(t, tt.want.IPAddress, result.Ingress[0].IP)
 			assert.Equal(t, tt.want.IPMode, result.Ingress[0].IPMode)
@@ -848,6 +1028,119 @@ func TestLbaasV2_createLoadBalancerStatus(t *testing.T) {
 	}
 }
 
+func TestLbaasV2_createLoadBalancerStatusAdditionalVIPs(t *testing.T) {
+	ipmodeVIP := corev1.LoadBalancerIPModeVIP
+	lbaas := &LbaasV2{}
+	service := &corev1.Service{}
+
+	result := lbaas.createLoadBalancerStatus(service, &serviceConfig{}, "172.24.4.10", []string{"10.0.1.5", "fd00::5"})
+	assert.Equal(t, []corev1.LoadBalancerIngress{
+		{IP: "172.24.4.10", IPMode: &ipmodeVIP},
+		{IP: "10.0.1.5", IPMode: &ipmodeVIP},
+		{IP: "fd00::5", IPMode: &ipmodeVIP},
+	}, result.Ingress)
+
+	service.Annotations = map[string]string{ServiceAnnotationLoadBalancerLoadbalancerHostname: "lb.example.com"}
+	result = lbaas.createLoadBalancerStatus(service, &serviceConfig{}, "172.24.4.10", []string{"10.0.1.5"})
+	assert.Equal(t, []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}}, result.Ingress)
+}
+
+func TestParseAdditionalVIPs(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []loadbalancers.AdditionalVip
+		wantErr    bool
+	}{
+		{
+			name:       "subnets only",
+			annotation: "subnet-a, subnet-b",
+			want:       []loadbalancers.AdditionalVip{{SubnetID: "subnet-a"}, {SubnetID: "subnet-b"}},
+		},
+		{
+			name:       "subnets with addresses",
+			annotation: "subnet-a=10.0.1.5,subnet-b=fd00::5,",
+			want: []loadbalancers.AdditionalVip{
+				{SubnetID: "subnet-a", IPAddress: "10.0.1.5"},
+				{SubnetID: "subnet-b", IPAddress: "fd00::5"},
+			},
+		},
+		{
+			name:       "missing subnet",
+			annotation: "=10.0.1.5",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid address",
+			annotation: "subnet-a=10.0.1",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAdditionalVIPs(tt.annotation)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseTLSVersions(t *testing.T) {
+	versions, err := parseTLSVersions("")
+	assert.NoError(t, err)
+	assert.Nil(t, versions)
+
+	versions, err = parseTLSVersions("TLSv1.2, TLSv1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, []listeners.TLSVersion{listeners.TLSVersionTLSv1_2, listeners.TLSVersionTLSv1_3}, versions)
+
+	_, err = parseTLSVersions("TLSv1.2,TLSv2")
+	assert.ErrorContains(t, err, `unknown TLS version "TLSv2"`)
+}
+
+func TestLbaasV2_buildListenerUpdateOptsTLS(t *testing.T) {
+	lbaas := &LbaasV2{
+		LoadBalancer{
+			opts: LoadBalancerOpts{LBProvider: "not-ovn"},
+			lb: &gophercloud.ServiceClient{
+				ProviderClient: &gophercloud.ProviderClient{},
+				Endpoint:       "",
+			},
+		},
+	}
+	port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 443}
+	listener := &listeners.Listener{
+		ID:                     "listener-id",
+		Protocol:               string(listeners.ProtocolTerminatedHTTPS),
+		ProtocolPort:           443,
+		ConnLimit:              -1,
+		DefaultTlsContainerRef: "tls-container-ref",
+		TLSCiphers:             "ECDHE-RSA-AES256-GCM-SHA384",
+		TLSVersions:            []string{"TLSv1.3", "TLSv1.2"},
+	}
+	svcConf := &serviceConfig{connLimit: -1, tlsContainerRef: "tls-container-ref", sniContainerRefs: []string{}}
+
+	// The ciphers and versions are left alone when they're not set.
+	_, changed := lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.False(t, changed)
+
+	svcConf.tlsCiphers = "ECDHE-RSA-AES256-GCM-SHA384"
+	svcConf.tlsVersions = []listeners.TLSVersion{listeners.TLSVersionTLSv1_2, listeners.TLSVersionTLSv1_3}
+	_, changed = lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.False(t, changed)
+
+	svcConf.tlsCiphers = "ECDHE-ECDSA-AES256-GCM-SHA384"
+	svcConf.tlsVersions = []listeners.TLSVersion{listeners.TLSVersionTLSv1_3}
+	updateOpts, changed := lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.True(t, changed)
+	assert.Equal(t, "ECDHE-ECDSA-AES256-GCM-SHA384", *updateOpts.TLSCiphers)
+	assert.Equal(t, []listeners.TLSVersion{listeners.TLSVersionTLSv1_3}, *updateOpts.TLSVersions)
+}
+
 func Test_getIntFromServiceAnnotation(t *testing.T) {
 	type args struct {
 		service        *corev1.Service
@@ -1062,7 +1355,7 @@ func Test_buildPoolCreateOpt(t *testing.T) {
 			},
 			want: pools.CreateOpts{
 				Name:        "test for pool protocol UDP with proxy protocol disabled",
-				Protocol:    pools.ProtocolHTTP,
+				Protocol:    pools.ProtocolUDP,
 				LBMethod:    "SOURCE_IP_PORT",
 				Persistence: &pools.SessionPersistence{Type: "SOURCE_IP"},
 			},
@@ -1234,6 +1527,115 @@ func Test_getSecurityGroupName(t *testing.T) {
 	}
 }
 
+func Test_getSecurityGroupRemoteCIDRs(t *testing.T) {
+	tests := []struct {
+		name        string
+		lbProvider  string
+		subnetCIDR  string
+		allowedCIDR []string
+		expected    []string
+	}{
+		{
+			name:        "amphora allows the member subnet",
+			lbProvider:  "amphora",
+			subnetCIDR:  "10.0.0.0/24",
+			allowedCIDR: []string{"192.168.0.0/16"},
+			expected:    []string{"10.0.0.0/24"},
+		},
+		{
+			name:        "ovn allows the source ranges",
+			lbProvider:  "ovn",
+			subnetCIDR:  "10.0.0.0/24",
+			allowedCIDR: []string{"192.168.0.0/16", "172.16.0.0/12"},
+			expected:    []string{"192.168.0.0/16", "172.16.0.0/12"},
+		},
+		{
+			name:        "ovn skips the source ranges of the other IP family",
+			lbProvider:  "ovn",
+			subnetCIDR:  "fd00::/64",
+			allowedCIDR: []string{"0.0.0.0/0", "2001:db8::/32"},
+			expected:    []string{"2001:db8::/32"},
+		},
+		{
+			name:        "ovn without matching source ranges",
+			lbProvider:  "ovn",
+			subnetCIDR:  "10.0.0.0/24",
+			allowedCIDR: []string{"::/0"},
+			expected:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getSecurityGroupRemoteCIDRs(test.lbProvider, test.subnetCIDR, test.allowedCIDR)
+
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func Test_getSecurityGroupRuleDescription(t *testing.T) {
+	data := securityGroupRuleDescriptionData{
+		ClusterName: "kubernetes",
+		Namespace:   "default",
+		Name:        "nginx",
+		PortName:    "http",
+		Protocol:    "TCP",
+		Port:        80,
+		NodePort:    30080,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default template",
+			template: defaultSecurityGroupRuleDescription,
+			expected: "Kubernetes cluster kubernetes, Service default/nginx, port http TCP/30080",
+		},
+		{
+			name:     "custom template",
+			template: "k8s:{{.ClusterName}}:{{.Namespace}}:{{.Name}}:{{.Port}}",
+			expected: "k8s:kubernetes:default:nginx:80",
+		},
+		{
+			name:     "empty template",
+			template: "",
+			expected: "",
+		},
+		{
+			name:     "description is cut to 255 characters",
+			template: strings.Repeat("a", 300),
+			expected: strings.Repeat("a", 255),
+		},
+		{
+			name:     "unknown field",
+			template: "{{.Unknown}}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid template",
+			template: "{{.Name",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			description, err := getSecurityGroupRuleDescription(test.template, data)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, description)
+		})
+	}
+}
+
 func Test_getBoolFromServiceAnnotation(t *testing.T) {
 	type testargs struct {
 		service        *corev1.Service
@@ -2221,6 +2623,244 @@ func TestLbaasV2_getNetworkID(t *testing.T) {
 	}
 }
 
+func TestLbaasV2_detectFloatingNetworkID(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/networks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"networks": [{"id": "internal-id", "subnets": ["subnet-1"]}, {"id": "external-id", "router:external": true, "subnets": ["subnet-2"]}]}`)
+	})
+	th.Mux.HandleFunc("/subnets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"subnets": [{"id": "subnet-2", "network_id": "external-id", "ip_version": 4}]}`)
+	})
+
+	tests := []struct {
+		name      string
+		require   bool
+		want      string
+		wantErr   bool
+		wantEvent bool
+	}{
+		{
+			name: "autodetect the external network",
+			want: "external-id",
+		},
+		{
+			name:      "floating network required",
+			require:   true,
+			wantErr:   true,
+			wantEvent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			lbaas := LbaasV2{LoadBalancer{
+				network:       fakeclient.ServiceClient(),
+				eventRecorder: recorder,
+				opts:          LoadBalancerOpts{RequireFloatingNetworkID: tt.require},
+			}}
+
+			got, err := lbaas.detectFloatingNetworkID(context.TODO(), &corev1.Service{}, "default/svc")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			if tt.wantEvent {
+				assert.Contains(t, <-recorder.Events, eventLBFloatingNetworkMissing)
+			} else {
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func TestLbaasV2_detectFloatingNetworkIDNotFound(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/networks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"networks": [{"id": "internal-id", "subnets": ["subnet-1"]}]}`)
+	})
+
+	tests := []struct {
+		name      string
+		require   bool
+		wantErr   bool
+		wantEvent string
+	}{
+		{
+			name:      "fall back to an internal load balancer",
+			wantEvent: eventLBExternalNetworkSearchFailed,
+		},
+		{
+			name:      "floating IP required",
+			require:   true,
+			wantErr:   true,
+			wantEvent: eventLBFloatingIPRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			lbaas := LbaasV2{LoadBalancer{
+				network:       fakeclient.ServiceClient(),
+				eventRecorder: recorder,
+			}}
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{
+				ServiceAnnotationLoadBalancerRequireFloatingIP: strconv.FormatBool(tt.require),
+			}}}
+
+			got, err := lbaas.detectFloatingNetworkID(context.TODO(), service, "default/svc")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Empty(t, got)
+			assert.Contains(t, <-recorder.Events, tt.wantEvent)
+		})
+	}
+}
+
+func TestLbaasV2_attachFloatingIPWithoutFloatingNetwork(t *testing.T) {
+	tests := []struct {
+		name      string
+		require   bool
+		wantErr   bool
+		wantEvent string
+	}{
+		{
+			name:      "fall back to an internal load balancer",
+			wantEvent: eventLBForceInternal,
+		},
+		{
+			name:      "floating IP required",
+			require:   true,
+			wantErr:   true,
+			wantEvent: eventLBFloatingIPRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			lbaas := LbaasV2{LoadBalancer{eventRecorder: recorder}}
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{
+				ServiceAnnotationLoadBalancerRequireFloatingIP: strconv.FormatBool(tt.require),
+			}}}
+
+			fip, err := lbaas.attachFloatingIP(context.TODO(), "kubernetes", service, &loadbalancers.LoadBalancer{VipPortID: "port-id"}, &serviceConfig{}, true)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Nil(t, fip)
+			assert.Contains(t, <-recorder.Events, tt.wantEvent)
+		})
+	}
+}
+
+// mockLoadBalancer serves the load balancer as ACTIVE to the GET requests. The returned function deletes it, it's then
+// not found.
+func mockLoadBalancer(t *testing.T, lb loadbalancers.LoadBalancer) func() {
+	deleted := false
+	lb.ProvisioningStatus = activeStatus
+	th.Mux.HandleFunc("/lbaas/loadbalancers/"+lb.ID, func(w http.ResponseWriter, r *http.Request) {
+		if deleted {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"loadbalancer": lb})
+	})
+	return func() { deleted = true }
+}
+
+// mockLoadBalancerActive serves the ACTIVE load balancer id of the default/svc Service, see mockLoadBalancer.
+func mockLoadBalancerActive(t *testing.T, id string) func() {
+	return mockLoadBalancer(t, loadbalancers.LoadBalancer{ID: id, Name: "kube_service_kubernetes_default_svc"})
+}
+
+func TestLbaasV2_ensureLoadBalancerDeletedIdempotent(t *testing.T) {
+	lbAnnotations := map[string]string{
+		ServiceAnnotationLoadBalancerID:               "lb-id",
+		ServiceAnnotationLoadBalancerAddress:          "172.24.4.10",
+		ServiceAnnotationLoadBalancerReconcileJournal: `{"step":"Completed","lbID":"lb-id"}`,
+	}
+
+	testCases := []struct {
+		name     string
+		register func(t *testing.T, fip *fakeFloatingIPs)
+	}{
+		{
+			name: "load balancer deleted out-of-band",
+			register: func(t *testing.T, fip *fakeFloatingIPs) {
+				th.Mux.HandleFunc("/lbaas/loadbalancers/lb-id", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNotFound)
+				})
+				// The floating IP was detached from the deleted VIP port
+				fip.fip = &floatingips.FloatingIP{ID: "fip-id", FloatingIP: "172.24.4.10", Description: getFloatingIPDescription("kubernetes", "default/svc")}
+				fip.register(t)
+			},
+		},
+		{
+			name: "load balancer deleted during the deletion of its listeners",
+			register: func(t *testing.T, _ *fakeFloatingIPs) {
+				deleteLoadBalancer := mockLoadBalancerActive(t, "lb-id")
+				th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("Content-Type", "application/json")
+					fmt.Fprint(w, `{"listeners": [{"id": "listener-id", "protocol": "TCP", "protocol_port": 80}]}`)
+				})
+				th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("Content-Type", "application/json")
+					fmt.Fprint(w, `{"pools": []}`)
+				})
+				th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+					th.TestMethod(t, r, http.MethodDelete)
+					deleteLoadBalancer()
+					w.WriteHeader(http.StatusNotFound)
+				})
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			fip := &fakeFloatingIPs{}
+			tc.register(t, fip)
+			th.Mux.HandleFunc("/security-groups", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprint(w, `{"security_groups": []}`)
+			})
+
+			service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: lbAnnotations}}
+			kclient := fake.NewSimpleClientset(service.DeepCopy())
+			lbaas := &LbaasV2{LoadBalancer{
+				lb:      fakeclient.ServiceClient(),
+				network: fakeclient.ServiceClient(),
+				kclient: kclient,
+			}}
+
+			assert.NoError(t, lbaas.ensureLoadBalancerDeleted(context.TODO(), "kubernetes", service))
+
+			saved, err := kclient.CoreV1().Services("default").Get(context.TODO(), "svc", v1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Empty(t, saved.Annotations)
+			assert.Equal(t, fip.fip != nil, fip.deleted)
+		})
+	}
+}
+
 func Test_buildMonitorCreateOpts(t *testing.T) {
 	type testArg struct {
 		lbaas   *LbaasV2
@@ -2419,6 +3059,26 @@ func TestBuildListenerCreateOpt(t *testing.T) {
 				Tags:                   nil,
 			},
 		},
+		{
+			name: "Test UDP port with TLSContainerRef and X-Forwarded-For",
+			port: corev1.ServicePort{
+				Protocol: "UDP",
+				Port:     443,
+			},
+			svcConf: &serviceConfig{
+				connLimit:       100,
+				lbName:          "my-lb",
+				tlsContainerRef: "tls-container-ref",
+				keepClientIP:    true,
+			},
+			expectedCreateOpt: listeners.CreateOpts{
+				Name:         "Test UDP port with TLSContainerRef and X-Forwarded-For",
+				Protocol:     listeners.ProtocolUDP,
+				ProtocolPort: 443,
+				ConnLimit:    &svcConf.connLimit,
+				Tags:         nil,
+			},
+		},
 		{
 			name: "Test with TLSContainerRef but without X-Forwarded-For",
 			port: corev1.ServicePort{
@@ -2440,6 +3100,30 @@ func TestBuildListenerCreateOpt(t *testing.T) {
 				Tags:                   nil,
 			},
 		},
+		{
+			name: "Test with TLSContainerRef, ciphers and versions",
+			port: corev1.ServicePort{
+				Protocol: "TCP",
+				Port:     443,
+			},
+			svcConf: &serviceConfig{
+				connLimit:       100,
+				lbName:          "my-lb",
+				tlsContainerRef: "tls-container-ref",
+				tlsCiphers:      "ECDHE-RSA-AES256-GCM-SHA384",
+				tlsVersions:     []listeners.TLSVersion{listeners.TLSVersionTLSv1_2, listeners.TLSVersionTLSv1_3},
+			},
+			expectedCreateOpt: listeners.CreateOpts{
+				Name:                   "Test with TLSContainerRef, ciphers and versions",
+				Protocol:               listeners.ProtocolTerminatedHTTPS,
+				ProtocolPort:           443,
+				ConnLimit:              &svcConf.connLimit,
+				DefaultTlsContainerRef: "tls-container-ref",
+				TLSCiphers:             "ECDHE-RSA-AES256-GCM-SHA384",
+				TLSVersions:            []listeners.TLSVersion{listeners.TLSVersionTLSv1_2, listeners.TLSVersionTLSv1_3},
+				Tags:                   nil,
+			},
+		},
 		{
 			name: "Test with supported CIDRs",
 			port: corev1.ServicePort{
@@ -2485,6 +3169,26 @@ func TestBuildListenerCreateOpt(t *testing.T) {
 				Tags:          nil,
 			},
 		},
+		{
+			name: "Test with administrative state down",
+			port: corev1.ServicePort{
+				Protocol: "TCP",
+				Port:     80,
+			},
+			svcConf: &serviceConfig{
+				connLimit:    100,
+				lbName:       "my-lb",
+				adminStateUp: ptr.To(false),
+			},
+			expectedCreateOpt: listeners.CreateOpts{
+				Name:         "Test with administrative state down",
+				Protocol:     listeners.ProtocolTCP,
+				ProtocolPort: 80,
+				ConnLimit:    &svcConf.connLimit,
+				AdminStateUp: ptr.To(false),
+				Tags:         nil,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -2507,6 +3211,36 @@ func TestBuildListenerCreateOpt(t *testing.T) {
 	}
 }
 
+func TestAddControlPlaneNodes(t *testing.T) {
+	ready := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}}
+	notReady := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}}
+
+	worker := &corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "worker"}, Status: ready}
+	controlPlane := &corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "control-plane", Labels: map[string]string{labelNodeRoleControlPlane: ""}}, Status: ready}
+	master := &corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "master", Labels: map[string]string{labelNodeRoleMaster: ""}}, Status: ready}
+	notReadyControlPlane := &corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "not-ready", Labels: map[string]string{labelNodeRoleControlPlane: ""}}, Status: notReady}
+	excludedWorker := &corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "excluded-worker"}, Status: ready}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*corev1.Node{worker, controlPlane, master, notReadyControlPlane, excludedWorker} {
+		assert.NoError(t, indexer.Add(node))
+	}
+
+	lbaas := &LbaasV2{LoadBalancer{nodeLister: corelisters.NewNodeLister(indexer)}}
+	nodes := lbaas.addControlPlaneNodes([]*corev1.Node{worker, master})
+
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"control-plane", "master", "worker"}, names)
+
+	// Without the informer, the nodes are left alone
+	lbaas = &LbaasV2{}
+	assert.Equal(t, []*corev1.Node{worker}, lbaas.addControlPlaneNodes([]*corev1.Node{worker}))
+}
+
 func TestFilterNodes(t *testing.T) {
 	tests := []struct {
 		name           string
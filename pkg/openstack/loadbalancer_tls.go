@@ -0,0 +1,297 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/containers"
+	"github.com/gophercloud/gophercloud/v2/openstack/keymanager/v1/secrets"
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	openstackutil "k8s.io/cloud-provider-openstack/pkg/util/openstack"
+)
+
+// tlsFingerprintTagPrefix prefixes the listener tag holding the fingerprint of the Barbican container or secret the
+// listener was last configured with.
+const tlsFingerprintTagPrefix = "tls-fingerprint:"
+
+// getTLSContainerFingerprint checks that the Barbican container or secret exists and returns a fingerprint of its
+// references and update time, which changes when the certificate is rotated.
+// The ref has the format: https://{keymanager_host}/v1/containers/{uuid} or https://{keymanager_host}/v1/secrets/{uuid}
+func getTLSContainerFingerprint(ctx context.Context, client *gophercloud.ServiceClient, ref string) (string, error) {
+	slice := strings.Split(ref, "/")
+	if len(slice) < 2 {
+		return "", fmt.Errorf("invalid tlsContainerRef %q", ref)
+	}
+	barbicanUUID := slice[len(slice)-1]
+	barbicanType := slice[len(slice)-2]
+
+	var parts []string
+	switch barbicanType {
+	case "containers":
+		container, err := containers.Get(ctx, client, barbicanUUID).Extract()
+		if err != nil {
+			return "", fmt.Errorf("failed to get tls container %q: %v", ref, err)
+		}
+		klog.V(4).Infof("Default TLS container %q found", container.ContainerRef)
+		parts = append(parts, container.ContainerRef, container.Updated.UTC().Format(time.RFC3339Nano))
+		for _, s := range container.SecretRefs {
+			parts = append(parts, s.Name+"="+s.SecretRef)
+		}
+		slices.Sort(parts[2:])
+	case "secrets":
+		secret, err := secrets.Get(ctx, client, barbicanUUID).Extract()
+		if err != nil {
+			return "", fmt.Errorf("failed to get tls secret %q: %v", ref, err)
+		}
+		klog.V(4).Infof("Default TLS secret %q found", secret.SecretRef)
+		parts = append(parts, secret.SecretRef, secret.Updated.UTC().Format(time.RFC3339Nano))
+	default:
+		return "", fmt.Errorf("tlsContainerRef type %s unknown", barbicanType)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// setClientAuthentication sets the TLS client authentication of the TERMINATED_HTTPS listeners from the annotations of
+// the Service, checking that the Barbican secrets exist. The client authentication of the listeners is left untouched
+// when none of the annotations is set, so removing them doesn't disable it: the annotation must be set to NONE first.
+// OPTIONAL and MANDATORY fail when Octavia doesn't support client authentication, the listeners would accept the
+// clients without a certificate otherwise.
+func (lbaas *LbaasV2) setClientAuthentication(ctx context.Context, service *corev1.Service, svcConf *serviceConfig) error {
+	mode := strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClientAuthentication, ""))
+	caRef := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClientCATLSContainerRef, "")
+	crlRef := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClientCRLContainerRef, "")
+	if mode == "" && caRef == "" && crlRef == "" {
+		return nil
+	}
+
+	if svcConf.tlsContainerRef == "" {
+		return fmt.Errorf("annotations %s, %s and %s require a default tls container ref to be set",
+			ServiceAnnotationLoadBalancerClientAuthentication, ServiceAnnotationLoadBalancerClientCATLSContainerRef, ServiceAnnotationLoadBalancerClientCRLContainerRef)
+	}
+	switch listeners.ClientAuthentication(mode) {
+	case "":
+		mode = string(listeners.ClientAuthenticationNone)
+	case listeners.ClientAuthenticationNone, listeners.ClientAuthenticationOptional, listeners.ClientAuthenticationMandatory:
+	default:
+		return fmt.Errorf("invalid %s %q, expected NONE, OPTIONAL or MANDATORY", ServiceAnnotationLoadBalancerClientAuthentication, mode)
+	}
+	if caRef == "" && mode != string(listeners.ClientAuthenticationNone) {
+		return fmt.Errorf("%s %s requires %s", ServiceAnnotationLoadBalancerClientAuthentication, mode, ServiceAnnotationLoadBalancerClientCATLSContainerRef)
+	}
+	if caRef == "" && crlRef != "" {
+		return fmt.Errorf("%s requires %s", ServiceAnnotationLoadBalancerClientCRLContainerRef, ServiceAnnotationLoadBalancerClientCATLSContainerRef)
+	}
+
+	if !openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureClientAuth, lbaas.opts.LBProvider) {
+		if mode != string(listeners.ClientAuthenticationNone) {
+			return fmt.Errorf("%s %s is not supported, it requires Octavia API version 2.8 or later and a provider other than ovn",
+				ServiceAnnotationLoadBalancerClientAuthentication, mode)
+		}
+		msg := "Listener client authentication isn't supported. Please, upgrade Octavia API to version 2.8 or later (Train release) to use it for Service %s/%s"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBClientAuthIgnored, msg, service.Namespace, service.Name)
+		klog.Warningf(msg, service.Namespace, service.Name)
+		return nil
+	}
+
+	if lbaas.opts.ContainerStore == "barbican" {
+		fingerprints, err := getClientAuthFingerprints(ctx, lbaas.secret, caRef, crlRef)
+		if err != nil {
+			return fmt.Errorf("failed to validate client certificate secret: %v", err)
+		}
+		// The rotation of the CA and CRL reloads the listener like the one of the default container
+		svcConf.tlsFingerprint = combineTLSFingerprints(svcConf.tlsFingerprint, fingerprints...)
+	}
+
+	svcConf.clientAuthentication = listeners.ClientAuthentication(mode)
+	svcConf.clientCATLSContainerRef = caRef
+	svcConf.clientCRLContainerRef = crlRef
+	return nil
+}
+
+// checkClientAuthLeftEnforced warns when the client authentication annotations were removed from the Service while the
+// listener still enforces the client certificates, setClientAuthentication leaves the listener untouched then.
+func (lbaas *LbaasV2) checkClientAuthLeftEnforced(service *corev1.Service, listener *listeners.Listener, svcConf *serviceConfig) {
+	if svcConf.clientAuthentication != "" {
+		return
+	}
+	switch listeners.ClientAuthentication(listener.ClientAuthentication) {
+	case listeners.ClientAuthenticationOptional, listeners.ClientAuthenticationMandatory:
+		msg := "Listener %s still enforces the client authentication %s without the annotation %s, set it to NONE to disable it for Service %s/%s"
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, eventLBClientAuthLeftEnforced, msg,
+			listener.ID, listener.ClientAuthentication, ServiceAnnotationLoadBalancerClientAuthentication, service.Namespace, service.Name)
+		klog.Warningf(msg, listener.ID, listener.ClientAuthentication, ServiceAnnotationLoadBalancerClientAuthentication, service.Namespace, service.Name)
+	}
+}
+
+// getClientAuthFingerprints checks that the Barbican secrets of the client CA and CRL exist and returns their
+// fingerprints, skipping the unset ones.
+func getClientAuthFingerprints(ctx context.Context, client *gophercloud.ServiceClient, caRef, crlRef string) ([]string, error) {
+	var fingerprints []string
+	for _, ref := range []string{caRef, crlRef} {
+		if ref == "" {
+			continue
+		}
+		fingerprint, err := getTLSContainerFingerprint(ctx, client, ref)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// combineTLSFingerprints returns a single fingerprint covering the default container and the client CA and CRL. The
+// fingerprint of the default container is returned as is without any other, so the tags of the listeners not using
+// client authentication are kept.
+func combineTLSFingerprints(fingerprint string, others ...string) string {
+	if fingerprint == "" || len(others) == 0 {
+		return fingerprint
+	}
+	hash := sha256.Sum256([]byte(strings.Join(append([]string{fingerprint}, others...), "\n")))
+	return hex.EncodeToString(hash[:8])
+}
+
+// getListenerTLSFingerprint returns the fingerprint recorded in the listener tags, if any.
+func getListenerTLSFingerprint(tags []string) string {
+	for _, tag := range tags {
+		if fingerprint, ok := strings.CutPrefix(tag, tlsFingerprintTagPrefix); ok {
+			return fingerprint
+		}
+	}
+	return ""
+}
+
+// setListenerTLSFingerprint returns the listener tags recording the given fingerprint.
+func setListenerTLSFingerprint(tags []string, fingerprint string) []string {
+	newTags := make([]string, 0, len(tags)+1)
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, tlsFingerprintTagPrefix) {
+			newTags = append(newTags, tag)
+		}
+	}
+	return append(newTags, tlsFingerprintTagPrefix+fingerprint)
+}
+
+// listenerNeedsTLSReload checks whether the certificate of the listener was rotated since the listener was last
+// configured. Listeners without a recorded fingerprint are only tagged.
+func listenerNeedsTLSReload(listener *listeners.Listener, fingerprint string) bool {
+	current := getListenerTLSFingerprint(listener.Tags)
+	return current != "" && current != fingerprint
+}
+
+// ensureListenersTLSFingerprint updates the TERMINATED_HTTPS listeners of the Service whose Barbican container or
+// secret changed. Updating the listener makes Octavia fetch the certificate again.
+func (lbaas *LbaasV2) ensureListenersTLSFingerprint(ctx context.Context, service *corev1.Service) error {
+	if lbaas.secret == nil || lbaas.opts.ContainerStore != "barbican" || lbaas.isDryRun(service) || lbaas.isPaused(service) {
+		return nil
+	}
+
+	svcConf := new(serviceConfig)
+	if err := lbaas.checkServiceDelete(service, svcConf); err != nil {
+		return err
+	}
+	if svcConf.tlsContainerRef == "" || svcConf.lbID == "" || !svcConf.supportLBTags {
+		return nil
+	}
+
+	fingerprint, err := getTLSContainerFingerprint(ctx, lbaas.secret, svcConf.tlsContainerRef)
+	if err != nil {
+		return err
+	}
+	// The client CA and CRL of the annotations, the listeners still using other ones are left to the Service sync
+	caRef := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClientCATLSContainerRef, "")
+	crlRef := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClientCRLContainerRef, "")
+	clientAuthFingerprints, err := getClientAuthFingerprints(ctx, lbaas.secret, caRef, crlRef)
+	if err != nil {
+		return err
+	}
+	fingerprint = combineTLSFingerprints(fingerprint, clientAuthFingerprints...)
+
+	listenerList, err := openstackutil.GetListenersByLoadBalancerID(lbaas.lb, svcConf.lbID)
+	if err != nil {
+		return fmt.Errorf("error getting LB %s listeners: %v", svcConf.lbID, err)
+	}
+	curListenerMapping := getListenerMapping(listenerList)
+
+	for _, port := range service.Spec.Ports {
+		listener, isPresent := curListenerMapping[getListenerKey(port, svcConf)]
+		if !isPresent || listener.DefaultTlsContainerRef != svcConf.tlsContainerRef || getListenerTLSFingerprint(listener.Tags) == fingerprint {
+			continue
+		}
+		if caRef != "" && (listener.ClientCATLSContainerRef != caRef || listener.ClientCRLContainerRef != crlRef) {
+			continue
+		}
+
+		tags := setListenerTLSFingerprint(listener.Tags, fingerprint)
+		updateOpts := listeners.UpdateOpts{Tags: &tags}
+		if listenerNeedsTLSReload(listener, fingerprint) {
+			klog.InfoS("Reloading rotated TLS certificate of listener", "listenerID", listener.ID, "lbID", svcConf.lbID, "service", klog.KObj(service))
+			updateOpts.DefaultTlsContainerRef = &svcConf.tlsContainerRef
+			if caRef != "" {
+				updateOpts.ClientCATLSContainerRef = &caRef
+				updateOpts.ClientCRLContainerRef = &crlRef
+			}
+			lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, eventLBTLSCertificateRotated, "Reloading the rotated TLS certificates of listener %s", listener.ID)
+		}
+		if err := openstackutil.UpdateListener(lbaas.lb, svcConf.lbID, listener.ID, updateOpts); err != nil {
+			return fmt.Errorf("failed to update listener %s of loadbalancer %s: %v", listener.ID, svcConf.lbID, err)
+		}
+	}
+	return nil
+}
+
+// runTLSCertificateCheck periodically checks the Barbican containers and secrets of the TERMINATED_HTTPS listeners of
+// the LoadBalancer Services and reloads the rotated certificates.
+func (lbaas *LbaasV2) runTLSCertificateCheck(serviceLister corelisters.ServiceLister, hasSynced cache.InformerSynced, interval time.Duration, stopCh <-chan struct{}) {
+	if !cache.WaitForCacheSync(stopCh, hasSynced) {
+		klog.Error("Timed out waiting for the Service cache to sync")
+		return
+	}
+
+	wait.Until(func() {
+		services, err := serviceLister.List(labels.Everything())
+		if err != nil {
+			klog.Errorf("Failed to list Services: %v", err)
+			return
+		}
+		for _, service := range services {
+			if service.Spec.Type != corev1.ServiceTypeLoadBalancer || !lbaas.managesService(service) {
+				continue
+			}
+			if err := lbaas.ensureListenersTLSFingerprint(context.TODO(), service); err != nil {
+				klog.Errorf("Failed to check the TLS certificate of Service %s: %v", klog.KObj(service), err)
+			}
+		}
+	}, interval, stopCh)
+}
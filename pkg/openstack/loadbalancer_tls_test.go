@@ -0,0 +1,393 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2/openstack/loadbalancer/v2/listeners"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetTLSContainerFingerprint(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	secretRef := "https://barbican/v1/secrets/certificate-1"
+	th.Mux.HandleFunc("/containers/container-id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"container_ref": "https://barbican/v1/containers/container-id",
+			"name": "tls",
+			"type": "certificate",
+			"status": "ACTIVE",
+			"updated": "2024-01-01T00:00:00",
+			"secret_refs": [{"name": "certificate", "secret_ref": %q}]
+		}`, secretRef)
+	})
+
+	client := fakeclient.ServiceClient()
+	fingerprint, err := getTLSContainerFingerprint(context.TODO(), client, "https://barbican/v1/containers/container-id")
+	assert.NoError(t, err)
+	assert.Len(t, fingerprint, 16)
+
+	again, err := getTLSContainerFingerprint(context.TODO(), client, "https://barbican/v1/containers/container-id")
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, again)
+
+	secretRef = "https://barbican/v1/secrets/certificate-2"
+	rotated, err := getTLSContainerFingerprint(context.TODO(), client, "https://barbican/v1/containers/container-id")
+	assert.NoError(t, err)
+	assert.NotEqual(t, fingerprint, rotated)
+
+	_, err = getTLSContainerFingerprint(context.TODO(), client, "https://barbican/v1/orders/order-id")
+	assert.Error(t, err)
+}
+
+func TestListenerTLSFingerprintTags(t *testing.T) {
+	assert.Equal(t, "", getListenerTLSFingerprint([]string{"kube_service_cluster_default_svc"}))
+
+	tags := setListenerTLSFingerprint([]string{"kube_service_cluster_default_svc"}, "old")
+	assert.Equal(t, []string{"kube_service_cluster_default_svc", "tls-fingerprint:old"}, tags)
+	assert.Equal(t, "old", getListenerTLSFingerprint(tags))
+
+	tags = setListenerTLSFingerprint(tags, "new")
+	assert.Equal(t, []string{"kube_service_cluster_default_svc", "tls-fingerprint:new"}, tags)
+
+	assert.False(t, listenerNeedsTLSReload(&listeners.Listener{}, "new"))
+	assert.False(t, listenerNeedsTLSReload(&listeners.Listener{Tags: tags}, "new"))
+	assert.True(t, listenerNeedsTLSReload(&listeners.Listener{Tags: tags}, "newer"))
+}
+
+func TestLbaasV2_ensureOctaviaListenerTLSRotation(t *testing.T) {
+	const tlsContainerRef = "https://barbican/v1/containers/container-id"
+	const lbName = "kube_service_cluster_default_svc"
+
+	testCases := []struct {
+		name           string
+		tags           []string
+		expectedTags   []string
+		expectedReload bool
+	}{
+		{
+			name:           "rotated certificate is reloaded",
+			tags:           []string{lbName, "tls-fingerprint:old"},
+			expectedTags:   []string{lbName, "tls-fingerprint:new"},
+			expectedReload: true,
+		},
+		{
+			name:         "listener without fingerprint is tagged",
+			tags:         []string{lbName},
+			expectedTags: []string{lbName, "tls-fingerprint:new"},
+		},
+		{
+			name:         "listener without its own tag keeps the fingerprint",
+			tags:         []string{"tls-fingerprint:new", "other"},
+			expectedTags: []string{"tls-fingerprint:new", "other", lbName},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			var update map[string]interface{}
+			th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+				th.TestMethod(t, r, http.MethodPut)
+				var body struct {
+					Listener map[string]interface{} `json:"listener"`
+				}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				update = body.Listener
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprint(w, `{"listener": {"id": "listener-id"}}`)
+			})
+			mockLoadBalancerActive(t, "lb-id")
+
+			lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+			port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 443}
+			svcConf := &serviceConfig{
+				lbName:          lbName,
+				supportLBTags:   true,
+				tlsContainerRef: tlsContainerRef,
+				tlsFingerprint:  "new",
+				connLimit:       -1,
+			}
+			listener := &listeners.Listener{
+				ID:                     "listener-id",
+				Protocol:               string(listeners.ProtocolTerminatedHTTPS),
+				ProtocolPort:           443,
+				ConnLimit:              -1,
+				DefaultTlsContainerRef: tlsContainerRef,
+				Tags:                   tc.tags,
+			}
+			mapping := map[listenerKey]*listeners.Listener{getListenerKey(port, svcConf): listener}
+
+			_, err := lbaas.ensureOctaviaListener("lb-id", "listener", mapping, port, svcConf)
+			assert.NoError(t, err)
+
+			var tags []string
+			for _, tag := range update["tags"].([]interface{}) {
+				tags = append(tags, tag.(string))
+			}
+			assert.Equal(t, tc.expectedTags, tags)
+			_, reload := update["default_tls_container_ref"]
+			assert.Equal(t, tc.expectedReload, reload)
+		})
+	}
+}
+
+func TestGetSNIContainerRefs(t *testing.T) {
+	svcConf := &serviceConfig{
+		tlsContainerRef:  "https://barbican/v1/containers/default",
+		sniContainerRefs: []string{"https://barbican/v1/containers/sni-1", "https://barbican/v1/containers/sni-2"},
+	}
+
+	assert.Equal(t, svcConf.sniContainerRefs, getSNIContainerRefs(corev1.ServicePort{Protocol: corev1.ProtocolTCP}, svcConf))
+	assert.Empty(t, getSNIContainerRefs(corev1.ServicePort{Protocol: corev1.ProtocolUDP}, svcConf))
+	assert.Empty(t, getSNIContainerRefs(corev1.ServicePort{Protocol: corev1.ProtocolTCP}, &serviceConfig{sniContainerRefs: svcConf.sniContainerRefs}))
+
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+	opts := lbaas.buildListenerCreateOpt(corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 443}, svcConf, "listener")
+	assert.Equal(t, listeners.ProtocolTerminatedHTTPS, opts.Protocol)
+	assert.Equal(t, svcConf.sniContainerRefs, opts.SniContainerRefs)
+}
+
+func TestLbaasV2_ensureOctaviaListenerSNIContainerRefs(t *testing.T) {
+	const tlsContainerRef = "https://barbican/v1/containers/default"
+
+	testCases := []struct {
+		name        string
+		current     []string
+		expected    []string
+		expectedPut bool
+	}{
+		{name: "SNI containers added", expected: []string{"sni-1", "sni-2"}, expectedPut: true},
+		{name: "SNI containers unchanged in another order", current: []string{"sni-2", "sni-1"}, expected: []string{"sni-1", "sni-2"}},
+		{name: "SNI containers removed", current: []string{"sni-1"}, expected: []string{}, expectedPut: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			var update map[string]interface{}
+			th.Mux.HandleFunc("/lbaas/listeners/listener-id", func(w http.ResponseWriter, r *http.Request) {
+				th.TestMethod(t, r, http.MethodPut)
+				var body struct {
+					Listener map[string]interface{} `json:"listener"`
+				}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				update = body.Listener
+				w.Header().Add("Content-Type", "application/json")
+				fmt.Fprint(w, `{"listener": {"id": "listener-id"}}`)
+			})
+			mockLoadBalancerActive(t, "lb-id")
+
+			lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+			port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 443}
+			svcConf := &serviceConfig{
+				tlsContainerRef:  tlsContainerRef,
+				sniContainerRefs: tc.expected,
+				connLimit:        -1,
+			}
+			listener := &listeners.Listener{
+				ID:                     "listener-id",
+				Protocol:               string(listeners.ProtocolTerminatedHTTPS),
+				ProtocolPort:           443,
+				ConnLimit:              -1,
+				DefaultTlsContainerRef: tlsContainerRef,
+				SniContainerRefs:       tc.current,
+			}
+			mapping := map[listenerKey]*listeners.Listener{getListenerKey(port, svcConf): listener}
+
+			_, err := lbaas.ensureOctaviaListener("lb-id", "listener", mapping, port, svcConf)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.expectedPut, update != nil)
+			if tc.expectedPut {
+				refs := []string{}
+				for _, ref := range update["sni_container_refs"].([]interface{}) {
+					refs = append(refs, ref.(string))
+				}
+				assert.Equal(t, tc.expected, refs)
+			}
+		})
+	}
+}
+
+func TestLbaasV2_setClientAuthenticationInvalid(t *testing.T) {
+	const tlsContainerRef = "https://barbican/v1/containers/default"
+	const caRef = "https://barbican/v1/secrets/client-ca"
+
+	testCases := []struct {
+		name            string
+		annotations     map[string]string
+		tlsContainerRef string
+	}{
+		{
+			name:        "without default tls container",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerClientAuthentication: "MANDATORY", ServiceAnnotationLoadBalancerClientCATLSContainerRef: caRef},
+		},
+		{
+			name:            "invalid mode",
+			annotations:     map[string]string{ServiceAnnotationLoadBalancerClientAuthentication: "REQUIRED", ServiceAnnotationLoadBalancerClientCATLSContainerRef: caRef},
+			tlsContainerRef: tlsContainerRef,
+		},
+		{
+			name:            "mandatory without CA",
+			annotations:     map[string]string{ServiceAnnotationLoadBalancerClientAuthentication: "mandatory"},
+			tlsContainerRef: tlsContainerRef,
+		},
+		{
+			name:            "CRL without CA",
+			annotations:     map[string]string{ServiceAnnotationLoadBalancerClientCRLContainerRef: "https://barbican/v1/secrets/client-crl"},
+			tlsContainerRef: tlsContainerRef,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: tc.annotations}}
+			svcConf := &serviceConfig{tlsContainerRef: tc.tlsContainerRef}
+			assert.Error(t, lbaas.setClientAuthentication(context.TODO(), service, svcConf))
+			assert.Empty(t, svcConf.clientAuthentication)
+		})
+	}
+
+	// The client authentication is not managed without the annotations
+	lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+	svcConf := &serviceConfig{tlsContainerRef: tlsContainerRef}
+	assert.NoError(t, lbaas.setClientAuthentication(context.TODO(), &corev1.Service{}, svcConf))
+	assert.Empty(t, svcConf.clientAuthentication)
+
+	// The client authentication can't be enforced with the ovn provider, NONE is ignored
+	lbaas = &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient(), opts: LoadBalancerOpts{LBProvider: "ovn"}, eventRecorder: record.NewFakeRecorder(10)}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default", Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerClientAuthentication:    "MANDATORY",
+		ServiceAnnotationLoadBalancerClientCATLSContainerRef: caRef,
+	}}}
+	assert.ErrorContains(t, lbaas.setClientAuthentication(context.TODO(), service, svcConf), "not supported")
+	assert.Empty(t, svcConf.clientAuthentication)
+
+	service.Annotations[ServiceAnnotationLoadBalancerClientAuthentication] = "NONE"
+	assert.NoError(t, lbaas.setClientAuthentication(context.TODO(), service, svcConf))
+	assert.Empty(t, svcConf.clientAuthentication)
+}
+
+func TestLbaasV2_listenerClientAuthentication(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fakeclient.ServiceClient()}}
+	port := corev1.ServicePort{Protocol: corev1.ProtocolTCP, Port: 443}
+	svcConf := &serviceConfig{
+		connLimit:               -1,
+		tlsContainerRef:         "https://barbican/v1/containers/default",
+		sniContainerRefs:        []string{},
+		clientAuthentication:    listeners.ClientAuthenticationMandatory,
+		clientCATLSContainerRef: "https://barbican/v1/secrets/client-ca",
+	}
+
+	createOpts := lbaas.buildListenerCreateOpt(port, svcConf, "listener")
+	assert.Equal(t, listeners.ClientAuthenticationMandatory, createOpts.ClientAuthentication)
+	assert.Equal(t, svcConf.clientCATLSContainerRef, createOpts.ClientCATLSContainerRef)
+	assert.Empty(t, createOpts.ClientCRLContainerRef)
+
+	// Not used by the non TLS listeners
+	createOpts = lbaas.buildListenerCreateOpt(corev1.ServicePort{Protocol: corev1.ProtocolUDP, Port: 53}, svcConf, "listener")
+	assert.Empty(t, createOpts.ClientAuthentication)
+
+	listener := &listeners.Listener{
+		ID:                      "listener-id",
+		Protocol:                string(listeners.ProtocolTerminatedHTTPS),
+		ProtocolPort:            443,
+		ConnLimit:               -1,
+		DefaultTlsContainerRef:  svcConf.tlsContainerRef,
+		ClientAuthentication:    string(listeners.ClientAuthenticationMandatory),
+		ClientCATLSContainerRef: svcConf.clientCATLSContainerRef,
+	}
+	_, changed := lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.False(t, changed)
+
+	// The drift of the listener is reconciled
+	listener.ClientAuthentication = string(listeners.ClientAuthenticationOptional)
+	listener.ClientCRLContainerRef = "https://barbican/v1/secrets/client-crl"
+	updateOpts, changed := lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.True(t, changed)
+	assert.Equal(t, listeners.ClientAuthenticationMandatory, *updateOpts.ClientAuthentication)
+	assert.Equal(t, "", *updateOpts.ClientCRLContainerRef)
+	assert.Nil(t, updateOpts.ClientCATLSContainerRef)
+
+	// The rotated client CA is reloaded along with the default container
+	listener.ClientAuthentication = string(listeners.ClientAuthenticationMandatory)
+	listener.ClientCRLContainerRef = ""
+	listener.Tags = []string{"kube_service_cluster_default_svc", "tls-fingerprint:old"}
+	svcConf.lbName = "kube_service_cluster_default_svc"
+	svcConf.supportLBTags = true
+	svcConf.tlsFingerprint = combineTLSFingerprints("default", "client-ca")
+	updateOpts, changed = lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.True(t, changed)
+	assert.Equal(t, svcConf.tlsContainerRef, *updateOpts.DefaultTlsContainerRef)
+	assert.Equal(t, svcConf.clientCATLSContainerRef, *updateOpts.ClientCATLSContainerRef)
+	assert.Equal(t, []string{svcConf.lbName, "tls-fingerprint:" + svcConf.tlsFingerprint}, *updateOpts.Tags)
+
+	// The listener is left untouched without the annotations
+	listener.Tags = nil
+	svcConf.supportLBTags = false
+	svcConf.clientAuthentication = ""
+	svcConf.clientCATLSContainerRef = ""
+	_, changed = lbaas.buildListenerUpdateOpts("lb-id", listener, port, svcConf)
+	assert.False(t, changed)
+
+	// But the client authentication left enforced is reported
+	recorder := record.NewFakeRecorder(1)
+	lbaas.eventRecorder = recorder
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	lbaas.checkClientAuthLeftEnforced(service, listener, svcConf)
+	assert.Contains(t, <-recorder.Events, eventLBClientAuthLeftEnforced)
+
+	listener.ClientAuthentication = string(listeners.ClientAuthenticationNone)
+	lbaas.checkClientAuthLeftEnforced(service, listener, svcConf)
+	assert.Empty(t, recorder.Events)
+}
+
+func TestCombineTLSFingerprints(t *testing.T) {
+	assert.Equal(t, "default", combineTLSFingerprints("default"))
+	assert.Equal(t, "", combineTLSFingerprints("", "client-ca"))
+
+	fingerprint := combineTLSFingerprints("default", "client-ca")
+	assert.Len(t, fingerprint, 16)
+	assert.Equal(t, fingerprint, combineTLSFingerprints("default", "client-ca"))
+	assert.NotEqual(t, fingerprint, combineTLSFingerprints("default", "rotated-client-ca"))
+	assert.NotEqual(t, fingerprint, combineTLSFingerprints("default", "client-ca", "client-crl"))
+}
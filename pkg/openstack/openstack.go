@@ -22,6 +22,7 @@ import (
 	"io"
 	"os"
 	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
@@ -37,8 +38,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/cloud-provider-openstack/pkg/client"
 	"k8s.io/cloud-provider-openstack/pkg/metrics"
@@ -59,6 +64,9 @@ const (
 // userAgentData is used to add extra information to the gophercloud user-agent
 var userAgentData []string
 
+// migrateInTreeLoadBalancers is set to migrate the load balancers created by the in-tree provider at startup
+var migrateInTreeLoadBalancers bool
+
 // supportedLBProvider map is used to define LoadBalancer providers that we support
 var supportedLBProvider = []string{"amphora", "octavia", "ovn", "f5"}
 
@@ -68,6 +76,8 @@ var supportedContainerStore = []string{"barbican", "external"}
 // AddExtraFlags is called by the main package to add component specific command line flags
 func AddExtraFlags(fs *pflag.FlagSet) {
 	fs.StringArrayVar(&userAgentData, "user-agent", nil, "Extra data to add to gophercloud user-agent. Use multiple times to add more than one component.")
+	fs.BoolVar(&migrateInTreeLoadBalancers, "migrate-in-tree-load-balancers", false, "Rename the load balancers created by the legacy in-tree OpenStack provider to the naming conventions of this provider once at startup, before the controllers start.")
+	fs.BoolVar(&reloadCloudConfig, "reload-cloud-config", false, "Reload the OpenStack credentials of the cloud config file whenever it changes, e.g. when an application credential is rotated, without restarting.")
 }
 
 type PortWithTrunkDetails struct {
@@ -88,6 +98,15 @@ type LoadBalancer struct {
 	opts          LoadBalancerOpts
 	kclient       kubernetes.Interface
 	eventRecorder record.EventRecorder
+	nodeLister    corelisters.NodeLister
+
+	// endpointSliceLister is only set when the member weights follow the EndpointSlices
+	endpointSliceLister discoverylisters.EndpointSliceLister
+	endpointSliceSynced cache.InformerSynced
+
+	// plan is only set on the copy reconciling a dry-run or paused Service, the OpenStack operations are recorded in it
+	// instead of being applied
+	plan *reconcilePlan
 }
 
 // LoadBalancerOpts have the options to talk to Neutron LBaaSV2 or Octavia
@@ -120,6 +139,41 @@ type LoadBalancerOpts struct {
 	MaxSharedLB                    int                 `gcfg:"max-shared-lb"`                      //  Number of Services in maximum can share a single load balancer. Default 2
 	ContainerStore                 string              `gcfg:"container-store"`                    // Used to specify the store of the tls-container-ref
 	ProviderRequiresSerialAPICalls bool                `gcfg:"provider-requires-serial-api-calls"` // default false, the provider supports the "bulk update" API call
+	SecurityGroupRuleDescription   string              `gcfg:"security-group-rule-description"`    // Go template used as the description of the managed security group rules
+	TLSContainerCheckInterval      util.MyDuration     `gcfg:"tls-container-check-interval"`       // default 0, the rotated Barbican certificates are not checked periodically
+	SharedLBLeaseDuration          util.MyDuration     `gcfg:"shared-lb-lease-duration"`           // default 0, the changes of the shared load balancers are only serialized within an OCCM
+	// EndpointSliceMemberUpdates updates the members of externalTrafficPolicy=Local Services on EndpointSlice changes, default false
+	EndpointSliceMemberUpdates bool `gcfg:"enable-endpointslice-member-updates"`
+	// DryRun only reports the changes the load balancer reconciliation would apply, default false
+	DryRun bool `gcfg:"dry-run"`
+	// RequireFloatingNetworkID fails the external load balancers without floating network instead of autodetecting it, default false
+	RequireFloatingNetworkID bool `gcfg:"require-floating-network-id"`
+	// ServiceLoadBalancerClass is the spec.loadBalancerClass of the Services managed by this OCCM, default empty, the
+	// Services without spec.loadBalancerClass are managed
+	ServiceLoadBalancerClass string `gcfg:"service-load-balancer-class"`
+	// ServiceMetricsLabelLimit is the number of Services having their own reconciliation metrics labels, default 100
+	ServiceMetricsLabelLimit int `gcfg:"service-metrics-label-limit"`
+	// MaxDestructiveChanges is the number of listeners and pools a reconciliation may delete or recreate without the
+	// acknowledgment annotation of the Service, default 0, not limited
+	MaxDestructiveChanges int `gcfg:"max-destructive-changes"`
+	// MemberDNSDomain is the DNS domain, e.g. a Designate zone, the node names are resolved in to get the member
+	// addresses, default empty, the node addresses are used
+	MemberDNSDomain string `gcfg:"member-dns-domain"`
+	// MemberDNSRefreshInterval is the interval the member addresses resolved in the DNS are refreshed at, default 0,
+	// they're only refreshed by the node updates
+	MemberDNSRefreshInterval util.MyDuration `gcfg:"member-dns-refresh-interval"`
+	// ExternalFloatingIP delegates the floating IPs of the external load balancers to an external controller, default
+	// false, the floating IPs are managed by the OCCM
+	ExternalFloatingIP bool `gcfg:"external-floating-ip"`
+	// SyncStatusCondition records the result of the reconciliation of the load balancers in the LoadBalancerSynced
+	// condition of the Services, default false
+	SyncStatusCondition bool `gcfg:"sync-status-condition"`
+	// AttachAllowedLoadBalancerIDs are the IDs of the load balancers whose pools the Services may attach their members
+	// to, default empty
+	AttachAllowedLoadBalancerIDs []string `gcfg:"attach-allowed-load-balancer-id"`
+	// AttachAllowedTags are the tags of the load balancers whose pools the Services may attach their members to,
+	// default empty, the Services can only attach to the load balancers of AttachAllowedLoadBalancerIDs
+	AttachAllowedTags []string `gcfg:"attach-allowed-tag"`
 	// revive:disable:var-naming
 	TlsContainerRef string `gcfg:"default-tls-container-ref"` //  reference to a tls container
 	// revive:enable:var-naming
@@ -141,12 +195,22 @@ type NetworkingOpts struct {
 	IPv6SupportDisabled bool     `gcfg:"ipv6-support-disabled"`
 	PublicNetworkName   []string `gcfg:"public-network-name"`
 	InternalNetworkName []string `gcfg:"internal-network-name"`
+	InternalSubnetID    []string `gcfg:"internal-subnet-id"`
 	AddressSortOrder    string   `gcfg:"address-sort-order"`
 }
 
 // RouterOpts is used for Neutron routes
 type RouterOpts struct {
-	RouterID string `gcfg:"router-id"`
+	// RouterIDs are the routers of the cluster networks, router-id can be set once per router.
+	RouterIDs []string `gcfg:"router-id"`
+}
+
+// InstancesOpts is used for the instances of the nodes
+type InstancesOpts struct {
+	// ShutdownGracePeriod is how long a server stays in a shutdown state before its node is reported shut down.
+	ShutdownGracePeriod util.MyDuration `gcfg:"shutdown-grace-period"`
+	// ShutdownStates are the server states reported as shut down, shutdown-state can be set once per state.
+	ShutdownStates []string `gcfg:"shutdown-state"`
 }
 
 // OpenStack is an implementation of cloud provider Interface for OpenStack.
@@ -157,12 +221,37 @@ type OpenStack struct {
 	routeOpts             RouterOpts
 	metadataOpts          metadata.Opts
 	networkingOpts        NetworkingOpts
+	instancesOpts         InstancesOpts
+	appCredOpts           ApplicationCredentialOpts
 	kclient               kubernetes.Interface
 	nodeInformer          coreinformers.NodeInformer
 	nodeInformerHasSynced func() bool
 
+	serviceInformer       coreinformers.ServiceInformer
+	endpointSliceInformer discoveryinformers.EndpointSliceInformer
+	stop                  <-chan struct{}
+
 	eventBroadcaster record.EventBroadcaster
 	eventRecorder    record.EventRecorder
+
+	// useApplicationCredential is set when OCCM authenticates with an application credential, the reloaded
+	// credentials update it
+	useApplicationCredential atomic.Bool
+
+	// clusterName is the --cluster-name of the controller manager, set by the main package
+	clusterName string
+
+	// cloudConfigFile is the --cloud-config of the controller manager, set by the main package
+	cloudConfigFile string
+	// config is the cloud config the clients are created with
+	config Config
+	// credentials swaps the credentials of the clients when the cloud config is reloaded
+	credentials *credentials
+}
+
+// SetClusterName sets the cluster name the controllers are run with
+func (os *OpenStack) SetClusterName(clusterName string) {
+	os.clusterName = clusterName
 }
 
 // Config is used to read and store information from the cloud configuration file
@@ -173,6 +262,10 @@ type Config struct {
 	Route             RouterOpts
 	Metadata          metadata.Opts
 	Networking        NetworkingOpts
+	Instances         InstancesOpts
+	APILimits         APILimitsOpts
+
+	ApplicationCredential ApplicationCredentialOpts
 }
 
 func init() {
@@ -196,9 +289,41 @@ func init() {
 func (os *OpenStack) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
 	clientset := clientBuilder.ClientOrDie("cloud-controller-manager")
 	os.kclient = clientset
+	os.stop = stop
 	os.eventBroadcaster = record.NewBroadcaster()
 	os.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: os.kclient.CoreV1().Events("")})
 	os.eventRecorder = os.eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "cloud-provider-openstack"})
+
+	// The reloaded credentials may switch to an application credential, the check then skips the other ones
+	if (os.useApplicationCredential.Load() || reloadCloudConfig) && os.appCredOpts.ExpiryCheckInterval.Duration > 0 {
+		go runApplicationCredentialExpiryCheck(os.provider, os.epOpts, os.appCredOpts, os.useApplicationCredential.Load, os.eventRecorder, stop)
+	}
+
+	if reloadCloudConfig {
+		go os.watchCloudConfig(stop)
+	}
+
+	if migrateInTreeLoadBalancers {
+		os.migrateInTreeLoadBalancers()
+	}
+}
+
+// migrateInTreeLoadBalancers migrates the load balancers created by the in-tree provider, the controllers aren't
+// started yet so that they don't reconcile the load balancers being renamed. A failed migration is only logged, the
+// load balancers keeping their in-tree name are still found by the controllers.
+func (os *OpenStack) migrateInTreeLoadBalancers() {
+	if os.clusterName == "" {
+		klog.Error("The cluster name is required to migrate the load balancers created by the in-tree provider")
+		return
+	}
+
+	lb, ok := os.LoadBalancer()
+	if !ok {
+		return
+	}
+	if err := lb.(*LbaasV2).migrateInTreeLoadBalancers(context.TODO(), os.clusterName); err != nil {
+		klog.Errorf("Failed to migrate the load balancers created by the in-tree provider: %v", err)
+	}
 }
 
 // ReadConfig reads values from the cloud.conf
@@ -227,6 +352,10 @@ func ReadConfig(config io.Reader) (Config, error) {
 	cfg.LoadBalancer.ContainerStore = "barbican"
 	cfg.LoadBalancer.MaxSharedLB = 2
 	cfg.LoadBalancer.ProviderRequiresSerialAPICalls = false
+	cfg.LoadBalancer.SecurityGroupRuleDescription = defaultSecurityGroupRuleDescription
+	cfg.LoadBalancer.ServiceMetricsLabelLimit = metrics.DefaultServiceLabelLimit
+	cfg.ApplicationCredential.ExpiryCheckInterval = util.MyDuration{Duration: time.Hour}
+	cfg.ApplicationCredential.ExpiryWarningDays = 14
 
 	err := gcfg.FatalOnly(gcfg.ReadInto(&cfg, config))
 	if err != nil {
@@ -277,6 +406,10 @@ func (c *caller) call(f func()) {
 
 // check opts for OpenStack
 func checkOpenStackOpts(openstackOpts *OpenStack) error {
+	if _, err := parseSecurityGroupRuleDescription(openstackOpts.lbOpts.SecurityGroupRuleDescription); err != nil {
+		return fmt.Errorf("invalid security-group-rule-description: %v", err)
+	}
+
 	return metadata.CheckMetadataSearchOrder(openstackOpts.metadataOpts.SearchOrder)
 }
 
@@ -291,9 +424,15 @@ func NewOpenStack(cfg Config) (*OpenStack, error) {
 		cfg.Metadata.RequestTimeout.Duration = time.Duration(defaultTimeOut)
 	}
 	provider.HTTPClient.Timeout = cfg.Metadata.RequestTimeout.Duration
+	// The time waited for the API limits counts in the request timeout
+	provider.HTTPClient.Transport = newThrottleTransport(provider.HTTPClient.Transport, cfg.APILimits)
+	// Count the load balancer API calls of each Service reconciliation
+	provider.HTTPClient.Transport = metrics.NewServiceReconcileTransport(provider.HTTPClient.Transport)
+	metrics.SetServiceLabelLimit(cfg.LoadBalancer.ServiceMetricsLabelLimit)
 
 	os := OpenStack{
 		provider: provider,
+		config:   cfg,
 		epOpts: &gophercloud.EndpointOpts{
 			Region:       cfg.Global.Region,
 			Availability: cfg.Global.EndpointType,
@@ -302,7 +441,10 @@ func NewOpenStack(cfg Config) (*OpenStack, error) {
 		routeOpts:      cfg.Route,
 		metadataOpts:   cfg.Metadata,
 		networkingOpts: cfg.Networking,
+		instancesOpts:  cfg.Instances,
+		appCredOpts:    cfg.ApplicationCredential,
 	}
+	os.useApplicationCredential.Store(usesApplicationCredential(cfg.Global))
 
 	// ini file doesn't support maps so we are reusing top level sub sections
 	// and copy the resulting map to corresponding loadbalancer section
@@ -313,6 +455,10 @@ func NewOpenStack(cfg Config) (*OpenStack, error) {
 		return nil, err
 	}
 
+	if reloadCloudConfig {
+		os.credentials = newCredentials(provider)
+	}
+
 	return &os, nil
 }
 
@@ -372,7 +518,18 @@ func (os *OpenStack) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
 
 	klog.V(1).Info("Claiming to support LoadBalancer")
 
-	return &LbaasV2{LoadBalancer{secret, network, lb, os.lbOpts, os.kclient, os.eventRecorder}}, true
+	var nodeLister corelisters.NodeLister
+	if os.nodeInformer != nil {
+		nodeLister = os.nodeInformer.Lister()
+	}
+
+	lbaas := &LbaasV2{LoadBalancer{secret, network, lb, os.lbOpts, os.kclient, os.eventRecorder, nodeLister, nil, nil, nil}}
+	if os.endpointSliceInformer != nil {
+		lbaas.endpointSliceLister = os.endpointSliceInformer.Lister()
+		lbaas.endpointSliceSynced = os.endpointSliceInformer.Informer().HasSynced
+	}
+
+	return lbaas, true
 }
 
 // Zones indicates that we support zones
@@ -424,4 +581,46 @@ func (os *OpenStack) SetInformers(informerFactory informers.SharedInformerFactor
 	klog.V(1).Infof("Setting up informers for Cloud")
 	os.nodeInformer = informerFactory.Core().V1().Nodes()
 	os.nodeInformerHasSynced = os.nodeInformer.Informer().HasSynced
+
+	if !os.lbOpts.Enabled {
+		return
+	}
+
+	endpointSliceMemberUpdates := os.lbOpts.EndpointSliceMemberUpdates
+	if endpointSliceMemberUpdates && os.lbOpts.ProviderRequiresSerialAPICalls {
+		klog.Warningf("enable-endpointslice-member-updates is ignored because provider-requires-serial-api-calls is set")
+		endpointSliceMemberUpdates = false
+	}
+	tlsContainerCheckInterval := os.lbOpts.TLSContainerCheckInterval.Duration
+	memberDNSRefreshInterval := os.lbOpts.MemberDNSRefreshInterval.Duration
+	serviceLoadBalancerClass := os.lbOpts.ServiceLoadBalancerClass
+	if !endpointSliceMemberUpdates && tlsContainerCheckInterval <= 0 && memberDNSRefreshInterval <= 0 && serviceLoadBalancerClass == "" {
+		return
+	}
+
+	os.serviceInformer = informerFactory.Core().V1().Services()
+	if endpointSliceMemberUpdates {
+		os.endpointSliceInformer = informerFactory.Discovery().V1().EndpointSlices()
+	}
+
+	lb, ok := os.LoadBalancer()
+	if !ok {
+		klog.Warningf("Failed to set up the load balancer controllers: load balancer support is not available")
+		return
+	}
+	lbaas := lb.(*LbaasV2)
+	if endpointSliceMemberUpdates {
+		controller := newEndpointSliceController(lbaas, os.clusterName, os.serviceInformer, os.endpointSliceInformer)
+		go controller.Run(os.stop)
+	}
+	if serviceLoadBalancerClass != "" {
+		controller := newLoadBalancerClassController(lbaas, os.clusterName, os.serviceInformer, os.nodeInformer)
+		go controller.Run(os.stop)
+	}
+	if tlsContainerCheckInterval > 0 {
+		go lbaas.runTLSCertificateCheck(os.serviceInformer.Lister(), os.serviceInformer.Informer().HasSynced, tlsContainerCheckInterval, os.stop)
+	}
+	if memberDNSRefreshInterval > 0 {
+		go lbaas.runMemberDNSRefresh(os.clusterName, os.serviceInformer.Lister(), os.serviceInformer.Informer().HasSynced, memberDNSRefreshInterval, os.stop)
+	}
 }
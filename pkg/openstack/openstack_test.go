@@ -101,6 +101,12 @@ func TestReadConfig(t *testing.T) {
  monitor-max-retries-down = 3
  [Metadata]
  search-order = configDrive, metadataService
+ [Route]
+ router-id = router-1
+ router-id = router-2
+ [APILimits]
+ qps = 2.5
+ max-concurrent-requests = 10
  `))
 	if err != nil {
 		t.Fatalf("Should succeed when a valid config is provided: %v", err)
@@ -148,6 +154,12 @@ func TestReadConfig(t *testing.T) {
 	if cfg.Metadata.SearchOrder != "configDrive, metadataService" {
 		t.Errorf("incorrect md.search-order: %v", cfg.Metadata.SearchOrder)
 	}
+	if !reflect.DeepEqual(cfg.Route.RouterIDs, []string{"router-1", "router-2"}) {
+		t.Errorf("incorrect route.router-id: %v", cfg.Route.RouterIDs)
+	}
+	if cfg.APILimits.QPS != 2.5 || cfg.APILimits.MaxConcurrentRequests != 10 {
+		t.Errorf("incorrect api limits: %+v", cfg.APILimits)
+	}
 }
 
 func TestReadClouds(t *testing.T) {
@@ -1159,3 +1171,102 @@ func testConfigFromEnv(t *testing.T, cfg *Config) {
 		t.Skip("No config found in environment")
 	}
 }
+
+func TestNodeAddressesDualStackInternalSubnet(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "10.0.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(6),
+					"addr":            "fd00::32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "50.56.176.36",
+					"OS-EXT-IPS:type": "floating",
+				},
+			},
+			"storage": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "192.168.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+			},
+		},
+	}
+
+	ports := []PortWithTrunkDetails{
+		{
+			Port: neutronports.Port{
+				Status: "ACTIVE",
+				FixedIPs: []neutronports.IP{
+					{SubnetID: "private-v4", IPAddress: "10.0.0.32"},
+					{SubnetID: "private-v6", IPAddress: "fd00::32"},
+				},
+			},
+		},
+		{
+			Port: neutronports.Port{
+				Status: "ACTIVE",
+				FixedIPs: []neutronports.IP{
+					{SubnetID: "storage-v4", IPAddress: "192.168.0.32"},
+				},
+			},
+		},
+	}
+
+	// Both address families of the ports are reported
+	addrs, err := nodeAddresses(context.TODO(), &srv, ports, nil, NetworkingOpts{})
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+		{Type: v1.NodeInternalIP, Address: "fd00::32"},
+		{Type: v1.NodeInternalIP, Address: "192.168.0.32"},
+		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+	}
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+
+	// The fixed IPs of the other subnets are ignored, the floating IPs are kept
+	addrs, err = nodeAddresses(context.TODO(), &srv, ports, nil, NetworkingOpts{InternalSubnetID: []string{"private-v4", "private-v6"}})
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+	want = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+		{Type: v1.NodeInternalIP, Address: "fd00::32"},
+		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+	}
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+
+	// The fixed IPs of the public networks are still external addresses
+	addrs, err = nodeAddresses(context.TODO(), &srv, ports, nil, NetworkingOpts{
+		InternalSubnetID:  []string{"private-v4", "private-v6"},
+		PublicNetworkName: []string{"storage"},
+	})
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+	want = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+		{Type: v1.NodeInternalIP, Address: "fd00::32"},
+		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+		{Type: v1.NodeExternalIP, Address: "192.168.0.32"},
+	}
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+}
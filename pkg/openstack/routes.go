@@ -18,7 +18,9 @@ package openstack
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"slices"
 	"sync"
 
 	v1 "k8s.io/api/core/v1"
@@ -41,8 +43,12 @@ import (
 type Routes struct {
 	network *gophercloud.ServiceClient
 	os      *OpenStack
-	// router's private network IDs
+	// IDs of the routers managed
+	routerIDs []string
+	// routers' private network IDs
 	networkIDs []string
+	// private network IDs of each router
+	routerNetworkIDs map[string][]string
 	// whether Neutron supports "extraroute-atomic" extension
 	atomicRoutes bool
 	// whether Neutron supports "allowed-address-pairs" extension
@@ -56,13 +62,20 @@ var _ cloudprovider.Routes = &Routes{}
 
 // NewRoutes creates a new instance of Routes
 func NewRoutes(os *OpenStack, network *gophercloud.ServiceClient, atomicRoutes bool, allowedAddressPairs bool) (cloudprovider.Routes, error) {
-	if os.routeOpts.RouterID == "" {
+	var routerIDs []string
+	for _, routerID := range os.routeOpts.RouterIDs {
+		if routerID != "" && !slices.Contains(routerIDs, routerID) {
+			routerIDs = append(routerIDs, routerID)
+		}
+	}
+	if len(routerIDs) == 0 {
 		return nil, errors.ErrNoRouterID
 	}
 
 	return &Routes{
 		network:             network,
 		os:                  os,
+		routerIDs:           routerIDs,
 		atomicRoutes:        atomicRoutes,
 		allowedAddressPairs: allowedAddressPairs,
 	}, nil
@@ -81,31 +94,85 @@ func (r *Routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpr
 		return nil, err
 	}
 
-	mc := metrics.NewMetricContext("router", "get")
-	router, err := routers.Get(ctx, r.network, r.os.routeOpts.RouterID).Extract()
-	if mc.ObserveRequest(err) != nil {
-		return nil, err
-	}
+	var routes []*cloudprovider.Route
+	var networkIDs []string
+	routerNetworkIDs := make(map[string][]string, len(r.routerIDs))
+	for _, routerID := range r.routerIDs {
+		mc := metrics.NewMetricContext("router", "get")
+		router, err := routers.Get(ctx, r.network, routerID).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return nil, err
+		}
 
-	routes := make([]*cloudprovider.Route, 0, len(router.Routes))
-	for _, item := range router.Routes {
-		nodeName, foundNode := getNodeNameByAddr(item.NextHop, nodes)
-		route := cloudprovider.Route{
-			Name:            item.DestinationCIDR,
-			TargetNode:      nodeName, //contains the nexthop address if node name was not found
-			Blackhole:       !foundNode,
-			DestinationCIDR: item.DestinationCIDR,
+		for _, item := range router.Routes {
+			nodeName, foundNode := getNodeNameByAddr(item.NextHop, nodes)
+			route := cloudprovider.Route{
+				Name:            item.DestinationCIDR,
+				TargetNode:      nodeName, //contains the nexthop address if node name was not found
+				Blackhole:       !foundNode,
+				DestinationCIDR: item.DestinationCIDR,
+			}
+			routes = append(routes, &route)
+		}
+
+		// detect router's private network ID for further VM ports filtering
+		routerNetworkIDs[routerID], err = getRouterNetworkIDs(ctx, r.network, routerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, networkID := range routerNetworkIDs[routerID] {
+			if !slices.Contains(networkIDs, networkID) {
+				networkIDs = append(networkIDs, networkID)
+			}
 		}
-		routes = append(routes, &route)
 	}
+	r.networkIDs = networkIDs
+	r.routerNetworkIDs = routerNetworkIDs
 
-	// detect router's private network ID for further VM ports filtering
-	r.networkIDs, err = getRouterNetworkIDs(ctx, r.network, r.os.routeOpts.RouterID)
+	return routes, nil
+}
+
+// getRouterIDForAddr returns the router connected to the network of the port with the given address.
+func (r *Routes) getRouterIDForAddr(ctx context.Context, addr string) (string, error) {
+	if len(r.routerIDs) == 1 {
+		return r.routerIDs[0], nil
+	}
+
+	port, err := r.getPortByIP(ctx, addr)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to find the port of %s: %w", addr, err)
 	}
 
-	return routes, nil
+	for _, routerID := range r.routerIDs {
+		if slices.Contains(r.routerNetworkIDs[routerID], port.NetworkID) {
+			return routerID, nil
+		}
+	}
+
+	return "", fmt.Errorf("none of the routers %v is connected to the network %s of %s", r.routerIDs, port.NetworkID, addr)
+}
+
+// getRouterIDWithRoute returns the router having the given route, or an empty string if none has it.
+func (r *Routes) getRouterIDWithRoute(ctx context.Context, destinationCIDR string, nextHop string) (string, error) {
+	if len(r.routerIDs) == 1 {
+		return r.routerIDs[0], nil
+	}
+
+	for _, routerID := range r.routerIDs {
+		mc := metrics.NewMetricContext("router", "get")
+		router, err := routers.Get(ctx, r.network, routerID).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return "", err
+		}
+
+		for _, item := range router.Routes {
+			if item.DestinationCIDR == destinationCIDR && item.NextHop == nextHop {
+				return routerID, nil
+			}
+		}
+	}
+
+	return "", nil
 }
 
 func getRouterNetworkIDs(ctx context.Context, network *gophercloud.ServiceClient, routerID string) ([]string, error) {
@@ -124,6 +191,10 @@ func getRouterNetworkIDs(ctx context.Context, network *gophercloud.ServiceClient
 
 	var networkIDs []string
 	for _, port := range ports {
+		// The external network is shared by the routers, the nodes aren't connected to it.
+		if port.DeviceOwner == "network:router_gateway" {
+			continue
+		}
 		if port.NetworkID != "" {
 			networkIDs = append(networkIDs, port.NetworkID)
 		}
@@ -289,13 +360,18 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 
 	klog.V(4).Infof("Using nexthop %v for node %v", addr, route.TargetNode)
 
+	routerID, err := r.getRouterIDForAddr(ctx, addr)
+	if err != nil {
+		return err
+	}
+
 	if !r.atomicRoutes {
 		// classical logic
 		r.Lock()
 		defer r.Unlock()
 
 		mc := metrics.NewMetricContext("router", "get")
-		router, err := routers.Get(ctx, r.network, r.os.routeOpts.RouterID).Extract()
+		router, err := routers.Get(ctx, r.network, routerID).Extract()
 		if mc.ObserveRequest(err) != nil {
 			return err
 		}
@@ -326,7 +402,7 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 			DestinationCIDR: route.DestinationCIDR,
 			NextHop:         addr,
 		}}
-		unwind, err := addRoute(ctx, r.network, r.os.routeOpts.RouterID, route)
+		unwind, err := addRoute(ctx, r.network, routerID, route)
 		if err != nil {
 			return err
 		}
@@ -398,13 +474,26 @@ func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *clo
 		}
 	}
 
+	nextHop := addr
+	if route.Blackhole {
+		nextHop = string(route.TargetNode)
+	}
+	routerID, err := r.getRouterIDWithRoute(ctx, route.DestinationCIDR, nextHop)
+	if err != nil {
+		return err
+	}
+	if routerID == "" {
+		klog.V(4).Infof("Skipping non-existent route: %v", route)
+		return nil
+	}
+
 	if !r.atomicRoutes {
 		// classical logic
 		r.Lock()
 		defer r.Unlock()
 
 		mc := metrics.NewMetricContext("router", "get")
-		router, err := routers.Get(ctx, r.network, r.os.routeOpts.RouterID).Extract()
+		router, err := routers.Get(ctx, r.network, routerID).Extract()
 		if mc.ObserveRequest(err) != nil {
 			return err
 		}
@@ -444,7 +533,7 @@ func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *clo
 			DestinationCIDR: route.DestinationCIDR,
 			NextHop:         addr,
 		}}
-		unwind, err := removeRoute(ctx, r.network, r.os.routeOpts.RouterID, route)
+		unwind, err := removeRoute(ctx, r.network, routerID, route)
 		// If this was a blackhole route we are done, there are no ports to update
 		if err != nil || blackhole {
 			return err
@@ -18,11 +18,16 @@ package openstack
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"net/http"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions/layer3/routers"
+	th "github.com/gophercloud/gophercloud/v2/testhelper"
+	fakeclient "github.com/gophercloud/gophercloud/v2/testhelper/client"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -50,7 +55,7 @@ func TestRoutes(t *testing.T) {
 	servername := vms[0].Name
 
 	// Pick the first router and server to try a test with
-	os.routeOpts.RouterID = getRouters(os)[0].ID
+	os.routeOpts.RouterIDs = []string{getRouters(os)[0].ID}
 
 	r, ok := os.Routes()
 	if !ok {
@@ -161,6 +166,45 @@ func TestGetAddrByNodeName(t *testing.T) {
 	}
 }
 
+func TestGetRouterIDForAddr(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodGet)
+		w.Header().Add("Content-Type", "application/json")
+		if r.URL.Query().Get("network_id") != "network-2" {
+			fmt.Fprint(w, `{"ports": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"ports": [{"id": "port-id", "network_id": "network-2", "fixed_ips": [{"ip_address": "10.0.2.10"}]}]}`)
+	})
+
+	r := &Routes{
+		network:    fakeclient.ServiceClient(),
+		routerIDs:  []string{"router-1", "router-2"},
+		networkIDs: []string{"network-1", "network-2"},
+		routerNetworkIDs: map[string][]string{
+			"router-1": {"network-1"},
+			"router-2": {"network-2"},
+		},
+	}
+
+	routerID, err := r.getRouterIDForAddr(context.TODO(), "10.0.2.10")
+	assert.NoError(t, err)
+	assert.Equal(t, "router-2", routerID)
+
+	r.routerNetworkIDs["router-2"] = nil
+	_, err = r.getRouterIDForAddr(context.TODO(), "10.0.2.10")
+	assert.Error(t, err)
+
+	// A single router is used without looking up the port
+	r.routerIDs = []string{"router-1"}
+	routerID, err = r.getRouterIDForAddr(context.TODO(), "10.0.3.10")
+	assert.NoError(t, err)
+	assert.Equal(t, "router-1", routerID)
+}
+
 func getServers(os *OpenStack) []servers.Server {
 	c, err := client.NewComputeV2(os.provider, os.epOpts)
 	if err != nil {
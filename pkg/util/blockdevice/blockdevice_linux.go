@@ -18,16 +18,25 @@ package blockdevice
 
 import (
 	"fmt"
-	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 
 	"k8s.io/klog/v2"
 )
 
+// sysBlockPath is the sysfs directory of the block devices, overridden by the tests.
+var sysBlockPath = "/sys/block"
+
+// multipathd runs a multipathd command, overridden by the tests.
+var multipathd = func(args ...string) ([]byte, error) {
+	return exec.Command("multipathd", args...).CombinedOutput()
+}
+
 // findBlockDeviceRescanPath Find the underlaying disk for a linked path such as /dev/disk/by-path/XXXX or /dev/mapper/XXXX
 // will return /sys/devices/pci0000:00/0000:00:15.0/0000:03:00.0/host0/target0:0:1/0:0:1:0/rescan
 func findBlockDeviceRescanPath(path string) (string, error) {
@@ -39,7 +48,7 @@ func findBlockDeviceRescanPath(path string) (string, error) {
 	// return just the last part
 	parts := strings.Split(devicePath, "/")
 	if len(parts) == 3 && strings.HasPrefix(parts[1], "dev") {
-		return filepath.EvalSymlinks(filepath.Join("/sys/block", parts[2], "device", "rescan"))
+		return filepath.EvalSymlinks(filepath.Join(sysBlockPath, parts[2], "device", "rescan"))
 	}
 	return "", fmt.Errorf("illegal path for device %s", devicePath)
 }
@@ -62,11 +71,12 @@ func GetBlockDeviceSize(path string) (int64, error) {
 		return 0, err
 	}
 	defer fd.Close()
-	pos, err := fd.Seek(0, io.SeekEnd)
-	if err != nil {
-		return 0, fmt.Errorf("error seeking to end of %s: %v", path, err)
+
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd.Fd(), unix.BLKGETSIZE64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, fmt.Errorf("error getting the size of %s: %v", path, errno)
 	}
-	return pos, nil
+	return int64(size), nil
 }
 
 func checkBlockDeviceSize(devicePath string, deviceMountPath string, newSize int64) error {
@@ -95,6 +105,71 @@ func triggerRescan(blockDeviceRescanPath string) error {
 	return nil
 }
 
+// findMultipathDevice returns the name of the multipath map of a device mapper device and the devices of its paths,
+// an empty name when the device isn't a multipath map.
+func findMultipathDevice(path string) (string, []string, error) {
+	devicePath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, err
+	}
+	dev := filepath.Base(devicePath)
+	if !strings.HasPrefix(dev, "dm-") {
+		return "", nil, nil
+	}
+
+	uuid, err := os.ReadFile(filepath.Join(sysBlockPath, dev, "dm", "uuid"))
+	if err != nil {
+		return "", nil, err
+	}
+	// The UUID of the maps created by multipathd starts with "mpath-"
+	if !strings.HasPrefix(strings.TrimSpace(string(uuid)), "mpath-") {
+		return "", nil, nil
+	}
+
+	name, err := os.ReadFile(filepath.Join(sysBlockPath, dev, "dm", "name"))
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(sysBlockPath, dev, "slaves"))
+	if err != nil {
+		return "", nil, err
+	}
+	slaves := make([]string, 0, len(entries))
+	for _, e := range entries {
+		slaves = append(slaves, e.Name())
+	}
+
+	return strings.TrimSpace(string(name)), slaves, nil
+}
+
+// IsMultipathDevice checks whether the device on the path is a multipath map
+func IsMultipathDevice(path string) bool {
+	name, _, err := findMultipathDevice(path)
+	if err != nil {
+		klog.V(4).Infof("Failed to detect whether %q is a multipath device: %v", path, err)
+		return false
+	}
+	return name != ""
+}
+
+// rescanMultipathDevice rescans the devices of all the paths of the multipath map, then resizes the map to their new
+// size.
+func rescanMultipathDevice(mapName string, slaves []string) error {
+	for _, slave := range slaves {
+		if err := triggerRescan(filepath.Join(sysBlockPath, slave, "device", "rescan")); err != nil {
+			return fmt.Errorf("failed to rescan path %s of multipath device %s: %v", slave, mapName, err)
+		}
+	}
+
+	klog.V(4).Infof("Resizing %q multipath device", mapName)
+	out, err := multipathd("resize", "map", mapName)
+	// multipathd may report the failure on its output only
+	if err != nil || strings.TrimSpace(string(out)) == "fail" {
+		return fmt.Errorf("failed to resize multipath device %s: %v: %s", mapName, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func RescanBlockDeviceGeometry(devicePath string, deviceMountPath string, newSize int64) error {
 	if newSize == 0 {
 		klog.Error("newSize is empty, skipping the block device rescan")
@@ -107,6 +182,19 @@ func RescanBlockDeviceGeometry(devicePath string, deviceMountPath string, newSiz
 		return nil
 	}
 
+	// the multipath maps have no rescan path, their paths are rescanned instead
+	mapName, slaves, err := findMultipathDevice(devicePath)
+	if err != nil {
+		klog.Errorf("Error detecting whether %q is a multipath device: %v", devicePath, err)
+	}
+	if mapName != "" {
+		klog.V(3).Infof("Rescanning the paths %v of %q multipath device", slaves, mapName)
+		if err := rescanMultipathDevice(mapName, slaves); err != nil {
+			return err
+		}
+		return checkBlockDeviceSize(devicePath, deviceMountPath, newSize)
+	}
+
 	// don't fail if resolving doesn't work
 	blockDeviceRescanPath, err := findBlockDeviceRescanPath(devicePath)
 	if err != nil {
@@ -138,3 +226,9 @@ func RescanDevice(devicePath string) error {
 	}
 	return nil
 }
+
+// UsesVirtioBlk checks whether the disks of the instance are attached with virtio-blk, which names them vdX.
+func UsesVirtioBlk() bool {
+	disks, err := filepath.Glob(filepath.Join(sysBlockPath, "vd*"))
+	return err == nil && len(disks) > 0
+}
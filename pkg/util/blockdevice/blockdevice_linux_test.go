@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdevice
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSysBlock creates the sysfs entries of a multipath map dm-0 with the paths sda and sdb, and of a LVM volume dm-1.
+func fakeSysBlock(t *testing.T) (string, string) {
+	sys := t.TempDir()
+	devices := t.TempDir()
+
+	files := map[string]string{
+		"dm-0/dm/uuid":      "mpath-3600a098038303634\n",
+		"dm-0/dm/name":      "mpatha\n",
+		"dm-0/slaves/sda":   "",
+		"dm-0/slaves/sdb":   "",
+		"dm-1/dm/uuid":      "LVM-k2lYg0\n",
+		"dm-1/dm/name":      "vg-lv\n",
+		"sda/device/rescan": "",
+		"sdb/device/rescan": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(sys, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	for _, name := range []string{"dm-0", "dm-1", "sdc"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(devices, name), nil, 0644))
+	}
+
+	oldSysBlockPath := sysBlockPath
+	sysBlockPath = sys
+	t.Cleanup(func() { sysBlockPath = oldSysBlockPath })
+
+	return sys, devices
+}
+
+func TestFindMultipathDevice(t *testing.T) {
+	_, devices := fakeSysBlock(t)
+
+	name, slaves, err := findMultipathDevice(filepath.Join(devices, "dm-0"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mpatha", name)
+	assert.Equal(t, []string{"sda", "sdb"}, slaves)
+	assert.True(t, IsMultipathDevice(filepath.Join(devices, "dm-0")))
+
+	name, _, err = findMultipathDevice(filepath.Join(devices, "dm-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", name)
+
+	name, _, err = findMultipathDevice(filepath.Join(devices, "sdc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", name)
+	assert.False(t, IsMultipathDevice(filepath.Join(devices, "sdc")))
+}
+
+func TestRescanMultipathDevice(t *testing.T) {
+	sys, _ := fakeSysBlock(t)
+
+	var calls [][]string
+	output := "ok\n"
+	oldMultipathd := multipathd
+	multipathd = func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return []byte(output), nil
+	}
+	t.Cleanup(func() { multipathd = oldMultipathd })
+
+	assert.NoError(t, rescanMultipathDevice("mpatha", []string{"sda", "sdb"}))
+	assert.Equal(t, [][]string{{"resize", "map", "mpatha"}}, calls)
+	for _, slave := range []string{"sda", "sdb"} {
+		content, err := os.ReadFile(filepath.Join(sys, slave, "device", "rescan"))
+		assert.NoError(t, err)
+		assert.Equal(t, "1", string(content))
+	}
+
+	output = "fail\n"
+	assert.ErrorContains(t, rescanMultipathDevice("mpatha", []string{"sda"}), "failed to resize multipath device mpatha")
+
+	assert.ErrorContains(t, rescanMultipathDevice("mpatha", []string{"sdz"}), "failed to rescan path sdz")
+}
+
+func TestUsesVirtioBlk(t *testing.T) {
+	sys, _ := fakeSysBlock(t)
+	assert.False(t, UsesVirtioBlk())
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(sys, "vda"), 0755))
+	assert.True(t, UsesVirtioBlk())
+}
@@ -31,6 +31,10 @@ func GetBlockDeviceSize(path string) (int64, error) {
 	return -1, errors.New("GetBlockDeviceSize is not implemented for this OS")
 }
 
+func IsMultipathDevice(path string) bool {
+	return false
+}
+
 func RescanBlockDeviceGeometry(devicePath string, deviceMountPath string, newSize int64) error {
 	return errors.New("RescanBlockDeviceGeometry is not implemented for this OS")
 }
@@ -38,3 +42,7 @@ func RescanBlockDeviceGeometry(devicePath string, deviceMountPath string, newSiz
 func RescanDevice(devicePath string) error {
 	return errors.New("RescanDevice is not implemented for this OS")
 }
+
+func UsesVirtioBlk() bool {
+	return false
+}
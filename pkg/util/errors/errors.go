@@ -61,3 +61,7 @@ func IsInvalidError(err error) bool {
 func IsConflictError(err error) bool {
 	return gophercloud.ResponseCodeIs(err, http.StatusConflict)
 }
+
+func IsForbiddenError(err error) bool {
+	return gophercloud.ResponseCodeIs(err, http.StatusForbidden)
+}
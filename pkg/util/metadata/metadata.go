@@ -40,6 +40,8 @@ const (
 	// https://docs.openstack.org/nova/latest/user/metadata-service.html
 	defaultMetadataVersion = "latest"
 	metadataURLTemplate    = "http://169.254.169.254/openstack/%s/meta_data.json"
+	// instanceTypeURL is the EC2 compatible metadata of the flavor name, which the OpenStack metadata doesn't provide.
+	instanceTypeURL = "http://169.254.169.254/latest/meta-data/instance-type"
 
 	// MetadataID is used as an identifier on the metadata search order configuration.
 	MetadataID = "metadataService"
@@ -49,6 +51,8 @@ const (
 	//https://docs.openstack.org/nova/latest/user/config-drive.html
 	configDriveLabel        = "config-2"
 	configDrivePathTemplate = "openstack/%s/meta_data.json"
+	// configDriveEC2Path is the EC2 compatible metadata on the config drive, it provides the flavor name.
+	configDriveEC2Path = "ec2/latest/meta-data.json"
 
 	// ConfigDriveID is used as an identifier on the metadata search order configuration.
 	ConfigDriveID = "configDrive"
@@ -88,8 +92,11 @@ type DeviceMetadata struct {
 type Metadata struct {
 	UUID             string           `json:"uuid"`
 	Name             string           `json:"name"`
+	Hostname         string           `json:"hostname"`
 	AvailabilityZone string           `json:"availability_zone"`
 	Devices          []DeviceMetadata `json:"devices,omitempty"`
+	// InstanceType is the flavor name, it is read from the EC2 compatible metadata.
+	InstanceType string `json:"-"`
 	// .. and other fields we don't care about.  Expand as necessary.
 }
 
@@ -97,10 +104,11 @@ type metadataService struct {
 	searchOrder string
 }
 
-// IMetadata implements GetInstanceID & GetAvailabilityZone
+// IMetadata implements GetInstanceID, GetAvailabilityZone & GetInstanceType
 type IMetadata interface {
 	GetInstanceID() (string, error)
 	GetAvailabilityZone() (string, error)
+	GetInstanceType() (string, error)
 }
 
 // GetMetadataProvider retrieves instance of IMetadata
@@ -189,7 +197,34 @@ func getFromConfigDrive(metadataVersion string) (*Metadata, error) {
 	}
 	defer f.Close()
 
-	return parseMetadata(f)
+	md, err := parseMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+	md.InstanceType = readConfigDriveInstanceType(mntdir)
+
+	return md, nil
+}
+
+// readConfigDriveInstanceType returns the flavor name from the EC2 compatible metadata of the config drive mounted on
+// dir, or an empty string if it is not available.
+func readConfigDriveInstanceType(dir string) string {
+	f, err := os.Open(filepath.Join(dir, configDriveEC2Path))
+	if err != nil {
+		klog.V(4).Infof("Unable to read %s on config drive: %v", configDriveEC2Path, err)
+		return ""
+	}
+	defer f.Close()
+
+	var ec2 struct {
+		InstanceType string `json:"instance-type"`
+	}
+	if err := json.NewDecoder(f).Decode(&ec2); err != nil {
+		klog.V(4).Infof("Unable to parse %s on config drive: %v", configDriveEC2Path, err)
+		return ""
+	}
+
+	return ec2.InstanceType
 }
 
 func noProxyHTTPClient() *http.Client {
@@ -216,6 +251,26 @@ func getFromMetadataService(metadataVersion string) (*Metadata, error) {
 	return parseMetadata(resp.Body)
 }
 
+// getInstanceTypeFromMetadataService returns the flavor name from the EC2 compatible metadata service.
+func getInstanceTypeFromMetadataService(url string) (string, error) {
+	klog.V(4).Infof("Attempting to fetch the instance type from %s, ignoring proxy settings", url)
+	resp, err := noProxyHTTPClient().Get(url)
+	if err != nil {
+		return "", fmt.Errorf("error fetching %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code when reading the instance type from %s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", url, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
 // GetDevicePath retrieves device path from metadata service
 func GetDevicePath(volumeID string) (string, error) {
 	// Nova Hyper-V hosts cannot override disk SCSI IDs. In order to locate
@@ -312,6 +367,21 @@ func (m *metadataService) GetAvailabilityZone() (string, error) {
 	return util.SanitizeLabel(md.AvailabilityZone), nil
 }
 
+// GetInstanceType returns the flavor name of the node. The metadata service is queried when the flavor name isn't
+// available on the config drive.
+func (m *metadataService) GetInstanceType() (string, error) {
+	md, err := Get(m.searchOrder)
+	if err != nil {
+		return "", err
+	}
+	if md.InstanceType == "" && strings.Contains(m.searchOrder, MetadataID) {
+		if md.InstanceType, err = getInstanceTypeFromMetadataService(instanceTypeURL); err != nil {
+			return "", err
+		}
+	}
+	return md.InstanceType, nil
+}
+
 func CheckMetadataSearchOrder(order string) error {
 	if order == "" {
 		return errors.New("invalid value in section [Metadata] with key `search-order`. Value cannot be empty")
@@ -51,3 +51,24 @@ func (_m *MetadataMock) GetInstanceID() (string, error) {
 
 	return r0, r1
 }
+
+// GetInstanceType provides a mock function with given fields:
+func (_m *MetadataMock) GetInstanceType() (string, error) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -81,6 +82,10 @@ func TestParseMetadata(t *testing.T) {
 		t.Errorf("incorrect name: %s", md.Name)
 	}
 
+	if md.Hostname != "test.novalocal" {
+		t.Errorf("incorrect hostname: %s", md.Hostname)
+	}
+
 	if md.UUID != "83679162-1378-4288-a2d4-70e13ec132aa" {
 		t.Errorf("incorrect uuid: %s", md.UUID)
 	}
@@ -152,6 +157,24 @@ func TestCheckMetaDataOpts(t *testing.T) {
 	}
 }
 
+func TestReadConfigDriveInstanceType(t *testing.T) {
+	dir := t.TempDir()
+	if instanceType := readConfigDriveInstanceType(dir); instanceType != "" {
+		t.Errorf("expecting no instance type without EC2 metadata, got %s", instanceType)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "ec2", "latest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"instance-id": "i-00000001", "instance-type": "m1.small", "placement": {"availability-zone": "nova"}}`
+	if err := os.WriteFile(filepath.Join(dir, configDriveEC2Path), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if instanceType := readConfigDriveInstanceType(dir); instanceType != "m1.small" {
+		t.Errorf("incorrect instance type: %s", instanceType)
+	}
+}
+
 func TestGetFromMetadataService(t *testing.T) {
 	t.Run("ignores HTTP_PROXY", func(t *testing.T) {
 		// Here I spin up an HTTP server, set it as HTTP_PROXY, and
@@ -175,3 +198,26 @@ func TestGetFromMetadataService(t *testing.T) {
 		_, _ = getFromMetadataService("")
 	})
 }
+
+func TestGetInstanceTypeFromMetadataService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest/meta-data/instance-type" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "m1.large\n")
+	}))
+	defer server.Close()
+
+	instanceType, err := getInstanceTypeFromMetadataService(server.URL + "/latest/meta-data/instance-type")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if instanceType != "m1.large" {
+		t.Errorf("expected m1.large, got %q", instanceType)
+	}
+
+	if _, err := getInstanceTypeFromMetadataService(server.URL + "/missing"); err == nil {
+		t.Errorf("expected an error when the instance type isn't available")
+	}
+}
@@ -97,6 +97,46 @@ func ParseSecretID(ref string) (string, error) {
 	return parts[len(parts)-1], nil
 }
 
+// GetSecretsByPrefix returns the secrets whose name starts with the prefix.
+func GetSecretsByPrefix(client *gophercloud.ServiceClient, prefix string) ([]secrets.Secret, error) {
+	listOpts := secrets.ListOpts{
+		SecretType: secrets.OpaqueSecret,
+	}
+	mc := metrics.NewMetricContext("secret", "list")
+	allPages, err := secrets.List(client, listOpts).AllPages(context.TODO())
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+	allSecrets, err := secrets.ExtractSecrets(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []secrets.Secret
+	for _, s := range allSecrets {
+		if strings.HasPrefix(s.Name, prefix) {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// DeleteSecret deletes the secret by its secretRef, a secret already deleted is ignored.
+func DeleteSecret(client *gophercloud.ServiceClient, secretRef string) error {
+	secretID, err := ParseSecretID(secretRef)
+	if err != nil {
+		return err
+	}
+	mc := metrics.NewMetricContext("secret", "delete")
+	err = secrets.Delete(context.TODO(), client, secretID).ExtractErr()
+	if mc.ObserveRequest(err) != nil && !cpoerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
 // DeleteSecrets deletes all the secrets that including the name string.
 func DeleteSecrets(client *gophercloud.ServiceClient, partName string) error {
 	listOpts := secrets.ListOpts{
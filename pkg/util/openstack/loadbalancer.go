@@ -46,6 +46,9 @@ const (
 	OctaviaFeatureTimeout           = 3
 	OctaviaFeatureAvailabilityZones = 4
 	OctaviaFeatureHTTPMonitorsOnUDP = 5
+	OctaviaFeatureAdditionalVIPs    = 6
+	OctaviaFeatureListenerTLS       = 7
+	OctaviaFeatureClientAuth        = 8
 
 	waitLoadbalancerInitDelay   = 1 * time.Second
 	waitLoadbalancerFactor      = 1.2
@@ -145,6 +148,27 @@ func IsOctaviaFeatureSupported(client *gophercloud.ServiceClient, feature int, l
 		if currentVer.GreaterThanOrEqual(verHTTPMonitorsOnUDP) {
 			return true
 		}
+	case OctaviaFeatureAdditionalVIPs:
+		verAdditionalVIPs, _ := version.NewVersion("v2.26")
+		if currentVer.GreaterThanOrEqual(verAdditionalVIPs) {
+			return true
+		}
+	case OctaviaFeatureListenerTLS:
+		if lbProvider == "ovn" {
+			return false
+		}
+		verListenerTLS, _ := version.NewVersion("v2.17")
+		if currentVer.GreaterThanOrEqual(verListenerTLS) {
+			return true
+		}
+	case OctaviaFeatureClientAuth:
+		if lbProvider == "ovn" {
+			return false
+		}
+		verClientAuth, _ := version.NewVersion("v2.8")
+		if currentVer.GreaterThanOrEqual(verClientAuth) {
+			return true
+		}
 	default:
 		klog.Warningf("Feature %d not recognized", feature)
 	}
@@ -178,6 +202,10 @@ func WaitActiveAndGetLoadBalancer(client *gophercloud.ServiceClient, loadbalance
 		var err error
 		loadbalancer, err = loadbalancers.Get(context.TODO(), client, loadbalancerID).Extract()
 		if mc.ObserveRequest(err) != nil {
+			if cpoerrors.IsNotFound(err) {
+				// It never becomes ACTIVE, let the callers deleting sub-resources tell it apart.
+				return false, err
+			}
 			klog.Warningf("Failed to fetch loadbalancer status from OpenStack (lbID %q): %s", loadbalancerID, err)
 			return false, nil
 		}
@@ -368,13 +396,25 @@ func DeleteListener(client *gophercloud.ServiceClient, listenerID string, lbID s
 		}
 	}
 
-	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+	// The sub-resources are gone with the load balancer.
+	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil && !cpoerrors.IsNotFound(err) {
 		return fmt.Errorf("failed to wait for load balancer %s ACTIVE after deleting listener: %v", lbID, err)
 	}
 
 	return nil
 }
 
+// GetListenerByID retrieves a listener by its ID.
+func GetListenerByID(client *gophercloud.ServiceClient, listenerID string) (*listeners.Listener, error) {
+	mc := metrics.NewMetricContext("loadbalancer_listener", "get")
+	listener, err := listeners.Get(context.TODO(), client, listenerID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return listener, nil
+}
+
 // GetListenerByName gets a listener by its name, raise error if not found or get multiple ones.
 func GetListenerByName(client *gophercloud.ServiceClient, name string, lbID string) (*listeners.Listener, error) {
 	opts := listeners.ListOpts{
@@ -442,6 +482,17 @@ func CreatePool(client *gophercloud.ServiceClient, opts pools.CreateOptsBuilder,
 	return pool, nil
 }
 
+// GetPoolByID retrieves a pool by its ID.
+func GetPoolByID(client *gophercloud.ServiceClient, poolID string) (*pools.Pool, error) {
+	mc := metrics.NewMetricContext("loadbalancer_pool", "get")
+	pool, err := pools.Get(context.TODO(), client, poolID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
 // GetPoolByName gets a pool by its name, raise error if not found or get multiple ones.
 func GetPoolByName(client *gophercloud.ServiceClient, name string, lbID string) (*pools.Pool, error) {
 	var listenerPools []pools.Pool
@@ -580,7 +631,8 @@ func DeletePool(client *gophercloud.ServiceClient, poolID string, lbID string) e
 			return fmt.Errorf("error deleting pool %s for load balancer %s: %v", poolID, lbID, err)
 		}
 	}
-	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+	// The sub-resources are gone with the load balancer.
+	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil && !cpoerrors.IsNotFound(err) {
 		return fmt.Errorf("failed to wait for load balancer %s ACTIVE after deleting pool: %v", lbID, err)
 	}
 
@@ -602,6 +654,39 @@ func BatchUpdatePoolMembers(client *gophercloud.ServiceClient, lbID string, pool
 	return nil
 }
 
+// CreateMember creates a member in the pool, leaving its other members as they are.
+func CreateMember(client *gophercloud.ServiceClient, lbID string, poolID string, opts pools.CreateMemberOpts) (*pools.Member, error) {
+	mc := metrics.NewMetricContext("loadbalancer_member", "create")
+	member, err := pools.CreateMember(context.TODO(), client, poolID, opts).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+		return nil, fmt.Errorf("failed to wait for load balancer %s ACTIVE after creating member of pool %s: %v", lbID, poolID, err)
+	}
+
+	return member, nil
+}
+
+// DeleteMember deletes a member of the pool.
+func DeleteMember(client *gophercloud.ServiceClient, lbID string, poolID string, memberID string) error {
+	mc := metrics.NewMetricContext("loadbalancer_member", "delete")
+	if err := pools.DeleteMember(context.TODO(), client, poolID, memberID).ExtractErr(); mc.ObserveRequest(err) != nil {
+		if cpoerrors.IsNotFound(err) {
+			klog.V(2).Infof("Member %s of pool %s was already deleted: %v", memberID, poolID, err)
+			return nil
+		}
+		return fmt.Errorf("error deleting member %s of pool %s: %v", memberID, poolID, err)
+	}
+
+	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+		return fmt.Errorf("failed to wait for load balancer %s ACTIVE after deleting member of pool %s: %v", lbID, poolID, err)
+	}
+
+	return nil
+}
+
 // GetL7policies retrieves all l7 policies for the given listener.
 func GetL7policies(client *gophercloud.ServiceClient, listenerID string) ([]l7policies.L7Policy, error) {
 	var policies []l7policies.L7Policy
@@ -638,6 +723,21 @@ func CreateL7Policy(client *gophercloud.ServiceClient, opts l7policies.CreateOpt
 	return policy, nil
 }
 
+// UpdateL7Policy updates a l7 policy in place.
+func UpdateL7Policy(client *gophercloud.ServiceClient, policyID string, opts l7policies.UpdateOpts, lbID string) error {
+	mc := metrics.NewMetricContext("loadbalancer_l7policy", "update")
+	_, err := l7policies.Update(context.TODO(), client, policyID, opts).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	if _, err = WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+		return fmt.Errorf("failed to wait for load balancer ACTIVE after updating l7policy: %v", err)
+	}
+
+	return nil
+}
+
 // DeleteL7policy deletes a l7 policy.
 func DeleteL7policy(client *gophercloud.ServiceClient, policyID string, lbID string) error {
 	mc := metrics.NewMetricContext("loadbalancer_l7policy", "delete")
@@ -705,7 +805,8 @@ func DeleteHealthMonitor(client *gophercloud.ServiceClient, monitorID string, lb
 		return mc.ObserveRequest(err)
 	}
 	_ = mc.ObserveRequest(nil)
-	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil {
+	// The sub-resources are gone with the load balancer.
+	if _, err := WaitActiveAndGetLoadBalancer(client, lbID); err != nil && !cpoerrors.IsNotFound(err) {
 		return fmt.Errorf("failed to wait for load balancer %s ACTIVE after deleting healthmonitor: %v", lbID, err)
 	}
 
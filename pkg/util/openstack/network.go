@@ -18,6 +18,7 @@ package openstack
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/networking/v2/extensions"
@@ -141,8 +142,33 @@ func getSubnet(networkSubnet string, subnetList []subnets.Subnet) *subnets.Subne
 	return nil
 }
 
+// PortsByDeviceIDsListOpts filters the ports of several devices, Neutron matches any of the repeated device_id
+// filters of a single request.
+type PortsByDeviceIDsListOpts struct {
+	neutronports.ListOpts
+	DeviceIDs []string
+}
+
+// ToPortListQuery formats the ListOpts into a query string, with a device_id filter for each device.
+func (opts PortsByDeviceIDsListOpts) ToPortListQuery() (string, error) {
+	query, err := opts.ListOpts.ToPortListQuery()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	for _, id := range opts.DeviceIDs {
+		params.Add("device_id", id)
+	}
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
 // GetPorts gets all the filtered ports.
-func GetPorts[PortType interface{}](ctx context.Context, client *gophercloud.ServiceClient, listOpts neutronports.ListOpts) ([]PortType, error) {
+func GetPorts[PortType interface{}](ctx context.Context, client *gophercloud.ServiceClient, listOpts neutronports.ListOptsBuilder) ([]PortType, error) {
 	mc := metrics.NewMetricContext("port", "list")
 	allPages, err := neutronports.List(client, listOpts).AllPages(ctx)
 	if mc.ObserveRequest(err) != nil {
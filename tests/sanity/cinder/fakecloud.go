@@ -8,8 +8,11 @@ import (
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/backups"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/qos"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/quotasets"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/snapshots"
 	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/v2/openstack/blockstorage/v3/volumetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
@@ -134,6 +137,24 @@ func (cloud *cloud) GetVolumesByName(name string) ([]volumes.Volume, error) {
 	return vlist, nil
 }
 
+func (cloud *cloud) GetVolumesByMetadata(metadata map[string]string) ([]volumes.Volume, error) {
+	var vlist []volumes.Volume
+	for _, v := range cloud.volumes {
+		matches := true
+		for key, value := range metadata {
+			if v.Metadata[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			vlist = append(vlist, *v)
+		}
+	}
+
+	return vlist, nil
+}
+
 func (cloud *cloud) GetVolumeByName(n string) (*volumes.Volume, error) {
 	vols, err := cloud.GetVolumesByName(n)
 	if err != nil {
@@ -345,6 +366,39 @@ func (cloud *cloud) ExpandVolume(volumeID string, status string, size int) error
 	return nil
 }
 
+func (cloud *cloud) GetVolumeQuotaUsage(projectID string) (*quotasets.QuotaUsageSet, error) {
+	unlimited := quotasets.QuotaUsage{Limit: -1}
+	return &quotasets.QuotaUsageSet{ID: projectID, Gigabytes: unlimited, PerVolumeGigabytes: unlimited}, nil
+}
+
+func (cloud *cloud) MigrateVolume(volumeID string) error {
+	if _, ok := cloud.volumes[volumeID]; !ok {
+		return notFoundError()
+	}
+	return nil
+}
+
+func (cloud *cloud) GetVolumeMigrationStatus(volumeID string) (string, error) {
+	if _, ok := cloud.volumes[volumeID]; !ok {
+		return "", notFoundError()
+	}
+	return "", nil
+}
+
+func (cloud *cloud) UpdateVolumeMetadata(volumeID string, metadata map[string]string) error {
+	vol, ok := cloud.volumes[volumeID]
+	if !ok {
+		return notFoundError()
+	}
+	if vol.Metadata == nil {
+		vol.Metadata = make(map[string]string)
+	}
+	for k, v := range metadata {
+		vol.Metadata[k] = v
+	}
+	return nil
+}
+
 func (cloud *cloud) GetMaxVolLimit() int64 {
 	return 256
 }
@@ -362,3 +416,15 @@ func (cloud *cloud) GetBlockStorageOpts() openstack.BlockStorageOpts {
 func (cloud *cloud) ResolveVolumeListToUUIDs(v string) (string, error) {
 	return v, nil
 }
+
+func (cloud *cloud) ListVolumeTypes() ([]volumetypes.VolumeType, error) {
+	return nil, nil
+}
+
+func (cloud *cloud) GetVolumeTypeEncryption(volumeTypeID string) (*volumetypes.GetEncryptionType, error) {
+	return nil, nil
+}
+
+func (cloud *cloud) GetQoSSpecs(qosSpecsID string) (*qos.QoS, error) {
+	return nil, errors.ErrNotFound
+}
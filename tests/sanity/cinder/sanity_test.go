@@ -8,6 +8,7 @@ import (
 	"github.com/kubernetes-csi/csi-test/v5/pkg/sanity"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
+	"k8s.io/utils/ptr"
 )
 
 // start sanity test for driver
@@ -29,7 +30,7 @@ func TestDriver(t *testing.T) {
 	fakemnt := GetFakeMountProvider()
 	fakemeta := &fakemetadata{}
 	fakeOpts := openstack.BlockStorageOpts{
-		RescanOnResize:        false,
+		RescanOnResize:        ptr.To(false),
 		NodeVolumeAttachLimit: 200,
 	}
 
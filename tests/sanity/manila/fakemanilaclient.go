@@ -22,7 +22,9 @@ import (
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/replicas"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/shares"
+	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetransfers"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/sharetypes"
 	"github.com/gophercloud/gophercloud/v2/openstack/sharedfilesystems/v2/snapshots"
 	"k8s.io/cloud-provider-openstack/pkg/client"
@@ -151,6 +153,38 @@ func (c fakeManilaClient) ExtendShare(shareID string, opts shares.ExtendOptsBuil
 	return nil
 }
 
+func (c fakeManilaClient) UpdateShare(shareID string, opts shares.UpdateOptsBuilder) (*shares.Share, error) {
+	share, err := c.GetShareByID(shareID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res shares.UpdateResult
+	res.Body = opts
+
+	updateOpts := &shares.UpdateOpts{}
+	if err := res.ExtractInto(updateOpts); err != nil {
+		return nil, err
+	}
+
+	if updateOpts.DisplayName != nil {
+		share.Name = *updateOpts.DisplayName
+	}
+	if updateOpts.DisplayDescription != nil {
+		share.Description = *updateOpts.DisplayDescription
+	}
+
+	return share, nil
+}
+
+func (c fakeManilaClient) GetShareTransfer(transferID string) (*sharetransfers.Transfer, error) {
+	return nil, gophercloud.ErrResourceNotFound{}
+}
+
+func (c fakeManilaClient) AcceptShareTransfer(transferID string, opts sharetransfers.AcceptOpts) error {
+	return gophercloud.ErrResourceNotFound{}
+}
+
 func (c fakeManilaClient) GetExportLocations(shareID string) ([]shares.ExportLocation, error) {
 	if !shareExists(shareID) {
 		return nil, gophercloud.ErrResourceNotFound{}
@@ -159,6 +193,10 @@ func (c fakeManilaClient) GetExportLocations(shareID string) ([]shares.ExportLoc
 	return []shares.ExportLocation{{Path: "fake-server:/fake-path"}}, nil
 }
 
+func (c fakeManilaClient) GetShareReplicas(shareID string) ([]replicas.Replica, error) {
+	return nil, nil
+}
+
 func (c fakeManilaClient) SetShareMetadata(shareID string, opts shares.SetMetadataOptsBuilder) (map[string]string, error) {
 	return nil, nil
 }
@@ -246,6 +284,23 @@ func (c fakeManilaClient) GetSnapshotByName(snapName string) (*snapshots.Snapsho
 	return c.GetSnapshotByID(snapID)
 }
 
+func (c fakeManilaClient) ListSnapshots(opts snapshots.ListOptsBuilder) ([]snapshots.Snapshot, error) {
+	listOpts := opts.(snapshots.ListOpts)
+
+	var snaps []snapshots.Snapshot
+	for _, snap := range fakeSnapshots {
+		if listOpts.ShareID != "" && snap.ShareID != listOpts.ShareID {
+			continue
+		}
+		if listOpts.Description != "" && snap.Description != listOpts.Description {
+			continue
+		}
+		snaps = append(snaps, *snap)
+	}
+
+	return snaps, nil
+}
+
 func (c fakeManilaClient) CreateSnapshot(opts snapshots.CreateOptsBuilder) (*snapshots.Snapshot, error) {
 	var res snapshots.CreateResult
 	res.Body = opts
@@ -258,9 +313,13 @@ func (c fakeManilaClient) CreateSnapshot(opts snapshots.CreateOptsBuilder) (*sna
 	snap.ID = intToStr(fakeSnapshotID)
 	snap.Status = "available"
 
-	if !shareExists(snap.ShareID) {
+	share, ok := fakeShares[strToInt(snap.ShareID)]
+	if !ok {
 		return nil, gophercloud.ErrUnexpectedResponseCode{Actual: 404}
 	}
+	snap.Size = share.Size
+	snap.ShareSize = share.Size
+	snap.ShareProto = share.ShareProto
 
 	fakeSnapshots[fakeSnapshotID] = snap
 	fakeSnapshotID++
@@ -281,3 +340,11 @@ func (c fakeManilaClient) DeleteSnapshot(snapID string) error {
 func (c fakeManilaClient) GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Message, error) {
 	return nil, nil
 }
+
+func (c fakeManilaClient) GetOrCreateEncryptionKey(name string) (string, error) {
+	return "fake-key-" + name, nil
+}
+
+func (c fakeManilaClient) DeleteEncryptionKey(keyID string) error {
+	return nil
+}
@@ -14,3 +14,7 @@ func (m *fakemetadata) GetInstanceID() (string, error) {
 func (m *fakemetadata) GetAvailabilityZone() (string, error) {
 	return FakeAvailability, nil
 }
+
+func (m *fakemetadata) GetInstanceType() (string, error) {
+	return "m1.small", nil
+}
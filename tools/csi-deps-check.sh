@@ -39,3 +39,14 @@ set -o errexit
 # go mod k8s.io/cloud-provider-openstack/pkg/util/mount
 /bin/udevadm --version
 /bin/findmnt -V
+
+# This utils are using by
+# the project quotas of cinder-csi-plugin
+/sbin/tune2fs 2>&1 | grep -q Usage
+/usr/bin/chattr 2>&1 | grep -q Usage
+/usr/sbin/xfs_quota -V
+/usr/sbin/setquota -V
+
+# This utils are using by
+# the resize of the multipath devices of cinder-csi-plugin
+/sbin/multipathd -h 2>&1 | grep -qi usage
@@ -96,3 +96,14 @@ cp /bin/btrfs* ${DEST}/bin/
 copy_deps /bin/udevadm
 copy_deps /lib/udev/rules.d
 copy_deps /bin/findmnt
+
+# This utils are using by
+# the project quotas of cinder-csi-plugin
+copy_deps /sbin/tune2fs
+copy_deps /usr/bin/chattr
+copy_deps /usr/sbin/xfs_quota
+copy_deps /usr/sbin/setquota
+
+# This utils are using by
+# the resize of the multipath devices of cinder-csi-plugin
+copy_deps /sbin/multipathd